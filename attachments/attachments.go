@@ -0,0 +1,298 @@
+// Package attachments links small documents (invoices, authorization forms) to transactions. The
+// documents are kept in an object store and only their records (name, type, size, SHA-256 digest)
+// in the ledger's storage. Uploads are checked against a size limit and an allow-list of content
+// types, and the declared type must match what the content actually is
+package attachments
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// Size limits
+const (
+	// DefaultMaxSize is the largest document accepted when ATTACHMENT_MAX_SIZE is unset
+	DefaultMaxSize = 2 << 20
+
+	// MaxSizeLimit is the largest ATTACHMENT_MAX_SIZE allowed; attachments are meant for small
+	// documents, not bulk storage
+	MaxSizeLimit = 5 << 20
+
+	// MaxRequestSize bounds the upload request body: the base64-encoded content of the largest
+	// allowed document plus room for the other fields
+	MaxRequestSize = MaxSizeLimit/3*4 + 64<<10
+
+	// maxFilenameLength bounds the stored file name
+	maxFilenameLength = 255
+)
+
+// DefaultContentTypes are the document types accepted when ATTACHMENT_TYPES is unset
+var DefaultContentTypes = []string{"application/pdf", "image/png", "image/jpeg"}
+
+// Errors reported by the Manager; repository errors are translated into them
+var (
+	ErrTooLarge           = errors.New("attachment too large")
+	ErrEmpty              = errors.New("attachment is empty")
+	ErrUnsupportedType    = errors.New("attachment content type not allowed")
+	ErrTypeMismatch       = errors.New("attachment content does not match its content type")
+	ErrInvalidFilename    = errors.New("invalid attachment filename")
+	ErrTransactionMissing = errors.New("transaction not found")
+	ErrNotFound           = errors.New("attachment not found")
+	ErrCorrupted          = errors.New("attachment content does not match its digest")
+)
+
+// Config controls where attachments are stored and what is accepted
+type Config struct {
+	// Dir is the directory of the filesystem object store; empty disables attachments
+	Dir string
+
+	// MaxSize is the largest document accepted, in bytes
+	MaxSize int64
+
+	// ContentTypes is the allow-list of document types
+	ContentTypes []string
+}
+
+// LoadConfig reads the attachment configuration from the environment
+// Variables:
+//   - ATTACHMENTS_DIR (unset): Directory documents are stored in; unset disables attachments
+//   - ATTACHMENT_MAX_SIZE (2097152): Largest document accepted, in bytes (at most 5 MiB)
+//   - ATTACHMENT_TYPES (application/pdf,image/png,image/jpeg): Comma-separated allowed types
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{Dir: os.Getenv("ATTACHMENTS_DIR"), MaxSize: DefaultMaxSize, ContentTypes: DefaultContentTypes}
+	if value := os.Getenv("ATTACHMENT_MAX_SIZE"); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 || size > MaxSizeLimit {
+			return Config{}, fmt.Errorf("invalid ATTACHMENT_MAX_SIZE %q (1 to %d bytes)", value, MaxSizeLimit)
+		}
+		config.MaxSize = size
+	}
+	if value := os.Getenv("ATTACHMENT_TYPES"); value != "" {
+		config.ContentTypes = nil
+		for _, contentType := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(contentType))
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid ATTACHMENT_TYPES %q", value)
+			}
+			config.ContentTypes = append(config.ContentTypes, mediaType)
+		}
+	}
+	return config, nil
+}
+
+// ObjectStore keeps the attachments' content under opaque keys
+// Implementations must make Put atomic: a key is either absent or holds the complete content
+type ObjectStore interface {
+	Put(ctx context.Context, key string, content []byte) error
+
+	// Get returns the content stored under key, or an error wrapping os.ErrNotExist
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	Delete(ctx context.Context, key string) error
+}
+
+// Manager validates, stores and serves attachments
+type Manager struct {
+	repo   database.AttachmentRepositoryInterface
+	store  ObjectStore
+	config Config
+}
+
+// NewManager creates a manager recording attachments in repo and their content in store
+func NewManager(repo database.AttachmentRepositoryInterface, store ObjectStore, config Config) *Manager {
+	return &Manager{repo: repo, store: store, config: config}
+}
+
+// Upload validates a document and attaches it to a transaction
+// Returns ErrTooLarge, ErrEmpty, ErrUnsupportedType, ErrTypeMismatch or ErrInvalidFilename for a
+// document that is refused, ErrTransactionMissing if the transaction does not exist
+func (m *Manager) Upload(ctx context.Context, transactionID int64, filename, contentType string, content []byte) (*models.Attachment, error) {
+	attachment, err := m.validate(transactionID, filename, contentType, content)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.store.Put(ctx, attachment.ObjectKey, content); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	recorded, err := m.repo.AddAttachment(ctx, attachment)
+	if err != nil {
+		// The content is unreachable without its record
+		if deleteErr := m.store.Delete(ctx, attachment.ObjectKey); deleteErr != nil {
+			slog.ErrorContext(ctx, "Orphaned attachment content", "object_key", attachment.ObjectKey, "error", deleteErr)
+		}
+		return nil, translate(err)
+	}
+	return recorded, nil
+}
+
+// validate checks a document against the configured limits and builds its record
+func (m *Manager) validate(transactionID int64, filename, contentType string, content []byte) (models.Attachment, error) {
+	filename = strings.TrimSpace(filename)
+	if filename == "" || len(filename) > maxFilenameLength || strings.ContainsAny(filename, "/\\\x00\r\n\"") {
+		return models.Attachment{}, ErrInvalidFilename
+	}
+	if int64(len(content)) > m.config.MaxSize {
+		return models.Attachment{}, ErrTooLarge
+	}
+	if len(content) == 0 {
+		return models.Attachment{}, ErrEmpty
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !m.allowed(mediaType) {
+		return models.Attachment{}, ErrUnsupportedType
+	}
+	// The declared type is only trusted if the content sniffs as the same type
+	if sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(content)); sniffed != mediaType {
+		return models.Attachment{}, ErrTypeMismatch
+	}
+
+	key, err := newObjectKey(transactionID)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	digest := sha256.Sum256(content)
+	return models.Attachment{
+		TransactionID: transactionID,
+		Filename:      filename,
+		ContentType:   mediaType,
+		Size:          int64(len(content)),
+		SHA256:        hex.EncodeToString(digest[:]),
+		ObjectKey:     key,
+	}, nil
+}
+
+// allowed reports whether the media type is on the allow-list
+func (m *Manager) allowed(mediaType string) bool {
+	for _, allowed := range m.config.ContentTypes {
+		if allowed == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns a transaction's attachments oldest first, or ErrTransactionMissing
+func (m *Manager) List(ctx context.Context, transactionID int64) ([]models.Attachment, error) {
+	attachments, err := m.repo.ListAttachments(ctx, transactionID)
+	return attachments, translate(err)
+}
+
+// Open returns an attachment and its content, verified against the recorded digest
+// Returns ErrNotFound if the transaction has no such attachment, ErrCorrupted if the stored
+// content is missing or was altered
+func (m *Manager) Open(ctx context.Context, transactionID, id int64) (*models.Attachment, []byte, error) {
+	attachment, err := m.repo.GetAttachment(ctx, transactionID, id)
+	if err != nil {
+		return nil, nil, translate(err)
+	}
+	content, err := m.store.Get(ctx, attachment.ObjectKey)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrCorrupted
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if digest := sha256.Sum256(content); hex.EncodeToString(digest[:]) != attachment.SHA256 {
+		return nil, nil, ErrCorrupted
+	}
+	return attachment, content, nil
+}
+
+// newObjectKey returns a fresh, unguessable key grouping the content under its transaction
+func newObjectKey(transactionID int64) (string, error) {
+	var random [16]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", fmt.Errorf("failed to generate attachment key: %w", err)
+	}
+	return fmt.Sprintf("transactions/%d/%s", transactionID, hex.EncodeToString(random[:])), nil
+}
+
+// repositoryErrors maps the attachment repository's error messages to the package errors
+var repositoryErrors = map[string]error{
+	ErrTransactionMissing.Error(): ErrTransactionMissing,
+	ErrNotFound.Error():           ErrNotFound,
+}
+
+// translate converts a repository error into the matching package error
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if sentinel, ok := repositoryErrors[err.Error()]; ok {
+		return sentinel
+	}
+	return err
+}
+
+// DirStore is an ObjectStore keeping each object as a file under a directory
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a store under dir, creating the directory if needed
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create attachments directory: %w", err)
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+// path maps a key to its file; keys are generated by newObjectKey, never taken from requests
+func (s *DirStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// Put writes the content to a temporary file and renames it into place
+func (s *DirStore) Put(ctx context.Context, key string, content []byte) error {
+	path := s.path(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get reads the object's file
+func (s *DirStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return os.ReadFile(s.path(key))
+}
+
+// Delete removes the object's file; deleting a missing object is not an error
+func (s *DirStore) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package attachments
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+
+	"github.com/shopspring/decimal"
+)
+
+var pdf = []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\n%%EOF\n")
+
+// newTestManager returns a manager on a memory store holding one transfer, its object store and
+// the transfer's transaction ID
+func newTestManager(t *testing.T, config Config) (*Manager, *DirStore, int64) {
+	t.Helper()
+	store := memory.NewStore()
+	for _, id := range []int64{1, 2} {
+		if err := store.Accounts().CreateAccount(models.NewAccount{AccountID: id, InitialBalance: decimal.NewFromInt(100)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transaction, err := store.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := NewDirStore(filepath.Join(t.TempDir(), "objects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(store.Attachments(), objects, config), objects, transaction.ID
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("ATTACHMENTS_DIR", "")
+	t.Setenv("ATTACHMENT_MAX_SIZE", "")
+	t.Setenv("ATTACHMENT_TYPES", "")
+	config, err := LoadConfig()
+	if err != nil || config.Dir != "" || config.MaxSize != DefaultMaxSize || len(config.ContentTypes) != len(DefaultContentTypes) {
+		t.Fatalf("Expected defaults, got %+v, %v", config, err)
+	}
+
+	t.Setenv("ATTACHMENT_MAX_SIZE", "1024")
+	t.Setenv("ATTACHMENT_TYPES", "application/pdf, text/csv")
+	config, err = LoadConfig()
+	if err != nil || config.MaxSize != 1024 || len(config.ContentTypes) != 2 || config.ContentTypes[1] != "text/csv" {
+		t.Errorf("Expected a 1024 byte limit and two types, got %+v, %v", config, err)
+	}
+
+	for name, value := range map[string]string{"ATTACHMENT_MAX_SIZE": "6291456", "ATTACHMENT_TYPES": "pdf;;"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := LoadConfig(); err == nil {
+				t.Errorf("Expected an error for %s=%q", name, value)
+			}
+		})
+	}
+}
+
+func TestManager_Upload(t *testing.T) {
+	manager, _, transactionID := newTestManager(t, Config{MaxSize: 128, ContentTypes: DefaultContentTypes})
+	ctx := context.Background()
+
+	attachment, err := manager.Upload(ctx, transactionID, "invoice.pdf", "application/pdf", pdf)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if attachment.ID == 0 || attachment.Size != int64(len(pdf)) || len(attachment.SHA256) != 64 {
+		t.Errorf("Unexpected attachment %+v", attachment)
+	}
+
+	for _, tc := range []struct {
+		name, filename, contentType string
+		content                     []byte
+		want                        error
+	}{
+		{"too large", "big.pdf", "application/pdf", append(append([]byte{}, pdf...), make([]byte, 128)...), ErrTooLarge},
+		{"empty", "empty.pdf", "application/pdf", nil, ErrEmpty},
+		{"type not allowed", "notes.txt", "text/plain", []byte("notes"), ErrUnsupportedType},
+		{"type mismatch", "invoice.png", "image/png", pdf, ErrTypeMismatch},
+		{"path in filename", "../invoice.pdf", "application/pdf", pdf, ErrInvalidFilename},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := manager.Upload(ctx, transactionID, tc.filename, tc.contentType, tc.content); !errors.Is(err, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+
+	if _, err := manager.Upload(ctx, transactionID+100, "invoice.pdf", "application/pdf", pdf); !errors.Is(err, ErrTransactionMissing) {
+		t.Errorf("Expected ErrTransactionMissing, got %v", err)
+	}
+	if list, err := manager.List(ctx, transactionID); err != nil || len(list) != 1 {
+		t.Errorf("Expected only the accepted attachment, got %d, %v", len(list), err)
+	}
+}
+
+func TestManager_Open(t *testing.T) {
+	manager, objects, transactionID := newTestManager(t, Config{MaxSize: DefaultMaxSize, ContentTypes: DefaultContentTypes})
+	ctx := context.Background()
+	attachment, err := manager.Upload(ctx, transactionID, "invoice.pdf", "application/pdf; charset=binary", pdf)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	opened, content, err := manager.Open(ctx, transactionID, attachment.ID)
+	if err != nil || string(content) != string(pdf) || opened.ContentType != "application/pdf" {
+		t.Fatalf("Expected the stored document, got %+v, %v", opened, err)
+	}
+	if _, _, err := manager.Open(ctx, transactionID+1, attachment.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another transaction, got %v", err)
+	}
+
+	// Altered content fails the digest check
+	if err := os.WriteFile(objects.path(attachment.ObjectKey), []byte("%PDF-1.4 altered"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := manager.Open(ctx, transactionID, attachment.ID); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Expected ErrCorrupted for altered content, got %v", err)
+	}
+	if err := objects.Delete(ctx, attachment.ObjectKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := manager.Open(ctx, transactionID, attachment.ID); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Expected ErrCorrupted for missing content, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Package balances keeps the balance history served by GET /accounts/{account_id}/balance-history.
+// Account creation and every transfer record the balances they leave in their own commit; the
+// Snapshotter adds periodic snapshots of accounts that had none recently, so quiet accounts still
+// have points to chart
+package balances
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"internal-transfers/database"
+)
+
+// DefaultSnapshotInterval is how often quiet accounts' balances are snapshotted
+const DefaultSnapshotInterval = time.Hour
+
+// Config controls periodic balance snapshots
+type Config struct {
+	// SnapshotInterval is how often every account without a snapshot in the last interval is
+	// snapshotted; zero disables periodic snapshots
+	SnapshotInterval time.Duration
+}
+
+// LoadConfig reads the balance history configuration from the environment
+// Variables:
+//   - BALANCE_SNAPSHOT_INTERVAL (1h): How often quiet accounts are snapshotted; 0 disables it
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{SnapshotInterval: DefaultSnapshotInterval}
+	if value := os.Getenv("BALANCE_SNAPSHOT_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid BALANCE_SNAPSHOT_INTERVAL %q", value)
+		}
+		config.SnapshotInterval = d
+	}
+	return config, nil
+}
+
+// Snapshotter writes the periodic balance snapshots
+// Several instances may share the storage: an account snapshotted by one within the interval is
+// skipped by the others
+type Snapshotter struct {
+	repo database.BalanceHistoryRepositoryInterface
+	now  func() time.Time
+}
+
+// NewSnapshotter creates a snapshotter writing to repo
+func NewSnapshotter(repo database.BalanceHistoryRepositoryInterface) *Snapshotter {
+	return &Snapshotter{repo: repo, now: time.Now}
+}
+
+// SnapshotQuiet snapshots every account without a snapshot in the last interval
+// Returns the number of snapshots written
+func (s *Snapshotter) SnapshotQuiet(ctx context.Context, interval time.Duration) (int64, error) {
+	return s.repo.SnapshotBalances(ctx, s.now().Add(-interval))
+}
+
+// Run snapshots quiet accounts every interval until ctx is cancelled
+// The first run is one interval after the start, so restarts do not add snapshots
+func (s *Snapshotter) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		written, err := s.SnapshotQuiet(ctx, interval)
+		if err != nil {
+			slog.Error("Balance snapshot error", "error", err)
+			continue
+		}
+		slog.Debug("Balances snapshotted", "accounts", written)
+	}
+}
@@ -0,0 +1,60 @@
+package balances
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("BALANCE_SNAPSHOT_INTERVAL", "")
+	if config, err := LoadConfig(); err != nil || config.SnapshotInterval != DefaultSnapshotInterval {
+		t.Errorf("Expected the default interval, got %+v (%v)", config, err)
+	}
+	t.Setenv("BALANCE_SNAPSHOT_INTERVAL", "0")
+	if config, err := LoadConfig(); err != nil || config.SnapshotInterval != 0 {
+		t.Errorf("Expected periodic snapshots to be disabled, got %+v (%v)", config, err)
+	}
+	for _, value := range []string{"hourly", "-1h"} {
+		t.Setenv("BALANCE_SNAPSHOT_INTERVAL", value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("BALANCE_SNAPSHOT_INTERVAL %q: expected an error", value)
+		}
+	}
+}
+
+func TestSnapshotter_SnapshotQuiet(t *testing.T) {
+	store := memory.NewStore()
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	snapshotter := NewSnapshotter(store.BalanceHistory())
+	now := time.Now().Add(2 * time.Hour)
+	snapshotter.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Both opening snapshots are older than the interval
+	if written, err := snapshotter.SnapshotQuiet(ctx, time.Hour); err != nil || written != 2 {
+		t.Fatalf("Expected 2 periodic snapshots, got %d (%v)", written, err)
+	}
+	// Account 2 has a recent transfer snapshot, account 1 a recent periodic one
+	if _, err := store.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)}); err != nil {
+		t.Fatalf("Unexpected transfer error: %v", err)
+	}
+	if written, err := snapshotter.SnapshotQuiet(ctx, 3*time.Hour); err != nil || written != 0 {
+		t.Errorf("Expected no snapshots for accounts with recent ones, got %d (%v)", written, err)
+	}
+
+	history, _ := store.BalanceHistory().ListBalanceSnapshots(ctx, models.BalanceHistoryFilter{AccountID: 2}, 10)
+	var sources []string
+	for _, snapshot := range history {
+		sources = append(sources, snapshot.Source+" "+snapshot.Balance.String())
+	}
+	if len(sources) != 3 || sources[0] != "opened 0" || sources[1] != "periodic 0" || sources[2] != "transfer 30" {
+		t.Errorf("Unexpected history of account 2: %v", sources)
+	}
+}
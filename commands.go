@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/compat"
+	"internal-transfers/database"
+	"internal-transfers/handlers"
+	"internal-transfers/models"
+	"internal-transfers/protogen"
+	"internal-transfers/reconcile"
+	"internal-transfers/retention"
+	"internal-transfers/routes"
+	"internal-transfers/sdkgen"
+	"internal-transfers/synthetic"
+)
+
+// runCommand executes a one-off administrative command instead of starting the server
+// Supported commands:
+//   - migrate up: Apply all pending migrations
+//   - migrate down [steps]: Roll back the last N migrations (default 1)
+//   - migrate version: Print the current schema version
+//   - doctor: Check the environment and print an actionable report (see runDoctorCommand)
+//   - sdk [-spec openapi.json] [-version X] <dir>: Generate the TypeScript and Python client SDKs
+//     (see runSDKCommand)
+//   - reconcile [-json]: Report accounts whose balance differs from their transactions (see
+//     runReconcileCommand)
+//   - retention [-apply] [-json]: Report, or with -apply purge, data past its retention period
+//     (see runRetentionCommand)
+//   - generate [flags] [-apply]: Generate a synthetic ledger, and with -apply write it into the
+//     database (see runGenerateCommand)
+//   - compat-check [-version X] [-write file] [previous.json]: Check the API and migrations against the previous
+//     release for breaking changes (see runCompatCheckCommand)
+//   - proto [-out file]: Generate the Protocol Buffers definitions of the API and event bodies (see
+//     runProtoCommand)
+func runCommand(args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrateCommand(args[1:])
+	case "doctor":
+		return runDoctorCommand(args[1:])
+	case "sdk":
+		return runSDKCommand(args[1:])
+	case "reconcile":
+		return runReconcileCommand(args[1:])
+	case "retention":
+		return runRetentionCommand(args[1:])
+	case "generate":
+		return runGenerateCommand(args[1:])
+	case "compat-check":
+		return runCompatCheckCommand(args[1:])
+	case "proto":
+		return runProtoCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runMigrateCommand handles the migrate subcommands
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|version")
+	}
+
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid rollback steps %q", args[1])
+		}
+		steps = n
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		return database.Migrate(db)
+	case "down":
+		return database.MigrateDown(db, steps)
+	case "version":
+		version, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q", args[0])
+	}
+}
+
+// sdkExcluded lists the routes the SDKs leave out: long-lived streams and the metrics dump, which
+// a request/response client cannot usefully call
+var sdkExcluded = map[string]bool{"stream_transactions": true, "balance_feed": true, "metrics": true}
+
+// runSDKCommand generates the client SDKs into <dir>/typescript and <dir>/python
+// They are generated from this binary's route table by default, or from an OpenAPI document saved
+// from a running service with -spec; -version overrides the package version (the API version)
+func runSDKCommand(args []string) error {
+	flags := flag.NewFlagSet("sdk", flag.ContinueOnError)
+	spec := flags.String("spec", "", "OpenAPI document to generate from (default: this binary's routes)")
+	version := flags.String("version", "", "package version (default: the document's info.version)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: sdk [-spec openapi.json] [-version X] <dir>")
+	}
+
+	var data []byte
+	var err error
+	if *spec != "" {
+		data, err = os.ReadFile(*spec)
+	} else {
+		// The handler is never invoked; the routes only contribute their descriptions
+		registry := currentAPI(&handlers.Handler{}).Filter(func(route routes.Route) bool {
+			return !sdkExcluded[route.Name]
+		})
+		data, err = json.Marshal(registry.OpenAPI(apiTitle, apiVersion))
+	}
+	if err != nil {
+		return err
+	}
+	doc, err := sdkgen.Parse(data)
+	if err != nil {
+		return err
+	}
+	if *version == "" {
+		*version = doc.Info.Version
+	}
+	if err := sdkgen.Generate(flags.Arg(0), doc, sdkgen.Options{Version: *version}); err != nil {
+		return err
+	}
+	fmt.Printf("Generated %s and %s %s in %s\n", sdkgen.NPMPackage, sdkgen.PythonPackage, *version, flags.Arg(0))
+	return nil
+}
+
+// runReconcileCommand checks every account's stored balance against its initial balance and
+// transactions in the storage selected by STORAGE, printing the discrepancy report as text or,
+// with -json, as GET /admin/reconciliation returns it
+// Fails when any account has a discrepancy, so it can gate scheduled jobs
+func runReconcileCommand(args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: reconcile [-json]")
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	report, err := reconcile.NewReconciler(storage.Reconciliation()).Reconcile(context.Background())
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(models.NewReconciliationResponse(*report)); err != nil {
+			return err
+		}
+	} else {
+		reconcile.WriteReport(os.Stdout, report)
+	}
+	if len(report.Discrepancies) > 0 {
+		return fmt.Errorf("%d accounts with balance discrepancies", len(report.Discrepancies))
+	}
+	return nil
+}
+
+// runRetentionCommand enforces the retention policies (see retention.LoadConfig) once against the
+// storage selected by STORAGE
+// Without -apply it is a dry run reporting what would be purged; -json prints the report as GET
+// /admin/retention returns it
+func runRetentionCommand(args []string) error {
+	flags := flag.NewFlagSet("retention", flag.ContinueOnError)
+	apply := flags.Bool("apply", false, "purge the expired data instead of only reporting it")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: retention [-apply] [-json]")
+	}
+
+	config, err := retention.LoadConfig()
+	if err != nil {
+		return err
+	}
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+	if storage.Retention() == nil {
+		return fmt.Errorf("storage %q keeps no data to purge", getStorage())
+	}
+
+	// A class that failed is reported in err; the others' results are still printed
+	report, err := retention.NewEnforcer(storage.Retention(), config).Enforce(context.Background(), !*apply)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(models.NewRetentionReportResponse(*report)); err != nil {
+			return err
+		}
+	} else {
+		retention.WriteReport(os.Stdout, report)
+	}
+	return err
+}
+
+// runGenerateCommand generates a synthetic ledger of many accounts and Zipf-distributed transfer
+// traffic (see package synthetic), for validating indexes, partitioning and hot-account sharding
+// at production volumes before a rollout
+// Without -apply it is a dry run printing how concentrated the traffic would be; -apply writes it
+// straight into the PostgreSQL database of the DB_* settings with COPY, bypassing the API. Meant
+// for development and staging databases only: the accounts are real rows that stay until the
+// database is dropped
+func runGenerateCommand(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	config := synthetic.DefaultConfig()
+	flags.IntVar(&config.Accounts, "accounts", synthetic.DefaultAccounts, "number of accounts")
+	flags.Int64Var(&config.FirstAccountID, "first-id", synthetic.DefaultFirstAccountID, "ID of the first account; the others follow consecutively")
+	balance := flags.String("balance", synthetic.DefaultInitialBalance.String(), "opening balance of every account")
+	flags.IntVar(&config.Transfers, "transfers", synthetic.DefaultTransfers, "number of transfers")
+	flags.Float64Var(&config.Skew, "skew", synthetic.DefaultSkew, "Zipf exponent of the accounts' share of transfers (> 1; higher is hotter)")
+	flags.IntVar(&config.Days, "days", synthetic.DefaultDays, "days before now the transfers are spread over")
+	flags.Uint64Var(&config.Seed, "seed", synthetic.DefaultSeed, "random seed; the same flags and seed generate the same ledger")
+	apply := flags.Bool("apply", false, "write the ledger into the database instead of only reporting it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: generate [-accounts N] [-first-id ID] [-balance B] [-transfers N] [-skew S] [-days D] [-seed N] [-apply]")
+	}
+	var err error
+	if config.InitialBalance, err = decimal.NewFromString(*balance); err != nil {
+		return fmt.Errorf("invalid balance %q", *balance)
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	generator := synthetic.NewGenerator(config, time.Now().UTC())
+	if !*apply {
+		for _, ok := generator.NextTransfer(); ok; _, ok = generator.NextTransfer() {
+		}
+		fmt.Println("Dry run (nothing written)")
+		synthetic.WriteSummary(os.Stdout, generator.Summary())
+		return nil
+	}
+
+	pool, err := database.InitPool()
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	db := database.OpenDB(pool)
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		return err
+	}
+	result, err := database.BulkLoad(context.Background(), pool, generator)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d accounts and %d transfers in %s\n", result.Accounts, result.Transfers, result.Duration.Round(time.Second))
+	synthetic.WriteSummary(os.Stdout, generator.Summary())
+	return nil
+}
+
+// runCompatCheckCommand compares this binary's public contract, its OpenAPI document and migration
+// set (see compat.Artifact), against the artifact recorded for the previous release, printing
+// every change and failing if any is breaking, so a release with breaking changes stops here
+// -write saves this binary's artifact, to be kept with the release it is built for, as -version
+// (default: the API version); with only -write nothing is compared
+func runCompatCheckCommand(args []string) error {
+	flags := flag.NewFlagSet("compat-check", flag.ContinueOnError)
+	write := flags.String("write", "", "file to save this binary's compatibility artifact to")
+	version := flags.String("version", "", "release version recorded in the artifact (default: the API version)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 || (flags.NArg() == 0 && *write == "") {
+		return fmt.Errorf("usage: compat-check [-version X] [-write file] [previous.json]")
+	}
+
+	migrations, err := database.LoadMigrations()
+	if err != nil {
+		return err
+	}
+	// The handler is never invoked; the routes only contribute their descriptions
+	registry := currentAPI(&handlers.Handler{})
+	current, err := compat.NewArtifact(registry.OpenAPI(apiTitle, apiVersion), migrations)
+	if err != nil {
+		return err
+	}
+	if *version != "" {
+		current.Version = *version
+	}
+
+	if flags.NArg() == 1 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		previous, err := compat.Read(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		report := compat.Check(previous, current)
+		compat.WriteReport(os.Stdout, report)
+		if report.Breaking() > 0 {
+			return fmt.Errorf("%d breaking changes since %s", report.Breaking(), previous.Version)
+		}
+	}
+
+	if *write != "" {
+		file, err := os.Create(*write)
+		if err != nil {
+			return err
+		}
+		if err := compat.Write(file, current); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote the compatibility artifact of %s to %s\n", current.Version, *write)
+	}
+	return nil
+}
+
+// protoFile is where the Protocol Buffers definitions are checked in
+const protoFile = "proto/transfers/v1/transfers.proto"
+
+// eventPayloads lists the payload type of each outbox event, as enqueued by the database package
+var eventPayloads = []struct {
+	eventType string
+	payload   any
+}{
+	{database.EventAccountCreated, models.AccountCreatedEvent{}},
+	{database.EventTransactionCompleted, models.TransactionResponse{}},
+	{database.EventAccountAdjusted, models.AdjustmentResponse{}},
+	{database.EventWalletOpened, models.WalletResponse{}},
+	{database.EventRecurringFailed, models.RecurringEscalation{}},
+}
+
+// protoDefinitions builds the Protocol Buffers definitions of this binary's request and response
+// bodies and event payloads
+func protoDefinitions() (*protogen.File, error) {
+	file := protogen.NewFile("transfers.v1", "internal-transfers/proto/transfers/v1;transfersv1")
+	// The handler is never invoked; the routes only contribute their body types
+	for _, route := range currentAPI(&handlers.Handler{}).Routes() {
+		if route.Request != nil {
+			if err := file.Add(route.Request, "Request of "+route.Name); err != nil {
+				return nil, err
+			}
+		}
+		if route.Response != nil {
+			if err := file.Add(route.Response, "Response of "+route.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, event := range eventPayloads {
+		if err := file.Add(event.payload, "Payload of the "+event.eventType+" event"); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+//go:generate go run . proto
+
+// runProtoCommand regenerates the Protocol Buffers definitions of the API and event bodies into
+// -out (default proto/transfers/v1/transfers.proto), keeping the field numbers the file already
+// assigns (see protogen)
+func runProtoCommand(args []string) error {
+	flags := flag.NewFlagSet("proto", flag.ContinueOnError)
+	out := flags.String("out", protoFile, "file to write the definitions to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: proto [-out file]")
+	}
+
+	file, err := protoDefinitions()
+	if err != nil {
+		return err
+	}
+	previous, err := os.ReadFile(*out)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content, err := file.Generate(previous)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, content, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the Protocol Buffers definitions to %s\n", *out)
+	return nil
+}
@@ -0,0 +1,421 @@
+// Package compat detects breaking changes between releases. Each release records its public
+// contract in an Artifact: the OpenAPI document of the HTTP API and the migration set. Comparing
+// the current build against the previous release's artifact reports removed operations and
+// fields, narrowed or changed types, rewritten migrations and new migrations that destroy or
+// rename data, so the release can be stopped before clients or deployed databases break
+package compat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"internal-transfers/database"
+)
+
+// Artifact is the public contract of a release
+type Artifact struct {
+	// Version is the release version, by default the OpenAPI document's info.version
+	Version    string         `json:"version"`
+	OpenAPI    map[string]any `json:"openapi"`
+	Migrations []Migration    `json:"migrations"`
+}
+
+// Migration identifies a released migration
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	// Checksum is the SHA-256 of the up SQL
+	Checksum string `json:"checksum"`
+
+	// up is the SQL, only known for migrations of the current build
+	up string
+}
+
+// NewArtifact records the contract of the current build from its OpenAPI document and migrations
+func NewArtifact(openapi map[string]any, migrations []database.Migration) (Artifact, error) {
+	// Round trip through JSON so the document compares like one read from a file
+	data, err := json.Marshal(openapi)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to encode OpenAPI document: %w", err)
+	}
+	artifact := Artifact{}
+	if err := json.Unmarshal(data, &artifact.OpenAPI); err != nil {
+		return Artifact{}, fmt.Errorf("failed to decode OpenAPI document: %w", err)
+	}
+	if info, ok := artifact.OpenAPI["info"].(map[string]any); ok {
+		artifact.Version, _ = info["version"].(string)
+	}
+	for _, m := range migrations {
+		sum := sha256.Sum256([]byte(m.Up))
+		artifact.Migrations = append(artifact.Migrations, Migration{
+			Version: m.Version, Name: m.Name, Checksum: hex.EncodeToString(sum[:]), up: m.Up,
+		})
+	}
+	return artifact, nil
+}
+
+// Read decodes an artifact written by Write
+func Read(r io.Reader) (Artifact, error) {
+	var artifact Artifact
+	if err := json.NewDecoder(r).Decode(&artifact); err != nil {
+		return Artifact{}, fmt.Errorf("invalid compatibility artifact: %w", err)
+	}
+	if artifact.OpenAPI == nil {
+		return Artifact{}, fmt.Errorf("invalid compatibility artifact: no OpenAPI document")
+	}
+	return artifact, nil
+}
+
+// Write encodes the artifact as indented JSON, to be kept with the release
+func Write(w io.Writer, artifact Artifact) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(artifact)
+}
+
+// Change is one difference between two releases
+type Change struct {
+	Breaking bool
+	// Location names what changed, e.g. "POST /transactions request.amount" or "migration 33"
+	Location string
+	Message  string
+}
+
+// Report lists the changes from the previous release to the current build
+type Report struct {
+	Previous string
+	Current  string
+	Changes  []Change
+}
+
+// Breaking returns the number of breaking changes
+func (r Report) Breaking() int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Breaking {
+			n++
+		}
+	}
+	return n
+}
+
+// Check compares the current build against the previous release
+// Breaking changes:
+//   - An operation is removed, or its success status changes
+//   - A request or response field is removed, or its type changes
+//   - An integer request field is narrowed (int64 to int32) or an integer response field is widened
+//     (int32 to int64, which clients may not hold); any other format change
+//   - A released migration is removed, renamed or modified
+//   - A new migration drops, truncates, deletes from or renames a table, or drops, renames or
+//     retypes a column
+//
+// Added operations, fields and migrations are reported as compatible changes
+func Check(previous, current Artifact) Report {
+	report := Report{Previous: previous.Version, Current: current.Version}
+	c := checker{report: &report}
+	c.operations(previous.OpenAPI, current.OpenAPI)
+	c.migrations(previous.Migrations, current.Migrations)
+	return report
+}
+
+// checker collects the changes of one Check
+type checker struct {
+	report *Report
+}
+
+func (c checker) breaking(location, format string, args ...any) {
+	c.report.Changes = append(c.report.Changes, Change{Breaking: true, Location: location, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c checker) compatible(location, format string, args ...any) {
+	c.report.Changes = append(c.report.Changes, Change{Location: location, Message: fmt.Sprintf(format, args...)})
+}
+
+// operations compares the operations of two OpenAPI documents
+func (c checker) operations(previous, current map[string]any) {
+	before, after := operationsOf(previous), operationsOf(current)
+	for _, key := range sortedKeys(before) {
+		op, ok := after[key]
+		if !ok {
+			c.breaking(key, "operation removed")
+			continue
+		}
+		c.operation(key, before[key], op)
+	}
+	for _, key := range sortedKeys(after) {
+		if _, ok := before[key]; !ok {
+			c.compatible(key, "operation added")
+		}
+	}
+}
+
+// operationsOf indexes a document's operations by "METHOD /path"
+func operationsOf(doc map[string]any) map[string]map[string]any {
+	operations := make(map[string]map[string]any)
+	paths, _ := doc["paths"].(map[string]any)
+	for path, item := range paths {
+		methods, _ := item.(map[string]any)
+		for method, op := range methods {
+			if op, ok := op.(map[string]any); ok {
+				operations[strings.ToUpper(method)+" "+path] = op
+			}
+		}
+	}
+	return operations
+}
+
+// operation compares one operation's request body and success responses
+func (c checker) operation(key string, previous, current map[string]any) {
+	switch before, after := requestSchema(previous), requestSchema(current); {
+	case before == nil && after != nil:
+		c.breaking(key+" request", "request body required")
+	case before != nil && after == nil:
+		c.compatible(key+" request", "request body no longer read")
+	default:
+		c.schema(key+" request", before, after, true)
+	}
+
+	before, _ := previous["responses"].(map[string]any)
+	after, _ := current["responses"].(map[string]any)
+	for _, status := range sortedKeys(before) {
+		response, ok := after[status]
+		if !ok {
+			c.breaking(key, "success status %s changed to %s", status, strings.Join(sortedKeys(after), ", "))
+			continue
+		}
+		switch before, after := responseSchema(before[status]), responseSchema(response); {
+		case before != nil && after == nil:
+			c.breaking(key+" response", "response body removed")
+		case before == nil && after != nil:
+			c.compatible(key+" response", "response body added")
+		default:
+			c.schema(key+" response", before, after, false)
+		}
+	}
+}
+
+// requestSchema returns the JSON schema of an operation's request body, or nil
+func requestSchema(op map[string]any) map[string]any {
+	body, _ := op["requestBody"].(map[string]any)
+	return jsonSchema(body)
+}
+
+// responseSchema returns the JSON schema of a response object, or nil
+func responseSchema(response any) map[string]any {
+	object, _ := response.(map[string]any)
+	return jsonSchema(object)
+}
+
+// jsonSchema returns the application/json schema of a request body or response object
+func jsonSchema(object map[string]any) map[string]any {
+	content, _ := object["content"].(map[string]any)
+	media, _ := content["application/json"].(map[string]any)
+	schema, _ := media["schema"].(map[string]any)
+	return schema
+}
+
+// schema compares two versions of a schema at location; request is true for what clients send
+func (c checker) schema(location string, previous, current map[string]any, request bool) {
+	// A schema without a type accepts any value
+	if previous["type"] == nil {
+		return
+	}
+	if current["type"] == nil {
+		if request {
+			c.compatible(location, "type widened from %s to any", describe(previous))
+		} else {
+			c.breaking(location, "type widened from %s to any", describe(previous))
+		}
+		return
+	}
+
+	before, after := previous["type"], current["type"]
+	if before != after {
+		c.breaking(location, "type changed from %v to %v", describe(previous), describe(current))
+		return
+	}
+	if previous["format"] != current["format"] {
+		c.format(location, previous, current, request)
+	}
+
+	switch before {
+	case "object":
+		c.properties(location, previous, current, request)
+		if items, ok := previous["additionalProperties"].(map[string]any); ok {
+			next, _ := current["additionalProperties"].(map[string]any)
+			c.schema(location+"{}", items, next, request)
+		}
+	case "array":
+		items, _ := previous["items"].(map[string]any)
+		next, _ := current["items"].(map[string]any)
+		c.schema(location+"[]", items, next, request)
+	}
+}
+
+// format reports a changed format of the same type
+func (c checker) format(location string, previous, current map[string]any, request bool) {
+	before, after := previous["format"], current["format"]
+	if previous["type"] == "integer" {
+		// Requests may accept more and responses may send less without breaking clients
+		widened := before == "int32" && after == "int64"
+		narrowed := before == "int64" && after == "int32"
+		if (request && widened) || (!request && narrowed) {
+			c.compatible(location, "format changed from %v to %v", before, after)
+			return
+		}
+		if narrowed {
+			c.breaking(location, "narrowed from %v to %v", before, after)
+			return
+		}
+		if widened {
+			c.breaking(location, "widened from %v to %v, which clients may not hold", before, after)
+			return
+		}
+	}
+	c.breaking(location, "type changed from %v to %v", describe(previous), describe(current))
+}
+
+// properties compares the fields of two object schemas
+func (c checker) properties(location string, previous, current map[string]any, request bool) {
+	before, _ := previous["properties"].(map[string]any)
+	after, _ := current["properties"].(map[string]any)
+	for _, name := range sortedKeys(before) {
+		field, ok := after[name]
+		if !ok {
+			c.breaking(location+"."+name, "field removed")
+			continue
+		}
+		prev, _ := before[name].(map[string]any)
+		next, _ := field.(map[string]any)
+		c.schema(location+"."+name, prev, next, request)
+	}
+	for _, name := range sortedKeys(after) {
+		if _, ok := before[name]; !ok {
+			c.compatible(location+"."+name, "field added")
+		}
+	}
+}
+
+// describe names a schema's type with its format, e.g. "integer (int64)"
+func describe(schema map[string]any) string {
+	description := fmt.Sprint(schema["type"])
+	if schema["type"] == nil {
+		description = "any"
+	}
+	if format, ok := schema["format"].(string); ok && format != "" {
+		description += " (" + format + ")"
+	}
+	return description
+}
+
+// destructiveStatements match the statements of a migration that destroy or rename data, which
+// the previous release's instances still read during a rolling deploy and which a rollback cannot
+// restore
+var destructiveStatements = []struct {
+	pattern *regexp.Regexp
+	message string
+}{
+	{regexp.MustCompile(`^DROP (TABLE|VIEW|MATERIALIZED VIEW|SCHEMA)\b`), "drops a table, view or schema"},
+	{regexp.MustCompile(`^TRUNCATE\b`), "truncates a table"},
+	{regexp.MustCompile(`^DELETE FROM\b`), "deletes rows"},
+	{regexp.MustCompile(`^ALTER TABLE\b.*\bALTER (COLUMN )?\w+ (SET DATA )?TYPE\b`), "changes a column type"},
+	{regexp.MustCompile(`^ALTER TABLE\b.*\bRENAME (TO|COLUMN|\w+ TO)\b`), "renames a table or column"},
+}
+
+// dropClause matches the DROP clauses of an ALTER TABLE statement, capturing what follows
+var dropClause = regexp.MustCompile(`\bDROP (?:IF EXISTS )?(\w+)`)
+
+// keptByDrop lists the ALTER TABLE ... DROP clauses that keep every column, e.g. DROP CONSTRAINT
+// and ALTER COLUMN ... DROP DEFAULT; any other drops a column ("DROP [COLUMN] name")
+var keptByDrop = map[string]bool{"CONSTRAINT": true, "DEFAULT": true, "NOT": true, "IDENTITY": true, "EXPRESSION": true}
+
+// lineComment matches a SQL comment to the end of the line
+var lineComment = regexp.MustCompile(`--[^\n]*`)
+
+// destructive returns what the statements of a migration's up SQL destroy
+func destructive(sql string) []string {
+	var found []string
+	for _, statement := range strings.Split(lineComment.ReplaceAllString(sql, ""), ";") {
+		statement = strings.ToUpper(strings.Join(strings.Fields(statement), " "))
+		for _, d := range destructiveStatements {
+			if d.pattern.MatchString(statement) {
+				found = append(found, d.message)
+			}
+		}
+		if strings.HasPrefix(statement, "ALTER TABLE ") {
+			for _, match := range dropClause.FindAllStringSubmatch(statement, -1) {
+				if !keptByDrop[match[1]] {
+					found = append(found, "drops a column")
+					break
+				}
+			}
+		}
+	}
+	return found
+}
+
+// migrations compares the migration sets
+func (c checker) migrations(previous, current []Migration) {
+	byVersion := make(map[int]Migration, len(current))
+	for _, m := range current {
+		byVersion[m.Version] = m
+	}
+	released := 0
+	for _, m := range previous {
+		released = max(released, m.Version)
+		location := fmt.Sprintf("migration %d", m.Version)
+		next, ok := byVersion[m.Version]
+		switch {
+		case !ok:
+			c.breaking(location, "released migration %s removed", m.Name)
+		case next.Name != m.Name:
+			c.breaking(location, "released migration %s renamed to %s", m.Name, next.Name)
+		case next.Checksum != m.Checksum:
+			c.breaking(location, "released migration %s modified; deployed databases will not run it again", m.Name)
+		}
+	}
+	for _, m := range current {
+		if m.Version <= released {
+			continue
+		}
+		location := fmt.Sprintf("migration %d", m.Version)
+		found := destructive(m.up)
+		for _, message := range found {
+			c.breaking(location, "%s %s", m.Name, message)
+		}
+		if len(found) == 0 {
+			c.compatible(location, "%s added", m.Name)
+		}
+	}
+}
+
+// WriteReport prints the report as text, breaking changes first
+func WriteReport(w io.Writer, report Report) {
+	fmt.Fprintf(w, "Compared %s with %s\n", report.Current, report.Previous)
+	changes := append([]Change(nil), report.Changes...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Breaking && !changes[j].Breaking })
+	for _, change := range changes {
+		label := "ok"
+		if change.Breaking {
+			label = "BREAKING"
+		}
+		fmt.Fprintf(w, "%-8s  %s: %s\n", label, change.Location, change.Message)
+	}
+	fmt.Fprintf(w, "%d breaking, %d compatible changes\n", report.Breaking(), len(report.Changes)-report.Breaking())
+}
+
+// sortedKeys returns a map's keys in order, so reports are stable
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,225 @@
+package compat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"internal-transfers/database"
+)
+
+// document builds an OpenAPI document with one operation whose request and response are the
+// given object properties
+func document(request, response map[string]any) map[string]any {
+	return map[string]any{
+		"info": map[string]any{"version": "1.0.0"},
+		"paths": map[string]any{
+			"/transactions": map[string]any{
+				"post": map[string]any{
+					"requestBody": map[string]any{"content": map[string]any{"application/json": map[string]any{
+						"schema": map[string]any{"type": "object", "properties": request},
+					}}},
+					"responses": map[string]any{"201": map[string]any{"content": map[string]any{"application/json": map[string]any{
+						"schema": map[string]any{"type": "object", "properties": response},
+					}}}},
+				},
+			},
+		},
+	}
+}
+
+var (
+	int32Schema   = map[string]any{"type": "integer", "format": "int32"}
+	int64Schema   = map[string]any{"type": "integer", "format": "int64"}
+	stringSchema  = map[string]any{"type": "string"}
+	decimalSchema = map[string]any{"type": "string", "format": "decimal"}
+)
+
+// artifact builds an artifact of a document and migrations
+func artifact(t *testing.T, doc map[string]any, migrations ...database.Migration) Artifact {
+	t.Helper()
+	a, err := NewArtifact(doc, migrations)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return a
+}
+
+// breaking returns the breaking changes of a report as "location: message"
+func breaking(report Report) []string {
+	var changes []string
+	for _, c := range report.Changes {
+		if c.Breaking {
+			changes = append(changes, c.Location+": "+c.Message)
+		}
+	}
+	return changes
+}
+
+func TestCheck_OpenAPI(t *testing.T) {
+	previous := document(
+		map[string]any{"source_account_id": int64Schema, "amount": stringSchema, "limit": int32Schema},
+		map[string]any{"id": int32Schema, "amount": decimalSchema, "tags": map[string]any{"type": "array", "items": stringSchema}},
+	)
+
+	testCases := []struct {
+		name     string
+		current  map[string]any
+		breaking []string
+	}{
+		{"Unchanged", previous, nil},
+		{
+			"Added fields",
+			document(
+				map[string]any{"source_account_id": int64Schema, "amount": stringSchema, "limit": int32Schema, "memo": stringSchema},
+				map[string]any{"id": int32Schema, "amount": decimalSchema, "tags": map[string]any{"type": "array", "items": stringSchema}, "memo": stringSchema},
+			),
+			nil,
+		},
+		{
+			"Removed fields",
+			document(
+				map[string]any{"source_account_id": int64Schema, "limit": int32Schema},
+				map[string]any{"id": int32Schema, "amount": decimalSchema},
+			),
+			[]string{"POST /transactions request.amount: field removed", "POST /transactions response.tags: field removed"},
+		},
+		{
+			"Narrowed and widened types",
+			document(
+				map[string]any{"source_account_id": int32Schema, "amount": stringSchema, "limit": int64Schema},
+				map[string]any{"id": int64Schema, "amount": stringSchema, "tags": map[string]any{"type": "array", "items": int32Schema}},
+			),
+			[]string{
+				"POST /transactions request.source_account_id: narrowed from int64 to int32",
+				"POST /transactions response.amount: type changed from string (decimal) to string",
+				"POST /transactions response.id: widened from int32 to int64, which clients may not hold",
+				"POST /transactions response.tags[]: type changed from string to integer (int32)",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := Check(artifact(t, previous), artifact(t, tc.current))
+			if got := breaking(report); strings.Join(got, "\n") != strings.Join(tc.breaking, "\n") {
+				t.Errorf("Expected breaking changes\n%s\ngot\n%s", strings.Join(tc.breaking, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestCheck_Operations(t *testing.T) {
+	previous := artifact(t, document(map[string]any{}, map[string]any{}))
+	current := artifact(t, map[string]any{"paths": map[string]any{
+		"/transactions": map[string]any{"post": map[string]any{"responses": map[string]any{"200": map[string]any{}}}},
+		"/accounts":     map[string]any{"post": map[string]any{"responses": map[string]any{"201": map[string]any{}}}},
+	}})
+
+	report := Check(previous, current)
+	expected := []string{
+		"POST /transactions request: request body no longer read",
+		"POST /transactions: success status 201 changed to 200",
+		"POST /accounts: operation added",
+	}
+	var got []string
+	for _, c := range report.Changes {
+		got = append(got, c.Location+": "+c.Message)
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") || report.Breaking() != 1 {
+		t.Errorf("Unexpected changes (%d breaking):\n%s", report.Breaking(), strings.Join(got, "\n"))
+	}
+
+	if report := Check(current, previous); report.Breaking() != 3 {
+		t.Errorf("Expected a removed operation, a required request body and a changed status, got %v", breaking(report))
+	}
+}
+
+func TestCheck_Migrations(t *testing.T) {
+	released := []database.Migration{
+		{Version: 1, Name: "create_accounts", Up: "CREATE TABLE accounts (id BIGINT);"},
+		{Version: 2, Name: "add_tags", Up: "ALTER TABLE accounts ADD COLUMN tags TEXT[];"},
+	}
+	previous := artifact(t, document(nil, nil), released...)
+
+	testCases := []struct {
+		name     string
+		added    []database.Migration
+		modify   func(m []database.Migration)
+		breaking []string
+	}{
+		{"Unchanged", nil, nil, nil},
+		{
+			"Additive migration",
+			[]database.Migration{{Version: 3, Name: "add_index", Up: `
+				-- Old rows are kept; DROP TABLE in a comment is ignored
+				CREATE INDEX idx_accounts_tags ON accounts (tags);
+				ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_tags_check, ALTER COLUMN tags DROP DEFAULT;
+				ALTER TABLE accounts RENAME CONSTRAINT a TO b;`}},
+			nil,
+			nil,
+		},
+		{
+			"Released migration modified",
+			nil,
+			func(m []database.Migration) { m[1].Up = "ALTER TABLE accounts ADD COLUMN tags JSONB;" },
+			[]string{"migration 2: released migration add_tags modified; deployed databases will not run it again"},
+		},
+		{
+			"Destructive migration",
+			[]database.Migration{{Version: 3, Name: "cleanup", Up: `
+				ALTER TABLE accounts DROP COLUMN tags;
+				alter table accounts alter column id type integer;
+				ALTER TABLE accounts RENAME TO ledger_accounts;
+				DELETE FROM audit_events;
+				DROP TABLE IF EXISTS legacy;`}},
+			nil,
+			[]string{
+				"migration 3: cleanup drops a column",
+				"migration 3: cleanup changes a column type",
+				"migration 3: cleanup renames a table or column",
+				"migration 3: cleanup deletes rows",
+				"migration 3: cleanup drops a table, view or schema",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations := append(append([]database.Migration(nil), released...), tc.added...)
+			if tc.modify != nil {
+				tc.modify(migrations)
+			}
+			report := Check(previous, artifact(t, document(nil, nil), migrations...))
+			if got := breaking(report); strings.Join(got, "\n") != strings.Join(tc.breaking, "\n") {
+				t.Errorf("Expected breaking changes\n%s\ngot\n%s", strings.Join(tc.breaking, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+
+	if report := Check(previous, artifact(t, document(nil, nil), released[0])); report.Breaking() != 1 {
+		t.Errorf("Expected a removed migration to break, got %v", breaking(report))
+	}
+}
+
+func TestArtifact_RoundTrip(t *testing.T) {
+	migrations, err := database.LoadMigrations()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	current := artifact(t, document(map[string]any{"amount": stringSchema}, map[string]any{"id": int64Schema}), migrations...)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, current); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	previous, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report := Check(previous, current); len(report.Changes) != 0 || previous.Version != "1.0.0" {
+		t.Errorf("Expected a release to be compatible with itself, got %+v", report)
+	}
+
+	if _, err := Read(strings.NewReader(`{"version": "1.0.0"}`)); err == nil {
+		t.Error("Expected an error for an artifact without an OpenAPI document")
+	}
+}
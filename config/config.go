@@ -0,0 +1,280 @@
+// Package config merges the service's settings from a config file, the environment and
+// command-line flags, in increasing order of precedence. Every setting is an environment variable
+// (DB_HOST, PORT, LOG_LEVEL, ...) that its package's LoadConfig reads; the file and the flags name
+// the same settings, which must be listed in Known, and are applied to the process environment
+// before anything reads it, so every setting can come from any of the three sources without the
+// packages knowing which
+//
+// A TOML file names a setting by its table and key, joined by underscores and upper-cased, e.g.
+//
+//	port = 8080
+//
+//	[db]
+//	host = "db.internal"
+//	max_open_conns = 50
+//
+// sets PORT, DB_HOST and DB_MAX_OPEN_CONNS; arrays are joined with commas. A YAML file names it
+// by its mappings and key the same way (db: {host: db.internal}). A flag names it in lower case
+// with dashes: --db-host=db.internal
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is how often the config file is checked for changes by default
+const DefaultReloadInterval = 5 * time.Second
+
+// Settings maps setting names, the environment variables the packages read, to values
+type Settings map[string]string
+
+// Names returns the setting names in order
+func (s Settings) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// settingName turns a file key path or flag name into a setting name, e.g. db.max_open_conns or
+// db-max-open-conns into DB_MAX_OPEN_CONNS
+// Returns an error unless the name is a Known setting
+func settingName(parts ...string) (string, error) {
+	name := strings.ToUpper(strings.ReplaceAll(strings.Join(parts, "_"), "-", "_"))
+	if !IsKnown(name) {
+		return "", fmt.Errorf("unknown setting %q", strings.Join(parts, "."))
+	}
+	return name, nil
+}
+
+// Source is where the process's settings came from, kept to reload the config file
+// Safe for concurrent use
+type Source struct {
+	// File is the config file, empty if there is none
+	File string
+
+	// fixed names the settings the environment or a flag set at startup, which the file cannot
+	// change
+	fixed map[string]bool
+
+	mu      sync.Mutex
+	applied Settings
+	modTime time.Time
+}
+
+// Load applies the config file and the flags at the start of args to the process environment, and
+// returns the source of the settings and the remaining arguments (a command such as "migrate up")
+// The file is named by the -config flag or the CONFIG_FILE environment variable; its settings only
+// apply where the environment leaves them unset or empty, while flags override both
+// Returns an error for malformed flags or an unreadable or malformed file
+func Load(args []string) (source *Source, rest []string, err error) {
+	file, flags, rest, err := ParseFlags(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	source = &Source{File: file, fixed: make(map[string]bool), applied: make(Settings)}
+	for _, entry := range os.Environ() {
+		if name, value, _ := strings.Cut(entry, "="); value != "" {
+			source.fixed[name] = true
+		}
+	}
+	for name, value := range flags {
+		os.Setenv(name, value)
+		source.fixed[name] = true
+	}
+	if _, err := source.Reload(); err != nil {
+		return nil, nil, err
+	}
+	return source, rest, nil
+}
+
+// Reload re-reads the config file and applies its changes to the process environment (see
+// Pending.Apply)
+// Returns the names of the settings whose value changed, in order; an error leaves the environment
+// unchanged
+func (s *Source) Reload() ([]string, error) {
+	pending, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+	return pending.Apply(), nil
+}
+
+// Read re-reads the config file without applying it, so the settings it would change can be
+// validated first (see Pending.Getenv); nothing is applied if there is no file
+// Returns an error for an unreadable or malformed file
+func (s *Source) Read() (*Pending, error) {
+	pending := &Pending{source: s, settings: make(Settings)}
+	if s.File == "" {
+		return pending, nil
+	}
+	info, err := os.Stat(s.File)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	// A malformed or refused file is reported once, not again at every check until it changes
+	s.mu.Lock()
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	if pending.settings, err = ReadFile(s.File); err != nil {
+		return nil, err
+	}
+	for name := range s.fixed {
+		delete(pending.settings, name)
+	}
+	return pending, nil
+}
+
+// Pending is a config file read by Source.Read but not applied yet
+type Pending struct {
+	source   *Source
+	settings Settings
+}
+
+// Getenv returns the value the environment variable name will have once the file is applied, in
+// the manner of os.Getenv, for validating the settings without changing the environment
+func (p *Pending) Getenv(name string) string {
+	if value, ok := p.settings[name]; ok {
+		return value
+	}
+	p.source.mu.Lock()
+	_, removed := p.source.applied[name]
+	p.source.mu.Unlock()
+	if removed {
+		return ""
+	}
+	return os.Getenv(name)
+}
+
+// Apply applies the file to the process environment: changed settings are updated and settings
+// removed from the file are unset, except those the environment or a flag fixed at startup
+// Returns the names of the settings whose value changed, in order
+func (p *Pending) Apply() []string {
+	s := p.source
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var changed []string
+	for name := range s.applied {
+		if _, kept := p.settings[name]; !kept {
+			os.Unsetenv(name)
+			changed = append(changed, name)
+		}
+	}
+	for name, value := range p.settings {
+		if previous, ok := s.applied[name]; !ok || previous != value {
+			os.Setenv(name, value)
+			changed = append(changed, name)
+		}
+	}
+	s.applied = p.settings
+	sort.Strings(changed)
+	return changed
+}
+
+// Modified reports whether the config file changed since it was last read, by its modification
+// time; false if there is no file or it cannot be read
+func (s *Source) Modified() bool {
+	if s.File == "" {
+		return false
+	}
+	info, err := os.Stat(s.File)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !info.ModTime().Equal(s.modTime)
+}
+
+// ReloadInterval reads how often the config file is checked for changes from the environment
+// Variables:
+//   - CONFIG_RELOAD_INTERVAL (5s): How often the config file is checked for changes; 0 reloads it
+//     on SIGHUP only
+//
+// Returns an error for malformed values
+func ReloadInterval() (time.Duration, error) {
+	value := os.Getenv("CONFIG_RELOAD_INTERVAL")
+	if value == "" {
+		return DefaultReloadInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		return 0, fmt.Errorf("invalid CONFIG_RELOAD_INTERVAL %q", value)
+	}
+	return interval, nil
+}
+
+// ParseFlags reads the flags at the start of args, up to the first argument that is not one or
+// "--": -config FILE names the config file, and any other flag sets the Known setting it names, as
+// --name=value or --name value (with one or two dashes)
+// Returns the config file, the settings and the remaining arguments; flag.ErrHelp for -h or
+// --help, and an error for an unknown setting or a missing value
+func ParseFlags(args []string) (file string, settings Settings, rest []string, err error) {
+	settings = make(Settings)
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return file, settings, args[1:], nil
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+		args = args[1:]
+		key, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if key == "h" || key == "help" {
+			return "", nil, nil, flag.ErrHelp
+		}
+		name := "config"
+		if key != "config" {
+			if name, err = settingName(key); err != nil {
+				return "", nil, nil, fmt.Errorf("invalid flag %s: %w", arg, err)
+			}
+		}
+		if !hasValue {
+			if len(args) == 0 {
+				return "", nil, nil, fmt.Errorf("flag -%s needs a value", key)
+			}
+			value, args = args[0], args[1:]
+		}
+		if name == "config" {
+			file = value
+			continue
+		}
+		settings[name] = value
+	}
+	return file, settings, args, nil
+}
+
+// ReadFile reads a TOML (.toml) or YAML (.yaml, .yml) config file
+func ReadFile(path string) (Settings, error) {
+	var parse func([]byte) (Settings, error)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		parse = ParseTOML
+	case ".yaml", ".yml":
+		parse = ParseYAML
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q (expected .toml, .yaml or .yml)", path, ext)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	settings, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,349 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTOML(t *testing.T) {
+	settings, err := ParseTOML([]byte(`# Local settings
+port = 8080
+shutdown_timeout = "30s"
+hold.max-ttl = "720h"
+"rules_file" = 'C:\rules\#1.json'
+
+[db]
+host = "db.internal" # primary
+password = "p#ss \"quoted\" \\ \u00e9\t="
+max_open_conns = 1_000
+conn_max_lifetime = '30m'
+
+[ "tls" ]
+autocert_domains = ["transfers.example.com", 'api.example.com',]
+
+[sandbox]
+mode = true
+
+[tls.cert]
+file = """
+/etc/ssl/cert.pem"""
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := Settings{
+		"PORT":                 "8080",
+		"SHUTDOWN_TIMEOUT":     "30s",
+		"HOLD_MAX_TTL":         "720h",
+		"RULES_FILE":           `C:\rules\#1.json`,
+		"DB_HOST":              "db.internal",
+		"DB_PASSWORD":          "p#ss \"quoted\" \\ \u00e9\t=",
+		"DB_MAX_OPEN_CONNS":    "1000",
+		"DB_CONN_MAX_LIFETIME": "30m",
+		"TLS_AUTOCERT_DOMAINS": "transfers.example.com,api.example.com",
+		"SANDBOX_MODE":         "true",
+		"TLS_CERT_FILE":        "/etc/ssl/cert.pem",
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("Expected %v, got %v", want, settings)
+	}
+
+	for name, data := range map[string]string{
+		"duplicate key":      "port = 1\nport = 2\n",
+		"duplicate setting":  "db_host = \"x\"\n[db]\nhost = \"y\"\n",
+		"missing value":      "port =\n",
+		"no equals sign":     "port\n",
+		"array of tables":    "[[db]]\nhost = \"x\"\n",
+		"inline table":       "db_host = {host = \"x\"}\n",
+		"nested array":       "tls_autocert_domains = [[\"x\"]]\n",
+		"open header":        "[db\n",
+		"unknown setting":    "[db]\npasword = \"x\"\n",
+		"unrelated variable": "path = \"/tmp\"\n",
+		"unquoted string":    "shutdown_timeout = 30s\n",
+		"invalid escape":     "db_password = \"a\\q\"\n",
+		"short unicode":      "db_password = \"\\u00e\"\n",
+		"surrogate":          "db_password = \"\\ud800\"\n",
+		"unescaped quote":    "db_password = \"a\"b\"\n",
+		"unterminated":       "db_password = \"abc\n",
+		"literal quote":      "db_password = 'it's'\n",
+		"invalid key":        "db host = \"x\"\n",
+	} {
+		if _, err := ParseTOML([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	settings, err := ParseYAML([]byte(`# Local settings
+port: 8080
+shutdown_timeout: 30s
+hold:
+  max-ttl: 720h
+rules_file: 'C:\rules\#1.json'
+
+db: &db
+  host: db.internal # primary
+  password: "p#ss \"quoted\" \u00e9"
+  max_open_conns: 50
+
+tls:
+  autocert_domains: [transfers.example.com, api.example.com]
+
+sandbox: {mode: true}
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := Settings{
+		"PORT":                 "8080",
+		"SHUTDOWN_TIMEOUT":     "30s",
+		"HOLD_MAX_TTL":         "720h",
+		"RULES_FILE":           `C:\rules\#1.json`,
+		"DB_HOST":              "db.internal",
+		"DB_PASSWORD":          "p#ss \"quoted\" \u00e9",
+		"DB_MAX_OPEN_CONNS":    "50",
+		"TLS_AUTOCERT_DOMAINS": "transfers.example.com,api.example.com",
+		"SANDBOX_MODE":         "true",
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("Expected %v, got %v", want, settings)
+	}
+	if settings, err := ParseYAML(nil); err != nil || len(settings) != 0 {
+		t.Errorf("Expected no settings from an empty file, got %v (%v)", settings, err)
+	}
+
+	for name, data := range map[string]string{
+		"duplicate key":      "port: 1\nport: 2\n",
+		"duplicate setting":  "db_host: x\ndb:\n  host: y\n",
+		"missing value":      "port:\n",
+		"not a mapping":      "- port\n",
+		"nested sequence":    "tls:\n  autocert_domains: [[x]]\n",
+		"unknown setting":    "db:\n  pasword: x\n",
+		"unrelated variable": "path: /tmp\n",
+		"invalid":            "port: [\n",
+	} {
+		if _, err := ParseYAML([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	file, settings, rest, err := ParseFlags([]string{
+		"-config", "local.toml", "--db-host=db.internal", "-port", "9090", "migrate", "up", "-x",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	wantSettings := Settings{"DB_HOST": "db.internal", "PORT": "9090"}
+	if file != "local.toml" || !reflect.DeepEqual(settings, wantSettings) ||
+		!reflect.DeepEqual(rest, []string{"migrate", "up", "-x"}) {
+		t.Errorf("Unexpected flags %q %v %q", file, settings, rest)
+	}
+
+	_, _, rest, _ = ParseFlags([]string{"--port=1", "--", "-x"})
+	if !reflect.DeepEqual(rest, []string{"-x"}) {
+		t.Errorf("Expected the arguments after --, got %q", rest)
+	}
+	for _, args := range [][]string{{"-port"}, {"--db.host=x"}, {"-=x"}, {"--db-pasword", "x"}, {"--path=/tmp"}} {
+		if _, _, _, err := ParseFlags(args); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+	for _, args := range [][]string{{"-h"}, {"--help"}, {"--port=1", "-help", "migrate"}} {
+		if _, _, _, err := ParseFlags(args); !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("%q: expected flag.ErrHelp, got %v", args, err)
+		}
+	}
+}
+
+// settingPatterns find the settings the service reads: the environment variables read by name,
+// and those documented in the "Variables:" lists of the LoadConfig functions
+var settingPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?:Getenv|getEnv\w*|LookupEnv)\("([A-Z][A-Z0-9_]*)"`),
+	regexp.MustCompile(`(?m)^//\s+- ([A-Z][A-Z0-9_]*)(?: / ([A-Z][A-Z0-9_]*))? \(`),
+}
+
+func TestKnown(t *testing.T) {
+	// Set by the -config flag and the service manager rather than as settings
+	external := map[string]bool{"CONFIG_FILE": true, "NOTIFY_SOCKET": true, "WATCHDOG_USEC": true, "WATCHDOG_PID": true}
+
+	// Every setting read anywhere in the module must be Known, or no file or flag could set it
+	err := filepath.WalkDir("..", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range settingPatterns {
+			for _, match := range pattern.FindAllStringSubmatch(string(data), -1) {
+				for _, name := range match[1:] {
+					if name != "" && !external[name] && !IsKnown(name) {
+						t.Errorf("%s reads %s, which is not in Known", path, name)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var usage strings.Builder
+	Usage(&usage)
+	if !strings.Contains(usage.String(), "--db-max-open-conns=VALUE") {
+		t.Errorf("Expected the usage to list the settings as flags, got:\n%s", usage.String())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	data := "port = 8080\n[db]\nhost = \"file\"\nname = \"file\"\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.ini"), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("PORT", "")
+	t.Setenv("DB_HOST", "env")
+	t.Setenv("DB_NAME", "env")
+
+	// The file fills in what the environment leaves unset; flags override both
+	source, rest, err := Load([]string{"--db-name=flag", "doctor"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(rest, []string{"doctor"}) {
+		t.Errorf("Expected the command to remain, got %q", rest)
+	}
+	for name, want := range map[string]string{"PORT": "8080", "DB_HOST": "env", "DB_NAME": "flag"} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("Expected %s=%q, got %q", name, want, got)
+		}
+	}
+	if source.File != path || source.Modified() {
+		t.Errorf("Expected the unmodified file %s, got %+v", path, source)
+	}
+
+	for name, args := range map[string][]string{
+		"missing file":       {"-config", filepath.Join(dir, "missing.toml")},
+		"unsupported format": {"-config", filepath.Join(dir, "config.ini")},
+	} {
+		if _, _, err := Load(args); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSource_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	write := func(data string, modified time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write("log_level = \"info\"\nusage_quotas = \"key_a=10\"\nrules_file = \"a.json\"\n", start)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("USAGE_QUOTAS", "")
+	t.Setenv("RULES_FILE", "env.json")
+	source, _, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Changed and removed settings are applied; the environment's own keep winning
+	write("log_level = \"debug\"\nrules_file = \"b.json\"\n", start.Add(time.Minute))
+	if !source.Modified() {
+		t.Fatal("Expected the file to be modified")
+	}
+	changed, err := source.Reload()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(changed, []string{"LOG_LEVEL", "USAGE_QUOTAS"}) {
+		t.Errorf("Expected LOG_LEVEL and USAGE_QUOTAS to change, got %q", changed)
+	}
+	for name, want := range map[string]string{"LOG_LEVEL": "debug", "USAGE_QUOTAS": "", "RULES_FILE": "env.json"} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("Expected %s=%q, got %q", name, want, got)
+		}
+	}
+	if source.Modified() {
+		t.Error("Expected the reloaded file to be current")
+	}
+
+	// Read stages a change without touching the environment until it is applied
+	write("usage_quotas = \"key_b=1\"\nrules_file = \"c.json\"\n", start.Add(90*time.Second))
+	pending, err := source.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	staged := map[string]string{"USAGE_QUOTAS": "key_b=1", "LOG_LEVEL": "", "RULES_FILE": "env.json"}
+	for name, want := range staged {
+		if got := pending.Getenv(name); got != want {
+			t.Errorf("Expected the staged %s=%q, got %q", name, want, got)
+		}
+	}
+	if os.Getenv("LOG_LEVEL") != "debug" || os.Getenv("USAGE_QUOTAS") != "" {
+		t.Errorf("Expected Read to leave the environment alone, got LOG_LEVEL=%q", os.Getenv("LOG_LEVEL"))
+	}
+	if changed := pending.Apply(); !reflect.DeepEqual(changed, []string{"LOG_LEVEL", "USAGE_QUOTAS"}) {
+		t.Errorf("Expected LOG_LEVEL and USAGE_QUOTAS to change, got %q", changed)
+	}
+	for name, want := range staged {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("Expected %s=%q once applied, got %q", name, want, got)
+		}
+	}
+	write("log_level = \"debug\"\n", start.Add(100*time.Second))
+	if _, err := source.Reload(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A malformed file changes nothing, and is not reported as modified again
+	write("log_level = [\"debug\"\n", start.Add(2*time.Minute))
+	if _, err := source.Reload(); err == nil {
+		t.Error("Expected an error for a malformed file")
+	}
+	if os.Getenv("LOG_LEVEL") != "debug" || source.Modified() {
+		t.Errorf("Expected the previous settings to remain, got LOG_LEVEL=%q", os.Getenv("LOG_LEVEL"))
+	}
+}
+
+func TestReloadInterval(t *testing.T) {
+	t.Setenv("CONFIG_RELOAD_INTERVAL", "")
+	if interval, err := ReloadInterval(); err != nil || interval != DefaultReloadInterval {
+		t.Errorf("Expected the default interval, got %v, %v", interval, err)
+	}
+	t.Setenv("CONFIG_RELOAD_INTERVAL", "0")
+	if interval, err := ReloadInterval(); err != nil || interval != 0 {
+		t.Errorf("Expected 0, got %v, %v", interval, err)
+	}
+	for _, value := range []string{"soon", "-1s"} {
+		t.Setenv("CONFIG_RELOAD_INTERVAL", value)
+		if _, err := ReloadInterval(); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
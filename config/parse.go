@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseTOML decodes a TOML config file: each key, under its tables, names a setting whose value is
+// a string, number, boolean, date or array of them; arrays of tables are refused
+func ParseTOML(data []byte) (Settings, error) {
+	var doc map[string]any
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	settings := make(Settings)
+	sources := make(map[string]string)
+	var flatten func(path []string, table map[string]any) error
+	flatten = func(path []string, table map[string]any) error {
+		keys := make([]string, 0, len(table))
+		for key := range table {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			parts := append(append([]string(nil), path...), key)
+			if nested, ok := table[key].(map[string]any); ok {
+				if err := flatten(parts, nested); err != nil {
+					return err
+				}
+				continue
+			}
+			value, err := tomlValue(table[key])
+			if err != nil {
+				return fmt.Errorf("%s: %w", strings.Join(parts, "."), err)
+			}
+			if err := settings.set(sources, parts, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := flatten(nil, doc); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// tomlValue formats a decoded TOML value as a setting: a number without its digit separators, a
+// boolean or date as TOML writes it, or an array of them joined with commas
+func tomlValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		switch v.Location().String() {
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999"), nil
+		case "date-local":
+			return v.Format(time.DateOnly), nil
+		case "time-local":
+			return v.Format("15:04:05.999999999"), nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	case []map[string]any:
+		return "", fmt.Errorf("arrays of tables are not supported")
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case []any, map[string]any:
+				return "", fmt.Errorf("arrays must hold strings, numbers, booleans or dates")
+			}
+			s, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+// ParseYAML decodes a YAML config file: each key, under its mappings, names a setting whose value
+// is a scalar, as written, or a sequence of them
+func ParseYAML(data []byte) (Settings, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	settings := make(Settings)
+	if len(doc.Content) == 0 {
+		return settings, nil
+	}
+	sources := make(map[string]string)
+	var flatten func(path []string, mapping *yaml.Node) error
+	flatten = func(path []string, mapping *yaml.Node) error {
+		if mapping.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: expected a mapping of settings", mapping.Line)
+		}
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			key, value := mapping.Content[i], resolveAlias(mapping.Content[i+1])
+			if key.Kind != yaml.ScalarNode || key.Tag == "!!merge" {
+				return fmt.Errorf("line %d: keys must be plain strings", key.Line)
+			}
+			parts := append(append([]string(nil), path...), key.Value)
+			if value.Kind == yaml.MappingNode {
+				if err := flatten(parts, value); err != nil {
+					return err
+				}
+				continue
+			}
+			s, err := yamlValue(value)
+			if err != nil {
+				return fmt.Errorf("line %d: %s: %w", value.Line, strings.Join(parts, "."), err)
+			}
+			if err := settings.set(sources, parts, s); err != nil {
+				return fmt.Errorf("line %d: %w", key.Line, err)
+			}
+		}
+		return nil
+	}
+	if err := flatten(nil, resolveAlias(doc.Content[0])); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// yamlValue formats a YAML scalar as written, or a sequence of them joined with commas
+func yamlValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "", fmt.Errorf("missing value")
+		}
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item = resolveAlias(item); item.Kind != yaml.ScalarNode || item.Tag == "!!null" {
+				return "", fmt.Errorf("sequences must hold scalars")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value")
+}
+
+// resolveAlias returns the node an alias refers to, or node itself
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// set stores the value of the setting the key path parts name, refusing unknown settings and a
+// setting named twice, e.g. by db.host and db_host; sources records the key path of each setting
+func (s Settings) set(sources map[string]string, parts []string, value string) error {
+	name, err := settingName(parts...)
+	if err != nil {
+		return err
+	}
+	key := strings.Join(parts, ".")
+	if previous, exists := sources[name]; exists {
+		if previous == key {
+			return fmt.Errorf("%s is set twice", key)
+		}
+		return fmt.Errorf("%s and %s both set %s", previous, key, name)
+	}
+	sources[name] = key
+	s[name] = value
+	return nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Setting is a setting the service reads, documented by the LoadConfig function of the package
+// reading it
+type Setting struct {
+	Name        string
+	Description string
+}
+
+// Known lists every setting the config file and flags may set, in the order of the README's
+// environment variable tables; anything else is refused, so a misspelt name fails at startup
+// instead of being ignored, and neither can overwrite unrelated variables such as PATH
+// CONFIG_FILE is named by the -config flag instead, and the variables the service manager sets
+// (NOTIFY_SOCKET, WATCHDOG_USEC, WATCHDOG_PID) are not settings
+var Known = []Setting{
+	// Application
+	{"CONFIG_RELOAD_INTERVAL", "How often the config file is checked for changes; 0 reloads it on SIGHUP only"},
+	{"PORT", "TCP port serving every route when LISTENERS is unset"},
+	{"LISTENERS", "Comma separated listener URLs with per-listener route scopes; replaces PORT"},
+	{"TLS_CERT_FILE", "PEM certificate chain served by TCP listeners"},
+	{"TLS_KEY_FILE", "PEM private key of TLS_CERT_FILE"},
+	{"TLS_MIN_VERSION", "Oldest TLS version accepted: 1.2 or 1.3"},
+	{"TLS_AUTOCERT_DOMAINS", "Comma separated domains to obtain a certificate for from an ACME CA"},
+	{"TLS_AUTOCERT_CACHE_DIR", "Directory caching the ACME account key and certificate"},
+	{"TLS_AUTOCERT_EMAIL", "Contact address of the ACME account"},
+	{"TLS_AUTOCERT_DIRECTORY_URL", "ACME directory URL of the CA"},
+	{"HTTP_REDIRECT_ADDR", "Address of a cleartext listener redirecting to HTTPS, e.g. :80"},
+	{"HTTP2_ENABLED", "Negotiate HTTP/2 on TLS connections"},
+	{"H2C_ENABLED", "Accept cleartext HTTP/2 (prior knowledge); only behind a trusted proxy"},
+	{"HTTP2_MAX_CONCURRENT_STREAMS", "Requests in flight per HTTP/2 connection"},
+	{"HTTP_IDLE_TIMEOUT", "How long idle keepalive connections stay open"},
+	{"HTTP2_PING_INTERVAL", "Silence after which an HTTP/2 connection is pinged; 0 disables pings"},
+	{"HTTP2_PING_TIMEOUT", "How long a ping may go unanswered before the connection is closed"},
+	{"IP_ALLOWLIST", "Comma separated CIDR ranges or addresses every request must come from"},
+	{"IP_ALLOWLIST_KEYS", "Comma separated key_id=cidr|cidr pairs restricting the requests signed with an API key"},
+	{"REQUIRE_SIGNED_REQUESTS", "Refuse unsigned requests to the signed endpoints"},
+	{"SIGNATURE_MAX_AGE", "How far a request signature's timestamp may be from the server's clock"},
+	{"MAX_REQUEST_BODY_BYTES", "Largest request body accepted by routes without their own limit"},
+	{"LOG_LEVEL", "Minimum level logged: debug, info, warn or error"},
+	{"LOG_FORMAT", "Log output: text or json"},
+	{"ACCESS_LOG_FORMAT", "Access log output: text, json or combined"},
+	{"ACCESS_LOG_HEALTH_CHECKS", "Also log health check requests in the access log"},
+	{"SHUTDOWN_TIMEOUT", "How long in-flight requests, and then background jobs, get to finish"},
+	{"SHUTDOWN_REPORT_FILE", "File the shutdown report is also written to as JSON"},
+	{"ROUNDING_POLICY", "Rounding of amounts beyond 5 decimal places: half_up, half_even or truncate"},
+	{"ADMIN_TOKEN", "Bearer token for the /admin endpoints; the admin API is disabled when unset"},
+	{"CONSOLE_ENABLED", "Serve the interactive API console at /console"},
+	{"FIXTURE_RECORD_DIR", "Record sanitized request/response fixtures into this directory"},
+	{"FIXTURE_REPLAY_DIR", "Serve recorded fixtures from this directory instead of the real API"},
+	{"SANDBOX_MODE", "Answer reserved amounts and account IDs with simulated outcomes"},
+	{"TRANSFER_CUTOFFS", "Daily cut-off per transfer type as type=HH:MM pairs"},
+	{"CUTOFF_TIMEZONE", "IANA time zone of the cut-off times and value dates"},
+	{"BUSINESS_HOLIDAYS", "Comma separated YYYY-MM-DD dates that are not business days"},
+	{"USAGE_QUOTAS", "Monthly request quotas per API key as key_id=requests pairs"},
+	{"USAGE_FLUSH_INTERVAL", "How often per-key usage counters are written to storage"},
+	{"SLA_COMMIT_TARGET", "p95 commit latency target of the transfer SLA reports"},
+	{"SLA_FLUSH_INTERVAL", "How often transfer latency samples are written to storage"},
+	{"SLA_STAGE_BUDGETS", "Transfer stage latency budgets to override, as stage=duration pairs"},
+	{"RECURRING_POLL_INTERVAL", "How often due recurring transfers are looked for"},
+	{"RECURRING_RETRY_DELAYS", "Comma separated waits before each retry of a recurring run"},
+	{"HOLD_TTL", "Lifetime of a hold created without expires_at"},
+	{"HOLD_MAX_TTL", "Longest lifetime a hold may be given"},
+	{"HOLD_EXPIRY_INTERVAL", "How often holds past their expiry are released"},
+	{"BALANCE_SNAPSHOT_INTERVAL", "How often quiet accounts are snapshotted; 0 disables it"},
+	{"CURRENCY_REFRESH_INTERVAL", "How often the stored currencies are reloaded; 0 disables it"},
+	{"RETENTION_AUDIT_EVENTS_DAYS", "Days audit events are kept; 0 keeps them forever"},
+	{"RETENTION_OUTBOX_EVENTS_DAYS", "Days published outbox events are kept; 0 keeps them forever"},
+	{"RETENTION_INTERVAL", "How often retention policies are enforced; 0 disables it"},
+	{"RETENTION_DRY_RUN", "Scheduled enforcement only logs what it would purge"},
+	{"REAPER_INTERVAL", "How often dead stream connections are reaped; 0 disables the reaper"},
+	{"REAPER_STREAM_IDLE", "How long a stream connection may go unheard before it is closed"},
+	{"ATTACHMENTS_DIR", "Directory transaction attachments are stored in; unset disables them"},
+	{"ATTACHMENT_MAX_SIZE", "Largest attachment accepted, in bytes"},
+	{"ATTACHMENT_TYPES", "Comma separated content types attachments may have"},
+	{"PAGINATION_KEY", "Secret of at least 32 bytes signing list cursors"},
+	{"RULES_FILE", "JSON file of additional transfer validation rules"},
+	{"PREAUTH_URL", "Decision endpoint asked to approve each transfer; unset disables it"},
+	{"PREAUTH_TOKEN", "Bearer token sent to PREAUTH_URL"},
+	{"PREAUTH_SIGNING_SECRET", "Secret signing each pre-authorization callout with HMAC-SHA256"},
+	{"PREAUTH_TIMEOUT", "How long a pre-authorization callout may take"},
+	{"PREAUTH_FAILURE_POLICY", "Outcome without a verdict: closed refuses the transfer, open books it"},
+	{"STORAGE", "Storage backend: postgres, memory or eventsourced"},
+	{"EVENT_LOG_PATH", "Event log file of STORAGE=eventsourced"},
+
+	// Exchange rates
+	{"FX_RATE_URLS", "Comma separated HTTP rate services, in priority order"},
+	{"FX_RATES", "Static rate table as BASE/QUOTE=rate pairs"},
+	{"FX_RATE_CACHE_TTL", "How long exchange rates are cached"},
+	{"FX_MAX_RATE_AGE", "Conversions are refused when the freshest rate is older than this"},
+	{"FX_MAX_RATE_DEVIATION", "Maximum percentage difference between the primary and secondary rates"},
+
+	// Events and settlement
+	{"KAFKA_BROKERS", "Comma separated Kafka brokers; enables the outbox relay when set"},
+	{"KAFKA_TOPIC", "Topic that events are published to"},
+	{"SETTLEMENT_PARTNERS_FILE", "JSON file of partners and file layouts; enables the settlement job"},
+	{"SETTLEMENT_EXPORT_DIR", "Directory settlement files are uploaded to"},
+	{"SETTLEMENT_INTERVAL", "How often the job checks for closed business days missing a file"},
+
+	// Database
+	{"DB_HOST", "PostgreSQL host, or a comma separated list of the hosts that may be the primary"},
+	{"DB_PORT", "PostgreSQL port"},
+	{"DB_USER", "Database user"},
+	{"DB_PASSWORD", "Database password"},
+	{"DB_USER_FILE", "File holding the database user, overriding DB_USER"},
+	{"DB_PASSWORD_FILE", "File holding the database password, overriding DB_PASSWORD"},
+	{"DB_CREDENTIALS_CHECK_INTERVAL", "How often the credentials are checked for rotation; 0 disables it"},
+	{"DB_NAME", "Database name"},
+	{"DB_SSLMODE", "SSL mode"},
+	{"DB_TARGET_SESSION_ATTRS", "Which DB_HOST server to connect to: read-write or any"},
+	{"DB_MAX_OPEN_CONNS", "Maximum open connections in the pool; 0 means unlimited"},
+	{"DB_MAX_IDLE_CONNS", "Maximum idle connections kept in the pool"},
+	{"DB_CONN_MAX_LIFETIME", "Maximum lifetime of a pooled connection; 0 means unlimited"},
+	{"DB_CONN_MAX_IDLE_TIME", "Maximum idle time before a connection is closed; 0 means unlimited"},
+	{"DB_TX_MAX_RETRIES", "Retries of a transfer aborted by a serialization failure or deadlock"},
+	{"DB_TX_RETRY_DELAY", "Base of the jittered exponential backoff between retries"},
+	{"DB_LOCKING", "How transfers protect account rows: pessimistic or optimistic"},
+	{"DB_REPLICA_DSN", "Connection string of a read replica for account reads and listings"},
+}
+
+// known indexes Known by name
+var known = func() map[string]bool {
+	names := make(map[string]bool, len(Known))
+	for _, setting := range Known {
+		names[setting.Name] = true
+	}
+	return names
+}()
+
+// IsKnown reports whether name is a setting the config file and flags may set
+func IsKnown(name string) bool {
+	return known[name]
+}
+
+// flagName returns the flag naming a setting, e.g. --db-host for DB_HOST
+func flagName(name string) string {
+	return "--" + strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+}
+
+// Usage writes the flags and the settings they name to w, for -h and --help
+func Usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: internal-transfers [flags] [command]\n\nFlags:\n")
+	fmt.Fprintf(w, "  %-34s %s\n", "-config FILE", "TOML or YAML config file (default: CONFIG_FILE)")
+	for _, setting := range Known {
+		fmt.Fprintf(w, "  %-34s %s\n", flagName(setting.Name)+"=VALUE", setting.Description)
+	}
+	fmt.Fprintf(w, "\nEach flag sets the environment variable of the same name, e.g. --db-host sets DB_HOST.\n")
+}
@@ -0,0 +1,29 @@
+// Package console serves a minimal browser-based API console
+// The page is compiled into the binary and drives itself from the service's OpenAPI document:
+// it lists every operation, pre-fills example bodies, sends requests with the developer's API key
+// and shows the full response (status, headers, body, latency)
+package console
+
+import (
+	"embed"
+	"net/http"
+)
+
+//go:embed static/index.html
+var static embed.FS
+
+// Handler serves the console page
+func Handler() http.HandlerFunc {
+	page, err := static.ReadFile("static/index.html")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "Console unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// The console only talks to this origin; forbid framing and external scripts
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(page)
+	}
+}
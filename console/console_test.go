@@ -0,0 +1,31 @@
+package console
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/console", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected HTML content type, got %q", ct)
+	}
+	if rr.Header().Get("Content-Security-Policy") == "" {
+		t.Error("Expected a Content-Security-Policy header")
+	}
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "openapi.json") {
+		t.Error("Console should load the OpenAPI document")
+	}
+	if !strings.Contains(body, "Authorization") {
+		t.Error("Console should send the API key")
+	}
+}
@@ -0,0 +1,131 @@
+// Package consumer provides idempotent processing of events published by the transfers service
+// It is transport agnostic: Kafka and NATS consumers decode their messages into an Event and
+// hand them to a Processor, which applies each event ID at most once per consumer, even though the
+// brokers themselves only deliver at least once, provided its handler finishes within the lease
+// (see Processor)
+package consumer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultLease is how long a claim on an event is held while its handler runs
+// If the consumer crashes mid-processing the claim expires and a redelivery may retry the event
+const DefaultLease = 5 * time.Minute
+
+// ErrMissingEventID is returned when an event without an ID is processed
+// Events without IDs cannot be deduplicated and must not be silently accepted
+var ErrMissingEventID = errors.New("event has no ID")
+
+// ErrClaimLost is returned by Complete when the claim expired and was taken over by another
+// consumer, which may have applied the event too
+var ErrClaimLost = errors.New("claim on the event was lost")
+
+// Event is a decoded message received from the event stream
+type Event struct {
+	ID      string
+	Type    string
+	Payload []byte
+}
+
+// HandlerFunc applies the side effects of a single event
+type HandlerFunc func(ctx context.Context, event Event) error
+
+// Store persists which events have been processed
+// Implementations must make Claim atomic: for a given event ID at most one caller may
+// hold an unexpired claim, and a completed event can never be claimed again. Each claim carries a
+// token, so a caller whose claim expired and was taken over cannot complete or release the new one
+type Store interface {
+	// Claim reserves the event for processing until the lease expires
+	// Returns the claim's token, and false if the event is already completed or currently claimed
+	// by someone else
+	Claim(ctx context.Context, eventID string, lease time.Duration) (token string, ok bool, err error)
+
+	// Complete marks the event as processed permanently, if token still holds its claim or the
+	// claim was reaped without being taken over
+	// Returns ErrClaimLost if another claim took it over
+	Complete(ctx context.Context, eventID, token string) error
+
+	// Release drops the claim token holds after a failed attempt so a redelivery can retry
+	// immediately; a claim taken over by another caller is left alone
+	Release(ctx context.Context, eventID, token string) error
+}
+
+// newToken returns a random claim token
+func newToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// Reaper is implemented by stores that can drop deduplication state no longer needed
+// Long-running consumers call both periodically (e.g. from the server's reaper package) so the
+// store does not grow forever
+type Reaper interface {
+	// ReapClaims deletes the claims whose lease expired without the event being completed: the
+	// consumer holding them crashed, and a redelivery would take them over anyway
+	ReapClaims(ctx context.Context) (int64, error)
+
+	// PurgeCompleted deletes the records of events completed before the cutoff
+	// A redelivery of a purged event is processed again, so the cutoff must lie well beyond the
+	// broker's redelivery window
+	PurgeCompleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Processor runs a handler at most once per event ID using a Store for deduplication
+// The guarantee holds while handlers finish within the lease: once a claim expires, a redelivery
+// may claim the event and run the handler again, and the first run's Complete then fails with
+// ErrClaimLost. Handlers that may outlive the lease must be idempotent
+type Processor struct {
+	store   Store
+	handler HandlerFunc
+	lease   time.Duration
+}
+
+// NewProcessor creates a processor that deduplicates events through the given store
+// A lease of zero uses DefaultLease; the lease must comfortably exceed the handler's runtime
+func NewProcessor(store Store, handler HandlerFunc, lease time.Duration) *Processor {
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	return &Processor{store: store, handler: handler, lease: lease}
+}
+
+// Process applies the event unless it has already been processed
+// Returns:
+//   - bool: true if the handler ran and succeeded, false if the event was a duplicate
+//   - error: Handler or store error; the message should not be acknowledged so it is redelivered
+//
+// Duplicates return (false, nil) and should be acknowledged normally
+func (p *Processor) Process(ctx context.Context, event Event) (bool, error) {
+	if event.ID == "" {
+		return false, ErrMissingEventID
+	}
+
+	token, claimed, err := p.store.Claim(ctx, event.ID, p.lease)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event %s: %w", event.ID, err)
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	if err := p.handler(ctx, event); err != nil {
+		if releaseErr := p.store.Release(ctx, event.ID, token); releaseErr != nil {
+			return false, fmt.Errorf("failed to process event %s: %w (release failed: %v)", event.ID, err, releaseErr)
+		}
+		return false, fmt.Errorf("failed to process event %s: %w", event.ID, err)
+	}
+
+	if err := p.store.Complete(ctx, event.ID, token); err != nil {
+		return false, fmt.Errorf("failed to complete event %s: %w", event.ID, err)
+	}
+	return true, nil
+}
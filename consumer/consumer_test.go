@@ -0,0 +1,191 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProcessor_DeduplicatesEvents(t *testing.T) {
+	var calls int32
+	p := NewProcessor(NewMemoryStore(), func(ctx context.Context, event Event) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, 0)
+
+	event := Event{ID: "evt-1", Type: "transaction.completed"}
+
+	processed, err := p.Process(context.Background(), event)
+	if err != nil || !processed {
+		t.Fatalf("Expected first delivery to be processed, got %v, %v", processed, err)
+	}
+
+	processed, err = p.Process(context.Background(), event)
+	if err != nil || processed {
+		t.Fatalf("Expected redelivery to be skipped, got %v, %v", processed, err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestProcessor_RetriesAfterHandlerFailure(t *testing.T) {
+	attempts := 0
+	p := NewProcessor(NewMemoryStore(), func(ctx context.Context, event Event) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("downstream unavailable")
+		}
+		return nil
+	}, 0)
+
+	event := Event{ID: "evt-2"}
+	if _, err := p.Process(context.Background(), event); err == nil {
+		t.Fatal("Expected first attempt to fail")
+	}
+
+	processed, err := p.Process(context.Background(), event)
+	if err != nil || !processed {
+		t.Fatalf("Expected retry to succeed, got %v, %v", processed, err)
+	}
+}
+
+func TestProcessor_MissingEventID(t *testing.T) {
+	p := NewProcessor(NewMemoryStore(), func(ctx context.Context, event Event) error { return nil }, 0)
+
+	if _, err := p.Process(context.Background(), Event{}); !errors.Is(err, ErrMissingEventID) {
+		t.Errorf("Expected ErrMissingEventID, got %v", err)
+	}
+}
+
+func TestProcessor_ConcurrentDeliveries(t *testing.T) {
+	var calls int32
+	p := NewProcessor(NewMemoryStore(), func(ctx context.Context, event Event) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Process(context.Background(), Event{ID: "evt-concurrent"})
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected handler to run once across concurrent deliveries, ran %d times", calls)
+	}
+}
+
+func TestMemoryStore_ExpiredClaim(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	stale, ok, _ := store.Claim(ctx, "evt-3", time.Minute)
+	if !ok {
+		t.Fatal("Expected initial claim to succeed")
+	}
+	if _, ok, _ := store.Claim(ctx, "evt-3", time.Minute); ok {
+		t.Fatal("Expected active claim to block a second claim")
+	}
+
+	// A crashed consumer's claim can be taken over once the lease expires
+	now = now.Add(2 * time.Minute)
+	token, ok, _ := store.Claim(ctx, "evt-3", time.Minute)
+	if !ok || token == stale {
+		t.Fatalf("Expected expired claim to be taken over with a new token, got %q (%v)", token, ok)
+	}
+
+	// The consumer whose claim expired can neither release nor complete the new claim
+	if err := store.Release(ctx, "evt-3", stale); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok, _ := store.Claim(ctx, "evt-3", time.Minute); ok {
+		t.Fatal("Expected a stale release to leave the new claim in place")
+	}
+	if err := store.Complete(ctx, "evt-3", stale); !errors.Is(err, ErrClaimLost) {
+		t.Fatalf("Expected ErrClaimLost completing with a stale token, got %v", err)
+	}
+
+	if err := store.Complete(ctx, "evt-3", token); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now = now.Add(time.Hour)
+	if _, ok, _ := store.Claim(ctx, "evt-3", time.Minute); ok {
+		t.Error("Completed events must never be claimed again")
+	}
+	if err := store.Complete(ctx, "evt-3", stale); !errors.Is(err, ErrClaimLost) {
+		t.Errorf("Expected ErrClaimLost completing a completed event, got %v", err)
+	}
+
+	// A reaped claim that nobody took over can still be completed
+	token, _, _ = store.Claim(ctx, "evt-4", time.Minute)
+	now = now.Add(2 * time.Minute)
+	store.ReapClaims(ctx)
+	if err := store.Complete(ctx, "evt-4", token); err != nil {
+		t.Errorf("Expected a reaped claim to complete, got %v", err)
+	}
+}
+
+func TestProcessor_LeaseExpired(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	// The handler outlives its lease, and a redelivery takes the event over meanwhile
+	var redelivered bool
+	var p *Processor
+	p = NewProcessor(store, func(ctx context.Context, event Event) error {
+		if !redelivered {
+			redelivered = true
+			now = now.Add(2 * time.Minute)
+			if processed, err := p.Process(ctx, event); err != nil || !processed {
+				t.Errorf("Expected the redelivery to take the expired claim over, got %v, %v", processed, err)
+			}
+		}
+		return nil
+	}, time.Minute)
+
+	if _, err := p.Process(context.Background(), Event{ID: "evt-slow"}); !errors.Is(err, ErrClaimLost) {
+		t.Errorf("Expected ErrClaimLost for the run that lost its claim, got %v", err)
+	}
+}
+
+func TestMemoryStore_Reap(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	store.Claim(ctx, "orphaned", time.Minute)
+	token, _, _ := store.Claim(ctx, "done", time.Minute)
+	store.Complete(ctx, "done", token)
+	now = now.Add(2 * time.Minute)
+	store.Claim(ctx, "active", time.Minute)
+
+	if reaped, err := store.ReapClaims(ctx); err != nil || reaped != 1 {
+		t.Fatalf("Expected 1 expired claim reaped, got %d, %v", reaped, err)
+	}
+	if _, ok := store.claims["active"]; !ok {
+		t.Error("An active claim must not be reaped")
+	}
+
+	if purged, _ := store.PurgeCompleted(ctx, now.Add(-time.Hour)); purged != 0 {
+		t.Errorf("Expected no completed event older than the cutoff, purged %d", purged)
+	}
+	if purged, _ := store.PurgeCompleted(ctx, now); purged != 1 {
+		t.Errorf("Expected the completed event purged, purged %d", purged)
+	}
+	if _, ok, _ := store.Claim(ctx, "done", time.Minute); !ok {
+		t.Error("Expected a purged event to be claimable again")
+	}
+}
@@ -0,0 +1,109 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store for tests and single-instance consumers
+// State is lost on restart, so it only deduplicates redeliveries within one process lifetime
+type MemoryStore struct {
+	mu        sync.Mutex
+	completed map[string]time.Time
+	claims    map[string]memoryClaim
+	now       func() time.Time
+}
+
+// memoryClaim is a claim on an event: its token and when its lease expires
+type memoryClaim struct {
+	token   string
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		completed: make(map[string]time.Time),
+		claims:    make(map[string]memoryClaim),
+		now:       time.Now,
+	}
+}
+
+// Claim reserves the event unless it is completed or holds an unexpired claim
+func (s *MemoryStore) Claim(ctx context.Context, eventID string, lease time.Duration) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.completed[eventID]; ok {
+		return "", false, nil
+	}
+	now := s.now()
+	if claim, ok := s.claims[eventID]; ok && now.Before(claim.expires) {
+		return "", false, nil
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", false, err
+	}
+	s.claims[eventID] = memoryClaim{token: token, expires: now.Add(lease)}
+	return token, true, nil
+}
+
+// Complete marks the event as processed unless another claim took it over
+func (s *MemoryStore) Complete(ctx context.Context, eventID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.completed[eventID]; ok {
+		return ErrClaimLost
+	}
+	if claim, ok := s.claims[eventID]; ok && claim.token != token {
+		return ErrClaimLost
+	}
+	delete(s.claims, eventID)
+	s.completed[eventID] = s.now()
+	return nil
+}
+
+// Release drops the claim token holds on the event
+func (s *MemoryStore) Release(ctx context.Context, eventID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if claim, ok := s.claims[eventID]; ok && claim.token == token {
+		delete(s.claims, eventID)
+	}
+	return nil
+}
+
+// ReapClaims deletes the claims whose lease has expired
+func (s *MemoryStore) ReapClaims(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reaped int64
+	now := s.now()
+	for eventID, claim := range s.claims {
+		if !now.Before(claim.expires) {
+			delete(s.claims, eventID)
+			reaped++
+		}
+	}
+	return reaped, nil
+}
+
+// PurgeCompleted deletes the records of events completed before the cutoff
+func (s *MemoryStore) PurgeCompleted(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for eventID, completedAt := range s.completed {
+		if completedAt.Before(before) {
+			delete(s.completed, eventID)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,182 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Schema creates the table used by SQLStore (PostgreSQL)
+// One row per (consumer, event); completed_at is NULL while an event is only claimed, and
+// claim_token identifies the current claim
+const Schema = `
+CREATE TABLE IF NOT EXISTS consumer_processed_events (
+    consumer TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    lease_until TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    claim_token TEXT,
+    PRIMARY KEY (consumer, event_id)
+);
+ALTER TABLE consumer_processed_events ADD COLUMN IF NOT EXISTS claim_token TEXT;
+CREATE INDEX IF NOT EXISTS idx_consumer_processed_events_completed_at
+    ON consumer_processed_events (consumer, completed_at);
+`
+
+// SQLStore is a PostgreSQL-backed Store shared by all instances of a consumer
+// The consumer name scopes deduplication, so several services can share one table
+type SQLStore struct {
+	db       *sql.DB
+	consumer string
+}
+
+// NewSQLStore creates a store for the named consumer; call Migrate (or apply Schema) before use
+func NewSQLStore(db *sql.DB, consumer string) *SQLStore {
+	return &SQLStore{db: db, consumer: consumer}
+}
+
+// Migrate creates the deduplication table if it does not exist
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, Schema); err != nil {
+		return fmt.Errorf("failed to create consumer_processed_events: %w", err)
+	}
+	return nil
+}
+
+// Claim inserts a claim row, or takes over an expired claim, in a single statement
+func (s *SQLStore) Claim(ctx context.Context, eventID string, lease time.Duration) (string, bool, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", false, err
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO consumer_processed_events (consumer, event_id, lease_until, claim_token)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 millisecond', $4)
+		ON CONFLICT (consumer, event_id) DO UPDATE
+		SET lease_until = EXCLUDED.lease_until, claim_token = EXCLUDED.claim_token
+		WHERE consumer_processed_events.completed_at IS NULL
+		  AND consumer_processed_events.lease_until < NOW()
+	`, s.consumer, eventID, lease.Milliseconds(), token)
+	if err != nil {
+		return "", false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return "", false, err
+	}
+	if rows != 1 {
+		return "", false, nil
+	}
+	return token, true, nil
+}
+
+// Complete marks the event as processed permanently if token holds its claim, or inserts it
+// completed if the claim was reaped
+func (s *SQLStore) Complete(ctx context.Context, eventID, token string) error {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO consumer_processed_events (consumer, event_id, completed_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (consumer, event_id) DO UPDATE
+		SET completed_at = NOW(), lease_until = NULL, claim_token = NULL
+		WHERE consumer_processed_events.completed_at IS NULL
+		  AND consumer_processed_events.claim_token = $3
+	`, s.consumer, eventID, token)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// Release deletes the uncompleted claim token holds so the event can be retried immediately
+func (s *SQLStore) Release(ctx context.Context, eventID, token string) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM consumer_processed_events
+		WHERE consumer = $1 AND event_id = $2 AND completed_at IS NULL AND claim_token = $3
+	`, s.consumer, eventID, token)
+	return err
+}
+
+// ReapClaims deletes the consumer's uncompleted claims whose lease has expired
+func (s *SQLStore) ReapClaims(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM consumer_processed_events
+		WHERE consumer = $1 AND completed_at IS NULL AND lease_until < NOW()
+	`, s.consumer)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reap expired claims: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// PurgeCompleted deletes the consumer's records of events completed before the cutoff
+func (s *SQLStore) PurgeCompleted(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM consumer_processed_events
+		WHERE consumer = $1 AND completed_at < $2
+	`, s.consumer, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge completed events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ProcessTx applies an event exactly once when the handler's side effects live in the same database
+// The processed marker is inserted in the same transaction as the handler's writes, so either both
+// commit or neither does; this closes the crash window between handling and Complete that the
+// lease-based Processor cannot avoid
+// Returns:
+//   - bool: true if the handler ran and committed, false if the event was a duplicate
+//   - error: Handler or database error; the transaction is rolled back
+func (s *SQLStore) ProcessTx(ctx context.Context, event Event, handler func(ctx context.Context, tx *sql.Tx, event Event) error) (bool, error) {
+	if event.ID == "" {
+		return false, ErrMissingEventID
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Concurrent deliveries of the same event block on the primary key until the first commits
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO consumer_processed_events (consumer, event_id, completed_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (consumer, event_id) DO UPDATE
+		SET completed_at = NOW(), lease_until = NULL, claim_token = NULL
+		WHERE consumer_processed_events.completed_at IS NULL
+	`, s.consumer, event.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record event %s: %w", event.ID, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record event %s: %w", event.ID, err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	if err := handler(ctx, tx, event); err != nil {
+		return false, fmt.Errorf("failed to process event %s: %w", event.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit event %s: %w", event.ID, err)
+	}
+	return true, nil
+}
+
+// Compile-time interface implementation checks
+var _ Store = (*MemoryStore)(nil)
+var _ Store = (*SQLStore)(nil)
+var _ Reaper = (*MemoryStore)(nil)
+var _ Reaper = (*SQLStore)(nil)
@@ -0,0 +1,179 @@
+// Package currencies manages the configuration of the supported currencies: their scale, the
+// bounds of a transfer debiting them and whether their accounts may go negative. The configuration
+// is stored by the repository; the Manager installs it into its models.Currencies, which the
+// services it is given to validate against, at startup and after each change. Instances sharing
+// the storage pick up each other's changes at their next refresh (see Run)
+package currencies
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// DefaultRefreshInterval is how often the stored configuration is reloaded
+const DefaultRefreshInterval = time.Minute
+
+// Config controls how the currency configuration is kept current
+type Config struct {
+	// RefreshInterval is how often the stored configuration is reloaded, so changes made through
+	// other instances apply; zero disables reloading
+	RefreshInterval time.Duration
+}
+
+// LoadConfig reads the currency configuration settings from the environment
+// Variables:
+//   - CURRENCY_REFRESH_INTERVAL (1m): How often the stored currencies are reloaded; 0 disables it
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{RefreshInterval: DefaultRefreshInterval}
+	if value := os.Getenv("CURRENCY_REFRESH_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid CURRENCY_REFRESH_INTERVAL %q", value)
+		}
+		config.RefreshInterval = d
+	}
+	return config, nil
+}
+
+// codePattern matches ISO 4217 alphabetic codes
+var codePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Manager reads and changes the currency configuration
+// Safe for concurrent use
+type Manager struct {
+	repo       database.CurrencyRepositoryInterface
+	currencies *models.Currencies
+}
+
+// NewManager creates a manager storing the configuration in repo
+// Its currencies are the built-in ones until the stored configuration is loaded
+func NewManager(repo database.CurrencyRepositoryInterface) *Manager {
+	return &Manager{repo: repo, currencies: models.NewCurrencies(models.DefaultCurrencies())}
+}
+
+// Currencies returns the currencies the manager keeps current, for the services validating
+// against them (e.g. service.AccountService.WithCurrencies)
+func (m *Manager) Currencies() *models.Currencies {
+	return m.currencies
+}
+
+// Load installs the stored configuration into the manager's currencies
+// Returns a storage error, leaving the configuration in use unchanged
+func (m *Manager) Load(ctx context.Context) error {
+	list, err := m.repo.ListCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+	m.currencies.Set(list)
+	return nil
+}
+
+// List returns every configured currency ordered by code
+func (m *Manager) List(ctx context.Context) ([]models.Currency, error) {
+	return m.repo.ListCurrencies(ctx)
+}
+
+// Set configures a currency, adding it if it is not supported yet, and installs the result
+// Validation rules:
+//   - The code must be 3 letters (ISO 4217)
+//   - scale must be 0 to models.AmountScale, and is required for a new currency; omitted, it
+//     keeps its current value
+//   - min_amount and max_amount must be positive decimals with no more decimal places than the
+//     scale, and max_amount at least min_amount; an omitted bound is removed
+//   - allow_negative defaults to the current value, or true for a new currency
+//
+// Returns the stored currency, a *service.ValidationError, service.ErrCurrencyOverdrafts if
+// negative balances are disallowed while accounts in the currency have an overdraft limit, or a
+// storage error
+func (m *Manager) Set(ctx context.Context, code string, req models.SetCurrencyRequest) (*models.Currency, error) {
+	code = models.NormalizeCurrency(code)
+	if !codePattern.MatchString(code) {
+		return nil, &service.ValidationError{Message: fmt.Sprintf("Invalid currency code %q", code)}
+	}
+	list, err := m.repo.ListCurrencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currency, exists := models.Currency{Code: code, AllowNegative: true}, false
+	for _, c := range list {
+		if c.Code == code {
+			currency, exists = c, true
+			break
+		}
+	}
+
+	switch {
+	case req.Scale != nil:
+		if *req.Scale < 0 || *req.Scale > models.AmountScale {
+			return nil, &service.ValidationError{Message: fmt.Sprintf("scale must be between 0 and %d", models.AmountScale)}
+		}
+		currency.Scale = *req.Scale
+	case !exists:
+		return nil, &service.ValidationError{Message: "scale is required for a new currency"}
+	}
+	if req.AllowNegative != nil {
+		currency.AllowNegative = *req.AllowNegative
+	}
+	for _, field := range []struct {
+		name   string
+		value  *string
+		amount **decimal.Decimal
+	}{{"min_amount", req.MinAmount, &currency.MinAmount}, {"max_amount", req.MaxAmount, &currency.MaxAmount}} {
+		*field.amount = nil
+		if field.value == nil {
+			continue
+		}
+		amount, err := decimal.NewFromString(*field.value)
+		if err != nil {
+			return nil, &service.ValidationError{Message: fmt.Sprintf("Invalid %s format", field.name)}
+		}
+		if !amount.IsPositive() {
+			return nil, &service.ValidationError{Message: fmt.Sprintf("%s must be positive", field.name)}
+		}
+		if !amount.Equal(amount.Truncate(currency.Scale)) {
+			return nil, &service.ValidationError{Message: fmt.Sprintf("%s has more than %d decimal places", field.name, currency.Scale)}
+		}
+		*field.amount = &amount
+	}
+	if currency.MinAmount != nil && currency.MaxAmount != nil && currency.MaxAmount.LessThan(*currency.MinAmount) {
+		return nil, &service.ValidationError{Message: "max_amount cannot be less than min_amount"}
+	}
+
+	stored, err := m.repo.SetCurrency(ctx, currency)
+	if err != nil {
+		if err.Error() == service.ErrCurrencyOverdrafts.Error() {
+			return nil, service.ErrCurrencyOverdrafts
+		}
+		return nil, err
+	}
+	if err := m.Load(ctx); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Run reloads the stored configuration every interval until ctx is cancelled
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if err := m.Load(ctx); err != nil {
+			slog.Error("Currency refresh error", "error", err)
+		}
+	}
+}
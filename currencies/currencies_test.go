@@ -0,0 +1,109 @@
+package currencies
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+func stringPtr(s string) *string { return &s }
+
+func scalePtr(s int32) *int32 { return &s }
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("CURRENCY_REFRESH_INTERVAL", "")
+	if config, err := LoadConfig(); err != nil || config.RefreshInterval != DefaultRefreshInterval {
+		t.Errorf("Expected the default interval, got %+v (%v)", config, err)
+	}
+	t.Setenv("CURRENCY_REFRESH_INTERVAL", "0")
+	if config, err := LoadConfig(); err != nil || config.RefreshInterval != 0 {
+		t.Errorf("Expected refreshing to be disabled, got %+v (%v)", config, err)
+	}
+	for _, value := range []string{"often", "-1m"} {
+		t.Setenv("CURRENCY_REFRESH_INTERVAL", value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("CURRENCY_REFRESH_INTERVAL %q: expected an error", value)
+		}
+	}
+}
+
+func TestManager_Set(t *testing.T) {
+	store := memory.NewStore()
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "USD"})
+	store.Accounts().SetOverdraftLimit(1, decimal.NewFromInt(50))
+	manager := NewManager(store.Currencies())
+	ctx := context.Background()
+
+	currency, err := manager.Set(ctx, "eur", models.SetCurrencyRequest{MinAmount: stringPtr("1.00"), MaxAmount: stringPtr("5000"), AllowNegative: boolPtr(false)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if currency.Code != "EUR" || currency.Scale != 2 || currency.MinAmount.String() != "1" || currency.AllowNegative {
+		t.Errorf("Unexpected currency %+v", currency)
+	}
+	// Installed for validation
+	if err := manager.Currencies().CheckTransferAmount("EUR", decimal.RequireFromString("0.99")); err == nil {
+		t.Error("Expected the new minimum to apply")
+	}
+	if _, err := manager.Set(ctx, "XTS", models.SetCurrencyRequest{Scale: scalePtr(4)}); err != nil {
+		t.Fatal(err)
+	}
+	if scale, ok := manager.Currencies().Scale("XTS"); !ok || scale != 4 || !manager.Currencies().AllowsNegative("XTS") {
+		t.Errorf("Expected the new currency with scale 4, got %d, %v", scale, ok)
+	}
+
+	var validation *service.ValidationError
+	for _, tc := range []struct {
+		code string
+		req  models.SetCurrencyRequest
+	}{
+		{"EURO", models.SetCurrencyRequest{}},
+		{"XYZ", models.SetCurrencyRequest{}},
+		{"EUR", models.SetCurrencyRequest{Scale: scalePtr(6)}},
+		{"EUR", models.SetCurrencyRequest{MinAmount: stringPtr("abc")}},
+		{"EUR", models.SetCurrencyRequest{MinAmount: stringPtr("0")}},
+		{"EUR", models.SetCurrencyRequest{MaxAmount: stringPtr("10.001")}},
+		{"EUR", models.SetCurrencyRequest{MinAmount: stringPtr("10"), MaxAmount: stringPtr("5")}},
+	} {
+		if _, err := manager.Set(ctx, tc.code, tc.req); !errors.As(err, &validation) {
+			t.Errorf("%s %+v: expected a validation error, got %v", tc.code, tc.req, err)
+		}
+	}
+
+	// Account 1 may be overdrawn, so USD cannot disallow negative balances
+	if _, err := manager.Set(ctx, "USD", models.SetCurrencyRequest{AllowNegative: boolPtr(false)}); !errors.Is(err, service.ErrCurrencyOverdrafts) {
+		t.Errorf("Expected ErrCurrencyOverdrafts, got %v", err)
+	}
+	if !manager.Currencies().AllowsNegative("USD") {
+		t.Error("Expected the refused change not to be installed")
+	}
+}
+
+func TestManager_Load(t *testing.T) {
+	store := memory.NewStore()
+	ctx := context.Background()
+	if _, err := store.Currencies().SetCurrency(ctx, models.Currency{Code: "JPY", Scale: 1}); err != nil {
+		t.Fatal(err)
+	}
+	manager := NewManager(store.Currencies())
+	if scale, _ := manager.Currencies().Scale("JPY"); scale != 0 {
+		t.Fatalf("Expected the stored change to wait for a load, got scale %d", scale)
+	}
+	if err := manager.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if scale, _ := manager.Currencies().Scale("JPY"); scale != 1 {
+		t.Errorf("Expected the loaded scale 1, got %d", scale)
+	}
+	if scale, _ := (*models.Currencies)(nil).Scale("JPY"); scale != 0 {
+		t.Errorf("Expected the built-in currencies to be left alone, got scale %d", scale)
+	}
+}
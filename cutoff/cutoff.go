@@ -0,0 +1,167 @@
+// Package cutoff assigns value dates to transfers from configurable daily cut-off times
+// A transfer submitted before its type's cut-off on a business day is value-dated the same day;
+// one submitted after the cut-off, or on a weekend or holiday, is value-dated to the next business
+// day. Balances still move immediately; the value date is the accounting date of the transfer
+package cutoff
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// dateLayout is the format of value dates and holiday dates
+const dateLayout = "2006-01-02"
+
+// Schedule holds the cut-off configuration
+// The zero value (or nil) knows only models.DefaultTransferType, without a cut-off, in UTC
+type Schedule struct {
+	cutoffs  map[string]time.Duration
+	location *time.Location
+	holidays map[string]bool
+}
+
+// Parse builds a schedule from its textual configuration
+// Parameters:
+//   - cutoffs: Comma separated type=HH:MM pairs, e.g. "internal=17:30,wire=15:00"
+//   - timezone: IANA time zone the cut-off times are expressed in (empty for UTC)
+//   - holidays: Comma separated YYYY-MM-DD dates that are not business days
+//
+// Returns:
+//   - *Schedule: The parsed schedule
+//   - error: If any entry is malformed or the time zone is unknown
+func Parse(cutoffs, timezone, holidays string) (*Schedule, error) {
+	s := &Schedule{cutoffs: make(map[string]time.Duration), location: time.UTC, holidays: make(map[string]bool)}
+
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cut-off time zone %q: %w", timezone, err)
+		}
+		s.location = location
+	}
+
+	for _, entry := range splitList(cutoffs) {
+		transferType, clock, ok := strings.Cut(entry, "=")
+		transferType = strings.TrimSpace(transferType)
+		if !ok || transferType == "" {
+			return nil, fmt.Errorf("invalid cut-off %q (expected type=HH:MM)", entry)
+		}
+		t, err := time.Parse("15:04", strings.TrimSpace(clock))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cut-off time for %s: %q", transferType, clock)
+		}
+		s.cutoffs[transferType] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	for _, date := range splitList(holidays) {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return nil, fmt.Errorf("invalid holiday %q (expected YYYY-MM-DD)", date)
+		}
+		s.holidays[date] = true
+	}
+
+	return s, nil
+}
+
+// Load builds a schedule from the environment
+// Environment variables used (with defaults):
+//   - TRANSFER_CUTOFFS (unset): type=HH:MM pairs; types without a cut-off are value-dated same day
+//   - CUTOFF_TIMEZONE (UTC): Time zone of the cut-off times and value dates
+//   - BUSINESS_HOLIDAYS (unset): YYYY-MM-DD dates that are not business days
+func Load() (*Schedule, error) {
+	return Parse(os.Getenv("TRANSFER_CUTOFFS"), os.Getenv("CUTOFF_TIMEZONE"), os.Getenv("BUSINESS_HOLIDAYS"))
+}
+
+// Types returns the known transfer types, sorted
+func (s *Schedule) Types() []string {
+	types := []string{models.DefaultTransferType}
+	if s != nil {
+		for t := range s.cutoffs {
+			if t != models.DefaultTransferType {
+				types = append(types, t)
+			}
+		}
+	}
+	sort.Strings(types[1:])
+	return types
+}
+
+// Known reports whether transferType is accepted: the default type or any type with a cut-off
+func (s *Schedule) Known(transferType string) bool {
+	if transferType == models.DefaultTransferType {
+		return true
+	}
+	if s == nil {
+		return false
+	}
+	_, ok := s.cutoffs[transferType]
+	return ok
+}
+
+// ValueDate returns the value date of a transfer of the given type submitted at now
+// The result is midnight UTC of the value date, so it compares and stores as a plain date
+// Types without a configured cut-off are value-dated on the current calendar day
+func (s *Schedule) ValueDate(transferType string, now time.Time) time.Time {
+	date := s.Today(now)
+	if s == nil {
+		return date
+	}
+	cutoff, ok := s.cutoffs[transferType]
+	if !ok {
+		return date
+	}
+
+	local := now.In(s.zone())
+	sinceMidnight := local.Sub(time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location()))
+	if sinceMidnight >= cutoff {
+		date = date.AddDate(0, 0, 1)
+	}
+	for !s.businessDay(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// Today returns the current calendar date in the schedule's time zone, as midnight UTC
+// Once Today has moved past a date, no further transfers can be value-dated on it
+func (s *Schedule) Today(now time.Time) time.Time {
+	local := now.In(s.zone())
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// zone returns the time zone cut-off times are expressed in
+func (s *Schedule) zone() *time.Location {
+	if s == nil || s.location == nil {
+		return time.UTC
+	}
+	return s.location
+}
+
+// businessDay reports whether date is a weekday that is not a holiday
+func (s *Schedule) businessDay(date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	return !s.holidays[date.Format(dateLayout)]
+}
+
+// FormatDate formats a value date as YYYY-MM-DD
+func FormatDate(date time.Time) string {
+	return date.Format(dateLayout)
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
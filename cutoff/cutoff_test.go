@@ -0,0 +1,95 @@
+package cutoff
+
+import (
+	"testing"
+	"time"
+
+	"internal-transfers/models"
+)
+
+func TestParse_Errors(t *testing.T) {
+	testCases := []struct {
+		name                        string
+		cutoffs, timezone, holidays string
+	}{
+		{"missing time", "wire", "", ""},
+		{"missing type", "=15:00", "", ""},
+		{"bad time", "wire=3pm", "", ""},
+		{"bad hour", "wire=25:00", "", ""},
+		{"unknown zone", "", "Mars/Olympus", ""},
+		{"bad holiday", "", "", "2024-13-01"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse(tc.cutoffs, tc.timezone, tc.holidays); err == nil {
+				t.Error("Expected parse error")
+			}
+		})
+	}
+}
+
+func TestSchedule_ValueDate(t *testing.T) {
+	schedule, err := Parse("wire=15:00, ach=17:30", "", "2024-03-11")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		transferType string
+		now          string
+		want         string
+	}{
+		{"before cut-off", "wire", "2024-03-06T14:59:00Z", "2024-03-06"},
+		{"at cut-off", "wire", "2024-03-06T15:00:00Z", "2024-03-07"},
+		{"after cut-off on friday", "wire", "2024-03-08T16:00:00Z", "2024-03-12"},
+		{"saturday", "ach", "2024-03-09T09:00:00Z", "2024-03-12"},
+		{"holiday", "ach", "2024-03-11T09:00:00Z", "2024-03-12"},
+		{"no cut-off", models.DefaultTransferType, "2024-03-09T23:00:00Z", "2024-03-09"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now, _ := time.Parse(time.RFC3339, tc.now)
+			if got := FormatDate(schedule.ValueDate(tc.transferType, now)); got != tc.want {
+				t.Errorf("Expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSchedule_TimeZone(t *testing.T) {
+	schedule, err := Parse("wire=15:00", "America/New_York", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 19:30 UTC is 15:30 in New York (EDT), past the cut-off
+	now, _ := time.Parse(time.RFC3339, "2024-06-05T19:30:00Z")
+	if got := FormatDate(schedule.ValueDate("wire", now)); got != "2024-06-06" {
+		t.Errorf("Expected 2024-06-06, got %s", got)
+	}
+
+	// 18:00 UTC is 14:00 in New York, before the cut-off
+	now, _ = time.Parse(time.RFC3339, "2024-06-05T18:00:00Z")
+	if got := FormatDate(schedule.ValueDate("wire", now)); got != "2024-06-05" {
+		t.Errorf("Expected 2024-06-05, got %s", got)
+	}
+}
+
+func TestSchedule_Known(t *testing.T) {
+	schedule, _ := Parse("wire=15:00", "", "")
+
+	if !schedule.Known(models.DefaultTransferType) || !schedule.Known("wire") || schedule.Known("swift") {
+		t.Error("Expected only the default type and configured types to be known")
+	}
+	if got := schedule.Types(); len(got) != 2 || got[0] != models.DefaultTransferType || got[1] != "wire" {
+		t.Errorf("Unexpected types %v", got)
+	}
+
+	var empty *Schedule
+	if !empty.Known(models.DefaultTransferType) || empty.Known("wire") {
+		t.Error("Expected a nil schedule to know only the default type")
+	}
+}
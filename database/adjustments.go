@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// AdjustmentRepository implements AdjustmentRepositoryInterface for PostgreSQL
+type AdjustmentRepository struct {
+	db *sql.DB
+}
+
+// NewAdjustmentRepository creates a new adjustment repository instance
+func NewAdjustmentRepository(db *sql.DB) *AdjustmentRepository {
+	return &AdjustmentRepository{db: db}
+}
+
+// adjustmentColumns is the select list read by scanAdjustment
+const adjustmentColumns = `id, account_id, amount, reason, actor, balance_after, created_at`
+
+// scanAdjustment reads one row selected with adjustmentColumns
+func scanAdjustment(row interface{ Scan(dest ...any) error }) (models.Adjustment, error) {
+	var a models.Adjustment
+	err := row.Scan(&a.ID, &a.AccountID, &a.Amount, &a.Reason, &a.Actor, &a.BalanceAfter, &a.CreatedAt)
+	return a, err
+}
+
+// CreateAdjustment books a manual credit or debit of an account
+// Parameters:
+//   - ctx: Context bounding the database transaction
+//   - adjustment: The account, signed amount, reason and actor (validated by caller); its ID,
+//     balance after and creation time are assigned by the database
+//
+// Returns:
+//   - *models.Adjustment: The booked adjustment
+//   - error: "account not found", "insufficient balance" if a debit exceeds the available balance
+//     (the overdraft limit counts, the funds reserved by active holds do not), or a database error
+//
+// Database behavior:
+//   - The account row is locked while the debit is checked and the balance changed; frozen
+//     accounts can be adjusted
+//   - The version is incremented, so optimistic transfers that read the account before the
+//     adjustment retry instead of checking a stale balance
+//   - The balance snapshot and the account.adjusted outbox event are written in the same commit
+func (r *AdjustmentRepository) CreateAdjustment(ctx context.Context, adjustment models.Adjustment) (*models.Adjustment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var balance, overdraft, held decimal.Decimal
+	var sequence int64
+	err = tx.QueryRowContext(ctx, "SELECT balance, overdraft_limit, held, sequence FROM accounts WHERE account_id = $1 FOR UPDATE",
+		adjustment.AccountID).Scan(&balance, &overdraft, &held, &sequence)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("account not found")
+		}
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if adjustment.Amount.IsNegative() && balance.Add(overdraft).Sub(held).LessThan(adjustment.Amount.Neg()) {
+		return nil, fmt.Errorf("insufficient balance")
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE accounts SET balance = balance + $1, version = version + 1, updated_at = NOW()
+		 WHERE account_id = $2
+		 RETURNING balance`,
+		adjustment.Amount, adjustment.AccountID,
+	).Scan(&adjustment.BalanceAfter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO adjustments (account_id, amount, reason, actor, balance_after)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, adjustment.AccountID, adjustment.Amount, adjustment.Reason, adjustment.Actor, adjustment.BalanceAfter).
+		Scan(&adjustment.ID, &adjustment.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create adjustment: %w", err)
+	}
+
+	snapshot := models.BalanceSnapshot{AccountID: adjustment.AccountID, Balance: adjustment.BalanceAfter, Sequence: sequence, Source: models.BalanceSnapshotAdjustment}
+	if err := addSnapshotTx(tx, snapshot); err != nil {
+		return nil, err
+	}
+	if err := enqueueEvent(tx, EventAccountAdjusted, adjustment.AccountID, models.NewAdjustmentResponse(adjustment)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &adjustment, nil
+}
+
+// ListAdjustments returns the account's adjustments created in [from, to) oldest first
+// Returns "account not found" if the account does not exist
+// Served by the (account_id, created_at) index
+func (r *AdjustmentRepository) ListAdjustments(ctx context.Context, accountID int64, from, to time.Time, limit int) ([]models.Adjustment, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM accounts WHERE account_id = $1)`, accountID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+
+	var fromParam, toParam interface{}
+	if !from.IsZero() {
+		fromParam = from
+	}
+	if !to.IsZero() {
+		toParam = to
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+adjustmentColumns+`
+		FROM adjustments
+		WHERE account_id = $1
+		  AND ($2::timestamptz IS NULL OR created_at >= $2)
+		  AND ($3::timestamptz IS NULL OR created_at < $3)
+		ORDER BY created_at, id
+		LIMIT $4
+	`, accountID, fromParam, toParam, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	adjustments := []models.Adjustment{}
+	for rows.Next() {
+		adjustment, err := scanAdjustment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan adjustment: %w", err)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list adjustments: %w", err)
+	}
+	return adjustments, nil
+}
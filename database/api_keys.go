@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"internal-transfers/models"
+)
+
+// APIKeyRepository implements APIKeyRepositoryInterface for PostgreSQL
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository instance
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// CreateAPIKey stores a new key and returns it with its creation time
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (id, secret, description)
+		VALUES ($1, $2, $3)
+		RETURNING created_at
+	`, key.ID, key.Secret, key.Description).Scan(&key.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return &key, nil
+}
+
+// GetAPIKey returns a key, revoked or not, or "API key not found"
+func (r *APIKeyRepository) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, secret, description, created_at, revoked_at
+		FROM api_keys
+		WHERE id = $1
+	`, id).Scan(&key.ID, &key.Secret, &key.Description, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API key not found")
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns every key, revoked ones included, oldest first
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, secret, description, created_at, revoked_at
+		FROM api_keys
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(&key.ID, &key.Secret, &key.Description, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey marks a key revoked and returns it
+// A revoked key keeps its original revocation time; unknown keys return "API key not found"
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+		RETURNING id, secret, description, created_at, revoked_at
+	`, id).Scan(&key.ID, &key.Secret, &key.Description, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API key not found")
+		}
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return &key, nil
+}
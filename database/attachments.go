@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"internal-transfers/models"
+)
+
+// AttachmentRepository implements AttachmentRepositoryInterface for PostgreSQL
+type AttachmentRepository struct {
+	db *sql.DB
+}
+
+// NewAttachmentRepository creates a new attachment repository instance
+func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+// attachmentColumns is the select list read by scanAttachment
+const attachmentColumns = `id, transaction_id, filename, content_type, size, sha256, object_key, created_at`
+
+// scanAttachment reads one row selected with attachmentColumns
+func scanAttachment(row interface{ Scan(dest ...any) error }) (models.Attachment, error) {
+	var a models.Attachment
+	err := row.Scan(&a.ID, &a.TransactionID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.ObjectKey, &a.CreatedAt)
+	return a, err
+}
+
+// AddAttachment records an attachment; its ID and creation time are assigned by the database
+// Returns "transaction not found" if the transaction does not exist
+func (r *AttachmentRepository) AddAttachment(ctx context.Context, attachment models.Attachment) (*models.Attachment, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO attachments (transaction_id, filename, content_type, size, sha256, object_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, attachment.TransactionID, attachment.Filename, attachment.ContentType, attachment.Size, attachment.SHA256, attachment.ObjectKey).
+		Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("transaction not found")
+		}
+		return nil, fmt.Errorf("failed to create attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+// ListAttachments returns a transaction's attachments oldest first
+// Returns "transaction not found" if the transaction does not exist
+func (r *AttachmentRepository) ListAttachments(ctx context.Context, transactionID int64) ([]models.Attachment, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM transactions WHERE id = $1)`, transactionID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("transaction not found")
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+attachmentColumns+` FROM attachments WHERE transaction_id = $1 ORDER BY id`, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+// GetAttachment returns one of a transaction's attachments
+// Returns "attachment not found" if it does not exist or belongs to another transaction
+func (r *AttachmentRepository) GetAttachment(ctx context.Context, transactionID, id int64) (*models.Attachment, error) {
+	attachment, err := scanAttachment(r.db.QueryRowContext(ctx,
+		`SELECT `+attachmentColumns+` FROM attachments WHERE id = $1 AND transaction_id = $2`, id, transactionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("attachment not found")
+		}
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return &attachment, nil
+}
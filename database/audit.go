@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"internal-transfers/models"
+)
+
+// AuditRepository implements AuditRepositoryInterface for PostgreSQL
+// The audit_events triggers reject updates and deletes, so the repository only inserts and reads
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new audit log repository instance
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// auditColumns lists the audit_events columns in the order scanAuditEvent reads them
+const auditColumns = `id, occurred_at, actor, action, to_jsonb(account_ids), COALESCE(transaction_id, 0), COALESCE(request_id, ''), before, after`
+
+// AppendAuditEvent inserts the event and returns it with its ID and occurrence time
+func (r *AuditRepository) AppendAuditEvent(ctx context.Context, event models.AuditEvent) (*models.AuditEvent, error) {
+	accountIDs := event.AccountIDs
+	if accountIDs == nil {
+		accountIDs = []int64{}
+	}
+	stored, err := scanAuditEvent(r.db.QueryRowContext(ctx, `
+		INSERT INTO audit_events (actor, action, account_ids, transaction_id, request_id, before, after)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''), $6, $7)
+		RETURNING `+auditColumns,
+		event.Actor, event.Action, accountIDs, event.TransactionID, event.RequestID, nullJSON(event.Before), nullJSON(event.After)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to append audit event: %w", err)
+	}
+	return &stored, nil
+}
+
+// ListAuditEvents returns the events matching the filter newest first, capped at limit
+// An account filter is served by the GIN index on account_ids, a transaction filter by the
+// transaction_id index, a period by the occurred_at index
+func (r *AuditRepository) ListAuditEvents(ctx context.Context, filter models.AuditFilter, limit int) ([]models.AuditEvent, error) {
+	var from, to interface{}
+	if !filter.From.IsZero() {
+		from = filter.From
+	}
+	if !filter.To.IsZero() {
+		to = filter.To
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_events
+		WHERE ($1 = '' OR actor = $1)
+		  AND ($2 = '' OR action = $2)
+		  AND ($3 = 0 OR account_ids @> ARRAY[$3::bigint])
+		  AND ($4::timestamptz IS NULL OR occurred_at >= $4)
+		  AND ($5::timestamptz IS NULL OR occurred_at < $5)
+		  AND ($6 = 0 OR id < $6)
+		  AND ($8 = 0 OR transaction_id = $8)
+		ORDER BY id DESC
+		LIMIT $7
+	`, filter.Actor, filter.Action, filter.AccountID, from, to, filter.BeforeID, limit, filter.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, nil
+}
+
+// scanAuditEvent reads a row selected with auditColumns
+func scanAuditEvent(row interface{ Scan(dest ...any) error }) (models.AuditEvent, error) {
+	var event models.AuditEvent
+	var accountIDs []byte
+	var before, after []byte
+	if err := row.Scan(&event.ID, &event.OccurredAt, &event.Actor, &event.Action, &accountIDs, &event.TransactionID, &event.RequestID, &before, &after); err != nil {
+		return event, err
+	}
+	if err := json.Unmarshal(accountIDs, &event.AccountIDs); err != nil {
+		return event, fmt.Errorf("failed to decode account IDs: %w", err)
+	}
+	event.Before, event.After = jsonOrNull(before), jsonOrNull(after)
+	return event, nil
+}
+
+// nullJSON converts an absent or null snapshot to SQL NULL
+func nullJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	return string(data)
+}
+
+// jsonOrNull returns a stored snapshot, or JSON null for SQL NULL
+func jsonOrNull(data []byte) json.RawMessage {
+	if data == nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(data)
+}
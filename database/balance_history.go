@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// BalanceHistoryRepository implements BalanceHistoryRepositoryInterface for PostgreSQL
+type BalanceHistoryRepository struct {
+	db *sql.DB
+}
+
+// NewBalanceHistoryRepository creates a new balance snapshot repository instance
+func NewBalanceHistoryRepository(db *sql.DB) *BalanceHistoryRepository {
+	return &BalanceHistoryRepository{db: db}
+}
+
+// addSnapshotTx records a balance snapshot inside the caller's database transaction, so it
+// commits or rolls back with the change that produced the balance
+func addSnapshotTx(tx *sql.Tx, snapshot models.BalanceSnapshot) error {
+	_, err := tx.Exec(`
+		INSERT INTO balance_snapshots (account_id, balance, sequence, source, transaction_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+	`, snapshot.AccountID, snapshot.Balance, snapshot.Sequence, snapshot.Source, snapshot.TransactionID)
+	if err != nil {
+		return fmt.Errorf("failed to record balance snapshot: %w", err)
+	}
+	return nil
+}
+
+// SnapshotBalances records a periodic snapshot of every account without a snapshot since since
+// The balances are read and written in a single statement, so they are consistent with each other
+func (r *BalanceHistoryRepository) SnapshotBalances(ctx context.Context, since time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO balance_snapshots (account_id, balance, sequence, source)
+		SELECT a.account_id, a.balance, a.sequence, $1 FROM accounts a
+		WHERE NOT EXISTS (
+			SELECT 1 FROM balance_snapshots s WHERE s.account_id = a.account_id AND s.taken_at >= $2
+		)
+	`, models.BalanceSnapshotPeriodic, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to snapshot balances: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ListBalanceSnapshots returns the account's snapshots in the filter's period in the order they
+// were written, oldest first
+// Served by the (account_id, taken_at) index
+func (r *BalanceHistoryRepository) ListBalanceSnapshots(ctx context.Context, filter models.BalanceHistoryFilter, limit int) ([]models.BalanceSnapshot, error) {
+	var from, to interface{}
+	if !filter.From.IsZero() {
+		from = filter.From
+	}
+	if !filter.To.IsZero() {
+		to = filter.To
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, account_id, balance, sequence, taken_at, source, COALESCE(transaction_id, 0)
+		FROM balance_snapshots
+		WHERE account_id = $1
+		  AND ($2::timestamptz IS NULL OR taken_at >= $2)
+		  AND ($3::timestamptz IS NULL OR taken_at < $3)
+		  AND id > $4
+		ORDER BY id
+		LIMIT $5
+	`, filter.AccountID, from, to, filter.AfterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list balance snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []models.BalanceSnapshot{}
+	for rows.Next() {
+		var s models.BalanceSnapshot
+		if err := rows.Scan(&s.ID, &s.AccountID, &s.Balance, &s.Sequence, &s.TakenAt, &s.Source, &s.TransactionID); err != nil {
+			return nil, fmt.Errorf("failed to scan balance snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list balance snapshots: %w", err)
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// BulkAccount is an account written by BulkLoad with its opening balance
+type BulkAccount struct {
+	AccountID      int64
+	InitialBalance decimal.Decimal
+	CreatedAt      time.Time
+}
+
+// BulkTransfer is a completed transfer written by BulkLoad, with the ledger sequence numbers it
+// took on each account
+type BulkTransfer struct {
+	SourceAccountID      int64
+	DestinationAccountID int64
+	Amount               decimal.Decimal
+	SourceSequence       int64
+	DestinationSequence  int64
+	CreatedAt            time.Time
+}
+
+// BulkBalance is an account's balance and sequence number after all of its transfers
+type BulkBalance struct {
+	AccountID int64
+	Balance   decimal.Decimal
+	Sequence  int64
+}
+
+// BulkSource supplies the ledger BulkLoad writes, in this order: every account, then every
+// transfer, then every account's final balance
+// Each method returns false once its rows are exhausted
+type BulkSource interface {
+	NextAccount() (BulkAccount, bool)
+	NextTransfer() (BulkTransfer, bool)
+	NextBalance() (BulkBalance, bool)
+}
+
+// BulkLoadResult counts the rows BulkLoad wrote
+type BulkLoadResult struct {
+	Accounts  int64
+	Transfers int64
+	Duration  time.Duration
+}
+
+// BulkLoad writes a ledger built outside the service straight into the database with COPY, for
+// volumes the API cannot create in reasonable time (e.g. millions of synthetic accounts)
+// Everything is written in one transaction: the accounts with an opening balance snapshot, the
+// transfers as completed transactions, then the final balances and sequence numbers with a
+// periodic snapshot. The source must keep the ledger consistent (balances equal to the opening
+// balance plus the transfers, sequence numbers without gaps), as nothing is validated here.
+// No outbox, audit or usage events are written. The tables are analyzed afterwards so the planner
+// sees the new volume
+func BulkLoad(ctx context.Context, pool *pgxpool.Pool, source BulkSource) (BulkLoadResult, error) {
+	started := time.Now()
+	var result BulkLoadResult
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result.Accounts, err = tx.CopyFrom(ctx, pgx.Identifier{"accounts"},
+		[]string{"account_id", "balance", "initial_balance", "created_at"},
+		pgx.CopyFromFunc(func() ([]any, error) {
+			account, ok := source.NextAccount()
+			if !ok {
+				return nil, nil
+			}
+			balance := numeric(account.InitialBalance)
+			return []any{account.AccountID, balance, balance, account.CreatedAt}, nil
+		}))
+	if err != nil {
+		return result, fmt.Errorf("failed to copy accounts: %w", err)
+	}
+
+	// Opening snapshots of the accounts just copied, the only ones without any snapshot
+	_, err = tx.Exec(ctx, `
+		INSERT INTO balance_snapshots (account_id, balance, sequence, taken_at, source)
+		SELECT a.account_id, a.initial_balance, 0, a.created_at, $1 FROM accounts a
+		WHERE NOT EXISTS (SELECT 1 FROM balance_snapshots s WHERE s.account_id = a.account_id)
+	`, models.BalanceSnapshotOpened)
+	if err != nil {
+		return result, fmt.Errorf("failed to record opening snapshots: %w", err)
+	}
+
+	result.Transfers, err = tx.CopyFrom(ctx, pgx.Identifier{"transactions"},
+		[]string{"source_account_id", "destination_account_id", "amount", "destination_amount", "source_sequence",
+			"destination_sequence", "value_date", "created_at", "effective_at"},
+		pgx.CopyFromFunc(func() ([]any, error) {
+			transfer, ok := source.NextTransfer()
+			if !ok {
+				return nil, nil
+			}
+			amount := numeric(transfer.Amount)
+			return []any{transfer.SourceAccountID, transfer.DestinationAccountID, amount, amount, transfer.SourceSequence,
+				transfer.DestinationSequence, transfer.CreatedAt.UTC().Truncate(24 * time.Hour), transfer.CreatedAt, transfer.CreatedAt}, nil
+		}))
+	if err != nil {
+		return result, fmt.Errorf("failed to copy transfers: %w", err)
+	}
+
+	// The final balances go through a staging table, so they are applied in one UPDATE
+	_, err = tx.Exec(ctx, `CREATE TEMPORARY TABLE bulk_balances (account_id BIGINT PRIMARY KEY, balance DECIMAL(15,5) NOT NULL, sequence BIGINT NOT NULL) ON COMMIT DROP`)
+	if err != nil {
+		return result, fmt.Errorf("failed to stage balances: %w", err)
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"bulk_balances"}, []string{"account_id", "balance", "sequence"},
+		pgx.CopyFromFunc(func() ([]any, error) {
+			balance, ok := source.NextBalance()
+			if !ok {
+				return nil, nil
+			}
+			return []any{balance.AccountID, numeric(balance.Balance), balance.Sequence}, nil
+		}))
+	if err != nil {
+		return result, fmt.Errorf("failed to copy balances: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE accounts a SET balance = b.balance, sequence = b.sequence, updated_at = NOW()
+		FROM bulk_balances b WHERE a.account_id = b.account_id
+	`)
+	if err != nil {
+		return result, fmt.Errorf("failed to apply balances: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO balance_snapshots (account_id, balance, sequence, source)
+		SELECT account_id, balance, sequence, $1 FROM bulk_balances
+	`, models.BalanceSnapshotPeriodic)
+	if err != nil {
+		return result, fmt.Errorf("failed to record balance snapshots: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("failed to commit bulk load: %w", err)
+	}
+	if _, err := pool.Exec(ctx, `ANALYZE accounts, transactions, balance_snapshots`); err != nil {
+		return result, fmt.Errorf("failed to analyze tables: %w", err)
+	}
+	result.Duration = time.Since(started)
+	return result, nil
+}
+
+// numeric converts a decimal for COPY, which encodes parameters in binary and so cannot take the
+// decimal's text form
+func numeric(d decimal.Decimal) pgtype.Numeric {
+	return pgtype.Numeric{Int: d.Coefficient(), Exp: d.Exponent(), Valid: true}
+}
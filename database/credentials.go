@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultCredentialCheckInterval is how often the database credentials are checked for rotation
+const DefaultCredentialCheckInterval = 30 * time.Second
+
+// credentialMetrics counts the credential rotations applied to the pool and those refused
+var credentialMetrics = expvar.NewMap("db_credentials")
+
+// Credentials are the user name and password connections to the primary authenticate with
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// CredentialConfig says where the database credentials come from and how often they are checked
+// for rotation
+type CredentialConfig struct {
+	// UserFile and PasswordFile hold the user name and password, e.g. rendered by Vault Agent from
+	// a dynamic secret; they are re-read at every check. Unset, DB_USER and DB_PASSWORD are used,
+	// which a config file reload may change
+	UserFile     string
+	PasswordFile string
+
+	// CheckInterval is how often the credentials are checked; zero disables following rotations,
+	// so connections keep using the credentials read at startup
+	CheckInterval time.Duration
+}
+
+// LoadCredentialConfig reads the credential rotation settings from the environment
+// Variables:
+//   - DB_USER_FILE (unset): File holding the database user name, overriding DB_USER
+//   - DB_PASSWORD_FILE (unset): File holding the database password, overriding DB_PASSWORD
+//   - DB_CREDENTIALS_CHECK_INTERVAL (30s): How often the credentials are checked for rotation; 0
+//     disables following rotations, keeping the credentials read at startup
+//
+// Returns an error for malformed values or unreadable files
+func LoadCredentialConfig() (CredentialConfig, error) {
+	interval, err := getEnvDuration("DB_CREDENTIALS_CHECK_INTERVAL", DefaultCredentialCheckInterval)
+	if err != nil {
+		return CredentialConfig{}, err
+	}
+	config := CredentialConfig{
+		UserFile:      os.Getenv("DB_USER_FILE"),
+		PasswordFile:  os.Getenv("DB_PASSWORD_FILE"),
+		CheckInterval: interval,
+	}
+	if _, err := config.Credentials(); err != nil {
+		return CredentialConfig{}, err
+	}
+	return config, nil
+}
+
+// Credentials reads the current credentials: from the files if they are set, otherwise from
+// DB_USER and DB_PASSWORD
+// Returns an error for an unreadable or empty file
+func (c CredentialConfig) Credentials() (Credentials, error) {
+	credentials := Credentials{
+		User:     getEnvWithDefault("DB_USER", "postgres"),
+		Password: getEnvWithDefault("DB_PASSWORD", "postgres"),
+	}
+	for _, file := range []struct {
+		path  string
+		value *string
+	}{{c.UserFile, &credentials.User}, {c.PasswordFile, &credentials.Password}} {
+		if file.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to read database credentials: %w", err)
+		}
+		// Secret files commonly end with a newline
+		if *file.value = strings.TrimRight(string(data), "\r\n"); *file.value == "" {
+			return Credentials{}, fmt.Errorf("database credentials file %s is empty", file.path)
+		}
+	}
+	return credentials, nil
+}
+
+// verifiedCredentials holds the credentials new connections authenticate with: those the pool
+// connected with at startup, replaced only by rotated ones the server has accepted, so a file
+// rewritten with credentials that do not work yet cannot break the connections opened meanwhile
+// Safe for concurrent use
+type verifiedCredentials struct {
+	current atomic.Pointer[Credentials]
+}
+
+// newVerifiedCredentials holds credentials until they are replaced by Store
+func newVerifiedCredentials(credentials Credentials) *verifiedCredentials {
+	v := &verifiedCredentials{}
+	v.Store(credentials)
+	return v
+}
+
+// Load returns the credentials last verified
+func (v *verifiedCredentials) Load() Credentials {
+	return *v.current.Load()
+}
+
+// Store replaces the credentials with ones the server accepted
+func (v *verifiedCredentials) Store(credentials Credentials) {
+	v.current.Store(&credentials)
+}
+
+// beforeConnect makes each new connection authenticate with the verified credentials
+// (pgxpool.Config.BeforeConnect)
+func (v *verifiedCredentials) beforeConnect(ctx context.Context, config *pgx.ConnConfig) error {
+	credentials := v.Load()
+	config.User, config.Password = credentials.User, credentials.Password
+	return nil
+}
+
+// WatchCredentials reconnects the pool when the database credentials rotate, checking them every
+// DB_CREDENTIALS_CHECK_INTERVAL until ctx is done; it returns at once if checking is disabled
+// New credentials are first tried on a connection of their own: if the server refuses them, e.g.
+// because a file was rotated before its role was created, the pool keeps the connections it has,
+// new connections keep the previous credentials and they are tried again at the next check. Once
+// accepted, they replace the previous ones for new connections, idle connections are closed and
+// those in use closed when released, so requests in flight complete and the next ones use the new
+// credentials without downtime. Connections that the server terminates when the previous
+// credentials are revoked are recovered like a failover (see Failover)
+func (s *PostgresStorage) WatchCredentials(ctx context.Context) {
+	if s.credentials.CheckInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.credentials.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.checkCredentials(ctx)
+	}
+}
+
+// checkCredentials reconnects the pool if the credentials changed from the verified ones and the
+// server accepts the new ones, which then replace them
+func (s *PostgresStorage) checkCredentials(ctx context.Context) {
+	next, err := s.credentials.Credentials()
+	if err != nil {
+		slog.ErrorContext(ctx, "Database credentials unreadable; keeping the current connections", "error", err)
+		return
+	}
+	if next == s.verified.Load() {
+		return
+	}
+	if err := s.tryCredentials(ctx, next); err != nil {
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "New database credentials refused; keeping the current connections", "user", next.User, "error", err)
+			credentialMetrics.Add("refused", 1)
+		}
+		return
+	}
+	slog.InfoContext(ctx, "Database credentials rotated; reconnecting the pool", "user", next.User)
+	s.verified.Store(next)
+	s.failover.reconnect()
+	credentialMetrics.Add("rotations", 1)
+}
+
+// tryCredentials opens and closes a connection to the primary with credentials
+func (s *PostgresStorage) tryCredentials(ctx context.Context, credentials Credentials) error {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	config := s.pool.Config().ConnConfig
+	config.User, config.Password = credentials.User, credentials.Password
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return err
+	}
+	return conn.Close(ctx)
+}
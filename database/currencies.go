@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// CurrencyRepository implements CurrencyRepositoryInterface for PostgreSQL
+type CurrencyRepository struct {
+	db *sql.DB
+}
+
+// NewCurrencyRepository creates a new currency repository instance
+func NewCurrencyRepository(db *sql.DB) *CurrencyRepository {
+	return &CurrencyRepository{db: db}
+}
+
+// ListCurrencies returns every configured currency ordered by code
+func (r *CurrencyRepository) ListCurrencies(ctx context.Context) ([]models.Currency, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT code, scale, min_amount, max_amount, allow_negative, updated_at
+		FROM currencies
+		ORDER BY code
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list currencies: %w", err)
+	}
+	defer rows.Close()
+
+	currencies := []models.Currency{}
+	for rows.Next() {
+		var c models.Currency
+		var minAmount, maxAmount decimal.NullDecimal
+		if err := rows.Scan(&c.Code, &c.Scale, &minAmount, &maxAmount, &c.AllowNegative, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan currency: %w", err)
+		}
+		if minAmount.Valid {
+			c.MinAmount = &minAmount.Decimal
+		}
+		if maxAmount.Valid {
+			c.MaxAmount = &maxAmount.Decimal
+		}
+		currencies = append(currencies, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list currencies: %w", err)
+	}
+	return currencies, nil
+}
+
+// SetCurrency adds or replaces a currency's configuration
+// Parameters:
+//   - ctx: Context bounding the database transaction
+//   - currency: The configuration to store (validated by caller)
+//
+// Returns:
+//   - *models.Currency: The stored configuration, with its update time
+//   - error: "currency has overdraft limits" if negative balances are disallowed while accounts in
+//     the currency have an overdraft limit, or a database error
+//
+// Database behavior:
+//   - Accounts in the currency are locked with FOR SHARE while negative balances are disallowed,
+//     so none is given an overdraft limit meanwhile
+func (r *CurrencyRepository) SetCurrency(ctx context.Context, currency models.Currency) (*models.Currency, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if !currency.AllowNegative {
+		var overdrawable bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM accounts WHERE currency = $1 AND overdraft_limit > 0 FOR SHARE
+			)
+		`, currency.Code).Scan(&overdrawable)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check overdraft limits: %w", err)
+		}
+		if overdrawable {
+			return nil, fmt.Errorf("currency has overdraft limits")
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO currencies (code, scale, min_amount, max_amount, allow_negative)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (code) DO UPDATE
+		SET scale = EXCLUDED.scale, min_amount = EXCLUDED.min_amount, max_amount = EXCLUDED.max_amount,
+		    allow_negative = EXCLUDED.allow_negative, updated_at = NOW()
+		RETURNING updated_at
+	`, currency.Code, currency.Scale, nullDecimal(currency.MinAmount), nullDecimal(currency.MaxAmount), currency.AllowNegative).
+		Scan(&currency.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set currency: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &currency, nil
+}
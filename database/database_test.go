@@ -1,13 +1,28 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"expvar"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
 )
 
 // =============================================================================
@@ -40,6 +55,459 @@ func TestGetEnvWithDefault(t *testing.T) {
 	}
 }
 
+func TestLoadPoolConfig(t *testing.T) {
+	keys := []string{"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONN_MAX_IDLE_TIME"}
+	resetPoolEnv := func() {
+		for _, k := range keys {
+			os.Unsetenv(k)
+		}
+	}
+	resetPoolEnv()
+	defer resetPoolEnv()
+
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, err := loadPoolConfig()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.MaxOpenConns != defaultMaxOpenConns || cfg.MaxIdleConns != defaultMaxIdleConns {
+			t.Errorf("Unexpected default connection limits: %+v", cfg)
+		}
+		if cfg.ConnMaxLifetime != defaultConnMaxLifetime || cfg.ConnMaxIdleTime != defaultConnMaxIdleTime {
+			t.Errorf("Unexpected default connection durations: %+v", cfg)
+		}
+	})
+
+	t.Run("Custom values", func(t *testing.T) {
+		os.Setenv("DB_MAX_OPEN_CONNS", "50")
+		os.Setenv("DB_MAX_IDLE_CONNS", "20")
+		os.Setenv("DB_CONN_MAX_LIFETIME", "1h")
+		os.Setenv("DB_CONN_MAX_IDLE_TIME", "90s")
+		defer resetPoolEnv()
+
+		cfg, err := loadPoolConfig()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.MaxOpenConns != 50 || cfg.MaxIdleConns != 20 {
+			t.Errorf("Expected 50/20 connections, got %+v", cfg)
+		}
+		if cfg.ConnMaxLifetime != time.Hour || cfg.ConnMaxIdleTime != 90*time.Second {
+			t.Errorf("Expected 1h/90s durations, got %+v", cfg)
+		}
+	})
+
+	t.Run("Idle capped at open", func(t *testing.T) {
+		os.Setenv("DB_MAX_OPEN_CONNS", "5")
+		os.Setenv("DB_MAX_IDLE_CONNS", "10")
+		defer resetPoolEnv()
+
+		cfg, err := loadPoolConfig()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.MaxIdleConns != 5 {
+			t.Errorf("Expected idle connections capped at 5, got %d", cfg.MaxIdleConns)
+		}
+	})
+
+	t.Run("Zero means unlimited", func(t *testing.T) {
+		os.Setenv("DB_MAX_OPEN_CONNS", "0")
+		os.Setenv("DB_CONN_MAX_LIFETIME", "0")
+		os.Setenv("DB_CONN_MAX_IDLE_TIME", "0s")
+		defer resetPoolEnv()
+
+		// pgxpool closes connections older than a zero lifetime, so 0 must not reach it as is
+		config, err := parsePoolConfig("postgres://transfers@localhost/transfers")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.MaxConns != math.MaxInt32 {
+			t.Errorf("Expected unlimited connections, got %d", config.MaxConns)
+		}
+		if config.MaxConnLifetime != math.MaxInt64 || config.MaxConnIdleTime != math.MaxInt64 {
+			t.Errorf("Expected unlimited durations, got %v/%v", config.MaxConnLifetime, config.MaxConnIdleTime)
+		}
+
+		resetPoolEnv()
+		config, err = parsePoolConfig("postgres://transfers@localhost/transfers")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.MaxConns != defaultMaxOpenConns || config.MaxConnLifetime != defaultConnMaxLifetime ||
+			config.MaxConnIdleTime != defaultConnMaxIdleTime {
+			t.Errorf("Expected the defaults, got %d %v/%v", config.MaxConns, config.MaxConnLifetime, config.MaxConnIdleTime)
+		}
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		invalid := map[string]string{
+			"DB_MAX_OPEN_CONNS":     "many",
+			"DB_MAX_IDLE_CONNS":     "-1",
+			"DB_CONN_MAX_LIFETIME":  "forever",
+			"DB_CONN_MAX_IDLE_TIME": "-5m",
+		}
+		for key, value := range invalid {
+			os.Setenv(key, value)
+			if _, err := loadPoolConfig(); err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("Expected error mentioning %s for value %q, got %v", key, value, err)
+			}
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	if !isUniqueViolation(&pgconn.PgError{Code: "23505"}) {
+		t.Error("Expected SQLSTATE 23505 to be a unique violation")
+	}
+	if !isUniqueViolation(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "23505"})) {
+		t.Error("Expected wrapped SQLSTATE 23505 to be a unique violation")
+	}
+	if isUniqueViolation(&pgconn.PgError{Code: "23503"}) {
+		t.Error("Foreign key violation should not be a unique violation")
+	}
+	if isUniqueViolation(fmt.Errorf("account not found")) {
+		t.Error("Plain errors should not be unique violations")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for code, want := range map[string]bool{"40001": true, "40P01": true, "23505": false, "57014": false} {
+		if got := isRetryable(fmt.Errorf("failed to commit transaction: %w", &pgconn.PgError{Code: code})); got != want {
+			t.Errorf("isRetryable(SQLSTATE %s) = %v, want %v", code, got, want)
+		}
+	}
+	if isRetryable(fmt.Errorf("insufficient balance")) {
+		t.Error("Plain errors should not be retryable")
+	}
+	if !isRetryable(fmt.Errorf("source account 1: %w", errBalanceConflict)) {
+		t.Error("Expected an optimistic balance conflict to be retryable")
+	}
+}
+
+func TestReplicaUnavailable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"no error":             {nil, false},
+		"no rows":              {sql.ErrNoRows, false},
+		"canceled":             {fmt.Errorf("query: %w", context.Canceled), false},
+		"connection failure":   {fmt.Errorf("query: %w", &pgconn.PgError{Code: "08006"}), true},
+		"recovery in progress": {&pgconn.PgError{Code: "57P03"}, true},
+		"unique violation":     {&pgconn.PgError{Code: "23505"}, false},
+		"dial error":           {fmt.Errorf("dial tcp: connection refused"), true},
+	} {
+		if got := replicaUnavailable(tc.err); got != tc.want {
+			t.Errorf("%s: replicaUnavailable = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestReplica_Read(t *testing.T) {
+	primary := &sql.DB{}
+	replica := &Replica{db: &sql.DB{}}
+	var used []*sql.DB
+	query := func(err error) func(db *sql.DB) error {
+		return func(db *sql.DB) error {
+			used = append(used, db)
+			if db == replica.db {
+				return err
+			}
+			return nil
+		}
+	}
+
+	if err := (*Replica)(nil).read(primary, query(nil)); err != nil || len(used) != 1 || used[0] != primary {
+		t.Fatalf("Expected a nil replica to read from the primary, got %v", err)
+	}
+	used = nil
+	if err := replica.read(primary, query(sql.ErrNoRows)); err != sql.ErrNoRows || len(used) != 1 {
+		t.Fatalf("Expected the replica's answer without falling back, got %v after %d queries", err, len(used))
+	}
+	used = nil
+	if err := replica.read(primary, query(&pgconn.PgError{Code: "08006"})); err != nil || len(used) != 2 || used[1] != primary {
+		t.Fatalf("Expected a fallback to the primary, got %v after %d queries", err, len(used))
+	}
+	// The failed replica is skipped until it is retried
+	used = nil
+	if err := replica.read(primary, query(nil)); err != nil || len(used) != 1 || used[0] != primary {
+		t.Fatal("Expected reads to stay on the primary after a failure")
+	}
+	replica.downUntil.Store(0)
+	used = nil
+	if replica.read(primary, query(nil)); used[0] != replica.db {
+		t.Error("Expected the replica to be tried again once its retry interval passed")
+	}
+}
+
+func TestIsFailover(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"no error":          {nil, false},
+		"no rows":           {sql.ErrNoRows, false},
+		"deadline":          {fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+		"read-only":         {fmt.Errorf("failed to update source account: %w", &pgconn.PgError{Code: "25006"}), true},
+		"connection":        {&pgconn.PgError{Code: "08006"}, true},
+		"admin shutdown":    {&pgconn.PgError{Code: "57P01"}, true},
+		"query canceled":    {&pgconn.PgError{Code: "57014"}, false},
+		"unique violation":  {&pgconn.PgError{Code: "23505"}, false},
+		"bad connection":    {fmt.Errorf("failed to begin transaction: %w", driver.ErrBadConn), true},
+		"connection closed": {io.ErrUnexpectedEOF, true},
+		"business error":    {fmt.Errorf("insufficient balance"), false},
+	} {
+		if got := isFailover(tc.err); got != tc.want {
+			t.Errorf("%s: isFailover = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	readOnly := fmt.Errorf("failed to update source account: %w", &pgconn.PgError{Code: "25006"})
+	var none *Failover
+	if err := none.check(readOnly); err != readOnly {
+		t.Errorf("Expected no failover recovery to pass errors through, got %v", err)
+	}
+
+	// The pool connects lazily, so no server is needed
+	config, err := pgxpool.ParseConfig("host=localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := OpenDB(pool)
+	defer func() { db.Close(); pool.Close() }()
+	failover := NewFailover(pool, db)
+
+	if err := failover.check(readOnly); !isRetryable(err) {
+		t.Errorf("Expected a write refused by a demoted primary to be retried, got %v", err)
+	}
+	reset := failover.lastReset.Load()
+	if reset == 0 {
+		t.Fatal("Expected the pool to be reset")
+	}
+	if failover.check(&pgconn.PgError{Code: "08006"}); failover.lastReset.Load() != reset {
+		t.Error("Expected errors in flight right after a reset not to reset the pool again")
+	}
+	commit := fmt.Errorf("%w: %w", errCommitFailed, io.ErrUnexpectedEOF)
+	if err := failover.check(commit); isRetryable(err) {
+		t.Error("Expected a commit that may have happened not to be retried")
+	}
+	if err := failover.check(errors.New("insufficient balance")); isRetryable(err) {
+		t.Error("Expected business errors not to be retried")
+	}
+
+	attempts := 0
+	err = failover.read(func() error {
+		if attempts++; attempts == 1 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("Expected the read to succeed on its second attempt, got %v after %d", err, attempts)
+	}
+}
+
+func TestLoadCredentialConfig(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	os.WriteFile(passwordFile, []byte("s3cret\n"), 0o600)
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_PASSWORD", "from-env")
+	t.Setenv("DB_USER_FILE", "")
+	t.Setenv("DB_PASSWORD_FILE", passwordFile)
+	t.Setenv("DB_CREDENTIALS_CHECK_INTERVAL", "")
+
+	config, err := LoadCredentialConfig()
+	if err != nil || config.CheckInterval != DefaultCredentialCheckInterval {
+		t.Fatalf("Expected the default interval, got %+v, %v", config, err)
+	}
+	// The file wins over DB_PASSWORD, without its trailing newline, and is re-read every time
+	credentials, err := config.Credentials()
+	if err != nil || credentials != (Credentials{User: "app", Password: "s3cret"}) {
+		t.Errorf("Expected app/s3cret, got %+v, %v", credentials, err)
+	}
+	os.WriteFile(passwordFile, []byte("rotated"), 0o600)
+	if credentials, _ := config.Credentials(); credentials.Password != "rotated" {
+		t.Errorf("Expected the rotated password, got %q", credentials.Password)
+	}
+
+	// New connections use the verified credentials, not whatever the file holds now
+	verified := newVerifiedCredentials(credentials)
+	connConfig := &pgx.ConnConfig{}
+	if err := verified.beforeConnect(context.Background(), connConfig); err != nil || connConfig.User != "app" || connConfig.Password != "s3cret" {
+		t.Errorf("Expected app/s3cret, got %q/%q, %v", connConfig.User, connConfig.Password, err)
+	}
+
+	for name, setup := range map[string]func(){
+		"empty file":       func() { os.WriteFile(passwordFile, []byte("\n"), 0o600) },
+		"missing file":     func() { t.Setenv("DB_USER_FILE", filepath.Join(dir, "missing")) },
+		"invalid interval": func() { t.Setenv("DB_CREDENTIALS_CHECK_INTERVAL", "often") },
+	} {
+		os.WriteFile(passwordFile, []byte("s3cret"), 0o600)
+		t.Setenv("DB_USER_FILE", "")
+		t.Setenv("DB_CREDENTIALS_CHECK_INTERVAL", "")
+		setup()
+		if _, err := LoadCredentialConfig(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCheckCredentials_Refused(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	os.WriteFile(passwordFile, []byte("old"), 0o600)
+	// Nothing listens on port 1, so the new credentials cannot be tried and must not be applied
+	config, err := pgxpool.ParseConfig("host=127.0.0.1 port=1 connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := OpenDB(pool)
+	defer func() { db.Close(); pool.Close() }()
+	current := Credentials{User: "postgres", Password: "old"}
+	storage := &PostgresStorage{pool: pool, db: db, failover: NewFailover(pool, db),
+		credentials: CredentialConfig{PasswordFile: passwordFile, CheckInterval: 10 * time.Millisecond},
+		verified:    newVerifiedCredentials(current)}
+	connConfig := &pgx.ConnConfig{}
+
+	count := func(name string) int64 {
+		if v, ok := credentialMetrics.Get(name).(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+	refused, rotations := count("refused"), count("rotations")
+	storage.checkCredentials(context.Background())
+	if got := storage.verified.Load(); got != current || count("refused") != refused {
+		t.Errorf("Expected unchanged credentials to be kept without a check, got %+v", got)
+	}
+	os.WriteFile(passwordFile, []byte("new"), 0o600)
+	storage.checkCredentials(context.Background())
+	if got := storage.verified.Load(); got != current {
+		t.Errorf("Expected the refused credentials not to be applied, got %+v", got)
+	}
+	// Connections opened meanwhile keep authenticating with the verified credentials
+	if storage.verified.beforeConnect(context.Background(), connConfig); connConfig.Password != "old" {
+		t.Errorf("Expected new connections to keep the old password, got %q", connConfig.Password)
+	}
+	if count("refused") != refused+1 || count("rotations") != rotations {
+		t.Errorf("Expected one refusal and no rotation, got %d and %d", count("refused")-refused, count("rotations")-rotations)
+	}
+
+	// With checking disabled the watcher returns at once
+	storage.credentials.CheckInterval = 0
+	storage.WatchCredentials(context.Background())
+}
+
+func TestLoadLockingMode(t *testing.T) {
+	for value, want := range map[string]string{"": LockingPessimistic, "pessimistic": LockingPessimistic, "optimistic": LockingOptimistic} {
+		t.Setenv("DB_LOCKING", value)
+		if mode, err := LoadLockingMode(); err != nil || mode != want {
+			t.Errorf("DB_LOCKING=%q: expected %s, got %q, %v", value, want, mode, err)
+		}
+	}
+	t.Setenv("DB_LOCKING", "none")
+	if _, err := LoadLockingMode(); err == nil || !strings.Contains(err.Error(), "DB_LOCKING") {
+		t.Errorf("Expected an error mentioning DB_LOCKING, got %v", err)
+	}
+}
+
+func TestLoadRetryConfig(t *testing.T) {
+	keys := []string{"DB_TX_MAX_RETRIES", "DB_TX_RETRY_DELAY"}
+	reset := func() {
+		for _, k := range keys {
+			os.Unsetenv(k)
+		}
+	}
+	reset()
+	defer reset()
+
+	if cfg, err := LoadRetryConfig(); err != nil || cfg != DefaultRetryConfig() {
+		t.Errorf("Expected defaults, got %+v, %v", cfg, err)
+	}
+
+	os.Setenv("DB_TX_MAX_RETRIES", "5")
+	os.Setenv("DB_TX_RETRY_DELAY", "50ms")
+	if cfg, err := LoadRetryConfig(); err != nil || cfg.MaxRetries != 5 || cfg.BaseDelay != 50*time.Millisecond {
+		t.Errorf("Expected 5 retries from 50ms, got %+v, %v", cfg, err)
+	}
+	reset()
+
+	for key, value := range map[string]string{"DB_TX_MAX_RETRIES": "-1", "DB_TX_RETRY_DELAY": "soon"} {
+		os.Setenv(key, value)
+		if _, err := LoadRetryConfig(); err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected error mentioning %s for value %q, got %v", key, value, err)
+		}
+		os.Unsetenv(key)
+	}
+}
+
+func TestRetryTx(t *testing.T) {
+	config := RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond}
+	conflict := fmt.Errorf("failed to update source account: %w", &pgconn.PgError{Code: sqlStateDeadlockDetected})
+
+	t.Run("Succeeds after conflicts", func(t *testing.T) {
+		attempts := 0
+		err := retryTx(config, func() error {
+			if attempts++; attempts < 3 {
+				return conflict
+			}
+			return nil
+		})
+		if err != nil || attempts != 3 {
+			t.Errorf("Expected success on the third attempt, got %v after %d", err, attempts)
+		}
+	})
+
+	t.Run("Retries exhausted", func(t *testing.T) {
+		attempts := 0
+		err := retryTx(config, func() error {
+			attempts++
+			return conflict
+		})
+		if err == nil || err.Error() != "transaction conflict" || attempts != 3 {
+			t.Errorf("Expected a transaction conflict after 3 attempts, got %v after %d", err, attempts)
+		}
+	})
+
+	t.Run("Other errors are not retried", func(t *testing.T) {
+		attempts := 0
+		err := retryTx(config, func() error {
+			attempts++
+			return fmt.Errorf("insufficient balance")
+		})
+		if err == nil || err.Error() != "insufficient balance" || attempts != 1 {
+			t.Errorf("Expected insufficient balance after 1 attempt, got %v after %d", err, attempts)
+		}
+	})
+
+	t.Run("Backoff stays within its ceiling", func(t *testing.T) {
+		config := RetryConfig{BaseDelay: 10 * time.Millisecond}
+		for retry := 1; retry <= 4; retry++ {
+			ceiling := config.BaseDelay << (retry - 1)
+			for range 20 {
+				if delay := config.backoff(retry); delay < 0 || delay > ceiling {
+					t.Fatalf("backoff(%d) = %v, want within [0, %v]", retry, delay, ceiling)
+				}
+			}
+		}
+		if delay := (RetryConfig{}).backoff(1); delay != 0 {
+			t.Errorf("Expected no delay without a base delay, got %v", delay)
+		}
+	})
+}
+
 func TestInitDB_ConfigurationOptions(t *testing.T) {
 	// Test various database configuration combinations
 	testCases := []struct {
@@ -218,43 +686,160 @@ func TestInitDB_ConnectionStringGeneration(t *testing.T) {
 // Migration Tests
 // =============================================================================
 
+// upSQL returns the forward SQL of an embedded migration version
+func upSQL(version int) string {
+	migrations, err := LoadMigrations()
+	if err != nil || version < 1 || version > len(migrations) {
+		return ""
+	}
+	return migrations[version-1].Up
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	if len(migrations) < 4 {
+		t.Fatalf("Expected at least 4 migrations, got %d", len(migrations))
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Migration %d has version %d", i, m.Version)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("Migration %d_%s is missing up or down SQL", m.Version, m.Name)
+		}
+	}
+}
+
+func TestExpectedIndexes(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	indexes := make(map[string]SchemaIndex)
+	for _, index := range ExpectedIndexes(migrations) {
+		indexes[index.Name] = index
+	}
+	if index := indexes["idx_transactions_source_sequence"]; !index.Unique || index.Table != "transactions" ||
+		!strings.HasPrefix(index.Definition, "CREATE UNIQUE INDEX") || !strings.HasSuffix(index.Definition, ";") {
+		t.Errorf("Unexpected sequence index %+v", index)
+	}
+	if index, ok := indexes["idx_holds_source_account"]; !ok || index.Unique || index.Table != "holds" {
+		t.Errorf("Unexpected holds index %+v", index)
+	}
+
+	// An index dropped by a later migration is no longer expected
+	dropped := ExpectedIndexes([]Migration{
+		{Version: 1, Up: "CREATE INDEX IF NOT EXISTS idx_a ON a(x);\nCREATE INDEX idx_b\n    ON b(y);"},
+		{Version: 2, Up: "DROP INDEX IF EXISTS idx_a;"},
+	})
+	if len(dropped) != 1 || dropped[0].Name != "idx_b" || dropped[0].Table != "b" {
+		t.Errorf("Expected only idx_b, got %+v", dropped)
+	}
+
+	for _, c := range ExpectedConstraints {
+		if c.Version < 1 || c.Version > len(migrations) {
+			t.Errorf("Constraint %s names unknown migration %d", c, c.Version)
+		}
+		if !sort.StringsAreSorted(c.Columns) {
+			t.Errorf("Constraint %s must list its columns sorted", c)
+		}
+	}
+}
+
+func TestLoadMigrations_Validation(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"Missing down file", fstest.MapFS{
+			"m/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{"Version gap", fstest.MapFS{
+			"m/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"m/0001_a.down.sql": {Data: []byte("SELECT 1;")},
+			"m/0003_c.up.sql":   {Data: []byte("SELECT 1;")},
+			"m/0003_c.down.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{"Conflicting names", fstest.MapFS{
+			"m/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"m/0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{"Malformed file name", fstest.MapFS{
+			"m/create_accounts.sql": {Data: []byte("SELECT 1;")},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := loadMigrations(tc.files, "m"); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+
+	t.Run("Valid set is sorted", func(t *testing.T) {
+		files := fstest.MapFS{
+			"m/0002_b.up.sql":   {Data: []byte("B UP")},
+			"m/0002_b.down.sql": {Data: []byte("B DOWN")},
+			"m/0001_a.up.sql":   {Data: []byte("A UP")},
+			"m/0001_a.down.sql": {Data: []byte("A DOWN")},
+		}
+		migrations, err := loadMigrations(files, "m")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(migrations) != 2 || migrations[0].Name != "a" || migrations[1].Down != "B DOWN" {
+			t.Errorf("Unexpected migrations: %+v", migrations)
+		}
+	})
+}
+
+func TestMigrateDown_InvalidSteps(t *testing.T) {
+	if err := MigrateDown(nil, 0); err == nil {
+		t.Error("Expected error for zero rollback steps")
+	}
+}
+
 func TestMigrate_SQLStructure(t *testing.T) {
 	// Test that migration SQL contains expected table structures
 	t.Run("Accounts table structure", func(t *testing.T) {
-		if !strings.Contains(createAccountsTable, "CREATE TABLE") {
+		if !strings.Contains(upSQL(1), "CREATE TABLE") {
 			t.Error("Accounts table SQL should contain CREATE TABLE")
 		}
-		if !strings.Contains(createAccountsTable, "account_id") {
+		if !strings.Contains(upSQL(1), "account_id") {
 			t.Error("Accounts table should have account_id column")
 		}
-		if !strings.Contains(createAccountsTable, "balance") {
+		if !strings.Contains(upSQL(1), "balance") {
 			t.Error("Accounts table should have balance column")
 		}
-		if !strings.Contains(createAccountsTable, "DECIMAL") {
+		if !strings.Contains(upSQL(1), "DECIMAL") {
 			t.Error("Accounts table should use DECIMAL for balance")
 		}
 	})
 
 	t.Run("Transactions table structure", func(t *testing.T) {
-		if !strings.Contains(createTransactionsTable, "CREATE TABLE") {
+		if !strings.Contains(upSQL(2), "CREATE TABLE") {
 			t.Error("Transactions table SQL should contain CREATE TABLE")
 		}
-		if !strings.Contains(createTransactionsTable, "source_account_id") {
+		if !strings.Contains(upSQL(2), "source_account_id") {
 			t.Error("Transactions table should have source_account_id column")
 		}
-		if !strings.Contains(createTransactionsTable, "destination_account_id") {
+		if !strings.Contains(upSQL(2), "destination_account_id") {
 			t.Error("Transactions table should have destination_account_id column")
 		}
-		if !strings.Contains(createTransactionsTable, "amount") {
+		if !strings.Contains(upSQL(2), "amount") {
 			t.Error("Transactions table should have amount column")
 		}
 	})
 
 	t.Run("Index creation", func(t *testing.T) {
-		if !strings.Contains(createIndexes, "CREATE INDEX") {
+		if !strings.Contains(upSQL(3), "CREATE INDEX") {
 			t.Error("Index SQL should contain CREATE INDEX")
 		}
-		if !strings.Contains(createIndexes, "account_id") {
+		if !strings.Contains(upSQL(3), "account_id") {
 			t.Error("Index should be created on account_id")
 		}
 	})
@@ -266,9 +851,9 @@ func TestMigrate_SQLValidation(t *testing.T) {
 		name string
 		sql  string
 	}{
-		{"Accounts table", createAccountsTable},
-		{"Transactions table", createTransactionsTable},
-		{"Indexes", createIndexes},
+		{"Accounts table", upSQL(1)},
+		{"Transactions table", upSQL(2)},
+		{"Indexes", upSQL(3)},
 	}
 
 	for _, stmt := range sqlStatements {
@@ -327,6 +912,12 @@ func TestNewTransactionRepository(t *testing.T) {
 	if repo.db != nil {
 		t.Error("Repository db should be nil when passed nil")
 	}
+	if repo.locking != LockingPessimistic {
+		t.Errorf("Expected pessimistic locking by default, got %q", repo.locking)
+	}
+	if repo.WithLocking(LockingOptimistic).locking != LockingOptimistic {
+		t.Error("Expected WithLocking to select optimistic locking")
+	}
 }
 
 func TestRepositoryInterfaces(t *testing.T) {
@@ -355,7 +946,7 @@ func TestAccountRepository_Methods(t *testing.T) {
 				t.Log("CreateAccount correctly panics with nil database")
 			}
 		}()
-		err := repo.CreateAccount(123, decimal.NewFromFloat(100.0))
+		err := repo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(100.0)})
 		if err == nil {
 			t.Error("Expected error with nil database")
 		}
@@ -395,7 +986,7 @@ func TestTransactionRepository_Methods(t *testing.T) {
 				t.Log("CreateTransaction correctly panics with nil database")
 			}
 		}()
-		err := repo.CreateTransaction(123, 456, decimal.NewFromFloat(100.0))
+		_, err := repo.CreateTransaction(models.Transfer{SourceAccountID: 123, DestinationAccountID: 456, Amount: decimal.NewFromFloat(100.0)})
 		if err == nil {
 			t.Error("Expected error with nil database")
 		}
@@ -429,7 +1020,7 @@ func TestAccountRepository_ParameterValidation(t *testing.T) {
 					t.Logf("Method correctly handles parameter: %v", tc.name)
 				}
 			}()
-			err := repo.CreateAccount(tc.accountID, tc.balance)
+			err := repo.CreateAccount(models.NewAccount{AccountID: tc.accountID, InitialBalance: tc.balance})
 			// We expect all of these to fail due to nil database
 			if err == nil {
 				t.Error("Expected error with nil database")
@@ -462,7 +1053,7 @@ func TestTransactionRepository_ParameterValidation(t *testing.T) {
 					t.Logf("Method correctly handles parameter: %v", tc.name)
 				}
 			}()
-			err := repo.CreateTransaction(tc.sourceID, tc.destID, tc.amount)
+			_, err := repo.CreateTransaction(models.Transfer{SourceAccountID: tc.sourceID, DestinationAccountID: tc.destID, Amount: tc.amount})
 			// We expect all of these to fail due to nil database
 			if err == nil {
 				t.Error("Expected error with nil database")
@@ -484,14 +1075,14 @@ func TestDatabase_PackageLevel(t *testing.T) {
 
 	t.Run("Constants and variables", func(t *testing.T) {
 		// Test that SQL constants are defined
-		if createAccountsTable == "" {
-			t.Error("createAccountsTable should not be empty")
+		if upSQL(1) == "" {
+			t.Error("accounts migration should not be empty")
 		}
-		if createTransactionsTable == "" {
-			t.Error("createTransactionsTable should not be empty")
+		if upSQL(2) == "" {
+			t.Error("transactions migration should not be empty")
 		}
-		if createIndexes == "" {
-			t.Error("createIndexes should not be empty")
+		if upSQL(3) == "" {
+			t.Error("indexes migration should not be empty")
 		}
 	})
 }
@@ -535,9 +1126,9 @@ func TestDatabase_ComprehensiveCoverage(t *testing.T) {
 	// Additional tests to improve coverage
 	t.Run("SQL constant validation", func(t *testing.T) {
 		sqlConstants := []string{
-			createAccountsTable,
-			createTransactionsTable,
-			createIndexes,
+			upSQL(1),
+			upSQL(2),
+			upSQL(3),
 		}
 
 		for i, sql := range sqlConstants {
@@ -643,7 +1234,7 @@ func TestMigrate_ExecutionFlow(t *testing.T) {
 	// Test migration execution logic
 	t.Run("Migration execution order", func(t *testing.T) {
 		// Verify the migration order is correct
-		migrations := []string{createAccountsTable, createTransactionsTable, createIndexes}
+		migrations := []string{upSQL(1), upSQL(2), upSQL(3)}
 
 		// Test that accounts come before transactions (foreign key dependency)
 		if !strings.Contains(migrations[0], "accounts") {
@@ -673,7 +1264,7 @@ func TestRepository_FullCoverage(t *testing.T) {
 		}()
 
 		// These will all panic but exercise the code paths
-		repo.CreateAccount(123, decimal.NewFromFloat(100.0))
+		repo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(100.0)})
 		repo.GetAccount(123)
 		repo.AccountExists(123)
 	})
@@ -689,7 +1280,7 @@ func TestRepository_FullCoverage(t *testing.T) {
 		}()
 
 		// This will panic but exercises the code path
-		repo.CreateTransaction(123, 456, decimal.NewFromFloat(100.0))
+		repo.CreateTransaction(models.Transfer{SourceAccountID: 123, DestinationAccountID: 456, Amount: decimal.NewFromFloat(100.0)})
 	})
 }
 
@@ -716,7 +1307,7 @@ func TestMigrate_AllExecutionPaths(t *testing.T) {
 	t.Run("Migrate execution sequence", func(t *testing.T) {
 		// Test that migration would execute all statements in sequence
 		// We can't test with real DB, but we can verify the SQL statements exist
-		sqlStatements := []string{createAccountsTable, createTransactionsTable, createIndexes}
+		sqlStatements := []string{upSQL(1), upSQL(2), upSQL(3)}
 
 		for i, sql := range sqlStatements {
 			if strings.TrimSpace(sql) == "" {
@@ -765,7 +1356,7 @@ func TestCreateTransaction_AllPaths(t *testing.T) {
 				}()
 
 				// This will panic due to nil database but covers different code paths
-				err := repo.CreateTransaction(tc.sourceID, tc.destID, tc.amount)
+				_, err := repo.CreateTransaction(models.Transfer{SourceAccountID: tc.sourceID, DestinationAccountID: tc.destID, Amount: tc.amount})
 				if err == nil {
 					t.Error("Expected error with nil database")
 				}
@@ -782,7 +1373,7 @@ func TestCreateTransaction_AllPaths(t *testing.T) {
 		}()
 
 		// Test transaction begin path
-		err := repo.CreateTransaction(123, 456, decimal.NewFromFloat(100.0))
+		_, err := repo.CreateTransaction(models.Transfer{SourceAccountID: 123, DestinationAccountID: 456, Amount: decimal.NewFromFloat(100.0)})
 		if err == nil {
 			t.Error("Expected error with nil database")
 		}
@@ -814,7 +1405,7 @@ func TestCreateAccount_AllPaths(t *testing.T) {
 					}
 				}()
 
-				err := repo.CreateAccount(tc.accountID, tc.balance)
+				err := repo.CreateAccount(models.NewAccount{AccountID: tc.accountID, InitialBalance: tc.balance})
 				if err == nil {
 					t.Error("Expected error with nil database")
 				}
@@ -949,10 +1540,15 @@ func TestDatabase_ErrorHandlingPaths(t *testing.T) {
 
 		// Test error paths for account repository
 		testFuncs := []func() error{
-			func() error { return accountRepo.CreateAccount(1, decimal.NewFromFloat(100)) },
+			func() error {
+				return accountRepo.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromFloat(100)})
+			},
 			func() error { _, err := accountRepo.GetAccount(1); return err },
 			func() error { _, err := accountRepo.AccountExists(1); return err },
-			func() error { return transactionRepo.CreateTransaction(1, 2, decimal.NewFromFloat(50)) },
+			func() error {
+				_, err := transactionRepo.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromFloat(50)})
+				return err
+			},
 		}
 
 		for i, testFunc := range testFuncs {
@@ -1,49 +1,237 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
+// Postgres SQLSTATE codes the repositories react to
+const (
+	sqlStateUniqueViolation     = "23505"
+	sqlStateCheckViolation      = "23514"
+	sqlStateForeignKeyViolation = "23503"
+
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// connectTimeout bounds how long InitPool waits for the initial connection
+const connectTimeout = 10 * time.Second
+
+// Connection pool defaults applied when the corresponding environment variables are unset
+// These keep a single instance well below the default Postgres max_connections (100)
+// so several replicas can share one database without exhausting connection slots
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// PoolConfig holds the connection pool limits applied to the database handle
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
 // InitDB initializes and returns a PostgreSQL database connection
 // This function sets up the database connection using environment variables with sensible defaults
 // Environment variables used (with defaults):
-//   - DB_HOST (localhost): Database server hostname
+//   - DB_HOST (localhost): Database server hostname, or a comma-separated list of the servers
+//     that may be the primary
 //   - DB_PORT (5432): Database server port
 //   - DB_USER (postgres): Database username
 //   - DB_PASSWORD (postgres): Database password
+//   - DB_USER_FILE / DB_PASSWORD_FILE (unset): Files holding the username and password instead,
+//     re-read at every check so rotated credentials apply (see LoadCredentialConfig)
 //   - DB_NAME (transfers): Database name
 //   - DB_SSLMODE (disable): SSL mode for connection
+//   - DB_TARGET_SESSION_ATTRS (read-write): Which server of DB_HOST to connect to; the default
+//     only accepts a writable primary, skipping standbys and a demoted former primary
+//   - DB_MAX_OPEN_CONNS (25): Maximum number of open connections; 0 means unlimited
+//   - DB_MAX_IDLE_CONNS (10): Maximum number of idle connections kept in the pool
+//   - DB_CONN_MAX_LIFETIME (30m): Maximum time a connection may be reused; 0 means unlimited
+//   - DB_CONN_MAX_IDLE_TIME (5m): Maximum time a connection may sit idle; 0 means unlimited
 //
 // Returns:
 //   - *sql.DB: Active database connection if successful
 //   - error: Connection error if database is unreachable or credentials invalid
 //
-// Note: This function also performs a ping test to verify the connection is working
+// Note: The returned handle is backed by a pgx connection pool (see InitPool); callers
+// that need pool statistics should use InitPool and OpenDB directly
 func InitDB() (*sql.DB, error) {
+	pool, err := InitPool()
+	if err != nil {
+		return nil, err
+	}
+	return OpenDB(pool), nil
+}
+
+// InitPool initializes and returns a pgx connection pool for PostgreSQL
+// Uses the same environment variables as InitDB; pool limits are mapped onto the pgxpool
+// configuration (DB_MAX_OPEN_CONNS becomes MaxConns, the lifetimes MaxConnLifetime and
+// MaxConnIdleTime), keeping database/sql's meaning of 0 as unlimited: pgxpool would instead close
+// every connection on release with a zero lifetime, so 0 becomes the largest value each allows
+// Returns:
+//   - *pgxpool.Pool: Connected pool if successful
+//   - error: Configuration or connection error
+//
+// Note: This function also performs a ping test to verify the connection is working
+func InitPool() (*pgxpool.Pool, error) {
+	config, err := LoadCredentialConfig()
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := config.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	return initPool(newVerifiedCredentials(credentials))
+}
+
+// initPool connects a pool whose connections authenticate with the verified credentials; the
+// initial ones are verified by the pool's ping
+func initPool(credentials *verifiedCredentials) (*pgxpool.Pool, error) {
+	config, err := parsePoolConfig(connectionString())
+	if err != nil {
+		return nil, err
+	}
+	config.BeforeConnect = credentials.beforeConnect
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return pool, nil
+}
+
+// parsePoolConfig parses a connection string and applies the DB_* pool limits to it
+func parsePoolConfig(connString string) (*pgxpool.Config, error) {
+	poolConfig, err := loadPoolConfig()
+	if err != nil {
+		return nil, err
+	}
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database configuration: %w", err)
+	}
+	config.MaxConns = math.MaxInt32
+	if poolConfig.MaxOpenConns > 0 && poolConfig.MaxOpenConns < math.MaxInt32 {
+		config.MaxConns = int32(poolConfig.MaxOpenConns)
+	}
+	config.MaxConnLifetime = unlimitedIfZero(poolConfig.ConnMaxLifetime)
+	config.MaxConnIdleTime = unlimitedIfZero(poolConfig.ConnMaxIdleTime)
+	return config, nil
+}
+
+// unlimitedIfZero returns d, or the longest duration for 0, which database/sql treats as
+// unlimited but pgxpool as already expired
+func unlimitedIfZero(d time.Duration) time.Duration {
+	if d == 0 {
+		return math.MaxInt64
+	}
+	return d
+}
+
+// OpenDB wraps a pgx pool in a *sql.DB so the repositories can keep using database/sql
+// Connections are borrowed from the pgx pool, which remains the authority on connection limits;
+// DB_MAX_IDLE_CONNS bounds how many of them database/sql holds on to between queries
+func OpenDB(pool *pgxpool.Pool) *sql.DB {
+	db := stdlib.OpenDBFromPool(pool)
+	if poolConfig, err := loadPoolConfig(); err == nil {
+		db.SetMaxIdleConns(poolConfig.MaxIdleConns)
+	}
+	return db
+}
+
+// PoolStats is a point-in-time snapshot of connection pool usage
+type PoolStats struct {
+	MaxConns             int32  `json:"max_conns"`
+	TotalConns           int32  `json:"total_conns"`
+	AcquiredConns        int32  `json:"acquired_conns"`
+	IdleConns            int32  `json:"idle_conns"`
+	ConstructingConns    int32  `json:"constructing_conns"`
+	AcquireCount         int64  `json:"acquire_count"`
+	EmptyAcquireCount    int64  `json:"empty_acquire_count"`
+	CanceledAcquireCount int64  `json:"canceled_acquire_count"`
+	AcquireDuration      string `json:"acquire_duration"`
+}
+
+// StatsFromPool captures the current statistics of a pgx pool
+// EmptyAcquireCount (acquires that had to wait for a connection) is the key signal
+// that DB_MAX_OPEN_CONNS is too low for the current load
+func StatsFromPool(pool *pgxpool.Pool) PoolStats {
+	stat := pool.Stat()
+	return PoolStats{
+		MaxConns:             stat.MaxConns(),
+		TotalConns:           stat.TotalConns(),
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		ConstructingConns:    stat.ConstructingConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDuration:      stat.AcquireDuration().String(),
+	}
+}
+
+// connectionString builds the keyword/value connection string from the DB_* environment variables
+func connectionString() string {
 	host := getEnvWithDefault("DB_HOST", "localhost")
 	port := getEnvWithDefault("DB_PORT", "5432")
 	user := getEnvWithDefault("DB_USER", "postgres")
 	password := getEnvWithDefault("DB_PASSWORD", "postgres")
 	dbname := getEnvWithDefault("DB_NAME", "transfers")
 	sslmode := getEnvWithDefault("DB_SSLMODE", "disable")
+	sessionAttrs := getEnvWithDefault("DB_TARGET_SESSION_ATTRS", "read-write")
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode)
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s target_session_attrs=%s",
+		host, port, user, password, dbname, sslmode, sessionAttrs)
+}
 
-	db, err := sql.Open("postgres", psqlInfo)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
+// isUniqueViolation reports whether err is a Postgres unique_violation (SQLSTATE 23505)
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == sqlStateUniqueViolation
+}
 
-	if err = db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
+// isUniqueViolationOf reports whether err is a Postgres unique_violation of the named constraint
+func isUniqueViolationOf(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == sqlStateUniqueViolation && pgErr.ConstraintName == constraint
+}
 
-	return db, nil
+// isCheckViolation reports whether err is a Postgres check_violation (SQLSTATE 23514)
+func isCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == sqlStateCheckViolation
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign_key_violation (SQLSTATE 23503)
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == sqlStateForeignKeyViolation
 }
 
 // getEnvWithDefault retrieves an environment variable value or returns a default value if not set
@@ -60,3 +248,68 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// loadPoolConfig reads the connection pool settings from the environment
+// Unset variables fall back to the package defaults; malformed values are reported
+// as errors rather than silently ignored so misconfiguration is caught at startup
+// Returns:
+//   - PoolConfig: Resolved pool limits
+//   - error: Parse error naming the offending environment variable
+func loadPoolConfig() (PoolConfig, error) {
+	maxOpen, err := getEnvInt("DB_MAX_OPEN_CONNS", defaultMaxOpenConns)
+	if err != nil {
+		return PoolConfig{}, err
+	}
+	maxIdle, err := getEnvInt("DB_MAX_IDLE_CONNS", defaultMaxIdleConns)
+	if err != nil {
+		return PoolConfig{}, err
+	}
+	lifetime, err := getEnvDuration("DB_CONN_MAX_LIFETIME", defaultConnMaxLifetime)
+	if err != nil {
+		return PoolConfig{}, err
+	}
+	idleTime, err := getEnvDuration("DB_CONN_MAX_IDLE_TIME", defaultConnMaxIdleTime)
+	if err != nil {
+		return PoolConfig{}, err
+	}
+
+	// More idle connections than open connections can never be used
+	if maxOpen > 0 && maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+
+	return PoolConfig{
+		MaxOpenConns:    maxOpen,
+		MaxIdleConns:    maxIdle,
+		ConnMaxLifetime: lifetime,
+		ConnMaxIdleTime: idleTime,
+	}, nil
+}
+
+// getEnvInt retrieves an integer environment variable or returns a default value if not set
+// Returns an error if the variable is set but is not a valid non-negative integer
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %q is not a non-negative integer", key, value)
+	}
+	return n, nil
+}
+
+// getEnvDuration retrieves a duration environment variable (e.g. "30m", "1h") or returns a default value if not set
+// Returns an error if the variable is set but cannot be parsed by time.ParseDuration
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %q is not a valid duration", key, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RequiredExtensions lists the PostgreSQL extensions the schema depends on
+// The schema currently uses only core PostgreSQL; a migration that adds CREATE EXTENSION must add
+// the extension here so the doctor command can flag servers where it is not available
+var RequiredExtensions []string
+
+// MissingExtensions returns the extensions in names that are neither installed in the database
+// nor available for installation on the server
+func MissingExtensions(ctx context.Context, db *sql.DB, names []string) ([]string, error) {
+	var missing []string
+	for _, name := range names {
+		var available bool
+		err := db.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = $1)
+			    OR EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = $1)`, name).Scan(&available)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check extension %s: %w", name, err)
+		}
+		if !available {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// ServerInfo describes the connected PostgreSQL server
+type ServerInfo struct {
+	Version string
+	Now     time.Time
+
+	// RoundTrip is how long the query took; ClockSkew is only accurate to about half of it
+	RoundTrip time.Duration
+
+	// ClockSkew is the server clock minus the local clock, measured at the round trip's midpoint
+	ClockSkew time.Duration
+}
+
+// GetServerInfo queries the server's version and clock
+func GetServerInfo(ctx context.Context, db *sql.DB) (ServerInfo, error) {
+	var info ServerInfo
+	sent := time.Now()
+	if err := db.QueryRowContext(ctx, "SELECT current_setting('server_version'), clock_timestamp()").Scan(&info.Version, &info.Now); err != nil {
+		return ServerInfo{}, fmt.Errorf("failed to query server: %w", err)
+	}
+	info.RoundTrip = time.Since(sent)
+	info.ClockSkew = info.Now.Sub(sent.Add(info.RoundTrip / 2))
+	return info, nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sqlStateReadOnlyTransaction is raised by a write on a server that is no longer the primary
+const sqlStateReadOnlyTransaction = "25006"
+
+// Failover recovery settings
+const (
+	// failoverResetInterval is how long after a pool reset further failover errors, usually from
+	// requests already in flight, do not reset it again
+	failoverResetInterval = time.Second
+
+	// failoverReadRetries and failoverReadDelay bound how long a read waits for the new primary
+	failoverReadRetries = 3
+	failoverReadDelay   = 250 * time.Millisecond
+)
+
+// errFailover marks an attempt that failed because the primary failed over and can safely run
+// again on the new primary; retryTx retries it like a serialization failure
+var errFailover = errors.New("database failover")
+
+// errCommitFailed marks an error of COMMIT itself: the transaction may have committed on the old
+// primary before the connection broke, so it is never run again
+var errCommitFailed = errors.New("failed to commit transaction")
+
+// failoverMetrics counts pool resets after a failover and the operations retried on the new primary
+var failoverMetrics = expvar.NewMap("db_failover")
+
+// Failover recovers the connection pool from a primary failover, e.g. of a managed Postgres
+// After a failover the pool holds connections that were reset, or that still reach the old
+// primary now demoted to a read-only standby. On the first error showing this (see isFailover)
+// every pooled connection is closed, so the next ones are dialed afresh: DB_HOST is resolved
+// again, and with target_session_attrs=read-write (DB_TARGET_SESSION_ATTRS) only a writable server
+// of the DB_HOST list is accepted. Idempotent operations are then retried (see check and read)
+// A nil *Failover only passes errors through
+type Failover struct {
+	pool *pgxpool.Pool
+	db   *sql.DB
+	idle int
+	// lastReset is when, in Unix nanoseconds, the pool was last reset
+	lastReset atomic.Int64
+}
+
+// NewFailover creates the failover recovery of a pool and the handle opened on it (see OpenDB)
+func NewFailover(pool *pgxpool.Pool, db *sql.DB) *Failover {
+	idle := defaultMaxIdleConns
+	if poolConfig, err := loadPoolConfig(); err == nil {
+		idle = poolConfig.MaxIdleConns
+	}
+	return &Failover{pool: pool, db: db, idle: idle}
+}
+
+// observe resets the pool if err shows the primary failed over, at most once per
+// failoverResetInterval, and reports whether it did show it
+func (f *Failover) observe(err error) bool {
+	if f == nil || !isFailover(err) {
+		return false
+	}
+	now, last := time.Now().UnixNano(), f.lastReset.Load()
+	if now-last < int64(failoverResetInterval) || !f.lastReset.CompareAndSwap(last, now) {
+		return true
+	}
+	slog.Warn("Database failover detected; reconnecting to the primary", "error", err)
+	f.reconnect()
+	failoverMetrics.Add("resets", 1)
+	return true
+}
+
+// reconnect closes every pooled connection, idle ones now and those in use once released, so the
+// next ones are dialed afresh
+func (f *Failover) reconnect() {
+	f.pool.Reset()
+	// Connections idle in database/sql are only returned to the pool, to be closed, when dropped
+	f.db.SetMaxIdleConns(0)
+	f.db.SetMaxIdleConns(f.idle)
+}
+
+// check observes the error of one attempt of a write transaction for retryTx, marking it
+// retryable if the primary failed over before the transaction could have committed
+func (f *Failover) check(err error) error {
+	if !f.observe(err) || (errors.Is(err, errCommitFailed) && !pgconn.SafeToRetry(err)) {
+		return err
+	}
+	failoverMetrics.Add("retries", 1)
+	return fmt.Errorf("%w: %w", errFailover, err)
+}
+
+// read runs an idempotent query, and again after a short delay while it fails because the primary
+// failed over
+func (f *Failover) read(query func() error) error {
+	err := query()
+	for retry := 1; retry <= failoverReadRetries && f.observe(err); retry++ {
+		failoverMetrics.Add("retries", 1)
+		time.Sleep(failoverReadDelay * time.Duration(retry))
+		err = query()
+	}
+	return err
+}
+
+// isFailover reports whether err shows the connection no longer reaches a working primary: a
+// write refused as read-only (the server was demoted), a connection exception (SQLSTATE class 08),
+// an administrator or crash shutdown or a server still starting (57P01-57P03), or a broken
+// connection. Answers such as no rows or constraint violations, and cancellations, are not
+func isFailover(err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == sqlStateReadOnlyTransaction || strings.HasPrefix(pgErr.Code, "08") ||
+			pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) || errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || pgconn.SafeToRetry(err)
+}
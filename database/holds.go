@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// HoldRepository implements HoldRepositoryInterface for PostgreSQL
+type HoldRepository struct {
+	db *sql.DB
+}
+
+// NewHoldRepository creates a new hold repository instance
+func NewHoldRepository(db *sql.DB) *HoldRepository {
+	return &HoldRepository{db: db}
+}
+
+// holdColumns is the select list read by scanHold
+const holdColumns = `id, source_account_id, destination_account_id, amount, transfer_type, tenant, status,
+		       expires_at, COALESCE(transaction_id, 0), created_at, updated_at`
+
+// scanHold reads one row selected with holdColumns
+func scanHold(row interface{ Scan(dest ...any) error }) (models.Hold, error) {
+	var h models.Hold
+	err := row.Scan(&h.ID, &h.SourceAccountID, &h.DestinationAccountID, &h.Amount, &h.TransferType, &h.Tenant, &h.Status,
+		&h.ExpiresAt, &h.TransactionID, &h.CreatedAt, &h.UpdatedAt)
+	return h, err
+}
+
+// CreateHold reserves a hold's amount on its source account
+// Parameters:
+//   - ctx: Context bounding the database transaction
+//   - hold: The hold to create; its ID, status and timestamps are assigned by the database
+//
+// Returns:
+//   - *models.Hold: The active hold
+//   - error: "source account not found", "source account frozen", "insufficient balance" (the
+//     available balance does not cover the amount), "destination account not found", or a
+//     database error
+//
+// Database behavior:
+//   - The source account row is locked while its available balance is checked and its held
+//     total raised, so concurrent holds and transfers cannot both spend the same funds
+func (r *HoldRepository) CreateHold(ctx context.Context, hold models.Hold) (*models.Hold, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var balance, overdraft, held decimal.Decimal
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT balance, overdraft_limit, held, status FROM accounts WHERE account_id = $1 FOR UPDATE",
+		hold.SourceAccountID).Scan(&balance, &overdraft, &held, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("source account not found")
+		}
+		return nil, fmt.Errorf("failed to get source account: %w", err)
+	}
+	if status == models.AccountFrozen {
+		return nil, fmt.Errorf("source account frozen")
+	}
+	if balance.Add(overdraft).Sub(held).LessThan(hold.Amount) {
+		return nil, fmt.Errorf("insufficient balance")
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM accounts WHERE account_id = $1)", hold.DestinationAccountID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get destination account: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("destination account not found")
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE accounts SET held = held + $1, updated_at = NOW() WHERE account_id = $2", hold.Amount, hold.SourceAccountID); err != nil {
+		return nil, fmt.Errorf("failed to reserve hold: %w", err)
+	}
+	stored, err := scanHold(tx.QueryRowContext(ctx, `
+		INSERT INTO holds (source_account_id, destination_account_id, amount, transfer_type, tenant, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, 'active', $6)
+		RETURNING `+holdColumns,
+		hold.SourceAccountID, hold.DestinationAccountID, hold.Amount, hold.TransferType, hold.Tenant, hold.ExpiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hold: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &stored, nil
+}
+
+// GetHold returns a hold by ID or a "hold not found" error
+func (r *HoldRepository) GetHold(ctx context.Context, id int64) (*models.Hold, error) {
+	hold, err := scanHold(r.db.QueryRowContext(ctx, `SELECT `+holdColumns+` FROM holds WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("hold not found")
+		}
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+	return &hold, nil
+}
+
+// ListHolds returns the holds on a source account newest first, below beforeID if set, capped at limit
+// Served by the (source_account_id, id) index
+func (r *HoldRepository) ListHolds(ctx context.Context, accountID, beforeID int64, limit int) ([]models.Hold, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+holdColumns+`
+		FROM holds
+		WHERE source_account_id = $1 AND ($2::bigint = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, accountID, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := []models.Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan hold: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list holds: %w", err)
+	}
+	return holds, nil
+}
+
+// ReleaseHold ends an active hold without a transfer and frees its amount
+// Returns the released hold, "hold not found", or "hold not active" if it was already captured,
+// released or expired
+func (r *HoldRepository) ReleaseHold(ctx context.Context, id int64) (*models.Hold, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM holds WHERE id = $1 FOR UPDATE`, id).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("hold not found")
+		}
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+	if status != models.HoldActive {
+		return nil, fmt.Errorf("hold not active")
+	}
+	hold, err := endHoldTx(ctx, tx, id, models.HoldReleased)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return hold, nil
+}
+
+// ExpireHolds expires the active holds whose expiry is at or before now, freeing their amounts
+// Returns the expired holds, at most limit of them
+// Database behavior:
+//   - Holds are locked with SKIP LOCKED, so a hold being captured or released concurrently is left
+//     to that caller and several instances can expire holds at once
+func (r *HoldRepository) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM holds
+		WHERE status = 'active' AND expires_at <= $1
+		ORDER BY expires_at, id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expired holds: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan hold: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find expired holds: %w", err)
+	}
+
+	expired := []models.Hold{}
+	for _, id := range ids {
+		hold, err := endHoldTx(ctx, tx, id, models.HoldExpired)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, *hold)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return expired, nil
+}
+
+// CountActiveHolds returns the number of holds still reserving funds
+func (r *HoldRepository) CountActiveHolds(ctx context.Context) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM holds WHERE status = 'active'`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count active holds: %w", err)
+	}
+	return count, nil
+}
+
+// endHoldTx sets a locked active hold's final status and frees its amount on the source account
+func endHoldTx(ctx context.Context, tx *sql.Tx, id int64, status string) (*models.Hold, error) {
+	hold, err := scanHold(tx.QueryRowContext(ctx, `
+		UPDATE holds SET status = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+holdColumns, id, status))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update hold: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE accounts SET held = held - $1, updated_at = NOW() WHERE account_id = $2", hold.Amount, hold.SourceAccountID); err != nil {
+		return nil, fmt.Errorf("failed to free hold: %w", err)
+	}
+	return &hold, nil
+}
+
+// captureHoldTx ends the hold a transfer captures, inside the transfer's database transaction
+// The whole hold is freed, so an amount not captured becomes available again
+// Returns "hold not found", "hold not active", "hold expired" (past its expiry but not yet
+// expired by ExpireHolds), "capture exceeds hold amount", or an error if the transfer's accounts
+// are not the hold's
+func captureHoldTx(tx *sql.Tx, transfer models.Transfer) error {
+	var source, destination int64
+	var amount decimal.Decimal
+	var status string
+	var expired bool
+	err := tx.QueryRow(`SELECT source_account_id, destination_account_id, amount, status, expires_at <= NOW() FROM holds WHERE id = $1 FOR UPDATE`,
+		transfer.HoldID).Scan(&source, &destination, &amount, &status, &expired)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("hold not found")
+		}
+		return fmt.Errorf("failed to get hold: %w", err)
+	}
+	switch {
+	case status != models.HoldActive:
+		return fmt.Errorf("hold not active")
+	case expired:
+		return fmt.Errorf("hold expired")
+	case source != transfer.SourceAccountID || destination != transfer.DestinationAccountID:
+		return fmt.Errorf("hold %d is not for this transfer's accounts", transfer.HoldID)
+	case transfer.Amount.GreaterThan(amount):
+		return fmt.Errorf("capture exceeds hold amount")
+	}
+	_, err = endHoldTx(context.Background(), tx, transfer.HoldID, models.HoldCaptured)
+	return err
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"context"
+	"time"
+
 	"github.com/shopspring/decimal"
 
 	"internal-transfers/models"
@@ -12,17 +15,47 @@ import (
 // Implementations must ensure data consistency and proper error handling
 // Used by HTTP handlers to interact with account data without direct database coupling
 type AccountRepositoryInterface interface {
-	// CreateAccount inserts a new account with the specified ID and initial balance
-	// Should fail if account ID already exists or if database constraints are violated
-	CreateAccount(accountID int64, initialBalance decimal.Decimal) error
+	// CreateAccount inserts a new account with the ID, initial balance, currency, external ID and
+	// creation token of account
+	// Should fail if account ID already exists or if database constraints are violated, with
+	// "external ID already exists" if another account has the external ID, and with "creation
+	// token already used" if another account was created with the token
+	CreateAccount(account models.NewAccount) error
 
 	// GetAccount retrieves account information by ID
 	// Returns account object with current balance or "account not found" error
 	GetAccount(accountID int64) (*models.Account, error)
 
+	// GetAccountByExternalID retrieves the account with the given external ID
+	// Returns "account not found" if no account has it
+	GetAccountByExternalID(externalID string) (*models.Account, error)
+
 	// AccountExists checks if an account with the given ID exists
 	// Returns boolean result and any database errors that occur during the check
 	AccountExists(accountID int64) (bool, error)
+
+	// SetAccountStatus changes the account's status (models.AccountActive or models.AccountFrozen),
+	// incrementing the version if the status changed
+	// Returns the updated account or "account not found" error
+	SetAccountStatus(accountID int64, status string) (*models.Account, error)
+
+	// SetOverdraftLimit changes how far below zero the account's balance may go (limit is
+	// non-negative), incrementing the version if the limit changed
+	// Returns the updated account, "account not found", or "balance below overdraft limit" if the
+	// account is already overdrawn by more than the new limit
+	SetOverdraftLimit(accountID int64, limit decimal.Decimal) (*models.Account, error)
+
+	// UpdateAccount merges metadata changes and replaces tags and the external ID (see
+	// models.AccountUpdate), and increments the account's version
+	// Returns the updated account, "account not found", "account version mismatch" when
+	// update.ExpectedVersion is set and differs from the current version, or "external ID already
+	// exists" if another account has the new external ID
+	UpdateAccount(accountID int64, update models.AccountUpdate) (*models.Account, error)
+
+	// ListAccounts returns up to limit accounts matching the filter, ordered by account ID, after
+	// skipping the first offset matches, together with the total number of matching accounts
+	// filter.AfterID starts the page after that account ID; it does not change the total
+	ListAccounts(filter models.AccountFilter, limit, offset int) (accounts []models.Account, total int, err error)
 }
 
 // TransactionRepositoryInterface defines the contract for transaction-related database operations
@@ -34,8 +67,193 @@ type TransactionRepositoryInterface interface {
 	// CreateTransaction performs an atomic money transfer between two accounts
 	// Must validate account existence, check sufficient balance, and update both accounts
 	// Should use database transactions to ensure atomicity and prevent race conditions
-	// Returns specific error messages for business rule violations (insufficient funds, etc.)
-	CreateTransaction(sourceAccountID, destinationAccountID int64, amount decimal.Decimal) error
+	// Returns specific error messages for business rule violations (insufficient funds, frozen
+	// accounts, etc.)
+	// The transfer's amount has already been rounded and its value date assigned by the caller;
+	// both are recorded on the transaction
+	// On success returns the committed transaction with its per-account sequence numbers
+	CreateTransaction(transfer models.Transfer) (*models.Transaction, error)
+
+	// ListTransactions returns the most recent transactions where the account is source or destination
+	// Results are ordered newest first and capped at limit
+	ListTransactions(accountID int64, limit int) ([]models.Transaction, error)
+
+	// SearchTransactions returns the account's transactions matching the filter (see
+	// models.TransactionFilter) in its order, capped at limit
+	SearchTransactions(filter models.TransactionFilter, limit int) ([]models.Transaction, error)
+
+	// SearchAllTransactions returns the transactions of any account matching the search (see
+	// models.TransactionSearch), newest first, capped at limit
+	SearchAllTransactions(search models.TransactionSearch, limit int) ([]models.Transaction, error)
+
+	// ListChanges returns the transactions that moved the account's ledger after sequence number
+	// sinceSeq, ordered by the account's sequence and capped at limit
+	ListChanges(accountID, sinceSeq int64, limit int) ([]models.Transaction, error)
+
+	// BalanceAsOf returns the account's balance counting only the movements recorded at or before
+	// recordedAt and effective at or before effectiveAt (see models.Transaction)
+	// Returns "account not found" if the account does not exist or was created after recordedAt
+	BalanceAsOf(accountID int64, recordedAt, effectiveAt time.Time) (decimal.Decimal, error)
+}
+
+// SettlementRepositoryInterface defines the storage used by partner settlement
+// Used by the settlement job to read a business day's transfers and record generation outcomes,
+// by acknowledgment ingestion to settle and return transfers, and by HTTP handlers to report status
+type SettlementRepositoryInterface interface {
+	// TransactionsByValueDate returns the transactions value-dated on date that involve any of the
+	// given accounts as source or destination, ordered by ID
+	TransactionsByValueDate(ctx context.Context, date time.Time, accountIDs []int64) ([]models.Transaction, error)
+
+	// SaveSettlementFile records a generation outcome, replacing any earlier record for the same
+	// partner and business date; returns the stored record
+	SaveSettlementFile(ctx context.Context, file models.SettlementFile) (*models.SettlementFile, error)
+
+	// ListSettlementFiles returns generation records newest business date first, then by partner,
+	// capped at limit. A zero date or empty partnerID matches all dates or partners; a non-zero
+	// after starts the page after that record
+	ListSettlementFiles(ctx context.Context, date time.Time, partnerID string, after models.SettlementFileKey, limit int) ([]models.SettlementFile, error)
+
+	// GetTransaction returns a transaction by ID or a "transaction not found" error
+	GetTransaction(ctx context.Context, transactionID int64) (*models.Transaction, error)
+
+	// SettleTransaction marks a transaction settled after a partner acknowledged it
+	// Idempotent for settled transactions; fails with "transaction already returned" for returned ones
+	SettleTransaction(ctx context.Context, transactionID int64) error
+
+	// ReturnTransaction atomically books the reverse of a returned transfer and marks it returned
+	// (settlement status) and reversed (status)
+	// Fails with "transaction already returned" if it was returned before
+	ReturnTransaction(ctx context.Context, transactionID int64, valueDate time.Time) (*models.Transaction, error)
+
+	// Snapshot calls fn with a read-only view in which every read sees the ledger as of one point
+	// in time, so an export made of several reads is consistent however long it takes
+	// Returns fn's error, or an error if the snapshot could not be taken
+	Snapshot(ctx context.Context, fn func(SettlementSnapshot) error) error
+}
+
+// SettlementSnapshot is a consistent read-only view of the ledger for settlement exports
+type SettlementSnapshot interface {
+	// TransactionsByValueDate is SettlementRepositoryInterface.TransactionsByValueDate as of the
+	// snapshot
+	TransactionsByValueDate(ctx context.Context, date time.Time, accountIDs []int64) ([]models.Transaction, error)
+}
+
+// UsageRepositoryInterface stores the per-key daily API usage rollup
+type UsageRepositoryInterface interface {
+	// AddUsage adds each record's counters to the stored row for the same key and day, creating
+	// missing rows; all records are applied atomically
+	AddUsage(ctx context.Context, records []models.Usage) error
+
+	// ListUsage returns the rows with a day between from and to (inclusive, calendar dates only),
+	// ordered by key and day; an empty keyID matches all keys
+	ListUsage(ctx context.Context, keyID string, from, to time.Time) ([]models.Usage, error)
+}
+
+// LatencyRepositoryInterface stores per-transfer processing latencies for SLA reports
+type LatencyRepositoryInterface interface {
+	// AddLatencies records the samples atomically; a sample for an already recorded transaction
+	// is ignored
+	AddLatencies(ctx context.Context, samples []models.TransferLatency) error
+
+	// LatencyStats summarizes the samples received in [from, to) per client, ordered by client;
+	// an empty clientID matches all clients. WithinTarget counts commits no slower than target
+	LatencyStats(ctx context.Context, clientID string, from, to time.Time, target time.Duration) ([]models.LatencyStats, error)
+
+	// AddRetries adds to the retry counts of recorded transactions, keyed by transaction ID;
+	// counts for transactions without a sample are dropped
+	AddRetries(ctx context.Context, retries map[int64]int) error
+
+	// GetLatency returns the sample of a transaction, or nil if none was recorded
+	GetLatency(ctx context.Context, transactionID int64) (*models.TransferLatency, error)
+}
+
+// RecurringRepositoryInterface stores recurring transfer rules and their execution history
+// Used by the recurring scheduler; several instances may poll the same storage, so a due run is
+// claimed with AdvanceRecurringTransfer before it is executed
+type RecurringRepositoryInterface interface {
+	// CreateRecurringTransfer stores a new rule and returns it with its ID and creation time
+	CreateRecurringTransfer(ctx context.Context, rule models.RecurringTransfer) (*models.RecurringTransfer, error)
+
+	// GetRecurringTransfer returns a rule by ID or a "recurring transfer not found" error
+	GetRecurringTransfer(ctx context.Context, id int64) (*models.RecurringTransfer, error)
+
+	// ListRecurringTransfers returns the rules with an ID above afterID ordered by ID, capped at limit
+	ListRecurringTransfers(ctx context.Context, afterID int64, limit int) ([]models.RecurringTransfer, error)
+
+	// SetRecurringTransferStatus changes a rule's status and next run time
+	// Returns the updated rule or "recurring transfer not found"
+	SetRecurringTransferStatus(ctx context.Context, id int64, status string, nextRunAt time.Time) (*models.RecurringTransfer, error)
+
+	// DeleteRecurringTransfer removes a rule and its execution history
+	// Returns "recurring transfer not found" if it does not exist
+	DeleteRecurringTransfer(ctx context.Context, id int64) error
+
+	// DueRecurringTransfers returns the active rules whose next run is at or before now, earliest
+	// first, capped at limit
+	DueRecurringTransfers(ctx context.Context, now time.Time, limit int) ([]models.RecurringTransfer, error)
+
+	// AdvanceRecurringTransfer moves an active rule's next run from from to to, reporting false
+	// (and changing nothing) if the rule is no longer active or another caller advanced it first
+	AdvanceRecurringTransfer(ctx context.Context, id int64, from, to time.Time) (bool, error)
+
+	// AddRecurringExecution records the outcome of an attempt of a run; a failed execution is
+	// final and escalated with the recurring_transfer.failed event where events are published
+	AddRecurringExecution(ctx context.Context, execution models.RecurringExecution) error
+
+	// DueRecurringRetries returns the queued retries of active rules due at now: retrying
+	// executions whose retry time is at or before now, earliest first, capped at limit
+	DueRecurringRetries(ctx context.Context, now time.Time, limit int) ([]models.RecurringExecution, error)
+
+	// ClaimRecurringRetry takes a queued retry off the queue, reporting false if it is no longer
+	// queued, e.g. because another instance claimed it first
+	ClaimRecurringRetry(ctx context.Context, executionID int64) (bool, error)
+
+	// ListRecurringExecutions returns a rule's executions newest first, capped at limit
+	// A non-zero beforeID starts the page below that execution ID
+	ListRecurringExecutions(ctx context.Context, id, beforeID int64, limit int) ([]models.RecurringExecution, error)
+}
+
+// HoldRepositoryInterface stores authorization holds and keeps each account's held total in step
+// with its active holds. Captures go through TransactionRepositoryInterface.CreateTransaction with
+// Transfer.HoldID set, which ends the hold in the same atomic write as the transfer
+type HoldRepositoryInterface interface {
+	// CreateHold reserves the hold's amount on its source account and returns the active hold
+	// Fails with "source account not found", "source account frozen", "insufficient balance" or
+	// "destination account not found"
+	CreateHold(ctx context.Context, hold models.Hold) (*models.Hold, error)
+
+	// GetHold returns a hold by ID or a "hold not found" error
+	GetHold(ctx context.Context, id int64) (*models.Hold, error)
+
+	// ListHolds returns the holds on a source account newest first, capped at limit
+	// A non-zero beforeID starts the page below that hold ID
+	ListHolds(ctx context.Context, accountID, beforeID int64, limit int) ([]models.Hold, error)
+
+	// ReleaseHold ends an active hold without a transfer and frees its amount
+	// Returns the released hold, "hold not found" or "hold not active"
+	ReleaseHold(ctx context.Context, id int64) (*models.Hold, error)
+
+	// ExpireHolds expires up to limit active holds whose expiry is at or before now, freeing their
+	// amounts, and returns them
+	ExpireHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error)
+
+	// CountActiveHolds returns the number of holds still reserving funds
+	CountActiveHolds(ctx context.Context) (int64, error)
+}
+
+// LimitRepositoryInterface stores per-account transfer limits
+// The limits are enforced by TransactionRepositoryInterface.CreateTransaction in the same atomic
+// write as each debit; transfers reversing a returned transfer are exempt
+type LimitRepositoryInterface interface {
+	// GetLimits returns an account's limits (none set if it has none) or "account not found"
+	GetLimits(ctx context.Context, accountID int64) (*models.TransferLimits, error)
+
+	// SetLimits replaces an account's limits; nil fields remove the limit
+	// Returns the stored limits or "account not found"
+	SetLimits(ctx context.Context, limits models.TransferLimits) (*models.TransferLimits, error)
+
+	// DailyUsage returns the total amount and number of transfers debiting the account since since
+	DailyUsage(ctx context.Context, accountID int64, since time.Time) (decimal.Decimal, int, error)
 }
 
 // Compile-time interface implementation checks
@@ -43,3 +261,128 @@ type TransactionRepositoryInterface interface {
 // Will cause compilation error if interface contracts are not properly fulfilled
 var _ AccountRepositoryInterface = (*AccountRepository)(nil)
 var _ TransactionRepositoryInterface = (*TransactionRepository)(nil)
+var _ SettlementRepositoryInterface = (*SettlementRepository)(nil)
+var _ UsageRepositoryInterface = (*UsageRepository)(nil)
+var _ LatencyRepositoryInterface = (*LatencyRepository)(nil)
+var _ RecurringRepositoryInterface = (*RecurringRepository)(nil)
+var _ HoldRepositoryInterface = (*HoldRepository)(nil)
+var _ LimitRepositoryInterface = (*LimitRepository)(nil)
+
+// AuditRepositoryInterface is the append-only audit log
+// Implementations must offer no way to change or delete an appended event
+type AuditRepositoryInterface interface {
+	// AppendAuditEvent stores the event, stamping its ID and occurrence time, and returns it
+	AppendAuditEvent(ctx context.Context, event models.AuditEvent) (*models.AuditEvent, error)
+
+	// ListAuditEvents returns the events matching the filter newest first, capped at limit
+	// A non-zero filter.BeforeID starts the page below that event ID
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter, limit int) ([]models.AuditEvent, error)
+}
+
+// BalanceHistoryRepositoryInterface stores the balance snapshots of GET
+// /accounts/{account_id}/balance-history
+// Transfers and account creation write their snapshots themselves, in the same commit
+type BalanceHistoryRepositoryInterface interface {
+	// SnapshotBalances records a periodic snapshot of the current balance of every account without
+	// a snapshot taken at or after since, so accounts with recent transfers are skipped
+	// Returns the number of snapshots written
+	SnapshotBalances(ctx context.Context, since time.Time) (int64, error)
+
+	// ListBalanceSnapshots returns the snapshots matching the filter oldest first, capped at limit
+	ListBalanceSnapshots(ctx context.Context, filter models.BalanceHistoryFilter, limit int) ([]models.BalanceSnapshot, error)
+}
+
+// ReconciliationRepositoryInterface recomputes account balances from the ledger for reconciliation
+type ReconciliationRepositoryInterface interface {
+	// ReconcileBalances recomputes every account's balance as its initial balance plus the
+	// transactions it received minus those it sent plus its manual adjustments, and compares it
+	// to the stored balance
+	// Every account is checked against one consistent view of the ledger
+	// Returns the number of accounts checked and the discrepancies ordered by account ID
+	ReconcileBalances(ctx context.Context) (int64, []models.BalanceDiscrepancy, error)
+}
+
+// AttachmentRepositoryInterface stores the records of documents attached to transactions; the
+// documents themselves are kept in an object store (see package attachments)
+type AttachmentRepositoryInterface interface {
+	// AddAttachment records an attachment whose content is already stored under its object key
+	// Returns the attachment with its ID and creation time, or "transaction not found"
+	AddAttachment(ctx context.Context, attachment models.Attachment) (*models.Attachment, error)
+
+	// ListAttachments returns a transaction's attachments oldest first, or "transaction not found"
+	ListAttachments(ctx context.Context, transactionID int64) ([]models.Attachment, error)
+
+	// GetAttachment returns one of a transaction's attachments or "attachment not found"
+	GetAttachment(ctx context.Context, transactionID, id int64) (*models.Attachment, error)
+}
+
+// AdjustmentRepositoryInterface books manual adjustments: credits and debits of a single account
+// made by an operator, outside of transfers
+type AdjustmentRepositoryInterface interface {
+	// CreateAdjustment applies the adjustment's signed amount to the account's balance and records
+	// it, incrementing the account's version; its ID, balance after and creation time are assigned
+	// by the repository
+	// A debit may not take the balance below what the overdraft limit and active holds allow
+	// Returns the booked adjustment, "account not found" or "insufficient balance"
+	CreateAdjustment(ctx context.Context, adjustment models.Adjustment) (*models.Adjustment, error)
+
+	// ListAdjustments returns the account's adjustments created in [from, to) oldest first, capped
+	// at limit; zero times are unbounded
+	// Returns "account not found" if the account does not exist
+	ListAdjustments(ctx context.Context, accountID int64, from, to time.Time, limit int) ([]models.Adjustment, error)
+}
+
+// WalletRepositoryInterface opens and lists the wallets of multi-currency accounts: their
+// balances in currencies other than their own (see models.Wallet)
+// Transfers move wallet balances through TransactionRepositoryInterface.CreateTransaction
+type WalletRepositoryInterface interface {
+	// OpenWallet opens the account's empty wallet in currency (validated by the caller)
+	// Returns the wallet, "account not found", "account has no currency" for accounts without
+	// one, or "wallet already exists" if the account already holds currency, as a wallet or as
+	// its own currency
+	OpenWallet(ctx context.Context, accountID int64, currency string) (*models.Wallet, error)
+
+	// ListWallets returns the account's wallets ordered by currency, empty if it has none
+	ListWallets(ctx context.Context, accountID int64) ([]models.Wallet, error)
+}
+
+// CurrencyRepositoryInterface stores the configuration of the supported currencies (see
+// models.Currency), which the currencies package installs for validation to consult
+type CurrencyRepositoryInterface interface {
+	// ListCurrencies returns every configured currency ordered by code
+	ListCurrencies(ctx context.Context) ([]models.Currency, error)
+
+	// SetCurrency adds or replaces a currency's configuration (validated by the caller) and
+	// returns it as stored
+	// Returns "currency has overdraft limits" if currency.AllowNegative is false while accounts
+	// in the currency have an overdraft limit
+	SetCurrency(ctx context.Context, currency models.Currency) (*models.Currency, error)
+}
+
+// APIKeyRepositoryInterface stores the API keys signing requests (see models.APIKey)
+type APIKeyRepositoryInterface interface {
+	// CreateAPIKey stores a new key (generated by the caller) and returns it with its creation time
+	CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error)
+
+	// GetAPIKey returns a key, revoked or not, or "API key not found"
+	GetAPIKey(ctx context.Context, id string) (*models.APIKey, error)
+
+	// ListAPIKeys returns every key, revoked ones included, oldest first
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+
+	// RevokeAPIKey marks a key revoked and returns it; revoking a revoked key keeps its original
+	// revocation time
+	// Returns "API key not found" for unknown keys
+	RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error)
+}
+
+// RetentionRepositoryInterface purges the rows of a data class older than its retention period
+// Classes are the models.Retention* constants that can be purged; others are refused
+type RetentionRepositoryInterface interface {
+	// CountExpired returns the number of rows of the class older than before
+	CountExpired(ctx context.Context, class string, before time.Time) (int64, error)
+
+	// DeleteExpired deletes up to limit rows of the class older than before, oldest first, and
+	// returns the number deleted
+	DeleteExpired(ctx context.Context, class string, before time.Time, limit int) (int64, error)
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// LatencyRepository implements LatencyRepositoryInterface for PostgreSQL
+type LatencyRepository struct {
+	db *sql.DB
+}
+
+// NewLatencyRepository creates a new latency repository instance
+func NewLatencyRepository(db *sql.DB) *LatencyRepository {
+	return &LatencyRepository{db: db}
+}
+
+// AddLatencies inserts the samples into transfer_latency, durations as whole microseconds
+// Parameters:
+//   - ctx: Context bounding the write
+//   - samples: One sample per committed transfer
+//
+// Returns: Database error if any insert fails; nothing is written in that case
+func (r *LatencyRepository) AddLatencies(ctx context.Context, samples []models.TransferLatency) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, s := range samples {
+		rules := s.Rules
+		if rules == nil {
+			rules = []string{}
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO transfer_latency (transaction_id, client_id, received_at, validate_us, lock_wait_us, commit_us, emit_us, rules, retries)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (transaction_id) DO NOTHING
+		`, s.TransactionID, s.ClientID, s.ReceivedAt, s.Validate.Microseconds(), s.LockWait.Microseconds(),
+			s.Commit.Microseconds(), s.Emit.Microseconds(), rules, s.Retries)
+		if err != nil {
+			return fmt.Errorf("failed to record transfer latency: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transfer latency: %w", err)
+	}
+	return nil
+}
+
+// LatencyStats computes each client's latency percentiles over the samples received in [from, to)
+// Served by the (client_id, received_at) index for a single client and the received_at index
+// for reports
+func (r *LatencyRepository) LatencyStats(ctx context.Context, clientID string, from, to time.Time, target time.Duration) ([]models.LatencyStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT client_id, COUNT(*),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY commit_us),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY commit_us),
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY commit_us),
+			MAX(commit_us),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY emit_us),
+			COUNT(*) FILTER (WHERE commit_us <= $4)
+		FROM transfer_latency
+		WHERE ($1 = '' OR client_id = $1) AND received_at >= $2 AND received_at < $3
+		GROUP BY client_id
+		ORDER BY client_id
+	`, clientID, from, to, target.Microseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute transfer latency: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []models.LatencyStats{}
+	for rows.Next() {
+		var s models.LatencyStats
+		var p50, p95, p99, emitP95 float64
+		var maxCommit int64
+		if err := rows.Scan(&s.ClientID, &s.Transfers, &p50, &p95, &p99, &maxCommit, &emitP95, &s.WithinTarget); err != nil {
+			return nil, fmt.Errorf("failed to scan transfer latency: %w", err)
+		}
+		s.CommitP50 = microseconds(p50)
+		s.CommitP95 = microseconds(p95)
+		s.CommitP99 = microseconds(p99)
+		s.CommitMax = time.Duration(maxCommit) * time.Microsecond
+		s.EmitP95 = microseconds(emitP95)
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to compute transfer latency: %w", err)
+	}
+	return stats, nil
+}
+
+// AddRetries adds to the retry counts of the recorded transactions
+func (r *LatencyRepository) AddRetries(ctx context.Context, retries map[int64]int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for transactionID, count := range retries {
+		_, err := tx.ExecContext(ctx, `UPDATE transfer_latency SET retries = retries + $2 WHERE transaction_id = $1`, transactionID, count)
+		if err != nil {
+			return fmt.Errorf("failed to record transfer retries: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transfer retries: %w", err)
+	}
+	return nil
+}
+
+// GetLatency returns the sample of a transaction, or nil if none was recorded
+func (r *LatencyRepository) GetLatency(ctx context.Context, transactionID int64) (*models.TransferLatency, error) {
+	var s models.TransferLatency
+	var validate, lockWait, commit, emit int64
+	var rules []byte
+	err := r.db.QueryRowContext(ctx, `
+		SELECT transaction_id, client_id, received_at, validate_us, lock_wait_us, commit_us, emit_us, to_jsonb(rules), retries
+		FROM transfer_latency
+		WHERE transaction_id = $1
+	`, transactionID).Scan(&s.TransactionID, &s.ClientID, &s.ReceivedAt, &validate, &lockWait, &commit, &emit, &rules, &s.Retries)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfer latency: %w", err)
+	}
+	if err := json.Unmarshal(rules, &s.Rules); err != nil {
+		return nil, fmt.Errorf("failed to decode transfer rules: %w", err)
+	}
+	s.Validate = time.Duration(validate) * time.Microsecond
+	s.LockWait = time.Duration(lockWait) * time.Microsecond
+	s.Commit = time.Duration(commit) * time.Microsecond
+	s.Emit = time.Duration(emit) * time.Microsecond
+	return &s, nil
+}
+
+// microseconds converts an interpolated microsecond count to a duration
+func microseconds(us float64) time.Duration {
+	return time.Duration(math.Round(us * float64(time.Microsecond)))
+}
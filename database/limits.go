@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// LimitRepository implements LimitRepositoryInterface for PostgreSQL
+type LimitRepository struct {
+	db *sql.DB
+}
+
+// NewLimitRepository creates a new transfer limit repository instance
+func NewLimitRepository(db *sql.DB) *LimitRepository {
+	return &LimitRepository{db: db}
+}
+
+// GetLimits returns an account's transfer limits; an account without limits has none set
+// Returns "account not found" if the account does not exist
+func (r *LimitRepository) GetLimits(ctx context.Context, accountID int64) (*models.TransferLimits, error) {
+	limits := models.TransferLimits{AccountID: accountID}
+	var maxAmount, dailyAmount decimal.NullDecimal
+	var dailyCount sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT l.max_amount, l.daily_amount, l.daily_count
+		FROM accounts a LEFT JOIN account_limits l ON l.account_id = a.account_id
+		WHERE a.account_id = $1
+	`, accountID).Scan(&maxAmount, &dailyAmount, &dailyCount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("account not found")
+		}
+		return nil, fmt.Errorf("failed to get transfer limits: %w", err)
+	}
+	setLimits(&limits, maxAmount, dailyAmount, dailyCount)
+	return &limits, nil
+}
+
+// SetLimits replaces an account's transfer limits; nil fields remove the limit
+// Returns the stored limits or "account not found"
+func (r *LimitRepository) SetLimits(ctx context.Context, limits models.TransferLimits) (*models.TransferLimits, error) {
+	var dailyCount interface{}
+	if limits.DailyCount != nil {
+		dailyCount = *limits.DailyCount
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO account_limits (account_id, max_amount, daily_amount, daily_count)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id) DO UPDATE
+		SET max_amount = EXCLUDED.max_amount, daily_amount = EXCLUDED.daily_amount,
+		    daily_count = EXCLUDED.daily_count, updated_at = NOW()
+	`, limits.AccountID, nullDecimal(limits.MaxAmount), nullDecimal(limits.DailyAmount), dailyCount)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, fmt.Errorf("account not found")
+		}
+		return nil, fmt.Errorf("failed to set transfer limits: %w", err)
+	}
+	return &limits, nil
+}
+
+// DailyUsage returns the total amount and number of transfers debiting an account's primary
+// balance since since; wallet debits are in other currencies and do not count
+// Served by the (source_account_id, created_at) index
+func (r *LimitRepository) DailyUsage(ctx context.Context, accountID int64, since time.Time) (decimal.Decimal, int, error) {
+	return dailyUsage(ctx, r.db, accountID, since)
+}
+
+// checkLimitsTx checks a debit of amount against the source account's limits inside the transfer's
+// database transaction; the caller holds the source account's row lock, so concurrent debits of
+// the account are counted one after the other
+// Returns the limit error of models.TransferLimits.Check, or a database error
+func checkLimitsTx(tx *sql.Tx, accountID int64, amount decimal.Decimal, now time.Time) error {
+	limits := models.TransferLimits{AccountID: accountID}
+	var maxAmount, dailyAmount decimal.NullDecimal
+	var dailyCount sql.NullInt64
+	err := tx.QueryRow(`SELECT max_amount, daily_amount, daily_count FROM account_limits WHERE account_id = $1`, accountID).
+		Scan(&maxAmount, &dailyAmount, &dailyCount)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get transfer limits: %w", err)
+	}
+	setLimits(&limits, maxAmount, dailyAmount, dailyCount)
+
+	usedAmount, usedCount := decimal.Zero, 0
+	if limits.Daily() {
+		if usedAmount, usedCount, err = dailyUsage(context.Background(), tx, accountID, models.LimitDay(now)); err != nil {
+			return err
+		}
+	}
+	return limits.Check(amount, usedAmount, usedCount)
+}
+
+// dailyUsage implements DailyUsage on a database or transaction
+func dailyUsage(ctx context.Context, q queryer, accountID int64, since time.Time) (decimal.Decimal, int, error) {
+	var amount decimal.Decimal
+	var count int
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0), COUNT(*)
+		FROM transactions
+		WHERE source_account_id = $1 AND source_wallet IS NULL AND created_at >= $2
+	`, accountID, since).Scan(&amount, &count)
+	if err != nil {
+		return decimal.Zero, 0, fmt.Errorf("failed to get daily usage: %w", err)
+	}
+	return amount, count, nil
+}
+
+// setLimits copies the nullable limit columns into limits
+func setLimits(limits *models.TransferLimits, maxAmount, dailyAmount decimal.NullDecimal, dailyCount sql.NullInt64) {
+	if maxAmount.Valid {
+		limits.MaxAmount = &maxAmount.Decimal
+	}
+	if dailyAmount.Valid {
+		limits.DailyAmount = &dailyAmount.Decimal
+	}
+	if dailyCount.Valid {
+		count := int(dailyCount.Int64)
+		limits.DailyCount = &count
+	}
+}
+
+// nullDecimal converts an optional decimal into a query parameter, NULL when unset
+func nullDecimal(d *decimal.Decimal) decimal.NullDecimal {
+	if d == nil {
+		return decimal.NullDecimal{}
+	}
+	return decimal.NullDecimal{Decimal: *d, Valid: true}
+}
@@ -1,88 +1,321 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"embed"
 	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 )
 
-// Migrate executes all database schema migrations in the correct order
-// This function sets up the complete database schema for the transfers service
+// migrationFiles holds the numbered SQL migrations compiled into the binary
+// Each migration is a pair of files: NNNN_name.up.sql and NNNN_name.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the Postgres advisory lock key held while migrations run
+// It serializes concurrent instances starting against the same database
+const migrationLockID = 727_274_001
+
+// migrationFilePattern matches migration file names and captures version, name and direction
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change with its forward and rollback SQL
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// LoadMigrations reads and validates the embedded migration set
+// Returns:
+//   - []Migration: Migrations sorted by version
+//   - error: If a file name is malformed, a version is duplicated, or an up/down file is missing
+//
+// Versions must be contiguous starting from 1 so that a gap (e.g. a forgotten file) fails loudly
+func LoadMigrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles, "migrations")
+}
+
+// loadMigrations parses migration files from dir within fsys
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(matches[1])
+		name, direction := matches[2], matches[3]
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be contiguous from 1: expected %d, found %d", i+1, m.Version)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Migrate applies all pending migrations in version order
+// This function brings the database schema up to date for the transfers service
 // Parameters:
 //   - db: Active database connection to execute migrations against
 //
 // Returns:
 //   - error: Migration error if any step fails, nil on complete success
 //
-// Migration sequence:
-//  1. Creates accounts table with balance constraints
-//  2. Creates transactions table with foreign key relationships
-//  3. Creates performance indexes on transaction lookups
+// Behavior:
+//   - Creates the schema_migrations version table if it does not exist
+//   - Holds a Postgres advisory lock so concurrently starting instances migrate one at a time
+//   - Applies each pending migration in its own transaction and records it in schema_migrations
 //
-// Note: Uses IF NOT EXISTS to make migrations idempotent (safe to run multiple times)
-// Important: Migrations are run in order and will stop on first failure
+// Important: Migrations are run in order and will stop on first failure; already applied
+// migrations are never re-run
 func Migrate(db *sql.DB) error {
-	migrations := []string{
-		createAccountsTable,
-		createTransactionsTable,
-		createIndexes,
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
 	}
 
-	for i, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
-			return fmt.Errorf("failed to run migration %d: %w", i+1, err)
+	return withMigrationLock(db, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if applied[m.Version] {
+				continue
+			}
+			err := runInTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to run migration %d_%s: %w", m.Version, m.Name, err)
+			}
 		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the most recently applied migrations
+// Parameters:
+//   - db: Active database connection
+//   - steps: Number of migrations to roll back (must be positive)
+//
+// Returns:
+//   - error: If a rollback fails; earlier rollbacks in the same call remain committed
+func MigrateDown(db *sql.DB, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("rollback steps must be positive")
 	}
 
-	return nil
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	return withMigrationLock(db, func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for i := 0; i < steps && i < len(versions); i++ {
+			m, ok := byVersion[versions[i]]
+			if !ok {
+				return fmt.Errorf("applied migration %d is not known to this build", versions[i])
+			}
+			err := runInTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to roll back migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
 }
 
-// createAccountsTable defines the schema for storing bank account information
-// Key design decisions:
-//   - BIGINT account_id for large scale account numbering
-//   - DECIMAL(15,5) for precise monetary calculations (up to 999,999,999.99999)
-//   - CHECK constraint prevents negative balances at database level
-//   - Timestamps for audit trail with timezone awareness
-//   - Primary key on account_id for unique identification and fast lookups
-const createAccountsTable = `
-CREATE TABLE IF NOT EXISTS accounts (
-    account_id BIGINT PRIMARY KEY,
-    balance DECIMAL(15,5) NOT NULL CHECK (balance >= 0),
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-);
-`
+// SchemaVersion returns the highest applied migration version, or 0 for an empty database
+func SchemaVersion(db *sql.DB) (int, error) {
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, createSchemaMigrationsTable); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var version int
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
 
-// createTransactionsTable defines the schema for storing transfer transaction records
-// Key design decisions:
-//   - BIGSERIAL id for unique transaction identification and ordering
-//   - Foreign keys ensure referential integrity with accounts table
-//   - CHECK constraints enforce business rules (positive amounts, different accounts)
-//   - DECIMAL(15,5) matches account balance precision for consistency
-//   - Timestamps for transaction audit trail and ordering
-//   - Source/destination pattern supports directional money transfers
-const createTransactionsTable = `
-CREATE TABLE IF NOT EXISTS transactions (
-    id BIGSERIAL PRIMARY KEY,
-    source_account_id BIGINT NOT NULL,
-    destination_account_id BIGINT NOT NULL,
-    amount DECIMAL(15,5) NOT NULL CHECK (amount > 0),
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    FOREIGN KEY (source_account_id) REFERENCES accounts(account_id),
-    FOREIGN KEY (destination_account_id) REFERENCES accounts(account_id),
-    CHECK (source_account_id != destination_account_id)
+// createSchemaMigrationsTable defines the table recording which migrations have been applied
+const createSchemaMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 `
 
-// createIndexes defines performance indexes for efficient transaction queries
-// Index strategy:
-//   - idx_transactions_source_account: Fast lookup of outgoing transfers for an account
-//   - idx_transactions_destination_account: Fast lookup of incoming transfers for an account
-//   - idx_transactions_created_at: Fast time-based queries and transaction history ordering
+// withMigrationLock runs fn on a dedicated connection holding the migration advisory lock
+func withMigrationLock(db *sql.DB, fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, createSchemaMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(ctx, conn)
+}
+
+// appliedVersions returns the set of migration versions recorded in schema_migrations
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// runInTx executes fn inside a transaction on conn, committing only if fn succeeds
+func runInTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus compares the applied migrations with those compiled into this build
+// Returns:
+//   - []Migration: Migrations not yet applied, in version order
+//   - []int: Applied versions this build does not know (the database was migrated by a newer release)
+//   - error: If the migrations or schema_migrations cannot be read
 //
-// These indexes support common query patterns like account transaction history,
-// balance calculations, and time-based reporting without full table scans
-const createIndexes = `
-CREATE INDEX IF NOT EXISTS idx_transactions_source_account ON transactions(source_account_id);
-CREATE INDEX IF NOT EXISTS idx_transactions_destination_account ON transactions(destination_account_id);
-CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
-`
+// Unlike Migrate and SchemaVersion it only reads, so a database that was never migrated reports
+// every migration as pending
+func MigrationStatus(db *sql.DB) ([]Migration, []int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	if !exists {
+		return migrations, nil, nil
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer conn.Close()
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+		delete(applied, m.Version)
+	}
+	unknown := make([]int, 0, len(applied))
+	for version := range applied {
+		unknown = append(unknown, version)
+	}
+	sort.Ints(unknown)
+	return pending, unknown, nil
+}
@@ -0,0 +1,10 @@
+-- Accounts hold the current balance of every ledger account
+--   - BIGINT account_id for large scale account numbering
+--   - DECIMAL(15,5) for precise monetary calculations (up to 999,999,999.99999)
+--   - CHECK constraint prevents negative balances at database level
+CREATE TABLE IF NOT EXISTS accounts (
+    account_id BIGINT PRIMARY KEY,
+    balance DECIMAL(15,5) NOT NULL CHECK (balance >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
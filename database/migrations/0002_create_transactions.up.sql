@@ -0,0 +1,13 @@
+-- Transactions record every transfer between two accounts
+--   - Foreign keys ensure referential integrity with accounts table
+--   - CHECK constraints enforce positive amounts and different accounts
+CREATE TABLE IF NOT EXISTS transactions (
+    id BIGSERIAL PRIMARY KEY,
+    source_account_id BIGINT NOT NULL,
+    destination_account_id BIGINT NOT NULL,
+    amount DECIMAL(15,5) NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (source_account_id) REFERENCES accounts(account_id),
+    FOREIGN KEY (destination_account_id) REFERENCES accounts(account_id),
+    CHECK (source_account_id != destination_account_id)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_created_at;
+DROP INDEX IF EXISTS idx_transactions_destination_account;
+DROP INDEX IF EXISTS idx_transactions_source_account;
@@ -0,0 +1,4 @@
+-- Lookup indexes for account transaction history and time-based reporting
+CREATE INDEX IF NOT EXISTS idx_transactions_source_account ON transactions(source_account_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_destination_account ON transactions(destination_account_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
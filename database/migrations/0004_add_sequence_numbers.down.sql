@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transactions_destination_sequence;
+DROP INDEX IF EXISTS idx_transactions_source_sequence;
+ALTER TABLE transactions DROP COLUMN IF EXISTS destination_sequence;
+ALTER TABLE transactions DROP COLUMN IF EXISTS source_sequence;
+ALTER TABLE accounts DROP COLUMN IF EXISTS sequence;
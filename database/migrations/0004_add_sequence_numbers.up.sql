@@ -0,0 +1,8 @@
+-- Per-account ledger sequence numbers
+--   - accounts.sequence is the last number assigned on the account, incremented under the row lock
+--   - Unique indexes guarantee no two movements on an account share a sequence number
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS sequence BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS source_sequence BIGINT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_sequence BIGINT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_source_sequence ON transactions(source_account_id, source_sequence);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_destination_sequence ON transactions(destination_account_id, destination_sequence);
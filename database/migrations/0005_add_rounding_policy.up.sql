@@ -0,0 +1,3 @@
+-- Rounding policy applied to each transaction's amount
+--   - Existing rows were rounded implicitly by NUMERIC storage, which rounds ties away from zero
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS rounding_policy TEXT NOT NULL DEFAULT 'half_up';
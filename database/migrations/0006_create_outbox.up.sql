@@ -0,0 +1,14 @@
+-- Transactional outbox for event publishing
+--   - Rows are inserted in the same database transaction as the change they describe, so an event
+--     exists if and only if the change committed
+--   - published_at is set by the relay once the broker acknowledged the event
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id TEXT NOT NULL UNIQUE,
+    event_type TEXT NOT NULL,
+    partition_key TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events(id) WHERE published_at IS NULL;
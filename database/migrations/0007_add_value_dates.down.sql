@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_value_date;
+ALTER TABLE transactions DROP COLUMN IF EXISTS value_date;
+ALTER TABLE transactions DROP COLUMN IF EXISTS transfer_type;
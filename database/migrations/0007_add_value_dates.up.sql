@@ -0,0 +1,8 @@
+-- Transfer type and value date
+--   - value_date is the accounting date assigned from the transfer type's cut-off time
+--   - Existing transfers are value-dated on the day they were created
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS transfer_type TEXT NOT NULL DEFAULT 'internal';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS value_date DATE;
+UPDATE transactions SET value_date = (created_at AT TIME ZONE 'UTC')::date WHERE value_date IS NULL;
+ALTER TABLE transactions ALTER COLUMN value_date SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_value_date ON transactions(value_date);
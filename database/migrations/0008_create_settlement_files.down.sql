@@ -0,0 +1 @@
+DROP TABLE IF EXISTS settlement_files;
@@ -0,0 +1,16 @@
+-- Partner settlement file generation records
+--   - One row per partner and business date; regeneration overwrites the row
+--   - status is 'generated' once the file was uploaded to the export target, 'failed' otherwise
+CREATE TABLE IF NOT EXISTS settlement_files (
+    id BIGSERIAL PRIMARY KEY,
+    partner_id TEXT NOT NULL,
+    business_date DATE NOT NULL,
+    file_name TEXT NOT NULL,
+    format TEXT NOT NULL,
+    status TEXT NOT NULL,
+    transfer_count INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    generated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (partner_id, business_date)
+);
+CREATE INDEX IF NOT EXISTS idx_settlement_files_business_date ON settlement_files(business_date);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_return_of;
+ALTER TABLE transactions DROP COLUMN IF EXISTS return_of;
+ALTER TABLE transactions DROP COLUMN IF EXISTS settlement_status;
@@ -0,0 +1,6 @@
+-- Settlement status of transfers, updated from partner acknowledgment/return files
+--   - settlement_status is 'unsettled' until a partner acknowledges ('settled') or returns ('returned') it
+--   - return_of links a return transaction to the transfer it reverses; at most one return per transfer
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS settlement_status TEXT NOT NULL DEFAULT 'unsettled';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS return_of BIGINT REFERENCES transactions(id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_return_of ON transactions(return_of) WHERE return_of IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Account status for compliance holds
+--   - Transfers debiting or crediting a 'frozen' account are refused until it is unfrozen
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_accounts_tags;
+ALTER TABLE accounts DROP COLUMN IF EXISTS tags;
+ALTER TABLE accounts DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1,5 @@
+-- Owner-defined account metadata (free-form JSON object) and tags
+--   - The GIN index serves tag filters in account listings (tags @> ARRAY[...])
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_accounts_tags ON accounts USING GIN (tags);
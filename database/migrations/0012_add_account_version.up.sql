@@ -0,0 +1,4 @@
+-- Version of an account's non-balance fields (status, metadata, tags) for optimistic concurrency
+--   - Incremented by every account update; ledger movements do not change it
+--   - Exposed as the ETag of the account and checked against If-Match on PATCH
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_accounts_status;
+DROP INDEX IF EXISTS idx_accounts_created_at;
+ALTER TABLE accounts ALTER COLUMN created_at DROP NOT NULL;
@@ -0,0 +1,7 @@
+-- Account listing filters and paginates on creation time and status
+--   - created_at is now read with every account, so it must always be set
+--   - Indexes serve the created_from/created_before and status filters of GET /accounts
+UPDATE accounts SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE accounts ALTER COLUMN created_at SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_accounts_created_at ON accounts(created_at);
+CREATE INDEX IF NOT EXISTS idx_accounts_status ON accounts(status);
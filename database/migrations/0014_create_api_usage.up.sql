@@ -0,0 +1,13 @@
+-- Daily API usage rollup per API key, for chargeback between business units
+--   - key_id is a fingerprint of the API key; the secret key is never stored
+--   - Rows are incremented by the usage recorder's periodic flushes
+CREATE TABLE IF NOT EXISTS api_usage_daily (
+    key_id TEXT NOT NULL,
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    transfers BIGINT NOT NULL DEFAULT 0,
+    transfer_volume DECIMAL(20,5) NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_usage_daily_day ON api_usage_daily(day);
@@ -0,0 +1,4 @@
+-- ISO 4217 currency of each account; transfers are only allowed between accounts of the same currency
+--   - Accounts created before multi-currency support keep an empty currency and the ledger's
+--     5 decimal places
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- Per-transfer processing latency, for per-client commit SLA reports
+--   - client_id is the API key fingerprint the transfer was made with (see the usage package)
+--   - commit_us and emit_us are microseconds from receipt to commit and to event emission
+--   - Rows are inserted by the SLA recorder's periodic flushes
+CREATE TABLE IF NOT EXISTS transfer_latency (
+    transaction_id BIGINT PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    commit_us BIGINT NOT NULL,
+    emit_us BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_latency_client_received ON transfer_latency(client_id, received_at);
+CREATE INDEX IF NOT EXISTS idx_transfer_latency_received ON transfer_latency(received_at);
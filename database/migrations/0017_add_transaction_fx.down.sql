@@ -0,0 +1,3 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_rate_timestamp;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE transactions DROP COLUMN IF EXISTS destination_amount;
@@ -0,0 +1,8 @@
+-- Cross-currency transfers debit the source in its currency and credit the converted amount
+--   - destination_amount is the amount credited, in the destination account's currency
+--   - fx_rate is the destination currency per unit of source currency used for the conversion,
+--     observed by the rate source at fx_rate_timestamp
+--   - All three stay NULL for same-currency transfers, which credit amount
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_amount DECIMAL(15,5) CHECK (destination_amount > 0);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate DECIMAL(20,10) CHECK (fx_rate > 0);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate_timestamp TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,4 @@
+-- Fails while any account is overdrawn; bring those balances back to zero first
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_balance_within_overdraft;
+ALTER TABLE accounts ADD CONSTRAINT accounts_balance_check CHECK (balance >= 0);
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_limit;
@@ -0,0 +1,8 @@
+-- Per-account overdraft limits let credit-style internal accounts go negative to a controlled extent
+--   - overdraft_limit is how far below zero the balance may go; 0 (the default) keeps the
+--     original no-negative-balance rule
+--   - The original balance >= 0 check is replaced by one that honors the limit, so the database
+--     still refuses any balance beyond it
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit DECIMAL(15,5) NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0);
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_balance_check;
+ALTER TABLE accounts ADD CONSTRAINT accounts_balance_within_overdraft CHECK (balance >= -overdraft_limit);
@@ -0,0 +1,9 @@
+-- Bi-temporal ledger: every transaction records when it takes effect for the business as well as
+-- when it was recorded (created_at)
+--   - effective_at defaults to the recording time; a backdated transfer (e.g. restating a closed
+--     period) sets it earlier
+--   - Existing transactions took effect when they were recorded
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS effective_at TIMESTAMP WITH TIME ZONE;
+UPDATE transactions SET effective_at = COALESCE(created_at, NOW()) WHERE effective_at IS NULL;
+ALTER TABLE transactions ALTER COLUMN effective_at SET DEFAULT NOW();
+ALTER TABLE transactions ALTER COLUMN effective_at SET NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS recurring_executions;
+DROP TABLE IF EXISTS recurring_transfers;
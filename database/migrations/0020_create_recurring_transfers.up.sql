@@ -0,0 +1,32 @@
+-- Recurring transfer rules and the history of their executions
+--   - schedule is a cron expression or @every interval, parsed by the recurring package
+--   - next_run_at is advanced with a compare-and-set before each run, so only one instance
+--     executes a due run
+--   - Executions are removed with their rule
+CREATE TABLE IF NOT EXISTS recurring_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    source_account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    destination_account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    amount DECIMAL(15,5) NOT NULL CHECK (amount > 0),
+    transfer_type TEXT NOT NULL DEFAULT 'internal',
+    tenant TEXT NOT NULL DEFAULT 'default',
+    schedule TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused')),
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (source_account_id != destination_account_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_transfers_due ON recurring_transfers(next_run_at) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS recurring_executions (
+    id BIGSERIAL PRIMARY KEY,
+    recurring_transfer_id BIGINT NOT NULL REFERENCES recurring_transfers(id) ON DELETE CASCADE,
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    executed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('succeeded', 'failed')),
+    transaction_id BIGINT REFERENCES transactions(id),
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_executions_rule ON recurring_executions(recurring_transfer_id, id);
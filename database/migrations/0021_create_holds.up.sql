@@ -0,0 +1,25 @@
+-- Authorization holds: funds reserved on a source account for a later capture
+--   - accounts.held is the total of the account's active holds; transfers may only debit
+--     balance + overdraft_limit - held, while the balance itself is unchanged until capture
+--   - A hold ends captured (transaction_id is the booked transfer), released or expired; only
+--     active holds count in accounts.held
+--   - Active holds are found for expiry by the partial index on expires_at
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS held DECIMAL(15,5) NOT NULL DEFAULT 0 CHECK (held >= 0);
+
+CREATE TABLE IF NOT EXISTS holds (
+    id BIGSERIAL PRIMARY KEY,
+    source_account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    destination_account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    amount DECIMAL(15,5) NOT NULL CHECK (amount > 0),
+    transfer_type TEXT NOT NULL DEFAULT 'internal',
+    tenant TEXT NOT NULL DEFAULT 'default',
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'captured', 'released', 'expired')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    transaction_id BIGINT REFERENCES transactions(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (source_account_id != destination_account_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_expiry ON holds(expires_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_holds_source_account ON holds(source_account_id, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_source_account_created;
+DROP TABLE IF EXISTS account_limits;
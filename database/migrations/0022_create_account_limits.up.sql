@@ -0,0 +1,13 @@
+-- Per-account transfer limits, checked for every debit inside the transfer's database transaction
+--   - A NULL column is not limited; an account without a row has no limits
+--   - Daily limits count the account's debits since the start of the UTC day, found through the
+--     (source_account_id, created_at) index
+CREATE TABLE IF NOT EXISTS account_limits (
+    account_id BIGINT PRIMARY KEY REFERENCES accounts(account_id),
+    max_amount DECIMAL(15,5) CHECK (max_amount >= 0),
+    daily_amount DECIMAL(15,5) CHECK (daily_amount >= 0),
+    daily_count INTEGER CHECK (daily_count >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_source_account_created ON transactions(source_account_id, created_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_destination_account_reference;
+DROP INDEX IF EXISTS idx_transactions_source_account_reference;
+ALTER TABLE transactions DROP COLUMN IF EXISTS memo;
+ALTER TABLE transactions DROP COLUMN IF EXISTS reference;
@@ -0,0 +1,8 @@
+-- Client annotations of a transfer, tying it back to e.g. an invoice
+--   - reference is optional and unique per source account; NULL for transfers without one
+--   - memo is free text, searched by substring without an index within one account's transactions
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reference TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS memo TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_source_account_reference ON transactions(source_account_id, reference) WHERE reference IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_destination_account_reference ON transactions(destination_account_id, reference) WHERE reference IS NOT NULL;
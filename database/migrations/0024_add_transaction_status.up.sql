@@ -0,0 +1,7 @@
+-- Lifecycle status of transactions
+--   - Transfers booked synchronously are recorded 'completed'; 'pending' and 'failed' are for
+--     transfers processed asynchronously, which are recorded before they are executed
+--   - A completed transfer becomes 'reversed' when a return transaction reverses it
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'completed'
+    CHECK (status IN ('pending', 'completed', 'failed', 'reversed'));
+UPDATE transactions SET status = 'reversed' WHERE settlement_status = 'returned';
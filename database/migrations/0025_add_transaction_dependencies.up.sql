@@ -0,0 +1,4 @@
+-- Transfers chained after an earlier transaction
+--   - depends_on names the transaction that had to be completed, and not reversed, when the
+--     transfer was booked; NULL for independent transfers
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS depends_on BIGINT REFERENCES transactions(id);
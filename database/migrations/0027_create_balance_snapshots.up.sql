@@ -0,0 +1,18 @@
+-- Balance history: the balance a transfer left on each of its accounts, written in the transfer's
+-- commit, plus periodic snapshots of every account
+--   - sequence is the account's ledger sequence number the balance includes
+--   - Existing accounts get an initial periodic snapshot of their current balance
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    balance DECIMAL(15,5) NOT NULL,
+    sequence BIGINT NOT NULL,
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    source TEXT NOT NULL CHECK (source IN ('opened', 'transfer', 'periodic')),
+    transaction_id BIGINT REFERENCES transactions(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_snapshots_account_taken_at ON balance_snapshots(account_id, taken_at);
+
+INSERT INTO balance_snapshots (account_id, balance, sequence, source)
+SELECT account_id, balance, sequence, 'periodic' FROM accounts;
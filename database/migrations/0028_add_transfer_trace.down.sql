@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_outbox_events_transaction_id;
+DROP INDEX IF EXISTS idx_audit_events_transaction_id;
+
+ALTER TABLE transfer_latency
+    DROP COLUMN IF EXISTS retries,
+    DROP COLUMN IF EXISTS rules,
+    DROP COLUMN IF EXISTS lock_wait_us,
+    DROP COLUMN IF EXISTS validate_us;
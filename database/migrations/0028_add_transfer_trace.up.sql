@@ -0,0 +1,13 @@
+-- Transfer trace: what GET /admin/transactions/{id}/trace assembles beyond the transaction itself
+--   - transfer_latency gains the rest of the processing timeline: validate_us (receipt until
+--     validation and the rules passed), lock_wait_us (time the commit waited for row locks), the
+--     rules the transfer passed and the retries refused as its duplicates
+--   - audit events and outbox events are looked up by transaction
+ALTER TABLE transfer_latency
+    ADD COLUMN IF NOT EXISTS validate_us BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS lock_wait_us BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rules TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS retries INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_transaction_id ON audit_events(transaction_id) WHERE transaction_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_transaction_id ON outbox_events(((payload->>'id')::bigint)) WHERE event_type = 'transaction.completed';
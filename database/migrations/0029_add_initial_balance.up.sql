@@ -0,0 +1,21 @@
+-- Reconciliation: accounts keep the balance they were opened with, so their stored balance can be
+-- recomputed from it and their transactions
+--   - Existing accounts take their opening snapshot's balance when balance history recorded one;
+--     older accounts are backfilled with their balance minus their transactions, so they
+--     reconcile as of this migration and only later discrepancies are detected
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS initial_balance DECIMAL(15,5);
+
+UPDATE accounts a SET initial_balance = COALESCE(
+    (SELECT s.balance FROM balance_snapshots s
+     WHERE s.account_id = a.account_id AND s.source = 'opened'
+     ORDER BY s.id LIMIT 1),
+    a.balance - COALESCE((
+        SELECT SUM(CASE WHEN t.source_account_id = a.account_id THEN -t.amount
+                        ELSE COALESCE(t.destination_amount, t.amount) END)
+        FROM transactions t
+        WHERE t.source_account_id = a.account_id OR t.destination_account_id = a.account_id
+    ), 0)
+)
+WHERE initial_balance IS NULL;
+
+ALTER TABLE accounts ALTER COLUMN initial_balance SET NOT NULL;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_outbox_events_published_at;
+
+CREATE OR REPLACE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
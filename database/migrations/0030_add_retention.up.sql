@@ -0,0 +1,15 @@
+-- Retention policies: expired audit events and published outbox events are purged in batches
+--   - The audit log stays append-only for every role, except that a DELETE is let through in a
+--     transaction that set retention.purge to 'audit_events' (see RetentionRepository); updates
+--     and truncation are always rejected
+--   - Published outbox events are selected by publication time
+CREATE OR REPLACE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('retention.purge', true) = 'audit_events' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events(published_at) WHERE published_at IS NOT NULL;
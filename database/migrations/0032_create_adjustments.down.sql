@@ -0,0 +1,6 @@
+DELETE FROM balance_snapshots WHERE source = 'adjustment';
+ALTER TABLE balance_snapshots DROP CONSTRAINT IF EXISTS balance_snapshots_source_check;
+ALTER TABLE balance_snapshots ADD CONSTRAINT balance_snapshots_source_check
+    CHECK (source IN ('opened', 'transfer', 'periodic'));
+
+DROP TABLE IF EXISTS adjustments;
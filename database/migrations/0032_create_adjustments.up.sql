@@ -0,0 +1,20 @@
+-- Manual adjustments: credits (positive amount) and debits (negative amount) of a single account
+-- booked by an operator to correct operational errors, with a mandatory reason and actor
+--   - balance_after is the account's balance once the adjustment was applied
+--   - The balance each adjustment leaves is recorded in the balance history as an 'adjustment'
+--     snapshot
+CREATE TABLE IF NOT EXISTS adjustments (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    amount DECIMAL(15,5) NOT NULL CHECK (amount <> 0),
+    reason TEXT NOT NULL CHECK (reason <> ''),
+    actor TEXT NOT NULL CHECK (actor <> ''),
+    balance_after DECIMAL(15,5) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_adjustments_account_created_at ON adjustments(account_id, created_at);
+
+ALTER TABLE balance_snapshots DROP CONSTRAINT IF EXISTS balance_snapshots_source_check;
+ALTER TABLE balance_snapshots ADD CONSTRAINT balance_snapshots_source_check
+    CHECK (source IN ('opened', 'transfer', 'periodic', 'adjustment'));
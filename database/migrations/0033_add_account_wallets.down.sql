@@ -0,0 +1,3 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS destination_wallet;
+ALTER TABLE transactions DROP COLUMN IF EXISTS source_wallet;
+DROP TABLE IF EXISTS account_wallets;
@@ -0,0 +1,16 @@
+-- Multi-currency wallets: an account holds balances in several currencies under one account ID
+--   - accounts.balance stays the account's primary balance, in accounts.currency; account_wallets
+--     holds its balances in other currencies, which open empty and cannot be overdrawn
+--   - source_wallet / destination_wallet are the currency of the wallet a transfer moved on each
+--     side, NULL for the account's primary balance
+CREATE TABLE IF NOT EXISTS account_wallets (
+    account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    currency TEXT NOT NULL CHECK (currency <> ''),
+    balance DECIMAL(15,5) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_id, currency)
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS source_wallet TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_wallet TEXT;
@@ -0,0 +1,24 @@
+-- Per-currency configuration consulted by validation, replacing the built-in currency table
+--   - scale is the number of decimal places amounts in the currency may have, at most the 5 of
+--     DECIMAL(15,5) every amount is stored with
+--   - min_amount / max_amount bound each transfer debiting the currency; NULL is not bounded
+--   - allow_negative is whether accounts in the currency may be given an overdraft limit
+--   - Seeded with the built-in currencies, so existing accounts keep validating as before
+CREATE TABLE IF NOT EXISTS currencies (
+    code TEXT PRIMARY KEY CHECK (code ~ '^[A-Z]{3}$'),
+    scale SMALLINT NOT NULL CHECK (scale BETWEEN 0 AND 5),
+    min_amount DECIMAL(15,5) CHECK (min_amount > 0),
+    max_amount DECIMAL(15,5) CHECK (max_amount > 0),
+    allow_negative BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (max_amount >= min_amount)
+);
+
+INSERT INTO currencies (code, scale) VALUES
+    ('AUD', 2), ('BHD', 3), ('BRL', 2), ('CAD', 2), ('CHF', 2),
+    ('CLP', 0), ('CNY', 2), ('CZK', 2), ('DKK', 2), ('EUR', 2),
+    ('GBP', 2), ('HKD', 2), ('HUF', 2), ('INR', 2), ('ISK', 0),
+    ('JOD', 3), ('JPY', 0), ('KRW', 0), ('KWD', 3), ('MXN', 2),
+    ('NOK', 2), ('NZD', 2), ('OMR', 3), ('PLN', 2), ('SEK', 2),
+    ('SGD', 2), ('TND', 3), ('USD', 2), ('VND', 0), ('ZAR', 2)
+ON CONFLICT (code) DO NOTHING;
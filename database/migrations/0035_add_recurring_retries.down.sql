@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_recurring_executions_retry;
+
+UPDATE recurring_executions SET status = 'failed' WHERE status = 'retrying';
+ALTER TABLE recurring_executions DROP CONSTRAINT IF EXISTS recurring_executions_status_check;
+ALTER TABLE recurring_executions ADD CONSTRAINT recurring_executions_status_check
+    CHECK (status IN ('succeeded', 'failed'));
+
+ALTER TABLE recurring_executions DROP COLUMN IF EXISTS retry_at;
+ALTER TABLE recurring_executions DROP COLUMN IF EXISTS attempt;
@@ -0,0 +1,14 @@
+-- Retry queue of recurring transfers: a run refused for a transient reason (e.g. insufficient
+-- balance) is attempted again after the configured delays instead of failing at once
+--   - attempt counts the attempts of the run scheduled at scheduled_at, from 1
+--   - A 'retrying' execution is queued while retry_at is set; claiming its retry clears retry_at
+--     with a compare-and-set, so only one instance takes it
+--   - 'failed' is final, and written with a recurring_transfer.failed outbox event
+ALTER TABLE recurring_executions ADD COLUMN IF NOT EXISTS attempt INTEGER NOT NULL DEFAULT 1 CHECK (attempt > 0);
+ALTER TABLE recurring_executions ADD COLUMN IF NOT EXISTS retry_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE recurring_executions DROP CONSTRAINT IF EXISTS recurring_executions_status_check;
+ALTER TABLE recurring_executions ADD CONSTRAINT recurring_executions_status_check
+    CHECK (status IN ('succeeded', 'failed', 'retrying'));
+
+CREATE INDEX IF NOT EXISTS idx_recurring_executions_retry ON recurring_executions(retry_at) WHERE retry_at IS NOT NULL;
@@ -0,0 +1,7 @@
+-- External reference IDs: an account's identifier in an external system (e.g. core banking or the
+-- ERP), so integrators can look accounts up by it instead of keeping a mapping table
+--   - NULL for accounts without one; the unique constraint ignores NULLs
+--   - The constraint's index serves GET /accounts/by-external-id/{external_id}
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS external_id TEXT CHECK (external_id <> '');
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_external_id_key;
+ALTER TABLE accounts ADD CONSTRAINT accounts_external_id_key UNIQUE (external_id);
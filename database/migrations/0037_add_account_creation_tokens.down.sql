@@ -0,0 +1,2 @@
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_creation_token_key;
+ALTER TABLE accounts DROP COLUMN IF EXISTS creation_token;
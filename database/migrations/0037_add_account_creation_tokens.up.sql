@@ -0,0 +1,6 @@
+-- Idempotent account creation: the client token an account was created with, so a retried
+-- POST /accounts with the same token and request returns the account instead of a conflict
+--   - NULL for accounts created without one; the unique constraint ignores NULLs
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS creation_token TEXT CHECK (creation_token <> '');
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_creation_token_key;
+ALTER TABLE accounts ADD CONSTRAINT accounts_creation_token_key UNIQUE (creation_token);
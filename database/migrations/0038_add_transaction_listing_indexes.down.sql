@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_transactions_source_account_created ON transactions(source_account_id, created_at);
+DROP INDEX IF EXISTS idx_transactions_destination_account_created_id;
+DROP INDEX IF EXISTS idx_transactions_source_account_created_id;
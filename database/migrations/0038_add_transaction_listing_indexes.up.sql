@@ -0,0 +1,8 @@
+-- Transaction listings page newest first by (created_at, id), the keyset of their cursors. Each
+-- side of a transfer is read from its own index, so a page costs the same however many
+-- transactions the account has
+--   - (source_account_id, created_at, id) also serves the daily limit sums, replacing the
+--     (source_account_id, created_at) index they used
+CREATE INDEX IF NOT EXISTS idx_transactions_source_account_created_id ON transactions(source_account_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_destination_account_created_id ON transactions(destination_account_id, created_at, id);
+DROP INDEX IF EXISTS idx_transactions_source_account_created;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_destination_source_created_id;
+DROP INDEX IF EXISTS idx_transactions_source_destination_created_id;
@@ -0,0 +1,4 @@
+-- Transaction listings filtered by counterparty read each side of the account's transfers with
+-- the other side fixed, in the listings' (created_at, id) order
+CREATE INDEX IF NOT EXISTS idx_transactions_source_destination_created_id ON transactions(source_account_id, destination_account_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_transactions_destination_source_created_id ON transactions(destination_account_id, source_account_id, created_at, id);
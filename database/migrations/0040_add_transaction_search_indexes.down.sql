@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_amount;
+DROP INDEX IF EXISTS idx_transactions_reference;
+DROP INDEX IF EXISTS idx_transactions_memo_search;
@@ -0,0 +1,9 @@
+-- Indexes of GET /transactions/search, which finds transactions across accounts
+--   - Memo words are matched with full-text search on the memo's 'simple' tsvector (no stemming,
+--     so words match whole); the expression must match the search query's exactly
+--   - References are searched without an account, which the (account, reference) indexes cannot
+--     serve
+--   - Amount ranges, e.g. every transfer of exactly the disputed amount
+CREATE INDEX IF NOT EXISTS idx_transactions_memo_search ON transactions USING GIN (to_tsvector('simple', COALESCE(memo, '')));
+CREATE INDEX IF NOT EXISTS idx_transactions_reference ON transactions(reference) WHERE reference IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_amount ON transactions(amount);
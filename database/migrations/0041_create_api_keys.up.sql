@@ -0,0 +1,12 @@
+-- API keys signing write requests with HMAC-SHA256 (see the signing package)
+--   - id is the public key ID clients send in X-Signature-Key-ID
+--   - secret is the HMAC key; verifying a signature needs it, so it is stored as issued and the
+--     table must be protected like the database credentials
+--   - revoked_at is set when the key is revoked; revoked keys are kept for the audit trail
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    secret TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
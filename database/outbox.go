@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Event types written to the outbox
+const (
+	EventAccountCreated       = "account.created"
+	EventTransactionCompleted = "transaction.completed"
+	EventAccountAdjusted      = "account.adjusted"
+	EventWalletOpened         = "account.wallet_opened"
+	EventRecurringFailed      = "recurring_transfer.failed"
+)
+
+// OutboxMessage is an event recorded in the outbox, waiting to be published
+// PublishedAt is zero until the broker acknowledged the event
+type OutboxMessage struct {
+	ID           int64
+	EventID      string
+	EventType    string
+	PartitionKey string
+	Payload      json.RawMessage
+	CreatedAt    time.Time
+	PublishedAt  time.Time
+}
+
+// enqueueEvent records an event in the outbox as part of tx
+// The event commits or rolls back together with the change it describes
+// The partition key keeps all events of one account ordered on the broker
+func enqueueEvent(tx *sql.Tx, eventType string, accountID int64, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	eventID, err := newEventID()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO outbox_events (event_id, event_type, partition_key, payload) VALUES ($1, $2, $3, $4)`,
+		eventID, eventType, strconv.FormatInt(accountID, 10), data,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// newEventID returns a random RFC 4122 version 4 UUID
+func newEventID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate event ID: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// OutboxRepository reads and acknowledges outbox events for the relay
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository creates a new outbox repository instance
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// PublishPending hands the oldest unpublished events to publish and marks them published on success
+// Parameters:
+//   - ctx: Context bounding the whole batch
+//   - limit: Maximum number of events in the batch
+//   - publish: Delivers the batch; it must return nil only once every event is acknowledged
+//
+// Returns:
+//   - int: Number of events published (0 if none were pending)
+//   - error: Database or publish error; the batch stays pending and is retried
+//
+// Database behavior:
+//   - Rows are locked with FOR UPDATE SKIP LOCKED, so several relays can run concurrently
+//     without publishing the same batch twice
+//   - Events are marked published in the same transaction that locked them; a crash after
+//     publishing but before commit republishes the batch (at-least-once, deduplicated by event ID)
+func (r *OutboxRepository) PublishPending(ctx context.Context, limit int, publish func(ctx context.Context, messages []OutboxMessage) error) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, event_id, event_type, partition_key, payload, created_at
+		FROM outbox_events
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	var messages []OutboxMessage
+	var ids []int64
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(&m.ID, &m.EventID, &m.EventType, &m.PartitionKey, &m.Payload, &m.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		messages = append(messages, m)
+		ids = append(ids, m.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(ctx, messages); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE outbox_events SET published_at = NOW() WHERE id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("failed to mark events published: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox batch: %w", err)
+	}
+	return len(messages), nil
+}
+
+// TransactionEvents returns the events recorded for a transaction, oldest first, published or not
+// Served by the index on the transaction.completed payload's id
+func (r *OutboxRepository) TransactionEvents(ctx context.Context, transactionID int64) ([]OutboxMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, event_id, event_type, partition_key, payload, created_at, published_at
+		FROM outbox_events
+		WHERE event_type = $1 AND (payload->>'id')::bigint = $2
+		ORDER BY id
+	`, EventTransactionCompleted, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction events: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []OutboxMessage{}
+	for rows.Next() {
+		var m OutboxMessage
+		var publishedAt sql.NullTime
+		if err := rows.Scan(&m.ID, &m.EventID, &m.EventType, &m.PartitionKey, &m.Payload, &m.CreatedAt, &publishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		m.PublishedAt = publishedAt.Time
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transaction events: %w", err)
+	}
+	return messages, nil
+}
+
+// CountPending returns the number of events not yet published
+func (r *OutboxRepository) CountPending(ctx context.Context) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox_events WHERE published_at IS NULL`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending outbox events: %w", err)
+	}
+	return count, nil
+}
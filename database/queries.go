@@ -1,16 +1,22 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"internal-transfers/models"
+	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 // AccountRepository handles account-related database operations
 type AccountRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  *Replica
+	failover *Failover
 }
 
 // NewAccountRepository creates a new account repository instance
@@ -23,28 +29,84 @@ func NewAccountRepository(db *sql.DB) *AccountRepository {
 	return &AccountRepository{db: db}
 }
 
+// WithReplica routes GetAccount and AccountExists to a read replica (nil for none)
+func (r *AccountRepository) WithReplica(replica *Replica) *AccountRepository {
+	r.replica = replica
+	return r
+}
+
+// WithFailover retries GetAccount and AccountExists on the new primary after a failover (nil for
+// none)
+func (r *AccountRepository) WithFailover(failover *Failover) *AccountRepository {
+	r.failover = failover
+	return r
+}
+
 // CreateAccount inserts a new account record into the database
 // This method creates a new account with the specified ID and initial balance
 // Parameters:
-//   - accountID: Unique identifier for the new account (must be positive)
-//   - initialBalance: Starting balance for the account (should be non-negative)
+//   - account: The account to open, with
+//     AccountID: Unique identifier for the new account (must be positive)
+//     InitialBalance: Starting balance for the account (should be non-negative)
+//     Currency: ISO 4217 code, or empty for an account without a currency
+//     ExternalID: The account's ID in an external system, or empty for none
+//     CreationToken: The client's creation token, or empty for none
 //
 // Returns:
 //   - error: Database error if insertion fails, nil on success
 //
 // Database behavior:
 //   - Inserts into accounts table with provided ID and balance
-//   - Will fail if account ID already exists (database constraint violation)
+//   - Records an account.created event in the outbox and the opening balance snapshot within the
+//     same transaction
+//   - Returns "account already exists" if the ID is taken (unique violation, e.g. a concurrent create)
+//     "external ID already exists" if another account has the external ID, and "creation token
+//     already used" if another account was created with the token
 //   - Uses precise decimal arithmetic for monetary values
-func (r *AccountRepository) CreateAccount(accountID int64, initialBalance decimal.Decimal) error {
+func (r *AccountRepository) CreateAccount(account models.NewAccount) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO accounts (account_id, balance)
-		VALUES ($1, $2)
+		INSERT INTO accounts (account_id, balance, initial_balance, currency, external_id, creation_token)
+		VALUES ($1, $2, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+		RETURNING created_at
 	`
-	_, err := r.db.Exec(query, accountID, initialBalance)
+	var createdAt time.Time
+	err = tx.QueryRow(query, account.AccountID, account.InitialBalance, account.Currency, account.ExternalID, account.CreationToken).Scan(&createdAt)
 	if err != nil {
+		if isUniqueViolationOf(err, "accounts_external_id_key") {
+			return fmt.Errorf("external ID already exists")
+		}
+		if isUniqueViolationOf(err, "accounts_creation_token_key") {
+			return fmt.Errorf("creation token already used")
+		}
+		if isUniqueViolation(err) {
+			return fmt.Errorf("account already exists")
+		}
 		return fmt.Errorf("failed to create account: %w", err)
 	}
+
+	event := models.AccountCreatedEvent{
+		AccountID:      account.AccountID,
+		InitialBalance: account.InitialBalance.String(),
+		Currency:       account.Currency,
+		ExternalID:     account.ExternalID,
+		CreatedAt:      createdAt,
+	}
+	if err := enqueueEvent(tx, EventAccountCreated, account.AccountID, event); err != nil {
+		return err
+	}
+	if err := addSnapshotTx(tx, models.BalanceSnapshot{AccountID: account.AccountID, Balance: account.InitialBalance, Source: models.BalanceSnapshotOpened}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit account creation: %w", err)
+	}
 	return nil
 }
 
@@ -54,7 +116,8 @@ func (r *AccountRepository) CreateAccount(accountID int64, initialBalance decima
 //   - accountID: The unique identifier of the account to retrieve
 //
 // Returns:
-//   - *models.Account: Account object with ID and current balance if found
+//   - *models.Account: Account object with ID, current balance, latest sequence number, status,
+//     metadata and tags if found
 //   - error: "account not found" if ID doesn't exist, other database errors possible
 //
 // Database behavior:
@@ -63,13 +126,19 @@ func (r *AccountRepository) CreateAccount(accountID int64, initialBalance decima
 //   - Balance is returned as precise decimal value
 func (r *AccountRepository) GetAccount(accountID int64) (*models.Account, error) {
 	query := `
-		SELECT account_id, balance
+		SELECT ` + accountColumns + `
 		FROM accounts
 		WHERE account_id = $1
 	`
 
 	var account models.Account
-	err := r.db.QueryRow(query, accountID).Scan(&account.AccountID, &account.Balance)
+	err := r.failover.read(func() error {
+		return r.replica.read(r.db, func(db *sql.DB) error {
+			var err error
+			account, err = scanAccount(db.QueryRow(query, accountID))
+			return err
+		})
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("account not found")
@@ -97,7 +166,11 @@ func (r *AccountRepository) AccountExists(accountID int64) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM accounts WHERE account_id = $1)`
 
 	var exists bool
-	err := r.db.QueryRow(query, accountID).Scan(&exists)
+	err := r.failover.read(func() error {
+		return r.replica.read(r.db, func(db *sql.DB) error {
+			return db.QueryRow(query, accountID).Scan(&exists)
+		})
+	})
 	if err != nil {
 		return false, fmt.Errorf("failed to check account existence: %w", err)
 	}
@@ -105,9 +178,266 @@ func (r *AccountRepository) AccountExists(accountID int64) (bool, error) {
 	return exists, nil
 }
 
+// SetAccountStatus freezes or unfreezes an account
+// Parameters:
+//   - accountID: The account to update
+//   - status: models.AccountActive or models.AccountFrozen (validated by caller)
+//
+// Returns:
+//   - *models.Account: The account after the update
+//   - error: "account not found" or a database error
+//
+// Database behavior:
+//   - A single UPDATE takes the row lock, so the change waits for in-flight transfers on the
+//     account and every transfer that starts afterwards sees the new status
+//   - The version is incremented only if the status changes
+func (r *AccountRepository) SetAccountStatus(accountID int64, status string) (*models.Account, error) {
+	account, err := scanAccount(r.db.QueryRow(
+		`UPDATE accounts SET
+			status = $2,
+			version = version + CASE WHEN status = $2 THEN 0 ELSE 1 END,
+			updated_at = NOW()
+		 WHERE account_id = $1
+		 RETURNING `+accountColumns,
+		accountID, status,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("account not found")
+		}
+		return nil, fmt.Errorf("failed to update account status: %w", err)
+	}
+	return &account, nil
+}
+
+// SetOverdraftLimit changes how far below zero an account's balance may go
+// Parameters:
+//   - accountID: The account to update
+//   - limit: The new overdraft limit (non-negative, validated by caller)
+//
+// Returns:
+//   - *models.Account: The account after the update
+//   - error: "account not found", "balance below overdraft limit" if the account is overdrawn by
+//     more than limit, or a database error
+//
+// Database behavior:
+//   - A single UPDATE takes the row lock, so the change waits for in-flight transfers on the
+//     account; the balance check constraint rejects a limit the current balance already exceeds
+//   - The version is incremented only if the limit changes
+func (r *AccountRepository) SetOverdraftLimit(accountID int64, limit decimal.Decimal) (*models.Account, error) {
+	account, err := scanAccount(r.db.QueryRow(
+		`UPDATE accounts SET
+			overdraft_limit = $2,
+			version = version + CASE WHEN overdraft_limit = $2 THEN 0 ELSE 1 END,
+			updated_at = NOW()
+		 WHERE account_id = $1
+		 RETURNING `+accountColumns,
+		accountID, limit,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("account not found")
+		}
+		if isCheckViolation(err) {
+			return nil, fmt.Errorf("balance below overdraft limit")
+		}
+		return nil, fmt.Errorf("failed to update overdraft limit: %w", err)
+	}
+	return &account, nil
+}
+
+// GetAccountByExternalID retrieves an account by its external ID
+// Returns "account not found" if no account has it; served by the external ID's unique index
+func (r *AccountRepository) GetAccountByExternalID(externalID string) (*models.Account, error) {
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE external_id = $1
+	`
+
+	var account models.Account
+	err := r.failover.read(func() error {
+		return r.replica.read(r.db, func(db *sql.DB) error {
+			var err error
+			account, err = scanAccount(db.QueryRow(query, externalID))
+			return err
+		})
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("account not found")
+		}
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	return &account, nil
+}
+
+// UpdateAccount applies a metadata, tag and external ID change to an account
+// Parameters:
+//   - accountID: The account to update
+//   - update: Validated change (see UpdateAccountRequest.Validate)
+//
+// Returns:
+//   - *models.Account: The account after the update
+//   - error: "account not found", "account version mismatch" if update.ExpectedVersion is set and
+//     the account has changed since, "external ID already exists" if another account has the
+//     new external ID, or a database error
+//
+// Database behavior:
+//   - Metadata keys are merged and removed inside a single UPDATE, so concurrent updates of
+//     different keys never overwrite each other
+//   - The version check and increment happen in the same statement under the row lock
+func (r *AccountRepository) UpdateAccount(accountID int64, update models.AccountUpdate) (*models.Account, error) {
+	set, err := json.Marshal(update.SetMetadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if update.SetMetadata == nil {
+		set = []byte("{}")
+	}
+	remove := update.RemoveMetadata
+	if remove == nil {
+		remove = []string{}
+	}
+	var tags interface{}
+	if update.Tags != nil {
+		tags = update.Tags
+	}
+	var externalID interface{}
+	if update.ExternalID != nil {
+		externalID = *update.ExternalID
+	}
+
+	account, err := scanAccount(r.db.QueryRow(
+		`UPDATE accounts SET
+			metadata = (metadata - $2::text[]) || $3::jsonb,
+			tags = COALESCE($4::text[], tags),
+			external_id = CASE WHEN $6::text IS NULL THEN external_id ELSE NULLIF($6::text, '') END,
+			version = version + 1,
+			updated_at = NOW()
+		 WHERE account_id = $1 AND ($5 = 0 OR version = $5)
+		 RETURNING `+accountColumns,
+		accountID, remove, string(set), tags, update.ExpectedVersion, externalID,
+	))
+	if err != nil {
+		if isUniqueViolationOf(err, "accounts_external_id_key") {
+			return nil, fmt.Errorf("external ID already exists")
+		}
+		if err == sql.ErrNoRows {
+			exists, existsErr := r.AccountExists(accountID)
+			if existsErr != nil {
+				return nil, existsErr
+			}
+			if exists {
+				return nil, fmt.Errorf("account version mismatch")
+			}
+			return nil, fmt.Errorf("account not found")
+		}
+		return nil, fmt.Errorf("failed to update account: %w", err)
+	}
+	return &account, nil
+}
+
+// ListAccounts returns a page of the accounts matching the filter ordered by account ID, and the
+// total number of matching accounts
+// The count and the page are read in one snapshot, so the total always agrees with the page
+// A tag filter is served by the GIN index on tags; filter.AfterID by the primary key
+func (r *AccountRepository) ListAccounts(filter models.AccountFilter, limit, offset int) ([]models.Account, int, error) {
+	args := accountFilterArgs(filter)
+
+	var total int
+	accounts := []models.Account{}
+	err := InSnapshot(context.Background(), r.db, func(tx *sql.Tx) error {
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM accounts WHERE `+accountFilterClause, args...).Scan(&total); err != nil {
+			return fmt.Errorf("failed to count accounts: %w", err)
+		}
+
+		rows, err := tx.Query(`
+			SELECT `+accountColumns+`
+			FROM accounts
+			WHERE `+accountFilterClause+` AND account_id > $9
+			ORDER BY account_id
+			LIMIT $7 OFFSET $8
+		`, append(args, limit, offset, filter.AfterID)...)
+		if err != nil {
+			return fmt.Errorf("failed to list accounts: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			account, err := scanAccount(rows)
+			if err != nil {
+				return fmt.Errorf("failed to scan account: %w", err)
+			}
+			accounts = append(accounts, account)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to list accounts: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return accounts, total, nil
+}
+
+// accountFilterClause is the WHERE condition for an AccountFilter, bound by accountFilterArgs
+// Unset filter fields are passed as empty strings or NULL and match every account
+const accountFilterClause = `($1 = '' OR tags @> ARRAY[$1::text])
+		  AND ($2 = '' OR status = $2)
+		  AND ($3::numeric IS NULL OR balance >= $3)
+		  AND ($4::numeric IS NULL OR balance <= $4)
+		  AND ($5::timestamptz IS NULL OR created_at >= $5)
+		  AND ($6::timestamptz IS NULL OR created_at < $6)`
+
+// accountFilterArgs returns the parameters $1-$6 of accountFilterClause
+func accountFilterArgs(filter models.AccountFilter) []any {
+	args := []any{filter.Tag, filter.Status, nil, nil, nil, nil}
+	if filter.MinBalance != nil {
+		args[2] = *filter.MinBalance
+	}
+	if filter.MaxBalance != nil {
+		args[3] = *filter.MaxBalance
+	}
+	if !filter.CreatedFrom.IsZero() {
+		args[4] = filter.CreatedFrom
+	}
+	if !filter.CreatedBefore.IsZero() {
+		args[5] = filter.CreatedBefore
+	}
+	return args
+}
+
+// accountColumns is the select list read by scanAccount
+// Tags are read as JSON so they scan through database/sql without driver-specific array types
+const accountColumns = `account_id, balance, currency, sequence, status, metadata, to_jsonb(tags), version, created_at, overdraft_limit, held,
+	COALESCE(external_id, ''), initial_balance, COALESCE(creation_token, ''), COALESCE(updated_at, created_at)`
+
+// scanAccount reads one row selected with accountColumns
+func scanAccount(row interface{ Scan(dest ...any) error }) (models.Account, error) {
+	var account models.Account
+	var metadata, tags []byte
+	if err := row.Scan(&account.AccountID, &account.Balance, &account.Currency, &account.Sequence, &account.Status, &metadata, &tags, &account.Version, &account.CreatedAt, &account.OverdraftLimit, &account.Held, &account.ExternalID,
+		&account.InitialBalance, &account.CreationToken, &account.UpdatedAt); err != nil {
+		return account, err
+	}
+	if err := json.Unmarshal(metadata, &account.Metadata); err != nil {
+		return account, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	if err := json.Unmarshal(tags, &account.Tags); err != nil {
+		return account, fmt.Errorf("failed to decode tags: %w", err)
+	}
+	return account, nil
+}
+
 // TransactionRepository handles transaction-related database operations
 type TransactionRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	replica  *Replica
+	failover *Failover
+	retry    RetryConfig
+	locking  string
 }
 
 // NewTransactionRepository creates a new transaction repository instance
@@ -117,92 +447,656 @@ type TransactionRepository struct {
 //
 // Returns: Configured TransactionRepository ready for use
 func NewTransactionRepository(db *sql.DB) *TransactionRepository {
-	return &TransactionRepository{db: db}
+	return &TransactionRepository{db: db, retry: DefaultRetryConfig(), locking: LockingPessimistic}
+}
+
+// WithRetry sets how transfers aborted by a serialization failure or deadlock are retried
+func (r *TransactionRepository) WithRetry(config RetryConfig) *TransactionRepository {
+	r.retry = config
+	return r
+}
+
+// WithReplica routes ListTransactions and SearchTransactions to a read replica (nil for none)
+func (r *TransactionRepository) WithReplica(replica *Replica) *TransactionRepository {
+	r.replica = replica
+	return r
+}
+
+// WithFailover retries transfers that failed before their commit, ListTransactions and
+// SearchTransactions on the new primary after a failover (nil for none)
+func (r *TransactionRepository) WithFailover(failover *Failover) *TransactionRepository {
+	r.failover = failover
+	return r
+}
+
+// WithLocking sets how transfers protect the account rows they update (LockingPessimistic or
+// LockingOptimistic)
+func (r *TransactionRepository) WithLocking(mode string) *TransactionRepository {
+	r.locking = mode
+	return r
 }
 
 // CreateTransaction performs an atomic money transfer between two accounts
 // This method implements a complete transfer operation with balance validation and record keeping
 // Parameters:
-//   - sourceAccountID: Account ID to debit the amount from
-//   - destinationAccountID: Account ID to credit the amount to
-//   - amount: Amount to transfer (must be positive)
+//   - transfer: Source and destination accounts, the amount (positive, already rounded to
+//     models.AmountScale), and the rounding policy, transfer type and value date to record;
+//     cross-currency transfers also carry the converted amount and the FX rate
 //
 // Returns:
+//   - *models.Transaction: The committed transaction including its ID and per-account sequence numbers
 //   - error: Specific error messages for business rule violations or database issues
 //
 // Business rules enforced:
 //   - Source account must exist and have sufficient balance
 //   - Destination account must exist
+//   - Neither account may be frozen (compliance hold)
+//   - Both accounts must hold the same currency unless the transfer converts, and amounts may not
+//     have more decimal places than their currency allows
+//   - A transfer naming a currency moves, on each side whose own currency differs, the account's
+//     wallet in that currency instead of its primary balance (see models.Transfer.Wallet); a
+//     wallet must exist and cover the debit, and the source's limits do not apply to it
 //   - Amount must be positive (validated by caller)
 //
 // Database behavior:
 //   - Uses database transaction for atomicity (all operations succeed or all fail)
-//   - Locks both account rows with FOR UPDATE to prevent race conditions
+//   - Locks both account rows with FOR UPDATE to prevent race conditions; in optimistic locking
+//     mode they are read without locks and the balance updates only apply if neither account
+//     changed since, otherwise the transfer is retried like a serialization failure
 //   - Updates both account balances and creates transaction record
+//   - Increments each account's sequence counter under the row lock, so every ledger
+//     movement on an account gets a strictly increasing, gap-free sequence number
+//   - Records a transaction.completed event in the outbox within the same transaction
 //   - Automatically rolls back on any error, commits only on complete success
+//   - Runs the whole transaction again, after a jittered backoff, when Postgres aborts it with a
+//     serialization failure or deadlock, or an optimistic balance update finds an account changed
+//     (see RetryConfig)
 //
 // Possible error returns:
 //   - "source account not found": Source account doesn't exist
 //   - "destination account not found": Destination account doesn't exist
-//   - "insufficient balance": Source account has less than transfer amount
+//   - "source wallet not found" / "destination wallet not found": An account has no wallet in the
+//     transfer's currency
+//   - "insufficient balance": Source account's balance plus overdraft limit (or its wallet's
+//     balance) is less than the amount
+//   - "source account frozen" / "destination account frozen": An account is under a compliance hold
+//   - "currency mismatch" / "amount exceeds currency precision" / "amount below currency minimum" /
+//     "amount above currency maximum": See models.Transfer.CheckCurrencies
+//   - "transfer amount limit exceeded" / "daily amount limit exceeded" / "daily count limit
+//     exceeded": The source account's limits refuse the debit (see models.TransferLimits.Check)
+//   - "duplicate reference": The source account already sent a transfer with the same reference
+//   - "dependency not found" / "dependency pending" / "dependency failed": transfer.DependsOn
+//     names a transaction that does not exist or has not completed (see Transaction.CheckDependency)
+//   - "transaction conflict": The transfer still conflicted with concurrent ones after every retry
 //   - Various database errors for connection/constraint issues
-func (r *TransactionRepository) CreateTransaction(sourceAccountID, destinationAccountID int64, amount decimal.Decimal) error {
+func (r *TransactionRepository) CreateTransaction(transfer models.Transfer) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := retryTx(r.retry, func() error {
+		var err error
+		transaction, err = r.createTransaction(transfer)
+		return r.failover.check(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// createTransaction is one attempt of CreateTransaction
+func (r *TransactionRepository) createTransaction(transfer models.Transfer) (*models.Transaction, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Check source account balance and lock the row
-	var sourceBalance decimal.Decimal
-	err = tx.QueryRow("SELECT balance FROM accounts WHERE account_id = $1 FOR UPDATE", sourceAccountID).Scan(&sourceBalance)
+	transaction, err := transferTx(tx, transfer, r.locking)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errCommitFailed, err)
+	}
+
+	return transaction, nil
+}
+
+// transferTx moves money between two accounts inside the caller's database transaction
+// Shared by CreateTransaction and settlement returns; see CreateTransaction for the rules and
+// error messages. The caller commits or rolls back
+// mode selects how the account rows are protected; with LockingOptimistic an account changed
+// since it was read fails the transfer with errBalanceConflict
+func transferTx(tx *sql.Tx, transfer models.Transfer, mode string) (*models.Transaction, error) {
+	transfer = transfer.WithDefaults(time.Now())
+	sourceAccountID, destinationAccountID, amount := transfer.SourceAccountID, transfer.DestinationAccountID, transfer.Amount
+
+	// Time spent in the locking statements, reported as the transaction's lock wait
+	var lockWait time.Duration
+
+	// The dependency is locked first, like a settlement return locks the transfer it reverses, so
+	// it cannot be reversed before this transfer commits
+	if transfer.DependsOn != 0 {
+		locking := time.Now()
+		err := checkDependencyTx(tx, transfer.DependsOn)
+		lockWait += time.Since(locking)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A captured hold stops reserving its amount before the balance check
+	if transfer.HoldID != 0 {
+		if err := captureHoldTx(tx, transfer); err != nil {
+			return nil, err
+		}
+	}
+
+	// Pessimistic locking locks the account rows as it reads them; optimistic locking reads them
+	// unlocked and guards the balance updates with the sequence (bumped by every transfer), the
+	// version (bumped by status and overdraft changes and manual adjustments) and the held amount
+	// it read. Under pessimistic locking the rows cannot change after being read, so the guards
+	// always hold
+	lockClause := " FOR UPDATE"
+	if mode == LockingOptimistic {
+		lockClause = ""
+	}
+
+	// Check source account balance, overdraft limit, holds and status, and lock the row
+	var sourceBalance, sourceOverdraft, sourceHeld decimal.Decimal
+	var sourceStatus, sourceCurrency string
+	var sourceReadSequence, sourceVersion int64
+	locking := time.Now()
+	err := tx.QueryRow("SELECT balance, overdraft_limit, held, status, currency, sequence, version FROM accounts WHERE account_id = $1"+lockClause, sourceAccountID).
+		Scan(&sourceBalance, &sourceOverdraft, &sourceHeld, &sourceStatus, &sourceCurrency, &sourceReadSequence, &sourceVersion)
+	lockWait += time.Since(locking)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("source account not found")
+			return nil, fmt.Errorf("source account not found")
 		}
-		return fmt.Errorf("failed to get source account: %w", err)
+		return nil, fmt.Errorf("failed to get source account: %w", err)
 	}
+	if sourceStatus == models.AccountFrozen {
+		return nil, fmt.Errorf("source account frozen")
+	}
+
+	// A wallet leg moves the account's wallet instead of its primary balance, which then only
+	// takes the next sequence number
+	sourceWallet, sourceDebit := transfer.Wallet(sourceCurrency), amount
+	if sourceWallet != "" {
+		sourceDebit = decimal.Zero
+		if err := moveWalletTx(tx, "source", sourceAccountID, sourceWallet, amount.Neg()); err != nil {
+			return nil, err
+		}
+	} else {
+		// Check if source account has sufficient available balance: its overdraft limit counts,
+		// the funds reserved by its active holds do not
+		if sourceBalance.Add(sourceOverdraft).Sub(sourceHeld).LessThan(amount) {
+			return nil, fmt.Errorf("insufficient balance")
+		}
 
-	// Check if source account has sufficient balance
-	if sourceBalance.LessThan(amount) {
-		return fmt.Errorf("insufficient balance")
+		// Check the source account's transfer limits, which are in its currency; reversing a
+		// returned transfer is not limited
+		if transfer.ReturnOf == 0 {
+			if err := checkLimitsTx(tx, sourceAccountID, amount, time.Now()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	// Lock destination account
-	var destinationBalance decimal.Decimal
-	err = tx.QueryRow("SELECT balance FROM accounts WHERE account_id = $1 FOR UPDATE", destinationAccountID).Scan(&destinationBalance)
+	// Lock destination account and check its status and currency
+	var destinationStatus, destinationCurrency string
+	var destinationVersion int64
+	locking = time.Now()
+	err = tx.QueryRow("SELECT status, currency, version FROM accounts WHERE account_id = $1"+lockClause, destinationAccountID).
+		Scan(&destinationStatus, &destinationCurrency, &destinationVersion)
+	lockWait += time.Since(locking)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("destination account not found")
+			return nil, fmt.Errorf("destination account not found")
+		}
+		return nil, fmt.Errorf("failed to get destination account: %w", err)
+	}
+	if destinationStatus == models.AccountFrozen {
+		return nil, fmt.Errorf("destination account frozen")
+	}
+	if err := transfer.CheckCurrencies(sourceCurrency, destinationCurrency); err != nil {
+		return nil, err
+	}
+	destinationWallet, destinationCredit := transfer.Wallet(destinationCurrency), transfer.Credit()
+	if destinationWallet != "" {
+		destinationCredit = decimal.Zero
+		if err := moveWalletTx(tx, "destination", destinationAccountID, destinationWallet, transfer.Credit()); err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("failed to get destination account: %w", err)
 	}
 
-	// Update source account balance
-	_, err = tx.Exec("UPDATE accounts SET balance = balance - $1, updated_at = NOW() WHERE account_id = $2", amount, sourceAccountID)
+	// Update source account balance and take its next ledger sequence number
+	var sourceSequence int64
+	var sourceBalanceAfter decimal.Decimal
+	err = tx.QueryRow(
+		`UPDATE accounts SET balance = balance - $1, sequence = sequence + 1, updated_at = NOW()
+		 WHERE account_id = $2 AND sequence = $3 AND version = $4 AND held = $5
+		 RETURNING sequence, balance`,
+		sourceDebit, sourceAccountID, sourceReadSequence, sourceVersion, sourceHeld,
+	).Scan(&sourceSequence, &sourceBalanceAfter)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("source account %d: %w", sourceAccountID, errBalanceConflict)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update source account: %w", err)
+		return nil, fmt.Errorf("failed to update source account: %w", err)
 	}
 
-	// Update destination account balance
-	_, err = tx.Exec("UPDATE accounts SET balance = balance + $1, updated_at = NOW() WHERE account_id = $2", amount, destinationAccountID)
+	// Update destination account balance (by the converted amount for cross-currency transfers)
+	// and take its next ledger sequence number
+	var destinationSequence int64
+	var destinationBalanceAfter decimal.Decimal
+	err = tx.QueryRow(
+		`UPDATE accounts SET balance = balance + $1, sequence = sequence + 1, updated_at = NOW()
+		 WHERE account_id = $2 AND version = $3
+		 RETURNING sequence, balance`,
+		destinationCredit, destinationAccountID, destinationVersion,
+	).Scan(&destinationSequence, &destinationBalanceAfter)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("destination account %d: %w", destinationAccountID, errBalanceConflict)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update destination account: %w", err)
+		return nil, fmt.Errorf("failed to update destination account: %w", err)
 	}
 
 	// Insert transaction record
-	_, err = tx.Exec(
-		"INSERT INTO transactions (source_account_id, destination_account_id, amount) VALUES ($1, $2, $3)",
-		sourceAccountID, destinationAccountID, amount,
-	)
+	transaction := &models.Transaction{
+		SourceAccountID:      sourceAccountID,
+		DestinationAccountID: destinationAccountID,
+		Amount:               amount,
+		SourceSequence:       sourceSequence,
+		DestinationSequence:  destinationSequence,
+		RoundingPolicy:       transfer.RoundingPolicy,
+		TransferType:         transfer.TransferType,
+		ValueDate:            transfer.ValueDate,
+		Status:               models.TransactionCompleted,
+		SettlementStatus:     models.SettlementUnsettled,
+		ReturnOf:             transfer.ReturnOf,
+		DestinationAmount:    transfer.Credit(),
+		FXRate:               transfer.FXRate,
+		FXRateTimestamp:      transfer.FXRateTimestamp,
+		Reference:            transfer.Reference,
+		Memo:                 transfer.Memo,
+		DependsOn:            transfer.DependsOn,
+		SourceWallet:         sourceWallet,
+		DestinationWallet:    destinationWallet,
+	}
+	// The FX columns stay NULL for same-currency transfers, and a transfer that is not backdated
+	// takes effect when it is recorded; missing annotations are stored as NULL
+	var fxRate, destinationAmount, fxRateTimestamp, effectiveAt interface{}
+	if transaction.Converted() {
+		fxRate, destinationAmount, fxRateTimestamp = transfer.FXRate, transfer.DestinationAmount, transfer.FXRateTimestamp
+	}
+	if !transfer.EffectiveAt.IsZero() {
+		effectiveAt = transfer.EffectiveAt
+	}
+	err = tx.QueryRow(
+		`INSERT INTO transactions (source_account_id, destination_account_id, amount, source_sequence, destination_sequence,
+		                           rounding_policy, transfer_type, value_date, return_of,
+		                           destination_amount, fx_rate, fx_rate_timestamp, effective_at, reference, memo, depends_on,
+		                           source_wallet, destination_wallet)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, 0), $10, $11, $12, COALESCE($13, NOW()), NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, 0),
+		         NULLIF($17, ''), NULLIF($18, ''))
+		 RETURNING id, created_at, effective_at`,
+		sourceAccountID, destinationAccountID, amount, sourceSequence, destinationSequence,
+		string(transfer.RoundingPolicy), transfer.TransferType, transfer.ValueDate, transfer.ReturnOf,
+		destinationAmount, fxRate, fxRateTimestamp, effectiveAt, transfer.Reference, transfer.Memo, transfer.DependsOn,
+		sourceWallet, destinationWallet,
+	).Scan(&transaction.ID, &transaction.CreatedAt, &transaction.EffectiveAt)
 	if err != nil {
-		return fmt.Errorf("failed to create transaction record: %w", err)
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("duplicate reference")
+		}
+		return nil, fmt.Errorf("failed to create transaction record: %w", err)
+	}
+	if transfer.HoldID != 0 {
+		if _, err := tx.Exec(`UPDATE holds SET transaction_id = $2 WHERE id = $1`, transfer.HoldID, transaction.ID); err != nil {
+			return nil, fmt.Errorf("failed to record hold capture: %w", err)
+		}
 	}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// Record the primary balances the transfer left for the balance history
+	for _, snapshot := range []models.BalanceSnapshot{
+		{AccountID: sourceAccountID, Balance: sourceBalanceAfter, Sequence: sourceSequence},
+		{AccountID: destinationAccountID, Balance: destinationBalanceAfter, Sequence: destinationSequence},
+	} {
+		if transaction.Wallet(snapshot.AccountID) != "" {
+			continue
+		}
+		snapshot.Source, snapshot.TransactionID = models.BalanceSnapshotTransfer, transaction.ID
+		if err := addSnapshotTx(tx, snapshot); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	// Record the event in the same transaction; it is published only if the transfer commits
+	if err := enqueueEvent(tx, EventTransactionCompleted, sourceAccountID, models.NewTransactionResponse(*transaction)); err != nil {
+		return nil, err
+	}
+
+	transaction.LockWait = lockWait
+	return transaction, nil
+}
+
+// checkDependencyTx locks the transaction a transfer depends on against reversal and checks its
+// status; see models.Transaction.CheckDependency
+func checkDependencyTx(tx *sql.Tx, transactionID int64) error {
+	var dependency models.Transaction
+	err := tx.QueryRow("SELECT status FROM transactions WHERE id = $1 FOR SHARE", transactionID).Scan(&dependency.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("dependency not found")
+		}
+		return fmt.Errorf("failed to get dependency: %w", err)
+	}
+	return dependency.CheckDependency()
+}
+
+// ListTransactions retrieves the transaction history of an account
+// This method returns transfers in either direction, newest first
+// Parameters:
+//   - accountID: The account whose history is requested
+//   - limit: Maximum number of transactions to return
+//
+// Returns:
+//   - []models.Transaction: Transactions ordered by descending ID (empty if none)
+//   - error: Database error if the query fails
+//
+// Database behavior:
+//   - Served by the source/destination account indexes
+func (r *TransactionRepository) ListTransactions(accountID int64, limit int) ([]models.Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE source_account_id = $1 OR destination_account_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	var transactions []models.Transaction
+	err := r.failover.read(func() error {
+		return r.replica.read(r.db, func(db *sql.DB) error {
+			rows, err := db.Query(query, accountID, limit)
+			if err != nil {
+				return fmt.Errorf("failed to list transactions: %w", err)
+			}
+			transactions, err = scanTransactions(rows)
+			return err
+		})
+	})
+	return transactions, err
+}
+
+// SearchTransactions retrieves an account's transactions matching a filter
+// Parameters:
+//   - filter: The account, the reference (exact) and memo (case-insensitive substring) to match,
+//     the effective period [EffectiveFrom, EffectiveTo), the creation period [CreatedFrom,
+//     CreatedBefore), the bounds of the amount moved on the account, the direction and the
+//     counterparty; empty fields match every transaction. A non-zero After pages after that
+//     position in the filter's order
+//   - limit: Maximum number of transactions to return
+//
+// Returns:
+//   - []models.Transaction: Matching transactions newest first, by descending (created_at, id),
+//     or oldest first by ascending (created_at, id) (empty if none)
+//   - error: Database error if the query fails
+//
+// Database behavior:
+//   - Each side of the transfer is read from its (account, created_at, id) index, starting at the
+//     page's position or the creation period, and the two are merged, so deep pages of busy
+//     accounts stay fast; a direction skips the other side
+//   - Counterparty searches are served by the (account, counterparty, created_at, id) indexes and
+//     reference searches by the partial (account, reference) indexes; amount and memo conditions
+//     are checked on the account's rows as they are read in order
+func (r *TransactionRepository) SearchTransactions(filter models.TransactionFilter, limit int) ([]models.Transaction, error) {
+	var from, to, createdFrom, createdBefore, afterCreated, minAmount, maxAmount interface{}
+	for _, bound := range []struct {
+		value  time.Time
+		target *interface{}
+	}{
+		{filter.EffectiveFrom, &from}, {filter.EffectiveTo, &to},
+		{filter.CreatedFrom, &createdFrom}, {filter.CreatedBefore, &createdBefore},
+		{filter.After.CreatedAt, &afterCreated},
+	} {
+		if !bound.value.IsZero() {
+			*bound.target = bound.value
+		}
+	}
+	if filter.MinAmount != nil {
+		minAmount = *filter.MinAmount
+	}
+	if filter.MaxAmount != nil {
+		maxAmount = *filter.MaxAmount
+	}
+	order, past := "DESC", "<"
+	if filter.OldestFirst() {
+		order, past = "ASC", ">"
+	}
+
+	// side selects the transactions on one side of the account's transfers; amount is the amount
+	// moved on the account on that side
+	side := func(account, counterparty, amount, excluded string) string {
+		return `
+		(SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE ` + account + ` = $1
+		  AND $14::text <> '` + excluded + `'
+		  AND ($13::bigint = 0 OR ` + counterparty + ` = $13)
+		  AND ($2::text = '' OR reference = $2)
+		  AND ($3::text = '' OR memo ILIKE '%' || $3 || '%' ESCAPE '\')
+		  AND ($4::timestamptz IS NULL OR effective_at >= $4)
+		  AND ($5::timestamptz IS NULL OR effective_at < $5)
+		  AND ($9::timestamptz IS NULL OR created_at >= $9)
+		  AND ($10::timestamptz IS NULL OR created_at < $10)
+		  AND ($11::numeric IS NULL OR ` + amount + ` >= $11)
+		  AND ($12::numeric IS NULL OR ` + amount + ` <= $12)
+		  AND ($6::bigint = 0 OR ($7::timestamptz IS NULL AND id ` + past + ` $6) OR (created_at, id) ` + past + ` ($7, $6))
+		ORDER BY created_at ` + order + `, id ` + order + `
+		LIMIT $8)`
+	}
+	query := side("source_account_id", "destination_account_id", "amount", models.TransactionIncoming) + `
+		UNION ALL` + side("destination_account_id", "source_account_id", "COALESCE(destination_amount, amount)", models.TransactionOutgoing) + `
+		ORDER BY created_at ` + order + `, id ` + order + `
+		LIMIT $8
+	`
+
+	var transactions []models.Transaction
+	err := r.failover.read(func() error {
+		return r.replica.read(r.db, func(db *sql.DB) error {
+			rows, err := db.Query(query, filter.AccountID, filter.Reference, escapeLike(filter.Memo), from, to,
+				filter.After.ID, afterCreated, limit, createdFrom, createdBefore, minAmount, maxAmount,
+				filter.CounterpartyID, filter.Direction)
+			if err != nil {
+				return fmt.Errorf("failed to search transactions: %w", err)
+			}
+			transactions, err = scanTransactions(rows)
+			return err
+		})
+	})
+	return transactions, err
+}
+
+// SearchAllTransactions retrieves the transactions of any account matching a search
+// Parameters:
+//   - search: The accounts (either side), references, memo words, bounds of the amount debited and
+//     creation period [CreatedFrom, CreatedBefore) to match; empty fields match every transaction.
+//     A non-zero After pages after that position
+//   - limit: Maximum number of transactions to return
+//
+// Returns:
+//   - []models.Transaction: Matching transactions newest first, by descending (created_at, id)
+//     (empty if none)
+//   - error: Database error if the query fails
+//
+// Database behavior:
+//   - Memo words are matched with full-text search on the GIN index of the memo's 'simple'
+//     tsvector, so words match whole and ignoring case, without stemming
+//   - References are served by the reference index, account sets by the per-side account
+//     indexes, amount bounds by the amount index and the creation period by the created_at
+//     index; the planner combines the most selective of them with a bitmap scan
+func (r *TransactionRepository) SearchAllTransactions(search models.TransactionSearch, limit int) ([]models.Transaction, error) {
+	var createdFrom, createdBefore, afterCreated, minAmount, maxAmount interface{}
+	for _, bound := range []struct {
+		value  time.Time
+		target *interface{}
+	}{{search.CreatedFrom, &createdFrom}, {search.CreatedBefore, &createdBefore}, {search.After.CreatedAt, &afterCreated}} {
+		if !bound.value.IsZero() {
+			*bound.target = bound.value
+		}
+	}
+	if search.MinAmount != nil {
+		minAmount = *search.MinAmount
+	}
+	if search.MaxAmount != nil {
+		maxAmount = *search.MaxAmount
+	}
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE (COALESCE(cardinality($1::bigint[]), 0) = 0 OR source_account_id = ANY($1) OR destination_account_id = ANY($1))
+		  AND (COALESCE(cardinality($2::text[]), 0) = 0 OR reference = ANY($2))
+		  AND ($3::text = '' OR to_tsvector('simple', COALESCE(memo, '')) @@ plainto_tsquery('simple', $3))
+		  AND ($4::numeric IS NULL OR amount >= $4)
+		  AND ($5::numeric IS NULL OR amount <= $5)
+		  AND ($6::timestamptz IS NULL OR created_at >= $6)
+		  AND ($7::timestamptz IS NULL OR created_at < $7)
+		  AND ($8::bigint = 0 OR ($9::timestamptz IS NULL AND id < $8) OR (created_at, id) < ($9, $8))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $10
+	`
+
+	var transactions []models.Transaction
+	err := r.failover.read(func() error {
+		return r.replica.read(r.db, func(db *sql.DB) error {
+			rows, err := db.Query(query, search.AccountIDs, search.References, search.Text, minAmount, maxAmount,
+				createdFrom, createdBefore, search.After.ID, afterCreated, limit)
+			if err != nil {
+				return fmt.Errorf("failed to search transactions: %w", err)
+			}
+			transactions, err = scanTransactions(rows)
+			return err
+		})
+	})
+	return transactions, err
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally (with ESCAPE '\')
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ListChanges retrieves an account's ledger movements after a sequence number
+// Parameters:
+//   - accountID: The account whose movements are requested
+//   - sinceSeq: Only movements with a greater account sequence number are returned
+//   - limit: Maximum number of transactions to return
+//
+// Returns:
+//   - []models.Transaction: Transactions ordered by the account's sequence number (empty if none)
+//   - error: Database error if the query fails
+//
+// Database behavior:
+//   - Served by the unique (account, sequence) indexes on both sides of the transfer
+//   - Transactions committed before sequence numbers were introduced have none and are never returned
+func (r *TransactionRepository) ListChanges(accountID, sinceSeq int64, limit int) ([]models.Transaction, error) {
+	query := `
+		SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE (source_account_id = $1 AND source_sequence > $2)
+		   OR (destination_account_id = $1 AND destination_sequence > $2)
+		ORDER BY CASE WHEN source_account_id = $1 THEN source_sequence ELSE destination_sequence END
+		LIMIT $3
+	`
+
+	rows, err := r.db.Query(query, accountID, sinceSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	return scanTransactions(rows)
+}
+
+// BalanceAsOf reconstructs an account's balance along both time axes of the ledger
+// Parameters:
+//   - accountID: The account whose balance is requested
+//   - recordedAt: Only movements recorded (created_at) at or before this time count
+//   - effectiveAt: Only movements effective (effective_at) at or before this time count
+//
+// Returns:
+//   - decimal.Decimal: The opening balance plus the counted movements of the primary balance
+//     (wallets are not counted); manual adjustments count from when they were recorded
+//   - error: "account not found" if the account does not exist or was created after recordedAt,
+//     or a database error
+//
+// Database behavior:
+//   - The current balance minus the movements that do not count is read in a single statement,
+//     so concurrent transfers cannot skew the result
+func (r *TransactionRepository) BalanceAsOf(accountID int64, recordedAt, effectiveAt time.Time) (decimal.Decimal, error) {
+	query := `
+		SELECT a.balance - COALESCE((
+			SELECT SUM(CASE WHEN t.source_account_id = a.account_id THEN -t.amount
+			                ELSE COALESCE(t.destination_amount, t.amount) END)
+			FROM transactions t
+			WHERE ((t.source_account_id = a.account_id AND t.source_wallet IS NULL)
+			       OR (t.destination_account_id = a.account_id AND t.destination_wallet IS NULL))
+			  AND (t.created_at > $2 OR t.effective_at > $3)
+		), 0) - COALESCE((
+			SELECT SUM(j.amount) FROM adjustments j
+			WHERE j.account_id = a.account_id AND (j.created_at > $2 OR j.created_at > $3)
+		), 0)
+		FROM accounts a
+		WHERE a.account_id = $1 AND a.created_at <= $2
+	`
+
+	var balance decimal.Decimal
+	if err := r.db.QueryRow(query, accountID, recordedAt, effectiveAt).Scan(&balance); err != nil {
+		if err == sql.ErrNoRows {
+			return decimal.Zero, fmt.Errorf("account not found")
+		}
+		return decimal.Zero, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return balance, nil
+}
+
+// transactionColumns is the select list read by scanTransaction
+const transactionColumns = `id, source_account_id, destination_account_id, amount,
+		       COALESCE(source_sequence, 0), COALESCE(destination_sequence, 0), rounding_policy, transfer_type, value_date,
+		       status, settlement_status, COALESCE(return_of, 0), COALESCE(destination_amount, amount), COALESCE(fx_rate, 0),
+		       fx_rate_timestamp, created_at, effective_at, COALESCE(reference, ''), COALESCE(memo, ''), COALESCE(depends_on, 0),
+		       COALESCE(source_wallet, ''), COALESCE(destination_wallet, '')`
+
+// scanTransaction reads one row selected with transactionColumns
+func scanTransaction(row interface{ Scan(dest ...any) error }) (models.Transaction, error) {
+	var t models.Transaction
+	var fxRateTimestamp sql.NullTime
+	err := row.Scan(&t.ID, &t.SourceAccountID, &t.DestinationAccountID, &t.Amount,
+		&t.SourceSequence, &t.DestinationSequence, &t.RoundingPolicy, &t.TransferType, &t.ValueDate,
+		&t.Status, &t.SettlementStatus, &t.ReturnOf, &t.DestinationAmount, &t.FXRate, &fxRateTimestamp, &t.CreatedAt, &t.EffectiveAt,
+		&t.Reference, &t.Memo, &t.DependsOn, &t.SourceWallet, &t.DestinationWallet)
+	t.FXRateTimestamp = fxRateTimestamp.Time
+	return t, err
+}
+
+// scanTransactions reads all rows selected with transactionColumns and closes them
+func scanTransactions(rows *sql.Rows) ([]models.Transaction, error) {
+	defer rows.Close()
+
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+
+	return transactions, nil
 }
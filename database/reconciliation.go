@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"internal-transfers/models"
+)
+
+// ReconciliationRepository implements ReconciliationRepositoryInterface for PostgreSQL
+type ReconciliationRepository struct {
+	db *sql.DB
+}
+
+// NewReconciliationRepository creates a new balance reconciliation repository instance
+func NewReconciliationRepository(db *sql.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+// ReconcileBalances recomputes every account's balance from its initial balance, transactions and
+// manual adjustments; wallet movements are not part of the primary balance
+// The count and the comparison run in one read-only REPEATABLE READ transaction, so transfers
+// committing meanwhile are either fully counted or not at all
+// The comparison is a single pass over accounts and transactions; only the discrepancies are
+// returned
+func (r *ReconciliationRepository) ReconcileBalances(ctx context.Context) (int64, []models.BalanceDiscrepancy, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var accounts int64
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM accounts`).Scan(&accounts); err != nil {
+		return 0, nil, fmt.Errorf("failed to count accounts: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH movements AS (
+			SELECT source_account_id AS account_id, -amount AS delta FROM transactions WHERE source_wallet IS NULL
+			UNION ALL
+			SELECT destination_account_id, COALESCE(destination_amount, amount) FROM transactions WHERE destination_wallet IS NULL
+			UNION ALL
+			SELECT account_id, amount FROM adjustments
+		), totals AS (
+			SELECT account_id, SUM(delta) AS net, COUNT(*) AS movements
+			FROM movements
+			GROUP BY account_id
+		)
+		SELECT a.account_id, a.initial_balance, a.balance,
+		       a.initial_balance + COALESCE(t.net, 0), COALESCE(t.movements, 0)
+		FROM accounts a
+		LEFT JOIN totals t ON t.account_id = a.account_id
+		WHERE a.balance <> a.initial_balance + COALESCE(t.net, 0)
+		ORDER BY a.account_id
+	`)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reconcile balances: %w", err)
+	}
+	defer rows.Close()
+
+	discrepancies := []models.BalanceDiscrepancy{}
+	for rows.Next() {
+		var d models.BalanceDiscrepancy
+		if err := rows.Scan(&d.AccountID, &d.InitialBalance, &d.StoredBalance, &d.ComputedBalance, &d.Transactions); err != nil {
+			return 0, nil, fmt.Errorf("failed to scan discrepancy: %w", err)
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("failed to reconcile balances: %w", err)
+	}
+	return accounts, discrepancies, nil
+}
@@ -0,0 +1,267 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// RecurringRepository implements RecurringRepositoryInterface for PostgreSQL
+type RecurringRepository struct {
+	db *sql.DB
+}
+
+// NewRecurringRepository creates a new recurring transfer repository instance
+func NewRecurringRepository(db *sql.DB) *RecurringRepository {
+	return &RecurringRepository{db: db}
+}
+
+// recurringColumns is the select list read by scanRecurringTransfer
+const recurringColumns = `id, source_account_id, destination_account_id, amount, transfer_type, tenant,
+		       schedule, status, next_run_at, created_at`
+
+// scanRecurringTransfer reads one row selected with recurringColumns
+func scanRecurringTransfer(row interface{ Scan(dest ...any) error }) (models.RecurringTransfer, error) {
+	var r models.RecurringTransfer
+	err := row.Scan(&r.ID, &r.SourceAccountID, &r.DestinationAccountID, &r.Amount, &r.TransferType, &r.Tenant,
+		&r.Schedule, &r.Status, &r.NextRunAt, &r.CreatedAt)
+	return r, err
+}
+
+// CreateRecurringTransfer inserts a rule
+// Parameters:
+//   - ctx: Context bounding the insert
+//   - rule: The rule to store; its ID and creation time are assigned by the database
+//
+// Returns:
+//   - *models.RecurringTransfer: The stored rule
+//   - error: Database error, e.g. a foreign key violation for an unknown account
+func (r *RecurringRepository) CreateRecurringTransfer(ctx context.Context, rule models.RecurringTransfer) (*models.RecurringTransfer, error) {
+	stored, err := scanRecurringTransfer(r.db.QueryRowContext(ctx, `
+		INSERT INTO recurring_transfers (source_account_id, destination_account_id, amount, transfer_type, tenant,
+		                                 schedule, status, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+recurringColumns,
+		rule.SourceAccountID, rule.DestinationAccountID, rule.Amount, rule.TransferType, rule.Tenant,
+		rule.Schedule, rule.Status, rule.NextRunAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recurring transfer: %w", err)
+	}
+	return &stored, nil
+}
+
+// GetRecurringTransfer returns a rule by ID or a "recurring transfer not found" error
+func (r *RecurringRepository) GetRecurringTransfer(ctx context.Context, id int64) (*models.RecurringTransfer, error) {
+	rule, err := scanRecurringTransfer(r.db.QueryRowContext(ctx, `SELECT `+recurringColumns+` FROM recurring_transfers WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("recurring transfer not found")
+		}
+		return nil, fmt.Errorf("failed to get recurring transfer: %w", err)
+	}
+	return &rule, nil
+}
+
+// ListRecurringTransfers returns the rules with an ID above afterID ordered by ID, capped at limit
+func (r *RecurringRepository) ListRecurringTransfers(ctx context.Context, afterID int64, limit int) ([]models.RecurringTransfer, error) {
+	return r.queryRecurringTransfers(ctx, `SELECT `+recurringColumns+` FROM recurring_transfers WHERE id > $1 ORDER BY id LIMIT $2`, afterID, limit)
+}
+
+// SetRecurringTransferStatus changes a rule's status and next run time
+// Returns the updated rule or "recurring transfer not found"
+func (r *RecurringRepository) SetRecurringTransferStatus(ctx context.Context, id int64, status string, nextRunAt time.Time) (*models.RecurringTransfer, error) {
+	rule, err := scanRecurringTransfer(r.db.QueryRowContext(ctx, `
+		UPDATE recurring_transfers SET status = $2, next_run_at = $3
+		WHERE id = $1
+		RETURNING `+recurringColumns, id, status, nextRunAt))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("recurring transfer not found")
+		}
+		return nil, fmt.Errorf("failed to update recurring transfer: %w", err)
+	}
+	return &rule, nil
+}
+
+// DeleteRecurringTransfer removes a rule; its executions are removed by the cascading foreign key
+// Returns "recurring transfer not found" if no rule has the ID
+func (r *RecurringRepository) DeleteRecurringTransfer(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM recurring_transfers WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recurring transfer: %w", err)
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return fmt.Errorf("recurring transfer not found")
+	}
+	return nil
+}
+
+// DueRecurringTransfers returns the active rules due at now, earliest first
+// Served by the partial index on next_run_at of active rules
+func (r *RecurringRepository) DueRecurringTransfers(ctx context.Context, now time.Time, limit int) ([]models.RecurringTransfer, error) {
+	return r.queryRecurringTransfers(ctx, `
+		SELECT `+recurringColumns+`
+		FROM recurring_transfers
+		WHERE status = 'active' AND next_run_at <= $1
+		ORDER BY next_run_at, id
+		LIMIT $2
+	`, now, limit)
+}
+
+// AdvanceRecurringTransfer moves an active rule's next run from from to to
+// Database behavior:
+//   - A single conditional UPDATE, so of several instances polling the same due rule exactly one
+//     sees true and executes the run
+func (r *RecurringRepository) AdvanceRecurringTransfer(ctx context.Context, id int64, from, to time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE recurring_transfers SET next_run_at = $3
+		WHERE id = $1 AND next_run_at = $2 AND status = 'active'
+	`, id, from, to)
+	if err != nil {
+		return false, fmt.Errorf("failed to advance recurring transfer: %w", err)
+	}
+	advanced, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to advance recurring transfer: %w", err)
+	}
+	return advanced == 1, nil
+}
+
+// AddRecurringExecution records the outcome of an attempt of a run
+// Database behavior:
+//   - A failed execution is final: the recurring_transfer.failed event escalating it, keyed by the
+//     rule's source account, is enqueued in the same transaction
+func (r *RecurringRepository) AddRecurringExecution(ctx context.Context, execution models.RecurringExecution) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if execution.Attempt == 0 {
+		execution.Attempt = 1
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO recurring_executions (recurring_transfer_id, scheduled_at, executed_at, status, transaction_id, error, attempt, retry_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7, $8)
+		RETURNING id
+	`, execution.RecurringTransferID, execution.ScheduledAt, execution.ExecutedAt, execution.Status,
+		execution.TransactionID, execution.Error, execution.Attempt, execution.RetryAt).Scan(&execution.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record recurring execution: %w", err)
+	}
+
+	if execution.Status == models.ExecutionFailed {
+		rule, err := scanRecurringTransfer(tx.QueryRowContext(ctx,
+			`SELECT `+recurringColumns+` FROM recurring_transfers WHERE id = $1`, execution.RecurringTransferID))
+		if err != nil {
+			return fmt.Errorf("failed to get recurring transfer: %w", err)
+		}
+		escalation := models.RecurringEscalation{RecurringTransfer: models.NewRecurringTransferResponse(rule), Execution: execution}
+		if err := enqueueEvent(tx, EventRecurringFailed, rule.SourceAccountID, escalation); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DueRecurringRetries returns the queued retries of active rules due at now, earliest first
+// Served by the partial retry_at index, which only holds queued retries
+func (r *RecurringRepository) DueRecurringRetries(ctx context.Context, now time.Time, limit int) ([]models.RecurringExecution, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+executionColumns+`
+		FROM recurring_executions e
+		JOIN recurring_transfers t ON t.id = e.recurring_transfer_id
+		WHERE e.retry_at IS NOT NULL AND e.retry_at <= $1 AND t.status = 'active'
+		ORDER BY e.retry_at, e.id
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due recurring retries: %w", err)
+	}
+	return scanRecurringExecutions(rows)
+}
+
+// ClaimRecurringRetry takes a queued retry off the queue
+// Database behavior:
+//   - A single conditional UPDATE, so of several instances polling the same due retry exactly one
+//     sees true and attempts it
+func (r *RecurringRepository) ClaimRecurringRetry(ctx context.Context, executionID int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE recurring_executions SET retry_at = NULL
+		WHERE id = $1 AND retry_at IS NOT NULL
+	`, executionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim recurring retry: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim recurring retry: %w", err)
+	}
+	return claimed == 1, nil
+}
+
+// ListRecurringExecutions returns a rule's executions newest first, below beforeID if set, capped at limit
+// Served by the (recurring_transfer_id, id) index
+func (r *RecurringRepository) ListRecurringExecutions(ctx context.Context, id, beforeID int64, limit int) ([]models.RecurringExecution, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+executionColumns+`
+		FROM recurring_executions e
+		WHERE e.recurring_transfer_id = $1 AND ($2::bigint = 0 OR e.id < $2)
+		ORDER BY e.id DESC
+		LIMIT $3
+	`, id, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recurring executions: %w", err)
+	}
+	return scanRecurringExecutions(rows)
+}
+
+// executionColumns is the select list read by scanRecurringExecutions, on recurring_executions e
+const executionColumns = `e.id, e.recurring_transfer_id, e.scheduled_at, e.executed_at, e.status,
+	COALESCE(e.transaction_id, 0), e.error, e.attempt, e.retry_at`
+
+// scanRecurringExecutions reads and closes rows selecting executionColumns
+func scanRecurringExecutions(rows *sql.Rows) ([]models.RecurringExecution, error) {
+	defer rows.Close()
+	executions := []models.RecurringExecution{}
+	for rows.Next() {
+		var e models.RecurringExecution
+		var retryAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.RecurringTransferID, &e.ScheduledAt, &e.ExecutedAt, &e.Status, &e.TransactionID,
+			&e.Error, &e.Attempt, &retryAt); err != nil {
+			return nil, fmt.Errorf("failed to scan recurring execution: %w", err)
+		}
+		if retryAt.Valid {
+			e.RetryAt = &retryAt.Time
+		}
+		executions = append(executions, e)
+	}
+	return executions, rows.Err()
+}
+
+// queryRecurringTransfers runs a query selecting recurringColumns
+func (r *RecurringRepository) queryRecurringTransfers(ctx context.Context, query string, args ...any) ([]models.RecurringTransfer, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recurring transfers: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.RecurringTransfer{}
+	for rows.Next() {
+		rule, err := scanRecurringTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recurring transfer: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replicaRetryInterval is how long reads stay on the primary after the replica failed
+const replicaRetryInterval = 30 * time.Second
+
+// replicaMetrics counts the reads served by the replica and those that fell back to the primary
+var replicaMetrics = expvar.NewMap("db_replica")
+
+// Replica routes read-only queries to a read replica, falling back to the primary while the
+// replica is unavailable
+// Only queries that tolerate replication lag are routed: a replica may briefly miss the latest
+// writes, so flows that read before writing (FOR UPDATE, version checks) stay on the primary.
+// A nil *Replica sends every read to the primary
+type Replica struct {
+	pool *pgxpool.Pool
+	db   *sql.DB
+	// downUntil is when, in Unix nanoseconds, the replica is tried again after a failure
+	downUntil atomic.Int64
+}
+
+// OpenReplica creates the replica pool for the DB_REPLICA_DSN connection string, with the same
+// DB_* pool limits as the primary
+// An unreachable replica does not fail: reads use the primary until it answers
+// Returns nil without a DSN, or an error for a malformed DSN
+func OpenReplica(dsn string) (*Replica, error) {
+	if dsn == "" {
+		return nil, nil
+	}
+	config, err := parsePoolConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_REPLICA_DSN: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replica: %w", err)
+	}
+	r := &Replica{pool: pool, db: OpenDB(pool)}
+	if err := pool.Ping(ctx); err != nil {
+		slog.Warn("Read replica unreachable; reads use the primary until it answers", "error", err)
+		r.markDown()
+	}
+	return r, nil
+}
+
+// read runs query against the replica, or against primary when there is no replica, it failed
+// less than replicaRetryInterval ago, or it fails now because it is unavailable
+func (r *Replica) read(primary *sql.DB, query func(db *sql.DB) error) error {
+	if r == nil || time.Now().UnixNano() < r.downUntil.Load() {
+		return query(primary)
+	}
+	err := query(r.db)
+	if !replicaUnavailable(err) {
+		replicaMetrics.Add("reads", 1)
+		return err
+	}
+	slog.Warn("Read replica failed; reading from the primary", "retry_in", replicaRetryInterval, "error", err)
+	r.markDown()
+	replicaMetrics.Add("fallbacks", 1)
+	return query(primary)
+}
+
+// markDown sends reads to the primary for replicaRetryInterval
+func (r *Replica) markDown() {
+	r.downUntil.Store(time.Now().Add(replicaRetryInterval).UnixNano())
+}
+
+// replicaUnavailable reports whether err means the replica could not answer, as opposed to an
+// answer (no rows included) or the caller giving up: connection failures and Postgres connection
+// exceptions (SQLSTATE class 08), shutdowns and recovery (class 57P)
+func replicaUnavailable(err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P")
+	}
+	return true
+}
+
+// Ping checks that the replica answers
+func (r *Replica) Ping(ctx context.Context) error {
+	return r.pool.Ping(ctx)
+}
+
+// Close closes the replica's handle and pool
+func (r *Replica) Close() error {
+	if r == nil {
+		return nil
+	}
+	err := r.db.Close()
+	r.pool.Close()
+	return err
+}
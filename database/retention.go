@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// RetentionRepository implements RetentionRepositoryInterface for PostgreSQL
+type RetentionRepository struct {
+	db *sql.DB
+}
+
+// NewRetentionRepository creates a new retention repository instance
+func NewRetentionRepository(db *sql.DB) *RetentionRepository {
+	return &RetentionRepository{db: db}
+}
+
+// retentionTables describes the purgeable data classes: the table and the condition selecting the
+// rows older than the cutoff ($1)
+// Published outbox events are selected by publication time through idx_outbox_events_published_at;
+// unpublished ones never match
+var retentionTables = map[string]struct {
+	table   string
+	expired string
+}{
+	models.RetentionAuditEvents:  {"audit_events", "occurred_at < $1"},
+	models.RetentionOutboxEvents: {"outbox_events", "published_at < $1"},
+}
+
+// CountExpired returns the number of rows of the class older than before
+func (r *RetentionRepository) CountExpired(ctx context.Context, class string, before time.Time) (int64, error) {
+	target, ok := retentionTables[class]
+	if !ok {
+		return 0, fmt.Errorf("data class %q cannot be purged", class)
+	}
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+target.table+` WHERE `+target.expired, before).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count expired %s: %w", class, err)
+	}
+	return count, nil
+}
+
+// DeleteExpired deletes up to limit rows of the class older than before, oldest first
+// Audit events are append-only: the deletion sets the transaction-local retention.purge flag,
+// the only case in which the audit_events trigger lets a DELETE through
+// Returns the number of rows deleted
+func (r *RetentionRepository) DeleteExpired(ctx context.Context, class string, before time.Time, limit int) (int64, error) {
+	target, ok := retentionTables[class]
+	if !ok {
+		return 0, fmt.Errorf("data class %q cannot be purged", class)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if class == models.RetentionAuditEvents {
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('retention.purge', $1, true)`, class); err != nil {
+			return 0, fmt.Errorf("failed to enable audit purge: %w", err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM `+target.table+` WHERE id IN (
+			SELECT id FROM `+target.table+` WHERE `+target.expired+` ORDER BY id LIMIT $2
+		)
+	`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired %s: %w", class, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired %s: %w", class, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Transaction retry defaults applied when the corresponding environment variables are unset
+const (
+	defaultTxMaxRetries = 3
+	defaultTxRetryDelay = 20 * time.Millisecond
+)
+
+// Locking modes of transfers' balance updates
+//   - LockingPessimistic: Account rows are locked with FOR UPDATE when read, so concurrent
+//     transfers on an account queue behind each other
+//   - LockingOptimistic: Account rows are read without locks and the balance updates only apply if
+//     the rows are unchanged; a transfer that loses the race is retried. Fewer and shorter locks
+//     suit read-heavy deployments where transfers on the same account rarely overlap
+const (
+	LockingPessimistic = "pessimistic"
+	LockingOptimistic  = "optimistic"
+)
+
+// errBalanceConflict is reported when an optimistic balance update finds the account changed
+// since it was read; it is retried like a serialization failure
+var errBalanceConflict = errors.New("account changed concurrently")
+
+// errTransactionConflict is reported when a transaction still conflicts with concurrent ones
+// after every retry
+var errTransactionConflict = errors.New("transaction conflict")
+
+// RetryConfig controls how transactions aborted by Postgres to resolve a conflict with concurrent
+// transactions (serialization failures and deadlocks) are retried
+// Retry n waits a random delay between zero and BaseDelay * 2^(n-1), so transactions that
+// conflicted once do not collide again in lockstep
+type RetryConfig struct {
+	// MaxRetries is how many times a conflicting transaction is retried; zero disables retries
+	MaxRetries int
+	BaseDelay  time.Duration
+}
+
+// DefaultRetryConfig returns the retry settings used when none are configured
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{MaxRetries: defaultTxMaxRetries, BaseDelay: defaultTxRetryDelay}
+}
+
+// LoadRetryConfig reads the transaction retry settings from the environment
+// Variables:
+//   - DB_TX_MAX_RETRIES (3): Retries of a transaction aborted by a serialization failure or deadlock
+//   - DB_TX_RETRY_DELAY (20ms): Base of the jittered exponential backoff between retries
+//
+// Returns an error naming the offending variable for malformed values
+func LoadRetryConfig() (RetryConfig, error) {
+	maxRetries, err := getEnvInt("DB_TX_MAX_RETRIES", defaultTxMaxRetries)
+	if err != nil {
+		return RetryConfig{}, err
+	}
+	delay, err := getEnvDuration("DB_TX_RETRY_DELAY", defaultTxRetryDelay)
+	if err != nil {
+		return RetryConfig{}, err
+	}
+	return RetryConfig{MaxRetries: maxRetries, BaseDelay: delay}, nil
+}
+
+// LoadLockingMode reads the transfers' locking mode from DB_LOCKING (pessimistic by default)
+// Returns an error for values other than "pessimistic" and "optimistic"
+func LoadLockingMode() (string, error) {
+	switch mode := getEnvWithDefault("DB_LOCKING", LockingPessimistic); mode {
+	case LockingPessimistic, LockingOptimistic:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid DB_LOCKING %q (expected %s or %s)", mode, LockingPessimistic, LockingOptimistic)
+	}
+}
+
+// backoff returns the jittered delay before the given retry (1 for the first)
+func (c RetryConfig) backoff(retry int) time.Duration {
+	ceiling := c.BaseDelay << min(retry-1, 10)
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// isRetryable reports whether err aborted a transaction that may succeed if run again: a Postgres
+// serialization_failure (SQLSTATE 40001) or deadlock_detected (SQLSTATE 40P01), an optimistic
+// balance update that lost the race, or a failover before the commit (see Failover.check)
+func isRetryable(err error) bool {
+	if errors.Is(err, errBalanceConflict) || errors.Is(err, errFailover) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected)
+}
+
+// retryTx runs attempt, a complete database transaction, until it succeeds, fails with an error
+// that is not retryable or has been retried config.MaxRetries times
+// Exhausted retries are reported as errTransactionConflict; the last conflict is logged
+func retryTx(config RetryConfig, attempt func() error) error {
+	for retry := 0; ; retry++ {
+		err := attempt()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if retry == config.MaxRetries {
+			slog.Warn("Transaction conflict retries exhausted", "retries", retry, "error", err)
+			return errTransactionConflict
+		}
+		delay := config.backoff(retry + 1)
+		slog.Debug("Retrying conflicting transaction", "retry", retry+1, "delay", delay, "error", err)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,319 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema verification catches drift that migrations cannot see: an index or constraint dropped
+// or broken by hand after the migration that created it was recorded as applied. A missing index
+// does not fail any query, it only makes it slow, so drift is reported rather than left to be
+// discovered from latency graphs
+
+// indexPattern matches the CREATE INDEX statements of migrations and captures uniqueness, name
+// and table
+var indexPattern = regexp.MustCompile(`(?i)CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(\w+)\s+ON\s+(\w+)[^;]*;`)
+
+// dropIndexPattern matches the DROP INDEX statements of migrations and captures the name
+var dropIndexPattern = regexp.MustCompile(`(?i)DROP\s+INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?(\w+)`)
+
+// SchemaIndex is an index created by an applied migration
+type SchemaIndex struct {
+	Name   string
+	Table  string
+	Unique bool
+
+	// Definition is the migration's CREATE INDEX statement, which recreates the index
+	Definition string
+}
+
+// ExpectedIndexes returns the indexes the given migrations leave behind when applied in order:
+// those created by one and not dropped by a later one
+func ExpectedIndexes(migrations []Migration) []SchemaIndex {
+	byName := make(map[string]SchemaIndex)
+	for _, m := range migrations {
+		for _, match := range dropIndexPattern.FindAllStringSubmatch(m.Up, -1) {
+			delete(byName, strings.ToLower(match[1]))
+		}
+		for _, match := range indexPattern.FindAllStringSubmatch(m.Up, -1) {
+			name := strings.ToLower(match[2])
+			byName[name] = SchemaIndex{Name: name, Table: strings.ToLower(match[3]), Unique: match[1] != "", Definition: match[0]}
+		}
+	}
+	indexes := make([]SchemaIndex, 0, len(byName))
+	for _, index := range byName {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// Constraint kinds, as in pg_constraint.contype
+const (
+	PrimaryKey = "p"
+	ForeignKey = "f"
+	Unique     = "u"
+	Check      = "c"
+)
+
+// SchemaConstraint is a constraint the code relies on for correctness
+// Most constraints are named by PostgreSQL, so they are identified by table, kind and the
+// columns they cover rather than by name
+type SchemaConstraint struct {
+	Table   string
+	Kind    string
+	Columns []string
+
+	// Version is the migration that creates the constraint; it is only expected once applied
+	Version int
+}
+
+// String describes the constraint for drift reports
+func (c SchemaConstraint) String() string {
+	kinds := map[string]string{PrimaryKey: "primary key", ForeignKey: "foreign key", Unique: "unique constraint", Check: "check constraint"}
+	return fmt.Sprintf("%s on %s(%s)", kinds[c.Kind], c.Table, strings.Join(c.Columns, ", "))
+}
+
+// ExpectedConstraints lists the constraints the migrations create; a migration that adds or
+// drops a constraint must update it
+var ExpectedConstraints = []SchemaConstraint{
+	{Table: "accounts", Kind: PrimaryKey, Columns: []string{"account_id"}, Version: 1},
+	{Table: "transactions", Kind: PrimaryKey, Columns: []string{"id"}, Version: 2},
+	{Table: "transactions", Kind: Check, Columns: []string{"amount"}, Version: 2},
+	{Table: "transactions", Kind: ForeignKey, Columns: []string{"source_account_id"}, Version: 2},
+	{Table: "transactions", Kind: ForeignKey, Columns: []string{"destination_account_id"}, Version: 2},
+	{Table: "transactions", Kind: Check, Columns: []string{"destination_account_id", "source_account_id"}, Version: 2},
+	{Table: "outbox_events", Kind: PrimaryKey, Columns: []string{"id"}, Version: 6},
+	{Table: "outbox_events", Kind: Unique, Columns: []string{"event_id"}, Version: 6},
+	{Table: "settlement_files", Kind: PrimaryKey, Columns: []string{"id"}, Version: 8},
+	{Table: "settlement_files", Kind: Unique, Columns: []string{"business_date", "partner_id"}, Version: 8},
+	{Table: "transactions", Kind: ForeignKey, Columns: []string{"return_of"}, Version: 9},
+	{Table: "api_usage_daily", Kind: PrimaryKey, Columns: []string{"day", "key_id"}, Version: 14},
+	{Table: "transfer_latency", Kind: PrimaryKey, Columns: []string{"transaction_id"}, Version: 16},
+	{Table: "transactions", Kind: Check, Columns: []string{"destination_amount"}, Version: 17},
+	{Table: "transactions", Kind: Check, Columns: []string{"fx_rate"}, Version: 17},
+	{Table: "accounts", Kind: Check, Columns: []string{"overdraft_limit"}, Version: 18},
+	{Table: "accounts", Kind: Check, Columns: []string{"balance", "overdraft_limit"}, Version: 18},
+	{Table: "recurring_transfers", Kind: PrimaryKey, Columns: []string{"id"}, Version: 20},
+	{Table: "recurring_transfers", Kind: ForeignKey, Columns: []string{"source_account_id"}, Version: 20},
+	{Table: "recurring_transfers", Kind: ForeignKey, Columns: []string{"destination_account_id"}, Version: 20},
+	{Table: "recurring_transfers", Kind: Check, Columns: []string{"amount"}, Version: 20},
+	{Table: "recurring_transfers", Kind: Check, Columns: []string{"status"}, Version: 20},
+	{Table: "recurring_transfers", Kind: Check, Columns: []string{"destination_account_id", "source_account_id"}, Version: 20},
+	{Table: "recurring_executions", Kind: PrimaryKey, Columns: []string{"id"}, Version: 20},
+	{Table: "recurring_executions", Kind: ForeignKey, Columns: []string{"recurring_transfer_id"}, Version: 20},
+	{Table: "recurring_executions", Kind: ForeignKey, Columns: []string{"transaction_id"}, Version: 20},
+	{Table: "recurring_executions", Kind: Check, Columns: []string{"status"}, Version: 20},
+	{Table: "accounts", Kind: Check, Columns: []string{"held"}, Version: 21},
+	{Table: "holds", Kind: PrimaryKey, Columns: []string{"id"}, Version: 21},
+	{Table: "holds", Kind: ForeignKey, Columns: []string{"source_account_id"}, Version: 21},
+	{Table: "holds", Kind: ForeignKey, Columns: []string{"destination_account_id"}, Version: 21},
+	{Table: "holds", Kind: ForeignKey, Columns: []string{"transaction_id"}, Version: 21},
+	{Table: "holds", Kind: Check, Columns: []string{"amount"}, Version: 21},
+	{Table: "holds", Kind: Check, Columns: []string{"status"}, Version: 21},
+	{Table: "holds", Kind: Check, Columns: []string{"destination_account_id", "source_account_id"}, Version: 21},
+	{Table: "account_limits", Kind: PrimaryKey, Columns: []string{"account_id"}, Version: 22},
+	{Table: "account_limits", Kind: ForeignKey, Columns: []string{"account_id"}, Version: 22},
+	{Table: "account_limits", Kind: Check, Columns: []string{"max_amount"}, Version: 22},
+	{Table: "account_limits", Kind: Check, Columns: []string{"daily_amount"}, Version: 22},
+	{Table: "account_limits", Kind: Check, Columns: []string{"daily_count"}, Version: 22},
+	{Table: "transactions", Kind: Check, Columns: []string{"status"}, Version: 24},
+	{Table: "transactions", Kind: ForeignKey, Columns: []string{"depends_on"}, Version: 25},
+	{Table: "audit_events", Kind: PrimaryKey, Columns: []string{"id"}, Version: 26},
+	{Table: "balance_snapshots", Kind: PrimaryKey, Columns: []string{"id"}, Version: 27},
+	{Table: "balance_snapshots", Kind: ForeignKey, Columns: []string{"account_id"}, Version: 27},
+	{Table: "balance_snapshots", Kind: ForeignKey, Columns: []string{"transaction_id"}, Version: 27},
+	{Table: "balance_snapshots", Kind: Check, Columns: []string{"source"}, Version: 27},
+	{Table: "attachments", Kind: PrimaryKey, Columns: []string{"id"}, Version: 31},
+	{Table: "attachments", Kind: ForeignKey, Columns: []string{"transaction_id"}, Version: 31},
+	{Table: "attachments", Kind: Check, Columns: []string{"size"}, Version: 31},
+	{Table: "attachments", Kind: Unique, Columns: []string{"object_key"}, Version: 31},
+	{Table: "adjustments", Kind: PrimaryKey, Columns: []string{"id"}, Version: 32},
+	{Table: "adjustments", Kind: ForeignKey, Columns: []string{"account_id"}, Version: 32},
+	{Table: "adjustments", Kind: Check, Columns: []string{"amount"}, Version: 32},
+	{Table: "adjustments", Kind: Check, Columns: []string{"reason"}, Version: 32},
+	{Table: "adjustments", Kind: Check, Columns: []string{"actor"}, Version: 32},
+	{Table: "account_wallets", Kind: PrimaryKey, Columns: []string{"account_id", "currency"}, Version: 33},
+	{Table: "account_wallets", Kind: ForeignKey, Columns: []string{"account_id"}, Version: 33},
+	{Table: "account_wallets", Kind: Check, Columns: []string{"currency"}, Version: 33},
+	{Table: "account_wallets", Kind: Check, Columns: []string{"balance"}, Version: 33},
+	{Table: "currencies", Kind: PrimaryKey, Columns: []string{"code"}, Version: 34},
+	{Table: "currencies", Kind: Check, Columns: []string{"code"}, Version: 34},
+	{Table: "currencies", Kind: Check, Columns: []string{"scale"}, Version: 34},
+	{Table: "currencies", Kind: Check, Columns: []string{"min_amount"}, Version: 34},
+	{Table: "currencies", Kind: Check, Columns: []string{"max_amount"}, Version: 34},
+	{Table: "currencies", Kind: Check, Columns: []string{"max_amount", "min_amount"}, Version: 34},
+	{Table: "recurring_executions", Kind: Check, Columns: []string{"attempt"}, Version: 35},
+	{Table: "accounts", Kind: Unique, Columns: []string{"external_id"}, Version: 36},
+	{Table: "accounts", Kind: Check, Columns: []string{"external_id"}, Version: 36},
+	{Table: "accounts", Kind: Unique, Columns: []string{"creation_token"}, Version: 37},
+	{Table: "accounts", Kind: Check, Columns: []string{"creation_token"}, Version: 37},
+}
+
+// SchemaDrift is a difference between the database and what the applied migrations created
+type SchemaDrift struct {
+	Object  string
+	Problem string
+
+	// Fix is the statement or action that repairs the drift
+	Fix string
+}
+
+// String describes the drift in one line
+func (d SchemaDrift) String() string {
+	return d.Object + ": " + d.Problem
+}
+
+// VerifySchema compares the indexes and constraints in the current schema with those the applied
+// migrations create. Only applied migrations are considered, so pending migrations are not drift
+// Returns the differences found, or an error if the catalogs cannot be read
+// Reported drift:
+//   - An expected index is missing, invalid (e.g. a failed CREATE INDEX CONCURRENTLY) or has lost
+//     its uniqueness
+//   - An expected constraint is missing, or was added NOT VALID and never validated
+func VerifySchema(ctx context.Context, db *sql.DB) ([]SchemaDrift, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	pending, _, err := MigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	isPending := make(map[int]bool, len(pending))
+	for _, m := range pending {
+		isPending[m.Version] = true
+	}
+	applied := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		if !isPending[m.Version] {
+			applied = append(applied, m)
+		}
+	}
+
+	var drift []SchemaDrift
+	indexDrift, err := verifyIndexes(ctx, db, ExpectedIndexes(applied))
+	if err != nil {
+		return nil, err
+	}
+	drift = append(drift, indexDrift...)
+
+	var constraints []SchemaConstraint
+	for _, c := range ExpectedConstraints {
+		if !isPending[c.Version] {
+			constraints = append(constraints, c)
+		}
+	}
+	constraintDrift, err := verifyConstraints(ctx, db, constraints)
+	if err != nil {
+		return nil, err
+	}
+	return append(drift, constraintDrift...), nil
+}
+
+// verifyIndexes reports the expected indexes that are missing or differ in the current schema
+func verifyIndexes(ctx context.Context, db *sql.DB, expected []SchemaIndex) ([]SchemaDrift, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.relname, t.relname, i.indisunique, i.indisvalid
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		JOIN pg_class t ON t.oid = i.indrelid
+		WHERE c.relnamespace = current_schema()::regnamespace`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+	defer rows.Close()
+
+	type indexState struct {
+		table         string
+		unique, valid bool
+	}
+	existing := make(map[string]indexState)
+	for rows.Next() {
+		var name string
+		var state indexState
+		if err := rows.Scan(&name, &state.table, &state.unique, &state.valid); err != nil {
+			return nil, fmt.Errorf("failed to read indexes: %w", err)
+		}
+		existing[name] = state
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+
+	var drift []SchemaDrift
+	for _, index := range expected {
+		object := fmt.Sprintf("index %s on %s", index.Name, index.Table)
+		recreate := fmt.Sprintf("DROP INDEX IF EXISTS %s; %s", index.Name, index.Definition)
+		state, ok := existing[index.Name]
+		switch {
+		case !ok:
+			drift = append(drift, SchemaDrift{Object: object, Problem: "missing", Fix: index.Definition})
+		case state.table != index.Table:
+			drift = append(drift, SchemaDrift{Object: object, Problem: "is on table " + state.table, Fix: recreate})
+		case !state.valid:
+			drift = append(drift, SchemaDrift{Object: object, Problem: "invalid (not used by queries)", Fix: recreate})
+		case index.Unique && !state.unique:
+			drift = append(drift, SchemaDrift{Object: object, Problem: "no longer unique", Fix: recreate})
+		}
+	}
+	return drift, nil
+}
+
+// verifyConstraints reports the expected constraints that are missing or not validated in the
+// current schema
+func verifyConstraints(ctx context.Context, db *sql.DB, expected []SchemaConstraint) ([]SchemaDrift, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.relname, c.conname, c.contype, c.convalidated,
+		       (SELECT string_agg(a.attname, ',' ORDER BY a.attname)
+		        FROM unnest(c.conkey) AS k(attnum)
+		        JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum)
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		WHERE t.relnamespace = current_schema()::regnamespace AND c.contype IN ('p', 'f', 'u', 'c')`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+	defer rows.Close()
+
+	// existing holds, per table, kind and columns, a validated matching constraint if there is
+	// one, otherwise the last one read
+	type constraintState struct {
+		name  string
+		valid bool
+	}
+	existing := make(map[string]constraintState)
+	for rows.Next() {
+		var table, kind string
+		var state constraintState
+		var columns sql.NullString
+		if err := rows.Scan(&table, &state.name, &kind, &state.valid, &columns); err != nil {
+			return nil, fmt.Errorf("failed to read constraints: %w", err)
+		}
+		key := table + "/" + kind + "/" + columns.String
+		if !existing[key].valid {
+			existing[key] = state
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+
+	var drift []SchemaDrift
+	for _, c := range expected {
+		state, ok := existing[c.Table+"/"+c.Kind+"/"+strings.Join(c.Columns, ",")]
+		switch {
+		case !ok:
+			drift = append(drift, SchemaDrift{Object: c.String(), Problem: "missing",
+				Fix: fmt.Sprintf("restore it as created by migration %04d", c.Version)})
+		case !state.valid:
+			drift = append(drift, SchemaDrift{Object: c.String(), Problem: "not validated (existing rows are not checked)",
+				Fix: fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;", c.Table, state.name)})
+		}
+	}
+	return drift, nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// SettlementRepository implements SettlementRepositoryInterface for PostgreSQL
+type SettlementRepository struct {
+	db *sql.DB
+}
+
+// NewSettlementRepository creates a new settlement repository instance
+func NewSettlementRepository(db *sql.DB) *SettlementRepository {
+	return &SettlementRepository{db: db}
+}
+
+// TransactionsByValueDate returns the transactions value-dated on date involving any of the accounts
+// Parameters:
+//   - ctx: Context bounding the query
+//   - date: Value date to select (only the calendar date is used)
+//   - accountIDs: Accounts whose transfers are included, as source or destination
+//
+// Returns:
+//   - []models.Transaction: Matching transactions ordered by ID (empty if none)
+//   - error: Database error if the query fails
+//
+// Database behavior:
+//   - Served by the value_date index
+func (r *SettlementRepository) TransactionsByValueDate(ctx context.Context, date time.Time, accountIDs []int64) ([]models.Transaction, error) {
+	return transactionsByValueDate(ctx, r.db, date, accountIDs)
+}
+
+// Snapshot calls fn with a view reading from one read-only REPEATABLE READ transaction
+// Returns fn's error, or an error if the snapshot could not be started
+func (r *SettlementRepository) Snapshot(ctx context.Context, fn func(SettlementSnapshot) error) error {
+	return InSnapshot(ctx, r.db, func(tx *sql.Tx) error {
+		return fn(settlementSnapshot{tx: tx})
+	})
+}
+
+// transactionsByValueDate implements TransactionsByValueDate on a database or snapshot
+func transactionsByValueDate(ctx context.Context, q queryer, date time.Time, accountIDs []int64) ([]models.Transaction, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+transactionColumns+`
+		FROM transactions
+		WHERE value_date = $1 AND (source_account_id = ANY($2) OR destination_account_id = ANY($2))
+		ORDER BY id
+	`, date.Format("2006-01-02"), accountIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	return scanTransactions(rows)
+}
+
+// GetTransaction returns a transaction by ID or a "transaction not found" error
+func (r *SettlementRepository) GetTransaction(ctx context.Context, transactionID int64) (*models.Transaction, error) {
+	t, err := scanTransaction(r.db.QueryRowContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE id = $1`, transactionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("transaction not found")
+		}
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	return &t, nil
+}
+
+// SettleTransaction marks an unsettled transaction as settled
+// Settling an already settled transaction is a no-op
+// Possible error returns:
+//   - "transaction not found": No transaction has the ID
+//   - "transaction already returned": The partner returned the transaction earlier
+func (r *SettlementRepository) SettleTransaction(ctx context.Context, transactionID int64) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE transactions SET settlement_status = $2 WHERE id = $1 AND settlement_status = $3`,
+		transactionID, models.SettlementSettled, models.SettlementUnsettled)
+	if err != nil {
+		return fmt.Errorf("failed to settle transaction: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 1 {
+		return nil
+	}
+
+	transaction, err := r.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return err
+	}
+	if transaction.SettlementStatus == models.SettlementReturned {
+		return fmt.Errorf("transaction already returned")
+	}
+	return nil
+}
+
+// ReturnTransaction reverses a transfer the partner returned
+// In one database transaction it locks the original, books a return transaction moving the amount
+// back from the original destination to the original source, links it through return_of and marks
+// the original returned and reversed
+// Parameters:
+//   - ctx: Context bounding the database transaction
+//   - transactionID: The returned transfer
+//   - valueDate: Value date of the return transaction
+//
+// Returns:
+//   - *models.Transaction: The committed return transaction
+//   - error: "transaction not found", "transaction already returned", or the CreateTransaction
+//     errors for the reverse movement (e.g. "insufficient balance" if the funds were spent)
+func (r *SettlementRepository) ReturnTransaction(ctx context.Context, transactionID int64, valueDate time.Time) (*models.Transaction, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	original, err := scanTransaction(tx.QueryRowContext(ctx,
+		`SELECT `+transactionColumns+` FROM transactions WHERE id = $1 FOR UPDATE`, transactionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("transaction not found")
+		}
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	if original.SettlementStatus == models.SettlementReturned {
+		return nil, fmt.Errorf("transaction already returned")
+	}
+
+	returned, err := transferTx(tx, original.Return(valueDate), LockingPessimistic)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE transactions SET settlement_status = $2, status = $3 WHERE id = $1`,
+		original.ID, models.SettlementReturned, models.TransactionReversed); err != nil {
+		return nil, fmt.Errorf("failed to mark transaction returned: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return returned, nil
+}
+
+// SaveSettlementFile upserts the generation record for the file's partner and business date
+// Returns the stored record including its ID and generation time
+func (r *SettlementRepository) SaveSettlementFile(ctx context.Context, file models.SettlementFile) (*models.SettlementFile, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO settlement_files (partner_id, business_date, file_name, format, status, transfer_count, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (partner_id, business_date) DO UPDATE SET
+			file_name = EXCLUDED.file_name,
+			format = EXCLUDED.format,
+			status = EXCLUDED.status,
+			transfer_count = EXCLUDED.transfer_count,
+			error = EXCLUDED.error,
+			generated_at = NOW()
+		RETURNING id, generated_at
+	`, file.PartnerID, file.BusinessDate.Format("2006-01-02"), file.FileName, file.Format, file.Status,
+		file.TransferCount, file.Error).Scan(&file.ID, &file.GeneratedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save settlement file: %w", err)
+	}
+	return &file, nil
+}
+
+// ListSettlementFiles returns generation records newest business date first, then by partner,
+// capped at limit
+// A zero date or empty partnerID matches all dates or partners; a non-zero after starts the page
+// after that record
+func (r *SettlementRepository) ListSettlementFiles(ctx context.Context, date time.Time, partnerID string, after models.SettlementFileKey, limit int) ([]models.SettlementFile, error) {
+	var dateFilter, afterDate interface{}
+	if !date.IsZero() {
+		dateFilter = date.Format("2006-01-02")
+	}
+	if !after.BusinessDate.IsZero() {
+		afterDate = after.BusinessDate.Format("2006-01-02")
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, partner_id, business_date, file_name, format, status, transfer_count, error, generated_at
+		FROM settlement_files
+		WHERE ($1::date IS NULL OR business_date = $1::date) AND ($2 = '' OR partner_id = $2)
+		  AND ($4::date IS NULL OR business_date < $4::date OR (business_date = $4::date AND partner_id > $5))
+		ORDER BY business_date DESC, partner_id
+		LIMIT $3
+	`, dateFilter, partnerID, limit, afterDate, after.PartnerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settlement files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []models.SettlementFile{}
+	for rows.Next() {
+		var f models.SettlementFile
+		if err := rows.Scan(&f.ID, &f.PartnerID, &f.BusinessDate, &f.FileName, &f.Format, &f.Status,
+			&f.TransferCount, &f.Error, &f.GeneratedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan settlement file: %w", err)
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list settlement files: %w", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// snapshotTxOptions start a read-only REPEATABLE READ transaction: in PostgreSQL all of its
+// queries see the database as of its first query, however long the transaction runs
+var snapshotTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// queryer is implemented by *sql.DB and *sql.Tx, so read queries can run inside or outside a
+// snapshot
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// InSnapshot runs fn in a read-only snapshot transaction of db
+// Exports and reports that read with several queries run them through it, so their output reflects
+// one consistent point in time instead of a ledger that kept changing while they were read.
+// Writes inside fn fail; the transaction is rolled back if fn returns an error
+func InSnapshot(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, snapshotTxOptions)
+	if err != nil {
+		return fmt.Errorf("failed to begin snapshot: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to end snapshot: %w", err)
+	}
+	return nil
+}
+
+// settlementSnapshot implements SettlementSnapshot inside a snapshot transaction
+type settlementSnapshot struct {
+	tx *sql.Tx
+}
+
+// TransactionsByValueDate returns the transactions value-dated on date as of the snapshot
+func (s settlementSnapshot) TransactionsByValueDate(ctx context.Context, date time.Time, accountIDs []int64) ([]models.Transaction, error) {
+	return transactionsByValueDate(ctx, s.tx, date, accountIDs)
+}
@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+
+	"internal-transfers/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Storage is a complete persistence backend
+// Handlers, the settlement job and the outbox relay depend only on this interface and the
+// repository interfaces it returns, so an alternative backend (another SQL database, a key-value
+// store, in-memory for tests) is added by implementing Storage; nothing above it changes.
+// Embedders construct their own Storage and pass it to handlers.NewHandlerWithStorage
+type Storage interface {
+	// Accounts returns the account repository
+	Accounts() AccountRepositoryInterface
+
+	// Transactions returns the repository that commits transfers
+	Transactions() TransactionRepositoryInterface
+
+	// Settlements returns the repository used for partner settlement files
+	Settlements() SettlementRepositoryInterface
+
+	// Outbox returns the transactional outbox, or nil if the backend does not record events
+	Outbox() OutboxRepositoryInterface
+
+	// Usage returns the per-key API usage rollup
+	Usage() UsageRepositoryInterface
+
+	// Latency returns the per-transfer latency samples used for SLA reports
+	Latency() LatencyRepositoryInterface
+
+	// Recurring returns the recurring transfer rules and their executions
+	Recurring() RecurringRepositoryInterface
+
+	// Holds returns the authorization holds
+	Holds() HoldRepositoryInterface
+
+	// Limits returns the per-account transfer limits
+	Limits() LimitRepositoryInterface
+
+	// Audit returns the append-only audit log
+	Audit() AuditRepositoryInterface
+
+	// BalanceHistory returns the account balance snapshots
+	BalanceHistory() BalanceHistoryRepositoryInterface
+
+	// Reconciliation returns the repository that recomputes balances from the ledger
+	Reconciliation() ReconciliationRepositoryInterface
+
+	// Retention returns the repository that purges expired data, or nil if the backend keeps
+	// everything
+	Retention() RetentionRepositoryInterface
+
+	// Attachments returns the records of documents attached to transactions
+	Attachments() AttachmentRepositoryInterface
+
+	// Adjustments returns the manual balance adjustments
+	Adjustments() AdjustmentRepositoryInterface
+
+	// Wallets returns the multi-currency accounts' wallets
+	Wallets() WalletRepositoryInterface
+
+	// Currencies returns the configuration of the supported currencies
+	Currencies() CurrencyRepositoryInterface
+
+	// APIKeys returns the API keys signing requests
+	APIKeys() APIKeyRepositoryInterface
+
+	// Close releases the backend's resources
+	Close() error
+}
+
+// OutboxRepositoryInterface is the transactional outbox drained by the outbox relay
+// Backends that implement it must record events in the same atomic write as the change
+// they describe (see PublishPending for the delivery contract)
+type OutboxRepositoryInterface interface {
+	PublishPending(ctx context.Context, limit int, publish func(ctx context.Context, messages []OutboxMessage) error) (int, error)
+
+	// CountPending returns the number of events not yet published
+	CountPending(ctx context.Context) (int64, error)
+
+	// TransactionEvents returns the events recorded for a transaction, oldest first
+	TransactionEvents(ctx context.Context, transactionID int64) ([]OutboxMessage, error)
+}
+
+// LedgerEventSource is implemented by event-sourced backends (STORAGE=eventsourced), whose ledger
+// is an append-only log of events
+type LedgerEventSource interface {
+	// AccountEvents returns the account's events with a sequence number above after, in order and
+	// capped at limit, each with the account's balance folded from its events up to that one
+	// Returns "account not found" if the account has no events
+	AccountEvents(ctx context.Context, accountID, after int64, limit int) ([]models.AccountEvent, error)
+}
+
+// PoolStatsProvider is implemented by backends that expose connection pool statistics
+type PoolStatsProvider interface {
+	PoolStats() PoolStats
+}
+
+// CredentialWatcher is implemented by backends whose database credentials can rotate while the
+// server runs; WatchCredentials applies rotations until ctx is done
+type CredentialWatcher interface {
+	WatchCredentials(ctx context.Context)
+}
+
+// PostgresStorage is the default Storage, backed by a pgx connection pool
+type PostgresStorage struct {
+	pool        *pgxpool.Pool
+	db          *sql.DB
+	replica     *Replica
+	failover    *Failover
+	credentials CredentialConfig
+	verified    *verifiedCredentials
+	retry       RetryConfig
+	locking     string
+}
+
+// OpenPostgresStorage connects to PostgreSQL and applies pending migrations
+// Connection settings come from the environment (see InitDB); DB_REPLICA_DSN adds a read replica
+// for lag-tolerant reads (see Replica). The pool recovers from a primary failover (see Failover)
+// and follows rotations of the primary's credentials (see WatchCredentials)
+// Returns:
+//   - *PostgresStorage: Ready to use storage
+//   - error: Connection or migration error; nothing is left open on failure
+func OpenPostgresStorage() (*PostgresStorage, error) {
+	retry, err := LoadRetryConfig()
+	if err != nil {
+		return nil, err
+	}
+	locking, err := LoadLockingMode()
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := LoadCredentialConfig()
+	if err != nil {
+		return nil, err
+	}
+	current, err := credentials.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	verified := newVerifiedCredentials(current)
+	pool, err := initPool(verified)
+	if err != nil {
+		return nil, err
+	}
+	db := OpenDB(pool)
+
+	if err := Migrate(db); err != nil {
+		db.Close()
+		pool.Close()
+		return nil, err
+	}
+	replica, err := OpenReplica(os.Getenv("DB_REPLICA_DSN"))
+	if err != nil {
+		db.Close()
+		pool.Close()
+		return nil, err
+	}
+	return &PostgresStorage{pool: pool, db: db, replica: replica, failover: NewFailover(pool, db),
+		credentials: credentials, verified: verified, retry: retry, locking: locking}, nil
+}
+
+// VerifySchema reports how the schema differs from what the applied migrations created (see
+// VerifySchema)
+func (s *PostgresStorage) VerifySchema(ctx context.Context) ([]SchemaDrift, error) {
+	return VerifySchema(ctx, s.db)
+}
+
+// Accounts returns the PostgreSQL account repository
+func (s *PostgresStorage) Accounts() AccountRepositoryInterface {
+	return NewAccountRepository(s.db).WithReplica(s.replica).WithFailover(s.failover)
+}
+
+// Transactions returns the PostgreSQL transaction repository
+func (s *PostgresStorage) Transactions() TransactionRepositoryInterface {
+	return NewTransactionRepository(s.db).WithReplica(s.replica).WithFailover(s.failover).WithRetry(s.retry).WithLocking(s.locking)
+}
+
+// Settlements returns the PostgreSQL settlement repository
+func (s *PostgresStorage) Settlements() SettlementRepositoryInterface {
+	return NewSettlementRepository(s.db)
+}
+
+// Usage returns the PostgreSQL usage rollup repository
+func (s *PostgresStorage) Usage() UsageRepositoryInterface {
+	return NewUsageRepository(s.db)
+}
+
+// Latency returns the PostgreSQL transfer latency repository
+func (s *PostgresStorage) Latency() LatencyRepositoryInterface {
+	return NewLatencyRepository(s.db)
+}
+
+// Recurring returns the PostgreSQL recurring transfer repository
+func (s *PostgresStorage) Recurring() RecurringRepositoryInterface {
+	return NewRecurringRepository(s.db)
+}
+
+// Holds returns the PostgreSQL hold repository
+func (s *PostgresStorage) Holds() HoldRepositoryInterface {
+	return NewHoldRepository(s.db)
+}
+
+// Limits returns the PostgreSQL transfer limit repository
+func (s *PostgresStorage) Limits() LimitRepositoryInterface {
+	return NewLimitRepository(s.db)
+}
+
+// Audit returns the PostgreSQL audit log repository
+func (s *PostgresStorage) Audit() AuditRepositoryInterface {
+	return NewAuditRepository(s.db)
+}
+
+// BalanceHistory returns the PostgreSQL balance snapshot repository
+func (s *PostgresStorage) BalanceHistory() BalanceHistoryRepositoryInterface {
+	return NewBalanceHistoryRepository(s.db)
+}
+
+// Reconciliation returns the PostgreSQL balance reconciliation repository
+func (s *PostgresStorage) Reconciliation() ReconciliationRepositoryInterface {
+	return NewReconciliationRepository(s.db)
+}
+
+// Retention returns the PostgreSQL retention repository
+func (s *PostgresStorage) Retention() RetentionRepositoryInterface {
+	return NewRetentionRepository(s.db)
+}
+
+// Attachments returns the PostgreSQL attachment repository
+func (s *PostgresStorage) Attachments() AttachmentRepositoryInterface {
+	return NewAttachmentRepository(s.db)
+}
+
+// Adjustments returns the PostgreSQL adjustment repository
+func (s *PostgresStorage) Adjustments() AdjustmentRepositoryInterface {
+	return NewAdjustmentRepository(s.db)
+}
+
+// Wallets returns the PostgreSQL wallet repository
+func (s *PostgresStorage) Wallets() WalletRepositoryInterface {
+	return NewWalletRepository(s.db)
+}
+
+// Currencies returns the PostgreSQL currency repository
+func (s *PostgresStorage) Currencies() CurrencyRepositoryInterface {
+	return NewCurrencyRepository(s.db)
+}
+
+// APIKeys returns the PostgreSQL API key repository
+func (s *PostgresStorage) APIKeys() APIKeyRepositoryInterface {
+	return NewAPIKeyRepository(s.db)
+}
+
+// Outbox returns the outbox_events table reader
+func (s *PostgresStorage) Outbox() OutboxRepositoryInterface {
+	return NewOutboxRepository(s.db)
+}
+
+// PoolStats returns the current pgx pool statistics
+func (s *PostgresStorage) PoolStats() PoolStats {
+	return StatsFromPool(s.pool)
+}
+
+// Close closes the database handle, the connection pool and the replica's
+func (s *PostgresStorage) Close() error {
+	s.replica.Close()
+	err := s.db.Close()
+	s.pool.Close()
+	return err
+}
+
+// Compile-time interface implementation checks
+var _ Storage = (*PostgresStorage)(nil)
+var _ PoolStatsProvider = (*PostgresStorage)(nil)
+var _ CredentialWatcher = (*PostgresStorage)(nil)
+var _ OutboxRepositoryInterface = (*OutboxRepository)(nil)
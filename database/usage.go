@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// UsageRepository implements UsageRepositoryInterface for PostgreSQL
+type UsageRepository struct {
+	db *sql.DB
+}
+
+// NewUsageRepository creates a new usage repository instance
+func NewUsageRepository(db *sql.DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// AddUsage upserts the records into api_usage_daily, adding to the existing counters
+// Parameters:
+//   - ctx: Context bounding the write
+//   - records: Usage deltas, at most one per key and day
+//
+// Returns: Database error if any upsert fails; nothing is written in that case
+func (r *UsageRepository) AddUsage(ctx context.Context, records []models.Usage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, u := range records {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO api_usage_daily (key_id, day, requests, transfers, transfer_volume)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (key_id, day) DO UPDATE SET
+				requests = api_usage_daily.requests + EXCLUDED.requests,
+				transfers = api_usage_daily.transfers + EXCLUDED.transfers,
+				transfer_volume = api_usage_daily.transfer_volume + EXCLUDED.transfer_volume
+		`, u.KeyID, u.Day.Format("2006-01-02"), u.Requests, u.Transfers, u.TransferVolume)
+		if err != nil {
+			return fmt.Errorf("failed to record usage: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit usage: %w", err)
+	}
+	return nil
+}
+
+// ListUsage returns rollup rows for one key (or all keys if keyID is empty) within [from, to]
+// Served by the (key_id, day) primary key for a single key and the day index for reports
+func (r *UsageRepository) ListUsage(ctx context.Context, keyID string, from, to time.Time) ([]models.Usage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT key_id, day, requests, transfers, transfer_volume
+		FROM api_usage_daily
+		WHERE ($1 = '' OR key_id = $1) AND day BETWEEN $2 AND $3
+		ORDER BY key_id, day
+	`, keyID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	defer rows.Close()
+
+	records := []models.Usage{}
+	for rows.Next() {
+		var u models.Usage
+		if err := rows.Scan(&u.KeyID, &u.Day, &u.Requests, &u.Transfers, &u.TransferVolume); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		records = append(records, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	return records, nil
+}
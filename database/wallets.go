@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// WalletRepository implements WalletRepositoryInterface for PostgreSQL
+type WalletRepository struct {
+	db *sql.DB
+}
+
+// NewWalletRepository creates a new wallet repository instance
+func NewWalletRepository(db *sql.DB) *WalletRepository {
+	return &WalletRepository{db: db}
+}
+
+// OpenWallet opens an account's empty wallet in a currency other than its own
+// Parameters:
+//   - ctx: Context bounding the database transaction
+//   - accountID: The account opening the wallet
+//   - currency: A supported ISO 4217 code (validated by caller)
+//
+// Returns:
+//   - *models.Wallet: The opened wallet
+//   - error: "account not found", "account has no currency", "wallet already exists" if the
+//     account already holds the currency (as a wallet or as its own currency), or a database error
+//
+// Database behavior:
+//   - The account row is locked with FOR SHARE so its currency cannot change meanwhile
+//   - The account.wallet_opened outbox event is written in the same commit
+func (r *WalletRepository) OpenWallet(ctx context.Context, accountID int64, currency string) (*models.Wallet, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The wallet is part of the account's representation, so opening it updates the account
+	var accountCurrency string
+	err = tx.QueryRowContext(ctx, "UPDATE accounts SET updated_at = NOW() WHERE account_id = $1 RETURNING currency", accountID).Scan(&accountCurrency)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("account not found")
+		}
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if accountCurrency == "" {
+		return nil, fmt.Errorf("account has no currency")
+	}
+	if accountCurrency == currency {
+		return nil, fmt.Errorf("wallet already exists")
+	}
+
+	wallet := models.Wallet{AccountID: accountID, Currency: currency, Balance: decimal.Zero}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO account_wallets (account_id, currency)
+		VALUES ($1, $2)
+		ON CONFLICT (account_id, currency) DO NOTHING
+		RETURNING created_at
+	`, accountID, currency).Scan(&wallet.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("wallet already exists")
+		}
+		return nil, fmt.Errorf("failed to open wallet: %w", err)
+	}
+	if err := enqueueEvent(tx, EventWalletOpened, accountID, models.NewWalletResponse(wallet)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &wallet, nil
+}
+
+// ListWallets returns the account's wallets ordered by currency
+// Served by the (account_id, currency) primary key
+func (r *WalletRepository) ListWallets(ctx context.Context, accountID int64) ([]models.Wallet, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT account_id, currency, balance, created_at
+		FROM account_wallets
+		WHERE account_id = $1
+		ORDER BY currency
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	defer rows.Close()
+
+	wallets := []models.Wallet{}
+	for rows.Next() {
+		var w models.Wallet
+		if err := rows.Scan(&w.AccountID, &w.Currency, &w.Balance, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan wallet: %w", err)
+		}
+		wallets = append(wallets, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	return wallets, nil
+}
+
+// moveWalletTx changes an account's wallet balance by delta inside the caller's database
+// transaction, locking the wallet row; side ("source" or "destination") names the leg in errors
+// Returns "<side> wallet not found" if the account has no wallet in currency, or "insufficient
+// balance" if a debit exceeds the wallet's balance: wallets cannot be overdrawn or held
+func moveWalletTx(tx *sql.Tx, side string, accountID int64, currency string, delta decimal.Decimal) error {
+	var balance decimal.Decimal
+	err := tx.QueryRow("SELECT balance FROM account_wallets WHERE account_id = $1 AND currency = $2 FOR UPDATE", accountID, currency).
+		Scan(&balance)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%s wallet not found", side)
+		}
+		return fmt.Errorf("failed to get %s wallet: %w", side, err)
+	}
+	if balance.Add(delta).IsNegative() {
+		return fmt.Errorf("insufficient balance")
+	}
+	_, err = tx.Exec(`UPDATE account_wallets SET balance = balance + $3, updated_at = NOW() WHERE account_id = $1 AND currency = $2`,
+		accountID, currency, delta)
+	if err != nil {
+		return fmt.Errorf("failed to update %s wallet: %w", side, err)
+	}
+	return nil
+}
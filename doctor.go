@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"internal-transfers/attachments"
+	"internal-transfers/balances"
+	"internal-transfers/config"
+	"internal-transfers/cutoff"
+	"internal-transfers/database"
+	"internal-transfers/fx"
+	"internal-transfers/holds"
+	"internal-transfers/listeners"
+	"internal-transfers/logging"
+	"internal-transfers/middleware"
+	"internal-transfers/models"
+	"internal-transfers/outbox"
+	"internal-transfers/pagination"
+	"internal-transfers/preauth"
+	"internal-transfers/reaper"
+	"internal-transfers/recurring"
+	"internal-transfers/retention"
+	"internal-transfers/routes"
+	"internal-transfers/rules"
+	"internal-transfers/settlement"
+	"internal-transfers/shutdown"
+	"internal-transfers/signing"
+	"internal-transfers/sla"
+	"internal-transfers/transport"
+	"internal-transfers/usage"
+)
+
+// Doctor thresholds
+const (
+	// maxClockSkew is the largest difference from the database clock that is merely reported;
+	// beyond it value dates, cut-offs and latency measurements disagree between instances
+	maxClockSkew = time.Second
+
+	// Free space below lowDiskSpace is a warning and below minDiskSpace a failure for directories
+	// the service writes exports and fixtures to
+	lowDiskSpace = 1 << 30
+	minDiskSpace = 100 << 20
+
+	// doctorDialTimeout bounds each network probe
+	doctorDialTimeout = 3 * time.Second
+)
+
+// checkStatus is the outcome of one doctor check
+type checkStatus string
+
+// Check outcomes; only failures make the doctor command exit non-zero
+const (
+	checkOK   checkStatus = "OK"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+	checkSkip checkStatus = "SKIP"
+)
+
+// checkResult is one line of the doctor report, with the action to take when it is not OK
+type checkResult struct {
+	Name   string
+	Status checkStatus
+	Detail string
+	Fix    string
+}
+
+// runDoctorCommand checks that the environment the server would start in is sane and prints a
+// report: configuration, database connectivity, extensions, migration status, schema drift, clock
+// skew, and the directories and brokers the service writes to
+// Returns an error if any check failed, so the command exits non-zero
+func runDoctorCommand(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: doctor")
+	}
+	results := runDoctor(context.Background())
+	failures := printDoctorReport(os.Stdout, results)
+	if failures > 0 {
+		return fmt.Errorf("doctor found %d failing checks", failures)
+	}
+	return nil
+}
+
+// runDoctor runs every check against the current environment
+func runDoctor(ctx context.Context) []checkResult {
+	results := []checkResult{checkConfiguration()}
+
+	if storage := getStorage(); storage != "postgres" {
+		for _, name := range []string{"database", "extensions", "migrations", "schema", "clock"} {
+			results = append(results, checkResult{Name: name, Status: checkSkip, Detail: fmt.Sprintf("STORAGE is %s", storage)})
+		}
+	} else {
+		results = append(results, checkDatabase(ctx)...)
+		if dsn := os.Getenv("DB_REPLICA_DSN"); dsn != "" {
+			results = append(results, checkReplica(ctx, dsn))
+		}
+	}
+
+	if dir := os.Getenv("SETTLEMENT_EXPORT_DIR"); dir != "" {
+		results = append(results, checkDirectory("settlement export dir", dir))
+	}
+	if dir := os.Getenv("FIXTURE_RECORD_DIR"); dir != "" {
+		results = append(results, checkDirectory("fixture record dir", dir))
+	}
+	if kafka := outbox.LoadKafkaConfig(); kafka.Enabled() {
+		results = append(results, checkBrokers(ctx, kafka.Brokers))
+	}
+	return results
+}
+
+// printDoctorReport writes the report and returns the number of failed checks
+func printDoctorReport(w io.Writer, results []checkResult) int {
+	counts := make(map[checkStatus]int)
+	for _, result := range results {
+		counts[result.Status]++
+		fmt.Fprintf(w, "%-4s  %-22s %s\n", result.Status, result.Name, result.Detail)
+		if result.Fix != "" && (result.Status == checkFail || result.Status == checkWarn) {
+			fmt.Fprintf(w, "      %-22s fix: %s\n", "", result.Fix)
+		}
+	}
+	fmt.Fprintf(w, "\n%d ok, %d warnings, %d failures, %d skipped\n",
+		counts[checkOK], counts[checkWarn], counts[checkFail], counts[checkSkip])
+	return counts[checkFail]
+}
+
+// checkConfiguration parses every setting the server reads at startup
+func checkConfiguration() checkResult {
+	var problems []string
+	if _, ok := storageBackends[getStorage()]; !ok {
+		problems = append(problems, fmt.Sprintf("unknown STORAGE %q (expected postgres, memory or eventsourced)", getStorage()))
+	}
+	if _, err := models.ParseRoundingPolicy(os.Getenv("ROUNDING_POLICY")); err != nil {
+		problems = append(problems, err.Error())
+	}
+	loaders := []func() error{
+		func() error {
+			config, err := logging.LoadConfig()
+			if err == nil {
+				_, err = logging.LoadAccessConfig(config)
+			}
+			return err
+		},
+		func() error { _, err := cutoff.Load(); return err },
+		func() error { _, err := settlement.LoadConfig(); return err },
+		func() error { _, err := rules.Load(); return err },
+		func() error { _, err := preauth.LoadConfig(); return err },
+		func() error { _, err := usage.LoadConfig(); return err },
+		func() error { _, err := sla.LoadConfig(); return err },
+		func() error { _, err := retention.LoadConfig(); return err },
+		func() error { _, err := reaper.LoadConfig(); return err },
+		func() error { _, err := attachments.LoadConfig(); return err },
+		func() error { _, err := fx.Load(); return err },
+		func() error { _, err := recurring.LoadConfig(); return err },
+		func() error { _, err := holds.LoadConfig(); return err },
+		func() error { _, err := listeners.LoadConfig(); return err },
+		func() error { _, err := transport.LoadConfig(); return err },
+		func() error { _, err := routes.LoadConfig(); return err },
+		func() error { _, err := balances.LoadConfig(); return err },
+		func() error { _, _, err := pagination.Load(); return err },
+		func() error { _, err := shutdown.LoadConfig(); return err },
+		func() error { _, err := database.LoadRetryConfig(); return err },
+		func() error { _, err := database.LoadLockingMode(); return err },
+		func() error { _, err := database.LoadCredentialConfig(); return err },
+		func() error { _, err := config.ReloadInterval(); return err },
+		func() error { _, err := middleware.LoadAllowlist(); return err },
+		func() error { _, err := signing.LoadConfig(); return err },
+	}
+	for _, load := range loaders {
+		if err := load(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return checkResult{Name: "configuration", Status: checkFail, Detail: strings.Join(problems, "; "),
+			Fix: "correct the environment variables named above (see README, Environment Variables)"}
+	}
+	return checkResult{Name: "configuration", Status: checkOK, Detail: "all settings are valid"}
+}
+
+// checkDatabase connects to PostgreSQL and checks its extensions, migrations, schema and clock
+// When the database is unreachable the dependent checks are skipped
+func checkDatabase(ctx context.Context) []checkResult {
+	db, err := database.InitDB()
+	if err != nil {
+		results := []checkResult{{Name: "database", Status: checkFail, Detail: err.Error(),
+			Fix: "check DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME and DB_SSLMODE, and that the server accepts connections from this host"}}
+		for _, name := range []string{"extensions", "migrations", "schema", "clock"} {
+			results = append(results, checkResult{Name: name, Status: checkSkip, Detail: "database unreachable"})
+		}
+		return results
+	}
+	defer db.Close()
+
+	info, err := database.GetServerInfo(ctx, db)
+	if err != nil {
+		return []checkResult{{Name: "database", Status: checkFail, Detail: err.Error(),
+			Fix: "check that DB_USER may query the database"}}
+	}
+	return []checkResult{
+		{Name: "database", Status: checkOK, Detail: fmt.Sprintf("connected to PostgreSQL %s in %s", info.Version, info.RoundTrip.Round(time.Millisecond))},
+		checkExtensions(ctx, db),
+		checkMigrations(db),
+		checkSchema(ctx, db),
+		checkClock(info),
+	}
+}
+
+// checkReplica connects to the read replica; an unreachable one only warns, as reads then fall back
+// to the primary
+func checkReplica(ctx context.Context, dsn string) checkResult {
+	replica, err := database.OpenReplica(dsn)
+	if err != nil {
+		return checkResult{Name: "replica", Status: checkFail, Detail: err.Error(), Fix: "correct DB_REPLICA_DSN"}
+	}
+	defer replica.Close()
+	if err := replica.Ping(ctx); err != nil {
+		return checkResult{Name: "replica", Status: checkWarn, Detail: err.Error(),
+			Fix: "check that the replica accepts connections from this host; until then reads use the primary"}
+	}
+	return checkResult{Name: "replica", Status: checkOK, Detail: "read replica reachable"}
+}
+
+// checkExtensions reports required extensions the server cannot provide
+func checkExtensions(ctx context.Context, db *sql.DB) checkResult {
+	if len(database.RequiredExtensions) == 0 {
+		return checkResult{Name: "extensions", Status: checkOK, Detail: "no extensions required"}
+	}
+	missing, err := database.MissingExtensions(ctx, db, database.RequiredExtensions)
+	if err != nil {
+		return checkResult{Name: "extensions", Status: checkFail, Detail: err.Error()}
+	}
+	if len(missing) > 0 {
+		return checkResult{Name: "extensions", Status: checkFail, Detail: "not available: " + strings.Join(missing, ", "),
+			Fix: "install the extension packages on the database server"}
+	}
+	return checkResult{Name: "extensions", Status: checkOK, Detail: "available: " + strings.Join(database.RequiredExtensions, ", ")}
+}
+
+// checkMigrations compares the schema with the migrations compiled into this binary
+// Pending migrations are only a warning since the server applies them at startup
+func checkMigrations(db *sql.DB) checkResult {
+	pending, unknown, err := database.MigrationStatus(db)
+	switch {
+	case err != nil:
+		return checkResult{Name: "migrations", Status: checkFail, Detail: err.Error()}
+	case len(unknown) > 0:
+		return checkResult{Name: "migrations", Status: checkFail, Detail: fmt.Sprintf("database has migrations this build does not know: %v", unknown),
+			Fix: "deploy the release that applied them, or roll them back with it (migrate down)"}
+	case len(pending) > 0:
+		names := make([]string, 0, len(pending))
+		for _, m := range pending {
+			names = append(names, fmt.Sprintf("%04d_%s", m.Version, m.Name))
+		}
+		return checkResult{Name: "migrations", Status: checkWarn, Detail: fmt.Sprintf("%d pending: %s", len(pending), strings.Join(names, ", ")),
+			Fix: "run migrate up, or let the server apply them at startup"}
+	}
+	return checkResult{Name: "migrations", Status: checkOK, Detail: "schema is up to date"}
+}
+
+// checkSchema compares the indexes and constraints in the database with those the applied
+// migrations created; a dropped index fails no query but can slow lookups by orders of magnitude
+func checkSchema(ctx context.Context, db *sql.DB) checkResult {
+	drift, err := database.VerifySchema(ctx, db)
+	if err != nil {
+		return checkResult{Name: "schema", Status: checkFail, Detail: err.Error()}
+	}
+	if len(drift) > 0 {
+		problems := make([]string, 0, len(drift))
+		fixes := make([]string, 0, len(drift))
+		for _, d := range drift {
+			problems = append(problems, d.String())
+			fixes = append(fixes, d.Fix)
+		}
+		return checkResult{Name: "schema", Status: checkFail, Detail: fmt.Sprintf("%d drifted: %s", len(drift), strings.Join(problems, "; ")),
+			Fix: strings.Join(fixes, " ")}
+	}
+	return checkResult{Name: "schema", Status: checkOK, Detail: "indexes and constraints match the migrations"}
+}
+
+// checkClock compares the local clock with the database's
+func checkClock(info database.ServerInfo) checkResult {
+	skew := info.ClockSkew.Round(time.Millisecond)
+	if skew > maxClockSkew || skew < -maxClockSkew {
+		return checkResult{Name: "clock", Status: checkFail, Detail: fmt.Sprintf("local clock differs from the database by %s", skew),
+			Fix: "synchronize this host and the database server with NTP"}
+	}
+	return checkResult{Name: "clock", Status: checkOK, Detail: fmt.Sprintf("within %s of the database", skew.Abs())}
+}
+
+// checkDirectory checks that the service can write to dir and that it has room to
+// A missing directory passes when it can be created, as the writers create it on first use
+func checkDirectory(name, dir string) checkResult {
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return checkResult{Name: name, Status: checkFail, Detail: existing + " is not a directory",
+					Fix: "point the setting at a directory"}
+			}
+			break
+		}
+		parent := filepath.Dir(existing)
+		if !errors.Is(err, fs.ErrNotExist) || parent == existing {
+			return checkResult{Name: name, Status: checkFail, Detail: err.Error()}
+		}
+		existing = parent
+	}
+
+	probe, err := os.CreateTemp(existing, ".doctor-*")
+	if err != nil {
+		return checkResult{Name: name, Status: checkFail, Detail: fmt.Sprintf("%s is not writable: %v", existing, err),
+			Fix: fmt.Sprintf("grant the service user write permission on %s", existing)}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	detail := dir + " is writable"
+	if existing != dir {
+		detail = fmt.Sprintf("%s will be created in %s", dir, existing)
+	}
+	free, ok := freeDiskSpace(existing)
+	if !ok {
+		return checkResult{Name: name, Status: checkOK, Detail: detail}
+	}
+	detail += fmt.Sprintf(", %s free", formatBytes(free))
+	switch {
+	case free < minDiskSpace:
+		return checkResult{Name: name, Status: checkFail, Detail: detail, Fix: "free up or add disk space"}
+	case free < lowDiskSpace:
+		return checkResult{Name: name, Status: checkWarn, Detail: detail, Fix: "free up or add disk space"}
+	}
+	return checkResult{Name: name, Status: checkOK, Detail: detail}
+}
+
+// checkBrokers checks that every Kafka broker accepts TCP connections
+func checkBrokers(ctx context.Context, brokers []string) checkResult {
+	var unreachable []string
+	dialer := net.Dialer{Timeout: doctorDialTimeout}
+	for _, broker := range brokers {
+		conn, err := dialer.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			unreachable = append(unreachable, broker)
+			continue
+		}
+		conn.Close()
+	}
+	if len(unreachable) > 0 {
+		return checkResult{Name: "kafka brokers", Status: checkFail, Detail: "unreachable: " + strings.Join(unreachable, ", "),
+			Fix: "check KAFKA_BROKERS and that the brokers accept connections from this host"}
+	}
+	return checkResult{Name: "kafka brokers", Status: checkOK, Detail: fmt.Sprintf("%d reachable", len(brokers))}
+}
+
+// formatBytes renders a byte count in binary units
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// freeDiskSpace is not implemented on this platform; the doctor only checks writability
+func freeDiskSpace(path string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,533 @@
+// Package eventsource provides the event-sourced storage backend (STORAGE=eventsourced)
+// The ledger (accounts and their wallets, transfers and their settlement, manual adjustments) is
+// stored only as an append-only log of events in a file; balances and transactions are a
+// projection rebuilt by replaying the log when the store opens, and account event streams fold
+// their balances from the events on demand.
+// Everything else (holds, limits, recurring rules, usage, audit, ...) is kept in memory like
+// STORAGE=memory and lost on exit
+package eventsource
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+	"internal-transfers/memory"
+	"internal-transfers/models"
+)
+
+// DefaultPath is the event log used when EVENT_LOG_PATH is unset
+const DefaultPath = "ledger-events.jsonl"
+
+// errLogFailed is returned for ledger writes once the log could not be written: the change is
+// applied to the projection but not durable, so the store refuses further changes until it is
+// reopened, which replays the log without it
+var errLogFailed = errors.New("event log unavailable")
+
+// Config locates the event log
+type Config struct {
+	Path string
+}
+
+// LoadConfig reads the event log's location from EVENT_LOG_PATH (ledger-events.jsonl by default)
+func LoadConfig() Config {
+	path := os.Getenv("EVENT_LOG_PATH")
+	if path == "" {
+		path = DefaultPath
+	}
+	return Config{Path: path}
+}
+
+// Store is a database.Storage whose ledger is an event log
+// Changes are validated and applied by the in-memory projection, then the events describing them
+// are appended to the log and synced before the change is acknowledged. Ledger changes are
+// serialized, so the log's order is the order they were applied in
+type Store struct {
+	mu         sync.RWMutex
+	projection *memory.Store
+	log        *os.File
+	events     []models.LedgerEvent
+	// byAccount indexes each account's events in events
+	byAccount map[int64][]int
+	failed    error
+}
+
+// Open opens the event log at path, creating it if needed, and replays it
+// An event cut short by a crash while it was written is discarded; it was never acknowledged
+// Returns an error if the log cannot be read or an event does not apply
+func Open(path string) (*Store, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	events, end, err := readLog(file)
+	if err == nil {
+		err = truncateTornEvent(file, end)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	projection, err := Replay(events)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s := &Store{projection: projection, log: file, byAccount: make(map[int64][]int)}
+	for _, event := range events {
+		s.index(event)
+	}
+	slog.Info("Replayed event log", "path", path, "events", len(events))
+	return s, nil
+}
+
+// Replay rebuilds the ledger from events, which must be the complete log in sequence order
+// Returns the projection, or an error naming the first event that is out of sequence or does not
+// apply
+func Replay(events []models.LedgerEvent) (*memory.Store, error) {
+	projection := memory.NewStore()
+	for i, event := range events {
+		if event.Sequence != int64(i)+1 {
+			return nil, fmt.Errorf("event log out of sequence at event %d (expected %d)", event.Sequence, i+1)
+		}
+		if err := projection.Apply(event); err != nil {
+			return nil, fmt.Errorf("failed to replay event log: %w", err)
+		}
+	}
+	return projection, nil
+}
+
+// readLog decodes the events of a log, one JSON object per line
+// Returns the events and the offset after the last complete line
+func readLog(file *os.File) ([]models.LedgerEvent, int64, error) {
+	var (
+		events []models.LedgerEvent
+		end    int64
+	)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A final line without its newline was not completely written
+			return events, end, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read event log: %w", err)
+		}
+		var event models.LedgerEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, 0, fmt.Errorf("corrupt event log at offset %d: %w", end, err)
+		}
+		events = append(events, event)
+		end += int64(len(line))
+	}
+}
+
+// truncateTornEvent cuts the log after its last complete event and positions it for appending
+func truncateTornEvent(file *os.File, end int64) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read event log: %w", err)
+	}
+	if info.Size() > end {
+		slog.Warn("Discarding an incompletely written event at the end of the event log", "bytes", info.Size()-end)
+		if err := file.Truncate(end); err != nil {
+			return fmt.Errorf("failed to truncate event log: %w", err)
+		}
+	}
+	if _, err := file.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read event log: %w", err)
+	}
+	return nil
+}
+
+// commit applies a change to the projection and appends the events describing it to the log
+// change returns no events when it changed nothing
+func (s *Store) commit(change func() ([]models.LedgerEvent, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failed != nil {
+		return errLogFailed
+	}
+	events, err := change()
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	if err := s.append(events); err != nil {
+		s.failed = err
+		slog.Error("Event log write failed; refusing ledger changes until restarted", "error", err)
+		return errLogFailed
+	}
+	return nil
+}
+
+// append numbers the events and writes them to the log in one synced write; the caller must hold
+// the write lock
+func (s *Store) append(events []models.LedgerEvent) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range events {
+		events[i].Sequence = int64(len(s.events) + i + 1)
+		if err := encoder.Encode(events[i]); err != nil {
+			return err
+		}
+	}
+	if _, err := s.log.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	for _, event := range events {
+		s.index(event)
+	}
+	return nil
+}
+
+// index adds a logged event to the store's events; the caller must hold the write lock or own
+// the store exclusively
+func (s *Store) index(event models.LedgerEvent) {
+	s.events = append(s.events, event)
+	for _, accountID := range event.AccountIDs() {
+		s.byAccount[accountID] = append(s.byAccount[accountID], len(s.events)-1)
+	}
+}
+
+// AccountEvents returns the account's events after the sequence number after, in order and capped
+// at limit, with the balance folded from the account's events up to each one; movements of the
+// account's wallets do not change it
+// Returns "account not found" if the account has no events
+func (s *Store) AccountEvents(ctx context.Context, accountID, after int64, limit int) ([]models.AccountEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	positions, exists := s.byAccount[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	events := []models.AccountEvent{}
+	balance := decimal.Zero
+	for _, position := range positions {
+		if len(events) == limit {
+			break
+		}
+		event := s.events[position]
+		switch {
+		case event.Type == models.LedgerAccountOpened:
+			balance = *event.Balance
+		case event.Adjustment != nil:
+			balance = balance.Add(event.Adjustment.Amount)
+		case event.Transaction != nil && event.Transaction.Wallet(accountID) == "":
+			balance = balance.Add(event.Transaction.Movement(accountID))
+		}
+		if event.Sequence > after {
+			events = append(events, models.AccountEvent{Event: event, Balance: balance})
+		}
+	}
+	return events, nil
+}
+
+// Accounts returns the account repository; changes are recorded as events
+func (s *Store) Accounts() database.AccountRepositoryInterface {
+	return &accountRepository{AccountRepositoryInterface: s.projection.Accounts(), store: s}
+}
+
+// Transactions returns the transaction repository; transfers are recorded as events
+func (s *Store) Transactions() database.TransactionRepositoryInterface {
+	return &transactionRepository{TransactionRepositoryInterface: s.projection.Transactions(), store: s}
+}
+
+// Settlements returns the settlement repository; settlements and returns are recorded as events
+func (s *Store) Settlements() database.SettlementRepositoryInterface {
+	return &settlementRepository{SettlementRepositoryInterface: s.projection.Settlements(), store: s}
+}
+
+// Outbox returns nil: ledger events are not published
+func (s *Store) Outbox() database.OutboxRepositoryInterface {
+	return nil
+}
+
+// Usage returns the in-memory usage rollup
+func (s *Store) Usage() database.UsageRepositoryInterface {
+	return s.projection.Usage()
+}
+
+// Latency returns the in-memory transfer latency samples
+func (s *Store) Latency() database.LatencyRepositoryInterface {
+	return s.projection.Latency()
+}
+
+// Recurring returns the in-memory recurring transfer rules; their transfers are recorded as events
+func (s *Store) Recurring() database.RecurringRepositoryInterface {
+	return s.projection.Recurring()
+}
+
+// Holds returns the in-memory holds; captures are recorded as transfer events
+func (s *Store) Holds() database.HoldRepositoryInterface {
+	return s.projection.Holds()
+}
+
+// Limits returns the in-memory transfer limits
+func (s *Store) Limits() database.LimitRepositoryInterface {
+	return s.projection.Limits()
+}
+
+// Currencies returns the in-memory currency configuration, which is not part of the ledger
+func (s *Store) Currencies() database.CurrencyRepositoryInterface {
+	return s.projection.Currencies()
+}
+
+// APIKeys returns the in-memory API keys, which are not part of the ledger
+func (s *Store) APIKeys() database.APIKeyRepositoryInterface {
+	return s.projection.APIKeys()
+}
+
+// Audit returns the in-memory audit log
+func (s *Store) Audit() database.AuditRepositoryInterface {
+	return s.projection.Audit()
+}
+
+// BalanceHistory returns the balance snapshots of the projection, rebuilt with it on replay
+func (s *Store) BalanceHistory() database.BalanceHistoryRepositoryInterface {
+	return s.projection.BalanceHistory()
+}
+
+// Reconciliation returns the reconciliation of the projection's balances against its transactions
+func (s *Store) Reconciliation() database.ReconciliationRepositoryInterface {
+	return s.projection.Reconciliation()
+}
+
+// Retention returns nil: the event log is never purged
+func (s *Store) Retention() database.RetentionRepositoryInterface {
+	return nil
+}
+
+// Attachments returns the in-memory attachment records
+func (s *Store) Attachments() database.AttachmentRepositoryInterface {
+	return s.projection.Attachments()
+}
+
+// Adjustments returns the adjustment repository; adjustments are recorded as events
+func (s *Store) Adjustments() database.AdjustmentRepositoryInterface {
+	return &adjustmentRepository{AdjustmentRepositoryInterface: s.projection.Adjustments(), store: s}
+}
+
+// Wallets returns the wallet repository; openings are recorded as events
+func (s *Store) Wallets() database.WalletRepositoryInterface {
+	return &walletRepository{WalletRepositoryInterface: s.projection.Wallets(), store: s}
+}
+
+// Close closes the event log
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
+
+// accountRepository records account changes made through the projection's repository
+type accountRepository struct {
+	database.AccountRepositoryInterface
+	store *Store
+}
+
+// CreateAccount opens the account and records account.opened
+func (r *accountRepository) CreateAccount(account models.NewAccount) error {
+	return r.store.commit(func() ([]models.LedgerEvent, error) {
+		if err := r.AccountRepositoryInterface.CreateAccount(account); err != nil {
+			return nil, err
+		}
+		created, err := r.AccountRepositoryInterface.GetAccount(account.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		return []models.LedgerEvent{{
+			Type: models.LedgerAccountOpened, OccurredAt: created.CreatedAt,
+			AccountID: account.AccountID, Balance: &account.InitialBalance, Currency: account.Currency,
+			ExternalID: account.ExternalID, CreationToken: account.CreationToken,
+		}}, nil
+	})
+}
+
+// SetAccountStatus freezes or unfreezes the account and records account.status_changed if the
+// status changed
+func (r *accountRepository) SetAccountStatus(accountID int64, status string) (*models.Account, error) {
+	var account *models.Account
+	err := r.store.commit(func() ([]models.LedgerEvent, error) {
+		before, err := r.AccountRepositoryInterface.GetAccount(accountID)
+		if err != nil {
+			return nil, err
+		}
+		if account, err = r.AccountRepositoryInterface.SetAccountStatus(accountID, status); err != nil || account.Status == before.Status {
+			return nil, err
+		}
+		return []models.LedgerEvent{{Type: models.LedgerAccountStatusChanged, OccurredAt: time.Now().UTC(), AccountID: accountID, Status: status}}, nil
+	})
+	return account, err
+}
+
+// SetOverdraftLimit changes the account's overdraft limit and records account.overdraft_changed
+// if it changed
+func (r *accountRepository) SetOverdraftLimit(accountID int64, limit decimal.Decimal) (*models.Account, error) {
+	var account *models.Account
+	err := r.store.commit(func() ([]models.LedgerEvent, error) {
+		before, err := r.AccountRepositoryInterface.GetAccount(accountID)
+		if err != nil {
+			return nil, err
+		}
+		if account, err = r.AccountRepositoryInterface.SetOverdraftLimit(accountID, limit); err != nil || account.OverdraftLimit.Equal(before.OverdraftLimit) {
+			return nil, err
+		}
+		return []models.LedgerEvent{{Type: models.LedgerOverdraftChanged, OccurredAt: time.Now().UTC(), AccountID: accountID, OverdraftLimit: &limit}}, nil
+	})
+	return account, err
+}
+
+// UpdateAccount changes the account's metadata, tags and external ID and records account.updated
+// with the result
+func (r *accountRepository) UpdateAccount(accountID int64, update models.AccountUpdate) (*models.Account, error) {
+	var account *models.Account
+	err := r.store.commit(func() ([]models.LedgerEvent, error) {
+		var err error
+		if account, err = r.AccountRepositoryInterface.UpdateAccount(accountID, update); err != nil {
+			return nil, err
+		}
+		return []models.LedgerEvent{{
+			Type: models.LedgerAccountUpdated, OccurredAt: time.Now().UTC(),
+			AccountID: accountID, Metadata: account.Metadata, Tags: account.Tags, ExternalID: account.ExternalID,
+		}}, nil
+	})
+	return account, err
+}
+
+// transactionRepository records transfers made through the projection's repository
+type transactionRepository struct {
+	database.TransactionRepositoryInterface
+	store *Store
+}
+
+// CreateTransaction commits the transfer and records transfer.committed
+// The wait for the store's write lock is part of the transaction's lock wait
+func (r *transactionRepository) CreateTransaction(transfer models.Transfer) (*models.Transaction, error) {
+	start := time.Now()
+	var transaction *models.Transaction
+	err := r.store.commit(func() ([]models.LedgerEvent, error) {
+		lockWait := time.Since(start)
+		var err error
+		if transaction, err = r.TransactionRepositoryInterface.CreateTransaction(transfer); err != nil {
+			return nil, err
+		}
+		transaction.LockWait += lockWait
+		return []models.LedgerEvent{transferCommitted(*transaction)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// transferCommitted returns the event recording a committed transaction
+func transferCommitted(transaction models.Transaction) models.LedgerEvent {
+	return models.LedgerEvent{Type: models.LedgerTransferCommitted, OccurredAt: transaction.CreatedAt, Transaction: &transaction}
+}
+
+// settlementRepository records settlements and returns made through the projection's repository
+type settlementRepository struct {
+	database.SettlementRepositoryInterface
+	store *Store
+}
+
+// SettleTransaction marks the transaction settled and records transaction.settled unless it
+// already was
+func (r *settlementRepository) SettleTransaction(ctx context.Context, transactionID int64) error {
+	return r.store.commit(func() ([]models.LedgerEvent, error) {
+		before, err := r.SettlementRepositoryInterface.GetTransaction(ctx, transactionID)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.SettlementRepositoryInterface.SettleTransaction(ctx, transactionID); err != nil || before.SettlementStatus == models.SettlementSettled {
+			return nil, err
+		}
+		return []models.LedgerEvent{{Type: models.LedgerTransactionSettled, OccurredAt: time.Now().UTC(), TransactionID: transactionID}}, nil
+	})
+}
+
+// ReturnTransaction books the reversal of a returned transfer and records it as transfer.committed
+// followed by transaction.returned
+func (r *settlementRepository) ReturnTransaction(ctx context.Context, transactionID int64, valueDate time.Time) (*models.Transaction, error) {
+	var reversal *models.Transaction
+	err := r.store.commit(func() ([]models.LedgerEvent, error) {
+		var err error
+		if reversal, err = r.SettlementRepositoryInterface.ReturnTransaction(ctx, transactionID, valueDate); err != nil {
+			return nil, err
+		}
+		return []models.LedgerEvent{
+			transferCommitted(*reversal),
+			{Type: models.LedgerTransactionReturned, OccurredAt: reversal.CreatedAt, TransactionID: transactionID},
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+// adjustmentRepository records adjustments made through the projection's repository
+type adjustmentRepository struct {
+	database.AdjustmentRepositoryInterface
+	store *Store
+}
+
+// CreateAdjustment books the adjustment and records account.adjusted with it
+func (r *adjustmentRepository) CreateAdjustment(ctx context.Context, adjustment models.Adjustment) (*models.Adjustment, error) {
+	var booked *models.Adjustment
+	err := r.store.commit(func() ([]models.LedgerEvent, error) {
+		var err error
+		if booked, err = r.AdjustmentRepositoryInterface.CreateAdjustment(ctx, adjustment); err != nil {
+			return nil, err
+		}
+		return []models.LedgerEvent{{Type: models.LedgerAccountAdjusted, OccurredAt: booked.CreatedAt, AccountID: booked.AccountID, Adjustment: booked}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return booked, nil
+}
+
+// walletRepository records wallets opened through the projection's repository
+type walletRepository struct {
+	database.WalletRepositoryInterface
+	store *Store
+}
+
+// OpenWallet opens the wallet and records account.wallet_opened with it
+func (r *walletRepository) OpenWallet(ctx context.Context, accountID int64, currency string) (*models.Wallet, error) {
+	var opened *models.Wallet
+	err := r.store.commit(func() ([]models.LedgerEvent, error) {
+		var err error
+		if opened, err = r.WalletRepositoryInterface.OpenWallet(ctx, accountID, currency); err != nil {
+			return nil, err
+		}
+		return []models.LedgerEvent{{Type: models.LedgerWalletOpened, OccurredAt: opened.CreatedAt, AccountID: accountID, Wallet: opened}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return opened, nil
+}
+
+// Compile-time interface implementation checks
+var _ database.Storage = (*Store)(nil)
+var _ database.LedgerEventSource = (*Store)(nil)
@@ -0,0 +1,240 @@
+package eventsource
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// ledgerState returns the store's accounts and transactions as JSON, which replaying the log must
+// reproduce
+func ledgerState(t *testing.T, s *Store) string {
+	t.Helper()
+	accounts, _, err := s.Accounts().ListAccounts(models.AccountFilter{}, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var transactions []models.Transaction
+	for _, account := range accounts {
+		list, err := s.Transactions().ListTransactions(account.AccountID, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, transaction := range list {
+			// Times are compared as instants; the log keeps them in UTC
+			transaction.CreatedAt, transaction.EffectiveAt = transaction.CreatedAt.UTC(), transaction.EffectiveAt.UTC()
+			transaction.ValueDate, transaction.FXRateTimestamp = transaction.ValueDate.UTC(), transaction.FXRateTimestamp.UTC()
+			transactions = append(transactions, transaction)
+		}
+	}
+	for i := range accounts {
+		accounts[i].CreatedAt = accounts[i].CreatedAt.UTC()
+		if len(accounts[i].Metadata) == 0 {
+			accounts[i].Metadata = nil
+		}
+	}
+	state, err := json.Marshal(map[string]any{"accounts": accounts, "transactions": transactions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(state)
+}
+
+func TestStore_ReplaysLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	ctx := context.Background()
+	accounts, transactions := store.Accounts(), store.Transactions()
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), ExternalID: "ERP-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero}); err != nil {
+		t.Fatal(err)
+	}
+	first, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(40)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(500)}); err == nil {
+		t.Fatal("Expected insufficient balance")
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(15), Reference: "INV-1"}); err != nil {
+		t.Fatal(err)
+	}
+	externalID := "ERP-2"
+	if _, err := accounts.UpdateAccount(1, models.AccountUpdate{Tags: []string{"payroll"}, ExternalID: &externalID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounts.SetOverdraftLimit(2, decimal.NewFromInt(50)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounts.SetAccountStatus(2, models.AccountFrozen); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounts.SetAccountStatus(2, models.AccountActive); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Settlements().ReturnTransaction(ctx, first.ID, time.Now().UTC().Truncate(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Adjustments().CreateAdjustment(ctx, models.Adjustment{AccountID: 2, Amount: decimal.NewFromInt(-3), Reason: "Duplicate fee", Actor: "jdoe"}); err != nil {
+		t.Fatal(err)
+	}
+	want := ledgerState(t, store)
+	if len(store.events) != 11 {
+		t.Errorf("Expected 11 events (2 openings, 3 transfers, 1 update, 1 overdraft, 2 status, 1 return, 1 adjustment), got %d", len(store.events))
+	}
+	store.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	defer reopened.Close()
+	if got := ledgerState(t, reopened); got != want {
+		t.Errorf("Replayed ledger differs:\n got %s\nwant %s", got, want)
+	}
+
+	// Transfers continue after the replayed ones
+	next, err := reopened.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+	if err != nil || next.ID != 4 {
+		t.Errorf("Expected transaction 4 after replay, got %+v, %v", next, err)
+	}
+	if _, err := reopened.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(1), Reference: "INV-1"}); err == nil {
+		t.Error("Expected the replayed reference to be a duplicate")
+	}
+	if account, err := reopened.Accounts().GetAccountByExternalID("ERP-2"); err != nil || account.AccountID != 1 {
+		t.Errorf("Expected the replayed external ID of account 1, got %+v, %v", account, err)
+	}
+	if adjustment, err := reopened.Adjustments().CreateAdjustment(ctx, models.Adjustment{AccountID: 1, Amount: decimal.NewFromInt(1), Reason: "Rounding", Actor: "jdoe"}); err != nil || adjustment.ID != 2 {
+		t.Errorf("Expected adjustment 2 after replay, got %+v, %v", adjustment, err)
+	}
+	if events, err := reopened.AccountEvents(ctx, 2, 10, 1); err != nil || len(events) != 1 || events[0].Event.Adjustment == nil || events[0].Balance.String() != "-18" {
+		t.Errorf("Expected the replayed adjustment with balance -18, got %+v, %v", events, err)
+	}
+}
+
+func TestStore_ReplaysWallets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	ctx := context.Background()
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "USD"})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100), Currency: "EUR"})
+	if _, err := store.Wallets().OpenWallet(ctx, 1, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(30), Currency: "EUR"}); err != nil {
+		t.Fatal(err)
+	}
+	// The wallet leg is in the account's history but leaves its primary balance alone
+	if events, err := store.AccountEvents(ctx, 1, 0, 10); err != nil || len(events) == 0 || events[len(events)-1].Event.Transaction == nil ||
+		events[len(events)-1].Balance.String() != "100" {
+		t.Errorf("Expected the wallet transfer at a balance of 100, got %+v, %v", events, err)
+	}
+	store.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	defer reopened.Close()
+	wallets, err := reopened.Wallets().ListWallets(ctx, 1)
+	if err != nil || len(wallets) != 1 || wallets[0].Balance.String() != "30" {
+		t.Errorf("Expected the replayed EUR wallet of 30, got %+v, %v", wallets, err)
+	}
+	if _, err := reopened.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(31), Currency: "EUR"}); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("Expected the replayed wallet to bound debits, got %v", err)
+	}
+}
+
+func TestStore_AccountEvents(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for _, id := range []int64{1, 2, 3} {
+		store.Accounts().CreateAccount(models.NewAccount{AccountID: id, InitialBalance: decimal.NewFromInt(100)})
+	}
+	for _, transfer := range []models.Transfer{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)},
+		{SourceAccountID: 2, DestinationAccountID: 3, Amount: decimal.NewFromInt(5)},
+		{SourceAccountID: 3, DestinationAccountID: 1, Amount: decimal.NewFromInt(10)},
+	} {
+		if _, err := store.Transactions().CreateTransaction(transfer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := store.AccountEvents(context.Background(), 1, 0, 10)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("Expected 3 events of account 1, got %d, %v", len(events), err)
+	}
+	for i, want := range []struct {
+		sequence int64
+		balance  string
+	}{{1, "100"}, {4, "70"}, {6, "80"}} {
+		if events[i].Event.Sequence != want.sequence || events[i].Balance.String() != want.balance {
+			t.Errorf("Event %d: expected sequence %d with balance %s, got %d with %s", i, want.sequence, want.balance, events[i].Event.Sequence, events[i].Balance)
+		}
+	}
+	// A later page still folds the balance from the account's first event
+	if events, _ := store.AccountEvents(context.Background(), 1, 4, 10); len(events) != 1 || events[0].Balance.String() != "80" {
+		t.Errorf("Expected the last event with balance 80, got %+v", events)
+	}
+	if _, err := store.AccountEvents(context.Background(), 9, 0, 10); err == nil || err.Error() != "account not found" {
+		t.Errorf("Expected account not found, got %v", err)
+	}
+}
+
+func TestOpen_TornEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Close()
+
+	// A crash cut the second event short
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"sequence":2,"type":"account.op`)
+	file.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected the torn event to be discarded, got %v", err)
+	}
+	if err := reopened.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero}); err != nil {
+		t.Fatal(err)
+	}
+	reopened.Close()
+	if reopened, err = Open(path); err != nil || len(reopened.events) != 2 {
+		t.Fatalf("Expected 2 events after appending past the torn one, got %v", err)
+	}
+	reopened.Close()
+
+	// A damaged event before the end is corruption, not a torn write
+	if err := os.WriteFile(path, []byte("{not json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Expected a corrupt log to be refused")
+	}
+}
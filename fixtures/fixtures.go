@@ -0,0 +1,123 @@
+// Package fixtures records sanitized request/response pairs and replays them
+// A development server started with FIXTURE_RECORD_DIR writes every exchange to a JSON fixture;
+// a server started with FIXTURE_REPLAY_DIR answers from those fixtures without any storage, so
+// client teams can keep developing against stable responses when the backend is unavailable
+package fixtures
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Fixture is one recorded request/response exchange
+type Fixture struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded, sanitized request
+type Request struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   string              `json:"query,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    json.RawMessage     `json:"body,omitempty"`
+}
+
+// Response is the recorded, sanitized response
+type Response struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    json.RawMessage     `json:"body,omitempty"`
+}
+
+// redacted replaces sensitive values in recorded fixtures
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are dropped from recorded requests and responses entirely
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"Proxy-Authorization": true,
+}
+
+// sensitiveField matches JSON field names whose values are redacted
+var sensitiveField = regexp.MustCompile(`(?i)(password|secret|token|api_?key|signature)`)
+
+// sanitizeHeaders copies headers, dropping credentials and per-request noise
+func sanitizeHeaders(h http.Header) map[string][]string {
+	out := make(map[string][]string)
+	for name, values := range h {
+		canonical := http.CanonicalHeaderKey(name)
+		if sensitiveHeaders[canonical] || canonical == "Date" || canonical == "Content-Length" {
+			continue
+		}
+		out[canonical] = append([]string(nil), values...)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// sanitizeBody redacts sensitive fields of a JSON body; non-JSON bodies are stored as a JSON string
+func sanitizeBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		encoded, _ := json.Marshal(string(body))
+		return encoded
+	}
+	encoded, _ := json.Marshal(redact(value))
+	return encoded
+}
+
+// redact walks a decoded JSON value replacing sensitive fields
+func redact(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, inner := range v {
+			if sensitiveField.MatchString(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redact(inner)
+			}
+		}
+	case []any:
+		for i, inner := range v {
+			v[i] = redact(inner)
+		}
+	}
+	return value
+}
+
+// matchKey identifies the request a fixture answers: method, path, query and body
+// JSON bodies are compared after sanitizing and re-encoding so key order does not matter
+func matchKey(method, path, query string, body json.RawMessage) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "?" + query + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// routeKey identifies fixtures by method and path only, used when no exact match exists
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+// fileName builds a readable, unique fixture file name
+func fileName(req Request) string {
+	slug := strings.Trim(regexp.MustCompile(`[^a-zA-Z0-9]+`).ReplaceAllString(req.Path, "_"), "_")
+	if slug == "" {
+		slug = "root"
+	}
+	return strings.ToLower(req.Method) + "_" + slug + "_" + matchKey(req.Method, req.Path, req.Query, req.Body)[:12] + ".json"
+}
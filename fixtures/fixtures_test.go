@@ -0,0 +1,115 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Set-Cookie", "session=abc")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(`{"received":` + string(body) + `,"token":"t-123"}`))
+}
+
+func TestRecorder_WritesSanitizedFixture(t *testing.T) {
+	dir := t.TempDir()
+	handler := NewRecorder(dir).Middleware(http.HandlerFunc(echoHandler))
+
+	req := httptest.NewRequest("POST", "/accounts?x=1", strings.NewReader(`{"account_id":1,"password":"hunter2"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	// The client response is unaffected by recording
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), "hunter2") {
+		t.Fatalf("Recorder must pass the exchange through unchanged, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one fixture file, got %d", len(files))
+	}
+	if !strings.HasPrefix(filepath.Base(files[0]), "post_accounts_") {
+		t.Errorf("Unexpected fixture name %s", filepath.Base(files[0]))
+	}
+
+	data, _ := os.ReadFile(files[0])
+	for _, leaked := range []string{"hunter2", "Bearer secret", "session=abc", "t-123"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("Fixture leaked %q: %s", leaked, data)
+		}
+	}
+
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("Fixture is not valid JSON: %v", err)
+	}
+	if f.Request.Query != "x=1" || f.Response.Status != http.StatusCreated {
+		t.Errorf("Unexpected fixture contents: %+v", f)
+	}
+}
+
+func TestReplayer_ServesRecordedFixtures(t *testing.T) {
+	dir := t.TempDir()
+	recorder := NewRecorder(dir).Middleware(http.HandlerFunc(echoHandler))
+	for _, body := range []string{`{"account_id":1}`, `{"account_id":2}`} {
+		recorder.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/accounts", strings.NewReader(body)))
+	}
+	recorder.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	if replayer.Len() != 3 {
+		t.Errorf("Expected 3 fixtures, got %d", replayer.Len())
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"exact match", "POST", "/accounts", `{"account_id":2}`, http.StatusCreated, `"account_id":2`},
+		{"key order ignored", "POST", "/accounts", `{ "account_id" : 1 }`, http.StatusCreated, `"account_id":1`},
+		{"route fallback", "POST", "/accounts", `{"account_id":3}`, http.StatusCreated, `"account_id"`},
+		{"unknown route", "GET", "/accounts/9", "", http.StatusNotFound, "No fixture recorded"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			replayer.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+			if rr.Code != tc.wantStatus {
+				t.Errorf("Expected status %d, got %d", tc.wantStatus, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), tc.wantBody) {
+				t.Errorf("Expected body containing %q, got %s", tc.wantBody, rr.Body.String())
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	replayer.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	if rr.Header().Get("X-Fixture-Replay") != "true" || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected recorded headers plus X-Fixture-Replay, got %v", rr.Header())
+	}
+}
+
+func TestNewReplayer_EmptyDirectory(t *testing.T) {
+	if _, err := NewReplayer(t.TempDir()); err == nil {
+		t.Error("Expected an error when no fixtures exist")
+	}
+}
@@ -0,0 +1,131 @@
+package fixtures
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// maxRecordedBody caps how much of a request or response body is captured
+const maxRecordedBody = 1 << 20
+
+// Recorder is middleware that writes every exchange to a fixture file in Dir
+// Recording failures are logged and never affect the response sent to the client
+type Recorder struct {
+	Dir string
+}
+
+// NewRecorder creates a recorder writing into dir; the directory is created on first write
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{Dir: dir}
+}
+
+// Middleware captures the request and response around next
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Capture the head of the body and hand the full stream on to the handler unchanged
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, maxRecordedBody))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		}
+
+		capture := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(capture, r)
+		if capture.hijacked {
+			return
+		}
+
+		fixture := Fixture{
+			Request: Request{
+				Method:  r.Method,
+				Path:    r.URL.Path,
+				Query:   r.URL.RawQuery,
+				Headers: sanitizeHeaders(r.Header),
+				Body:    sanitizeBody(body),
+			},
+			Response: Response{
+				Status:  capture.status,
+				Headers: sanitizeHeaders(capture.Header()),
+				Body:    sanitizeBody(capture.body.Bytes()),
+			},
+		}
+		if err := rec.write(fixture); err != nil {
+			slog.ErrorContext(r.Context(), "Fixture recording failed", "method", r.Method, "path", r.URL.Path, "error", err)
+		}
+	})
+}
+
+// write stores a fixture; re-recording the same request overwrites the previous fixture
+func (rec *Recorder) write(f Fixture) error {
+	if err := os.MkdirAll(rec.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(rec.Dir, fileName(f.Request)), data, 0o644)
+}
+
+// captureWriter tees the response into a buffer while writing it to the client
+type captureWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	hijacked    bool
+	body        bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	c.wroteHeader = true
+	if remaining := maxRecordedBody - c.body.Len(); remaining > 0 {
+		if len(b) > remaining {
+			c.body.Write(b[:remaining])
+		} else {
+			c.body.Write(b)
+		}
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush passes streaming flushes through to the client
+func (c *captureWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController (e.g. per-write deadlines on
+// the streaming endpoints)
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Hijack passes connection upgrades (e.g. WebSocket) through; hijacked exchanges are not recorded
+func (c *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	c.hijacked = true
+	return hijacker.Hijack()
+}
@@ -0,0 +1,87 @@
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Replayer serves recorded fixtures in place of the real API
+// Requests are matched exactly on method, path, query and (sanitized) body; if no exact match
+// exists, the most recently loaded fixture for the same method and path is served instead
+type Replayer struct {
+	exact  map[string]Fixture
+	routes map[string]Fixture
+}
+
+// NewReplayer loads every *.json fixture in dir
+func NewReplayer(dir string) (*Replayer, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+
+	r := &Replayer{exact: make(map[string]Fixture), routes: make(map[string]Fixture)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", file, err)
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", file, err)
+		}
+		// Fixture files are indented for review; normalize bodies back to their compact form
+		f.Request.Body = sanitizeBody(f.Request.Body)
+		var compact bytes.Buffer
+		if len(f.Response.Body) > 0 && json.Compact(&compact, f.Response.Body) == nil {
+			f.Response.Body = compact.Bytes()
+		}
+		req := f.Request
+		r.exact[matchKey(req.Method, req.Path, req.Query, req.Body)] = f
+		r.routes[routeKey(req.Method, req.Path)] = f
+	}
+	return r, nil
+}
+
+// Len returns the number of distinct fixtures loaded
+func (r *Replayer) Len() int {
+	return len(r.exact)
+}
+
+// ServeHTTP answers a request from the matching fixture, or 404 if none exists
+func (r *Replayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(io.LimitReader(req.Body, maxRecordedBody))
+
+	f, ok := r.exact[matchKey(req.Method, req.URL.Path, req.URL.RawQuery, sanitizeBody(body))]
+	if !ok {
+		f, ok = r.routes[routeKey(req.Method, req.URL.Path)]
+	}
+	if !ok {
+		http.Error(w, "No fixture recorded for "+req.Method+" "+req.URL.Path, http.StatusNotFound)
+		return
+	}
+
+	for name, values := range f.Response.Headers {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	w.Header().Set("X-Fixture-Replay", "true")
+	w.WriteHeader(f.Response.Status)
+
+	// Non-JSON bodies were stored as a JSON string; write them back verbatim
+	var text string
+	if len(f.Response.Body) > 0 && json.Unmarshal(f.Response.Body, &text) == nil {
+		io.WriteString(w, text)
+		return
+	}
+	w.Write(f.Response.Body)
+}
@@ -0,0 +1,149 @@
+package fx
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Cache defaults applied when the corresponding environment variables are unset
+const (
+	defaultCacheTTL   = 30 * time.Second
+	defaultMaxRateAge = 5 * time.Minute
+)
+
+// metrics exposes cache and staleness counters under "fx" at /debug/vars
+//   - cache_hits / cache_misses: lookups served from cache vs fetched from the provider
+//   - provider_errors: failed provider fetches (a cached rate may still have been served)
+//   - stale_rejections: conversions refused because the freshest rate exceeded the max age
+//   - rate_age_seconds.<BASE>/<QUOTE>: age of the last rate served for each pair
+//   - failovers: provider errors that caused the next provider to be tried (FailoverProvider)
+//   - deviation_rejections: rates refused because they disagreed with the secondary provider
+//   - unchecked_rates: rates used without a cross-check because no secondary answered
+var metrics = expvar.NewMap("fx")
+
+// rateAges tracks the age of the last rate served per pair
+var rateAges = new(expvar.Map).Init()
+
+func init() {
+	metrics.Set("rate_age_seconds", rateAges)
+}
+
+// CacheConfig controls rate caching and the staleness guard
+type CacheConfig struct {
+	// TTL is how long a fetched rate is served before the provider is asked again
+	TTL time.Duration
+	// MaxAge is the oldest rate (by its source timestamp) that may be used for a conversion
+	MaxAge time.Duration
+}
+
+// LoadCacheConfig reads the cache configuration from the environment
+// Environment variables used (with defaults):
+//   - FX_RATE_CACHE_TTL (30s): How long fetched rates are cached
+//   - FX_MAX_RATE_AGE (5m): Rates older than this are refused
+func LoadCacheConfig() (CacheConfig, error) {
+	ttl, err := getEnvDuration("FX_RATE_CACHE_TTL", defaultCacheTTL)
+	if err != nil {
+		return CacheConfig{}, err
+	}
+	maxAge, err := getEnvDuration("FX_MAX_RATE_AGE", defaultMaxRateAge)
+	if err != nil {
+		return CacheConfig{}, err
+	}
+	return CacheConfig{TTL: ttl, MaxAge: maxAge}, nil
+}
+
+// CachingProvider caches rates from another provider and enforces a maximum rate age
+// When a refresh fails, the cached rate keeps being served for as long as it is within MaxAge;
+// once every available rate is older than MaxAge, lookups fail with ErrStaleRate
+type CachingProvider struct {
+	next   RateProvider
+	config CacheConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached rate with the time it was fetched
+type cacheEntry struct {
+	rate      Rate
+	fetchedAt time.Time
+}
+
+// NewCachingProvider wraps next with a cache configured by config
+func NewCachingProvider(next RateProvider, config CacheConfig) *CachingProvider {
+	return &CachingProvider{
+		next:    next,
+		config:  config,
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// Rate returns a cached or freshly fetched rate for the pair
+// Returns ErrStaleRate (wrapped with the pair and age) if the rate exceeds the maximum age
+func (p *CachingProvider) Rate(ctx context.Context, base, quote string) (Rate, error) {
+	base, quote = normalizeCurrency(base), normalizeCurrency(quote)
+	key := base + "/" + quote
+
+	p.mu.Lock()
+	entry, cached := p.entries[key]
+	p.mu.Unlock()
+
+	now := p.now()
+	if cached && now.Sub(entry.fetchedAt) < p.config.TTL {
+		metrics.Add("cache_hits", 1)
+		return p.checkAge(key, entry.rate, now)
+	}
+
+	metrics.Add("cache_misses", 1)
+	rate, err := p.next.Rate(ctx, base, quote)
+	if err != nil {
+		metrics.Add("provider_errors", 1)
+		if !cached {
+			return Rate{}, err
+		}
+		// Keep serving the last known rate while it is still fresh enough
+		return p.checkAge(key, entry.rate, now)
+	}
+
+	p.mu.Lock()
+	p.entries[key] = cacheEntry{rate: rate, fetchedAt: now}
+	p.mu.Unlock()
+
+	return p.checkAge(key, rate, now)
+}
+
+// checkAge records the rate's age and refuses it if it is older than MaxAge
+func (p *CachingProvider) checkAge(key string, rate Rate, now time.Time) (Rate, error) {
+	age := rate.Age(now)
+	ageMetric := new(expvar.Float)
+	ageMetric.Set(age.Seconds())
+	rateAges.Set(key, ageMetric)
+
+	if p.config.MaxAge > 0 && age > p.config.MaxAge {
+		metrics.Add("stale_rejections", 1)
+		return Rate{}, fmt.Errorf("%w: %s rate is %s old (max %s)", ErrStaleRate, key, age.Truncate(time.Second), p.config.MaxAge)
+	}
+	return rate, nil
+}
+
+// getEnvDuration retrieves a non-negative duration environment variable with a fallback default
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %q is not a valid duration", key, value)
+	}
+	return d, nil
+}
+
+// Compile-time interface implementation check
+var _ RateProvider = (*CachingProvider)(nil)
@@ -0,0 +1,102 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/shopspring/decimal"
+)
+
+// defaultMaxDeviationPercent is the largest allowed difference between primary and secondary rates
+const defaultMaxDeviationPercent = 2
+
+// ErrRateDeviation is returned when the selected rate disagrees too much with the secondary provider
+var ErrRateDeviation = errors.New("exchange rate deviates from secondary provider")
+
+// FailoverProvider queries providers in priority order and sanity-checks the result
+// The first provider that returns a rate wins; the next provider that answers is used as the
+// secondary and the winning rate is refused if it deviates from it by more than MaxDeviation
+// percent. A single bad feed therefore cannot misprice conversions: disagreement fails closed.
+// If no secondary answers, the winning rate is used unchecked (counted in unchecked_rates)
+type FailoverProvider struct {
+	providers    []RateProvider
+	maxDeviation decimal.Decimal
+}
+
+// NewFailoverProvider creates a provider trying providers in the given order
+// maxDeviationPercent is the allowed difference in percent; zero disables the cross-check
+func NewFailoverProvider(maxDeviationPercent decimal.Decimal, providers ...RateProvider) *FailoverProvider {
+	return &FailoverProvider{providers: providers, maxDeviation: maxDeviationPercent}
+}
+
+// LoadMaxDeviation reads the allowed cross-provider deviation in percent
+// Environment variables used (with defaults):
+//   - FX_MAX_RATE_DEVIATION (2): Maximum percentage difference between primary and secondary rates
+func LoadMaxDeviation() (decimal.Decimal, error) {
+	value := os.Getenv("FX_MAX_RATE_DEVIATION")
+	if value == "" {
+		return decimal.NewFromInt(defaultMaxDeviationPercent), nil
+	}
+	d, err := decimal.NewFromString(value)
+	if err != nil || d.IsNegative() {
+		return decimal.Zero, fmt.Errorf("invalid FX_MAX_RATE_DEVIATION: %q is not a non-negative percentage", value)
+	}
+	return d, nil
+}
+
+// Rate returns the highest priority available rate that passes the cross-provider check
+// Returns:
+//   - Rate: The selected rate
+//   - error: The last provider error if none answered, or ErrRateDeviation (wrapped) on disagreement
+func (p *FailoverProvider) Rate(ctx context.Context, base, quote string) (Rate, error) {
+	lastErr := fmt.Errorf("%w: no rate providers configured", ErrRateUnavailable)
+
+	for i, provider := range p.providers {
+		rate, err := provider.Rate(ctx, base, quote)
+		if err != nil {
+			metrics.Add("failovers", 1)
+			lastErr = err
+			continue
+		}
+		if p.maxDeviation.IsZero() {
+			return rate, nil
+		}
+		return p.crossCheck(ctx, rate, p.providers[i+1:])
+	}
+	return Rate{}, lastErr
+}
+
+// crossCheck compares rate with the first secondary provider that answers
+func (p *FailoverProvider) crossCheck(ctx context.Context, rate Rate, secondaries []RateProvider) (Rate, error) {
+	for _, secondary := range secondaries {
+		reference, err := secondary.Rate(ctx, rate.Base, rate.Quote)
+		if err != nil || !reference.Value.IsPositive() {
+			continue
+		}
+
+		deviation := rate.Value.Sub(reference.Value).Abs().Div(reference.Value).Mul(decimal.NewFromInt(100))
+		if deviation.GreaterThan(p.maxDeviation) {
+			metrics.Add("deviation_rejections", 1)
+			return Rate{}, fmt.Errorf("%w: %s/%s %s from %s vs %s from %s (%s%% > %s%%)", ErrRateDeviation,
+				rate.Base, rate.Quote, rate.Value, sourceName(rate), reference.Value, sourceName(reference),
+				deviation.StringFixed(2), p.maxDeviation)
+		}
+		return rate, nil
+	}
+
+	metrics.Add("unchecked_rates", 1)
+	return rate, nil
+}
+
+// sourceName returns the rate's source for error messages
+func sourceName(r Rate) string {
+	if r.Source == "" {
+		return "unnamed provider"
+	}
+	return r.Source
+}
+
+// Compile-time interface implementation check
+var _ RateProvider = (*FailoverProvider)(nil)
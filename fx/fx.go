@@ -0,0 +1,92 @@
+// Package fx provides foreign exchange rates for currency conversion
+// Rates come from a RateProvider; CachingProvider adds TTL caching and refuses rates older than
+// a configured bound, so a frozen feed can never silently price conversions
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// Errors returned by rate providers and the conversion helpers
+var (
+	// ErrRateUnavailable is returned when a provider has no rate for the requested pair
+	ErrRateUnavailable = errors.New("exchange rate unavailable")
+	// ErrStaleRate is returned when the only available rate is older than the allowed age
+	ErrStaleRate = errors.New("exchange rate is stale")
+)
+
+// Rate is the price of one unit of Base expressed in Quote currency
+// Timestamp is when the rate was observed by its source, not when it was fetched
+type Rate struct {
+	Base      string          `json:"base"`
+	Quote     string          `json:"quote"`
+	Value     decimal.Decimal `json:"value"`
+	Timestamp time.Time       `json:"timestamp"`
+	Source    string          `json:"source,omitempty"`
+}
+
+// Age returns how old the rate is at now
+func (r Rate) Age(now time.Time) time.Duration {
+	return now.Sub(r.Timestamp)
+}
+
+// RateProvider is a source of exchange rates
+// Implementations return ErrRateUnavailable (possibly wrapped) for unknown pairs
+type RateProvider interface {
+	Rate(ctx context.Context, base, quote string) (Rate, error)
+}
+
+// Quote is the result of converting an amount at a specific rate
+// RateTimestamp is carried through so callers can show and record how fresh the rate was
+type Quote struct {
+	Base            string                `json:"base"`
+	Quote           string                `json:"quote"`
+	Amount          decimal.Decimal       `json:"amount"`
+	ConvertedAmount decimal.Decimal       `json:"converted_amount"`
+	Rate            decimal.Decimal       `json:"rate"`
+	RateTimestamp   time.Time             `json:"rate_timestamp"`
+	RoundingPolicy  models.RoundingPolicy `json:"rounding_policy"`
+}
+
+// Convert prices amount of base in quote currency using provider
+// The converted amount is rounded to the stored scale with the given rounding policy
+// Returns:
+//   - Quote: The conversion including the rate and its timestamp
+//   - error: Provider error (ErrRateUnavailable, ErrStaleRate, ...) or invalid currency codes
+func Convert(ctx context.Context, provider RateProvider, base, quote string, amount decimal.Decimal, rounding models.RoundingPolicy) (Quote, error) {
+	base, quote = normalizeCurrency(base), normalizeCurrency(quote)
+	if base == "" || quote == "" {
+		return Quote{}, fmt.Errorf("currency codes are required")
+	}
+
+	rate := Rate{Base: base, Quote: quote, Value: decimal.NewFromInt(1), Timestamp: time.Now()}
+	if base != quote {
+		var err error
+		if rate, err = provider.Rate(ctx, base, quote); err != nil {
+			return Quote{}, err
+		}
+	}
+
+	return Quote{
+		Base:            base,
+		Quote:           quote,
+		Amount:          amount,
+		ConvertedAmount: rounding.RoundAmount(amount.Mul(rate.Value)),
+		Rate:            rate.Value,
+		RateTimestamp:   rate.Timestamp,
+		RoundingPolicy:  rounding,
+	}, nil
+}
+
+// normalizeCurrency upper-cases and trims an ISO 4217 currency code
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,269 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// stubProvider returns a fixed rate or error and counts calls
+type stubProvider struct {
+	rate  Rate
+	err   error
+	calls int
+}
+
+func (s *stubProvider) Rate(ctx context.Context, base, quote string) (Rate, error) {
+	s.calls++
+	if s.err != nil {
+		return Rate{}, s.err
+	}
+	return s.rate, nil
+}
+
+func TestCachingProvider_TTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	stub := &stubProvider{rate: Rate{Base: "EUR", Quote: "USD", Value: decimal.RequireFromString("1.1"), Timestamp: now}}
+	p := NewCachingProvider(stub, CacheConfig{TTL: time.Minute, MaxAge: time.Hour})
+	p.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	p.Rate(ctx, "eur", "usd")
+	p.Rate(ctx, "EUR", "USD")
+	if stub.calls != 1 {
+		t.Errorf("Expected one provider call within TTL, got %d", stub.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	p.Rate(ctx, "EUR", "USD")
+	if stub.calls != 2 {
+		t.Errorf("Expected a refresh after TTL, got %d calls", stub.calls)
+	}
+}
+
+func TestCachingProvider_Staleness(t *testing.T) {
+	observed := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := observed
+	stub := &stubProvider{rate: Rate{Base: "EUR", Quote: "USD", Value: decimal.RequireFromString("1.1"), Timestamp: observed}}
+	p := NewCachingProvider(stub, CacheConfig{TTL: time.Minute, MaxAge: 5 * time.Minute})
+	p.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if _, err := p.Rate(ctx, "EUR", "USD"); err != nil {
+		t.Fatalf("Expected fresh rate, got %v", err)
+	}
+
+	// The feed goes down: the cached rate is served while it is within the max age
+	stub.err = errors.New("feed unavailable")
+	now = observed.Add(3 * time.Minute)
+	if _, err := p.Rate(ctx, "EUR", "USD"); err != nil {
+		t.Errorf("Expected cached rate during outage, got %v", err)
+	}
+
+	// ...and refused once it is too old
+	now = observed.Add(6 * time.Minute)
+	if _, err := p.Rate(ctx, "EUR", "USD"); !errors.Is(err, ErrStaleRate) {
+		t.Errorf("Expected ErrStaleRate, got %v", err)
+	}
+
+	// A provider that keeps returning an old rate is refused as well
+	stub.err = nil
+	if _, err := p.Rate(ctx, "EUR", "USD"); !errors.Is(err, ErrStaleRate) {
+		t.Errorf("Expected ErrStaleRate for a frozen feed, got %v", err)
+	}
+}
+
+func TestCachingProvider_ProviderErrorWithoutCache(t *testing.T) {
+	p := NewCachingProvider(&stubProvider{err: ErrRateUnavailable}, CacheConfig{TTL: time.Minute, MaxAge: time.Hour})
+	if _, err := p.Rate(context.Background(), "EUR", "JPY"); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("Expected ErrRateUnavailable, got %v", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	observed := time.Now().Add(-time.Second)
+	stub := &stubProvider{rate: Rate{Base: "EUR", Quote: "USD", Value: decimal.RequireFromString("1.085"), Timestamp: observed}}
+
+	quote, err := Convert(context.Background(), stub, "eur", "usd", decimal.RequireFromString("10.000005"), models.RoundHalfEven)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	// 10.000005 * 1.085 = 10.850005425, rounded half-even to 5 places
+	if !quote.ConvertedAmount.Equal(decimal.RequireFromString("10.85001")) {
+		t.Errorf("Expected 10.85001, got %s", quote.ConvertedAmount)
+	}
+	if !quote.RateTimestamp.Equal(observed) || quote.RoundingPolicy != models.RoundHalfEven {
+		t.Errorf("Expected rate timestamp and policy on the quote, got %+v", quote)
+	}
+
+	// Same-currency conversion never consults the provider
+	stub.calls = 0
+	if _, err := Convert(context.Background(), stub, "USD", "USD", decimal.NewFromInt(1), models.RoundHalfUp); err != nil || stub.calls != 0 {
+		t.Errorf("Expected identity conversion without provider call, got %v (%d calls)", err, stub.calls)
+	}
+}
+
+func TestLoadCacheConfig(t *testing.T) {
+	defer os.Unsetenv("FX_RATE_CACHE_TTL")
+	defer os.Unsetenv("FX_MAX_RATE_AGE")
+
+	config, err := LoadCacheConfig()
+	if err != nil || config.TTL != defaultCacheTTL || config.MaxAge != defaultMaxRateAge {
+		t.Errorf("Expected defaults, got %+v, %v", config, err)
+	}
+
+	os.Setenv("FX_MAX_RATE_AGE", "soon")
+	if _, err := LoadCacheConfig(); err == nil {
+		t.Error("Expected error for invalid FX_MAX_RATE_AGE")
+	}
+}
+
+func TestFailoverProvider(t *testing.T) {
+	now := time.Now()
+	rate := func(source, value string) *stubProvider {
+		return &stubProvider{rate: Rate{Base: "EUR", Quote: "USD", Value: decimal.RequireFromString(value), Timestamp: now, Source: source}}
+	}
+	down := &stubProvider{err: ErrRateUnavailable}
+	twoPercent := decimal.NewFromInt(2)
+
+	testCases := []struct {
+		name       string
+		deviation  decimal.Decimal
+		providers  []RateProvider
+		wantSource string
+		wantErr    error
+	}{
+		{"primary agrees with secondary", twoPercent, []RateProvider{rate("a", "1.10"), rate("b", "1.09")}, "a", nil},
+		{"fails over to secondary", twoPercent, []RateProvider{down, rate("b", "1.09"), rate("c", "1.10")}, "b", nil},
+		{"skips unavailable secondary", twoPercent, []RateProvider{rate("a", "1.10"), down, rate("c", "1.09")}, "a", nil},
+		{"rejects deviating primary", twoPercent, []RateProvider{rate("a", "1.50"), rate("b", "1.09")}, "", ErrRateDeviation},
+		{"no secondary available", twoPercent, []RateProvider{rate("a", "1.50"), down}, "a", nil},
+		{"cross-check disabled", decimal.Zero, []RateProvider{rate("a", "1.50"), rate("b", "1.09")}, "a", nil},
+		{"all providers down", twoPercent, []RateProvider{down, down}, "", ErrRateUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewFailoverProvider(tc.deviation, tc.providers...).Rate(context.Background(), "EUR", "USD")
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("Expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil || got.Source != tc.wantSource {
+				t.Errorf("Expected rate from %q, got %+v, %v", tc.wantSource, got, err)
+			}
+		})
+	}
+}
+
+func TestLoadMaxDeviation(t *testing.T) {
+	defer os.Unsetenv("FX_MAX_RATE_DEVIATION")
+
+	if d, err := LoadMaxDeviation(); err != nil || !d.Equal(decimal.NewFromInt(defaultMaxDeviationPercent)) {
+		t.Errorf("Expected default deviation, got %s, %v", d, err)
+	}
+	os.Setenv("FX_MAX_RATE_DEVIATION", "0.5")
+	if d, err := LoadMaxDeviation(); err != nil || !d.Equal(decimal.RequireFromString("0.5")) {
+		t.Errorf("Expected 0.5, got %s, %v", d, err)
+	}
+	os.Setenv("FX_MAX_RATE_DEVIATION", "-1")
+	if _, err := LoadMaxDeviation(); err == nil {
+		t.Error("Expected error for negative deviation")
+	}
+}
+
+func TestStaticProvider(t *testing.T) {
+	rates, err := ParseStaticRates(" eur/usd=1.25, USD/JPY=150")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	p := NewStaticProvider(rates)
+	ctx := context.Background()
+
+	if rate, err := p.Rate(ctx, "EUR", "USD"); err != nil || !rate.Value.Equal(decimal.RequireFromString("1.25")) || rate.Source != "static" {
+		t.Errorf("Unexpected EUR/USD rate %+v, %v", rate, err)
+	}
+	if rate, err := p.Rate(ctx, "usd", "eur"); err != nil || !rate.Value.Equal(decimal.RequireFromString("0.8")) {
+		t.Errorf("Expected the inverse rate 0.8, got %+v, %v", rate, err)
+	}
+	if _, err := p.Rate(ctx, "EUR", "JPY"); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("Expected ErrRateUnavailable, got %v", err)
+	}
+
+	for _, value := range []string{"EUR/USD", "EURUSD=1.1", "EUR/USD=-1", "EUR/=1"} {
+		if _, err := ParseStaticRates(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("base") + "/" + r.URL.Query().Get("quote") {
+		case "EUR/USD":
+			w.Write([]byte(`{"rate": "1.0842", "timestamp": "2024-03-11T12:00:00Z"}`))
+		case "EUR/GBP":
+			w.Write([]byte(`{"rate": "0"}`))
+		case "EUR/CHF":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewHTTPProvider(server.URL+"/rates", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	rate, err := p.Rate(ctx, "eur", "usd")
+	if err != nil || !rate.Value.Equal(decimal.RequireFromString("1.0842")) || !rate.Timestamp.Equal(time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected rate %+v, %v", rate, err)
+	}
+	if _, err := p.Rate(ctx, "EUR", "JPY"); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("Expected ErrRateUnavailable for an unquoted pair, got %v", err)
+	}
+	for _, quote := range []string{"GBP", "CHF"} {
+		if _, err := p.Rate(ctx, "EUR", quote); err == nil || errors.Is(err, ErrRateUnavailable) {
+			t.Errorf("EUR/%s: expected a provider error, got %v", quote, err)
+		}
+	}
+
+	if _, err := NewHTTPProvider("ftp://rates", nil); err == nil {
+		t.Error("Expected error for a non-HTTP URL")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("FX_RATE_URLS", "")
+	t.Setenv("FX_RATES", "")
+	if p, err := Load(); p != nil || err != nil {
+		t.Errorf("Expected no provider without configuration, got %v, %v", p, err)
+	}
+
+	t.Setenv("FX_RATES", "EUR/USD=1.1")
+	p, err := Load()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rate, err := p.Rate(context.Background(), "USD", "EUR"); err != nil || rate.Value.IsZero() {
+		t.Errorf("Unexpected rate %+v, %v", rate, err)
+	}
+
+	t.Setenv("FX_RATE_URLS", "not a url")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for an invalid FX_RATE_URLS entry")
+	}
+}
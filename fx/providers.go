@@ -0,0 +1,184 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// inverseRatePlaces is the precision of rates derived by inverting a configured pair
+const inverseRatePlaces = 10
+
+// StaticProvider serves rates from a fixed table, e.g. for treasury-set rates or tests
+// A pair missing from the table is derived from its inverse when that is configured
+// Rates are reported as observed now, so the staleness guard never rejects them
+type StaticProvider struct {
+	rates map[string]decimal.Decimal
+	now   func() time.Time
+}
+
+// NewStaticProvider creates a provider from rates keyed by "BASE/QUOTE"
+func NewStaticProvider(rates map[string]decimal.Decimal) *StaticProvider {
+	table := make(map[string]decimal.Decimal, len(rates))
+	for pair, value := range rates {
+		table[normalizeCurrency(pair)] = value
+	}
+	return &StaticProvider{rates: table, now: time.Now}
+}
+
+// ParseStaticRates parses a rate table written as comma separated BASE/QUOTE=rate pairs,
+// e.g. "EUR/USD=1.0842,USD/JPY=151.2"
+func ParseStaticRates(value string) (map[string]decimal.Decimal, error) {
+	rates := make(map[string]decimal.Decimal)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pair, rate, ok := strings.Cut(entry, "=")
+		base, quote, isPair := strings.Cut(strings.TrimSpace(pair), "/")
+		d, err := decimal.NewFromString(strings.TrimSpace(rate))
+		if !ok || !isPair || base == "" || quote == "" || err != nil || !d.IsPositive() {
+			return nil, fmt.Errorf("invalid FX_RATES entry %q (expected BASE/QUOTE=rate)", entry)
+		}
+		rates[normalizeCurrency(base)+"/"+normalizeCurrency(quote)] = d
+	}
+	return rates, nil
+}
+
+// Rate returns the configured rate for the pair, or the inverse of the reverse pair
+func (p *StaticProvider) Rate(ctx context.Context, base, quote string) (Rate, error) {
+	base, quote = normalizeCurrency(base), normalizeCurrency(quote)
+	rate := Rate{Base: base, Quote: quote, Timestamp: p.now(), Source: "static"}
+	if value, ok := p.rates[base+"/"+quote]; ok {
+		rate.Value = value
+		return rate, nil
+	}
+	if value, ok := p.rates[quote+"/"+base]; ok {
+		rate.Value = decimal.NewFromInt(1).DivRound(value, inverseRatePlaces)
+		return rate, nil
+	}
+	return Rate{}, fmt.Errorf("%w: no static rate for %s/%s", ErrRateUnavailable, base, quote)
+}
+
+// HTTPProvider fetches rates from an HTTP rate service
+// It requests GET <url>?base=EUR&quote=USD and expects 200 OK with a JSON body
+// {"rate": "1.0842", "timestamp": "2024-03-11T12:00:00Z"}; 404 means the pair is not quoted.
+// Services with a different API are integrated by implementing RateProvider instead
+type HTTPProvider struct {
+	url    string
+	name   string
+	client *http.Client
+}
+
+// DefaultHTTPTimeout bounds each rate request of an HTTPProvider
+const DefaultHTTPTimeout = 2 * time.Second
+
+// NewHTTPProvider creates a provider querying rateURL; its rates are attributed to the URL's host
+func NewHTTPProvider(rateURL string, client *http.Client) (*HTTPProvider, error) {
+	parsed, err := url.Parse(rateURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid FX rate URL %q", rateURL)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+	return &HTTPProvider{url: rateURL, name: parsed.Host, client: client}, nil
+}
+
+// httpRate is the response body of an HTTP rate service
+type httpRate struct {
+	Rate      decimal.Decimal `json:"rate"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Rate fetches the pair's current rate
+// Returns ErrRateUnavailable (wrapped) for pairs the service does not quote, or a transport error
+func (p *HTTPProvider) Rate(ctx context.Context, base, quote string) (Rate, error) {
+	base, quote = normalizeCurrency(base), normalizeCurrency(quote)
+	query := url.Values{"base": {base}, "quote": {quote}}
+	separator := "?"
+	if strings.Contains(p.url, "?") {
+		separator = "&"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+separator+query.Encode(), nil)
+	if err != nil {
+		return Rate{}, fmt.Errorf("failed to build %s rate request: %w", p.name, err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Rate{}, fmt.Errorf("failed to fetch %s/%s rate from %s: %w", base, quote, p.name, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return Rate{}, fmt.Errorf("%w: %s does not quote %s/%s", ErrRateUnavailable, p.name, base, quote)
+	case resp.StatusCode != http.StatusOK:
+		return Rate{}, fmt.Errorf("failed to fetch %s/%s rate from %s: status %d", base, quote, p.name, resp.StatusCode)
+	}
+
+	var body httpRate
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Rate{}, fmt.Errorf("invalid %s/%s rate from %s: %w", base, quote, p.name, err)
+	}
+	if !body.Rate.IsPositive() || body.Timestamp.IsZero() {
+		return Rate{}, fmt.Errorf("invalid %s/%s rate from %s: rate and timestamp are required", base, quote, p.name)
+	}
+	return Rate{Base: base, Quote: quote, Value: body.Rate, Timestamp: body.Timestamp, Source: p.name}, nil
+}
+
+// Load builds the rate provider configured in the environment
+// Variables:
+//   - FX_RATE_URLS (unset): Comma separated HTTP rate services, in priority order (see HTTPProvider)
+//   - FX_RATES (unset): Static rate table as BASE/QUOTE=rate pairs, tried after the HTTP services
+//
+// The providers are chained through a FailoverProvider (FX_MAX_RATE_DEVIATION) behind a
+// CachingProvider (FX_RATE_CACHE_TTL, FX_MAX_RATE_AGE)
+// Returns nil if no provider is configured (currency conversion is disabled), or an error for
+// malformed values
+func Load() (RateProvider, error) {
+	var providers []RateProvider
+	for _, rateURL := range strings.Split(os.Getenv("FX_RATE_URLS"), ",") {
+		if rateURL = strings.TrimSpace(rateURL); rateURL == "" {
+			continue
+		}
+		provider, err := NewHTTPProvider(rateURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	if value := os.Getenv("FX_RATES"); value != "" {
+		rates, err := ParseStaticRates(value)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, NewStaticProvider(rates))
+	}
+	if len(providers) == 0 {
+		return nil, nil
+	}
+
+	maxDeviation, err := LoadMaxDeviation()
+	if err != nil {
+		return nil, err
+	}
+	cacheConfig, err := LoadCacheConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewCachingProvider(NewFailoverProvider(maxDeviation, providers...), cacheConfig), nil
+}
+
+// Compile-time interface implementation checks
+var _ RateProvider = (*StaticProvider)(nil)
+var _ RateProvider = (*HTTPProvider)(nil)
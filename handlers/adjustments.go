@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"internal-transfers/audit"
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// WithAdjustments attaches the repository that books manual adjustments, which statements then
+// list alongside the transactions
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithAdjustments(repo database.AdjustmentRepositoryInterface) *Handler {
+	h.adjustments = repo
+	h.accounts.WithAdjustments(repo)
+	h.transfers.WithAdjustments(repo)
+	return h
+}
+
+// CreateAdjustment handles POST /admin/adjustments endpoint (admin only)
+// This endpoint corrects an operational error by crediting or debiting a single account outside of
+// any transfer. The adjustment is a ledger entry of its own, with no counterparty: it changes the
+// balance and appears in the account's statements and balance history, and reconciliation counts
+// it. Frozen accounts can be adjusted; a debit may not exceed the available balance
+// Request body: JSON with account_id, amount (signed decimal string: positive credits, negative
+// debits), reason and actor (the operator booking it), all required
+// Response: 201 Created with the adjustment and the balance it left, 400 for an invalid request or
+// a debit beyond the available balance, 404 if the account does not exist, 503 if adjustments are
+// unavailable
+// Example request: {"account_id": 123, "amount": "-25.00", "reason": "Duplicate card settlement 2024-03-11", "actor": "jdoe"}
+func (h *Handler) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	if h.adjustments == nil {
+		http.Error(w, "Adjustments unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var req models.CreateAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	before := h.auditedAccount(req.AccountID)
+	adjustment, err := h.accounts.Adjust(r.Context(), req)
+	if err != nil {
+		var invalid *service.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeValidationError(w, r, invalid)
+		case errors.Is(err, service.ErrAccountNotFound):
+			http.Error(w, "Account not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrInsufficientBalance):
+			http.Error(w, "Insufficient balance", http.StatusBadRequest)
+		default:
+			slog.ErrorContext(r.Context(), "Adjustment error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	slog.InfoContext(r.Context(), "Account adjusted", "account_id", adjustment.AccountID, "adjustment_id", adjustment.ID,
+		"amount", adjustment.Amount, "actor", adjustment.Actor)
+
+	response := models.NewAdjustmentResponse(*adjustment)
+	if h.audit != nil {
+		h.audit.Record(r.Context(), models.AuditEvent{
+			Actor:      models.AuditActorAdmin,
+			Action:     models.AuditAccountAdjusted,
+			AccountIDs: []int64{adjustment.AccountID},
+			Before:     audit.Snapshot(before),
+			After:      audit.Snapshot(response),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// FreezeAccount handles POST /admin/accounts/{account_id}/freeze endpoint (admin only)
+// This endpoint places a compliance hold on an account: until it is unfrozen, transfers debiting
+// or crediting it are refused with 423 Locked. Balances and history remain readable
+// Response: 200 OK with the updated account, 404 if the account does not exist
+func (h *Handler) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.setAccountStatus(w, r, models.AuditAccountFrozen, h.accounts.Freeze)
+}
+
+// UnfreezeAccount handles POST /admin/accounts/{account_id}/unfreeze endpoint (admin only)
+// This endpoint lifts a compliance hold so the account can send and receive transfers again
+// Response: 200 OK with the updated account, 404 if the account does not exist
+func (h *Handler) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.setAccountStatus(w, r, models.AuditAccountUnfrozen, h.accounts.Unfreeze)
+}
+
+// setAccountStatus applies a status change, audited as action, and writes the updated account
+// Freezing a frozen account (or unfreezing an active one) succeeds without changes
+func (h *Handler) setAccountStatus(w http.ResponseWriter, r *http.Request, action string, change func(accountID int64) (*models.Account, error)) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	before := h.auditedAccount(accountID)
+	account, err := change(accountID)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Account status change error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "Account status set", "account_id", accountID, "status", account.Status)
+	h.recordAccountChange(r.Context(), models.AuditActorAdmin, action, before, account)
+
+	writeAccount(w, r, account)
+}
+
+// SetOverdraftLimit handles PUT /admin/accounts/{account_id}/overdraft endpoint (admin only)
+// This endpoint lets credit-style internal accounts go negative to a controlled extent: transfers
+// debiting the account succeed while its balance stays at or above -overdraft_limit
+// Request body: {"overdraft_limit": "500.00"}; "0" forbids negative balances again
+// Response: 200 OK with the updated account, 400 for an invalid limit, 404 if the account does not
+// exist, 409 if the account is already overdrawn by more than the new limit
+func (h *Handler) SetOverdraftLimit(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetOverdraftLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	before := h.auditedAccount(accountID)
+	account, err := h.accounts.SetOverdraftLimit(accountID, req)
+	if err != nil {
+		var invalid *service.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeValidationError(w, r, invalid)
+		case errors.Is(err, service.ErrAccountNotFound):
+			http.Error(w, "Account not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrOverdrawn):
+			http.Error(w, "Account is overdrawn beyond the requested limit", http.StatusConflict)
+		default:
+			slog.ErrorContext(r.Context(), "Overdraft limit change error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	slog.InfoContext(r.Context(), "Account overdraft limit set", "account_id", accountID, "overdraft_limit", account.OverdraftLimit)
+	h.recordAccountChange(r.Context(), models.AuditActorAdmin, models.AuditOverdraftLimitSet, before, account)
+
+	writeAccount(w, r, account)
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/audit"
+	"internal-transfers/database"
+	"internal-transfers/middleware"
+	"internal-transfers/models"
+	"internal-transfers/service"
+	"internal-transfers/signing"
+	"internal-transfers/usage"
+)
+
+// WithSigning attaches the API keys verifying signed requests (see the signing package) and
+// served by the API key endpoints
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithSigning(repo database.APIKeyRepositoryInterface, config signing.Config) *Handler {
+	h.signing = signing.NewManager(repo, config)
+	return h
+}
+
+// WithAllowlist restricts the requests signed with an API key to the key's networks in the
+// allowlist's Keys; the allowlist's Networks are enforced by middleware.IPAllowlist
+// Only signed requests are restricted to a key's networks: an unsigned request naming a key, as in
+// X-API-Key, proves nothing about its sender (see CheckAllowlist)
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithAllowlist(allowlist middleware.Allowlist) *Handler {
+	h.allowlist = allowlist
+	return h
+}
+
+// CheckAllowlist checks that every key in the allowlist's Keys can sign requests, as their networks
+// only restrict signed requests: ranges for a key that cannot sign would restrict nothing
+// Returns an error naming the keys that are unknown or revoked, or all of them when signing is
+// not attached, or a storage error
+func (h *Handler) CheckAllowlist(ctx context.Context) error {
+	if len(h.allowlist.Keys) == 0 {
+		return nil
+	}
+	signers := make(map[string]bool)
+	if h.signing != nil {
+		keys, err := h.signing.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			signers[key.ID] = key.RevokedAt == nil
+		}
+	}
+	var unusable []string
+	for keyID := range h.allowlist.Keys {
+		if !signers[keyID] {
+			unusable = append(unusable, keyID)
+		}
+	}
+	if len(unusable) == 0 {
+		return nil
+	}
+	sort.Strings(unusable)
+	return fmt.Errorf("IP_ALLOWLIST_KEYS names keys that cannot sign requests, unknown or revoked: %s",
+		strings.Join(unusable, ", "))
+}
+
+// verifiedKey carries the API key a request was verified to be signed with
+type verifiedKey struct{}
+
+// Signed verifies the HMAC signature of the requests to next: 401 for a signature that does not
+// verify, or for an unsigned request when signatures are required, and 403 for a request signed
+// with a key from outside its networks (see WithAllowlist); the body is read once here and
+// replayed to next
+// Verified requests are attributed to their key (see Identified); requests Identified already
+// verified pass. Without signing attached requests pass unverified
+func (h *Handler) Signed(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r, ok := h.verifySignature(w, r, true); ok {
+			next(w, r)
+		}
+	}
+}
+
+// Identified verifies the signature of the signed requests to next like Signed, attributing them
+// to their key for usage metering, transfer SLAs and the audit log (see usage.Attribute), so a
+// key's requests are attributed to it whichever endpoint they call; unsigned requests pass as
+// anonymous, even when signatures are required
+func (h *Handler) Identified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r, ok := h.verifySignature(w, r, false); ok {
+			next(w, r)
+		}
+	}
+}
+
+// verifySignature verifies the signature of r, if required or signed, and attributes r to its
+// key, answering 401, 403 or 500 if it fails
+// Returns r with the key in its context, and ok false after answering
+func (h *Handler) verifySignature(w http.ResponseWriter, r *http.Request, required bool) (*http.Request, bool) {
+	if h.signing == nil {
+		return r, true
+	}
+	if _, verified := r.Context().Value(verifiedKey{}).(*models.APIKey); verified || (!required && !signing.IsSigned(r)) {
+		return r, true
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyError(w, err)
+		return r, false
+	}
+	key, err := h.signing.Verify(r.Context(), r, body)
+	switch {
+	case errors.Is(err, signing.ErrUnsigned), errors.Is(err, signing.ErrInvalidSignature):
+		slog.WarnContext(r.Context(), "Request signature refused", "key_id", r.Header.Get(signing.KeyIDHeader), "error", err)
+		w.Header().Set("WWW-Authenticate", `HMAC-SHA256 realm="api"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return r, false
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Request signature verification error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return r, false
+	case key != nil:
+		if !h.allowlist.AllowsRequest(r, key.ID) {
+			slog.WarnContext(r.Context(), "Signed request from outside the key's networks", "key_id", key.ID, "remote_addr", r.RemoteAddr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return r, false
+		}
+		slog.DebugContext(r.Context(), "Request signature verified", "key_id", key.ID)
+		r = usage.Attribute(w, r.WithContext(context.WithValue(r.Context(), verifiedKey{}, key)), key.ID)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return r, true
+}
+
+// CreateAPIKey handles POST /admin/api-keys endpoint (admin only)
+// This endpoint issues a key for signing requests; its secret is only returned here
+// Request body: {"description": "payroll batch job"}
+// Response: 201 Created with the key and its secret, 400 for a description over 200 characters,
+// 503 if signing is unavailable
+// Example response: {"id": "ak_3f9c0e12a4b7d658", "description": "payroll batch job",
+// "created_at": "2026-10-15T09:30:00Z", "revoked_at": null, "secret": "q7Xw..."}
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h.signing == nil {
+		http.Error(w, "Request signing unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	key, err := h.signing.Create(r.Context(), req)
+	if err != nil {
+		var invalid *service.ValidationError
+		if errors.As(err, &invalid) {
+			writeValidationError(w, r, invalid)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Create API key error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "API key created", "key_id", key.ID)
+
+	response := models.NewAPIKeyResponse(*key)
+	if h.audit != nil {
+		h.audit.Record(r.Context(), models.AuditEvent{
+			Actor:  models.AuditActorAdmin,
+			Action: models.AuditAPIKeyCreated,
+			After:  audit.Snapshot(response),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CreateAPIKeyResponse{APIKeyResponse: response, Secret: key.Secret})
+}
+
+// ListAPIKeys handles GET /admin/api-keys endpoint (admin only)
+// Response: 200 OK with every key, revoked ones included, oldest first and without their secrets;
+// 503 if signing is unavailable
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if h.signing == nil {
+		http.Error(w, "Request signing unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	keys, err := h.signing.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "List API keys error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.APIKeyListResponse{APIKeys: make([]models.APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.APIKeys = append(response.APIKeys, models.NewAPIKeyResponse(key))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeAPIKey handles POST /admin/api-keys/{key_id}/revoke endpoint (admin only)
+// Requests signed with the key are refused from now on; the key stays listed
+// Response: 200 OK with the key (also if it was already revoked), 404 if it does not exist, 503
+// if signing is unavailable
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if h.signing == nil {
+		http.Error(w, "Request signing unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	key, err := h.signing.Revoke(r.Context(), mux.Vars(r)["key_id"])
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Revoke API key error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "API key revoked", "key_id", key.ID)
+
+	response := models.NewAPIKeyResponse(*key)
+	if h.audit != nil {
+		h.audit.Record(r.Context(), models.AuditEvent{
+			Actor:  models.AuditActorAdmin,
+			Action: models.AuditAPIKeyRevoked,
+			After:  audit.Snapshot(response),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/attachments"
+	"internal-transfers/models"
+)
+
+// WithAttachments attaches the manager of transaction attachments
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithAttachments(manager *attachments.Manager) *Handler {
+	h.attachments = manager
+	return h
+}
+
+// UploadAttachment handles POST /admin/transactions/{transaction_id}/attachments endpoint (admin only)
+// Request body: JSON with filename, content_type and content, the document base64 encoded
+//   - The content type must be on the ATTACHMENT_TYPES allow-list and match the content itself
+//   - The document must not exceed ATTACHMENT_MAX_SIZE bytes
+//
+// Response: 201 Created with the attachment's record, 400 for an invalid request, 404 if the
+// transaction does not exist, 413 for a document that is too large, 415 for a type that is not
+// allowed or does not match the content, 503 if attachments are not configured
+// Example request: {"filename": "invoice-1001.pdf", "content_type": "application/pdf", "content": "JVBERi0xLjQK..."}
+func (h *Handler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	transactionID, ok := h.attachmentTransactionID(w, r)
+	if !ok {
+		return
+	}
+	var req models.CreateAttachmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	content, err := base64.StdEncoding.DecodeString(req.Content)
+	if err != nil {
+		http.Error(w, "Invalid attachment content (expected base64)", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := h.attachments.Upload(r.Context(), transactionID, req.Filename, req.ContentType, content)
+	if err != nil {
+		writeAttachmentError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.NewAttachmentResponse(*attachment))
+}
+
+// ListAttachments handles GET /admin/transactions/{transaction_id}/attachments endpoint (admin only)
+// Response: 200 OK with the transaction's attachments oldest first, 404 if the transaction does
+// not exist
+// Example response: {"transaction_id": 42, "attachments": [{"id": 1, "filename": "invoice-1001.pdf", ...}]}
+func (h *Handler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	transactionID, ok := h.attachmentTransactionID(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.attachments.List(r.Context(), transactionID)
+	if err != nil {
+		writeAttachmentError(w, r, err)
+		return
+	}
+
+	response := models.AttachmentListResponse{TransactionID: transactionID, Attachments: make([]models.AttachmentResponse, 0, len(list))}
+	for _, attachment := range list {
+		response.Attachments = append(response.Attachments, models.NewAttachmentResponse(attachment))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DownloadAttachment handles GET /admin/transactions/{transaction_id}/attachments/{attachment_id}
+// endpoint (admin only)
+// Response: 200 OK with the document as a download in its recorded content type, 404 if the
+// transaction has no such attachment, 500 if the stored content is missing or fails its digest check
+func (h *Handler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	transactionID, ok := h.attachmentTransactionID(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["attachment_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	attachment, content, err := h.attachments.Open(r.Context(), transactionID, id)
+	if err != nil {
+		writeAttachmentError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+attachment.Filename+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(content)
+}
+
+// attachmentTransactionID parses the transaction ID from the path, writing the error response if
+// it cannot
+// Also answers 503 when attachments are not configured
+func (h *Handler) attachmentTransactionID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if h.attachments == nil {
+		http.Error(w, "Attachments unavailable", http.StatusServiceUnavailable)
+		return 0, false
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["transaction_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeAttachmentError maps an attachment manager error to a response
+func writeAttachmentError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, attachments.ErrTransactionMissing):
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+	case errors.Is(err, attachments.ErrNotFound):
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+	case errors.Is(err, attachments.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, attachments.ErrUnsupportedType), errors.Is(err, attachments.ErrTypeMismatch):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, attachments.ErrEmpty), errors.Is(err, attachments.ErrInvalidFilename):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.ErrorContext(r.Context(), "Attachment error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"internal-transfers/audit"
+	"internal-transfers/models"
+	"internal-transfers/pagination"
+)
+
+// Audit log page sizes
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// WithAudit attaches the recorder appending account changes, transfers and admin actions to the
+// audit log, and serving GET /admin/audit
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithAudit(recorder *audit.Recorder) *Handler {
+	h.audit = recorder
+	return h
+}
+
+// auditedAccount reads an account's state before a change, for its audit event
+// Returns nil when the audit log is off or the account cannot be read; the change then reports
+// the failure itself
+func (h *Handler) auditedAccount(accountID int64) *models.AccountResponse {
+	if h.audit == nil {
+		return nil
+	}
+	account, err := h.accountRepo.GetAccount(accountID)
+	if err != nil {
+		return nil
+	}
+	response := models.NewAccountResponse(*account)
+	return &response
+}
+
+// recordAccountChange appends an account change to the audit log
+// before is the state read by auditedAccount, nil for a created account
+func (h *Handler) recordAccountChange(ctx context.Context, actor, action string, before *models.AccountResponse, after *models.Account) {
+	if h.audit == nil {
+		return
+	}
+	h.audit.Record(ctx, models.AuditEvent{
+		Actor:      actor,
+		Action:     action,
+		AccountIDs: []int64{after.AccountID},
+		Before:     audit.Snapshot(before),
+		After:      audit.Snapshot(models.NewAccountResponse(*after)),
+	})
+}
+
+// ListAuditEvents handles GET /admin/audit endpoint (admin only) for compliance queries
+// Query parameters (optional):
+//   - actor: Only events by this actor, e.g. "admin", "settlement", "api_key:key_1a2b3c4d5e6f7a8b"
+//     or "recurring:7"
+//   - action: Only events of this action, e.g. "transaction.created" or "account.frozen"
+//   - account_id: Only events touching this account
+//   - from, to: Period as RFC 3339 timestamps or dates (midnight UTC); from is inclusive, to exclusive
+//   - limit (1-1000, default 100): Maximum number of events returned
+//   - cursor: next_cursor of the previous page
+//
+// Response: 200 OK with the matching events newest first, each with the API representation of the
+// account or transaction before and after the change, and next_cursor while the page is full; 400
+// for invalid parameters, 503 if the audit log is unavailable
+// Example response: {"events": [{"id": 812, "occurred_at": "2026-10-15T09:30:00Z", "actor": "admin",
+// "action": "account.frozen", "account_ids": [123], "before": {...}, "after": {...}}]}
+func (h *Handler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if h.audit == nil {
+		http.Error(w, "Audit log unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var err error
+	limit := defaultAuditLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxAuditLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxAuditLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	var before idCursor
+	filters, ok := h.listingFilters(w, r, "list_audit_events", pagination.Filters(r.URL.Query(), "actor", "action", "account_id", "from", "to"), &before)
+	if !ok {
+		return
+	}
+
+	filter := models.AuditFilter{Actor: filters.Get("actor"), Action: filters.Get("action"), BeforeID: before.ID}
+	if value := filters.Get("account_id"); value != "" {
+		if filter.AccountID, err = strconv.ParseInt(value, 10, 64); err != nil || filter.AccountID <= 0 {
+			http.Error(w, "Invalid account_id", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if value := filters.Get(bound.name); value != "" {
+			if *bound.target, err = parseListingTime(value); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s (expected RFC 3339 or YYYY-MM-DD)", bound.name), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	events, err := h.audit.List(r.Context(), filter, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Audit log error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	response := models.AuditListResponse{Events: events}
+	if len(events) == limit {
+		response.NextCursor = h.cursors.Encode("list_audit_events", filters, idCursor{ID: events[len(events)-1].ID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
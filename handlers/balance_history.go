@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/pagination"
+	"internal-transfers/service"
+)
+
+// Balance history page sizes
+const (
+	defaultBalanceHistoryLimit = 100
+	maxBalanceHistoryLimit     = 1000
+)
+
+// WithBalanceHistory attaches the balance snapshots served by GET /accounts/{account_id}/balance-history
+// Snapshots are written by the repositories whether or not they are attached here
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithBalanceHistory(repo database.BalanceHistoryRepositoryInterface) *Handler {
+	h.balanceHistory = repo
+	return h
+}
+
+// GetBalanceHistory handles GET /accounts/{account_id}/balance-history endpoint, the time series
+// of an account's balance for charts and investigations
+// Every transfer records the balance it left on both accounts, and accounts without transfers are
+// snapshotted periodically (BALANCE_SNAPSHOT_INTERVAL)
+// Query parameters (optional):
+//   - from, to: Period as RFC 3339 timestamps or dates (midnight UTC); from is inclusive, to exclusive
+//   - limit (1-1000, default 100): Maximum number of snapshots returned
+//   - cursor: next_cursor of the previous page
+//
+// Response: 200 OK with the snapshots oldest first, each with the balance, the ledger sequence it
+// includes, its source (opened, transfer, periodic or adjustment) and, for transfers, the
+// transaction, and
+// next_cursor while the page is full; 400 for invalid parameters, 404 if the account does not
+// exist, 503 if the balance history is unavailable
+// Example response: {"account_id": 123, "snapshots": [{"id": 7, "taken_at": "2024-03-11T09:30:00Z",
+// "balance": "250.5", "sequence": 4, "source": "transfer", "transaction_id": 42}]}
+func (h *Handler) GetBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	if h.balanceHistory == nil {
+		http.Error(w, "Balance history unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	limit := defaultBalanceHistoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxBalanceHistoryLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxBalanceHistoryLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	requested := pagination.Filters(r.URL.Query(), "from", "to")
+	requested.Set("account_id", strconv.FormatInt(accountID, 10))
+	var after idCursor
+	filters, ok := h.listingFilters(w, r, "get_balance_history", requested, &after)
+	if !ok {
+		return
+	}
+
+	filter := models.BalanceHistoryFilter{AccountID: accountID, AfterID: after.ID}
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if value := filters.Get(bound.name); value != "" {
+			if *bound.target, err = parseListingTime(value); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s (expected RFC 3339 or YYYY-MM-DD)", bound.name), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	if _, err := h.accounts.GetAccount(accountID); err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Balance history error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	snapshots, err := h.balanceHistory.ListBalanceSnapshots(r.Context(), filter, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Balance history error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	response := models.BalanceHistoryResponse{AccountID: accountID, Snapshots: make([]models.BalanceSnapshotResponse, 0, len(snapshots))}
+	for _, snapshot := range snapshots {
+		response.Snapshots = append(response.Snapshots, models.NewBalanceSnapshotResponse(snapshot))
+	}
+	if len(snapshots) == limit {
+		response.NextCursor = h.cursors.Encode("get_balance_history", filters, idCursor{ID: snapshots[len(snapshots)-1].ID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// Change feed page sizes
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+// GetAccountChanges handles GET /accounts/{account_id}/changes endpoint for incremental sync
+// Mirroring systems remember the last sequence number they applied and fetch only what followed it,
+// instead of rereading statements; sequence numbers are gap-free, so a mirror can verify that it
+// applied every movement
+// Query parameters:
+//   - since_seq (default 0): Only movements with a greater account sequence number are returned
+//   - cursor: next_cursor of the previous page, instead of since_seq
+//   - limit (1-1000, default 100): Maximum number of movements returned
+//
+// Response: 200 OK with the movements in sequence order, 400 for invalid parameters or a cursor
+// issued for another account, 404 if the account does not exist. While has_more is true, request
+// again with since_seq set to last_seq, or with cursor set to next_cursor
+// Example response: {"account_id": 123, "since_seq": 7, "last_seq": 8, "has_more": false,
+// "changes": [{"seq": 8, "transaction_id": 42, "amount": "-100.5", "counterparty_id": 456, ...}]}
+func (h *Handler) GetAccountChanges(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	sinceSeq, limit := int64(0), defaultChangesLimit
+	query := r.URL.Query()
+	if query.Get("cursor") != "" && query.Get("since_seq") != "" {
+		http.Error(w, "since_seq cannot be combined with cursor", http.StatusBadRequest)
+		return
+	}
+	var after changesCursor
+	filters, ok := h.listingFilters(w, r, "account_changes", url.Values{"account_id": {strconv.FormatInt(accountID, 10)}}, &after)
+	if !ok {
+		return
+	}
+	sinceSeq = after.Seq
+	if value := query.Get("since_seq"); value != "" {
+		if sinceSeq, err = strconv.ParseInt(value, 10, 64); err != nil || sinceSeq < 0 {
+			http.Error(w, "Invalid since_seq (expected a non-negative integer)", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxChangesLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxChangesLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := h.accounts.GetAccount(accountID); err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Account changes error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	changes, hasMore, err := h.transfers.Changes(accountID, sinceSeq, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Account changes error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.AccountChangesResponse{AccountID: accountID, SinceSeq: sinceSeq, LastSeq: sinceSeq, HasMore: hasMore, Changes: changes}
+	if len(changes) > 0 {
+		response.LastSeq = changes[len(changes)-1].Seq
+	}
+	if hasMore {
+		response.NextCursor = h.cursors.Encode("account_changes", filters, changesCursor{Seq: response.LastSeq})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Codec reads request bodies and writes response bodies in one format
+// The account and transaction endpoints read a request body with the codec of its Content-Type
+// and write the response with the codec negotiated from the Accept header; JSON and XML are built
+// in, and RegisterCodec adds others
+type Codec interface {
+	// MediaTypes lists the media types of the format, e.g. "application/xml"; responses declare
+	// the first
+	MediaTypes() []string
+
+	// Encode writes v, a response type such as models.AccountResponse
+	Encode(w io.Writer, v any) error
+
+	// Decode reads a request body into v, a pointer to a request type
+	Decode(r io.Reader, v any) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	// codecs in registration order; JSON comes first, so it answers wildcard Accept headers
+	codecs = []Codec{jsonCodec{}, xmlCodec{}}
+)
+
+// RegisterCodec adds a codec; for the media types it lists it replaces the codec registered
+// before, including the built-in ones
+// Call it during initialization, before the handler serves requests
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs = append(codecs, codec)
+}
+
+// codecFor returns the codec of a media type, the last registered one listing it
+func codecFor(mediaType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for i := len(codecs) - 1; i >= 0; i-- {
+		for _, t := range codecs[i].MediaTypes() {
+			if strings.EqualFold(t, mediaType) {
+				return codecs[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// acceptedCodec returns the codec for a media range of an Accept header: a media type, or a
+// wildcard such as "*/*" or "application/*" that the first codec with a matching type answers
+func acceptedCodec(mediaRange string) (Codec, bool) {
+	prefix, wildcard := strings.CutSuffix(mediaRange, "*")
+	if !wildcard {
+		return codecFor(mediaRange)
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, codec := range codecs {
+		for _, t := range codec.MediaTypes() {
+			if prefix == "" || prefix == "*/" || strings.HasPrefix(strings.ToLower(t), strings.ToLower(prefix)) {
+				return codec, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// requestCodec returns the codec reading r's body, the one of its Content-Type
+// Bodies of other or no types are read as JSON, as they were before other formats were supported
+func requestCodec(r *http.Request) Codec {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil {
+		if codec, ok := codecFor(mediaType); ok {
+			return codec
+		}
+	}
+	return jsonCodec{}
+}
+
+// responseCodec returns the codec of the response to r: the one of the media range its Accept
+// header prefers (by quality, then order); JSON if it accepts none of the codecs' types
+func responseCodec(r *http.Request) Codec {
+	var best Codec = jsonCodec{}
+	bestQuality := 0.0
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if value, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if quality <= bestQuality {
+			continue
+		}
+		if codec, ok := acceptedCodec(mediaType); ok {
+			best, bestQuality = codec, quality
+		}
+	}
+	return best
+}
+
+// decodeBody reads r's body into v, a pointer to a request type, in the format of its Content-Type
+func decodeBody(r *http.Request, v any) error {
+	return requestCodec(r).Decode(r.Body, v)
+}
+
+// writeBody writes v as the response with status, in the format negotiated from r's Accept header
+func writeBody(w http.ResponseWriter, r *http.Request, status int, v any) {
+	codec := responseCodec(r)
+	var body bytes.Buffer
+	if err := codec.Encode(&body, v); err != nil {
+		slog.ErrorContext(r.Context(), "Response encoding error", "media_type", codec.MediaTypes()[0], "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", codec.MediaTypes()[0])
+	varyAccept(w)
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// varyAccept marks a response as depending on the request's Accept header, so caches keep the
+// negotiated formats apart; responses without a body that stand for one, such as 304, need it too
+func varyAccept(w http.ResponseWriter) {
+	for _, vary := range w.Header().Values("Vary") {
+		if vary == "Accept" {
+			return
+		}
+	}
+	w.Header().Add("Vary", "Accept")
+}
+
+// jsonCodec is the default format
+type jsonCodec struct{}
+
+func (jsonCodec) MediaTypes() []string { return []string{"application/json"} }
+
+func (jsonCodec) Encode(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+
+func (jsonCodec) Decode(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) }
+
+// xmlCodec reads and writes XML for integrators that cannot produce JSON
+// Documents mirror the JSON ones: an element per field, named like the JSON field, holding the
+// field's text; objects nest, arrays hold one <item> per element and null is nil="true"
+// The root element is named after the type, e.g. <account> for models.AccountResponse, and any
+// name is accepted when reading; map keys that are not valid element names, such as metadata
+// keys with spaces, become <entry key="...">
+// Request bodies are read by the fields of the target type, so numbers and booleans need no
+// markup, e.g. <transfer><source_account_id>123</source_account_id><amount>50.00</amount></transfer>;
+// metadata values are read as strings
+type xmlCodec struct{}
+
+func (xmlCodec) MediaTypes() []string { return []string{"application/xml", "text/xml"} }
+
+// Encode writes v's JSON encoding as XML, so both formats name and format fields alike
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := writeXML(enc, dec, xml.StartElement{Name: xml.Name{Local: xmlRootName(v)}}); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// Decode reads the XML document into the JSON value v's type expects, and decodes that into v
+func (xmlCodec) Decode(r io.Reader, v any) error {
+	dec := xml.NewDecoder(r)
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root, err := readXML(dec, start)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(root.value(reflect.TypeOf(v).Elem()))
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(data, v)
+		}
+	}
+}
+
+// writeXML writes the next JSON value of dec as the element start
+func writeXML(enc *xml.Encoder, dec *json.Decoder, start xml.StartElement) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch token := token.(type) {
+	case json.Delim:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for dec.More() {
+			child := xml.StartElement{Name: xml.Name{Local: "item"}}
+			if token == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = xmlElement(key.(string))
+			}
+			if err := writeXML(enc, dec, child); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	case nil:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+		return enc.EncodeElement("", start)
+	default:
+		return enc.EncodeElement(fmt.Sprint(token), start)
+	}
+}
+
+// xmlElement returns the element of an object field: named key, or an entry with a key attribute
+// if key is not a valid element name
+func xmlElement(key string) xml.StartElement {
+	valid := key != "" && !strings.HasPrefix(strings.ToLower(key), "xml")
+	for i, r := range key {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.')) {
+			valid = false
+		}
+	}
+	if valid {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}}}
+}
+
+// xmlRootName names the root element after v's type in snake case, without a Response suffix,
+// e.g. "account_list" for models.AccountListResponse
+func xmlRootName(v any) string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Name() == "" {
+		return "response"
+	}
+	name := []rune(strings.TrimSuffix(t.Name(), "Response"))
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(name[i-1]) || i+1 < len(name) && unicode.IsLower(name[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// xmlNode is an element of a request document
+type xmlNode struct {
+	// name is the element's name, or the key attribute of an <entry>
+	name     string
+	text     string
+	nil      bool
+	children []*xmlNode
+}
+
+// readXML reads the element start up to its end
+func readXML(dec *xml.Decoder, start xml.StartElement) (*xmlNode, error) {
+	node := &xmlNode{name: start.Name.Local}
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Local == "key" && start.Name.Local == "entry":
+			node.name = attr.Value
+		case attr.Name.Local == "nil":
+			node.nil = attr.Value == "true"
+		}
+	}
+	var text strings.Builder
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			child, err := readXML(dec, token)
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			node.text = text.String()
+			return node, nil
+		}
+	}
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// value converts the node into the JSON value that decodes into type t: objects for structs (by
+// their JSON field names) and maps, arrays for slices, numbers and booleans for their kinds, and
+// strings otherwise, including for types decoding themselves from strings such as time.Time
+// Elements t has no field for are dropped, as JSON decoding ignores unknown fields
+func (n *xmlNode) value(t reflect.Type) any {
+	if n.nil {
+		return nil
+	}
+	if t.Kind() != reflect.Pointer {
+		if p := reflect.PointerTo(t); p.Implements(jsonUnmarshalerType) || p.Implements(textUnmarshalerType) {
+			return n.text
+		}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return n.value(t.Elem())
+	case reflect.Struct:
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fields[name] = field.Type
+		}
+		object := make(map[string]any)
+		for _, child := range n.children {
+			if fieldType, ok := fields[child.name]; ok {
+				object[child.name] = child.value(fieldType)
+			}
+		}
+		return object
+	case reflect.Map:
+		object := make(map[string]any)
+		for _, child := range n.children {
+			object[child.name] = child.value(t.Elem())
+		}
+		return object
+	case reflect.Slice, reflect.Array:
+		items := make([]any, 0, len(n.children))
+		for _, child := range n.children {
+			items = append(items, child.value(t.Elem()))
+		}
+		return items
+	case reflect.Interface:
+		if len(n.children) > 0 {
+			object := make(map[string]any)
+			for _, child := range n.children {
+				object[child.name] = child.value(t)
+			}
+			return object
+		}
+		return n.text
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(strings.TrimSpace(n.text)); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return json.Number(strings.TrimSpace(n.text))
+	}
+	// Left to JSON decoding to reject, e.g. a boolean that is not one
+	return n.text
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/audit"
+	"internal-transfers/currencies"
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// WithCurrencies attaches the repository of the currency configuration served by the currency
+// endpoints, and has accounts, transfers and limits validated against it instead of the built-in
+// currencies
+// The stored configuration only applies once loaded, e.g. h.Currencies().Load(ctx)
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithCurrencies(repo database.CurrencyRepositoryInterface) *Handler {
+	h.currencies = currencies.NewManager(repo)
+	h.accounts.WithCurrencies(h.currencies.Currencies())
+	h.transfers.WithCurrencies(h.currencies.Currencies())
+	if h.limits != nil {
+		h.limits.WithCurrencies(h.currencies.Currencies())
+	}
+	return h
+}
+
+// Currencies returns the currency manager, or nil if none is attached
+func (h *Handler) Currencies() *currencies.Manager {
+	return h.currencies
+}
+
+// ListCurrencies handles GET /currencies endpoint
+// Response: 200 OK with every supported currency by code, 503 if the configuration is unavailable
+// Example response: {"currencies": [{"code": "EUR", "scale": 2, "min_amount": "0.01", "max_amount": null,
+// "allow_negative": true, "updated_at": "2026-10-15T09:30:00Z"}]}
+func (h *Handler) ListCurrencies(w http.ResponseWriter, r *http.Request) {
+	if h.currencies == nil {
+		http.Error(w, "Currency configuration unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	list, err := h.currencies.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "List currencies error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.CurrencyListResponse{Currencies: make([]models.CurrencyResponse, 0, len(list))}
+	for _, c := range list {
+		response.Currencies = append(response.Currencies, models.NewCurrencyResponse(c))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetCurrency handles PUT /admin/currencies/{code} endpoint (admin only)
+// This endpoint configures a currency, adding it if it is not supported yet; validation of
+// accounts, transfers and limits in the currency follows the new configuration
+// Request body: {"scale": 2, "min_amount": "1.00", "max_amount": "50000", "allow_negative": false};
+// an omitted bound is removed, an omitted scale or allow_negative is kept
+// Response: 200 OK with the currency (as listed by GET /currencies), 400 for an invalid
+// configuration, 409 if negative balances are disallowed while accounts in the currency have an
+// overdraft limit, 503 if the configuration is unavailable
+func (h *Handler) SetCurrency(w http.ResponseWriter, r *http.Request) {
+	if h.currencies == nil {
+		http.Error(w, "Currency configuration unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	code := models.NormalizeCurrency(mux.Vars(r)["code"])
+	var req models.SetCurrencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	var before *models.CurrencyResponse
+	if current, ok := h.currencies.Currencies().Lookup(code); ok {
+		response := models.NewCurrencyResponse(current)
+		before = &response
+	}
+	currency, err := h.currencies.Set(r.Context(), code, req)
+	if err != nil {
+		var invalid *service.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeValidationError(w, r, invalid)
+		case errors.Is(err, service.ErrCurrencyOverdrafts):
+			http.Error(w, "Accounts in the currency have overdraft limits", http.StatusConflict)
+		default:
+			slog.ErrorContext(r.Context(), "Set currency error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	slog.InfoContext(r.Context(), "Currency configured", "currency", currency.Code)
+
+	response := models.NewCurrencyResponse(*currency)
+	if h.audit != nil {
+		h.audit.Record(r.Context(), models.AuditEvent{
+			Actor:  models.AuditActorAdmin,
+			Action: models.AuditCurrencyConfigured,
+			Before: audit.Snapshot(before),
+			After:  audit.Snapshot(response),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
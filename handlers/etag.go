@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// accountETag returns the entity tag of an account's representation in mediaType: a strong
+// validator derived from its ID, balance, last update and the format, so it changes with every
+// change to the representation (see models.Account.UpdatedAt), including transfers and holds, and
+// differs between the negotiated formats
+// This replaces the weak ETags the endpoint was first specified with: If-Match must compare
+// strongly (RFC 9110), so a weak tag could never satisfy it, and the tag changes with every byte of
+// the representation, which is what a strong validator promises
+func accountETag(account *models.Account, mediaType string) string {
+	state := fmt.Sprintf("%d|%s|%s|%s", account.AccountID, account.Balance.String(), account.UpdatedAt.UTC().Format(time.RFC3339Nano), mediaType)
+	sum := sha256.Sum256([]byte(state))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// responseETag returns the ETag of the account's representation in the format negotiated for r
+func responseETag(r *http.Request, account *models.Account) string {
+	return accountETag(account, responseCodec(r).MediaTypes()[0])
+}
+
+// setAccountHeaders sets the validator headers of a response standing for an account, a 200 with
+// its body or a 304 without: its ETag and Vary: Accept, as both depend on the negotiated format
+func setAccountHeaders(w http.ResponseWriter, r *http.Request, account *models.Account) {
+	w.Header().Set("ETag", responseETag(r, account))
+	varyAccept(w)
+}
+
+// etagMatches reports whether a conditional header lists etag; "*" matches any tag
+// With weak, as for If-None-Match, tags compare whether or not either is marked weak; otherwise,
+// as for If-Match, they compare strongly: a tag marked weak never matches
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if !strings.HasPrefix(tag, "W/") && !strings.HasPrefix(etag, "W/") && tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified answers 304 Not Modified if the request's If-None-Match lists the account's
+// current ETag, so clients polling an account only download it when it changed
+// Returns true if it answered; otherwise the caller writes the account
+func notModified(w http.ResponseWriter, r *http.Request, account *models.Account) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatches(header, responseETag(r, account), true) {
+		return false
+	}
+	setAccountHeaders(w, r, account)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// accountPrecondition evaluates the If-Match header of a request changing an account against the
+// account's current state, for endpoints changing accounts to offer optimistic concurrency
+// The header lists ETags of earlier responses, which match while the account is unchanged if they
+// were of the format negotiated for the request (compared strongly, so weak tags never match), or
+// account versions in quotes (e.g. "3"), the ETag of earlier releases, which match while the
+// account's version is; "*" or no header is no precondition
+// Returns the version the change must still find, to pass on as its expected version so a
+// concurrent change fails it too (0 without a precondition or for an unknown account, which the
+// change reports), and ok false after answering 412 or an error
+func (h *Handler) accountPrecondition(w http.ResponseWriter, r *http.Request, accountID int64) (version int64, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	account, err := h.accounts.GetAccount(accountID)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			return 0, true
+		}
+		slog.ErrorContext(r.Context(), "Account precondition error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return 0, false
+	}
+	if etagMatches(header, responseETag(r, account), false) || listsVersion(header, account.Version) {
+		return account.Version, true
+	}
+	http.Error(w, "Account has been modified (If-Match does not match)", http.StatusPreconditionFailed)
+	return 0, false
+}
+
+// listsVersion reports whether an If-Match header lists the account version as a strong tag
+func listsVersion(header string, version int64) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, `"`) && strings.Trim(tag, `"`) == strconv.FormatInt(version, 10) {
+			return true
+		}
+	}
+	return false
+}
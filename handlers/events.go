@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// Account event stream page sizes
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+// WithLedgerEvents attaches the event log of an event-sourced storage, served by GET
+// /accounts/{account_id}/events
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithLedgerEvents(events database.LedgerEventSource) *Handler {
+	h.ledgerEvents = events
+	return h
+}
+
+// GetAccountEvents handles GET /accounts/{account_id}/events endpoint (event-sourced storage only)
+// The account's history as recorded: its opening, status, overdraft and metadata changes and the
+// transfers debiting or crediting it, each with the balance folded from the events up to it
+// Query parameters:
+//   - after_seq (default 0): Only events with a greater sequence number are returned
+//   - cursor: next_cursor of the previous page, instead of after_seq
+//   - limit (1-1000, default 100): Maximum number of events returned
+//
+// Response: 200 OK with the events in sequence order, 400 for invalid parameters or a cursor issued
+// for another account, 404 if the account does not exist, 503 unless STORAGE=eventsourced.
+// Sequence numbers are shared by all accounts, so an account's events skip the numbers of others
+// Example response: {"account_id": 123, "after_seq": 0, "last_sequence": 7, "has_more": false,
+// "events": [{"sequence": 1, "type": "account.opened", "balance": "500", ...}, {"sequence": 7,
+// "type": "transfer.committed", "balance": "399.5", "transaction": {"id": 3, ...}}]}
+func (h *Handler) GetAccountEvents(w http.ResponseWriter, r *http.Request) {
+	if h.ledgerEvents == nil {
+		http.Error(w, "Account events are only recorded with STORAGE=eventsourced", http.StatusServiceUnavailable)
+		return
+	}
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("cursor") != "" && query.Get("after_seq") != "" {
+		http.Error(w, "after_seq cannot be combined with cursor", http.StatusBadRequest)
+		return
+	}
+	var after changesCursor
+	filters, ok := h.listingFilters(w, r, "account_events", url.Values{"account_id": {strconv.FormatInt(accountID, 10)}}, &after)
+	if !ok {
+		return
+	}
+	afterSeq, limit := after.Seq, defaultEventsLimit
+	if value := query.Get("after_seq"); value != "" {
+		if afterSeq, err = strconv.ParseInt(value, 10, 64); err != nil || afterSeq < 0 {
+			http.Error(w, "Invalid after_seq (expected a non-negative integer)", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxEventsLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxEventsLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// One extra event tells whether another page follows
+	events, err := h.ledgerEvents.AccountEvents(r.Context(), accountID, afterSeq, limit+1)
+	if err != nil {
+		if err.Error() == "account not found" {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Account events error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.AccountEventListResponse{AccountID: accountID, AfterSeq: afterSeq, LastSequence: afterSeq, HasMore: len(events) > limit}
+	if response.HasMore {
+		events = events[:limit]
+	}
+	response.Events = make([]models.AccountEventResponse, 0, len(events))
+	for _, event := range events {
+		response.Events = append(response.Events, models.NewAccountEventResponse(event))
+	}
+	if len(events) > 0 {
+		response.LastSequence = events[len(events)-1].Event.Sequence
+	}
+	if response.HasMore {
+		response.NextCursor = h.cursors.Encode("account_events", filters, changesCursor{Seq: response.LastSequence})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
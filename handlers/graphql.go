@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+
+	"internal-transfers/middleware"
+	"internal-transfers/models"
+	"internal-transfers/preauth"
+	"internal-transfers/rules"
+	"internal-transfers/service"
+	"internal-transfers/usage"
+)
+
+// graphqlSchema describes the GraphQL surface served at /graphql
+// Account and transaction IDs are 64-bit, which does not fit GraphQL's 32-bit Int, so they are
+// exposed as ID and sequence numbers use the Long scalar; amounts stay decimal strings as in REST
+const graphqlSchema = `
+	schema {
+		query: Query
+		mutation: Mutation
+	}
+
+	scalar Long
+	scalar Time
+
+	type Query {
+		account(id: ID!): Account
+	}
+
+	type Mutation {
+		transfer(sourceAccountId: ID!, destinationAccountId: ID!, amount: String!, transferType: String, convert: Boolean, reference: String, memo: String, dependsOn: ID): Transaction!
+	}
+
+	type Account {
+		id: ID!
+		balance: String!
+		overdraftLimit: String!
+		held: String!
+		available: String!
+		currency: String
+		sequence: Long!
+		status: String!
+		tags: [String!]!
+		transactions(limit: Int = 50): [Transaction!]!
+	}
+
+	type Transaction {
+		id: ID!
+		sourceAccountId: ID!
+		destinationAccountId: ID!
+		amount: String!
+		sourceSequence: Long!
+		destinationSequence: Long!
+		roundingPolicy: String!
+		transferType: String!
+		valueDate: String!
+		status: String!
+		destinationAmount: String!
+		fxRate: String
+		fxRateTimestamp: Time
+		createdAt: Time!
+		effectiveAt: Time!
+		reference: String
+		memo: String
+		dependsOn: ID
+		source: Account
+		destination: Account
+	}
+`
+
+// maxGraphQLTransactions caps the history returned per account in a single query
+const maxGraphQLTransactions = 500
+
+// GraphQL returns the handler for POST /graphql endpoint
+// Queries fetch an account with its nested transaction history in one round trip; the
+// transfer mutation applies the same validation and business rules as POST /transactions
+// Example query: { account(id: "123") { balance transactions(limit: 10) { amount destination { id } } } }
+func (h *Handler) GraphQL() http.Handler {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{h: h})
+	relayHandler := &relay.Handler{Schema: schema}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relayHandler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant(r))))
+	})
+}
+
+// tenantKey carries the request's tenant to the GraphQL resolvers
+type tenantKey struct{}
+
+// Long is a GraphQL scalar carrying a 64-bit integer
+type Long int64
+
+// ImplementsGraphQLType maps Long to the schema's Long scalar
+func (Long) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+// UnmarshalGraphQL accepts Long input values as numbers or numeric strings
+func (l *Long) UnmarshalGraphQL(input interface{}) error {
+	switch v := input.(type) {
+	case int32:
+		*l = Long(v)
+	case int64:
+		*l = Long(v)
+	case float64:
+		*l = Long(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid Long %q", v)
+		}
+		*l = Long(n)
+	default:
+		return fmt.Errorf("invalid Long input %T", input)
+	}
+	return nil
+}
+
+// MarshalJSON encodes Long as a JSON number
+func (l Long) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(l), 10), nil
+}
+
+// graphqlResolver is the root resolver for queries and mutations
+type graphqlResolver struct {
+	h *Handler
+}
+
+// Account resolves Query.account; unknown accounts resolve to null rather than an error
+func (r *graphqlResolver) Account(ctx context.Context, args struct{ ID graphql.ID }) (*accountResolver, error) {
+	accountID, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.h.resolveAccount(accountID)
+}
+
+// Transfer resolves Mutation.transfer
+func (r *graphqlResolver) Transfer(ctx context.Context, args struct {
+	SourceAccountID      graphql.ID
+	DestinationAccountID graphql.ID
+	Amount               string
+	TransferType         *string
+	Convert              *bool
+	Reference            *string
+	Memo                 *string
+	DependsOn            *graphql.ID
+}) (*transactionResolver, error) {
+	received := time.Now()
+	sourceID, err := parseGraphQLID(args.SourceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	destinationID, err := parseGraphQLID(args.DestinationAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := models.CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destinationID,
+		Amount:               args.Amount,
+	}
+	req.Tenant, _ = ctx.Value(tenantKey{}).(string)
+	req.ClientID = usage.KeyIDFromContext(ctx)
+	req.ReceivedAt = received
+	if args.TransferType != nil {
+		req.TransferType = *args.TransferType
+	}
+	if args.Convert != nil {
+		req.Convert = *args.Convert
+	}
+	if args.Reference != nil {
+		req.Reference = *args.Reference
+	}
+	if args.Memo != nil {
+		req.Memo = *args.Memo
+	}
+	if args.DependsOn != nil {
+		if req.DependsOn, err = parseGraphQLID(*args.DependsOn); err != nil {
+			return nil, err
+		}
+	}
+	middleware.LogAccounts(ctx, req.SourceAccountID, req.DestinationAccountID)
+	transaction, err := r.h.transfers.Transfer(req)
+	if err != nil {
+		var invalid *service.ValidationError
+		var violation *rules.Violation
+		var decline *preauth.Decline
+		switch {
+		case errors.As(err, &invalid), errors.As(err, &violation), errors.Is(err, service.ErrSourceNotFound), errors.Is(err, service.ErrDestinationNotFound),
+			errors.Is(err, service.ErrInsufficientBalance), errors.Is(err, service.ErrSourceFrozen), errors.Is(err, service.ErrDestinationFrozen),
+			errors.Is(err, service.ErrCurrencyMismatch), errors.Is(err, service.ErrCurrencyPrecision), errors.Is(err, service.ErrDuplicateReference),
+			errors.Is(err, service.ErrDependencyNotFound), errors.Is(err, service.ErrDependencyPending), errors.Is(err, service.ErrDependencyFailed),
+			errors.Is(err, service.ErrSourceWallet), errors.Is(err, service.ErrDestinationWallet),
+			errors.Is(err, service.ErrAmountBelowMinimum), errors.Is(err, service.ErrAmountAboveMaximum),
+			errors.Is(err, service.ErrTransactionConflict), errors.As(err, &decline):
+			return nil, err
+		case isRateError(err):
+			slog.ErrorContext(ctx, "GraphQL transfer FX rate error", "error", err)
+			return nil, fmt.Errorf("exchange rate unavailable")
+		case errors.Is(err, preauth.ErrUnavailable):
+			slog.ErrorContext(ctx, "GraphQL transfer pre-authorization error", "error", err)
+			return nil, fmt.Errorf("pre-authorization unavailable")
+		default:
+			slog.ErrorContext(ctx, "GraphQL transfer error", "error", err)
+			return nil, fmt.Errorf("failed to process transaction")
+		}
+	}
+	r.h.recordTransfer(ctx, transaction)
+	return &transactionResolver{h: r.h, t: *transaction}, nil
+}
+
+// resolveAccount loads an account, returning nil for a missing account
+func (h *Handler) resolveAccount(accountID int64) (*accountResolver, error) {
+	account, err := h.accounts.GetAccount(accountID)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			return nil, nil
+		}
+		slog.Error("GraphQL account lookup error", "account_id", accountID, "error", err)
+		return nil, fmt.Errorf("internal server error")
+	}
+	return &accountResolver{h: h, a: *account}, nil
+}
+
+// accountResolver resolves Account fields
+type accountResolver struct {
+	h *Handler
+	a models.Account
+}
+
+func (r *accountResolver) ID() graphql.ID         { return formatGraphQLID(r.a.AccountID) }
+func (r *accountResolver) Balance() string        { return r.a.Balance.String() }
+func (r *accountResolver) OverdraftLimit() string { return r.a.OverdraftLimit.String() }
+func (r *accountResolver) Held() string           { return r.a.Held.String() }
+func (r *accountResolver) Available() string      { return r.a.Available().String() }
+func (r *accountResolver) Sequence() Long         { return Long(r.a.Sequence) }
+func (r *accountResolver) Status() string         { return r.a.Status }
+
+// Currency resolves to null for accounts created without a currency
+func (r *accountResolver) Currency() *string {
+	if r.a.Currency == "" {
+		return nil
+	}
+	return &r.a.Currency
+}
+
+func (r *accountResolver) Tags() []string {
+	return models.NewAccountResponse(r.a).Tags
+}
+
+// Transactions resolves the account's history, newest first
+// The limit argument defaults to 50 in the schema
+func (r *accountResolver) Transactions(args struct{ Limit int32 }) ([]*transactionResolver, error) {
+	limit := int(args.Limit)
+	if limit <= 0 || limit > maxGraphQLTransactions {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxGraphQLTransactions)
+	}
+
+	transactions, err := r.h.transfers.Transactions(r.a.AccountID, limit)
+	if err != nil {
+		slog.Error("GraphQL transaction listing error", "account_id", r.a.AccountID, "error", err)
+		return nil, fmt.Errorf("internal server error")
+	}
+
+	resolvers := make([]*transactionResolver, len(transactions))
+	for i, t := range transactions {
+		resolvers[i] = &transactionResolver{h: r.h, t: t}
+	}
+	return resolvers, nil
+}
+
+// transactionResolver resolves Transaction fields
+type transactionResolver struct {
+	h *Handler
+	t models.Transaction
+}
+
+func (r *transactionResolver) ID() graphql.ID { return formatGraphQLID(r.t.ID) }
+func (r *transactionResolver) SourceAccountID() graphql.ID {
+	return formatGraphQLID(r.t.SourceAccountID)
+}
+func (r *transactionResolver) DestinationAccountID() graphql.ID {
+	return formatGraphQLID(r.t.DestinationAccountID)
+}
+func (r *transactionResolver) Amount() string            { return r.t.Amount.String() }
+func (r *transactionResolver) SourceSequence() Long      { return Long(r.t.SourceSequence) }
+func (r *transactionResolver) DestinationSequence() Long { return Long(r.t.DestinationSequence) }
+func (r *transactionResolver) RoundingPolicy() string    { return string(r.t.RoundingPolicy) }
+func (r *transactionResolver) TransferType() string      { return r.t.TransferType }
+func (r *transactionResolver) ValueDate() string         { return r.t.ValueDate.Format("2006-01-02") }
+func (r *transactionResolver) Status() string            { return r.t.Status }
+func (r *transactionResolver) CreatedAt() graphql.Time   { return graphql.Time{Time: r.t.CreatedAt} }
+func (r *transactionResolver) EffectiveAt() graphql.Time { return graphql.Time{Time: r.t.EffectiveAt} }
+func (r *transactionResolver) DestinationAmount() string {
+	return r.t.DestinationAmount.String()
+}
+
+// FXRate and FXRateTimestamp are null for same-currency transactions
+func (r *transactionResolver) FXRate() *string {
+	if !r.t.Converted() {
+		return nil
+	}
+	rate := r.t.FXRate.String()
+	return &rate
+}
+func (r *transactionResolver) FXRateTimestamp() *graphql.Time {
+	if !r.t.Converted() {
+		return nil
+	}
+	return &graphql.Time{Time: r.t.FXRateTimestamp}
+}
+
+// Reference and Memo are null when the transfer was not annotated
+func (r *transactionResolver) Reference() *string {
+	if r.t.Reference == "" {
+		return nil
+	}
+	return &r.t.Reference
+}
+func (r *transactionResolver) Memo() *string {
+	if r.t.Memo == "" {
+		return nil
+	}
+	return &r.t.Memo
+}
+
+// DependsOn is null for transfers not chained after another
+func (r *transactionResolver) DependsOn() *graphql.ID {
+	if r.t.DependsOn == 0 {
+		return nil
+	}
+	id := formatGraphQLID(r.t.DependsOn)
+	return &id
+}
+func (r *transactionResolver) Source() (*accountResolver, error) {
+	return r.h.resolveAccount(r.t.SourceAccountID)
+}
+func (r *transactionResolver) Destination() (*accountResolver, error) {
+	return r.h.resolveAccount(r.t.DestinationAccountID)
+}
+
+// parseGraphQLID converts a GraphQL ID into a positive account or transaction ID
+func parseGraphQLID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid ID %q", id)
+	}
+	return n, nil
+}
+
+// formatGraphQLID converts a numeric ID into a GraphQL ID
+func formatGraphQLID(id int64) graphql.ID {
+	return graphql.ID(strconv.FormatInt(id, 10))
+}
@@ -3,20 +3,70 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"internal-transfers/attachments"
+	"internal-transfers/audit"
+	"internal-transfers/currencies"
+	"internal-transfers/cutoff"
 	"internal-transfers/database"
+	"internal-transfers/fx"
+	"internal-transfers/holds"
+	"internal-transfers/limits"
+	"internal-transfers/middleware"
 	"internal-transfers/models"
+	"internal-transfers/pagination"
+	"internal-transfers/preauth"
+	"internal-transfers/pubsub"
+	"internal-transfers/reconcile"
+	"internal-transfers/recurring"
+	"internal-transfers/retention"
+	"internal-transfers/rules"
+	"internal-transfers/service"
+	"internal-transfers/settlement"
+	"internal-transfers/signing"
+	"internal-transfers/sla"
+	"internal-transfers/usage"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/shopspring/decimal"
 )
 
 // Handler contains the dependencies for HTTP handlers
+// Business rules live in the account and transfer services; handlers translate HTTP (and GraphQL)
+// requests into service calls and service errors into responses
 type Handler struct {
 	accountRepo     database.AccountRepositoryInterface
 	transactionRepo database.TransactionRepositoryInterface
+	accounts        *service.AccountService
+	transfers       *service.TransferService
+	poolStats       func() database.PoolStats
+	broker          *pubsub.Broker
+	settlements     database.SettlementRepositoryInterface
+	ingester        *settlement.Ingester
+	usage           *usage.Recorder
+	latency         *sla.Recorder
+	recurring       *recurring.Scheduler
+	holds           *holds.Manager
+	limits          *limits.Manager
+	cursors         *pagination.Codec
+	audit           *audit.Recorder
+	balanceHistory  database.BalanceHistoryRepositoryInterface
+	outbox          database.OutboxRepositoryInterface
+	reconciler      *reconcile.Reconciler
+	retention       *retention.Enforcer
+	attachments     *attachments.Manager
+	adjustments     database.AdjustmentRepositoryInterface
+	wallets         database.WalletRepositoryInterface
+	currencies      *currencies.Manager
+	signing         *signing.Manager
+	allowlist       middleware.Allowlist
+	ledgerEvents    database.LedgerEventSource
 }
 
 // NewHandler creates a new handler with database repositories
@@ -26,64 +76,182 @@ type Handler struct {
 //
 // Returns: Configured Handler with account and transaction repositories
 func NewHandler(db *sql.DB) *Handler {
+	return NewHandlerWithRepositories(database.NewAccountRepository(db), database.NewTransactionRepository(db))
+}
+
+// NewHandlerWithRepositories creates a handler from already constructed repositories
+// This is used for alternative storage backends (e.g. STORAGE=memory) and for tests
+// Parameters:
+//   - accountRepo: Repository used for account operations
+//   - transactionRepo: Repository used for money transfers
+//
+// Returns: Configured Handler using the given repositories
+func NewHandlerWithRepositories(accountRepo database.AccountRepositoryInterface, transactionRepo database.TransactionRepositoryInterface) *Handler {
 	return &Handler{
-		accountRepo:     database.NewAccountRepository(db),
-		transactionRepo: database.NewTransactionRepository(db),
+		accountRepo:     accountRepo,
+		transactionRepo: transactionRepo,
+		accounts:        service.NewAccountService(accountRepo),
+		transfers:       service.NewTransferService(transactionRepo),
+		cursors:         pagination.NewRandomCodec(),
+	}
+}
+
+// NewHandlerWithStorage creates a handler on a complete storage backend
+// This is the entry point for embedding the service with a custom database.Storage implementation
+// Pool statistics are attached when the storage provides them (database.PoolStatsProvider), and
+// account event streams when it is event-sourced (database.LedgerEventSource)
+// Returns: Configured Handler using the storage's repositories
+func NewHandlerWithStorage(storage database.Storage) *Handler {
+	h := NewHandlerWithRepositories(storage.Accounts(), storage.Transactions()).
+		WithSettlements(storage.Settlements()).
+		WithAdjustments(storage.Adjustments())
+	if stats, ok := storage.(database.PoolStatsProvider); ok {
+		h.WithPoolStats(stats.PoolStats)
+	}
+	if events, ok := storage.(database.LedgerEventSource); ok {
+		h.WithLedgerEvents(events)
+	}
+	return h
+}
+
+// Accounts returns the account service behind the handler, e.g. to register OnAccountCreated hooks
+func (h *Handler) Accounts() *service.AccountService {
+	return h.accounts
+}
+
+// Transfers returns the transfer service behind the handler, e.g. to register OnTransferCommitted
+// hooks that also see transfers made through the REST and GraphQL APIs
+func (h *Handler) Transfers() *service.TransferService {
+	return h.transfers
+}
+
+// WithPoolStats attaches a connection pool statistics source used by the /health/db endpoint
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithPoolStats(stats func() database.PoolStats) *Handler {
+	h.poolStats = stats
+	return h
+}
+
+// WithRoundingPolicy sets the policy used to round amounts to the stored scale
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithRoundingPolicy(policy models.RoundingPolicy) *Handler {
+	h.accounts.WithRoundingPolicy(policy)
+	h.transfers.WithRoundingPolicy(policy)
+	return h
+}
+
+// WithCutoffSchedule sets the cut-off schedule used to accept transfer types and assign value dates
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithCutoffSchedule(schedule *cutoff.Schedule) *Handler {
+	h.transfers.WithCutoffSchedule(schedule)
+	return h
+}
+
+// WithRules sets the validation rules evaluated before each transfer, against accounts read from
+// the handler's account repository
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithRules(engine *rules.Engine) *Handler {
+	h.transfers.WithRules(engine, h.accountRepo)
+	return h
+}
+
+// Rules returns the validation rules, or nil if none are set
+func (h *Handler) Rules() *rules.Engine {
+	return h.transfers.Rules()
+}
+
+// WithPreauthorization sets the external decision endpoint asked to approve each transfer; a nil
+// client leaves pre-authorization disabled
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithPreauthorization(client *preauth.Client) *Handler {
+	h.transfers.WithPreauthorization(client)
+	return h
+}
+
+// WithFX enables cross-currency transfers priced by rates, reading account currencies from the
+// handler's account repository; a nil provider leaves conversion disabled
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithFX(rates fx.RateProvider) *Handler {
+	h.transfers.WithFX(rates, h.accountRepo)
+	return h
+}
+
+// isRateError reports whether err means no usable exchange rate was available
+func isRateError(err error) bool {
+	return errors.Is(err, fx.ErrRateUnavailable) || errors.Is(err, fx.ErrStaleRate) || errors.Is(err, fx.ErrRateDeviation)
+}
+
+// TenantHeader names the tenant whose validation rules apply to a transfer
+const TenantHeader = "X-Tenant-ID"
+
+// tenant returns the request's tenant, or the default tenant if the header is absent
+func tenant(r *http.Request) string {
+	if t := r.Header.Get(TenantHeader); t != "" {
+		return t
 	}
+	return rules.DefaultTenant
 }
 
 // CreateAccount handles POST /accounts endpoint for creating new bank accounts
 // This endpoint allows creation of new accounts with an initial balance
-// Request body: JSON with account_id (int64) and initial_balance (string decimal)
+// Request body: JSON with account_id (int64), initial_balance (string decimal), and optional
+// currency, external_id and creation_token
 // Validation rules:
 //   - Account ID must be positive
 //   - Initial balance must be valid decimal format and non-negative
-//   - Account ID must not already exist in the system
+//   - Initial balance is rounded to the stored scale using the configured rounding policy
+//   - Currency, if given, must be a supported ISO 4217 code, and the initial balance may not have
+//     more decimal places than it allows (e.g. 2 for USD, 0 for JPY)
+//   - External ID, if given, must be letters, digits and _.:- up to 128 characters
+//   - Creation token, if given, must be letters, digits and _.:- up to 128 characters
+//   - Account ID and external ID must not already exist in the system
 //
-// Response: 201 Created on success, various 4xx/5xx on validation/server errors
-// Example request: {"account_id": 123, "initial_balance": "100.50"}
+// A retried request with the same creation_token and payload does not conflict with the account
+// the first attempt created: it returns 200 OK with that account, so provisioning pipelines can
+// retry blindly
+// Response: 201 Created on success, 200 OK with the account for a replayed creation, 400 with the
+// violation of each invalid field as JSON (see writeValidationError), 409 if the account ID or
+// external ID is taken or the creation token was used for a different request, various 4xx/5xx
+// on server errors
+// Example request: {"account_id": 123, "initial_balance": "100.50", "currency": "USD", "external_id": "ERP-4711",
+// "creation_token": "provision-7f3a"}
 func (h *Handler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateAccountRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate account ID
-	if req.AccountID <= 0 {
-		http.Error(w, "Account ID must be positive", http.StatusBadRequest)
-		return
-	}
-
-	// Parse initial balance
-	initialBalance, err := decimal.NewFromString(req.InitialBalance)
-	if err != nil {
-		http.Error(w, "Invalid initial balance format", http.StatusBadRequest)
-		return
-	}
-
-	// Validate initial balance is non-negative
-	if initialBalance.IsNegative() {
-		http.Error(w, "Initial balance cannot be negative", http.StatusBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		writeBodyError(w, err)
 		return
 	}
 
-	// Check if account already exists
-	exists, err := h.accountRepo.AccountExists(req.AccountID)
+	created, err := h.accounts.CreateAccount(req)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		var invalid *service.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeValidationError(w, r, invalid)
+		case errors.Is(err, service.ErrAccountExists):
+			http.Error(w, "Account already exists", http.StatusConflict)
+		case errors.Is(err, service.ErrExternalIDExists):
+			http.Error(w, "External ID already in use by another account", http.StatusConflict)
+		case errors.Is(err, service.ErrCreationTokenUsed):
+			http.Error(w, "Creation token already used for a different request", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to create account", http.StatusInternalServerError)
+		}
 		return
 	}
-	if exists {
-		http.Error(w, "Account already exists", http.StatusConflict)
+	if !created {
+		account, err := h.accounts.GetAccount(req.AccountID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Get replayed account error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		writeAccount(w, r, account)
 		return
 	}
-
-	// Create account
-	if err := h.accountRepo.CreateAccount(req.AccountID, initialBalance); err != nil {
-		http.Error(w, "Failed to create account", http.StatusInternalServerError)
-		return
+	if account, err := h.accountRepo.GetAccount(req.AccountID); err == nil {
+		h.recordAccountChange(r.Context(), audit.APIKeyActor(r.Context()), models.AuditAccountCreated, nil, account)
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -96,8 +264,16 @@ func (h *Handler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 //   - Account ID must be a valid integer
 //   - Account must exist in the system
 //
-// Response: JSON with account_id and current balance on success, 404 if not found
-// Example response: {"account_id": 123, "balance": "100.50"}
+// Query parameters (optional):
+//   - as_of: RFC 3339 timestamp or date (midnight UTC); returns the balance the account had at that
+//     time instead, replayed from the ledger as it stood then, for month-end reporting (see
+//     GetBalanceAsOf, whose response it shares, for backdated transfers recorded later)
+//
+// Response: JSON with account_id, current balance, latest ledger sequence, status (active or
+// frozen), external_id if set, metadata and tags on success, and for a multi-currency account
+// every balance under wallets, and the account's ETag; 304 Not Modified without a body if
+// If-None-Match lists the ETag; 404 if not found (or, with as_of, not yet opened then)
+// Example response: {"account_id": 123, "balance": "100.50", "sequence": 7, "status": "active", "metadata": {}, "tags": []}
 func (h *Handler) GetAccount(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	accountIDStr := vars["account_id"]
@@ -108,23 +284,276 @@ func (h *Handler) GetAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account, err := h.accountRepo.GetAccount(accountID)
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		asOf, err := parseListingTime(value)
+		if err != nil {
+			http.Error(w, "Invalid as_of (expected RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		h.writeBalanceAsOf(w, r, accountID, asOf, asOf)
+		return
+	}
+
+	account, err := h.accounts.GetAccount(accountID)
 	if err != nil {
-		if err.Error() == "account not found" {
+		if errors.Is(err, service.ErrAccountNotFound) {
 			http.Error(w, "Account not found", http.StatusNotFound)
 			return
 		}
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	h.writeAccountWithWallets(w, r, account)
+}
 
-	response := models.AccountResponse{
-		AccountID: account.AccountID,
-		Balance:   account.Balance.String(),
+// GetAccountByExternalID handles GET /accounts/by-external-id/{external_id} endpoint
+// This endpoint finds an account by its identifier in an external system (e.g. core banking or
+// the ERP), so integrators need not keep their own mapping to account IDs
+// URL parameter: external_id - the external ID set when the account was created or updated
+// Response: the same JSON as GET /accounts/{account_id}; 404 if no account has the external ID
+func (h *Handler) GetAccountByExternalID(w http.ResponseWriter, r *http.Request) {
+	account, err := h.accounts.GetAccountByExternalID(mux.Vars(r)["external_id"])
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Get account by external ID error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
+	h.writeAccountWithWallets(w, r, account)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// writeAccountWithWallets writes an account response with its wallets, if any, and its ETag, or
+// 304 Not Modified if the request's If-None-Match lists the ETag
+func (h *Handler) writeAccountWithWallets(w http.ResponseWriter, r *http.Request, account *models.Account) {
+	if notModified(w, r, account) {
+		return
+	}
+	wallets, err := h.accounts.Wallets(r.Context(), account.AccountID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Get account wallets error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.NewAccountResponse(*account)
+	if len(wallets) > 0 {
+		response.Wallets = models.NewWalletResponses(*account, wallets)
+	}
+	setAccountHeaders(w, r, account)
+	writeBody(w, r, http.StatusOK, response)
+}
+
+// writeBodyError responds to a request body that could not be decoded: 413 if it exceeded the
+// route's body limit, 400 otherwise
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Invalid request body", http.StatusBadRequest)
+}
+
+// writeValidationError responds 400 to an invalid request: with the violation of each invalid
+// field, as JSON or the format negotiated from r's Accept header, if the request was checked
+// field by field, and the plain message otherwise
+func writeValidationError(w http.ResponseWriter, r *http.Request, invalid *service.ValidationError) {
+	if len(invalid.Fields) == 0 {
+		http.Error(w, invalid.Message, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeBody(w, r, http.StatusBadRequest, models.ValidationErrorResponse{Error: invalid.Message, Fields: invalid.Fields})
+}
+
+// writeAccount writes an account response with its ETag
+func writeAccount(w http.ResponseWriter, r *http.Request, account *models.Account) {
+	setAccountHeaders(w, r, account)
+	writeBody(w, r, http.StatusOK, models.NewAccountResponse(*account))
+}
+
+// Account listing page sizes
+const (
+	defaultAccountListing = 100
+	maxAccountListing     = 1000
+)
+
+// TotalCountHeader carries the number of accounts matching a listing's filters across all pages
+const TotalCountHeader = "X-Total-Count"
+
+// ListAccounts handles GET /accounts endpoint for browsing accounts
+// Query parameters (all optional):
+//   - limit (1-1000, default 100): page size
+//   - cursor: next_cursor of the previous page, continuing the listing with its filters
+//   - offset (default 0): skip matches instead; cannot be combined with cursor
+//   - tag: only accounts carrying this tag
+//   - status: only active or frozen accounts
+//   - min_balance, max_balance: inclusive balance range
+//   - created_from (inclusive), created_before (exclusive): creation time as RFC 3339 or YYYY-MM-DD (UTC)
+//
+// Response: 200 OK with the page of matching accounts ordered by account ID, next_cursor while the
+// page is full, and the total number of matches in the X-Total-Count header; 400 for invalid
+// parameters or a cursor that is invalid or was issued for other filters
+// Example response: {"accounts": [{"account_id": 123, "balance": "100.5", "tags": ["payroll"], ...}], "next_cursor": "eyJ2Ijox..."}
+func (h *Handler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("cursor") != "" && query.Get("offset") != "" {
+		http.Error(w, "offset cannot be combined with cursor", http.StatusBadRequest)
+		return
+	}
+	var after accountCursor
+	filters, ok := h.listingFilters(w, r, "list_accounts", pagination.Filters(query, accountFilterParams...), &after)
+	if !ok {
+		return
+	}
+	for name := range filters {
+		query.Set(name, filters.Get(name))
+	}
+
+	filter, limit, offset, err := parseAccountListing(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.AfterID = after.AccountID
+
+	accounts, total, err := h.accounts.ListAccounts(filter, limit, offset)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Account listing error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.AccountListResponse{Accounts: make([]models.AccountResponse, 0, len(accounts))}
+	for _, account := range accounts {
+		response.Accounts = append(response.Accounts, models.NewAccountResponse(account))
+	}
+	if len(accounts) == limit {
+		response.NextCursor = h.cursors.Encode("list_accounts", filters, accountCursor{AccountID: accounts[len(accounts)-1].AccountID})
+	}
+
+	w.Header().Set(TotalCountHeader, strconv.Itoa(total))
+	writeBody(w, r, http.StatusOK, response)
+}
+
+// accountFilterParams are the query parameters of GET /accounts its cursors are bound to
+var accountFilterParams = []string{"tag", "status", "min_balance", "max_balance", "created_from", "created_before"}
+
+// parseAccountListing reads the filter and page of GET /accounts from its query parameters
+// Errors are client-facing
+func parseAccountListing(query url.Values) (filter models.AccountFilter, limit, offset int, err error) {
+	limit, offset = defaultAccountListing, 0
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxAccountListing {
+			return filter, 0, 0, fmt.Errorf("Invalid limit (expected 1-%d)", maxAccountListing)
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return filter, 0, 0, fmt.Errorf("Invalid offset (expected a non-negative integer)")
+		}
+	}
+
+	filter.Tag = query.Get("tag")
+	filter.Status = query.Get("status")
+	if filter.Status != "" && filter.Status != models.AccountActive && filter.Status != models.AccountFrozen {
+		return filter, 0, 0, fmt.Errorf("Invalid status (expected %s or %s)", models.AccountActive, models.AccountFrozen)
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **decimal.Decimal
+	}{{"min_balance", &filter.MinBalance}, {"max_balance", &filter.MaxBalance}} {
+		if value := query.Get(bound.name); value != "" {
+			balance, parseErr := decimal.NewFromString(value)
+			if parseErr != nil {
+				return filter, 0, 0, fmt.Errorf("Invalid %s (expected a decimal amount)", bound.name)
+			}
+			*bound.target = &balance
+		}
+	}
+	if filter.MinBalance != nil && filter.MaxBalance != nil && filter.MinBalance.GreaterThan(*filter.MaxBalance) {
+		return filter, 0, 0, fmt.Errorf("min_balance must not exceed max_balance")
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"created_from", &filter.CreatedFrom}, {"created_before", &filter.CreatedBefore}} {
+		if value := query.Get(bound.name); value != "" {
+			if *bound.target, err = parseListingTime(value); err != nil {
+				return filter, 0, 0, fmt.Errorf("Invalid %s (expected RFC 3339 or YYYY-MM-DD)", bound.name)
+			}
+		}
+	}
+	return filter, limit, offset, nil
+}
+
+// parseListingTime accepts an RFC 3339 timestamp or a date, meaning midnight UTC
+func parseListingTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// UpdateAccount handles PATCH /accounts/{account_id} endpoint for account metadata, tags and
+// external ID
+// This endpoint lets callers attach names, cost centers and other labels to an account; balances
+// cannot be changed through it, and status only through the admin freeze/unfreeze endpoints
+// Request body: JSON with optional metadata (object), tags (array of strings) and external_id
+//   - metadata is merged into the existing metadata; a key set to null is removed
+//   - tags, when present, replace the account's tags
+//   - external_id, when present, replaces the account's external ID; "" removes it
+//
+// Optimistic concurrency: sending the ETag of an earlier response back as If-Match applies the
+// update only if the account did not change in between (see accountPrecondition)
+// Response: 200 OK with the updated account, 400 for invalid metadata, tags or external ID, 404 if
+// the account does not exist, 409 if another account has the external ID, 412 if If-Match does
+// not match the current account
+// Example request: {"metadata": {"cost_center": "CC-42", "name": null}, "tags": ["payroll"]}
+func (h *Handler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateAccountRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	expectedVersion, ok := h.accountPrecondition(w, r, accountID)
+	if !ok {
+		return
+	}
+
+	before := h.auditedAccount(accountID)
+	account, err := h.accounts.UpdateAccount(accountID, req, expectedVersion)
+	if err != nil {
+		var invalid *service.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeValidationError(w, r, invalid)
+		case errors.Is(err, service.ErrAccountNotFound):
+			http.Error(w, "Account not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrVersionMismatch):
+			http.Error(w, "Account has been modified (If-Match does not match)", http.StatusPreconditionFailed)
+		case errors.Is(err, service.ErrExternalIDExists):
+			http.Error(w, "External ID already in use by another account", http.StatusConflict)
+		default:
+			slog.ErrorContext(r.Context(), "Account update error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.recordAccountChange(r.Context(), audit.APIKeyActor(r.Context()), models.AuditAccountUpdated, before, account)
+
+	writeAccount(w, r, account)
 }
 
 // CreateTransaction handles POST /transactions endpoint for transferring money between accounts
@@ -133,62 +562,142 @@ func (h *Handler) GetAccount(w http.ResponseWriter, r *http.Request) {
 // Business rules:
 //   - Both account IDs must be positive and different from each other
 //   - Amount must be positive decimal value
-//   - Source account must have sufficient balance
+//   - Amount is rounded to the stored scale using the configured rounding policy, which is
+//     recorded on the transaction
+//   - Optional transfer_type (default "internal") must be known; transfers after the type's
+//     cut-off time, or on non-business days, are value-dated to the next business day
+//   - Source account must have sufficient balance, counting its overdraft limit
 //   - Both accounts must exist in the system
+//   - Neither account may be frozen; compliance holds are reported as 423 Locked
+//   - Both accounts must hold the same currency, and the amount may not have more decimal places
+//     than that currency allows; with "convert": true, accounts of different currencies are
+//     allowed and the destination is credited the amount converted at the current FX rate
+//     (503 if no usable rate is available)
+//   - Optional currency moves a multi-currency account's wallet in that currency instead of its
+//     primary balance, on each side whose own currency differs; 422 if an account has no such
+//     wallet. It cannot be combined with convert, and wallets cannot be overdrawn
+//   - Configured validation rules for the X-Tenant-ID tenant must pass; a rejection is reported
+//     as 422 Unprocessable Entity naming the rule
+//   - The pre-authorization endpoint, if configured, must approve the transfer; a decline is
+//     reported as 422 with its reason, and 503 means it gave no verdict and fails closed
+//   - Optional reference (up to 64 characters) must not have been used by an earlier transfer
+//     from the same source account (409 Conflict); optional memo is up to 500 characters
+//   - Optional depends_on names a transaction that must have completed and not been reversed;
+//     404 if it does not exist, 409 while it is pending, 422 if it failed or was reversed
 //
-// Response: 201 Created on success, various 4xx/5xx on validation/business rule violations
+// Response: 201 Created with the transaction (including per-account sequence numbers) on success,
+// 400 with the violation of each invalid field as JSON (see writeValidationError) for a malformed
+// request, various 4xx/5xx on business rule violations
 // Example request: {"source_account_id": 123, "destination_account_id": 456, "amount": "50.00"}
 // Note: This operation is atomic - either both account balances are updated or neither
+// Note: Committed transfers' latency from receipt is recorded for the caller's SLA report (see GetSLA);
+// the response's Server-Timing header has the time each stage took and X-Latency-Budget-Exceeded
+// names the stages over their latency budget
 func (h *Handler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
 	var req models.CreateTransactionRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		writeBodyError(w, err)
 		return
 	}
+	req.Tenant = tenant(r)
+	req.ClientID = usage.KeyIDFromContext(r.Context())
+	req.ReceivedAt = received
+	middleware.LogAccounts(r.Context(), req.SourceAccountID, req.DestinationAccountID)
 
-	// Validate account IDs
-	if req.SourceAccountID <= 0 || req.DestinationAccountID <= 0 {
-		http.Error(w, "Account IDs must be positive", http.StatusBadRequest)
+	transaction, err := h.transfers.Transfer(req)
+	if err != nil {
+		writeTransferError(w, r, err)
 		return
 	}
+	h.recordTransfer(r.Context(), transaction)
 
-	// Validate that source and destination accounts are different
-	if req.SourceAccountID == req.DestinationAccountID {
-		http.Error(w, "Source and destination accounts must be different", http.StatusBadRequest)
-		return
-	}
+	response := models.NewTransactionResponse(*transaction)
 
-	// Parse amount
-	amount, err := decimal.NewFromString(req.Amount)
-	if err != nil {
-		http.Error(w, "Invalid amount format", http.StatusBadRequest)
-		return
-	}
+	h.writeServerTiming(w, transaction)
+	writeBody(w, r, http.StatusCreated, response)
+}
 
-	// Validate amount is positive
-	if amount.IsZero() || amount.IsNegative() {
-		http.Error(w, "Amount must be positive", http.StatusBadRequest)
-		return
+// writeTransferError maps an error of service.TransferService.Transfer to a response
+// Shared by every endpoint that books a transfer (transfers, hold captures)
+func writeTransferError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid *service.ValidationError
+	var violation *rules.Violation
+	var decline *preauth.Decline
+	switch {
+	case errors.As(err, &invalid):
+		writeValidationError(w, r, invalid)
+	case errors.As(err, &violation):
+		http.Error(w, fmt.Sprintf("Transfer rejected by rule %s: %s", violation.Rule, violation.Message), http.StatusUnprocessableEntity)
+	case errors.As(err, &decline):
+		http.Error(w, declineMessage(decline), http.StatusUnprocessableEntity)
+	case errors.Is(err, preauth.ErrUnavailable):
+		slog.ErrorContext(r.Context(), "Transaction pre-authorization error", "error", err)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Pre-authorization unavailable; retry later", http.StatusServiceUnavailable)
+	case errors.Is(err, service.ErrSourceNotFound):
+		http.Error(w, "Source account not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrDestinationNotFound):
+		http.Error(w, "Destination account not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInsufficientBalance):
+		http.Error(w, "Insufficient balance", http.StatusBadRequest)
+	case errors.Is(err, service.ErrSourceFrozen):
+		http.Error(w, "Source account is frozen (compliance hold)", http.StatusLocked)
+	case errors.Is(err, service.ErrDestinationFrozen):
+		http.Error(w, "Destination account is frozen (compliance hold)", http.StatusLocked)
+	case errors.Is(err, service.ErrCurrencyMismatch):
+		http.Error(w, "Source and destination accounts hold different currencies", http.StatusBadRequest)
+	case errors.Is(err, service.ErrCurrencyPrecision):
+		http.Error(w, "Amount has more decimal places than the account currency allows", http.StatusBadRequest)
+	case errors.Is(err, service.ErrAmountBelowMinimum):
+		http.Error(w, "Amount is below the currency's minimum transfer amount", http.StatusBadRequest)
+	case errors.Is(err, service.ErrAmountAboveMaximum):
+		http.Error(w, "Amount is above the currency's maximum transfer amount", http.StatusBadRequest)
+	case errors.Is(err, service.ErrSourceWallet):
+		http.Error(w, "Source account has no wallet in the transfer currency", http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrDestinationWallet):
+		http.Error(w, "Destination account has no wallet in the transfer currency", http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrHoldNotFound):
+		http.Error(w, "Hold not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrHoldNotActive):
+		http.Error(w, "Hold is no longer active", http.StatusConflict)
+	case errors.Is(err, service.ErrHoldExpired):
+		http.Error(w, "Hold has expired", http.StatusConflict)
+	case errors.Is(err, service.ErrHoldExceeded):
+		http.Error(w, "Capture amount exceeds the held amount", http.StatusBadRequest)
+	case errors.Is(err, service.ErrAmountLimit):
+		http.Error(w, "Amount exceeds the source account's per-transfer limit", http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrDailyAmountLimit):
+		http.Error(w, "Transfer would exceed the source account's daily amount limit", http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrDailyCountLimit):
+		http.Error(w, "Transfer would exceed the source account's daily transfer count limit", http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrDuplicateReference):
+		http.Error(w, "The source account already sent a transfer with this reference", http.StatusConflict)
+	case errors.Is(err, service.ErrDependencyNotFound):
+		http.Error(w, "Dependency transaction not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrDependencyPending):
+		http.Error(w, "Dependency transaction has not completed yet; retry later", http.StatusConflict)
+	case errors.Is(err, service.ErrDependencyFailed):
+		http.Error(w, "Dependency transaction failed or was reversed", http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrTransactionConflict):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Transaction conflicted with concurrent transfers; retry later", http.StatusServiceUnavailable)
+	case isRateError(err):
+		slog.ErrorContext(r.Context(), "Transaction FX rate error", "error", err)
+		http.Error(w, "Exchange rate unavailable", http.StatusServiceUnavailable)
+	default:
+		slog.ErrorContext(r.Context(), "Transaction error", "error", err)
+		http.Error(w, "Failed to process transaction", http.StatusInternalServerError)
 	}
+}
 
-	// Create transaction
-	if err := h.transactionRepo.CreateTransaction(req.SourceAccountID, req.DestinationAccountID, amount); err != nil {
-		switch err.Error() {
-		case "source account not found":
-			http.Error(w, "Source account not found", http.StatusNotFound)
-		case "destination account not found":
-			http.Error(w, "Destination account not found", http.StatusNotFound)
-		case "insufficient balance":
-			http.Error(w, "Insufficient balance", http.StatusBadRequest)
-		default:
-			fmt.Printf("Transaction error: %v\n", err)
-			http.Error(w, "Failed to process transaction", http.StatusInternalServerError)
-		}
-		return
+// declineMessage describes a pre-authorization decline to the client
+func declineMessage(decline *preauth.Decline) string {
+	if decline.Reason == "" {
+		return "Transfer declined by pre-authorization"
 	}
-
-	w.WriteHeader(http.StatusCreated)
+	return "Transfer declined by pre-authorization: " + decline.Reason
 }
 
 // HealthCheck handles GET /health endpoint for service health monitoring
@@ -202,3 +711,18 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
+
+// DatabaseStats handles GET /health/db endpoint for connection pool monitoring
+// This endpoint exposes the pgx pool statistics so operators can see saturation
+// (acquired vs max connections, acquires that had to wait) before it causes timeouts
+// Response: 200 OK with pool statistics JSON, 503 if no pool is attached
+// Example response: {"max_conns": 25, "total_conns": 4, "acquired_conns": 1, ...}
+func (h *Handler) DatabaseStats(w http.ResponseWriter, r *http.Request) {
+	if h == nil || h.poolStats == nil {
+		http.Error(w, "Pool statistics unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.poolStats())
+}
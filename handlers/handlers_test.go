@@ -1,19 +1,48 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"internal-transfers/attachments"
+	"internal-transfers/audit"
+	"internal-transfers/cutoff"
 	"internal-transfers/database"
+	"internal-transfers/eventsource"
+	"internal-transfers/fx"
+	"internal-transfers/holds"
+	"internal-transfers/memory"
+	"internal-transfers/middleware"
 	"internal-transfers/models"
+	"internal-transfers/preauth"
+	"internal-transfers/pubsub"
+	"internal-transfers/reconcile"
+	"internal-transfers/retention"
+	"internal-transfers/rules"
+	"internal-transfers/settlement"
+	"internal-transfers/signing"
+	"internal-transfers/sla"
+	"internal-transfers/usage"
+	"internal-transfers/validation"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"sync"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	"github.com/shopspring/decimal"
 )
 
@@ -33,20 +62,58 @@ func NewMockAccountRepository() *MockAccountRepository {
 	}
 }
 
-func (m *MockAccountRepository) CreateAccount(accountID int64, initialBalance decimal.Decimal) error {
+func (m *MockAccountRepository) CreateAccount(account models.NewAccount) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	accountID := account.AccountID
 	if _, exists := m.accounts[accountID]; exists {
 		return fmt.Errorf("account already exists") // Return error for duplicates
 	}
+	if m.externalIDTaken(account.ExternalID, accountID) {
+		return fmt.Errorf("external ID already exists")
+	}
+	for _, existing := range m.accounts {
+		if account.CreationToken != "" && existing.CreationToken == account.CreationToken {
+			return fmt.Errorf("creation token already used")
+		}
+	}
 	m.accounts[accountID] = &models.Account{
-		AccountID: accountID,
-		Balance:   initialBalance,
+		AccountID:      accountID,
+		Balance:        account.InitialBalance,
+		InitialBalance: account.InitialBalance,
+		Currency:       account.Currency,
+		ExternalID:     account.ExternalID,
+		CreationToken:  account.CreationToken,
+		Status:         models.AccountActive,
+		Version:        1,
+		CreatedAt:      time.Now().UTC(),
 	}
+	m.accounts[accountID].UpdatedAt = m.accounts[accountID].CreatedAt
 	return nil
 }
 
+func (m *MockAccountRepository) GetAccountByExternalID(externalID string) (*models.Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, account := range m.accounts {
+		if account.ExternalID == externalID {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("account not found")
+}
+
+func (m *MockAccountRepository) externalIDTaken(externalID string, accountID int64) bool {
+	for _, account := range m.accounts {
+		if externalID != "" && account.ExternalID == externalID && account.AccountID != accountID {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *MockAccountRepository) GetAccount(accountID int64) (*models.Account, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -65,9 +132,92 @@ func (m *MockAccountRepository) AccountExists(accountID int64) (bool, error) {
 	return exists, nil
 }
 
+func (m *MockAccountRepository) SetAccountStatus(accountID int64, status string) (*models.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, exists := m.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	account.Status = status
+	return account, nil
+}
+
+func (m *MockAccountRepository) SetOverdraftLimit(accountID int64, limit decimal.Decimal) (*models.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, exists := m.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	if account.Balance.LessThan(limit.Neg()) {
+		return nil, fmt.Errorf("balance below overdraft limit")
+	}
+	account.OverdraftLimit = limit
+	return account, nil
+}
+
+func (m *MockAccountRepository) UpdateAccount(accountID int64, update models.AccountUpdate) (*models.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, exists := m.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	if update.ExpectedVersion != 0 && update.ExpectedVersion != account.Version {
+		return nil, fmt.Errorf("account version mismatch")
+	}
+	if update.ExternalID != nil && m.externalIDTaken(*update.ExternalID, accountID) {
+		return nil, fmt.Errorf("external ID already exists")
+	}
+	if account.Metadata == nil {
+		account.Metadata = models.Metadata{}
+	}
+	for _, key := range update.RemoveMetadata {
+		delete(account.Metadata, key)
+	}
+	for key, value := range update.SetMetadata {
+		account.Metadata[key] = value
+	}
+	if update.Tags != nil {
+		account.Tags = update.Tags
+	}
+	if update.ExternalID != nil {
+		account.ExternalID = *update.ExternalID
+	}
+	account.Version++
+	account.UpdatedAt = time.Now().UTC()
+	return account, nil
+}
+
+func (m *MockAccountRepository) ListAccounts(filter models.AccountFilter, limit, offset int) ([]models.Account, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	accounts := []models.Account{}
+	for _, account := range m.accounts {
+		if filter.Matches(*account) {
+			accounts = append(accounts, *account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].AccountID < accounts[j].AccountID })
+	total := len(accounts)
+	accounts = accounts[sort.Search(total, func(i int) bool { return accounts[i].AccountID > filter.AfterID }):]
+	accounts = accounts[min(offset, len(accounts)):]
+	if len(accounts) > limit {
+		accounts = accounts[:limit]
+	}
+	return accounts, total, nil
+}
+
 // MockTransactionRepository implements TransactionRepository interface for testing
 type MockTransactionRepository struct {
-	accountRepo *MockAccountRepository
+	accountRepo  *MockAccountRepository
+	nextID       int64
+	transactions []models.Transaction
 }
 
 func NewMockTransactionRepository(accountRepo *MockAccountRepository) *MockTransactionRepository {
@@ -76,29 +226,150 @@ func NewMockTransactionRepository(accountRepo *MockAccountRepository) *MockTrans
 	}
 }
 
-func (m *MockTransactionRepository) CreateTransaction(sourceAccountID, destinationAccountID int64, amount decimal.Decimal) error {
+func (m *MockTransactionRepository) CreateTransaction(transfer models.Transfer) (*models.Transaction, error) {
+	transfer = transfer.WithDefaults(time.Now())
+	sourceAccountID, destinationAccountID, amount := transfer.SourceAccountID, transfer.DestinationAccountID, transfer.Amount
+
 	m.accountRepo.mu.Lock()
 	defer m.accountRepo.mu.Unlock()
 
 	sourceAccount, exists := m.accountRepo.accounts[sourceAccountID]
 	if !exists {
-		return fmt.Errorf("source account not found")
+		return nil, fmt.Errorf("source account not found")
 	}
 
-	_, exists = m.accountRepo.accounts[destinationAccountID]
+	destinationAccount, exists := m.accountRepo.accounts[destinationAccountID]
 	if !exists {
-		return fmt.Errorf("destination account not found")
+		return nil, fmt.Errorf("destination account not found")
+	}
+
+	if sourceAccount.Status == models.AccountFrozen {
+		return nil, fmt.Errorf("source account frozen")
+	}
+	if destinationAccount.Status == models.AccountFrozen {
+		return nil, fmt.Errorf("destination account frozen")
+	}
+	if err := transfer.CheckCurrencies(sourceAccount.Currency, destinationAccount.Currency); err != nil {
+		return nil, err
 	}
 
-	if sourceAccount.Balance.LessThan(amount) {
-		return fmt.Errorf("insufficient balance")
+	if sourceAccount.Available().LessThan(amount) {
+		return nil, fmt.Errorf("insufficient balance")
 	}
 
-	// Update balances
+	// Update balances and sequence numbers
 	sourceAccount.Balance = sourceAccount.Balance.Sub(amount)
-	m.accountRepo.accounts[destinationAccountID].Balance = m.accountRepo.accounts[destinationAccountID].Balance.Add(amount)
+	sourceAccount.Sequence++
+	destinationAccount.Balance = destinationAccount.Balance.Add(transfer.Credit())
+	destinationAccount.Sequence++
+	sourceAccount.UpdatedAt, destinationAccount.UpdatedAt = time.Now().UTC(), time.Now().UTC()
+	m.nextID++
+
+	transaction := models.Transaction{
+		ID:                   m.nextID,
+		SourceAccountID:      sourceAccountID,
+		DestinationAccountID: destinationAccountID,
+		Amount:               amount,
+		SourceSequence:       sourceAccount.Sequence,
+		DestinationSequence:  destinationAccount.Sequence,
+		RoundingPolicy:       transfer.RoundingPolicy,
+		TransferType:         transfer.TransferType,
+		ValueDate:            transfer.ValueDate,
+		Status:               models.TransactionCompleted,
+		DestinationAmount:    transfer.Credit(),
+		FXRate:               transfer.FXRate,
+		FXRateTimestamp:      transfer.FXRateTimestamp,
+		CreatedAt:            time.Now(),
+		EffectiveAt:          transfer.EffectiveAt,
+		Reference:            transfer.Reference,
+		Memo:                 transfer.Memo,
+		DependsOn:            transfer.DependsOn,
+	}
+	if transaction.EffectiveAt.IsZero() {
+		transaction.EffectiveAt = transaction.CreatedAt
+	}
+	m.transactions = append(m.transactions, transaction)
+	return &transaction, nil
+}
 
-	return nil
+func (m *MockTransactionRepository) ListTransactions(accountID int64, limit int) ([]models.Transaction, error) {
+	m.accountRepo.mu.RLock()
+	defer m.accountRepo.mu.RUnlock()
+
+	transactions := []models.Transaction{}
+	for i := len(m.transactions) - 1; i >= 0 && len(transactions) < limit; i-- {
+		t := m.transactions[i]
+		if t.SourceAccountID == accountID || t.DestinationAccountID == accountID {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+func (m *MockTransactionRepository) SearchTransactions(filter models.TransactionFilter, limit int) ([]models.Transaction, error) {
+	m.accountRepo.mu.RLock()
+	defer m.accountRepo.mu.RUnlock()
+
+	transactions := []models.Transaction{}
+	for _, t := range m.transactions {
+		if filter.Matches(t) && filter.ListedAfter(t.Position(), filter.After) {
+			transactions = append(transactions, t)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return filter.ListedAfter(transactions[j].Position(), transactions[i].Position())
+	})
+	return transactions[:min(limit, len(transactions))], nil
+}
+
+func (m *MockTransactionRepository) SearchAllTransactions(search models.TransactionSearch, limit int) ([]models.Transaction, error) {
+	m.accountRepo.mu.RLock()
+	defer m.accountRepo.mu.RUnlock()
+
+	transactions := []models.Transaction{}
+	for _, t := range m.transactions {
+		if search.Matches(t) && t.Position().Follows(search.After) {
+			transactions = append(transactions, t)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[j].Position().Follows(transactions[i].Position())
+	})
+	return transactions[:min(limit, len(transactions))], nil
+}
+
+func (m *MockTransactionRepository) ListChanges(accountID, sinceSeq int64, limit int) ([]models.Transaction, error) {
+	m.accountRepo.mu.RLock()
+	defer m.accountRepo.mu.RUnlock()
+
+	transactions := []models.Transaction{}
+	for _, t := range m.transactions {
+		if len(transactions) == limit {
+			break
+		}
+		if (t.SourceAccountID == accountID && t.SourceSequence > sinceSeq) ||
+			(t.DestinationAccountID == accountID && t.DestinationSequence > sinceSeq) {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+func (m *MockTransactionRepository) BalanceAsOf(accountID int64, recordedAt, effectiveAt time.Time) (decimal.Decimal, error) {
+	m.accountRepo.mu.RLock()
+	defer m.accountRepo.mu.RUnlock()
+
+	account, exists := m.accountRepo.accounts[accountID]
+	if !exists || account.CreatedAt.After(recordedAt) {
+		return decimal.Zero, fmt.Errorf("account not found")
+	}
+	balance := account.Balance
+	for _, t := range m.transactions {
+		if (t.SourceAccountID == accountID || t.DestinationAccountID == accountID) && !t.Counts(recordedAt, effectiveAt) {
+			balance = balance.Sub(t.Movement(accountID))
+		}
+	}
+	return balance, nil
 }
 
 // MockHandler creates a handler with mock repositories for testing
@@ -106,10 +377,7 @@ func NewMockHandler() *Handler {
 	accountRepo := NewMockAccountRepository()
 	transactionRepo := NewMockTransactionRepository(accountRepo)
 
-	return &Handler{
-		accountRepo:     accountRepo,
-		transactionRepo: transactionRepo,
-	}
+	return NewHandlerWithRepositories(accountRepo, transactionRepo)
 }
 
 // =============================================================================
@@ -272,6 +540,29 @@ func TestCreateAccountHandler_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestCreateTransaction_FieldErrors(t *testing.T) {
+	handler := NewMockHandler()
+	body := `{"source_account_id": 0, "destination_account_id": 5, "amount": "ten", "memo": "` + strings.Repeat("m", 501) + `"}`
+	rr := httptest.NewRecorder()
+	handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", strings.NewReader(body)))
+
+	var response models.ValidationErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil || rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected a 400 JSON body, got %d (%v)", rr.Code, err)
+	}
+	want := validation.Errors{
+		{Field: "source_account_id", Message: "Source account ID must be positive"},
+		{Field: "amount", Message: "Invalid amount format"},
+		{Field: "memo", Message: "Memo must not exceed 500 characters"},
+	}
+	if !reflect.DeepEqual(response.Fields, want) || response.Error != want.Error() {
+		t.Errorf("Expected %v, got %+v", want, response)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON content type, got %q", ct)
+	}
+}
+
 func TestCreateAccountHandler_NegativeBalance(t *testing.T) {
 	handler := NewMockHandler()
 
@@ -482,6 +773,21 @@ func TestCreateAccount_LargePayload(t *testing.T) {
 	}
 }
 
+func TestCreateAccount_BodyTooLarge(t *testing.T) {
+	handler := NewMockHandler()
+
+	// The route's body limit wraps the body; the handler reports reads past it as 413
+	body := `{"account_id": 1, "initial_balance": "100", "external_id": "` + strings.Repeat("x", 100) + `"}`
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/accounts", strings.NewReader(body))
+	req.Body = http.MaxBytesReader(rr, req.Body, 64)
+	handler.CreateAccount(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rr.Code)
+	}
+}
+
 // =============================================================================
 // Get Account Handler Tests
 // =============================================================================
@@ -496,7 +802,7 @@ func TestGetAccountHandler_Success(t *testing.T) {
 	handler := NewMockHandler()
 
 	// First create an account
-	handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(100.50))
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(100.50)})
 
 	req := httptest.NewRequest("GET", "/accounts/123", nil)
 	req = mux.SetURLVars(req, map[string]string{"account_id": "123"})
@@ -619,7 +925,7 @@ func TestGetAccount_EdgeCases(t *testing.T) {
 			handler := NewMockHandler()
 
 			if tt.setupAccount {
-				handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(100.0))
+				handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(100.0)})
 			}
 
 			req := httptest.NewRequest("GET", "/accounts/"+tt.accountID, nil)
@@ -640,7 +946,7 @@ func TestGetAccount_ResponseFormat(t *testing.T) {
 	handler := NewMockHandler()
 
 	// Create account with specific balance
-	handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(100.12345))
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(100.12345)})
 
 	req := httptest.NewRequest("GET", "/accounts/123", nil)
 	req = mux.SetURLVars(req, map[string]string{"account_id": "123"})
@@ -694,8 +1000,8 @@ func TestCreateTransactionHandler_Success(t *testing.T) {
 	handler := NewMockHandler()
 
 	// Create source and destination accounts
-	handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(1000.00))
-	handler.accountRepo.CreateAccount(456, decimal.NewFromFloat(500.00))
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.00)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(500.00)})
 
 	reqBody := models.CreateTransactionRequest{
 		SourceAccountID:      123,
@@ -734,8 +1040,8 @@ func TestCreateTransactionHandler_InsufficientBalance(t *testing.T) {
 	handler := NewMockHandler()
 
 	// Create accounts with insufficient balance
-	handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(50.00))
-	handler.accountRepo.CreateAccount(456, decimal.NewFromFloat(500.00))
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(50.00)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(500.00)})
 
 	reqBody := models.CreateTransactionRequest{
 		SourceAccountID:      123,
@@ -755,6 +1061,35 @@ func TestCreateTransactionHandler_InsufficientBalance(t *testing.T) {
 	}
 }
 
+// conflictingTransactionRepository fails every transfer as if it kept conflicting with concurrent
+// transfers after the storage's retries
+type conflictingTransactionRepository struct {
+	*MockTransactionRepository
+}
+
+func (conflictingTransactionRepository) CreateTransaction(models.Transfer) (*models.Transaction, error) {
+	return nil, errors.New("transaction conflict")
+}
+
+func TestCreateTransactionHandler_Conflict(t *testing.T) {
+	accountRepo := NewMockAccountRepository()
+	accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(500.00)})
+	accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(500.00)})
+	handler := NewHandlerWithRepositories(accountRepo, conflictingTransactionRepository{NewMockTransactionRepository(accountRepo)})
+
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{"source_account_id": 123, "destination_account_id": 456, "amount": "10.00"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.CreateTransaction(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+}
+
 func TestCreateTransactionHandler_SameAccount(t *testing.T) {
 	handler := NewMockHandler()
 
@@ -930,8 +1265,8 @@ func TestCreateTransaction_EdgeCases(t *testing.T) {
 			handler := NewMockHandler()
 
 			if tt.setupAccounts {
-				handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(1000.0))
-				handler.accountRepo.CreateAccount(456, decimal.NewFromFloat(500.0))
+				handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.0)})
+				handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(500.0)})
 			}
 
 			jsonBody, _ := json.Marshal(tt.requestBody)
@@ -953,8 +1288,8 @@ func TestCreateTransaction_ResponseFormat(t *testing.T) {
 	handler := NewMockHandler()
 
 	// Create accounts
-	handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(1000.0))
-	handler.accountRepo.CreateAccount(456, decimal.NewFromFloat(500.0))
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.0)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(500.0)})
 
 	reqBody := models.CreateTransactionRequest{
 		SourceAccountID:      123,
@@ -973,497 +1308,3355 @@ func TestCreateTransaction_ResponseFormat(t *testing.T) {
 		t.Errorf("Expected status 201, got %d", rr.Code)
 	}
 
-	// Check that a response was generated (may be empty for 201 Created)
-	body := rr.Body.String()
-	t.Logf("Response body: %s", body)
-
-	// 201 Created responses often have empty bodies, which is valid
-	// The important thing is that the status code is correct
-}
-
-func TestFullTransactionFlow(t *testing.T) {
-	_ = httptest.NewRecorder()
-	// Integration test would verify complete transaction flow
-	t.Log("Integration test would verify complete transaction flow")
+	var response models.TransactionResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.ID == 0 {
+		t.Error("Expected transaction ID in response")
+	}
+	if response.Amount != "100.5" {
+		t.Errorf("Expected amount 100.5, got %s", response.Amount)
+	}
+	if response.SourceSequence != 1 || response.DestinationSequence != 1 {
+		t.Errorf("Expected first sequence numbers 1/1, got %d/%d", response.SourceSequence, response.DestinationSequence)
+	}
+	if response.Status != models.TransactionCompleted {
+		t.Errorf("Expected status completed, got %q", response.Status)
+	}
 }
 
-// =============================================================================
-// Content Type and Misc Tests
-// =============================================================================
-
-func TestHandlers_ContentTypeValidation(t *testing.T) {
+func TestCreateTransaction_SequenceNumbers(t *testing.T) {
 	handler := NewMockHandler()
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.0)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(500.0)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 789, InitialBalance: decimal.NewFromFloat(0)})
 
-	tests := []struct {
-		name        string
-		endpoint    string
-		method      string
-		contentType string
-		body        string
+	transfers := []struct {
+		source, destination        int64
+		wantSourceSeq, wantDestSeq int64
 	}{
-		{
-			name:        "CreateAccount without content-type",
-			endpoint:    "/accounts",
-			method:      "POST",
-			contentType: "",
-			body:        `{"account_id": 123, "initial_balance": "100.00"}`,
-		},
-		{
-			name:        "CreateAccount with wrong content-type",
-			endpoint:    "/accounts",
-			method:      "POST",
-			contentType: "text/plain",
-			body:        `{"account_id": 123, "initial_balance": "100.00"}`,
-		},
-		{
-			name:        "CreateTransaction without content-type",
-			endpoint:    "/transactions",
-			method:      "POST",
-			contentType: "",
-			body:        `{"source_account_id": 123, "destination_account_id": 456, "amount": "100.00"}`,
-		},
+		{123, 456, 1, 1},
+		{456, 789, 2, 1},
+		{123, 789, 2, 2},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(tt.method, tt.endpoint, strings.NewReader(tt.body))
-			if tt.contentType != "" {
-				req.Header.Set("Content-Type", tt.contentType)
-			}
+	for _, tr := range transfers {
+		body, _ := json.Marshal(models.CreateTransactionRequest{
+			SourceAccountID:      tr.source,
+			DestinationAccountID: tr.destination,
+			Amount:               "10",
+		})
+		req := httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		handler.CreateTransaction(rr, req)
 
+		var response models.TransactionResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.SourceSequence != tr.wantSourceSeq || response.DestinationSequence != tr.wantDestSeq {
+			t.Errorf("Transfer %d->%d: expected sequences %d/%d, got %d/%d", tr.source, tr.destination,
+				tr.wantSourceSeq, tr.wantDestSeq, response.SourceSequence, response.DestinationSequence)
+		}
+	}
+
+	// The account read exposes the latest assigned sequence
+	req := httptest.NewRequest("GET", "/accounts/789", nil)
+	req = mux.SetURLVars(req, map[string]string{"account_id": "789"})
+	rr := httptest.NewRecorder()
+	handler.GetAccount(rr, req)
+
+	var account models.AccountResponse
+	if err := json.NewDecoder(rr.Body).Decode(&account); err != nil {
+		t.Fatalf("Failed to decode account: %v", err)
+	}
+	if account.Sequence != 2 {
+		t.Errorf("Expected account 789 sequence 2, got %d", account.Sequence)
+	}
+}
+
+func TestCreateTransaction_RoundingPolicy(t *testing.T) {
+	testCases := []struct {
+		policy     models.RoundingPolicy
+		amount     string
+		wantStatus int
+		wantAmount string
+	}{
+		{models.RoundHalfEven, "10.000025", http.StatusCreated, "10.00002"},
+		{models.RoundHalfUp, "10.000025", http.StatusCreated, "10.00003"},
+		{models.RoundTruncate, "10.000029", http.StatusCreated, "10.00002"},
+		{models.RoundTruncate, "0.000009", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.policy)+"/"+tc.amount, func(t *testing.T) {
+			handler := NewMockHandler().WithRoundingPolicy(tc.policy)
+			handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.0)})
+			handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.Zero})
+
+			body, _ := json.Marshal(models.CreateTransactionRequest{
+				SourceAccountID:      123,
+				DestinationAccountID: 456,
+				Amount:               tc.amount,
+			})
 			rr := httptest.NewRecorder()
+			handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
 
-			if tt.endpoint == "/accounts" {
-				handler.CreateAccount(rr, req)
-			} else if tt.endpoint == "/transactions" {
-				handler.CreateTransaction(rr, req)
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d (%s)", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantStatus != http.StatusCreated {
+				return
 			}
 
-			// Most should result in bad request due to JSON parsing issues
-			if rr.Code != http.StatusBadRequest && rr.Code != http.StatusCreated {
-				t.Logf("Status %d for %s (this tests error handling paths)",
-					rr.Code, tt.name)
+			var response models.TransactionResponse
+			json.NewDecoder(rr.Body).Decode(&response)
+			if response.Amount != tc.wantAmount || response.RoundingPolicy != string(tc.policy) {
+				t.Errorf("Expected %s rounded with %s, got %s with %s", tc.wantAmount, tc.policy, response.Amount, response.RoundingPolicy)
+			}
+
+			// Balances move by exactly the rounded amount
+			destination, _ := handler.accountRepo.GetAccount(456)
+			if destination.Balance.String() != tc.wantAmount {
+				t.Errorf("Expected destination balance %s, got %s", tc.wantAmount, destination.Balance)
 			}
 		})
 	}
 }
 
-// =============================================================================
-// Health Check Tests
-// =============================================================================
-
-func TestHealthCheck(t *testing.T) {
-	_ = httptest.NewRecorder()
-	t.Log("Health check test placeholder")
-}
+func TestCreateTransaction_TransferType(t *testing.T) {
+	schedule, err := cutoff.Parse("wire=00:00", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func TestHealthCheck_Detailed(t *testing.T) {
-	handler := &Handler{}
+	testCases := []struct {
+		transferType string
+		wantStatus   int
+		wantType     string
+	}{
+		{"", http.StatusCreated, models.DefaultTransferType},
+		{"wire", http.StatusCreated, "wire"},
+		{"swift", http.StatusBadRequest, ""},
+	}
 
-	req := httptest.NewRequest("GET", "/health", nil)
-	rr := httptest.NewRecorder()
+	for _, tc := range testCases {
+		t.Run("type="+tc.transferType, func(t *testing.T) {
+			handler := NewMockHandler().WithCutoffSchedule(schedule)
+			handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.0)})
+			handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.Zero})
+
+			body, _ := json.Marshal(models.CreateTransactionRequest{
+				SourceAccountID:      123,
+				DestinationAccountID: 456,
+				Amount:               "10.00",
+				TransferType:         tc.transferType,
+			})
+			rr := httptest.NewRecorder()
+			handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
 
-	handler.HealthCheck(rr, req)
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d (%s)", tc.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tc.wantStatus != http.StatusCreated {
+				return
+			}
 
-	// Test status code
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", rr.Code)
+			var response models.TransactionResponse
+			json.NewDecoder(rr.Body).Decode(&response)
+			if response.TransferType != tc.wantType {
+				t.Errorf("Expected transfer type %s, got %s", tc.wantType, response.TransferType)
+			}
+			// A 00:00 cut-off has always passed, so wire transfers are value-dated after today
+			wantDate := schedule.ValueDate(tc.wantType, time.Now())
+			if response.ValueDate != cutoff.FormatDate(wantDate) {
+				t.Errorf("Expected value date %s, got %s", cutoff.FormatDate(wantDate), response.ValueDate)
+			}
+			today := time.Now().UTC().Format("2006-01-02")
+			if tc.wantType == "wire" && response.ValueDate <= today {
+				t.Errorf("Expected wire value date after %s, got %s", today, response.ValueDate)
+			}
+		})
 	}
+}
 
-	// Test content type
-	expectedContentType := "application/json"
-	if contentType := rr.Header().Get("Content-Type"); contentType != expectedContentType {
-		t.Errorf("Expected Content-Type %s, got %s", expectedContentType, contentType)
+func TestListSettlementFiles(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NewMockHandler().ListSettlementFiles(rr, httptest.NewRequest("GET", "/settlements/files", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without settlement storage, got %d", rr.Code)
 	}
 
-	// Test response body
-	var response map[string]string
-	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
-		t.Errorf("Failed to decode response: %v", err)
+	repo := memory.NewSettlementRepository(memory.NewStore())
+	date := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	repo.SaveSettlementFile(context.Background(), models.SettlementFile{
+		PartnerID: "acme", BusinessDate: date, FileName: "acme_20240131.csv", Format: "csv", Status: models.SettlementFileGenerated, TransferCount: 3,
+	})
+	repo.SaveSettlementFile(context.Background(), models.SettlementFile{
+		PartnerID: "globex", BusinessDate: date, FileName: "globex_20240131.csv", Format: "csv", Status: models.SettlementFileFailed, Error: "upload failed",
+	})
+	handler := NewMockHandler().WithSettlements(repo)
+
+	testCases := []struct {
+		query      string
+		wantStatus int
+		wantFiles  int
+	}{
+		{"", http.StatusOK, 2},
+		{"?date=2024-01-31&partner_id=acme", http.StatusOK, 1},
+		{"?date=2024-02-01", http.StatusOK, 0},
+		{"?date=31/01/2024", http.StatusBadRequest, 0},
 	}
 
-	if response["status"] != "healthy" {
-		t.Errorf("Expected status 'healthy', got %s", response["status"])
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ListSettlementFiles(rr, httptest.NewRequest("GET", "/settlements/files"+tc.query, nil))
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tc.wantStatus, rr.Code)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response models.SettlementFileListResponse
+			json.NewDecoder(rr.Body).Decode(&response)
+			if len(response.Files) != tc.wantFiles {
+				t.Fatalf("Expected %d files, got %+v", tc.wantFiles, response.Files)
+			}
+			if tc.wantFiles > 0 && (response.Files[0].BusinessDate != "2024-01-31" || response.Files[0].PartnerID != "acme") {
+				t.Errorf("Unexpected first file %+v", response.Files[0])
+			}
+		})
 	}
 }
 
-func TestHealthCheck_WithNilHandler(t *testing.T) {
-	handler := &Handler{}
-
-	req := httptest.NewRequest("GET", "/health", nil)
+func TestIngestSettlementAck(t *testing.T) {
 	rr := httptest.NewRecorder()
+	NewMockHandler().IngestSettlementAck(rr, httptest.NewRequest("POST", "/settlements/acks?partner_id=acme", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without configured partners, got %d", rr.Code)
+	}
 
-	// This should still work even with nil repositories in handler
-	handler.HealthCheck(rr, req)
+	store := memory.NewStore()
+	repo := memory.NewSettlementRepository(store)
+	partners := []settlement.Partner{{ID: "acme", Accounts: []int64{1}, Layout: settlement.Layout{Format: settlement.FormatCSV}}}
+	handler := NewMockHandler().WithSettlementIngester(settlement.NewIngester(partners, repo, nil))
+
+	rr = httptest.NewRecorder()
+	handler.IngestSettlementAck(rr, httptest.NewRequest("POST", "/settlements/acks?partner_id=globex", strings.NewReader("1,settled\n")))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown partner, got %d", rr.Code)
+	}
 
+	rr = httptest.NewRecorder()
+	handler.IngestSettlementAck(rr, httptest.NewRequest("POST", "/settlements/acks?partner_id=acme", strings.NewReader("1,settled\n")))
 	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", rr.Code)
+		t.Fatalf("Expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var report settlement.AckReport
+	json.NewDecoder(rr.Body).Decode(&report)
+	if report.Lines != 1 || len(report.Unmatched) != 1 || report.Unmatched[0].Reason != "transaction not found" {
+		t.Errorf("Unexpected report %+v", report)
 	}
 }
 
-func TestHealthCheck_InvalidMethod(t *testing.T) {
-	handler := &Handler{}
+func TestFreezeAccount(t *testing.T) {
+	handler := NewMockHandler()
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/accounts/{account_id}/freeze", handler.FreezeAccount).Methods("POST")
+	router.HandleFunc("/admin/accounts/{account_id}/unfreeze", handler.UnfreezeAccount).Methods("POST")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100)})
+
+	post := func(path string, body []byte) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", path, bytes.NewBuffer(body)))
+		return rr
+	}
+	transfer := func(source, destination int64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: source, DestinationAccountID: destination, Amount: "1"})
+		return post("/transactions", body)
+	}
 
-	req := httptest.NewRequest("POST", "/health", nil)
-	rr := httptest.NewRecorder()
+	rr := post("/admin/accounts/1/freeze", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	var account models.AccountResponse
+	json.NewDecoder(rr.Body).Decode(&account)
+	if account.Status != models.AccountFrozen {
+		t.Errorf("Expected frozen status, got %+v", account)
+	}
 
-	handler.HealthCheck(rr, req)
+	// Frozen accounts can neither send nor receive
+	if rr := transfer(1, 2); rr.Code != http.StatusLocked || !strings.Contains(rr.Body.String(), "Source account is frozen") {
+		t.Errorf("Expected 423 for frozen source, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := transfer(2, 1); rr.Code != http.StatusLocked || !strings.Contains(rr.Body.String(), "Destination account is frozen") {
+		t.Errorf("Expected 423 for frozen destination, got %d (%s)", rr.Code, rr.Body.String())
+	}
 
-	// Health check should still respond regardless of method
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", rr.Code)
+	if rr := post("/admin/accounts/1/unfreeze", nil); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	if rr := transfer(1, 2); rr.Code != http.StatusCreated {
+		t.Errorf("Expected transfer after unfreeze to succeed, got %d", rr.Code)
+	}
+
+	if rr := post("/admin/accounts/99/freeze", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown account, got %d", rr.Code)
+	}
+	if rr := post("/admin/accounts/abc/freeze", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid account ID, got %d", rr.Code)
 	}
 }
 
-func TestHealthCheck_Multiple(t *testing.T) {
-	handler := &Handler{}
+func TestUpdateAccount(t *testing.T) {
+	handler := NewMockHandler()
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts", handler.ListAccounts).Methods("GET")
+	router.HandleFunc("/accounts/{account_id}", handler.UpdateAccount).Methods("PATCH")
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100)})
 
-	// Test multiple calls to ensure consistency
-	for i := 0; i < 5; i++ {
-		req := httptest.NewRequest("GET", "/health", nil)
+	patch := func(path, body string) *httptest.ResponseRecorder {
 		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("PATCH", path, strings.NewReader(body)))
+		return rr
+	}
 
-		handler.HealthCheck(rr, req)
+	rr := patch("/accounts/1", `{"metadata": {"name": "Payroll", "cost_center": "CC-42"}, "tags": ["payroll"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr = patch("/accounts/1", `{"metadata": {"name": null}}`)
+	var account models.AccountResponse
+	json.NewDecoder(rr.Body).Decode(&account)
+	if len(account.Metadata) != 1 || account.Metadata["cost_center"] != "CC-42" || len(account.Tags) != 1 || account.Balance != "100" {
+		t.Errorf("Unexpected account after update %+v", account)
+	}
 
-		if rr.Code != http.StatusOK {
-			t.Errorf("Health check call %d failed with status %d", i+1, rr.Code)
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/accounts/1", `{"tags": ["not valid"]}`, http.StatusBadRequest},
+		{"/accounts/1", `{"status": "frozen"}`, http.StatusBadRequest},
+		{"/accounts/1", `{}`, http.StatusBadRequest},
+		{"/accounts/1", `{"balance": "5"`, http.StatusBadRequest},
+		{"/accounts/99", `{"tags": []}`, http.StatusNotFound},
+		{"/accounts/abc", `{"tags": []}`, http.StatusBadRequest},
+	} {
+		if rr := patch(tc.path, tc.body); rr.Code != tc.want {
+			t.Errorf("PATCH %s %s: expected %d, got %d", tc.path, tc.body, tc.want, rr.Code)
 		}
 	}
-}
 
-// =============================================================================
-// Error Handling and Edge Cases
-// =============================================================================
+	// Optimistic concurrency through the ETag, or the version ETag of earlier releases
+	etag := rr.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || account.Version != 3 {
+		t.Fatalf("Expected a strong ETag after two updates, got %s (version %d)", etag, account.Version)
+	}
+	conditional := func(ifMatch string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("PATCH", "/accounts/1", strings.NewReader(`{"tags": ["eu"]}`))
+		req.Header.Set("If-Match", ifMatch)
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	// If-Match compares strongly: the same tag marked weak does not match
+	if code := conditional("W/" + etag); code != http.StatusPreconditionFailed {
+		t.Errorf("Expected weak If-Match to fail, got %d", code)
+	}
+	if code := conditional(etag); code != http.StatusOK {
+		t.Errorf("Expected matching If-Match to succeed, got %d", code)
+	}
+	if code := conditional(`"0", "4"`); code != http.StatusOK {
+		t.Errorf("Expected If-Match listing the version to succeed, got %d", code)
+	}
+	for _, stale := range []string{etag, `"4"`, `W/"5"`, "garbage"} {
+		if code := conditional(stale); code != http.StatusPreconditionFailed {
+			t.Errorf("If-Match %s: expected 412, got %d", stale, code)
+		}
+	}
+	if code := conditional("*"); code != http.StatusOK {
+		t.Errorf("Expected If-Match * to succeed, got %d", code)
+	}
 
-func TestHandler_ErrorPaths(t *testing.T) {
-	// Test various error conditions to improve coverage
-	handler := NewMockHandler()
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts?tag=payroll", nil))
+	var list models.AccountListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || len(list.Accounts) != 0 {
+		t.Errorf("Expected no account tagged payroll after retagging, got %d %+v", rr.Code, list)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts?tag=eu", nil))
+	json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || len(list.Accounts) != 1 || list.Accounts[0].AccountID != 1 {
+		t.Errorf("Expected account 1 tagged eu, got %d %+v", rr.Code, list)
+	}
 
-	t.Run("CreateAccount with malformed JSON", func(t *testing.T) {
-		req := httptest.NewRequest("POST", "/accounts", strings.NewReader(`{"account_id": 123, "initial_balance": "100.00"`))
-		req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts", nil))
+	list = models.AccountListResponse{}
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Accounts) != 2 || list.Accounts[1].Tags == nil || list.Accounts[1].Metadata == nil {
+		t.Errorf("Expected both accounts with empty metadata and tags, got %+v", list)
+	}
+}
 
+func TestGetAccount_ConditionalRequests(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store)
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}", handler.GetAccount).Methods("GET")
+	router.HandleFunc("/accounts/{account_id}", handler.UpdateAccount).Methods("PATCH")
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
 		rr := httptest.NewRecorder()
-		handler.CreateAccount(rr, req)
+		req := httptest.NewRequest("GET", "/accounts/1", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		router.ServeHTTP(rr, req)
+		return rr
+	}
 
-		if rr.Code != http.StatusBadRequest {
-			t.Errorf("Expected status 400 for malformed JSON, got %d", rr.Code)
+	rr := get("")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("Expected 200 with a strong ETag, got %d %q", rr.Code, etag)
+	}
+	if vary := rr.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept" {
+		t.Errorf("Expected the 200 to vary by Accept once, got %q", vary)
+	}
+	// If-None-Match compares weakly: the same tag marked weak matches
+	for _, header := range []string{etag, "W/" + etag, `W/"other", ` + etag, "*"} {
+		if rr := get(header); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 || rr.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s: expected 304 without a body, got %d %q", header, rr.Code, rr.Body.String())
+		} else if vary := rr.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept" {
+			t.Errorf("If-None-Match %s: expected the 304 to vary by Accept once, got %q", header, vary)
 		}
-	})
+	}
+	if rr := get(`W/"other"`); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for another ETag, got %d", rr.Code)
+	}
 
-	t.Run("CreateTransaction with missing fields", func(t *testing.T) {
-		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{"source_account_id": 123}`))
-		req.Header.Set("Content-Type", "application/json")
+	// Each format is a representation of its own, with its own strong ETag
+	xmlGet := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/accounts/1", nil)
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(xmlGet, req)
+	if xmlGet.Code != http.StatusOK || xmlGet.Header().Get("ETag") == etag {
+		t.Errorf("Expected the XML representation with another ETag, got %d %q", xmlGet.Code, xmlGet.Header().Get("ETag"))
+	}
 
-		rr := httptest.NewRecorder()
-		handler.CreateTransaction(rr, req)
+	// Transfers leave the version alone but change the ETag
+	if _, err := store.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5)}); err != nil {
+		t.Fatal(err)
+	}
+	rr = get(etag)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Fatalf("Expected the account after a transfer, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
 
-		if rr.Code != http.StatusBadRequest {
-			t.Errorf("Expected status 400 for missing fields, got %d", rr.Code)
-		}
-	})
+	// If-Match takes the current ETag, so an update based on a read from before the transfer fails
+	patch := func(ifMatch string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("PATCH", "/accounts/1", strings.NewReader(`{"tags": ["eu"]}`))
+		req.Header.Set("If-Match", ifMatch)
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := patch(etag); code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for the ETag from before the transfer, got %d", code)
+	}
+	if code := patch(rr.Header().Get("ETag")); code != http.StatusOK {
+		t.Errorf("Expected the current ETag to match, got %d", code)
+	}
 }
 
-// =============================================================================
-// Additional Edge Case Tests for Maximum Coverage
-// =============================================================================
-
-func TestCreateAccount_AdditionalEdgeCases(t *testing.T) {
+func TestAccountExternalID(t *testing.T) {
 	handler := NewMockHandler()
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts", handler.CreateAccount).Methods("POST")
+	router.HandleFunc("/accounts/by-external-id/{external_id}", handler.GetAccountByExternalID).Methods("GET")
+	router.HandleFunc("/accounts/{account_id}", handler.UpdateAccount).Methods("PATCH")
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
 
-	t.Run("Invalid account ID in URL", func(t *testing.T) {
-		reqBody := models.CreateAccountRequest{
-			AccountID:      123,
-			InitialBalance: "100.50",
-		}
+	if rr := serve("POST", "/accounts", `{"account_id": 1, "initial_balance": "100", "external_id": "ERP-4711"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr := serve("GET", "/accounts/by-external-id/ERP-4711", "")
+	var account models.AccountResponse
+	json.NewDecoder(rr.Body).Decode(&account)
+	if rr.Code != http.StatusOK || account.AccountID != 1 || account.ExternalID != "ERP-4711" || rr.Header().Get("ETag") == "" {
+		t.Errorf("Expected account 1, got %d %+v", rr.Code, account)
+	}
 
-		jsonBody, _ := json.Marshal(reqBody)
-		req := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
-		req.Header.Set("Content-Type", "application/json")
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/accounts", `{"account_id": 2, "initial_balance": "0", "external_id": "ERP-4711"}`, http.StatusConflict},
+		{"POST", "/accounts", `{"account_id": 2, "initial_balance": "0", "external_id": "ERP 4711"}`, http.StatusBadRequest},
+		{"POST", "/accounts", `{"account_id": 2, "initial_balance": "0"}`, http.StatusCreated},
+		{"PATCH", "/accounts/2", `{"external_id": "ERP-4711"}`, http.StatusConflict},
+		{"PATCH", "/accounts/2", `{"external_id": "ERP-0815"}`, http.StatusOK},
+		{"PATCH", "/accounts/1", `{"external_id": ""}`, http.StatusOK},
+		{"GET", "/accounts/by-external-id/ERP-4711", "", http.StatusNotFound},
+		{"GET", "/accounts/by-external-id/ERP-0815", "", http.StatusOK},
+	} {
+		if rr := serve(tc.method, tc.path, tc.body); rr.Code != tc.want {
+			t.Errorf("%s %s %s: expected %d, got %d (%s)", tc.method, tc.path, tc.body, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+}
 
+func TestCreateAccount_CreationToken(t *testing.T) {
+	handler := NewMockHandler()
+	serve := func(body string) *httptest.ResponseRecorder {
 		rr := httptest.NewRecorder()
-		handler.CreateAccount(rr, req)
-
-		// Should succeed since account ID is in body, not URL
-		if rr.Code != http.StatusCreated {
-			t.Errorf("Expected status 201, got %d", rr.Code)
-		}
-	})
+		handler.CreateAccount(rr, httptest.NewRequest("POST", "/accounts", strings.NewReader(body)))
+		return rr
+	}
 
-	t.Run("Duplicate account creation", func(t *testing.T) {
-		// Use a fresh handler to avoid conflicts with previous tests
-		freshHandler := NewMockHandler()
+	body := `{"account_id": 1, "initial_balance": "100", "currency": "USD", "creation_token": "provision-1"}`
+	if rr := serve(body); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	rr := serve(body)
+	var account models.AccountResponse
+	json.NewDecoder(rr.Body).Decode(&account)
+	if rr.Code != http.StatusOK || account.AccountID != 1 || rr.Header().Get("ETag") == "" {
+		t.Errorf("Expected the replay to return account 1, got %d %+v", rr.Code, account)
+	}
 
-		// Create account first time
-		reqBody := models.CreateAccountRequest{
-			AccountID:      12345, // Use unique ID
-			InitialBalance: "100.50",
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"account_id": 1, "initial_balance": "200", "currency": "USD", "creation_token": "provision-1"}`, http.StatusConflict},
+		{`{"account_id": 2, "initial_balance": "100", "currency": "USD", "creation_token": "provision-1"}`, http.StatusConflict},
+		{`{"account_id": 1, "initial_balance": "100", "currency": "USD"}`, http.StatusConflict},
+		{`{"account_id": 2, "initial_balance": "100", "creation_token": "provision 2"}`, http.StatusBadRequest},
+	} {
+		if rr := serve(tc.body); rr.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d (%s)", tc.body, tc.want, rr.Code, rr.Body.String())
 		}
+	}
+}
 
-		jsonBody, _ := json.Marshal(reqBody)
-		req := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
-		req.Header.Set("Content-Type", "application/json")
+func TestListAccounts(t *testing.T) {
+	handler := NewMockHandler()
+	for id := int64(1); id <= 5; id++ {
+		handler.accountRepo.CreateAccount(models.NewAccount{AccountID: id, InitialBalance: decimal.NewFromInt(id * 100)})
+	}
+	handler.accountRepo.SetAccountStatus(2, models.AccountFrozen)
+
+	testCases := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedIDs   []int64
+		expectedTotal string
+	}{
+		{"All", "", http.StatusOK, []int64{1, 2, 3, 4, 5}, "5"},
+		{"First page", "?limit=2", http.StatusOK, []int64{1, 2}, "5"},
+		{"Second page", "?limit=2&offset=2", http.StatusOK, []int64{3, 4}, "5"},
+		{"Past the end", "?offset=10", http.StatusOK, []int64{}, "5"},
+		{"Status", "?status=frozen", http.StatusOK, []int64{2}, "1"},
+		{"Balance range", "?min_balance=200&max_balance=400.00", http.StatusOK, []int64{2, 3, 4}, "3"},
+		{"Balance range page", "?min_balance=200&limit=1&offset=1", http.StatusOK, []int64{3}, "4"},
+		{"Created window", "?created_from=2000-01-01&created_before=2999-01-01T00:00:00Z", http.StatusOK, []int64{1, 2, 3, 4, 5}, "5"},
+		{"Created in the future", "?created_from=2999-01-01", http.StatusOK, []int64{}, "0"},
+		{"Invalid limit", "?limit=0", http.StatusBadRequest, nil, ""},
+		{"Limit too large", "?limit=1001", http.StatusBadRequest, nil, ""},
+		{"Negative offset", "?offset=-1", http.StatusBadRequest, nil, ""},
+		{"Invalid status", "?status=closed", http.StatusBadRequest, nil, ""},
+		{"Invalid balance", "?min_balance=abc", http.StatusBadRequest, nil, ""},
+		{"Inverted balance range", "?min_balance=5&max_balance=1", http.StatusBadRequest, nil, ""},
+		{"Invalid date", "?created_before=yesterday", http.StatusBadRequest, nil, ""},
+	}
 
-		rr := httptest.NewRecorder()
-		freshHandler.CreateAccount(rr, req)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ListAccounts(rr, httptest.NewRequest("GET", "/accounts"+tc.query, nil))
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("Expected status %d, got %d (%s)", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+			if got := rr.Header().Get(TotalCountHeader); got != tc.expectedTotal {
+				t.Errorf("Expected total %s, got %s", tc.expectedTotal, got)
+			}
+			var list models.AccountListResponse
+			json.NewDecoder(rr.Body).Decode(&list)
+			ids := []int64{}
+			for _, account := range list.Accounts {
+				ids = append(ids, account.AccountID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tc.expectedIDs) {
+				t.Errorf("Expected accounts %v, got %v", tc.expectedIDs, ids)
+			}
+		})
+	}
+}
 
-		if rr.Code != http.StatusCreated {
-			t.Errorf("Expected status 201, got %d", rr.Code)
-		}
+func TestHandler_ServiceHooks(t *testing.T) {
+	handler := NewMockHandler()
+	var created, committed int
+	handler.Accounts().OnAccountCreated(func(models.Account) { created++ })
+	handler.Transfers().OnTransferCommitted(func(models.Transaction) { committed++ })
 
-		// Try to create same account again
-		rr2 := httptest.NewRecorder()
-		req2 := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
-		req2.Header.Set("Content-Type", "application/json")
-		freshHandler.CreateAccount(rr2, req2)
+	for _, id := range []int64{1, 2} {
+		body, _ := json.Marshal(models.CreateAccountRequest{AccountID: id, InitialBalance: "10"})
+		handler.CreateAccount(httptest.NewRecorder(), httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
+	}
+	body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "5"})
+	handler.CreateTransaction(httptest.NewRecorder(), httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
 
-		// The mock handler now properly rejects duplicate accounts
-		if rr2.Code != http.StatusConflict {
-			t.Errorf("Expected status 409 for duplicate account, got %d", rr2.Code)
-		}
+	if created != 2 || committed != 1 {
+		t.Errorf("Expected hooks for 2 accounts and 1 transfer made through the API, got %d and %d", created, committed)
+	}
+}
+
+func TestCreateTransaction_Rules(t *testing.T) {
+	engine, err := rules.New([]rules.Definition{
+		{Name: "acme_cap", When: `amount > 50`, Message: "above the acme limit", Tenants: []string{"acme"}},
 	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	handler := NewMockHandler().WithRules(engine)
+	for _, id := range []int64{1, 2} {
+		body, _ := json.Marshal(models.CreateAccountRequest{AccountID: id, InitialBalance: "200"})
+		handler.CreateAccount(httptest.NewRecorder(), httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
+	}
 
-	t.Run("Very large balance", func(t *testing.T) {
-		reqBody := models.CreateAccountRequest{
-			AccountID:      999,
-			InitialBalance: "999999999999.999999",
+	testCases := []struct {
+		name         string
+		tenant       string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Rejected for tenant", "acme", http.StatusUnprocessableEntity, "Transfer rejected by rule acme_cap: above the acme limit"},
+		{"Other tenant", "globex", http.StatusCreated, ""},
+		{"Default tenant", "", http.StatusCreated, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "60"})
+			req := httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body))
+			if tc.tenant != "" {
+				req.Header.Set(TenantHeader, tc.tenant)
+			}
+			rr := httptest.NewRecorder()
+			handler.CreateTransaction(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Errorf("Expected status %d, got %d", tc.expectedCode, rr.Code)
+			}
+			if tc.expectedBody != "" && strings.TrimSpace(rr.Body.String()) != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateTransaction_Preauthorization(t *testing.T) {
+	decision := `{"decision": "decline", "reason": "velocity"}`
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if decision == "" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
 		}
+		w.Write([]byte(decision))
+	}))
+	defer endpoint.Close()
 
-		jsonBody, _ := json.Marshal(reqBody)
-		req := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
-		req.Header.Set("Content-Type", "application/json")
+	config := preauth.Config{URL: endpoint.URL, Timeout: time.Second, FailurePolicy: preauth.FailClosed}
+	handler := NewMockHandler().WithPreauthorization(preauth.New(config, nil))
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
 
+	transfer := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10"})
 		rr := httptest.NewRecorder()
-		handler.CreateAccount(rr, req)
+		handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+		return rr
+	}
 
-		if rr.Code != http.StatusCreated {
-			t.Errorf("Expected status 201, got %d", rr.Code)
-		}
-	})
+	if rr := transfer(); rr.Code != http.StatusUnprocessableEntity ||
+		strings.TrimSpace(rr.Body.String()) != "Transfer declined by pre-authorization: velocity" {
+		t.Errorf("Expected the decline as 422, got %d %q", rr.Code, rr.Body.String())
+	}
+	decision = ""
+	if rr := transfer(); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a verdict, got %d %q", rr.Code, rr.Body.String())
+	}
+	decision = `{"decision": "approve"}`
+	if rr := transfer(); rr.Code != http.StatusCreated {
+		t.Errorf("Expected an approved transfer, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	account, _ := handler.accountRepo.GetAccount(1)
+	if !account.Balance.Equal(decimal.NewFromInt(90)) {
+		t.Errorf("Expected only the approved transfer to be booked, balance %s", account.Balance)
+	}
 }
 
-func TestGetAccount_AdditionalEdgeCases(t *testing.T) {
+func TestSetOverdraftLimit(t *testing.T) {
 	handler := NewMockHandler()
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/accounts/{account_id}/overdraft", handler.SetOverdraftLimit).Methods("PUT")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(10)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
 
-	t.Run("Account exists - verify response headers", func(t *testing.T) {
-		// Create account first
-		handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(500.0))
+	put := func(path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("PUT", path, strings.NewReader(body)))
+		return rr
+	}
+	transfer := func(amount string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+		return rr
+	}
 
-		req := httptest.NewRequest("GET", "/accounts/123", nil)
-		vars := map[string]string{"account_id": "123"}
-		req = mux.SetURLVars(req, vars)
+	rr := put("/admin/accounts/1/overdraft", `{"overdraft_limit": "25.50"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var account models.AccountResponse
+	json.NewDecoder(rr.Body).Decode(&account)
+	if account.OverdraftLimit != "25.5" || account.Balance != "10" {
+		t.Errorf("Unexpected account %+v", account)
+	}
 
-		rr := httptest.NewRecorder()
-		handler.GetAccount(rr, req)
+	if rr := transfer("35.5"); rr.Code != http.StatusCreated {
+		t.Errorf("Expected the transfer to use the overdraft, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := transfer("0.01"); rr.Code != http.StatusBadRequest || strings.TrimSpace(rr.Body.String()) != "Insufficient balance" {
+		t.Errorf("Expected insufficient balance beyond the limit, got %d: %s", rr.Code, rr.Body.String())
+	}
 
-		if rr.Code != http.StatusOK {
-			t.Errorf("Expected status 200, got %d", rr.Code)
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/admin/accounts/1/overdraft", `{"overdraft_limit": "10"}`, http.StatusConflict},
+		{"/admin/accounts/1/overdraft", `{"overdraft_limit": "-1"}`, http.StatusBadRequest},
+		{"/admin/accounts/1/overdraft", `{`, http.StatusBadRequest},
+		{"/admin/accounts/9/overdraft", `{"overdraft_limit": "1"}`, http.StatusNotFound},
+		{"/admin/accounts/x/overdraft", `{"overdraft_limit": "1"}`, http.StatusBadRequest},
+	} {
+		if rr := put(tc.path, tc.body); rr.Code != tc.want {
+			t.Errorf("PUT %s %s: expected %d, got %d: %s", tc.path, tc.body, tc.want, rr.Code, rr.Body.String())
 		}
+	}
+}
 
-		// Check Content-Type header
-		contentType := rr.Header().Get("Content-Type")
-		if contentType != "application/json" {
-			t.Errorf("Expected Content-Type application/json, got %s", contentType)
+func TestCreateAdjustment(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithAudit(audit.NewRecorder(store.Audit()))
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/adjustments", handler.CreateAdjustment).Methods("POST")
+	router.HandleFunc("/accounts/{account_id}/statement", handler.GetStatement).Methods("GET")
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "USD"})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(10)})
+	store.Accounts().SetAccountStatus(2, models.AccountFrozen)
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/adjustments", strings.NewReader(body)))
+		return rr
+	}
+
+	rr := post(`{"account_id": 1, "amount": "-25.50", "reason": "Duplicate card settlement", "actor": "jdoe"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var adjustment models.AdjustmentResponse
+	json.NewDecoder(rr.Body).Decode(&adjustment)
+	if adjustment.ID != 1 || adjustment.Amount != "-25.5" || adjustment.BalanceAfter != "74.5" || adjustment.Actor != "jdoe" {
+		t.Errorf("Unexpected adjustment %+v", adjustment)
+	}
+	// Frozen accounts can be corrected
+	if rr := post(`{"account_id": 2, "amount": "5", "reason": "Missed refund", "actor": "jdoe"}`); rr.Code != http.StatusCreated {
+		t.Errorf("Expected the frozen account to be adjusted, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	for _, tc := range []struct {
+		body, want string
+		code       int
+	}{
+		{`{"account_id": 1, "amount": "-75", "reason": "Too much", "actor": "jdoe"}`, "Insufficient balance", http.StatusBadRequest},
+		{`{"account_id": 1, "amount": "0", "reason": "Nothing", "actor": "jdoe"}`, "Amount must not be zero", http.StatusBadRequest},
+		{`{"account_id": 1, "amount": "1.001", "reason": "Precision", "actor": "jdoe"}`, "Amount has more than 2 decimal places", http.StatusBadRequest},
+		{`{"account_id": 1, "amount": "1", "reason": " ", "actor": "jdoe"}`, "Reason is required", http.StatusBadRequest},
+		{`{"account_id": 1, "amount": "1", "reason": "No actor"}`, "Actor is required", http.StatusBadRequest},
+		{`{"account_id": 9, "amount": "1", "reason": "Unknown", "actor": "jdoe"}`, "Account not found", http.StatusNotFound},
+		{`{`, "Invalid request body", http.StatusBadRequest},
+	} {
+		if rr := post(tc.body); rr.Code != tc.code || strings.TrimSpace(rr.Body.String()) != tc.want {
+			t.Errorf("%s: expected %d %q, got %d %q", tc.body, tc.code, tc.want, rr.Code, rr.Body.String())
 		}
-	})
+	}
 
-	t.Run("Very large account ID", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/accounts/999999999999", nil)
-		vars := map[string]string{"account_id": "999999999999"}
-		req = mux.SetURLVars(req, vars)
+	events, _ := store.Audit().ListAuditEvents(context.Background(), models.AuditFilter{Action: models.AuditAccountAdjusted}, 10)
+	if len(events) != 2 || events[1].Actor != models.AuditActorAdmin || !strings.Contains(string(events[1].Before), `"balance":"100"`) ||
+		!strings.Contains(string(events[1].After), `"reason":"Duplicate card settlement"`) {
+		t.Errorf("Expected audited adjustments, got %+v", events)
+	}
 
-		rr := httptest.NewRecorder()
-		handler.GetAccount(rr, req)
+	// The adjustment is a statement line of its own, without transaction or counterparty
+	from, to := time.Now().Add(-24*time.Hour).UTC().Format("2006-01-02"), time.Now().Add(48*time.Hour).UTC().Format("2006-01-02")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts/1/statement?from="+from+"&to="+to, nil))
+	rows := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(rows) != 4 || !strings.HasSuffix(rows[1], ",Opening balance,,,,,,100.00") ||
+		!strings.HasSuffix(rows[2], ",,Manual adjustment - Duplicate card settlement,,,Duplicate card settlement,25.50,,74.50") {
+		t.Errorf("Unexpected statement:\n%s", rr.Body.String())
+	}
 
-		if rr.Code != http.StatusNotFound {
-			t.Errorf("Expected status 404, got %d", rr.Code)
-		}
-	})
+	if _, discrepancies, _ := store.Reconciliation().ReconcileBalances(context.Background()); len(discrepancies) != 0 {
+		t.Errorf("Expected adjustments to reconcile, got %+v", discrepancies)
+	}
 }
 
-func TestCreateTransaction_AdditionalEdgeCases(t *testing.T) {
-	handler := NewMockHandler()
-
-	t.Run("Transaction between same account", func(t *testing.T) {
-		// Create account
-		handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(1000.0))
+func TestWallets(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithWallets(store.Wallets()).WithAudit(audit.NewRecorder(store.Audit()))
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}", handler.GetAccount).Methods("GET")
+	router.HandleFunc("/accounts/{account_id}/wallets", handler.OpenWallet).Methods("POST")
+	router.HandleFunc("/accounts/{account_id}/wallets", handler.ListWallets).Methods("GET")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "USD"})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100), Currency: "EUR"})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.NewFromInt(100)})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
 
-		reqBody := models.CreateTransactionRequest{
-			SourceAccountID:      123,
-			DestinationAccountID: 123,
-			Amount:               "100.50",
+	rr := do("POST", "/accounts/1/wallets", `{"currency": "eur"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var wallet models.WalletResponse
+	json.NewDecoder(rr.Body).Decode(&wallet)
+	if wallet.Currency != "EUR" || wallet.Balance != "0" || wallet.Primary {
+		t.Errorf("Unexpected wallet %+v", wallet)
+	}
+	for _, tc := range []struct {
+		path, body, want string
+		code             int
+	}{
+		{"/accounts/1/wallets", `{"currency": "EUR"}`, "The account already holds this currency", http.StatusConflict},
+		{"/accounts/1/wallets", `{"currency": "ABC"}`, `Unsupported currency "ABC"`, http.StatusBadRequest},
+		{"/accounts/1/wallets", `{}`, "Currency is required", http.StatusBadRequest},
+		{"/accounts/3/wallets", `{"currency": "EUR"}`, "Accounts without a currency cannot hold wallets", http.StatusUnprocessableEntity},
+		{"/accounts/9/wallets", `{"currency": "EUR"}`, "Account not found", http.StatusNotFound},
+		{"/accounts/1/wallets", `{`, "Invalid request body", http.StatusBadRequest},
+	} {
+		if rr := do("POST", tc.path, tc.body); rr.Code != tc.code || strings.TrimSpace(rr.Body.String()) != tc.want {
+			t.Errorf("%s %s: expected %d %q, got %d %q", tc.path, tc.body, tc.code, tc.want, rr.Code, rr.Body.String())
 		}
+	}
 
-		jsonBody, _ := json.Marshal(reqBody)
-		req := httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(jsonBody))
-		req.Header.Set("Content-Type", "application/json")
+	rr = do("POST", "/transactions", `{"source_account_id": 2, "destination_account_id": 1, "amount": "25.50", "currency": "EUR"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"destination_wallet":"EUR"`) || strings.Contains(rr.Body.String(), `"source_wallet"`) {
+		t.Errorf("Expected a credit to the EUR wallet, got %s", rr.Body.String())
+	}
+	for _, tc := range []struct {
+		body, want string
+		code       int
+	}{
+		{`{"source_account_id": 1, "destination_account_id": 2, "amount": "30", "currency": "EUR"}`, "Insufficient balance", http.StatusBadRequest},
+		{`{"source_account_id": 2, "destination_account_id": 1, "amount": "1", "currency": "GBP"}`, "Source account has no wallet in the transfer currency", http.StatusUnprocessableEntity},
+		{`{"source_account_id": 2, "destination_account_id": 1, "amount": "1", "currency": "ABC"}`, `Unsupported currency "ABC"`, http.StatusBadRequest},
+	} {
+		if rr := do("POST", "/transactions", tc.body); rr.Code != tc.code || strings.TrimSpace(rr.Body.String()) != tc.want {
+			t.Errorf("%s: expected %d %q, got %d %q", tc.body, tc.code, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = do("GET", "/accounts/1/wallets", "")
+	var list models.WalletListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || len(list.Wallets) != 2 || !list.Wallets[0].Primary || list.Wallets[0].Balance != "100" ||
+		list.Wallets[1].Currency != "EUR" || list.Wallets[1].Balance != "25.5" {
+		t.Errorf("Expected the primary balance and the EUR wallet, got %d %+v", rr.Code, list)
+	}
+	if rr := do("GET", "/accounts/9/wallets", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rr.Code)
+	}
+	rr = do("GET", "/accounts/1", "")
+	var account models.AccountResponse
+	json.NewDecoder(rr.Body).Decode(&account)
+	if len(account.Wallets) != 2 || account.Wallets[1].Available != "25.5" {
+		t.Errorf("Expected the account to list its wallets, got %+v", account)
+	}
+	if rr := do("GET", "/accounts/3", ""); strings.Contains(rr.Body.String(), `"wallets"`) {
+		t.Errorf("Expected no wallets on a single-currency account, got %s", rr.Body.String())
+	}
+
+	events, _ := store.Audit().ListAuditEvents(context.Background(), models.AuditFilter{Action: models.AuditWalletOpened}, 10)
+	if len(events) != 1 || !strings.Contains(string(events[0].After), `"currency":"EUR"`) {
+		t.Errorf("Expected the wallet to be audited, got %+v", events)
+	}
+}
 
+func TestCurrencies(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithCurrencies(store.Currencies()).WithAudit(audit.NewRecorder(store.Audit()))
+	router := mux.NewRouter()
+	router.HandleFunc("/currencies", handler.ListCurrencies).Methods("GET")
+	router.HandleFunc("/admin/currencies/{code}", handler.SetCurrency).Methods("PUT")
+	router.HandleFunc("/admin/accounts/{account_id}/overdraft", handler.SetOverdraftLimit).Methods("PUT")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "EUR"})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100), Currency: "EUR"})
+	do := func(method, path, body string) *httptest.ResponseRecorder {
 		rr := httptest.NewRecorder()
-		handler.CreateTransaction(rr, req)
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
 
-		// Self-transfer may be rejected by business logic
-		if rr.Code != http.StatusCreated && rr.Code != http.StatusBadRequest {
-			t.Errorf("Expected status 201 or 400 for self-transfer, got %d", rr.Code)
+	rr := do("PUT", "/admin/currencies/eur", `{"min_amount": "5", "max_amount": "50", "allow_negative": false}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var currency models.CurrencyResponse
+	json.NewDecoder(rr.Body).Decode(&currency)
+	if currency.Code != "EUR" || currency.Scale != 2 || *currency.MinAmount != "5" || *currency.MaxAmount != "50" || currency.AllowNegative {
+		t.Errorf("Unexpected currency %+v", currency)
+	}
+	for _, tc := range []struct {
+		method, path, body, want string
+		code                     int
+	}{
+		{"PUT", "/admin/currencies/EUR", `{"scale": 9}`, "scale must be between 0 and 5", http.StatusBadRequest},
+		{"PUT", "/admin/currencies/XTS", `{}`, "scale is required for a new currency", http.StatusBadRequest},
+		{"PUT", "/admin/currencies/EUR", `{`, "Invalid request body", http.StatusBadRequest},
+		{"POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "4.99"}`, "Amount is below the currency's minimum transfer amount", http.StatusBadRequest},
+		{"POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "50.01"}`, "Amount is above the currency's maximum transfer amount", http.StatusBadRequest},
+		{"PUT", "/admin/accounts/1/overdraft", `{"overdraft_limit": "10"}`, "EUR accounts cannot have negative balances", http.StatusBadRequest},
+	} {
+		if rr := do(tc.method, tc.path, tc.body); rr.Code != tc.code || strings.TrimSpace(rr.Body.String()) != tc.want {
+			t.Errorf("%s %s %s: expected %d %q, got %d %q", tc.method, tc.path, tc.body, tc.code, tc.want, rr.Code, rr.Body.String())
 		}
-	})
+	}
+	if rr := do("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "50"}`); rr.Code != http.StatusCreated {
+		t.Errorf("Expected a transfer within the bounds, got %d: %s", rr.Code, rr.Body.String())
+	}
 
-	t.Run("Very small transaction amount", func(t *testing.T) {
-		handler.accountRepo.CreateAccount(123, decimal.NewFromFloat(1000.0))
-		handler.accountRepo.CreateAccount(456, decimal.NewFromFloat(500.0))
+	// The configuration applies to the handler it is attached to; others keep the built-in one
+	rr = httptest.NewRecorder()
+	NewHandlerWithStorage(store).CreateTransaction(rr, httptest.NewRequest("POST", "/transactions",
+		strings.NewReader(`{"source_account_id": 1, "destination_account_id": 2, "amount": "4.99"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected a handler without the configuration to ignore its bounds, got %d: %s", rr.Code, rr.Body.String())
+	}
 
-		reqBody := models.CreateTransactionRequest{
-			SourceAccountID:      123,
-			DestinationAccountID: 456,
-			Amount:               "0.001",
-		}
+	// Negative balances cannot be disallowed while an account may be overdrawn
+	do("PUT", "/admin/currencies/EUR", `{"allow_negative": true}`)
+	do("PUT", "/admin/accounts/1/overdraft", `{"overdraft_limit": "10"}`)
+	if rr := do("PUT", "/admin/currencies/EUR", `{"allow_negative": false}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409, got %d: %s", rr.Code, rr.Body.String())
+	}
 
-		jsonBody, _ := json.Marshal(reqBody)
-		req := httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(jsonBody))
-		req.Header.Set("Content-Type", "application/json")
+	rr = do("GET", "/currencies", "")
+	var list models.CurrencyListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || len(list.Currencies) != len(models.DefaultCurrencies()) {
+		t.Errorf("Expected every built-in currency, got %d %+v", rr.Code, list)
+	}
 
-		rr := httptest.NewRecorder()
-		handler.CreateTransaction(rr, req)
+	events, _ := store.Audit().ListAuditEvents(context.Background(), models.AuditFilter{Action: models.AuditCurrencyConfigured}, 10)
+	if len(events) != 2 || !strings.Contains(string(events[1].Before), `"scale":2`) || !strings.Contains(string(events[1].After), `"min_amount":"5"`) {
+		t.Errorf("Expected audited currency changes, got %+v", events)
+	}
+}
 
+func TestCreateTransaction_Currencies(t *testing.T) {
+	handler := NewMockHandler()
+	for id, currency := range map[int64]string{1: "USD", 2: "USD", 3: "EUR"} {
+		body, _ := json.Marshal(models.CreateAccountRequest{AccountID: id, InitialBalance: "100", Currency: currency})
+		rr := httptest.NewRecorder()
+		handler.CreateAccount(rr, httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
 		if rr.Code != http.StatusCreated {
-			t.Errorf("Expected status 201, got %d", rr.Code)
+			t.Fatalf("Expected status %d creating account %d, got %d: %s", http.StatusCreated, id, rr.Code, rr.Body.String())
 		}
-	})
+	}
 
-	t.Run("Transaction with invalid amount format", func(t *testing.T) {
-		reqBody := map[string]interface{}{
-			"source_account_id":      123,
-			"destination_account_id": 456,
-			"amount":                 "not-a-number",
-		}
+	rr := httptest.NewRecorder()
+	handler.CreateAccount(rr, httptest.NewRequest("POST", "/accounts", strings.NewReader(`{"account_id": 4, "initial_balance": "1", "currency": "ABC"}`)))
+	var invalid models.ValidationErrorResponse
+	json.NewDecoder(rr.Body).Decode(&invalid)
+	if rr.Code != http.StatusBadRequest || len(invalid.Fields) != 1 || invalid.Fields[0].Message != `Unsupported currency "ABC"` {
+		t.Errorf("Expected 400 for unsupported currency, got %d: %+v", rr.Code, invalid)
+	}
 
-		jsonBody, _ := json.Marshal(reqBody)
-		req := httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(jsonBody))
-		req.Header.Set("Content-Type", "application/json")
+	testCases := []struct {
+		name         string
+		destination  int64
+		amount       string
+		expectedCode int
+		expectedBody string
+	}{
+		{"Currency mismatch", 3, "10", http.StatusBadRequest, "Source and destination accounts hold different currencies"},
+		{"Too many decimals", 2, "10.001", http.StatusBadRequest, "Amount has more decimal places than the account currency allows"},
+		{"Same currency", 2, "10.01", http.StatusCreated, ""},
+	}
 
-		rr := httptest.NewRecorder()
-		handler.CreateTransaction(rr, req)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: tc.destination, Amount: tc.amount})
+			rr := httptest.NewRecorder()
+			handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+			if rr.Code != tc.expectedCode {
+				t.Errorf("Expected status %d, got %d", tc.expectedCode, rr.Code)
+			}
+			if tc.expectedBody != "" && strings.TrimSpace(rr.Body.String()) != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, rr.Body.String())
+			}
+		})
+	}
 
-		if rr.Code != http.StatusBadRequest {
-			t.Errorf("Expected status 400 for invalid amount, got %d", rr.Code)
-		}
-	})
+	req := httptest.NewRequest("GET", "/accounts/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"account_id": "1"})
+	rr = httptest.NewRecorder()
+	handler.GetAccount(rr, req)
+	var account models.AccountResponse
+	json.NewDecoder(rr.Body).Decode(&account)
+	if account.Currency != "USD" || account.Balance != "89.99" {
+		t.Errorf("Unexpected account %+v", account)
+	}
 }
 
-func TestHealthCheck_Comprehensive(t *testing.T) {
+func TestCreateTransaction_Convert(t *testing.T) {
 	handler := NewMockHandler()
+	for id, currency := range map[int64]string{1: "USD", 2: "EUR", 3: "GBP"} {
+		body, _ := json.Marshal(models.CreateAccountRequest{AccountID: id, InitialBalance: "100", Currency: currency})
+		rr := httptest.NewRecorder()
+		handler.CreateAccount(rr, httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d creating account %d, got %d: %s", http.StatusCreated, id, rr.Code, rr.Body.String())
+		}
+	}
 
-	t.Run("Health check returns proper headers", func(t *testing.T) {
-		req := httptest.NewRequest("GET", "/health", nil)
+	transfer := func(destination int64, convert bool) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: destination, Amount: "10", Convert: convert})
 		rr := httptest.NewRecorder()
+		handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+		return rr
+	}
 
-		handler.HealthCheck(rr, req)
+	if rr := transfer(2, true); rr.Code != http.StatusBadRequest || strings.TrimSpace(rr.Body.String()) != "Currency conversion is not enabled" {
+		t.Errorf("Expected 400 without a rate provider, got %d: %s", rr.Code, rr.Body.String())
+	}
 
-		if rr.Code != http.StatusOK {
-			t.Errorf("Expected status 200, got %d", rr.Code)
-		}
+	handler.WithFX(fx.NewStaticProvider(map[string]decimal.Decimal{"EUR/USD": decimal.RequireFromString("1.25")}))
+	rr := transfer(2, true)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var response models.TransactionResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Amount != "10" || response.DestinationAmount != "8" || response.FXRate != "0.8" || response.FXRateTimestamp == nil {
+		t.Errorf("Unexpected converted transaction %+v", response)
+	}
 
-		// Check response body
-		expected := `{"status":"healthy"}`
-		if strings.TrimSpace(rr.Body.String()) != expected {
-			t.Errorf("Expected %s, got %s", expected, rr.Body.String())
-		}
+	if rr := transfer(3, true); rr.Code != http.StatusServiceUnavailable || strings.TrimSpace(rr.Body.String()) != "Exchange rate unavailable" {
+		t.Errorf("Expected 503 for a pair without a rate, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := transfer(2, false); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a cross-currency transfer without convert, got %d", rr.Code)
+	}
+}
 
-		// Check Content-Type header
-		contentType := rr.Header().Get("Content-Type")
-		if contentType != "application/json" {
-			t.Errorf("Expected Content-Type application/json, got %s", contentType)
-		}
-	})
+func TestGetAccountChanges(t *testing.T) {
+	handler := NewMockHandler()
+	for id, balance := range map[int64]string{1: "100", 2: "0"} {
+		body, _ := json.Marshal(models.CreateAccountRequest{AccountID: id, InitialBalance: balance})
+		handler.CreateAccount(httptest.NewRecorder(), httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
+	}
+	for _, amount := range []string{"10", "20", "30"} {
+		body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount})
+		handler.CreateTransaction(httptest.NewRecorder(), httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+	}
 
-	t.Run("Health check with different HTTP methods", func(t *testing.T) {
-		methods := []string{"POST", "PUT", "DELETE", "PATCH"}
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}/changes", handler.GetAccountChanges).Methods("GET")
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		return rr
+	}
 
-		for _, method := range methods {
-			req := httptest.NewRequest(method, "/health", nil)
-			rr := httptest.NewRecorder()
+	rr := get("/accounts/2/changes?since_seq=1&limit=1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response models.AccountChangesResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.SinceSeq != 1 || response.LastSeq != 2 || !response.HasMore || len(response.Changes) != 1 ||
+		response.Changes[0].Amount != "20" || response.Changes[0].CounterpartyID != 1 {
+		t.Errorf("Unexpected response %+v", response)
+	}
 
-			// Note: This test assumes the router would reject non-GET methods
-			// But since we're testing the handler directly, it will respond
-			handler.HealthCheck(rr, req)
+	rr = get("/accounts/2/changes?since_seq=3")
+	response = models.AccountChangesResponse{}
+	json.NewDecoder(rr.Body).Decode(&response)
+	if rr.Code != http.StatusOK || response.LastSeq != 3 || response.HasMore || response.Changes == nil || len(response.Changes) != 0 {
+		t.Errorf("Expected an empty page for a mirror that is up to date, got %d %+v", rr.Code, response)
+	}
 
-			if rr.Code != http.StatusOK {
-				t.Errorf("HealthCheck handler should respond to %s method, got %d", method, rr.Code)
-			}
+	for url, code := range map[string]int{
+		"/accounts/9/changes":              http.StatusNotFound,
+		"/accounts/x/changes":              http.StatusBadRequest,
+		"/accounts/1/changes?since_seq=-1": http.StatusBadRequest,
+		"/accounts/1/changes?limit=1001":   http.StatusBadRequest,
+	} {
+		if rr := get(url); rr.Code != code {
+			t.Errorf("%s: expected status %d, got %d", url, code, rr.Code)
 		}
-	})
+	}
 }
 
-func TestHandlers_ErrorResponseFormats(t *testing.T) {
-	handler := NewMockHandler()
+// testSigner signs test requests with an API key issued by a handler, each a second after the
+// last, so a retry of the same request is not refused as a replay
+type testSigner struct {
+	key *models.APIKey
+	at  time.Time
+}
 
-	t.Run("Error responses have correct format", func(t *testing.T) {
-		// Test 404 error format
-		req := httptest.NewRequest("GET", "/accounts/999", nil)
-		vars := map[string]string{"account_id": "999"}
-		req = mux.SetURLVars(req, vars)
+// newTestSigner issues an API key from handler, which has signing attached
+func newTestSigner(t *testing.T, handler *Handler, description string) *testSigner {
+	t.Helper()
+	key, err := handler.signing.Create(context.Background(), models.CreateAPIKeyRequest{Description: description})
+	if err != nil {
+		t.Fatalf("Failed to issue an API key: %v", err)
+	}
+	return &testSigner{key: key, at: time.Now()}
+}
+
+// sign signs req, whose body is body; a nil signer leaves req unsigned
+func (s *testSigner) sign(req *http.Request, body string) {
+	if s == nil {
+		return
+	}
+	s.at = s.at.Add(time.Second)
+	signing.SignRequest(req, s.key.ID, s.key.Secret, []byte(body), s.at)
+}
 
+func TestUsage(t *testing.T) {
+	handler := NewMockHandler().WithSigning(memory.NewStore().APIKeys(), signing.Config{MaxAge: time.Hour})
+	payroll := newTestSigner(t, handler, "payroll")
+	recorder := usage.NewRecorder(memory.NewStore().Usage(), map[string]int64{payroll.key.ID: 3})
+	handler.WithUsage(recorder)
+	router := mux.NewRouter()
+	router.Use(recorder.Middleware)
+	router.HandleFunc("/accounts", handler.Identified(handler.CreateAccount)).Methods("POST")
+	router.HandleFunc("/transactions", handler.Identified(handler.CreateTransaction)).Methods("POST")
+	router.HandleFunc("/usage", handler.Identified(handler.GetUsage)).Methods("GET")
+	router.HandleFunc("/admin/usage", handler.UsageReport).Methods("GET")
+
+	send := func(method, path string, key *testSigner, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		key.sign(req, body)
 		rr := httptest.NewRecorder()
-		handler.GetAccount(rr, req)
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	send("POST", "/accounts", nil, `{"account_id": 1, "initial_balance": "100"}`)
+	send("POST", "/accounts", nil, `{"account_id": 2, "initial_balance": "0"}`)
+	send("POST", "/transactions", payroll, `{"source_account_id": 1, "destination_account_id": 2, "amount": "12.5"}`)
+	send("POST", "/transactions", payroll, `{"source_account_id": 1, "destination_account_id": 2, "amount": "500"}`)
+	// A key merely named, without a signature, is not charged
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{"source_account_id": 1, "destination_account_id": 2, "amount": "1"}`))
+	req.Header.Set("X-API-Key", payroll.key.ID)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	rr := send("GET", "/usage", payroll, "")
+	var own models.KeyUsageResponse
+	json.NewDecoder(rr.Body).Decode(&own)
+	if rr.Code != http.StatusOK || own.KeyID != payroll.key.ID || own.Requests != 3 || own.Transfers != 1 ||
+		own.TransferVolume != "12.5" || len(own.Days) != 1 {
+		t.Errorf("Unexpected usage %d %+v", rr.Code, own)
+	}
+	if own.Quota == nil || own.Quota.MonthlyRequests != 3 || own.Quota.Used != 3 || own.Quota.Remaining != 0 {
+		t.Errorf("Unexpected quota %+v", own.Quota)
+	}
 
-		if rr.Code != http.StatusNotFound {
-			t.Errorf("Expected status 404, got %d", rr.Code)
-		}
+	// Anonymous requests are counted once answered, so the report does not include itself
+	rr = send("GET", "/admin/usage", nil, "")
+	var report models.UsageReportResponse
+	json.NewDecoder(rr.Body).Decode(&report)
+	if rr.Code != http.StatusOK || len(report.Keys) != 2 || report.Keys[1].KeyID != usage.Anonymous || report.Keys[1].Requests != 3 ||
+		report.Keys[0].Transfers != 1 || report.Keys[1].Quota != nil || report.Keys[0].Days != nil {
+		t.Errorf("Unexpected report %d %+v", rr.Code, report)
+	}
 
-		// Check response body is not empty
-		responseBody := rr.Body.String()
-		if responseBody == "" {
-			t.Error("Error response should not be empty")
+	for _, query := range []string{"?from=2024-02-30", "?from=2024-03-02&to=2024-03-01", "?from=2023-01-01&to=2024-12-31"} {
+		if rr := send("GET", "/usage"+query, nil, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
 		}
+	}
+	if rr := send("GET", "/usage?from=2000-01-01&to=2000-01-31", payroll, ""); !strings.Contains(rr.Body.String(), `"requests":0`) {
+		t.Errorf("Expected no usage in an old period, got %s", rr.Body.String())
+	}
 
-		// Try to parse as JSON if it looks like JSON
-		if strings.HasPrefix(responseBody, "{") {
-			var response map[string]interface{}
-			err := json.Unmarshal(rr.Body.Bytes(), &response)
-			if err != nil {
-				t.Errorf("Error response should be valid JSON: %v", err)
-			} else {
-				// Check that error field exists
-				if _, exists := response["error"]; !exists {
-					t.Error("JSON error response should contain 'error' field")
-				}
-			}
-		} else {
-			t.Logf("Non-JSON error response: %s", responseBody)
-		}
-	})
+	rr = httptest.NewRecorder()
+	NewMockHandler().GetUsage(rr, httptest.NewRequest("GET", "/usage", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without usage metering, got %d", rr.Code)
+	}
 }
 
-func TestHandlers_ConcurrentRequests(t *testing.T) {
-	handler := NewMockHandler()
+func TestSLA(t *testing.T) {
+	store := memory.NewStore()
+	recorder := usage.NewRecorder(store.Usage(), nil)
+	handler := NewHandlerWithStorage(store).WithUsage(recorder).WithLatency(sla.NewRecorder(store.Latency(), time.Minute)).
+		WithSigning(store.APIKeys(), signing.Config{MaxAge: time.Hour})
+	partner, other := newTestSigner(t, handler, "partner"), newTestSigner(t, handler, "other")
+	router := mux.NewRouter()
+	router.Use(recorder.Middleware)
+	router.HandleFunc("/accounts", handler.Identified(handler.CreateAccount)).Methods("POST")
+	router.HandleFunc("/transactions", handler.Identified(handler.CreateTransaction)).Methods("POST")
+	router.HandleFunc("/sla", handler.Identified(handler.GetSLA)).Methods("GET")
+	router.HandleFunc("/admin/sla", handler.SLAReport).Methods("GET")
+
+	send := func(method, path string, key *testSigner, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		key.sign(req, body)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	send("POST", "/accounts", nil, `{"account_id": 1, "initial_balance": "100"}`)
+	send("POST", "/accounts", nil, `{"account_id": 2, "initial_balance": "0"}`)
+	send("POST", "/transactions", partner, `{"source_account_id": 1, "destination_account_id": 2, "amount": "10"}`)
+	send("POST", "/transactions", partner, `{"source_account_id": 1, "destination_account_id": 2, "amount": "20"}`)
+	send("POST", "/transactions", partner, `{"source_account_id": 1, "destination_account_id": 2, "amount": "500"}`)
+
+	rr := send("GET", "/sla", partner, "")
+	var own models.SLAResponse
+	json.NewDecoder(rr.Body).Decode(&own)
+	if rr.Code != http.StatusOK || own.ClientID != partner.key.ID || own.Transfers != 2 || own.WithinTarget != 2 ||
+		!own.Compliant || own.TargetP95Ms != 60000 || own.CommitP95Ms > own.EmitP95Ms {
+		t.Errorf("Unexpected SLA %d %+v", rr.Code, own)
+	}
 
-	t.Run("Concurrent account creation", func(t *testing.T) {
-		// Test multiple concurrent requests
-		var wg sync.WaitGroup
-		numRequests := 10
+	// Clients without transfers meet the SLA trivially
+	rr = send("GET", "/sla", other, "")
+	if !strings.Contains(rr.Body.String(), `"transfers":0`) || !strings.Contains(rr.Body.String(), `"compliant":true`) {
+		t.Errorf("Expected an empty compliant SLA, got %s", rr.Body.String())
+	}
 
-		for i := 0; i < numRequests; i++ {
-			wg.Add(1)
-			go func(accountID int) {
-				defer wg.Done()
+	rr = send("GET", "/admin/sla", nil, "")
+	var report models.SLAReportResponse
+	json.NewDecoder(rr.Body).Decode(&report)
+	if rr.Code != http.StatusOK || len(report.Clients) != 1 || report.Clients[0].Transfers != 2 || report.TargetP95Ms != 60000 {
+		t.Errorf("Unexpected report %d %+v", rr.Code, report)
+	}
 
-				reqBody := models.CreateAccountRequest{
-					AccountID:      int64(accountID),
-					InitialBalance: "100.00",
-				}
+	if rr := send("GET", "/sla?from=2024-03-02&to=2024-03-01", nil, ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an inverted period, got %d", rr.Code)
+	}
 
-				jsonBody, _ := json.Marshal(reqBody)
-				req := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
-				req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	NewMockHandler().GetSLA(rr, httptest.NewRequest("GET", "/sla", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without SLA tracking, got %d", rr.Code)
+	}
+}
 
-				rr := httptest.NewRecorder()
-				handler.CreateAccount(rr, req)
+func TestNewHandlerWithStorage(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store)
 
-				if rr.Code != http.StatusCreated && rr.Code != http.StatusConflict {
-					t.Errorf("Expected status 201 or 409, got %d", rr.Code)
-				}
-			}(i + 1000) // Use account IDs starting from 1000
-		}
+	body, _ := json.Marshal(models.CreateAccountRequest{AccountID: 1, InitialBalance: "10"})
+	rr := httptest.NewRecorder()
+	handler.CreateAccount(rr, httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rr.Code)
+	}
+	if exists, _ := store.Accounts().AccountExists(1); !exists {
+		t.Error("Expected the account to be created in the storage")
+	}
 
-		wg.Wait()
-	})
+	// The in-memory storage has no connection pool
+	rr = httptest.NewRecorder()
+	handler.DatabaseStats(rr, httptest.NewRequest("GET", "/health/db", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without pool statistics, got %d", rr.Code)
+	}
+	if handler.settlements == nil {
+		t.Error("Expected settlement repository from the storage")
+	}
+}
+
+func TestFullTransactionFlow(t *testing.T) {
+	_ = httptest.NewRecorder()
+	// Integration test would verify complete transaction flow
+	t.Log("Integration test would verify complete transaction flow")
+}
+
+// =============================================================================
+// Content Type and Misc Tests
+// =============================================================================
+
+func TestHandlers_ContentTypeValidation(t *testing.T) {
+	handler := NewMockHandler()
+
+	tests := []struct {
+		name        string
+		endpoint    string
+		method      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "CreateAccount without content-type",
+			endpoint:    "/accounts",
+			method:      "POST",
+			contentType: "",
+			body:        `{"account_id": 123, "initial_balance": "100.00"}`,
+		},
+		{
+			name:        "CreateAccount with wrong content-type",
+			endpoint:    "/accounts",
+			method:      "POST",
+			contentType: "text/plain",
+			body:        `{"account_id": 123, "initial_balance": "100.00"}`,
+		},
+		{
+			name:        "CreateTransaction without content-type",
+			endpoint:    "/transactions",
+			method:      "POST",
+			contentType: "",
+			body:        `{"source_account_id": 123, "destination_account_id": 456, "amount": "100.00"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.endpoint, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+
+			if tt.endpoint == "/accounts" {
+				handler.CreateAccount(rr, req)
+			} else if tt.endpoint == "/transactions" {
+				handler.CreateTransaction(rr, req)
+			}
+
+			// Most should result in bad request due to JSON parsing issues
+			if rr.Code != http.StatusBadRequest && rr.Code != http.StatusCreated {
+				t.Logf("Status %d for %s (this tests error handling paths)",
+					rr.Code, tt.name)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Health Check Tests
+// =============================================================================
+
+func TestHealthCheck(t *testing.T) {
+	_ = httptest.NewRecorder()
+	t.Log("Health check test placeholder")
+}
+
+func TestHealthCheck_Detailed(t *testing.T) {
+	handler := &Handler{}
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	rr := httptest.NewRecorder()
+
+	handler.HealthCheck(rr, req)
+
+	// Test status code
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+
+	// Test content type
+	expectedContentType := "application/json"
+	if contentType := rr.Header().Get("Content-Type"); contentType != expectedContentType {
+		t.Errorf("Expected Content-Type %s, got %s", expectedContentType, contentType)
+	}
+
+	// Test response body
+	var response map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Errorf("Failed to decode response: %v", err)
+	}
+
+	if response["status"] != "healthy" {
+		t.Errorf("Expected status 'healthy', got %s", response["status"])
+	}
+}
+
+func TestHealthCheck_WithNilHandler(t *testing.T) {
+	handler := &Handler{}
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	rr := httptest.NewRecorder()
+
+	// This should still work even with nil repositories in handler
+	handler.HealthCheck(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestHealthCheck_InvalidMethod(t *testing.T) {
+	handler := &Handler{}
+
+	req := httptest.NewRequest("POST", "/health", nil)
+	rr := httptest.NewRecorder()
+
+	handler.HealthCheck(rr, req)
+
+	// Health check should still respond regardless of method
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestHealthCheck_Multiple(t *testing.T) {
+	handler := &Handler{}
+
+	// Test multiple calls to ensure consistency
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/health", nil)
+		rr := httptest.NewRecorder()
+
+		handler.HealthCheck(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Health check call %d failed with status %d", i+1, rr.Code)
+		}
+	}
+}
+
+func TestStreamTransactions(t *testing.T) {
+	t.Run("Streaming disabled", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		NewMockHandler().StreamTransactions(rr, httptest.NewRequest("GET", "/accounts/1/transactions/stream", nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 without a broker, got %d", rr.Code)
+		}
+	})
+
+	handler := NewMockHandler()
+	broker := pubsub.NewBroker()
+	handler.WithBroker(broker)
+	handler.transactionRepo = pubsub.NewTransactionRepository(handler.transactionRepo, broker)
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromInt(100)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.Zero})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}/transactions/stream", handler.StreamTransactions)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	t.Run("Unknown account", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/accounts/999/transactions/stream")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Replay and live events", func(t *testing.T) {
+		// Committed before the client connects; replayed because of Last-Event-ID
+		handler.transactionRepo.CreateTransaction(models.Transfer{SourceAccountID: 123, DestinationAccountID: 456, Amount: decimal.NewFromInt(10)})
+
+		req, _ := http.NewRequest("GET", server.URL+"/accounts/456/transactions/stream", nil)
+		req.Header.Set("Last-Event-ID", "0")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Expected text/event-stream, got %q", ct)
+		}
+
+		events := make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if line := scanner.Text(); strings.HasPrefix(line, "id: ") || strings.HasPrefix(line, "data: ") {
+					events <- line
+				}
+			}
+			close(events)
+		}()
+		next := func() string {
+			select {
+			case line := <-events:
+				return line
+			case <-time.After(2 * time.Second):
+				t.Fatal("Timed out waiting for event")
+				return ""
+			}
+		}
+
+		if line := next(); line != "id: 1" {
+			t.Fatalf("Expected replayed event id 1, got %q", line)
+		}
+		next()
+
+		handler.transactionRepo.CreateTransaction(models.Transfer{SourceAccountID: 123, DestinationAccountID: 456, Amount: decimal.NewFromInt(5)})
+		if line := next(); line != "id: 2" {
+			t.Fatalf("Expected live event id 2, got %q", line)
+		}
+		var event models.TransactionResponse
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(next(), "data: ")), &event); err != nil {
+			t.Fatalf("Invalid event data: %v", err)
+		}
+		if event.Amount != "5" || event.DestinationAccountID != 456 {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	})
+}
+
+func TestBalanceFeed(t *testing.T) {
+	handler := NewMockHandler()
+	broker := pubsub.NewBroker()
+	handler.WithBroker(broker)
+	handler.transactionRepo = pubsub.NewTransactionRepository(handler.transactionRepo, broker)
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromInt(100)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.Zero})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 789, InitialBalance: decimal.Zero})
+
+	server := httptest.NewServer(http.HandlerFunc(handler.BalanceFeed))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	read := func() wsServerMessage {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg wsServerMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return msg
+	}
+
+	// Unknown accounts are rejected without closing the connection
+	conn.WriteJSON(wsClientMessage{Action: "subscribe", AccountIDs: []int64{999}})
+	if msg := read(); msg.Type != "error" || msg.AccountID != 999 {
+		t.Errorf("Expected error for unknown account, got %+v", msg)
+	}
+
+	// Subscribing acknowledges and sends the current balance
+	conn.WriteJSON(wsClientMessage{Action: "subscribe", AccountIDs: []int64{456}})
+	if msg := read(); msg.Type != "subscribed" || len(msg.AccountIDs) != 1 {
+		t.Fatalf("Expected subscribed ack, got %+v", msg)
+	}
+	if msg := read(); msg.Type != "balance" || msg.AccountID != 456 || msg.Balance != "0" {
+		t.Fatalf("Expected initial balance snapshot, got %+v", msg)
+	}
+
+	// Transfers touching other accounts only are not pushed
+	handler.transactionRepo.CreateTransaction(models.Transfer{SourceAccountID: 123, DestinationAccountID: 789, Amount: decimal.NewFromInt(1)})
+	handler.transactionRepo.CreateTransaction(models.Transfer{SourceAccountID: 123, DestinationAccountID: 456, Amount: decimal.NewFromInt(25)})
+
+	msg := read()
+	if msg.Type != "balance" || msg.AccountID != 456 || msg.Balance != "25" || msg.Sequence != 1 || msg.TransactionID != 2 {
+		t.Errorf("Expected balance update for 456 from transaction 2, got %+v", msg)
+	}
+}
+
+func TestDatabaseStats(t *testing.T) {
+	t.Run("No pool attached", func(t *testing.T) {
+		handler := &Handler{}
+
+		req := httptest.NewRequest("GET", "/health/db", nil)
+		rr := httptest.NewRecorder()
+		handler.DatabaseStats(rr, req)
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Pool attached", func(t *testing.T) {
+		handler := NewMockHandler().WithPoolStats(func() database.PoolStats {
+			return database.PoolStats{MaxConns: 25, TotalConns: 3, AcquiredConns: 1, IdleConns: 2}
+		})
+
+		req := httptest.NewRequest("GET", "/health/db", nil)
+		rr := httptest.NewRecorder()
+		handler.DatabaseStats(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+
+		var stats database.PoolStats
+		if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if stats.MaxConns != 25 || stats.TotalConns != 3 || stats.IdleConns != 2 {
+			t.Errorf("Unexpected pool statistics: %+v", stats)
+		}
+	})
+}
+
+// =============================================================================
+// GraphQL Tests
+// =============================================================================
+
+// graphqlRequest executes a GraphQL query against the handler and decodes the response
+func graphqlRequest(t *testing.T, handler *Handler, query string) (map[string]any, []map[string]any) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	handler.GraphQL().ServeHTTP(rr, req)
+
+	var response struct {
+		Data   map[string]any   `json:"data"`
+		Errors []map[string]any `json:"errors"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode GraphQL response: %v", err)
+	}
+	return response.Data, response.Errors
+}
+
+func TestGraphQL_AccountWithTransactions(t *testing.T) {
+	handler := NewMockHandler()
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.0)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(500.0)})
+	handler.transactionRepo.CreateTransaction(models.Transfer{SourceAccountID: 123, DestinationAccountID: 456, Amount: decimal.NewFromFloat(100.0)})
+	handler.transactionRepo.CreateTransaction(models.Transfer{SourceAccountID: 456, DestinationAccountID: 123, Amount: decimal.NewFromFloat(25.0)})
+
+	data, errs := graphqlRequest(t, handler, `{
+		account(id: "123") {
+			id
+			balance
+			sequence
+			transactions(limit: 10) { id amount sourceSequence destination { id balance } }
+		}
+	}`)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	account := data["account"].(map[string]any)
+	if account["balance"] != "925" || account["sequence"] != float64(2) {
+		t.Errorf("Unexpected account: %v", account)
+	}
+	transactions := account["transactions"].([]any)
+	if len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactions))
+	}
+	newest := transactions[0].(map[string]any)
+	if newest["amount"] != "25" || newest["destination"].(map[string]any)["id"] != "123" {
+		t.Errorf("Expected newest transaction first, got %v", newest)
+	}
+}
+
+func TestGraphQL_UnknownAccount(t *testing.T) {
+	data, errs := graphqlRequest(t, NewMockHandler(), `{ account(id: "999") { id } }`)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if data["account"] != nil {
+		t.Errorf("Expected null account, got %v", data["account"])
+	}
+}
+
+func TestGraphQL_TransferMutation(t *testing.T) {
+	handler := NewMockHandler()
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(100.0)})
+	handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(0)})
+
+	data, errs := graphqlRequest(t, handler, `mutation {
+		transfer(sourceAccountId: "123", destinationAccountId: "456", amount: "40") { amount source { balance } }
+	}`)
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	transfer := data["transfer"].(map[string]any)
+	if transfer["source"].(map[string]any)["balance"] != "60" {
+		t.Errorf("Expected source balance 60 after transfer, got %v", transfer)
+	}
+
+	testCases := []struct {
+		name, mutation, wantErr string
+	}{
+		{"Insufficient balance", `mutation { transfer(sourceAccountId: "123", destinationAccountId: "456", amount: "1000") { id } }`, "insufficient balance"},
+		{"Same account", `mutation { transfer(sourceAccountId: "123", destinationAccountId: "123", amount: "1") { id } }`, "Destination account ID must differ from source account ID"},
+		{"Invalid amount", `mutation { transfer(sourceAccountId: "123", destinationAccountId: "456", amount: "abc") { id } }`, "Invalid amount format"},
+		{"Invalid ID", `mutation { transfer(sourceAccountId: "x", destinationAccountId: "456", amount: "1") { id } }`, `invalid ID "x"`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errs := graphqlRequest(t, handler, tc.mutation)
+			if len(errs) != 1 || errs[0]["message"] != tc.wantErr {
+				t.Errorf("Expected error %q, got %v", tc.wantErr, errs)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Error Handling and Edge Cases
+// =============================================================================
+
+func TestHandler_ErrorPaths(t *testing.T) {
+	// Test various error conditions to improve coverage
+	handler := NewMockHandler()
+
+	t.Run("CreateAccount with malformed JSON", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/accounts", strings.NewReader(`{"account_id": 123, "initial_balance": "100.00"`))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.CreateAccount(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for malformed JSON, got %d", rr.Code)
+		}
+	})
+
+	t.Run("CreateTransaction with missing fields", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{"source_account_id": 123}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.CreateTransaction(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for missing fields, got %d", rr.Code)
+		}
+	})
+}
+
+// =============================================================================
+// Additional Edge Case Tests for Maximum Coverage
+// =============================================================================
+
+func TestCreateAccount_AdditionalEdgeCases(t *testing.T) {
+	handler := NewMockHandler()
+
+	t.Run("Invalid account ID in URL", func(t *testing.T) {
+		reqBody := models.CreateAccountRequest{
+			AccountID:      123,
+			InitialBalance: "100.50",
+		}
+
+		jsonBody, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.CreateAccount(rr, req)
+
+		// Should succeed since account ID is in body, not URL
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Duplicate account creation", func(t *testing.T) {
+		// Use a fresh handler to avoid conflicts with previous tests
+		freshHandler := NewMockHandler()
+
+		// Create account first time
+		reqBody := models.CreateAccountRequest{
+			AccountID:      12345, // Use unique ID
+			InitialBalance: "100.50",
+		}
+
+		jsonBody, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		freshHandler.CreateAccount(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", rr.Code)
+		}
+
+		// Try to create same account again
+		rr2 := httptest.NewRecorder()
+		req2 := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
+		req2.Header.Set("Content-Type", "application/json")
+		freshHandler.CreateAccount(rr2, req2)
+
+		// The mock handler now properly rejects duplicate accounts
+		if rr2.Code != http.StatusConflict {
+			t.Errorf("Expected status 409 for duplicate account, got %d", rr2.Code)
+		}
+	})
+
+	t.Run("Very large balance", func(t *testing.T) {
+		reqBody := models.CreateAccountRequest{
+			AccountID:      999,
+			InitialBalance: "999999999999.999999",
+		}
+
+		jsonBody, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.CreateAccount(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", rr.Code)
+		}
+	})
+}
+
+func TestGetAccount_AdditionalEdgeCases(t *testing.T) {
+	handler := NewMockHandler()
+
+	t.Run("Account exists - verify response headers", func(t *testing.T) {
+		// Create account first
+		handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(500.0)})
+
+		req := httptest.NewRequest("GET", "/accounts/123", nil)
+		vars := map[string]string{"account_id": "123"}
+		req = mux.SetURLVars(req, vars)
+
+		rr := httptest.NewRecorder()
+		handler.GetAccount(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", rr.Code)
+		}
+
+		// Check Content-Type header
+		contentType := rr.Header().Get("Content-Type")
+		if contentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got %s", contentType)
+		}
+	})
+
+	t.Run("Very large account ID", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/accounts/999999999999", nil)
+		vars := map[string]string{"account_id": "999999999999"}
+		req = mux.SetURLVars(req, vars)
+
+		rr := httptest.NewRecorder()
+		handler.GetAccount(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", rr.Code)
+		}
+	})
+}
+
+func TestCreateTransaction_AdditionalEdgeCases(t *testing.T) {
+	handler := NewMockHandler()
+
+	t.Run("Transaction between same account", func(t *testing.T) {
+		// Create account
+		handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.0)})
+
+		reqBody := models.CreateTransactionRequest{
+			SourceAccountID:      123,
+			DestinationAccountID: 123,
+			Amount:               "100.50",
+		}
+
+		jsonBody, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.CreateTransaction(rr, req)
+
+		// Self-transfer may be rejected by business logic
+		if rr.Code != http.StatusCreated && rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 201 or 400 for self-transfer, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Very small transaction amount", func(t *testing.T) {
+		handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 123, InitialBalance: decimal.NewFromFloat(1000.0)})
+		handler.accountRepo.CreateAccount(models.NewAccount{AccountID: 456, InitialBalance: decimal.NewFromFloat(500.0)})
+
+		reqBody := models.CreateTransactionRequest{
+			SourceAccountID:      123,
+			DestinationAccountID: 456,
+			Amount:               "0.001",
+		}
+
+		jsonBody, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.CreateTransaction(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status 201, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Transaction with invalid amount format", func(t *testing.T) {
+		reqBody := map[string]interface{}{
+			"source_account_id":      123,
+			"destination_account_id": 456,
+			"amount":                 "not-a-number",
+		}
+
+		jsonBody, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.CreateTransaction(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for invalid amount, got %d", rr.Code)
+		}
+	})
+}
+
+func TestHealthCheck_Comprehensive(t *testing.T) {
+	handler := NewMockHandler()
+
+	t.Run("Health check returns proper headers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/health", nil)
+		rr := httptest.NewRecorder()
+
+		handler.HealthCheck(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", rr.Code)
+		}
+
+		// Check response body
+		expected := `{"status":"healthy"}`
+		if strings.TrimSpace(rr.Body.String()) != expected {
+			t.Errorf("Expected %s, got %s", expected, rr.Body.String())
+		}
+
+		// Check Content-Type header
+		contentType := rr.Header().Get("Content-Type")
+		if contentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got %s", contentType)
+		}
+	})
+
+	t.Run("Health check with different HTTP methods", func(t *testing.T) {
+		methods := []string{"POST", "PUT", "DELETE", "PATCH"}
+
+		for _, method := range methods {
+			req := httptest.NewRequest(method, "/health", nil)
+			rr := httptest.NewRecorder()
+
+			// Note: This test assumes the router would reject non-GET methods
+			// But since we're testing the handler directly, it will respond
+			handler.HealthCheck(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("HealthCheck handler should respond to %s method, got %d", method, rr.Code)
+			}
+		}
+	})
+}
+
+func TestHandlers_ErrorResponseFormats(t *testing.T) {
+	handler := NewMockHandler()
+
+	t.Run("Error responses have correct format", func(t *testing.T) {
+		// Test 404 error format
+		req := httptest.NewRequest("GET", "/accounts/999", nil)
+		vars := map[string]string{"account_id": "999"}
+		req = mux.SetURLVars(req, vars)
+
+		rr := httptest.NewRecorder()
+		handler.GetAccount(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", rr.Code)
+		}
+
+		// Check response body is not empty
+		responseBody := rr.Body.String()
+		if responseBody == "" {
+			t.Error("Error response should not be empty")
+		}
+
+		// Try to parse as JSON if it looks like JSON
+		if strings.HasPrefix(responseBody, "{") {
+			var response map[string]interface{}
+			err := json.Unmarshal(rr.Body.Bytes(), &response)
+			if err != nil {
+				t.Errorf("Error response should be valid JSON: %v", err)
+			} else {
+				// Check that error field exists
+				if _, exists := response["error"]; !exists {
+					t.Error("JSON error response should contain 'error' field")
+				}
+			}
+		} else {
+			t.Logf("Non-JSON error response: %s", responseBody)
+		}
+	})
+}
+
+func TestHandlers_ConcurrentRequests(t *testing.T) {
+	handler := NewMockHandler()
+
+	t.Run("Concurrent account creation", func(t *testing.T) {
+		// Test multiple concurrent requests
+		var wg sync.WaitGroup
+		numRequests := 10
+
+		for i := 0; i < numRequests; i++ {
+			wg.Add(1)
+			go func(accountID int) {
+				defer wg.Done()
+
+				reqBody := models.CreateAccountRequest{
+					AccountID:      int64(accountID),
+					InitialBalance: "100.00",
+				}
+
+				jsonBody, _ := json.Marshal(reqBody)
+				req := httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(jsonBody))
+				req.Header.Set("Content-Type", "application/json")
+
+				rr := httptest.NewRecorder()
+				handler.CreateAccount(rr, req)
+
+				if rr.Code != http.StatusCreated && rr.Code != http.StatusConflict {
+					t.Errorf("Expected status 201 or 409, got %d", rr.Code)
+				}
+			}(i + 1000) // Use account IDs starting from 1000
+		}
+
+		wg.Wait()
+	})
+}
+
+func TestGetBalanceAsOf(t *testing.T) {
+	handler := NewMockHandler()
+	for id, balance := range map[int64]string{1: "100", 2: "0"} {
+		body, _ := json.Marshal(models.CreateAccountRequest{AccountID: id, InitialBalance: balance})
+		handler.CreateAccount(httptest.NewRecorder(), httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
+	}
+	backdated := time.Now().Add(-time.Hour).UTC()
+	body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "40", EffectiveAt: &backdated})
+	rr := httptest.NewRecorder()
+	handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+	var created models.TransactionResponse
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || !created.EffectiveAt.Equal(backdated) {
+		t.Fatalf("Expected a backdated transfer, got %d %+v", rr.Code, created)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}/balance", handler.GetBalanceAsOf).Methods("GET")
+	get := func(url string) (*httptest.ResponseRecorder, models.BalanceAsOfResponse) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		var response models.BalanceAsOfResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return rr, response
+	}
+
+	if rr, response := get("/accounts/1/balance"); rr.Code != http.StatusOK || response.Balance != "60" {
+		t.Errorf("Expected the current balance 60, got %d %+v", rr.Code, response)
+	}
+	before := url.QueryEscape(backdated.Add(-time.Minute).Format(time.RFC3339))
+	if rr, response := get("/accounts/1/balance?effective_at=" + before); rr.Code != http.StatusOK || response.Balance != "100" {
+		t.Errorf("Expected 100 effective before the backdated transfer, got %d %+v", rr.Code, response)
+	}
+
+	// as_of on the account replays the ledger as it stood then along both axes
+	router.HandleFunc("/accounts/{account_id}", handler.GetAccount).Methods("GET")
+	now := url.QueryEscape(time.Now().UTC().Format(time.RFC3339Nano))
+	if rr, response := get("/accounts/1?as_of=" + now); rr.Code != http.StatusOK || response.Balance != "60" || !response.RecordedAt.Equal(response.EffectiveAt) {
+		t.Errorf("Expected the balance 60 as of now, got %d %+v", rr.Code, response)
+	}
+
+	for url, code := range map[string]int{
+		"/accounts/9/balance":                        http.StatusNotFound,
+		"/accounts/1/balance?recorded_at=2000-01-01": http.StatusNotFound,
+		"/accounts/1/balance?effective_at=yesterday": http.StatusBadRequest,
+		"/accounts/x/balance":                        http.StatusBadRequest,
+		"/accounts/1?as_of=2000-01-01":               http.StatusNotFound,
+		"/accounts/1?as_of=yesterday":                http.StatusBadRequest,
+	} {
+		if rr, _ := get(url); rr.Code != code {
+			t.Errorf("%s: expected status %d, got %d", url, code, rr.Code)
+		}
+	}
+}
+
+func TestRecurringTransfers(t *testing.T) {
+	handler := NewMockHandler().WithRecurring(memory.NewRecurringRepository(memory.NewStore()))
+	for id, balance := range map[int64]string{1: "100", 2: "0"} {
+		body, _ := json.Marshal(models.CreateAccountRequest{AccountID: id, InitialBalance: balance})
+		handler.CreateAccount(httptest.NewRecorder(), httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/recurring-transfers", handler.CreateRecurringTransfer).Methods("POST")
+	router.HandleFunc("/recurring-transfers", handler.ListRecurringTransfers).Methods("GET")
+	router.HandleFunc("/recurring-transfers/{recurring_id}", handler.GetRecurringTransfer).Methods("GET")
+	router.HandleFunc("/recurring-transfers/{recurring_id}", handler.DeleteRecurringTransfer).Methods("DELETE")
+	router.HandleFunc("/recurring-transfers/{recurring_id}/pause", handler.PauseRecurringTransfer).Methods("POST")
+	router.HandleFunc("/recurring-transfers/{recurring_id}/resume", handler.ResumeRecurringTransfer).Methods("POST")
+	router.HandleFunc("/recurring-transfers/{recurring_id}/executions", handler.ListRecurringExecutions).Methods("GET")
+	do := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, url, &payload))
+		return rr
+	}
+
+	rr := do("POST", "/recurring-transfers", models.CreateRecurringTransferRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "25", Schedule: "@every 1m"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var rule models.RecurringTransferResponse
+	json.NewDecoder(rr.Body).Decode(&rule)
+	if rule.ID == 0 || rule.Amount != "25" || rule.Status != models.RecurringActive || rule.NextRunAt.IsZero() {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+	path := fmt.Sprintf("/recurring-transfers/%d", rule.ID)
+
+	if rr := do("POST", path+"/pause", nil); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"status":"paused"`) {
+		t.Errorf("Expected a paused rule, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", path+"/resume", nil); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"status":"active"`) {
+		t.Errorf("Expected an active rule, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = do("GET", "/recurring-transfers", nil)
+	var list models.RecurringTransferListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || len(list.RecurringTransfers) != 1 {
+		t.Errorf("Expected one rule, got %d %+v", rr.Code, list)
+	}
+	rr = do("GET", path+"/executions", nil)
+	var executions models.RecurringExecutionListResponse
+	json.NewDecoder(rr.Body).Decode(&executions)
+	if rr.Code != http.StatusOK || executions.RecurringTransferID != rule.ID || executions.Executions == nil {
+		t.Errorf("Expected an empty execution list, got %d %+v", rr.Code, executions)
+	}
+
+	for _, tc := range []struct {
+		method, url string
+		body        interface{}
+		code        int
+	}{
+		{"POST", "/recurring-transfers", models.CreateRecurringTransferRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", Schedule: "0 25 * * *"}, http.StatusBadRequest},
+		{"POST", "/recurring-transfers", models.CreateRecurringTransferRequest{SourceAccountID: 9, DestinationAccountID: 2, Amount: "1", Schedule: "@daily"}, http.StatusNotFound},
+		{"GET", path + "/executions?limit=0", nil, http.StatusBadRequest},
+		{"GET", "/recurring-transfers/x", nil, http.StatusBadRequest},
+		{"DELETE", path, nil, http.StatusNoContent},
+		{"GET", path, nil, http.StatusNotFound},
+		{"POST", path + "/pause", nil, http.StatusNotFound},
+	} {
+		if rr := do(tc.method, tc.url, tc.body); rr.Code != tc.code {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tc.method, tc.url, tc.code, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	NewMockHandler().ListRecurringTransfers(rr, httptest.NewRequest("GET", "/recurring-transfers", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d without a scheduler, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestHolds(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithHolds(store.Holds(), holds.Config{TTL: time.Hour, MaxTTL: 24 * time.Hour, ExpiryInterval: time.Minute})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/holds", handler.CreateHold).Methods("POST")
+	router.HandleFunc("/holds/{hold_id}", handler.GetHold).Methods("GET")
+	router.HandleFunc("/holds/{hold_id}/capture", handler.CaptureHold).Methods("POST")
+	router.HandleFunc("/holds/{hold_id}/release", handler.ReleaseHold).Methods("POST")
+	router.HandleFunc("/accounts/{account_id}/holds", handler.ListAccountHolds).Methods("GET")
+	router.HandleFunc("/accounts/{account_id}", handler.GetAccount).Methods("GET")
+	do := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, url, &payload))
+		return rr
+	}
+
+	rr := do("POST", "/holds", models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "80"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var hold models.HoldResponse
+	json.NewDecoder(rr.Body).Decode(&hold)
+	if hold.ID == 0 || hold.Amount != "80" || hold.Status != models.HoldActive || hold.ExpiresAt.IsZero() {
+		t.Errorf("Unexpected hold %+v", hold)
+	}
+	path := fmt.Sprintf("/holds/%d", hold.ID)
+
+	rr = do("GET", "/accounts/1", nil)
+	var account models.AccountResponse
+	json.NewDecoder(rr.Body).Decode(&account)
+	if account.Balance != "100" || account.Held != "80" || account.Available != "20" {
+		t.Errorf("Expected balance 100, held 80, available 20, got %+v", account)
+	}
+
+	rr = do("POST", path+"/capture", models.CaptureHoldRequest{Amount: "75"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	var capture models.CaptureHoldResponse
+	json.NewDecoder(rr.Body).Decode(&capture)
+	if capture.Hold.Status != models.HoldCaptured || capture.Transaction.Amount != "75" || capture.Hold.TransactionID != capture.Transaction.ID {
+		t.Errorf("Unexpected capture %+v", capture)
+	}
+
+	rr = do("POST", "/holds", models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10"})
+	var second models.HoldResponse
+	json.NewDecoder(rr.Body).Decode(&second)
+	secondPath := fmt.Sprintf("/holds/%d", second.ID)
+
+	rr = do("GET", "/accounts/1/holds", nil)
+	var list models.HoldListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || len(list.Holds) != 2 || list.Holds[0].ID != second.ID {
+		t.Errorf("Expected two holds newest first, got %d %+v", rr.Code, list)
+	}
+
+	for _, tc := range []struct {
+		method, url string
+		body        interface{}
+		code        int
+	}{
+		{"POST", "/holds", models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "50"}, http.StatusBadRequest},
+		{"POST", "/holds", models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 9, Amount: "1"}, http.StatusNotFound},
+		{"POST", path + "/capture", nil, http.StatusConflict},
+		{"POST", path + "/release", nil, http.StatusConflict},
+		{"POST", secondPath + "/capture", models.CaptureHoldRequest{Amount: "11"}, http.StatusBadRequest},
+		{"POST", secondPath + "/release", nil, http.StatusOK},
+		{"GET", "/holds/99", nil, http.StatusNotFound},
+		{"GET", "/holds/x", nil, http.StatusBadRequest},
+		{"GET", "/accounts/1/holds?limit=0", nil, http.StatusBadRequest},
+	} {
+		if rr := do(tc.method, tc.url, tc.body); rr.Code != tc.code {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tc.method, tc.url, tc.code, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	NewMockHandler().CreateHold(rr, httptest.NewRequest("POST", "/holds", strings.NewReader("{}")))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d without holds, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+func TestTransferLimits(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithLimits(store.Limits())
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}/limits", handler.GetAccountLimits).Methods("GET")
+	router.HandleFunc("/admin/accounts/{account_id}/limits", handler.SetAccountLimits).Methods("PUT")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := do("PUT", "/admin/accounts/1/limits", `{"max_amount": "30", "daily_count": 2}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	transfer := `{"source_account_id": 1, "destination_account_id": 2, "amount": "%s"}`
+	if rr := do("POST", "/transactions", fmt.Sprintf(transfer, "30.5")); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "per-transfer limit") {
+		t.Errorf("Expected 422 for the per-transfer limit, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for i := 0; i < 2; i++ {
+		if rr := do("POST", "/transactions", fmt.Sprintf(transfer, "10")); rr.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	if rr := do("POST", "/transactions", fmt.Sprintf(transfer, "10")); rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "daily transfer count limit") {
+		t.Errorf("Expected 422 for the daily count limit, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do("GET", "/accounts/1/limits", "")
+	var limits models.TransferLimitsResponse
+	json.NewDecoder(rr.Body).Decode(&limits)
+	if rr.Code != http.StatusOK || limits.MaxAmount == nil || *limits.MaxAmount != "30" || limits.DailyAmount != nil || limits.Usage.Amount != "20" || limits.Usage.Count != 2 {
+		t.Errorf("Unexpected limits %d %+v", rr.Code, limits)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"PUT", "/admin/accounts/1/limits", `{"max_amount": "-1"}`, http.StatusBadRequest},
+		{"PUT", "/admin/accounts/1/limits", `{`, http.StatusBadRequest},
+		{"PUT", "/admin/accounts/9/limits", `{}`, http.StatusNotFound},
+		{"GET", "/accounts/9/limits", "", http.StatusNotFound},
+		{"GET", "/accounts/x/limits", "", http.StatusBadRequest},
+	} {
+		if rr := do(tc.method, tc.path, tc.body); rr.Code != tc.want {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", tc.method, tc.path, tc.body, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := do("PUT", "/admin/accounts/1/limits", `{}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", "/transactions", fmt.Sprintf(transfer, "50")); rr.Code != http.StatusCreated {
+		t.Errorf("Expected removed limits to allow the transfer, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithAudit(audit.NewRecorder(store.Audit()))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts", handler.CreateAccount).Methods("POST")
+	router.HandleFunc("/accounts/{account_id}", handler.UpdateAccount).Methods("PATCH")
+	router.HandleFunc("/admin/accounts/{account_id}/freeze", handler.FreezeAccount).Methods("POST")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	router.HandleFunc("/admin/audit", handler.ListAuditEvents).Methods("GET")
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rr
+	}
+	list := func(query string) (*httptest.ResponseRecorder, models.AuditListResponse) {
+		rr := do("GET", "/admin/audit"+query, "")
+		var response models.AuditListResponse
+		json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		return rr, response
+	}
+
+	for _, step := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/accounts", `{"account_id": 1, "initial_balance": "100"}`, http.StatusCreated},
+		{"POST", "/accounts", `{"account_id": 2, "initial_balance": "0"}`, http.StatusCreated},
+		{"POST", "/accounts", `{"account_id": 2, "initial_balance": "0"}`, http.StatusConflict},
+		{"PATCH", "/accounts/1", `{"tags": ["payroll"]}`, http.StatusOK},
+		{"POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "25"}`, http.StatusCreated},
+		{"POST", "/admin/accounts/2/freeze", "", http.StatusOK},
+	} {
+		if rr := do(step.method, step.path, step.body); rr.Code != step.want {
+			t.Fatalf("%s %s: expected %d, got %d: %s", step.method, step.path, step.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr, page := list("")
+	if rr.Code != http.StatusOK || len(page.Events) != 5 || page.NextCursor != "" {
+		t.Fatalf("Expected 5 events, got %d %+v", rr.Code, page)
+	}
+	var actions []string
+	for _, event := range page.Events {
+		actions = append(actions, event.Actor+" "+event.Action)
+	}
+	want := []string{"admin account.frozen", "api_key:anonymous transaction.created", "api_key:anonymous account.updated",
+		"api_key:anonymous account.created", "api_key:anonymous account.created"}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected events %v, got %v", want, actions)
+	}
+	frozen := page.Events[0]
+	if !strings.Contains(string(frozen.Before), `"status":"active"`) || !strings.Contains(string(frozen.After), `"status":"frozen"`) {
+		t.Errorf("Expected before and after snapshots of the freeze, got %s -> %s", frozen.Before, frozen.After)
+	}
+	if transfer := page.Events[1]; transfer.TransactionID == 0 || string(transfer.Before) != "null" || !strings.Contains(string(transfer.After), `"amount":"25"`) {
+		t.Errorf("Unexpected transfer event %+v", transfer)
+	}
+
+	rr, page = list("?account_id=2&limit=2")
+	if rr.Code != http.StatusOK || len(page.Events) != 2 || page.Events[0].Action != models.AuditAccountFrozen || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page %d %+v", rr.Code, page)
+	}
+	// The cursor carries account_id, so it can be passed on its own
+	rr, page = list("?limit=2&cursor=" + page.NextCursor)
+	if rr.Code != http.StatusOK || len(page.Events) != 1 || page.Events[0].Action != models.AuditAccountCreated || page.NextCursor != "" {
+		t.Errorf("Unexpected last page %d %+v", rr.Code, page)
+	}
+	if _, page = list("?action=account.updated&actor=api_key:anonymous"); len(page.Events) != 1 || page.Events[0].AccountIDs[0] != 1 {
+		t.Errorf("Expected the update of account 1, got %+v", page)
+	}
+
+	for _, query := range []string{"?limit=0", "?account_id=x", "?from=yesterday", "?cursor=bogus"} {
+		if rr, _ := list(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+	rr = httptest.NewRecorder()
+	NewMockHandler().ListAuditEvents(rr, httptest.NewRequest("GET", "/admin/audit", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without an audit log, got %d", rr.Code)
+	}
+}
+
+func TestTransactionTrace(t *testing.T) {
+	store := memory.NewStore()
+	recorder := usage.NewRecorder(store.Usage(), nil)
+	// A validation budget no transfer can meet
+	budgets := sla.Budgets{models.LatencyStageValidate: time.Microsecond}
+	handler := NewHandlerWithStorage(store).WithUsage(recorder).
+		WithLatency(sla.NewRecorder(store.Latency(), time.Minute).WithBudgets(budgets)).
+		WithAudit(audit.NewRecorder(store.Audit())).WithSigning(store.APIKeys(), signing.Config{MaxAge: time.Hour})
+	partner := newTestSigner(t, handler, "partner")
+	router := mux.NewRouter()
+	router.Use(recorder.Middleware)
+	router.HandleFunc("/accounts", handler.Identified(handler.CreateAccount)).Methods("POST")
+	router.HandleFunc("/transactions", handler.Identified(handler.CreateTransaction)).Methods("POST")
+	router.HandleFunc("/admin/transactions/{transaction_id}/trace", handler.GetTransactionTrace).Methods("GET")
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		partner.sign(req, body)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	send("POST", "/accounts", `{"account_id": 1, "initial_balance": "100"}`)
+	send("POST", "/accounts", `{"account_id": 2, "initial_balance": "0"}`)
+	transfer := `{"source_account_id": 1, "destination_account_id": 2, "amount": "25", "reference": "INV-1"}`
+	rr := send("POST", "/transactions", transfer)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if timing := rr.Header().Get("Server-Timing"); strings.Count(timing, ";dur=") != 4 || !strings.HasPrefix(timing, "validate;dur=") {
+		t.Errorf("Expected the four stages in Server-Timing, got %q", timing)
+	}
+	if exceeded := rr.Header().Get("X-Latency-Budget-Exceeded"); exceeded != "validate" {
+		t.Errorf("Expected the validation to be over budget, got %q", exceeded)
+	}
+	// A client retrying after losing the response
+	if rr := send("POST", "/transactions", transfer); rr.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 for the retry, got %d", rr.Code)
+	}
+
+	rr = send("GET", "/admin/transactions/1/trace", "")
+	var trace models.TransferTraceResponse
+	json.NewDecoder(rr.Body).Decode(&trace)
+	if rr.Code != http.StatusOK || trace.Transaction.ID != 1 || trace.Transaction.Amount != "25" {
+		t.Fatalf("Unexpected trace %d %+v", rr.Code, trace)
+	}
+	timeline := trace.Timeline
+	if timeline == nil || timeline.ClientID != partner.key.ID || timeline.Retries != 1 || timeline.Rules == nil ||
+		timeline.ValidateMs > timeline.CommitMs || timeline.LockWaitMs > timeline.CommitMs || timeline.CommitMs > timeline.EmitMs {
+		t.Fatalf("Unexpected timeline %+v", timeline)
+	}
+	if len(timeline.Stages) != 4 || timeline.Stages[0].BudgetMs != 0.001 || !timeline.Stages[0].Exceeded || timeline.Stages[2].BudgetMs != 0 ||
+		strings.Join(timeline.BudgetExceeded, ",") != models.LatencyStageValidate {
+		t.Errorf("Unexpected stage budgets %+v %v", timeline.Stages, timeline.BudgetExceeded)
+	}
+	if len(trace.Audit) != 1 || trace.Audit[0].Action != models.AuditTransactionCreated || trace.Audit[0].TransactionID != 1 {
+		t.Errorf("Expected the transfer's audit event, got %+v", trace.Audit)
+	}
+	// The in-memory storage has no outbox
+	if len(trace.Events) != 0 || strings.Join(trace.Unavailable, ",") != models.TraceEvents {
+		t.Errorf("Expected only the events to be unavailable, got %+v %v", trace.Events, trace.Unavailable)
+	}
+
+	if rr := send("GET", "/admin/transactions/9/trace", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown transaction, got %d", rr.Code)
+	}
+	if rr := send("GET", "/admin/transactions/x/trace", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid ID, got %d", rr.Code)
+	}
+
+	// Without latency tracking or an audit log the transaction alone is traced
+	rr = httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/admin/transactions/1/trace", nil), map[string]string{"transaction_id": "1"})
+	NewHandlerWithStorage(store).GetTransactionTrace(rr, req)
+	if !strings.Contains(rr.Body.String(), `"timeline":null`) || !strings.Contains(rr.Body.String(), `"unavailable":["timeline","audit","events"]`) {
+		t.Errorf("Unexpected trace without tracking %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGetStatement(t *testing.T) {
+	handler := NewMockHandler()
+	for id, balance := range map[int64]string{1: "100", 2: "0"} {
+		body, _ := json.Marshal(models.CreateAccountRequest{AccountID: id, InitialBalance: balance})
+		handler.CreateAccount(httptest.NewRecorder(), httptest.NewRequest("POST", "/accounts", bytes.NewBuffer(body)))
+	}
+	// One transfer effective before the period, one in it
+	backdated := time.Now().Add(-72 * time.Hour).UTC()
+	for _, req := range []models.CreateTransactionRequest{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10", EffectiveAt: &backdated},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "40", Reference: "INV-7"},
+	} {
+		body, _ := json.Marshal(req)
+		rr := httptest.NewRecorder()
+		handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Failed to create transfer: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}/statement", handler.GetStatement).Methods("GET")
+	from := time.Now().Add(-48 * time.Hour).UTC().Format("2006-01-02")
+	to := time.Now().Add(48 * time.Hour).UTC().Format("2006-01-02")
+	period := "from=" + from + "&to=" + to
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts/1/statement?"+period, nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("Expected a CSV statement, got %d %s", rr.Code, rr.Body.String())
+	}
+	rows := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(rows) != 4 || !strings.HasSuffix(rows[1], ",Opening balance,,,,,,90") ||
+		!strings.HasSuffix(rows[2], ",INV-7,2,INV-7,,40,,50") || !strings.HasSuffix(rows[3], ",Closing balance,,,,40,0,50") {
+		t.Errorf("Unexpected statement:\n%s", rr.Body.String())
+	}
+	if want := `attachment; filename="statement_1_`; !strings.HasPrefix(rr.Header().Get("Content-Disposition"), want) {
+		t.Errorf("Expected an attachment, got %q", rr.Header().Get("Content-Disposition"))
+	}
+
+	req := httptest.NewRequest("GET", "/accounts/1/statement?"+period, nil)
+	req.Header.Set("Accept", "application/pdf")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(rr.Body.String(), "%PDF-") {
+		t.Errorf("Expected a PDF statement, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	for url, code := range map[string]int{
+		"/accounts/9/statement?" + period:                  http.StatusNotFound,
+		"/accounts/1/statement?from=" + from:               http.StatusBadRequest,
+		"/accounts/1/statement?from=" + to + "&to=" + from: http.StatusBadRequest,
+		"/accounts/1/statement?" + period + "&format=xlsx": http.StatusBadRequest,
+		"/accounts/1/statement?from=yesterday&to=" + to:    http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != code {
+			t.Errorf("%s: expected status %d, got %d", url, code, rr.Code)
+		}
+	}
+}
+
+func TestGetBalanceHistory(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithBalanceHistory(store.BalanceHistory())
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	for _, amount := range []int64{10, 20, 30} {
+		store.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(amount)})
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}/balance-history", handler.GetBalanceHistory).Methods("GET")
+	get := func(url string) (*httptest.ResponseRecorder, models.BalanceHistoryResponse) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		var response models.BalanceHistoryResponse
+		json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		return rr, response
+	}
+
+	rr, page := get("/accounts/1/balance-history?limit=3")
+	if rr.Code != http.StatusOK || len(page.Snapshots) != 3 || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page %d %+v", rr.Code, page)
+	}
+	if first := page.Snapshots[0]; first.Source != models.BalanceSnapshotOpened || first.Balance != "100" || first.TransactionID != 0 {
+		t.Errorf("Expected the opening balance first, got %+v", first)
+	}
+	if second := page.Snapshots[1]; second.Source != models.BalanceSnapshotTransfer || second.Balance != "90" || second.Sequence != 1 || second.TransactionID != 1 {
+		t.Errorf("Expected the balance left by the first transfer, got %+v", second)
+	}
+	rr, page = get("/accounts/1/balance-history?limit=3&cursor=" + page.NextCursor)
+	if rr.Code != http.StatusOK || len(page.Snapshots) != 1 || page.Snapshots[0].Balance != "40" || page.NextCursor != "" {
+		t.Errorf("Unexpected last page %d %+v", rr.Code, page)
+	}
+	if _, page = get("/accounts/2/balance-history?to=2000-01-01"); len(page.Snapshots) != 0 {
+		t.Errorf("Expected no snapshots before 2000, got %+v", page)
+	}
+
+	for _, tc := range []struct {
+		url  string
+		want int
+	}{
+		{"/accounts/9/balance-history", http.StatusNotFound},
+		{"/accounts/x/balance-history", http.StatusBadRequest},
+		{"/accounts/1/balance-history?limit=1001", http.StatusBadRequest},
+		{"/accounts/1/balance-history?from=yesterday", http.StatusBadRequest},
+	} {
+		if rr, _ := get(tc.url); rr.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.url, tc.want, rr.Code)
+		}
+	}
+	rr = httptest.NewRecorder()
+	NewMockHandler().GetBalanceHistory(rr, httptest.NewRequest("GET", "/accounts/1/balance-history", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a balance history, got %d", rr.Code)
+	}
+}
+
+func TestListAccounts_Cursor(t *testing.T) {
+	handler := NewMockHandler()
+	for id := int64(1); id <= 5; id++ {
+		handler.accountRepo.CreateAccount(models.NewAccount{AccountID: id, InitialBalance: decimal.NewFromInt(id * 10)})
+	}
+	list := func(query string) (*httptest.ResponseRecorder, models.AccountListResponse) {
+		rr := httptest.NewRecorder()
+		handler.ListAccounts(rr, httptest.NewRequest("GET", "/accounts"+query, nil))
+		var response models.AccountListResponse
+		json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		return rr, response
+	}
+
+	rr, page := list("?min_balance=20&limit=2")
+	if rr.Code != http.StatusOK || len(page.Accounts) != 2 || page.Accounts[0].AccountID != 2 || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page %d %+v", rr.Code, page)
+	}
+	// The cursor carries min_balance, so it can be passed on its own
+	rr, page = list("?limit=2&cursor=" + page.NextCursor)
+	if rr.Code != http.StatusOK || len(page.Accounts) != 2 || page.Accounts[0].AccountID != 4 || rr.Header().Get(TotalCountHeader) != "4" {
+		t.Fatalf("Unexpected second page %d %+v", rr.Code, page)
+	}
+	cursor := page.NextCursor
+	if rr, page = list("?limit=2&min_balance=20&cursor=" + cursor); rr.Code != http.StatusOK || len(page.Accounts) != 0 || page.NextCursor != "" {
+		t.Errorf("Expected an empty last page, got %d %+v", rr.Code, page)
+	}
+
+	for _, query := range []string{
+		"?min_balance=30&cursor=" + cursor,
+		"?status=active&cursor=" + cursor,
+		"?offset=1&cursor=" + cursor,
+		"?cursor=" + cursor[:len(cursor)-2],
+		"?cursor=" + NewMockHandler().cursors.Encode("list_accounts", nil, accountCursor{AccountID: 1}),
+	} {
+		if rr, _ := list(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", query, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestListAccountHolds_Cursor(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithHolds(store.Holds(), holds.Config{TTL: time.Hour, MaxTTL: 24 * time.Hour, ExpiryInterval: time.Minute})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100)})
+	for i := 0; i < 3; i++ {
+		handler.Holds().Create(context.Background(), models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1"})
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}/holds", handler.ListAccountHolds).Methods("GET")
+	list := func(url string) (*httptest.ResponseRecorder, models.HoldListResponse) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		var response models.HoldListResponse
+		json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		return rr, response
+	}
+
+	_, page := list("/accounts/1/holds?limit=2")
+	if len(page.Holds) != 2 || page.Holds[0].ID != 3 || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page %+v", page)
+	}
+	if rr, next := list("/accounts/1/holds?limit=2&cursor=" + page.NextCursor); len(next.Holds) != 1 || next.Holds[0].ID != 1 || next.NextCursor != "" {
+		t.Errorf("Unexpected second page %d %+v", rr.Code, next)
+	}
+	if rr, _ := list("/accounts/2/holds?cursor=" + page.NextCursor); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a cursor of another account to be refused, got %d", rr.Code)
+	}
+}
+
+func TestTransactionReferences(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store)
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100)})
+	router := mux.NewRouter()
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	router.HandleFunc("/accounts/{account_id}/transactions", handler.SearchAccountTransactions).Methods("GET")
+	transfer := func(source, destination int64, reference, memo string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.CreateTransactionRequest{SourceAccountID: source, DestinationAccountID: destination, Amount: "1", Reference: reference, Memo: memo})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+		return rr
+	}
+	search := func(url string) (*httptest.ResponseRecorder, models.TransactionListResponse) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		var response models.TransactionListResponse
+		json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		return rr, response
+	}
+
+	rr := transfer(1, 2, "INV-1001", "March rent")
+	var created models.TransactionResponse
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || created.Reference != "INV-1001" || created.Memo != "March rent" {
+		t.Fatalf("Unexpected response %d %+v", rr.Code, created)
+	}
+	if rr := transfer(1, 2, "INV-1001", ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a reused reference, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// References are unique per source account only
+	if rr := transfer(2, 1, "INV-1001", "Refund of march rent"); rr.Code != http.StatusCreated {
+		t.Errorf("Expected another account to reuse the reference, got %d: %s", rr.Code, rr.Body.String())
+	}
+	transfer(1, 2, "", "")
+	if rr := transfer(1, 2, " INV-1002", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a reference with surrounding whitespace, got %d", rr.Code)
+	}
+	if rr := transfer(1, 2, "", strings.Repeat("x", models.MaxMemoLength+1)); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a long memo, got %d", rr.Code)
+	}
+
+	if _, found := search("/accounts/2/transactions?reference=INV-1001"); len(found.Transactions) != 2 {
+		t.Errorf("Expected both transfers with the reference, got %+v", found)
+	}
+	if _, found := search("/accounts/1/transactions?memo=RENT"); len(found.Transactions) != 2 || found.Transactions[0].Memo != "Refund of march rent" {
+		t.Errorf("Expected memo search to ignore case, got %+v", found)
+	}
+	if _, found := search("/accounts/1/transactions?memo=%25"); len(found.Transactions) != 0 {
+		t.Errorf("Expected wildcards to match literally, got %+v", found)
+	}
+
+	_, page := search("/accounts/1/transactions?limit=2")
+	if len(page.Transactions) != 2 || page.Transactions[0].ID != 3 || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page %+v", page)
+	}
+	if _, next := search("/accounts/1/transactions?limit=2&cursor=" + page.NextCursor); len(next.Transactions) != 1 || next.Transactions[0].ID != 1 {
+		t.Errorf("Unexpected second page %+v", next)
+	}
+	// Cursors issued while the listing was ordered by ID alone continue below their ID
+	legacy := handler.cursors.Encode("search_account_transactions", url.Values{"account_id": {"1"}}, idCursor{ID: 2})
+	if _, next := search("/accounts/1/transactions?cursor=" + legacy); len(next.Transactions) != 1 || next.Transactions[0].ID != 1 {
+		t.Errorf("Unexpected page of a legacy cursor %+v", next)
+	}
+	if rr, _ := search("/accounts/1/transactions?memo=rent&cursor=" + page.NextCursor); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a cursor of another search to be refused, got %d", rr.Code)
+	}
+	if rr, _ := search("/accounts/9/transactions"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown account, got %d", rr.Code)
+	}
+}
+
+func TestSearchAccountTransactions_Filters(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store)
+	for id := int64(1); id <= 3; id++ {
+		store.Accounts().CreateAccount(models.NewAccount{AccountID: id, InitialBalance: decimal.NewFromInt(100)})
+	}
+	for _, transfer := range []struct{ source, destination, amount int64 }{{1, 2, 5}, {2, 1, 20}, {1, 3, 50}} {
+		store.Transactions().CreateTransaction(models.Transfer{SourceAccountID: transfer.source, DestinationAccountID: transfer.destination, Amount: decimal.NewFromInt(transfer.amount)})
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts/{account_id}/transactions", handler.SearchAccountTransactions).Methods("GET")
+	search := func(url string) (*httptest.ResponseRecorder, models.TransactionListResponse) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		var response models.TransactionListResponse
+		json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		return rr, response
+	}
+	ids := func(response models.TransactionListResponse) string {
+		ids := []int64{}
+		for _, t := range response.Transactions {
+			ids = append(ids, t.ID)
+		}
+		return fmt.Sprint(ids)
+	}
+
+	for query, want := range map[string]string{
+		"direction=outgoing":                "[3 1]",
+		"direction=incoming":                "[2]",
+		"counterparty_id=2":                 "[2 1]",
+		"min_amount=20":                     "[3 2]",
+		"min_amount=10&max_amount=20":       "[2]",
+		"order=oldest":                      "[1 2 3]",
+		"direction=outgoing&order=oldest":   "[1 3]",
+		"created_from=2000-01-01":           "[3 2 1]",
+		"created_before=2000-01-01":         "[]",
+		"counterparty_id=3&min_amount=60":   "[]",
+		"order=newest&counterparty_id=3":    "[3]",
+		"direction=incoming&min_amount=0.5": "[2]",
+	} {
+		if rr, found := search("/accounts/1/transactions?" + query); rr.Code != http.StatusOK || ids(found) != want {
+			t.Errorf("%s: expected %s, got %d %s", query, want, rr.Code, ids(found))
+		}
+	}
+
+	_, page := search("/accounts/1/transactions?order=oldest&limit=2")
+	if ids(page) != "[1 2]" || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page %+v", page)
+	}
+	if _, next := search("/accounts/1/transactions?cursor=" + page.NextCursor); ids(next) != "[3]" {
+		t.Errorf("Expected the cursor to keep the order, got %s", ids(next))
+	}
+	if rr, _ := search("/accounts/1/transactions?order=newest&cursor=" + page.NextCursor); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a cursor of another order to be refused, got %d", rr.Code)
+	}
+
+	for _, query := range []string{
+		"direction=sideways", "order=largest", "counterparty_id=0", "min_amount=ten",
+		"min_amount=20&max_amount=10", "created_from=yesterday",
+	} {
+		if rr, _ := search("/accounts/1/transactions?" + query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestSearchTransactions(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store)
+	for id := int64(1); id <= 4; id++ {
+		store.Accounts().CreateAccount(models.NewAccount{AccountID: id, InitialBalance: decimal.NewFromInt(1000)})
+	}
+	for _, transfer := range []models.Transfer{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5), Reference: "INV-1", Memo: "March rent"},
+		{SourceAccountID: 3, DestinationAccountID: 4, Amount: decimal.NewFromInt(250), Reference: "INV-2", Memo: "Disputed card payment"},
+		{SourceAccountID: 2, DestinationAccountID: 3, Amount: decimal.NewFromInt(250), Memo: "Refund of the card payment"},
+	} {
+		store.Transactions().CreateTransaction(transfer)
+	}
+	search := func(query string) (*httptest.ResponseRecorder, models.TransactionSearchResponse) {
+		rr := httptest.NewRecorder()
+		handler.SearchTransactions(rr, httptest.NewRequest("GET", "/transactions/search?"+query, nil))
+		var response models.TransactionSearchResponse
+		json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		return rr, response
+	}
+	ids := func(response models.TransactionSearchResponse) string {
+		ids := []int64{}
+		for _, t := range response.Transactions {
+			ids = append(ids, t.ID)
+		}
+		return fmt.Sprint(ids)
+	}
+
+	for query, want := range map[string]string{
+		"account_id=1&account_id=4":           "[2 1]",
+		"reference=INV-1&reference=INV-2":     "[2 1]",
+		"text=card+PAYMENT":                   "[3 2]",
+		"text=card&account_id=2":              "[3]",
+		"min_amount=250&max_amount=250":       "[3 2]",
+		"min_amount=100&reference=INV-1":      "[]",
+		"created_from=2000-01-01&text=refund": "[3]",
+	} {
+		if rr, found := search(query); rr.Code != http.StatusOK || ids(found) != want {
+			t.Errorf("%s: expected %s, got %d %s", query, want, rr.Code, ids(found))
+		}
+	}
+
+	_, page := search("min_amount=1&limit=2")
+	if ids(page) != "[3 2]" || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page %+v", page)
+	}
+	if _, next := search("cursor=" + page.NextCursor); ids(next) != "[1]" || next.NextCursor != "" {
+		t.Errorf("Unexpected second page %+v", next)
+	}
+	if rr, _ := search("min_amount=2&cursor=" + page.NextCursor); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a cursor of another search to be refused, got %d", rr.Code)
+	}
+
+	tooMany := strings.Repeat("account_id=1&", maxSearchValues+1)
+	for _, query := range []string{"", "limit=10", "text=%2C%2C", "account_id=x", "min_amount=ten", "min_amount=5&max_amount=1", "created_before=soon", tooMany} {
+		if rr, _ := search(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestCreateTransaction_DependsOn(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store)
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.Zero})
+	transfer := func(req models.CreateTransactionRequest) (*httptest.ResponseRecorder, models.TransactionResponse) {
+		body, _ := json.Marshal(req)
+		rr := httptest.NewRecorder()
+		handler.CreateTransaction(rr, httptest.NewRequest("POST", "/transactions", bytes.NewBuffer(body)))
+		var response models.TransactionResponse
+		json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+		return rr, response
+	}
+
+	// Fund, then pay out
+	_, funding := transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "50"})
+	rr, payout := transfer(models.CreateTransactionRequest{SourceAccountID: 2, DestinationAccountID: 3, Amount: "20", DependsOn: funding.ID})
+	if rr.Code != http.StatusCreated || payout.DependsOn != funding.ID {
+		t.Fatalf("Expected the payout chained after the funding, got %d %+v", rr.Code, payout)
+	}
+
+	if rr, _ := transfer(models.CreateTransactionRequest{SourceAccountID: 2, DestinationAccountID: 3, Amount: "1", DependsOn: 99}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown dependency, got %d", rr.Code)
+	}
+	if rr, _ := transfer(models.CreateTransactionRequest{SourceAccountID: 2, DestinationAccountID: 3, Amount: "1", DependsOn: -1}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a negative dependency, got %d", rr.Code)
+	}
+
+	// A reversed dependency fails the transfers chained after it
+	if _, err := memory.NewSettlementRepository(store).ReturnTransaction(context.Background(), funding.ID, time.Time{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rr, _ := transfer(models.CreateTransactionRequest{SourceAccountID: 2, DestinationAccountID: 3, Amount: "1", DependsOn: funding.ID}); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a reversed dependency, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithReconciler(reconcile.NewReconciler(store.Reconciliation()))
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	store.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(40)})
+
+	rr := httptest.NewRecorder()
+	handler.Reconcile(rr, httptest.NewRequest("GET", "/admin/reconciliation", nil))
+	var report models.ReconciliationResponse
+	json.NewDecoder(rr.Body).Decode(&report)
+	if rr.Code != http.StatusOK || report.Accounts != 2 || !report.Balanced || report.Discrepancies == nil || len(report.Discrepancies) != 0 {
+		t.Errorf("Unexpected report %d %+v", rr.Code, report)
+	}
+
+	rr = httptest.NewRecorder()
+	NewMockHandler().Reconcile(rr, httptest.NewRequest("GET", "/admin/reconciliation", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a reconciler, got %d", rr.Code)
+	}
+}
+
+// staticRetentionRepository reports a fixed number of expired rows per data class
+type staticRetentionRepository map[string]int64
+
+func (r staticRetentionRepository) CountExpired(ctx context.Context, class string, before time.Time) (int64, error) {
+	return r[class], nil
+}
+
+func (r staticRetentionRepository) DeleteExpired(ctx context.Context, class string, before time.Time, limit int) (int64, error) {
+	return 0, errors.New("unexpected delete")
+}
+
+func TestRetentionReport(t *testing.T) {
+	repo := staticRetentionRepository{models.RetentionAuditEvents: 12}
+	handler := NewMockHandler().WithRetention(retention.NewEnforcer(repo, retention.Config{AuditEvents: 90 * 24 * time.Hour}))
+
+	rr := httptest.NewRecorder()
+	handler.RetentionReport(rr, httptest.NewRequest("GET", "/admin/retention", nil))
+	var report models.RetentionReportResponse
+	json.NewDecoder(rr.Body).Decode(&report)
+	if rr.Code != http.StatusOK || !report.DryRun || len(report.Policies) != 3 {
+		t.Fatalf("Unexpected report %d %+v", rr.Code, report)
+	}
+	audit := report.Policies[1]
+	if audit.Class != models.RetentionAuditEvents || audit.RetentionDays != 90 || audit.KeepForever || audit.Eligible != 12 || audit.Cutoff == nil {
+		t.Errorf("Unexpected audit policy %+v", audit)
+	}
+	if transactions := report.Policies[0]; !transactions.KeepForever || transactions.Enforceable || transactions.Cutoff != nil {
+		t.Errorf("Unexpected transactions policy %+v", transactions)
+	}
+
+	rr = httptest.NewRecorder()
+	NewMockHandler().RetentionReport(rr, httptest.NewRequest("GET", "/admin/retention", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without retention, got %d", rr.Code)
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	store := memory.NewStore()
+	objects, err := attachments.NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := attachments.Config{MaxSize: 64, ContentTypes: attachments.DefaultContentTypes}
+	handler := NewHandlerWithStorage(store).WithAttachments(attachments.NewManager(store.Attachments(), objects, config))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts", handler.CreateAccount).Methods("POST")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	router.HandleFunc("/admin/transactions/{transaction_id}/attachments", handler.UploadAttachment).Methods("POST")
+	router.HandleFunc("/admin/transactions/{transaction_id}/attachments", handler.ListAttachments).Methods("GET")
+	router.HandleFunc("/admin/transactions/{transaction_id}/attachments/{attachment_id}", handler.DownloadAttachment).Methods("GET")
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rr
+	}
+	do("POST", "/accounts", `{"account_id": 1, "initial_balance": "100"}`)
+	do("POST", "/accounts", `{"account_id": 2, "initial_balance": "0"}`)
+	if rr := do("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "25"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Failed to create transfer: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// "%PDF-1.4\n%%EOF\n" base64 encoded
+	upload := `{"filename": "invoice.pdf", "content_type": "application/pdf", "content": "JVBERi0xLjQKJSVFT0YK"}`
+	for _, tc := range []struct {
+		name, path, body string
+		want             int
+	}{
+		{"unknown transaction", "/admin/transactions/99/attachments", upload, http.StatusNotFound},
+		{"invalid base64", "/admin/transactions/1/attachments", `{"filename": "a.pdf", "content_type": "application/pdf", "content": "%%%"}`, http.StatusBadRequest},
+		{"type not allowed", "/admin/transactions/1/attachments", `{"filename": "a.txt", "content_type": "text/plain", "content": "bm90ZXM="}`, http.StatusUnsupportedMediaType},
+		{"too large", "/admin/transactions/1/attachments", `{"filename": "a.pdf", "content_type": "application/pdf", "content": "` + strings.Repeat("JVBERi0x", 12) + `"}`, http.StatusRequestEntityTooLarge},
+	} {
+		if rr := do("POST", tc.path, tc.body); rr.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := do("POST", "/admin/transactions/1/attachments", upload)
+	var attachment models.AttachmentResponse
+	json.NewDecoder(rr.Body).Decode(&attachment)
+	if rr.Code != http.StatusCreated || attachment.ID == 0 || attachment.Size != 15 {
+		t.Fatalf("Expected the attachment to be created, got %d %+v", rr.Code, attachment)
+	}
+
+	rr = do("GET", "/admin/transactions/1/attachments", "")
+	var list models.AttachmentListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if rr.Code != http.StatusOK || len(list.Attachments) != 1 || list.Attachments[0].SHA256 != attachment.SHA256 {
+		t.Errorf("Expected one attachment, got %d %+v", rr.Code, list)
+	}
+
+	rr = do("GET", fmt.Sprintf("/admin/transactions/1/attachments/%d", attachment.ID), "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "%PDF-1.4\n%%EOF\n" || rr.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("Expected the document, got %d %q", rr.Code, rr.Body.String())
+	}
+	if want := `attachment; filename="invoice.pdf"`; rr.Header().Get("Content-Disposition") != want {
+		t.Errorf("Expected %q, got %q", want, rr.Header().Get("Content-Disposition"))
+	}
+	if rr := do("GET", "/admin/transactions/1/attachments/99", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown attachment, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	NewMockHandler().ListAttachments(rr, httptest.NewRequest("GET", "/admin/transactions/1/attachments", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without attachments, got %d", rr.Code)
+	}
+}
+
+func TestGetAccountEvents(t *testing.T) {
+	store, err := eventsource.Open(filepath.Join(t.TempDir(), "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	handler := NewHandlerWithStorage(store)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts", handler.CreateAccount).Methods("POST")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	router.HandleFunc("/accounts/{account_id}/events", handler.GetAccountEvents).Methods("GET")
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rr
+	}
+	do("POST", "/accounts", `{"account_id": 1, "initial_balance": "100"}`)
+	do("POST", "/accounts", `{"account_id": 2, "initial_balance": "0"}`)
+	for _, amount := range []string{"10", "20.5"} {
+		if rr := do("POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "`+amount+`"}`); rr.Code != http.StatusCreated {
+			t.Fatalf("Failed to create transfer: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := do("GET", "/accounts/1/events?limit=2", "")
+	var page models.AccountEventListResponse
+	json.NewDecoder(rr.Body).Decode(&page)
+	if rr.Code != http.StatusOK || len(page.Events) != 2 || !page.HasMore || page.NextCursor == "" || page.LastSequence != 3 {
+		t.Fatalf("Expected a first page of 2 events, got %d %+v", rr.Code, page)
+	}
+	if opened := page.Events[0]; opened.Type != models.LedgerAccountOpened || opened.Balance != "100" {
+		t.Errorf("Unexpected opening event %+v", opened)
+	}
+
+	rr = do("GET", "/accounts/1/events?cursor="+url.QueryEscape(page.NextCursor), "")
+	page = models.AccountEventListResponse{}
+	json.NewDecoder(rr.Body).Decode(&page)
+	if rr.Code != http.StatusOK || len(page.Events) != 1 || page.HasMore || page.Events[0].Balance != "69.5" || page.Events[0].Transaction == nil {
+		t.Errorf("Expected the last transfer with balance 69.5, got %d %+v", rr.Code, page)
+	}
+
+	for path, want := range map[string]int{
+		"/accounts/9/events":              http.StatusNotFound,
+		"/accounts/1/events?after_seq=-1": http.StatusBadRequest,
+		"/accounts/1/events?limit=0":      http.StatusBadRequest,
+	} {
+		if rr := do("GET", path, ""); rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, rr.Code)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	NewMockHandler().GetAccountEvents(rr, httptest.NewRequest("GET", "/accounts/1/events", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without event-sourced storage, got %d", rr.Code)
+	}
+}
+
+func TestResponseCodec(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/plain", "application/json"},
+		{"application/xml", "application/xml"},
+		{"text/xml", "application/xml"},
+		{"application/json;q=0.5, application/xml", "application/xml"},
+		{"application/xml;q=0.2, application/*;q=0.8", "application/json"},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", "application/xml"},
+	} {
+		req := httptest.NewRequest("GET", "/accounts/1", nil)
+		req.Header.Set("Accept", tc.accept)
+		if got := responseCodec(req).MediaTypes()[0]; got != tc.want {
+			t.Errorf("Accept %q: expected %s, got %s", tc.accept, tc.want, got)
+		}
+	}
+}
+
+func TestXMLContentNegotiation(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store)
+	router := mux.NewRouter()
+	router.HandleFunc("/accounts", handler.CreateAccount).Methods("POST")
+	router.HandleFunc("/accounts/{account_id}", handler.GetAccount).Methods("GET")
+	router.HandleFunc("/accounts/{account_id}", handler.UpdateAccount).Methods("PATCH")
+	router.HandleFunc("/accounts/{account_id}/transactions", handler.SearchAccountTransactions).Methods("GET")
+	router.HandleFunc("/transactions", handler.CreateTransaction).Methods("POST")
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+		req.Header.Set("Accept", "application/xml")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, body := range []string{
+		`<account><account_id>1</account_id><initial_balance>100.00</initial_balance></account>`,
+		`<?xml version="1.0"?><request><account_id>2</account_id><initial_balance>0</initial_balance></request>`,
+	} {
+		if rr := send("POST", "/accounts", body); rr.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+	rr := send("PATCH", "/accounts/1", `<account><metadata><cost_center>CC-42</cost_center><entry key="owner name">Ada</entry></metadata><tags><item>payroll</item></tags></account>`)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/xml" || rr.Header().Get("ETag") == "" {
+		t.Fatalf("Expected 200 with an XML account, got %d %q: %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	var account struct {
+		XMLName   xml.Name `xml:"account"`
+		AccountID int64    `xml:"account_id"`
+		Balance   string   `xml:"balance"`
+		Tags      []string `xml:"tags>item"`
+		Metadata  struct {
+			CostCenter string `xml:"cost_center"`
+			Entries    []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"entry"`
+		} `xml:"metadata"`
+	}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &account); err != nil {
+		t.Fatalf("Failed to decode XML account: %v", err)
+	}
+	if account.AccountID != 1 || account.Balance != "100" || !reflect.DeepEqual(account.Tags, []string{"payroll"}) ||
+		account.Metadata.CostCenter != "CC-42" || len(account.Metadata.Entries) != 1 || account.Metadata.Entries[0].Value != "Ada" {
+		t.Errorf("Unexpected XML account %+v", account)
+	}
+
+	rr = send("POST", "/transactions", `<transfer><source_account_id>1</source_account_id><destination_account_id>2</destination_account_id><amount>25.50</amount><memo> rent </memo></transfer>`)
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), "<transaction><id>") || !strings.Contains(rr.Body.String(), "<memo> rent </memo>") {
+		t.Fatalf("Expected 201 with an XML transaction, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = send("GET", "/accounts/2/transactions", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<transactions><item><id>") {
+		t.Errorf("Expected an XML transaction listing, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Field violations are reported in the negotiated format
+	rr = send("POST", "/transactions", `<transfer><source_account_id>1</source_account_id><destination_account_id>1</destination_account_id></transfer>`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "<validation_error><error>") {
+		t.Errorf("Expected an XML validation error, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, tc := range []struct{ path, body string }{
+		{"/accounts", ""},
+		{"/accounts", "<account>"},
+		{"/accounts", `<account><account_id>one</account_id><initial_balance>1</initial_balance></account>`},
+		{"/transactions", `<transfer><source_account_id>1</source_account_id><destination_account_id>2</destination_account_id><amount>1</amount><convert>maybe</convert></transfer>`},
+	} {
+		if rr := send("POST", tc.path, tc.body); rr.Code != http.StatusBadRequest || strings.Contains(rr.Body.String(), "<transaction>") {
+			t.Errorf("%s %q: expected 400, got %d", tc.path, tc.body, rr.Code)
+		}
+	}
+
+	// JSON stays the default
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/accounts/1", nil))
+	if rr.Header().Get("Content-Type") != "application/json" || rr.Header().Get("Vary") != "Accept" {
+		t.Errorf("Expected a JSON account varying by Accept, got %q", rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestAPIKeys_SignedTransactions(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandlerWithStorage(store).WithSigning(store.APIKeys(), signing.Config{Required: true})
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/api-keys", handler.CreateAPIKey).Methods("POST")
+	router.HandleFunc("/admin/api-keys", handler.ListAPIKeys).Methods("GET")
+	router.HandleFunc("/admin/api-keys/{key_id}/revoke", handler.RevokeAPIKey).Methods("POST")
+	router.HandleFunc("/transactions", handler.Signed(handler.CreateTransaction)).Methods("POST")
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100)})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/api-keys", strings.NewReader(`{"description": "batch"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var key models.CreateAPIKeyResponse
+	json.NewDecoder(rr.Body).Decode(&key)
+	if key.ID == "" || key.Secret == "" || key.Description != "batch" {
+		t.Fatalf("Unexpected key %+v", key)
+	}
+	transfer := func(reference string, sign bool) *httptest.ResponseRecorder {
+		body := []byte(`{"source_account_id": 1, "destination_account_id": 2, "amount": "5", "reference": "` + reference + `"}`)
+		req := httptest.NewRequest("POST", "/transactions", bytes.NewReader(body))
+		if sign {
+			signing.SignRequest(req, key.ID, key.Secret, body, time.Now())
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The handler reads the body the signature covered
+	if rr := transfer("INV-1", true); rr.Code != http.StatusCreated {
+		t.Errorf("Expected a signed transfer to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := transfer("INV-2", false); rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected an unsigned transfer to be refused, got %d", rr.Code)
+	}
+
+	// A key restricted to networks is refused from elsewhere (httptest's client is 192.0.2.1)
+	handler.WithAllowlist(middleware.Allowlist{Keys: map[string][]netip.Prefix{key.ID: {netip.MustParsePrefix("10.1.0.0/16")}}})
+	if rr := transfer("INV-4", true); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected a signed transfer from outside the key's networks to be refused, got %d", rr.Code)
+	}
+	handler.WithAllowlist(middleware.Allowlist{Keys: map[string][]netip.Prefix{key.ID: {netip.MustParsePrefix("192.0.2.0/24")}}})
+	if rr := transfer("INV-5", true); rr.Code != http.StatusCreated {
+		t.Errorf("Expected a signed transfer from the key's networks to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/api-keys/"+key.ID+"/revoke", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"revoked_at":"`) || strings.Contains(rr.Body.String(), key.Secret) {
+		t.Errorf("Expected the revoked key without its secret, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := transfer("INV-3", true); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked key to be refused, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/api-keys/ak_0/revoke", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown key, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/api-keys", nil))
+	var list models.APIKeyListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.APIKeys) != 1 || list.APIKeys[0].RevokedAt == nil || strings.Contains(rr.Body.String(), key.Secret) {
+		t.Errorf("Expected the revoked key without its secret, got %s", rr.Body.String())
+	}
+}
+
+func TestCheckAllowlist(t *testing.T) {
+	store := memory.NewStore()
+	ctx := context.Background()
+	manager := signing.NewManager(store.APIKeys(), signing.Config{})
+	active, _ := manager.Create(ctx, models.CreateAPIKeyRequest{Description: "batch"})
+	revoked, _ := manager.Create(ctx, models.CreateAPIKeyRequest{Description: "retired"})
+	manager.Revoke(ctx, revoked.ID)
+	networks := []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")}
+	restrict := func(keyIDs ...string) middleware.Allowlist {
+		allowlist := middleware.Allowlist{Keys: make(map[string][]netip.Prefix)}
+		for _, keyID := range keyIDs {
+			allowlist.Keys[keyID] = networks
+		}
+		return allowlist
+	}
+
+	handler := NewHandlerWithStorage(store).WithSigning(store.APIKeys(), signing.Config{})
+	if err := handler.WithAllowlist(restrict(active.ID)).CheckAllowlist(ctx); err != nil {
+		t.Errorf("Expected the ranges of a signing key to be accepted, got %v", err)
+	}
+	err := handler.WithAllowlist(restrict(active.ID, revoked.ID, "ak_unknown")).CheckAllowlist(ctx)
+	if err == nil || !strings.Contains(err.Error(), revoked.ID) || !strings.Contains(err.Error(), "ak_unknown") ||
+		strings.Contains(err.Error(), active.ID) {
+		t.Errorf("Expected the revoked and unknown keys refused, got %v", err)
+	}
+
+	// Without signing no key can sign
+	if err := NewHandlerWithStorage(store).WithAllowlist(restrict(active.ID)).CheckAllowlist(ctx); err == nil {
+		t.Error("Expected key ranges refused without signing")
+	}
+	if err := NewHandlerWithStorage(store).WithAllowlist(middleware.Allowlist{}).CheckAllowlist(ctx); err != nil {
+		t.Errorf("Expected no key ranges to pass, got %v", err)
+	}
 }
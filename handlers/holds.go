@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/database"
+	"internal-transfers/holds"
+	"internal-transfers/middleware"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// Hold listing limits
+const (
+	defaultHoldsLimit = 100
+	maxHolds          = 1000
+)
+
+// WithHolds attaches the repository of authorization holds; captures are submitted through the
+// handler's transfer service, so they follow its rules, cut-offs and FX settings
+// Expired holds are only released once the expiry loop runs, e.g. go h.Holds().Run(ctx, interval)
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithHolds(repo database.HoldRepositoryInterface, config holds.Config) *Handler {
+	h.holds = holds.NewManager(repo, h.transfers, config)
+	return h
+}
+
+// Holds returns the hold manager, or nil if none is attached
+func (h *Handler) Holds() *holds.Manager {
+	return h.holds
+}
+
+// CreateHold handles POST /holds endpoint reserving funds for a later transfer
+// Request body: JSON with source_account_id, destination_account_id, amount, optional
+// transfer_type and optional expires_at (defaults to HOLD_TTL from now, at most HOLD_MAX_TTL)
+//   - The amount stops counting in the source's available balance; its balance is unchanged
+//   - The capture evaluates the validation rules of the X-Tenant-ID tenant that created the hold
+//
+// Response: 201 Created with the active hold, 400 for an invalid request or insufficient available
+// balance, 404 if an account does not exist, 423 if the source is frozen, 503 if holds are unavailable
+// Example request: {"source_account_id": 123, "destination_account_id": 456, "amount": "80.00"}
+func (h *Handler) CreateHold(w http.ResponseWriter, r *http.Request) {
+	if h.holds == nil {
+		http.Error(w, "Holds unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var req models.CreateHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	req.Tenant = tenant(r)
+	middleware.LogAccounts(r.Context(), req.SourceAccountID, req.DestinationAccountID)
+
+	hold, err := h.holds.Create(r.Context(), req)
+	if err != nil {
+		writeTransferError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.NewHoldResponse(*hold))
+}
+
+// GetHold handles GET /holds/{hold_id} endpoint
+// Response: 200 OK with the hold, 404 if it does not exist
+func (h *Handler) GetHold(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.holdID(w, r)
+	if !ok {
+		return
+	}
+	hold, err := h.holds.Get(r.Context(), id)
+	if err != nil {
+		writeHoldError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NewHoldResponse(*hold))
+}
+
+// CaptureHold handles POST /holds/{hold_id}/capture endpoint booking the held transfer
+// Request body (optional): {"amount": "50.00"} to capture part of the hold; the rest is released.
+// Without a body the whole hold is captured
+// Response: 201 Created with the captured hold and the booked transaction; 404 if the hold does
+// not exist, 409 if it is no longer active or has expired, 400 if the amount exceeds the hold,
+// and otherwise the same errors as POST /transactions
+func (h *Handler) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.holdID(w, r)
+	if !ok {
+		return
+	}
+	var req models.CaptureHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeBodyError(w, err)
+		return
+	}
+
+	hold, transaction, err := h.holds.Capture(r.Context(), id, req.Amount)
+	if err != nil {
+		writeTransferError(w, r, err)
+		return
+	}
+	h.recordTransfer(r.Context(), transaction)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CaptureHoldResponse{
+		Hold:        models.NewHoldResponse(*hold),
+		Transaction: models.NewTransactionResponse(*transaction),
+	})
+}
+
+// ReleaseHold handles POST /holds/{hold_id}/release endpoint freeing the held funds
+// Response: 200 OK with the released hold, 404 if it does not exist, 409 if it is no longer active
+func (h *Handler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.holdID(w, r)
+	if !ok {
+		return
+	}
+	hold, err := h.holds.Release(r.Context(), id)
+	if err != nil {
+		writeHoldError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NewHoldResponse(*hold))
+}
+
+// ListAccountHolds handles GET /accounts/{account_id}/holds endpoint
+// Query parameters:
+//   - limit (1-1000, default 100): Maximum number of holds returned
+//   - cursor: next_cursor of the previous page
+//
+// Response: 200 OK with the holds on the account as source, newest first, in every status, and
+// next_cursor while the page is full; 400 for invalid parameters or a cursor of another account
+// Example response: {"account_id": 123, "holds": [{"id": 9, "amount": "80", "status": "active", ...}]}
+func (h *Handler) ListAccountHolds(w http.ResponseWriter, r *http.Request) {
+	if h.holds == nil {
+		http.Error(w, "Holds unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	limit := defaultHoldsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxHolds {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxHolds), http.StatusBadRequest)
+			return
+		}
+	}
+	var before idCursor
+	filters, ok := h.listingFilters(w, r, "list_account_holds", url.Values{"account_id": {strconv.FormatInt(accountID, 10)}}, &before)
+	if !ok {
+		return
+	}
+
+	list, err := h.holds.List(r.Context(), accountID, before.ID, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Hold error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	response := models.HoldListResponse{AccountID: accountID, Holds: make([]models.HoldResponse, 0, len(list))}
+	for _, hold := range list {
+		response.Holds = append(response.Holds, models.NewHoldResponse(hold))
+	}
+	if len(list) == limit {
+		response.NextCursor = h.cursors.Encode("list_account_holds", filters, idCursor{ID: list[len(list)-1].ID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// holdID parses the hold ID from the path, writing the error response if it cannot
+// Also answers 503 when holds are unavailable
+func (h *Handler) holdID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if h.holds == nil {
+		http.Error(w, "Holds unavailable", http.StatusServiceUnavailable)
+		return 0, false
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["hold_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid hold ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeHoldError maps a hold lookup or release error to a response
+func writeHoldError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrHoldNotFound):
+		http.Error(w, "Hold not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrHoldNotActive):
+		http.Error(w, "Hold is no longer active", http.StatusConflict)
+	default:
+		slog.ErrorContext(r.Context(), "Hold error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
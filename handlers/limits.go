@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/audit"
+	"internal-transfers/database"
+	"internal-transfers/limits"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// WithLimits attaches the repository of per-account transfer limits served by the limit endpoints
+// The limits are enforced by the transaction repository whether or not they are attached here
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithLimits(repo database.LimitRepositoryInterface) *Handler {
+	h.limits = limits.NewManager(repo, h.accountRepo)
+	if h.currencies != nil {
+		h.limits.WithCurrencies(h.currencies.Currencies())
+	}
+	return h
+}
+
+// GetAccountLimits handles GET /accounts/{account_id}/limits endpoint
+// Response: 200 OK with the account's limits (null when not limited) and what it has debited so
+// far in the current UTC day, 404 if the account does not exist, 503 if limits are unavailable
+// Example response: {"account_id": 123, "max_amount": "500", "daily_amount": "2000", "daily_count": null,
+// "usage": {"day": "2026-10-15", "amount": "750", "count": 3}}
+func (h *Handler) GetAccountLimits(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.limitAccountID(w, r)
+	if !ok {
+		return
+	}
+	response, err := h.limits.Get(r.Context(), accountID)
+	if err != nil {
+		writeLimitError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetAccountLimits handles PUT /admin/accounts/{account_id}/limits endpoint (admin only)
+// This endpoint replaces the account's transfer limits; transfers debiting the account beyond a
+// limit are refused with 422
+// Request body: {"max_amount": "500.00", "daily_amount": "2000.00", "daily_count": 20}; an omitted
+// or null field removes that limit, so {} removes all of them
+// Response: 200 OK with the new limits (as for GET /accounts/{account_id}/limits), 400 for an
+// invalid limit, 404 if the account does not exist
+func (h *Handler) SetAccountLimits(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.limitAccountID(w, r)
+	if !ok {
+		return
+	}
+	var req models.SetTransferLimitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	var before *models.TransferLimitsResponse
+	if h.audit != nil {
+		before, _ = h.limits.Get(r.Context(), accountID)
+	}
+	response, err := h.limits.Set(r.Context(), accountID, req)
+	if err != nil {
+		writeLimitError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "Account transfer limits set", "account_id", accountID)
+	if h.audit != nil {
+		h.audit.Record(r.Context(), models.AuditEvent{
+			Actor:      models.AuditActorAdmin,
+			Action:     models.AuditTransferLimitsSet,
+			AccountIDs: []int64{accountID},
+			Before:     audit.Snapshot(before),
+			After:      audit.Snapshot(response),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// limitAccountID parses the account ID from the path, writing the error response if it cannot
+// Also answers 503 when limits are unavailable
+func (h *Handler) limitAccountID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if h.limits == nil {
+		http.Error(w, "Transfer limits unavailable", http.StatusServiceUnavailable)
+		return 0, false
+	}
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return accountID, true
+}
+
+// writeLimitError maps an error of limits.Manager to a response
+func writeLimitError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeValidationError(w, r, invalid)
+	case errors.Is(err, service.ErrAccountNotFound):
+		http.Error(w, "Account not found", http.StatusNotFound)
+	default:
+		slog.ErrorContext(r.Context(), "Transfer limit error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
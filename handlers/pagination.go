@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"internal-transfers/pagination"
+)
+
+// Typed sort keys of the list endpoints' cursors, one per listing
+// Each records the last row of a page in terms of API fields, so it remains valid across storage
+// changes; the JSON names are part of the cursor format
+
+// accountCursor continues GET /accounts after an account ID
+type accountCursor struct {
+	AccountID int64 `json:"account_id"`
+}
+
+// changesCursor continues GET /accounts/{account_id}/changes and GET /accounts/{account_id}/events
+// after a sequence number
+type changesCursor struct {
+	Seq int64 `json:"seq"`
+}
+
+// idCursor continues the listings ordered by ID: holds, recurring transfers and executions,
+// audit events and balance snapshots
+type idCursor struct {
+	ID int64 `json:"id"`
+}
+
+// transactionCursor continues GET /accounts/{account_id}/transactions after a transaction's
+// creation time and ID; cursors issued while the listing was ordered by ID alone (an idCursor)
+// decode without the creation time and continue below the ID
+type transactionCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        int64     `json:"id"`
+}
+
+// settlementFileCursor continues GET /settlements/files after a business date and partner
+type settlementFileCursor struct {
+	BusinessDate time.Time `json:"business_date"`
+	PartnerID    string    `json:"partner_id"`
+}
+
+// WithCursors sets the codec signing the list endpoints' cursors; every instance behind the same
+// API must share its key. Without it the handler signs with a random per-process key
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithCursors(codec *pagination.Codec) *Handler {
+	h.cursors = codec
+	return h
+}
+
+// listingFilters returns the filters of a listing: the request's, or the cursor's if the request
+// has a cursor, in which case the page continues after the cursor's key
+// endpoint names the listing, so a cursor of one endpoint is refused by the others. The request's
+// filters must match the cursor's; filters it omits are taken from the cursor
+// Returns ok=false after writing 400 for a malformed, forged or mismatched cursor
+func (h *Handler) listingFilters(w http.ResponseWriter, r *http.Request, endpoint string, filters url.Values, key interface{}) (url.Values, bool) {
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		return filters, true
+	}
+	filters, err := h.cursors.Decode(cursor, endpoint, filters, key)
+	switch {
+	case errors.Is(err, pagination.ErrFilterMismatch):
+		http.Error(w, "Filters do not match the cursor (omit them or repeat the original values)", http.StatusBadRequest)
+		return nil, false
+	case err != nil:
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return nil, false
+	}
+	return filters, true
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"internal-transfers/models"
+	"internal-transfers/reconcile"
+)
+
+// WithReconciler attaches the reconciler serving GET /admin/reconciliation
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithReconciler(reconciler *reconcile.Reconciler) *Handler {
+	h.reconciler = reconciler
+	return h
+}
+
+// Reconcile handles GET /admin/reconciliation endpoint (admin only)
+// This endpoint recomputes every account's balance from its initial balance and transactions and
+// compares it to the stored balance; nothing is corrected
+// Response: 200 OK with the number of accounts checked and every discrepancy by account ID (the
+// report is a 200 whether or not the ledger balances), 503 if reconciliation is unavailable
+// Example response: {"checked_at": "2026-10-15T09:30:00Z", "accounts": 1200, "balanced": false,
+// "discrepancies": [{"account_id": 123, "initial_balance": "100", "stored_balance": "90.5",
+// "computed_balance": "80.5", "difference": "10", "transactions": 4}]}
+func (h *Handler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	if h.reconciler == nil {
+		http.Error(w, "Reconciliation unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	report, err := h.reconciler.Reconcile(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Reconciliation error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NewReconciliationResponse(*report))
+}
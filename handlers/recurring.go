@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/database"
+	"internal-transfers/middleware"
+	"internal-transfers/models"
+	"internal-transfers/recurring"
+	"internal-transfers/service"
+)
+
+// Recurring transfer listing limits
+const (
+	maxRecurringTransfers  = 1000
+	defaultExecutionsLimit = 100
+	maxRecurringExecutions = 1000
+)
+
+// WithRecurring attaches the repository of recurring transfers; their executions are submitted
+// through the handler's transfer service, so they follow its rules, cut-offs and FX settings
+// The scheduler must still be started, e.g. go h.Recurring().Run(ctx, interval)
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithRecurring(repo database.RecurringRepositoryInterface) *Handler {
+	h.recurring = recurring.NewScheduler(repo, h.accountRepo, h.transfers)
+	return h
+}
+
+// Recurring returns the recurring transfer scheduler, or nil if none is attached
+func (h *Handler) Recurring() *recurring.Scheduler {
+	return h.recurring
+}
+
+// CreateRecurringTransfer handles POST /recurring-transfers endpoint for scheduled transfers
+// Request body: JSON with the transfer template (source_account_id, destination_account_id, amount,
+// optional transfer_type), a schedule and an optional start_at for the first run
+//   - schedule is a five-field cron expression in UTC ("0 9 1 * *"), a macro such as @daily, or
+//     "@every <duration>" of at least a minute
+//   - Executions evaluate the validation rules of the X-Tenant-ID tenant that created the rule
+//
+// Response: 201 Created with the rule and its next_run_at, 400 for an invalid template, schedule
+// or start_at, 404 if an account does not exist, 503 if recurring transfers are unavailable
+// Example request: {"source_account_id": 123, "destination_account_id": 456, "amount": "250.00", "schedule": "0 9 1 * *"}
+func (h *Handler) CreateRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+	if h.recurring == nil {
+		http.Error(w, "Recurring transfers unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var req models.CreateRecurringTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	req.Tenant = tenant(r)
+	middleware.LogAccounts(r.Context(), req.SourceAccountID, req.DestinationAccountID)
+
+	rule, err := h.recurring.Create(r.Context(), req)
+	if err != nil {
+		var invalid *service.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeValidationError(w, r, invalid)
+		case errors.Is(err, service.ErrSourceNotFound):
+			http.Error(w, "Source account not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrDestinationNotFound):
+			http.Error(w, "Destination account not found", http.StatusNotFound)
+		default:
+			slog.ErrorContext(r.Context(), "Recurring transfer error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.NewRecurringTransferResponse(*rule))
+}
+
+// ListRecurringTransfers handles GET /recurring-transfers endpoint
+// Query parameters:
+//   - limit (1-1000, default 1000): Maximum number of recurring transfers returned
+//   - cursor: next_cursor of the previous page
+//
+// Response: 200 OK with the recurring transfers ordered by ID and next_cursor while the page is
+// full, 400 for invalid parameters
+func (h *Handler) ListRecurringTransfers(w http.ResponseWriter, r *http.Request) {
+	if h.recurring == nil {
+		http.Error(w, "Recurring transfers unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	limit := maxRecurringTransfers
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxRecurringTransfers {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxRecurringTransfers), http.StatusBadRequest)
+			return
+		}
+	}
+	var after idCursor
+	filters, ok := h.listingFilters(w, r, "list_recurring_transfers", url.Values{}, &after)
+	if !ok {
+		return
+	}
+
+	rules, err := h.recurring.List(r.Context(), after.ID, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Recurring transfer error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.RecurringTransferListResponse{RecurringTransfers: make([]models.RecurringTransferResponse, 0, len(rules))}
+	for _, rule := range rules {
+		response.RecurringTransfers = append(response.RecurringTransfers, models.NewRecurringTransferResponse(rule))
+	}
+	if len(rules) == limit {
+		response.NextCursor = h.cursors.Encode("list_recurring_transfers", filters, idCursor{ID: rules[len(rules)-1].ID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRecurringTransfer handles GET /recurring-transfers/{recurring_id} endpoint
+// Response: 200 OK with the rule, 404 if it does not exist
+func (h *Handler) GetRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+	h.recurringAction(w, r, h.recurring.Get)
+}
+
+// PauseRecurringTransfer handles POST /recurring-transfers/{recurring_id}/pause endpoint
+// A paused rule keeps its history but does not run until resumed
+// Response: 200 OK with the updated rule (also if it was already paused), 404 if it does not exist
+func (h *Handler) PauseRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+	h.recurringAction(w, r, h.recurring.Pause)
+}
+
+// ResumeRecurringTransfer handles POST /recurring-transfers/{recurring_id}/resume endpoint
+// Runs missed while the rule was paused are skipped; next_run_at is the schedule's next time
+// Response: 200 OK with the updated rule (also if it was already active), 404 if it does not exist
+func (h *Handler) ResumeRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+	h.recurringAction(w, r, h.recurring.Resume)
+}
+
+// DeleteRecurringTransfer handles DELETE /recurring-transfers/{recurring_id} endpoint
+// The rule and its execution history are removed; transfers it booked stay in the ledger
+// Response: 204 No Content, 404 if it does not exist
+func (h *Handler) DeleteRecurringTransfer(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.recurringID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.recurring.Delete(r.Context(), id); err != nil {
+		writeRecurringError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRecurringExecutions handles GET /recurring-transfers/{recurring_id}/executions endpoint
+// Query parameters:
+//   - limit (1-1000, default 100): Maximum number of executions returned
+//   - cursor: next_cursor of the previous page
+//
+// Response: 200 OK with the executions newest first and next_cursor while the page is full; a
+// succeeded execution carries the booked transaction_id, a failed one the error that refused the
+// transfer. 400 for invalid parameters or a cursor of another rule, 404 if the rule does not exist
+// Example response: {"recurring_transfer_id": 7, "executions": [{"id": 31, "status": "failed", "error": "insufficient balance", ...}]}
+func (h *Handler) ListRecurringExecutions(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.recurringID(w, r)
+	if !ok {
+		return
+	}
+	limit := defaultExecutionsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxRecurringExecutions {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxRecurringExecutions), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var before idCursor
+	filters, ok := h.listingFilters(w, r, "list_recurring_executions", url.Values{"recurring_id": {strconv.FormatInt(id, 10)}}, &before)
+	if !ok {
+		return
+	}
+
+	executions, err := h.recurring.Executions(r.Context(), id, before.ID, limit)
+	if err != nil {
+		writeRecurringError(w, r, err)
+		return
+	}
+	response := models.RecurringExecutionListResponse{RecurringTransferID: id, Executions: executions}
+	if len(executions) == limit {
+		response.NextCursor = h.cursors.Encode("list_recurring_executions", filters, idCursor{ID: executions[len(executions)-1].ID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// recurringAction applies action to the rule named in the path and writes the resulting rule
+func (h *Handler) recurringAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, id int64) (*models.RecurringTransfer, error)) {
+	id, ok := h.recurringID(w, r)
+	if !ok {
+		return
+	}
+	rule, err := action(r.Context(), id)
+	if err != nil {
+		writeRecurringError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NewRecurringTransferResponse(*rule))
+}
+
+// recurringID parses the rule ID from the path, writing the error response if it cannot
+// Also answers 503 when recurring transfers are unavailable
+func (h *Handler) recurringID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	if h.recurring == nil {
+		http.Error(w, "Recurring transfers unavailable", http.StatusServiceUnavailable)
+		return 0, false
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["recurring_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid recurring transfer ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeRecurringError maps a scheduler error to a response
+func writeRecurringError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, recurring.ErrNotFound) {
+		http.Error(w, "Recurring transfer not found", http.StatusNotFound)
+		return
+	}
+	slog.ErrorContext(r.Context(), "Recurring transfer error", "error", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"internal-transfers/models"
+	"internal-transfers/retention"
+)
+
+// WithRetention attaches the enforcer of the data retention policies, reported by GET
+// /admin/retention
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithRetention(enforcer *retention.Enforcer) *Handler {
+	h.retention = enforcer
+	return h
+}
+
+// RetentionReport handles GET /admin/retention endpoint (admin only)
+// This endpoint shows the effective retention policy of every data class with a dry run of its
+// enforcement: the cutoff and how many rows a run would purge now; nothing is deleted
+// Response: 200 OK with the policies in a fixed order, 503 if the storage does not purge data
+// Example response: {"checked_at": "2026-10-15T09:30:00Z", "dry_run": true, "policies": [{"class":
+// "audit_events", "retention_days": 2555, "keep_forever": false, "enforceable": true,
+// "cutoff": "2019-10-17T09:30:00Z", "eligible": 1200, "deleted": 0}, ...]}
+func (h *Handler) RetentionReport(w http.ResponseWriter, r *http.Request) {
+	if h.retention == nil {
+		http.Error(w, "Retention unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	report, err := h.retention.Enforce(r.Context(), true)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Retention report error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.NewRetentionReportResponse(*report))
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/pagination"
+	"internal-transfers/settlement"
+)
+
+// maxSettlementFiles caps how many generation records a page of GET /settlements/files returns
+const maxSettlementFiles = 100
+
+// WithSettlements attaches the settlement repository used to report file generation status
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithSettlements(repo database.SettlementRepositoryInterface) *Handler {
+	h.settlements = repo
+	return h
+}
+
+// WithSettlementIngester attaches the ingester that applies partner acknowledgment/return files
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithSettlementIngester(ingester *settlement.Ingester) *Handler {
+	h.ingester = ingester
+	return h
+}
+
+// IngestSettlementAck handles POST /settlements/acks endpoint for partner acknowledgment/return files
+// This endpoint matches each line of the uploaded file to a transfer previously exported to the
+// partner, marks acknowledged transfers settled and books return transactions for returned ones
+// Query parameter: partner_id (required) - the partner that sent the file
+// Request body: the file itself, CSV lines of transaction_id,status[,reason]
+// Response: 200 OK with a report of settled, returned and unmatched lines (unmatched lines do not
+// fail the request), 400 for an unknown partner, 413 if the file exceeds the route's body limit,
+// 503 if no partners are configured
+// Example request body: "1042,settled\n1043,returned,R01 insufficient funds\n"
+func (h *Handler) IngestSettlementAck(w http.ResponseWriter, r *http.Request) {
+	if h.ingester == nil {
+		http.Error(w, "Settlement ingestion unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	report, err := h.ingester.Ingest(r.Context(), r.URL.Query().Get("partner_id"), r.Body)
+	if err != nil {
+		if errors.Is(err, settlement.ErrUnknownPartner) {
+			http.Error(w, "Unknown partner", http.StatusBadRequest)
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Acknowledgment file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Settlement acknowledgment error", "error", err)
+		http.Error(w, "Failed to read acknowledgment file", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ListSettlementFiles handles GET /settlements/files endpoint for settlement file generation status
+// This endpoint reports, per partner and business date, whether the settlement file was generated
+// and uploaded, how many transfer legs it contains, and the error if generation failed
+// Query parameters (optional):
+//   - date: Business date (YYYY-MM-DD)
+//   - partner_id: Partner identifier
+//   - cursor: next_cursor of the previous page, continuing the listing with its filters
+//
+// Response: 200 OK with up to 100 matching records, newest business date first, and next_cursor
+// while the page is full; 400 for an invalid date or cursor, 503 if settlement storage is not attached
+// Example response: {"files": [{"partner_id": "acme", "business_date": "2024-01-31", "status": "generated", ...}]}
+func (h *Handler) ListSettlementFiles(w http.ResponseWriter, r *http.Request) {
+	if h.settlements == nil {
+		http.Error(w, "Settlement files unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var after settlementFileCursor
+	filters, ok := h.listingFilters(w, r, "list_settlement_files", pagination.Filters(r.URL.Query(), "date", "partner_id"), &after)
+	if !ok {
+		return
+	}
+
+	var date time.Time
+	if value := filters.Get("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid date (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		date = parsed
+	}
+
+	key := models.SettlementFileKey{BusinessDate: after.BusinessDate, PartnerID: after.PartnerID}
+	files, err := h.settlements.ListSettlementFiles(r.Context(), date, filters.Get("partner_id"), key, maxSettlementFiles)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Settlement file listing error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.SettlementFileListResponse{Files: make([]models.SettlementFileResponse, 0, len(files))}
+	for _, f := range files {
+		response.Files = append(response.Files, models.NewSettlementFileResponse(f))
+	}
+	if len(files) == maxSettlementFiles {
+		last := files[len(files)-1]
+		response.NextCursor = h.cursors.Encode("list_settlement_files", filters, settlementFileCursor{BusinessDate: last.BusinessDate, PartnerID: last.PartnerID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
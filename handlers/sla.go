@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"internal-transfers/models"
+	"internal-transfers/sla"
+	"internal-transfers/usage"
+)
+
+// WithLatency attaches the recorder that tracks per-client transfer latency for SLA reports
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithLatency(recorder *sla.Recorder) *Handler {
+	h.latency = recorder
+	h.transfers.WithLatency(recorder)
+	return h
+}
+
+// GetSLA handles GET /sla endpoint for the caller's own commit latency SLA compliance
+// The caller is identified by the API key it signed the request with, as for GET /usage
+// Query parameters (optional):
+//   - from, to: Inclusive period as UTC dates (YYYY-MM-DD); defaults to the current month to date
+//
+// Response: 200 OK with the commit latency percentiles of the caller's transfers received in the
+// period and whether the p95 met the target; 400 for an invalid period; 503 if latency is not tracked
+// Example response: {"client_id": "ak_3f9c0e12a4b7d658", "transfers": 310, "commit_p95_ms": 212.4, "compliant": true, ...}
+func (h *Handler) GetSLA(w http.ResponseWriter, r *http.Request) {
+	if h.latency == nil {
+		http.Error(w, "SLA tracking unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	from, to, err := reportPeriod(r, h.latency.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	clientID := usage.KeyIDFromContext(r.Context())
+	stats, err := h.latency.Stats(r.Context(), clientID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "SLA query error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	client := models.LatencyStats{ClientID: clientID}
+	if len(stats) > 0 {
+		client = stats[0]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.slaResponse(client, from, to))
+}
+
+// SLAReport handles GET /admin/sla endpoint (admin only) for per-client SLA compliance reports
+// Query parameters (optional): from, to as for GET /sla
+// Response: 200 OK with the SLA compliance of every client with transfers in the period, ordered
+// by client ID
+func (h *Handler) SLAReport(w http.ResponseWriter, r *http.Request) {
+	if h.latency == nil {
+		http.Error(w, "SLA tracking unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	from, to, err := reportPeriod(r, h.latency.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.latency.Stats(r.Context(), "", from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "SLA report error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.SLAReportResponse{
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		TargetP95Ms: milliseconds(h.latency.Target()),
+		Clients:     make([]models.SLAResponse, 0, len(stats)),
+	}
+	for _, client := range stats {
+		response.Clients = append(response.Clients, h.slaResponse(client, from, to))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// slaResponse converts a client's latency statistics into its API representation
+func (h *Handler) slaResponse(stats models.LatencyStats, from, to time.Time) models.SLAResponse {
+	return models.SLAResponse{
+		ClientID:     stats.ClientID,
+		From:         from.Format("2006-01-02"),
+		To:           to.Format("2006-01-02"),
+		Transfers:    stats.Transfers,
+		CommitP50Ms:  milliseconds(stats.CommitP50),
+		CommitP95Ms:  milliseconds(stats.CommitP95),
+		CommitP99Ms:  milliseconds(stats.CommitP99),
+		CommitMaxMs:  milliseconds(stats.CommitMax),
+		EmitP95Ms:    milliseconds(stats.EmitP95),
+		TargetP95Ms:  milliseconds(h.latency.Target()),
+		WithinTarget: stats.WithinTarget,
+		Compliant:    h.latency.Compliant(stats),
+	}
+}
+
+// stageBudgets returns the time each stage of the timeline took against the current stage budgets,
+// and the stages over budget
+func (h *Handler) stageBudgets(latency models.TransferLatency) ([]models.StageBudgetResponse, []string) {
+	budgets := h.latency.Budgets()
+	stages := make([]models.StageBudgetResponse, 0, len(models.LatencyStages))
+	for _, stage := range latency.Stages() {
+		budget := budgets[stage.Stage]
+		stages = append(stages, models.StageBudgetResponse{
+			Stage:    stage.Stage,
+			Ms:       milliseconds(stage.Duration),
+			BudgetMs: milliseconds(budget),
+			Exceeded: budget > 0 && stage.Duration > budget,
+		})
+	}
+	return stages, budgets.Exceeded(latency)
+}
+
+// writeServerTiming reports the stages of the transfer that booked transaction in a Server-Timing
+// header, and names those over their latency budget in X-Latency-Budget-Exceeded
+// Nothing is written when the transfer's timeline was not recorded
+func (h *Handler) writeServerTiming(w http.ResponseWriter, transaction *models.Transaction) {
+	if transaction.Timeline == nil || h.latency == nil {
+		return
+	}
+	stages, exceeded := h.stageBudgets(*transaction.Timeline)
+	timings := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		timings = append(timings, fmt.Sprintf("%s;dur=%.3f", stage.Stage, stage.Ms))
+	}
+	w.Header().Set("Server-Timing", strings.Join(timings, ", "))
+	if len(exceeded) > 0 {
+		w.Header().Set("X-Latency-Budget-Exceeded", strings.Join(exceeded, ","))
+	}
+}
+
+// milliseconds converts a duration to fractional milliseconds, rounded to the microsecond
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
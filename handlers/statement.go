@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/service"
+	"internal-transfers/statements"
+)
+
+// GetStatement handles GET /accounts/{account_id}/statement endpoint, a formatted account statement
+// for a period: the opening balance, every transaction effective in the period with its running
+// balance, and the closing balance, generated from the ledger as it stands now
+// Query parameters:
+//   - from, to (required): Period as RFC 3339 timestamps or dates (midnight UTC); from is
+//     inclusive, to exclusive
+//   - format (optional): csv or pdf; defaults to pdf if the Accept header asks for
+//     application/pdf, otherwise csv
+//
+// Response: 200 OK with the statement as an attachment; 400 for invalid parameters or a period
+// with more than 10000 transactions, 404 if the account does not exist
+func (h *Handler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	var from, to time.Time
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			http.Error(w, "Missing "+bound.name, http.StatusBadRequest)
+			return
+		}
+		if *bound.target, err = parseListingTime(value); err != nil {
+			http.Error(w, "Invalid "+bound.name+" (expected RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+	}
+	format := query.Get("format")
+	switch format {
+	case statements.FormatCSV, statements.FormatPDF:
+	case "":
+		format = statements.FormatCSV
+		if strings.Contains(r.Header.Get("Accept"), "application/pdf") {
+			format = statements.FormatPDF
+		}
+	default:
+		http.Error(w, "Invalid format (expected csv or pdf)", http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.accounts.GetAccount(accountID)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Statement error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	statement, err := h.transfers.Statement(*account, from, to)
+	if err != nil {
+		var invalid *service.ValidationError
+		if errors.As(err, &invalid) {
+			writeValidationError(w, r, invalid)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Statement error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Rendered in full first, so a failure is still reported as an error status
+	var buf bytes.Buffer
+	if err := statements.Write(&buf, statement, format); err != nil {
+		slog.ErrorContext(r.Context(), "Statement rendering error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", statements.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+statements.FileName(statement, format)+`"`)
+	w.Write(buf.Bytes())
+}
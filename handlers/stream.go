@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/models"
+	"internal-transfers/pubsub"
+	"internal-transfers/service"
+)
+
+// streamHeartbeat is how often an SSE comment is sent to keep idle connections open through proxies
+const streamHeartbeat = 15 * time.Second
+
+// streamWriteWait bounds each write to the stream, so a connection whose peer vanished fails
+// instead of blocking its handler until the kernel gives up on it
+const streamWriteWait = 10 * time.Second
+
+// maxStreamReplay caps how many missed transactions are replayed when a client reconnects
+const maxStreamReplay = 100
+
+// WithBroker attaches the in-process broker that feeds the live transaction stream
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithBroker(broker *pubsub.Broker) *Handler {
+	h.broker = broker
+	return h
+}
+
+// StreamTransactions handles GET /accounts/{account_id}/transactions/stream endpoint
+// This endpoint holds the connection open and pushes each newly committed transfer touching the
+// account as a Server-Sent Event, so dashboards can show balances changing in real time
+// Event format: "event: transaction", "id: <transaction id>", "data: <TransactionResponse JSON>"
+// Reconnection: browsers resend the last received id as Last-Event-ID; transactions committed
+// since then (up to 100) are replayed before live events resume. If the client falls too far
+// behind the stream is closed so that it reconnects and resynchronises the same way
+// Response: 200 text/event-stream, 404 if the account does not exist, 503 if streaming is disabled
+func (h *Handler) StreamTransactions(w http.ResponseWriter, r *http.Request) {
+	if h.broker == nil {
+		http.Error(w, "Streaming unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.accounts.GetAccount(accountID); err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before replaying so nothing committed in between is missed
+	sub := h.broker.Subscribe(0, accountID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var lastSent int64
+	if lastEventID, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		lastSent = lastEventID
+		missed, err := h.transactionRepo.ListTransactions(accountID, maxStreamReplay)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Stream replay error", "error", err)
+			return
+		}
+		// ListTransactions is newest first; replay oldest first
+		for i := len(missed) - 1; i >= 0; i-- {
+			if missed[i].ID > lastSent {
+				writeTransactionEvent(w, missed[i])
+				lastSent = missed[i].ID
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	// Writes that fail end the stream; successful ones keep the subscription from being reaped
+	controller := http.NewResponseController(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case t, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if t.ID <= lastSent {
+				continue
+			}
+			controller.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := writeTransactionEvent(w, t); err != nil {
+				return
+			}
+			lastSent = t.ID
+		case <-heartbeat.C:
+			controller.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		if controller.Flush() != nil {
+			return
+		}
+		sub.Touch()
+	}
+}
+
+// writeTransactionEvent writes a transaction as a single SSE event
+func writeTransactionEvent(w http.ResponseWriter, t models.Transaction) error {
+	data, _ := json.Marshal(models.NewTransactionResponse(t))
+	_, err := fmt.Fprintf(w, "event: transaction\nid: %d\ndata: %s\n\n", t.ID, data)
+	return err
+}
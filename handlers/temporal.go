@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// GetBalanceAsOf handles GET /accounts/{account_id}/balance endpoint for point-in-time balances
+// The ledger is bi-temporal: every transfer has the time it was recorded and the business time it
+// takes effect, which is earlier for backdated transfers. recorded_at answers "what did the ledger
+// say at that time", effective_at "what was the balance effective at that time"; combining them
+// shows a past balance as it was known at a later time
+// Query parameters (optional, RFC 3339 or YYYY-MM-DD, default now):
+//   - recorded_at: Ignore transfers recorded after this time
+//   - effective_at: Ignore transfers effective after this time
+//
+// Response: 200 OK with the balance, 400 for invalid times, 404 if the account does not exist or
+// did not exist yet at recorded_at
+// Example response: {"account_id": 123, "balance": "250.5", "recorded_at": "2024-03-31T00:00:00Z", "effective_at": "2024-03-31T00:00:00Z"}
+func (h *Handler) GetBalanceAsOf(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	recordedAt, effectiveAt := now, now
+	query := r.URL.Query()
+	for _, axis := range []struct {
+		name   string
+		target *time.Time
+	}{{"recorded_at", &recordedAt}, {"effective_at", &effectiveAt}} {
+		if value := query.Get(axis.name); value != "" {
+			if *axis.target, err = parseListingTime(value); err != nil {
+				http.Error(w, "Invalid "+axis.name+" (expected RFC 3339 or YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	h.writeBalanceAsOf(w, r, accountID, recordedAt, effectiveAt)
+}
+
+// writeBalanceAsOf writes the account's balance counting the movements recorded by recordedAt and
+// effective by effectiveAt, for GET /accounts/{account_id}/balance and ?as_of on the account
+func (h *Handler) writeBalanceAsOf(w http.ResponseWriter, r *http.Request, accountID int64, recordedAt, effectiveAt time.Time) {
+	account, err := h.accounts.GetAccount(accountID)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Balance as-of error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	balance, err := h.transfers.BalanceAsOf(accountID, recordedAt, effectiveAt)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account did not exist at the requested time", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Balance as-of error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, r, http.StatusOK, models.BalanceAsOfResponse{
+		AccountID:   accountID,
+		Balance:     balance.String(),
+		Currency:    account.Currency,
+		RecordedAt:  recordedAt.UTC(),
+		EffectiveAt: effectiveAt.UTC(),
+	})
+}
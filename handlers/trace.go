@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+
+	"github.com/gorilla/mux"
+)
+
+// WithOutbox attaches the outbox, whose events and their publication status are part of a
+// transfer's trace
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithOutbox(repo database.OutboxRepositoryInterface) *Handler {
+	h.outbox = repo
+	return h
+}
+
+// GetTransactionTrace handles GET /admin/transactions/{transaction_id}/trace endpoint (admin only)
+// This endpoint assembles everything recorded about one transfer for incident triage: the
+// transaction, its processing timeline (validation, lock wait, commit and emit latencies, the
+// rules it passed and the retries refused as duplicates, each stage's time against its latency
+// budget and the stages over budget), its audit events with their request IDs
+// and the events its commit recorded in the outbox with their publication status
+// Response: 200 OK with the trace; sources this deployment does not record are listed under
+// unavailable; 400 for an invalid ID, 404 if the transaction does not exist, 503 if transactions
+// cannot be looked up
+// Example response: {"transaction": {"id": 42, ...}, "timeline": {"client_id": "key_1a2b3c4d5e6f7a8b",
+// "validate_ms": 0.4, "lock_wait_ms": 12.7, "commit_ms": 18.2, "emit_ms": 18.5, "stages": [{"stage":
+// "lock_wait", "ms": 12.7, "budget_ms": 100, "exceeded": false}, ...], "budget_exceeded": [],
+// "rules": ["wire_cap"], "retries": 1, ...}, "audit": [...], "events": [{"event_type": "transaction.completed", "published": true, ...}],
+// "unavailable": []}
+func (h *Handler) GetTransactionTrace(w http.ResponseWriter, r *http.Request) {
+	if h.settlements == nil {
+		http.Error(w, "Transaction lookup unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	transactionID, err := strconv.ParseInt(mux.Vars(r)["transaction_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	transaction, err := h.settlements.GetTransaction(r.Context(), transactionID)
+	if err != nil {
+		if err.Error() == "transaction not found" {
+			http.Error(w, "Transaction not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Transaction trace error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.TransferTraceResponse{
+		Transaction: models.NewTransactionResponse(*transaction),
+		Audit:       []models.AuditEvent{},
+		Events:      []models.TransferEventResponse{},
+		Unavailable: []string{},
+	}
+
+	if h.latency == nil {
+		response.Unavailable = append(response.Unavailable, models.TraceTimeline)
+	} else {
+		latency, err := h.latency.Latency(r.Context(), transactionID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Transaction trace error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if latency != nil {
+			stages, exceeded := h.stageBudgets(*latency)
+			response.Timeline = &models.TransferTimelineResponse{
+				ClientID:       latency.ClientID,
+				ReceivedAt:     latency.ReceivedAt,
+				ValidateMs:     milliseconds(latency.Validate),
+				LockWaitMs:     milliseconds(latency.LockWait),
+				CommitMs:       milliseconds(latency.Commit),
+				EmitMs:         milliseconds(latency.Emit),
+				Stages:         stages,
+				BudgetExceeded: exceeded,
+				Rules:          latency.Rules,
+				Retries:        latency.Retries,
+			}
+		}
+	}
+
+	if h.audit == nil {
+		response.Unavailable = append(response.Unavailable, models.TraceAudit)
+	} else {
+		events, err := h.audit.List(r.Context(), models.AuditFilter{TransactionID: transactionID}, maxAuditLimit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Transaction trace error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		// Oldest first, to read as a timeline
+		for i := len(events) - 1; i >= 0; i-- {
+			response.Audit = append(response.Audit, events[i])
+		}
+	}
+
+	if h.outbox == nil {
+		response.Unavailable = append(response.Unavailable, models.TraceEvents)
+	} else {
+		messages, err := h.outbox.TransactionEvents(r.Context(), transactionID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Transaction trace error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		for _, m := range messages {
+			event := models.TransferEventResponse{EventID: m.EventID, EventType: m.EventType, CreatedAt: m.CreatedAt}
+			if !m.PublishedAt.IsZero() {
+				publishedAt := m.PublishedAt
+				event.Published, event.PublishedAt = true, &publishedAt
+			}
+			response.Events = append(response.Events, event)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+	"internal-transfers/pagination"
+	"internal-transfers/service"
+)
+
+// Transaction search page sizes
+const (
+	defaultTransactionsLimit = 100
+	maxTransactionsLimit     = 1000
+)
+
+// SearchAccountTransactions handles GET /accounts/{account_id}/transactions endpoint, listing the
+// account's transactions and finding e.g. those tied to an invoice by their client annotations
+// Query parameters:
+//   - reference: Only the transaction with exactly this reference
+//   - memo: Only transactions whose memo contains this text, ignoring case
+//   - created_from, created_before (RFC 3339 or YYYY-MM-DD): Only transactions created in
+//     [created_from, created_before)
+//   - min_amount, max_amount: Only transactions moving at least/at most this amount on the
+//     account (debited if outgoing, credited if incoming)
+//   - direction (incoming or outgoing): Only transactions crediting or debiting the account
+//   - counterparty_id: Only transactions with this account on the other side
+//   - order (newest or oldest, default newest): Listing order by creation time
+//   - limit (1-1000, default 100): Maximum number of transactions returned
+//   - cursor: next_cursor of the previous page
+//
+// Response: 200 OK with the account's matching transactions in the requested order and
+// next_cursor while the page is full; 400 for invalid parameters or a cursor of another search,
+// 404 if the account does not exist
+// Example response: {"account_id": 123, "transactions": [{"id": 42, "reference": "INV-1001", ...}]}
+func (h *Handler) SearchAccountTransactions(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	limit := defaultTransactionsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxTransactionsLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxTransactionsLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	requested := pagination.Filters(r.URL.Query(), transactionFilterParams...)
+	requested.Set("account_id", strconv.FormatInt(accountID, 10))
+	var after transactionCursor
+	filters, ok := h.listingFilters(w, r, "search_account_transactions", requested, &after)
+	if !ok {
+		return
+	}
+	filter, err := parseTransactionFilter(filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.AccountID, filter.After = accountID, models.TransactionPosition(after)
+
+	if _, err := h.accounts.GetAccount(accountID); err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Transaction search error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	transactions, err := h.transfers.Search(filter, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Transaction search error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	response := models.TransactionListResponse{AccountID: accountID, Transactions: make([]models.TransactionResponse, 0, len(transactions))}
+	for _, t := range transactions {
+		response.Transactions = append(response.Transactions, models.NewTransactionResponse(t))
+	}
+	if len(transactions) == limit {
+		last := transactions[len(transactions)-1]
+		response.NextCursor = h.cursors.Encode("search_account_transactions", filters, transactionCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	writeBody(w, r, http.StatusOK, response)
+}
+
+// maxSearchValues caps the accounts and the references of one transaction search
+const maxSearchValues = 100
+
+// SearchTransactions handles GET /transactions/search endpoint (admin only), letting support staff
+// investigating e.g. a dispute find transactions across accounts
+// Query parameters, at least one filter required:
+//   - account_id (repeatable, at most 100): Only transactions with one of these accounts on either
+//     side
+//   - reference (repeatable, at most 100): Only transactions with one of these references
+//   - text: Only transactions whose memo contains every word of the text, ignoring case and
+//     punctuation
+//   - min_amount, max_amount: Only transactions debiting at least/at most this amount
+//   - created_from, created_before (RFC 3339 or YYYY-MM-DD): Only transactions created in
+//     [created_from, created_before)
+//   - limit (1-1000, default 100): Maximum number of transactions returned
+//   - cursor: next_cursor of the previous page
+//
+// Response: 200 OK with the matching transactions newest first and next_cursor while the page is
+// full; 400 for missing or invalid filters or a cursor of another search
+// Example response: {"transactions": [{"id": 42, "reference": "INV-1001", ...}], "next_cursor": "..."}
+func (h *Handler) SearchTransactions(w http.ResponseWriter, r *http.Request) {
+	var err error
+	limit := defaultTransactionsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxTransactionsLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit (expected 1-%d)", maxTransactionsLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	var after transactionCursor
+	filters, ok := h.listingFilters(w, r, "search_transactions", pagination.Filters(r.URL.Query(), transactionSearchParams...), &after)
+	if !ok {
+		return
+	}
+	search, err := parseTransactionSearch(filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	search.After = models.TransactionPosition(after)
+
+	transactions, err := h.transfers.SearchAll(search, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Transaction search error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	response := models.TransactionSearchResponse{Transactions: make([]models.TransactionResponse, 0, len(transactions))}
+	for _, t := range transactions {
+		response.Transactions = append(response.Transactions, models.NewTransactionResponse(t))
+	}
+	if len(transactions) == limit {
+		last := transactions[len(transactions)-1]
+		response.NextCursor = h.cursors.Encode("search_transactions", filters, transactionCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	writeBody(w, r, http.StatusOK, response)
+}
+
+// transactionSearchParams are the query parameters of GET /transactions/search its cursors are
+// bound to
+var transactionSearchParams = []string{
+	"account_id", "reference", "text", "min_amount", "max_amount", "created_from", "created_before",
+}
+
+// parseTransactionSearch reads the search of GET /transactions/search from its query parameters,
+// leaving the page position unset
+// Errors are client-facing
+func parseTransactionSearch(query url.Values) (search models.TransactionSearch, err error) {
+	if len(query["account_id"]) > maxSearchValues || len(query["reference"]) > maxSearchValues {
+		return search, fmt.Errorf("Too many account_id or reference values (at most %d each)", maxSearchValues)
+	}
+	for _, value := range query["account_id"] {
+		id, parseErr := strconv.ParseInt(value, 10, 64)
+		if parseErr != nil || id <= 0 {
+			return search, fmt.Errorf("Invalid account_id %q (expected a positive account ID)", value)
+		}
+		search.AccountIDs = append(search.AccountIDs, id)
+	}
+	search.References = query["reference"]
+	// Normalized to its words, so punctuation alone is no condition
+	search.Text = strings.Join(models.SearchWords(query.Get("text")), " ")
+
+	for _, bound := range []struct {
+		name   string
+		target **decimal.Decimal
+	}{{"min_amount", &search.MinAmount}, {"max_amount", &search.MaxAmount}} {
+		if value := query.Get(bound.name); value != "" {
+			amount, parseErr := decimal.NewFromString(value)
+			if parseErr != nil {
+				return search, fmt.Errorf("Invalid %s (expected a decimal amount)", bound.name)
+			}
+			*bound.target = &amount
+		}
+	}
+	if search.MinAmount != nil && search.MaxAmount != nil && search.MinAmount.GreaterThan(*search.MaxAmount) {
+		return search, fmt.Errorf("min_amount must not exceed max_amount")
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"created_from", &search.CreatedFrom}, {"created_before", &search.CreatedBefore}} {
+		if value := query.Get(bound.name); value != "" {
+			if *bound.target, err = parseListingTime(value); err != nil {
+				return search, fmt.Errorf("Invalid %s (expected RFC 3339 or YYYY-MM-DD)", bound.name)
+			}
+		}
+	}
+	if search.IsEmpty() {
+		return search, fmt.Errorf("At least one filter is required (%s)", strings.Join(transactionSearchParams, ", "))
+	}
+	return search, nil
+}
+
+// transactionFilterParams are the query parameters of GET /accounts/{account_id}/transactions its
+// cursors are bound to
+var transactionFilterParams = []string{
+	"reference", "memo", "created_from", "created_before", "min_amount", "max_amount", "direction",
+	"counterparty_id", "order",
+}
+
+// parseTransactionFilter reads the filter of GET /accounts/{account_id}/transactions from its
+// query parameters, leaving the account and page position unset
+// Errors are client-facing
+func parseTransactionFilter(query url.Values) (filter models.TransactionFilter, err error) {
+	filter.Reference = query.Get("reference")
+	filter.Memo = query.Get("memo")
+
+	filter.Direction = query.Get("direction")
+	if filter.Direction != "" && filter.Direction != models.TransactionIncoming && filter.Direction != models.TransactionOutgoing {
+		return filter, fmt.Errorf("Invalid direction (expected %s or %s)", models.TransactionIncoming, models.TransactionOutgoing)
+	}
+	filter.Order = query.Get("order")
+	if filter.Order != "" && filter.Order != models.TransactionsNewestFirst && filter.Order != models.TransactionsOldestFirst {
+		return filter, fmt.Errorf("Invalid order (expected %s or %s)", models.TransactionsNewestFirst, models.TransactionsOldestFirst)
+	}
+	if value := query.Get("counterparty_id"); value != "" {
+		if filter.CounterpartyID, err = strconv.ParseInt(value, 10, 64); err != nil || filter.CounterpartyID <= 0 {
+			return filter, fmt.Errorf("Invalid counterparty_id (expected a positive account ID)")
+		}
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **decimal.Decimal
+	}{{"min_amount", &filter.MinAmount}, {"max_amount", &filter.MaxAmount}} {
+		if value := query.Get(bound.name); value != "" {
+			amount, parseErr := decimal.NewFromString(value)
+			if parseErr != nil {
+				return filter, fmt.Errorf("Invalid %s (expected a decimal amount)", bound.name)
+			}
+			*bound.target = &amount
+		}
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && filter.MinAmount.GreaterThan(*filter.MaxAmount) {
+		return filter, fmt.Errorf("min_amount must not exceed max_amount")
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"created_from", &filter.CreatedFrom}, {"created_before", &filter.CreatedBefore}} {
+		if value := query.Get(bound.name); value != "" {
+			if *bound.target, err = parseListingTime(value); err != nil {
+				return filter, fmt.Errorf("Invalid %s (expected RFC 3339 or YYYY-MM-DD)", bound.name)
+			}
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"internal-transfers/audit"
+	"internal-transfers/models"
+	"internal-transfers/usage"
+)
+
+// maxReportDays bounds the period of a usage or SLA query
+const maxReportDays = 366
+
+// WithUsage attaches the usage recorder that meters requests and transfers per API key
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithUsage(recorder *usage.Recorder) *Handler {
+	h.usage = recorder
+	return h
+}
+
+// Usage returns the handler's usage recorder, or nil if usage is not metered
+// The router installs its middleware to count requests
+func (h *Handler) Usage() *usage.Recorder {
+	return h.usage
+}
+
+// recordTransfer attributes a committed transfer to the API key of the request and appends it to
+// the audit log
+func (h *Handler) recordTransfer(ctx context.Context, transaction *models.Transaction) {
+	if h.usage != nil {
+		h.usage.Transfer(usage.KeyIDFromContext(ctx), transaction.Amount)
+	}
+	h.audit.Record(ctx, audit.TransferEvent(audit.APIKeyActor(ctx), transaction))
+}
+
+// GetUsage handles GET /usage endpoint for the caller's own API usage
+// Usage is attributed to the API key the request is signed with; unsigned requests are reported
+// as "anonymous"
+// Query parameters (optional):
+//   - from, to: Inclusive period as UTC dates (YYYY-MM-DD); defaults to the current month to date
+//
+// Response: 200 OK with request and transfer totals, a per-day breakdown and, if the key has a
+// quota, the quota consumed this month; 400 for an invalid period; 503 if usage is not metered
+// Example response: {"key_id": "ak_3f9c0e12a4b7d658", "requests": 1520, "transfers": 310, "transfer_volume": "48210.5", ...}
+func (h *Handler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	if h.usage == nil {
+		http.Error(w, "Usage metering unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	from, to, err := reportPeriod(r, h.usage.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keyID := usage.KeyIDFromContext(r.Context())
+	records, err := h.usage.Usage(r.Context(), keyID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Usage query error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := h.keyUsage(r.Context(), keyID, from, to, records)
+	response.Days = make([]models.UsageDayResponse, 0, len(records))
+	for _, u := range records {
+		response.Days = append(response.Days, models.UsageDayResponse{
+			Day:            u.Day.Format("2006-01-02"),
+			Requests:       u.Requests,
+			Transfers:      u.Transfers,
+			TransferVolume: u.TransferVolume.String(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UsageReport handles GET /admin/usage endpoint (admin only) for chargeback reporting
+// Query parameters (optional): from, to as for GET /usage
+// Response: 200 OK with every key's totals over the period, ordered by key ID
+func (h *Handler) UsageReport(w http.ResponseWriter, r *http.Request) {
+	if h.usage == nil {
+		http.Error(w, "Usage metering unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	from, to, err := reportPeriod(r, h.usage.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := h.usage.Usage(r.Context(), "", from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Usage report error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.UsageReportResponse{From: from.Format("2006-01-02"), To: to.Format("2006-01-02"), Keys: []models.KeyUsageResponse{}}
+	for start := 0; start < len(records); {
+		end := start
+		for end < len(records) && records[end].KeyID == records[start].KeyID {
+			end++
+		}
+		response.Keys = append(response.Keys, h.keyUsage(r.Context(), records[start].KeyID, from, to, records[start:end]))
+		start = end
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// reportPeriod reads the from and to query parameters of a usage or SLA report, defaulting to the
+// current month to date
+func reportPeriod(r *http.Request, now time.Time) (from, to time.Time, err error) {
+	today := usage.Today(now)
+	from, to = today.AddDate(0, 0, 1-today.Day()), today
+
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if value := r.URL.Query().Get(bound.name); value != "" {
+			if *bound.target, err = time.Parse("2006-01-02", value); err != nil {
+				return from, to, fmt.Errorf("Invalid %s (expected YYYY-MM-DD)", bound.name)
+			}
+		}
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("from must not be after to")
+	}
+	if to.Sub(from) >= maxReportDays*24*time.Hour {
+		return from, to, fmt.Errorf("Period must not exceed %d days", maxReportDays)
+	}
+	return from, to, nil
+}
+
+// keyUsage totals a key's daily records and adds its quota consumption for the current month
+func (h *Handler) keyUsage(ctx context.Context, keyID string, from, to time.Time, records []models.Usage) models.KeyUsageResponse {
+	total := models.Usage{KeyID: keyID}
+	for _, u := range records {
+		total.Add(u)
+	}
+	response := models.KeyUsageResponse{
+		KeyID:          keyID,
+		From:           from.Format("2006-01-02"),
+		To:             to.Format("2006-01-02"),
+		Requests:       total.Requests,
+		Transfers:      total.Transfers,
+		TransferVolume: total.TransferVolume.String(),
+	}
+
+	quota, ok := h.usage.Quota(keyID)
+	if !ok {
+		return response
+	}
+	today := usage.Today(h.usage.Now())
+	month, err := h.usage.Usage(ctx, keyID, today.AddDate(0, 0, 1-today.Day()), today)
+	if err != nil {
+		slog.ErrorContext(ctx, "Usage quota query error", "error", err)
+		return response
+	}
+	var used int64
+	for _, u := range month {
+		used += u.Requests
+	}
+	response.Quota = &models.QuotaResponse{MonthlyRequests: quota, Used: used, Remaining: max(quota-used, 0)}
+	return response
+}
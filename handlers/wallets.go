@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/audit"
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// WithWallets attaches the repository of multi-currency accounts' wallets, served by the wallet
+// endpoints and listed in account responses
+// Transfers move wallets through the transaction repository whether or not it is attached here
+// Returns the handler to allow chaining after NewHandler
+func (h *Handler) WithWallets(repo database.WalletRepositoryInterface) *Handler {
+	h.wallets = repo
+	h.accounts.WithWallets(repo)
+	return h
+}
+
+// OpenWallet handles POST /accounts/{account_id}/wallets endpoint
+// This endpoint makes an account multi-currency: it opens an empty wallet in another currency
+// under the same account ID. Transfers naming that currency then credit and debit the wallet
+// instead of the account's primary balance
+// Request body: JSON with currency, a supported ISO 4217 code other than the account's own
+// Response: 201 Created with the wallet, 400 for an invalid currency, 404 if the account does not
+// exist, 409 if the account already holds the currency, 422 if the account has no currency, 503
+// if wallets are unavailable
+// Example request: {"currency": "EUR"}
+func (h *Handler) OpenWallet(w http.ResponseWriter, r *http.Request) {
+	if h.wallets == nil {
+		http.Error(w, "Wallets unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	var req models.OpenWalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	wallet, err := h.accounts.OpenWallet(r.Context(), accountID, req)
+	if err != nil {
+		var invalid *service.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeValidationError(w, r, invalid)
+		case errors.Is(err, service.ErrAccountNotFound):
+			http.Error(w, "Account not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrWalletExists):
+			http.Error(w, "The account already holds this currency", http.StatusConflict)
+		case errors.Is(err, service.ErrAccountNoCurrency):
+			http.Error(w, "Accounts without a currency cannot hold wallets", http.StatusUnprocessableEntity)
+		default:
+			slog.ErrorContext(r.Context(), "Open wallet error", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	slog.InfoContext(r.Context(), "Wallet opened", "account_id", accountID, "currency", wallet.Currency)
+
+	response := models.NewWalletResponse(*wallet)
+	if h.audit != nil {
+		h.audit.Record(r.Context(), models.AuditEvent{
+			Actor:      audit.APIKeyActor(r.Context()),
+			Action:     models.AuditWalletOpened,
+			AccountIDs: []int64{accountID},
+			After:      audit.Snapshot(response),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListWallets handles GET /accounts/{account_id}/wallets endpoint
+// Response: 200 OK with every balance of the account, its primary balance (in its own currency)
+// first and then its wallets by currency; 404 if the account does not exist
+// Example response: {"account_id": 123, "wallets": [{"currency": "USD", "balance": "100.5", "available": "100.5", "primary": true, ...},
+// {"currency": "EUR", "balance": "20", "available": "20", ...}]}
+func (h *Handler) ListWallets(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	account, err := h.accounts.GetAccount(accountID)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountNotFound) {
+			http.Error(w, "Account not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "List wallets error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	wallets, err := h.accounts.Wallets(r.Context(), accountID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "List wallets error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WalletListResponse{AccountID: accountID, Wallets: models.NewWalletResponses(*account, wallets)})
+}
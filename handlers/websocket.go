@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"internal-transfers/models"
+	"internal-transfers/pubsub"
+	"internal-transfers/service"
+)
+
+// WebSocket connection limits
+const (
+	wsWriteWait        = 10 * time.Second
+	wsPongWait         = 60 * time.Second
+	wsPingPeriod       = wsPongWait * 9 / 10
+	wsMaxMessageSize   = 4096
+	wsMaxSubscriptions = 100
+)
+
+// wsUpgrader upgrades /ws requests; the default origin check rejects cross-site browser pages
+var wsUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// wsClientMessage is a request sent by a WebSocket client
+// Actions: "subscribe" and "unsubscribe"
+type wsClientMessage struct {
+	Action     string  `json:"action"`
+	AccountIDs []int64 `json:"account_ids"`
+}
+
+// wsServerMessage is a message pushed to a WebSocket client
+// Types:
+//   - "subscribed" / "unsubscribed": acknowledges a request with the affected account IDs
+//   - "balance": an account's balance after a transfer (or its current balance on subscribe)
+//   - "error": a rejected request; the connection stays open
+type wsServerMessage struct {
+	Type          string  `json:"type"`
+	AccountIDs    []int64 `json:"account_ids,omitempty"`
+	AccountID     int64   `json:"account_id,omitempty"`
+	Balance       string  `json:"balance,omitempty"`
+	Sequence      int64   `json:"sequence,omitempty"`
+	TransactionID int64   `json:"transaction_id,omitempty"`
+	Message       string  `json:"message,omitempty"`
+}
+
+// BalanceFeed handles the /ws endpoint for live balance updates over WebSocket
+// Clients subscribe to account IDs and receive a balance message whenever a transfer touches one
+// of them, starting with the current balance at subscription time
+// Example client message: {"action": "subscribe", "account_ids": [123, 456]}
+// Example server message: {"type": "balance", "account_id": 123, "balance": "90.5", "sequence": 8, "transaction_id": 43}
+// Sequence numbers increase with every movement on the account; messages never go backwards.
+// A connection may cover up to 100 accounts. A client that falls too far behind is disconnected
+// (close code 1013, try again later) and should reconnect and resubscribe. Connections that stop
+// answering pings are closed by the reaper (close code 1001, going away)
+func (h *Handler) BalanceFeed(w http.ResponseWriter, r *http.Request) {
+	if h.broker == nil {
+		http.Error(w, "Streaming unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	sub := h.broker.Subscribe(0)
+	defer sub.Close()
+
+	// The reader goroutine handles client requests; all writes happen on this goroutine
+	replies := make(chan []wsServerMessage)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(wsMaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			sub.Touch()
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			var msg wsClientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			sub.Touch()
+			select {
+			case replies <- h.handleFeedMessage(sub, msg):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	// lastSequence suppresses updates older than one already sent for the account
+	lastSequence := make(map[int64]int64)
+	send := func(messages ...wsServerMessage) bool {
+		for _, m := range messages {
+			if m.Type == "balance" {
+				if last, ok := lastSequence[m.AccountID]; ok && m.Sequence <= last {
+					continue
+				}
+				lastSequence[m.AccountID] = m.Sequence
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(m); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case messages := <-replies:
+			if !send(messages...) {
+				return
+			}
+		case t, ok := <-sub.C:
+			if !ok {
+				// Reaped subscriptions belong to connections presumed dead; the close is best effort
+				closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "connection idle")
+				if sub.Overflowed() {
+					closing = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow, resubscribe")
+				}
+				conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				conn.WriteMessage(websocket.CloseMessage, closing)
+				return
+			}
+			if !send(h.balanceUpdates(sub, t)...) {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleFeedMessage applies a subscribe/unsubscribe request and returns the messages to send
+func (h *Handler) handleFeedMessage(sub *pubsub.Subscription, msg wsClientMessage) []wsServerMessage {
+	switch msg.Action {
+	case "subscribe":
+		if len(msg.AccountIDs) == 0 {
+			return []wsServerMessage{{Type: "error", Message: "account_ids is required"}}
+		}
+		if sub.Accounts()+len(msg.AccountIDs) > wsMaxSubscriptions {
+			return []wsServerMessage{{Type: "error", Message: "too many subscriptions"}}
+		}
+
+		// Load current balances first so unknown accounts reject the whole request
+		snapshots := make([]wsServerMessage, 0, len(msg.AccountIDs))
+		for _, id := range msg.AccountIDs {
+			account, err := h.accounts.GetAccount(id)
+			if err != nil {
+				if errors.Is(err, service.ErrAccountNotFound) {
+					return []wsServerMessage{{Type: "error", Message: "Account not found", AccountID: id}}
+				}
+				slog.Error("WebSocket subscribe error", "account_id", id, "error", err)
+				return []wsServerMessage{{Type: "error", Message: "Internal server error"}}
+			}
+			snapshots = append(snapshots, balanceMessage(account, 0))
+		}
+		sub.Add(msg.AccountIDs...)
+		return append([]wsServerMessage{{Type: "subscribed", AccountIDs: msg.AccountIDs}}, snapshots...)
+	case "unsubscribe":
+		sub.Remove(msg.AccountIDs...)
+		return []wsServerMessage{{Type: "unsubscribed", AccountIDs: msg.AccountIDs}}
+	default:
+		return []wsServerMessage{{Type: "error", Message: "unknown action"}}
+	}
+}
+
+// balanceUpdates builds balance messages for the subscribed accounts touched by a transfer
+// Balances are read after the transfer committed, so they may already include later transfers;
+// the sequence number lets clients (and the sender) order updates
+func (h *Handler) balanceUpdates(sub *pubsub.Subscription, t models.Transaction) []wsServerMessage {
+	var messages []wsServerMessage
+	for _, id := range []int64{t.SourceAccountID, t.DestinationAccountID} {
+		if !sub.Interested(id) {
+			continue
+		}
+		account, err := h.accountRepo.GetAccount(id)
+		if err != nil {
+			slog.Error("WebSocket balance lookup error", "account_id", id, "error", err)
+			continue
+		}
+		messages = append(messages, balanceMessage(account, t.ID))
+	}
+	return messages
+}
+
+// balanceMessage converts an account into a balance message
+func balanceMessage(account *models.Account, transactionID int64) wsServerMessage {
+	return wsServerMessage{
+		Type:          "balance",
+		AccountID:     account.AccountID,
+		Balance:       account.Balance.String(),
+		Sequence:      account.Sequence,
+		TransactionID: transactionID,
+	}
+}
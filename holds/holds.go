@@ -0,0 +1,225 @@
+// Package holds implements two-phase transfers. A hold reserves funds on a source account for a
+// transfer to a destination: the reserved amount is no longer available to other transfers, but
+// the balance does not change until the hold is captured. Capturing submits the transfer through
+// the transfer service, so it is validated, value-dated and rule-checked exactly like an API
+// transfer, and ends the hold in the same atomic write. Releasing frees the funds without a
+// transfer.
+//
+// Every hold expires: an uncaptured hold past its expiry can no longer be captured and is released
+// by the Manager's expiry loop, so abandoned authorizations do not lock funds indefinitely.
+package holds
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// Defaults
+const (
+	// DefaultTTL is how long a hold lasts when the request does not set an expiry
+	DefaultTTL = 7 * 24 * time.Hour
+
+	// DefaultMaxTTL is the longest expiry a hold may be given
+	DefaultMaxTTL = 30 * 24 * time.Hour
+
+	// DefaultExpiryInterval is how often expired holds are released
+	DefaultExpiryInterval = time.Minute
+
+	// maxExpiredPerRun caps the holds expired by one run; the rest are picked up by the next
+	maxExpiredPerRun = 500
+)
+
+// metrics exposes hold counters under "holds" at /debug/vars
+//   - expired: holds released by the expiry loop
+var metrics = expvar.NewMap("holds")
+
+// Config controls hold lifetimes and expiry
+type Config struct {
+	// TTL is the lifetime of a hold created without an expiry
+	TTL time.Duration
+
+	// MaxTTL is the longest lifetime a hold may be given
+	MaxTTL time.Duration
+
+	// ExpiryInterval is how often expired holds are released; funds of an expired hold become
+	// available up to this late, although the hold can no longer be captured
+	ExpiryInterval time.Duration
+}
+
+// LoadConfig reads the hold configuration from the environment
+// Variables:
+//   - HOLD_TTL (168h): Lifetime of a hold created without expires_at
+//   - HOLD_MAX_TTL (720h): Longest lifetime a hold may be given
+//   - HOLD_EXPIRY_INTERVAL (1m): How often expired holds are released
+//
+// Returns an error for malformed values or a TTL above the maximum
+func LoadConfig() (Config, error) {
+	config := Config{TTL: DefaultTTL, MaxTTL: DefaultMaxTTL, ExpiryInterval: DefaultExpiryInterval}
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+	}{{"HOLD_TTL", &config.TTL}, {"HOLD_MAX_TTL", &config.MaxTTL}, {"HOLD_EXPIRY_INTERVAL", &config.ExpiryInterval}} {
+		if value := os.Getenv(setting.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return Config{}, fmt.Errorf("invalid %s %q", setting.name, value)
+			}
+			*setting.value = d
+		}
+	}
+	if config.TTL > config.MaxTTL {
+		return Config{}, fmt.Errorf("HOLD_TTL %s exceeds HOLD_MAX_TTL %s", config.TTL, config.MaxTTL)
+	}
+	return config, nil
+}
+
+// Manager creates, captures, releases and expires holds
+// Safe for concurrent use
+type Manager struct {
+	repo      database.HoldRepositoryInterface
+	transfers *service.TransferService
+	config    Config
+	now       func() time.Time
+}
+
+// NewManager creates a manager storing holds in repo and capturing them through transfers
+func NewManager(repo database.HoldRepositoryInterface, transfers *service.TransferService, config Config) *Manager {
+	return &Manager{repo: repo, transfers: transfers, config: config, now: time.Now}
+}
+
+// Create validates a hold and reserves its amount on the source account
+// The amount and transfer type are validated like a transfer made now; the hold expires at
+// req.ExpiresAt, or after the configured TTL if unset
+// Returns the active hold, or one of:
+//   - *service.ValidationError: Invalid amount, transfer type or expiry
+//   - service.ErrSourceNotFound, service.ErrDestinationNotFound: An account does not exist
+//   - service.ErrSourceFrozen: The source account is under a compliance hold
+//   - service.ErrInsufficientBalance: The source's available balance does not cover the amount
+//   - any other error: Storage failure
+func (m *Manager) Create(ctx context.Context, req models.CreateHoldRequest) (*models.Hold, error) {
+	transfer, err := m.transfers.Prepare(models.CreateTransactionRequest{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               req.Amount,
+		TransferType:         req.TransferType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	expiresAt := now.Add(m.config.TTL)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, &service.ValidationError{Message: "expires_at must be in the future"}
+		}
+		if req.ExpiresAt.After(now.Add(m.config.MaxTTL)) {
+			return nil, &service.ValidationError{Message: fmt.Sprintf("expires_at may be at most %s ahead", m.config.MaxTTL)}
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	hold, err := m.repo.CreateHold(ctx, models.Hold{
+		SourceAccountID:      transfer.SourceAccountID,
+		DestinationAccountID: transfer.DestinationAccountID,
+		Amount:               transfer.Amount,
+		TransferType:         transfer.TransferType,
+		Tenant:               req.Tenant,
+		ExpiresAt:            expiresAt.UTC(),
+	})
+	return hold, translate(err)
+}
+
+// Get returns a hold or service.ErrHoldNotFound
+func (m *Manager) Get(ctx context.Context, id int64) (*models.Hold, error) {
+	hold, err := m.repo.GetHold(ctx, id)
+	return hold, translate(err)
+}
+
+// List returns up to limit holds on a source account, newest first, below beforeID if set
+func (m *Manager) List(ctx context.Context, accountID, beforeID int64, limit int) ([]models.Hold, error) {
+	return m.repo.ListHolds(ctx, accountID, beforeID, limit)
+}
+
+// Capture books the transfer a hold reserved funds for and ends the hold
+// amount defaults to the whole hold; a smaller amount is transferred and the rest released
+// Returns the captured hold and the booked transaction, or:
+//   - service.ErrHoldNotFound, service.ErrHoldNotActive, service.ErrHoldExpired
+//   - service.ErrHoldExceeded: amount is larger than the hold
+//   - any error of service.TransferService.Transfer, e.g. a rule that rejects the transfer
+func (m *Manager) Capture(ctx context.Context, id int64, amount string) (*models.Hold, *models.Transaction, error) {
+	hold, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hold.Status != models.HoldActive {
+		return nil, nil, service.ErrHoldNotActive
+	}
+
+	transaction, err := m.transfers.Transfer(hold.CaptureRequest(amount))
+	if err != nil {
+		return nil, nil, err
+	}
+	if hold, err = m.Get(ctx, id); err != nil {
+		return nil, nil, err
+	}
+	return hold, transaction, nil
+}
+
+// Release ends an active hold without a transfer and frees its amount
+// Returns the released hold, service.ErrHoldNotFound or service.ErrHoldNotActive
+func (m *Manager) Release(ctx context.Context, id int64) (*models.Hold, error) {
+	hold, err := m.repo.ReleaseHold(ctx, id)
+	return hold, translate(err)
+}
+
+// ExpireDue releases the holds that are past their expiry
+// Returns the number of holds expired
+func (m *Manager) ExpireDue(ctx context.Context) (int, error) {
+	expired, err := m.repo.ExpireHolds(ctx, m.now(), maxExpiredPerRun)
+	metrics.Add("expired", int64(len(expired)))
+	return len(expired), err
+}
+
+// Run expires holds every interval until ctx is cancelled
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	for {
+		if _, err := m.ExpireDue(ctx); err != nil {
+			slog.Error("Hold expiry error", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// repositoryErrors maps the hold repository's error messages to the service sentinels
+var repositoryErrors = map[string]error{
+	service.ErrSourceNotFound.Error():      service.ErrSourceNotFound,
+	service.ErrDestinationNotFound.Error(): service.ErrDestinationNotFound,
+	service.ErrSourceFrozen.Error():        service.ErrSourceFrozen,
+	service.ErrInsufficientBalance.Error(): service.ErrInsufficientBalance,
+	service.ErrHoldNotFound.Error():        service.ErrHoldNotFound,
+	service.ErrHoldNotActive.Error():       service.ErrHoldNotActive,
+}
+
+// translate converts a repository error into the matching service sentinel
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if sentinel, ok := repositoryErrors[err.Error()]; ok {
+		return sentinel
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package holds
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// newManager returns a manager on an in-memory store with accounts 1 (balance 100) and 2
+func newManager(t *testing.T) (*Manager, *memory.Store) {
+	t.Helper()
+	store := memory.NewStore()
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	config := Config{TTL: time.Hour, MaxTTL: 24 * time.Hour, ExpiryInterval: time.Minute}
+	return NewManager(store.Holds(), service.NewTransferService(store.Transactions()), config), store
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("HOLD_TTL", "2h")
+	t.Setenv("HOLD_MAX_TTL", "")
+	t.Setenv("HOLD_EXPIRY_INTERVAL", "")
+	if config, err := LoadConfig(); err != nil || config.TTL != 2*time.Hour || config.MaxTTL != DefaultMaxTTL || config.ExpiryInterval != DefaultExpiryInterval {
+		t.Errorf("Unexpected config %+v (%v)", config, err)
+	}
+	for name, value := range map[string]string{"HOLD_TTL": "1000h", "HOLD_MAX_TTL": "soon", "HOLD_EXPIRY_INTERVAL": "-1m"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := LoadConfig(); err == nil {
+				t.Errorf("Expected an error for %s=%s", name, value)
+			}
+		})
+	}
+}
+
+func TestManager_Create(t *testing.T) {
+	manager, store := newManager(t)
+	ctx := context.Background()
+
+	hold, err := manager.Create(ctx, models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "40.123456", Tenant: "acme"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hold.Status != models.HoldActive || !hold.Amount.Equal(decimal.RequireFromString("40.12346")) || hold.Tenant != "acme" ||
+		hold.TransferType != models.DefaultTransferType || hold.ExpiresAt.Sub(time.Now()) > time.Hour {
+		t.Errorf("Unexpected hold %+v", hold)
+	}
+	if account, _ := store.Accounts().GetAccount(1); !account.Held.Equal(hold.Amount) {
+		t.Errorf("Expected the hold amount to be held, got %s", account.Held)
+	}
+
+	var validation *service.ValidationError
+	past, far := time.Now().Add(-time.Minute), time.Now().Add(48*time.Hour)
+	for _, req := range []models.CreateHoldRequest{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "0"},
+		{SourceAccountID: 1, DestinationAccountID: 1, Amount: "1"},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", TransferType: "wire"},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", ExpiresAt: &past},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", ExpiresAt: &far},
+	} {
+		if _, err := manager.Create(ctx, req); !errors.As(err, &validation) {
+			t.Errorf("%+v: expected a validation error, got %v", req, err)
+		}
+	}
+	for _, tc := range []struct {
+		req  models.CreateHoldRequest
+		want error
+	}{
+		{models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "60"}, service.ErrInsufficientBalance},
+		{models.CreateHoldRequest{SourceAccountID: 9, DestinationAccountID: 2, Amount: "1"}, service.ErrSourceNotFound},
+		{models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 9, Amount: "1"}, service.ErrDestinationNotFound},
+	} {
+		if _, err := manager.Create(ctx, tc.req); !errors.Is(err, tc.want) {
+			t.Errorf("%+v: expected %v, got %v", tc.req, tc.want, err)
+		}
+	}
+}
+
+func TestManager_CaptureRelease(t *testing.T) {
+	manager, store := newManager(t)
+	ctx := context.Background()
+	hold, _ := manager.Create(ctx, models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "80"})
+
+	captured, transaction, err := manager.Capture(ctx, hold.ID, "75")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if captured.Status != models.HoldCaptured || captured.TransactionID != transaction.ID || !transaction.Amount.Equal(decimal.NewFromInt(75)) {
+		t.Errorf("Unexpected capture %+v of %+v", captured, transaction)
+	}
+	if account, _ := store.Accounts().GetAccount(1); !account.Balance.Equal(decimal.NewFromInt(25)) || !account.Available().Equal(decimal.NewFromInt(25)) {
+		t.Errorf("Expected the uncaptured 5 to be released, got balance %s available %s", account.Balance, account.Available())
+	}
+	if _, _, err := manager.Capture(ctx, hold.ID, ""); !errors.Is(err, service.ErrHoldNotActive) {
+		t.Errorf("Expected ErrHoldNotActive, got %v", err)
+	}
+
+	second, _ := manager.Create(ctx, models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10"})
+	if _, _, err := manager.Capture(ctx, second.ID, "11"); !errors.Is(err, service.ErrHoldExceeded) {
+		t.Errorf("Expected ErrHoldExceeded, got %v", err)
+	}
+	if released, err := manager.Release(ctx, second.ID); err != nil || released.Status != models.HoldReleased {
+		t.Errorf("Expected a released hold, got %+v (%v)", released, err)
+	}
+	if _, err := manager.Release(ctx, second.ID); !errors.Is(err, service.ErrHoldNotActive) {
+		t.Errorf("Expected ErrHoldNotActive, got %v", err)
+	}
+	if _, _, err := manager.Capture(ctx, 99, ""); !errors.Is(err, service.ErrHoldNotFound) {
+		t.Errorf("Expected ErrHoldNotFound, got %v", err)
+	}
+}
+
+func TestManager_ExpireDue(t *testing.T) {
+	manager, store := newManager(t)
+	ctx := context.Background()
+	hold, _ := manager.Create(ctx, models.CreateHoldRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "50"})
+
+	if expired, err := manager.ExpireDue(ctx); err != nil || expired != 0 {
+		t.Fatalf("Expected nothing to expire yet, got %d (%v)", expired, err)
+	}
+	manager.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if expired, err := manager.ExpireDue(ctx); err != nil || expired != 1 {
+		t.Fatalf("Expected the hold to expire, got %d (%v)", expired, err)
+	}
+	if got, _ := manager.Get(ctx, hold.ID); got.Status != models.HoldExpired {
+		t.Errorf("Expected an expired hold, got %+v", got)
+	}
+	if account, _ := store.Accounts().GetAccount(1); !account.Available().Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Expected the funds to be available again, got %s", account.Available())
+	}
+	if _, _, err := manager.Capture(ctx, hold.ID, ""); !errors.Is(err, service.ErrHoldNotActive) {
+		t.Errorf("Expected an expired hold not to be captured, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package limits manages per-account transfer limits: a maximum amount per transfer, and a
+// maximum total amount and number of transfers debiting the account per UTC day. The limits are
+// stored by the repository and enforced by the transaction repositories inside each transfer's
+// atomic write, under the source account's lock, so concurrent transfers cannot exceed a daily
+// limit between them. Transfers reversing a returned transfer are not limited.
+package limits
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// Manager reads and changes transfer limits
+// Safe for concurrent use
+type Manager struct {
+	repo       database.LimitRepositoryInterface
+	accounts   database.AccountRepositoryInterface
+	currencies *models.Currencies
+	now        func() time.Time
+}
+
+// NewManager creates a manager storing limits in repo; accounts is used to check amounts against
+// the account's currency precision, of the built-in currencies until WithCurrencies
+func NewManager(repo database.LimitRepositoryInterface, accounts database.AccountRepositoryInterface) *Manager {
+	return &Manager{repo: repo, accounts: accounts, now: time.Now}
+}
+
+// WithCurrencies sets the supported currencies limit amounts are checked against; nil keeps the
+// built-in ones
+// Returns the manager to allow chaining after NewManager
+func (m *Manager) WithCurrencies(currencies *models.Currencies) *Manager {
+	m.currencies = currencies
+	return m
+}
+
+// Get returns an account's limits with what it has debited so far today
+// Returns service.ErrAccountNotFound or a storage error
+func (m *Manager) Get(ctx context.Context, accountID int64) (*models.TransferLimitsResponse, error) {
+	limits, err := m.repo.GetLimits(ctx, accountID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return m.report(ctx, *limits)
+}
+
+// Set replaces an account's limits; an omitted field removes that limit
+// Validation rules:
+//   - Amounts must be valid, non-negative decimals with no more decimal places than the account's
+//     currency allows
+//   - daily_count must be non-negative
+//
+// Returns the new limits with today's usage, a *service.ValidationError,
+// service.ErrAccountNotFound or a storage error
+func (m *Manager) Set(ctx context.Context, accountID int64, req models.SetTransferLimitsRequest) (*models.TransferLimitsResponse, error) {
+	account, err := m.accounts.GetAccount(accountID)
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	limits := models.TransferLimits{AccountID: accountID, DailyCount: req.DailyCount}
+	for _, field := range []struct {
+		name  string
+		value *string
+		limit **decimal.Decimal
+	}{{"max_amount", req.MaxAmount, &limits.MaxAmount}, {"daily_amount", req.DailyAmount, &limits.DailyAmount}} {
+		if field.value == nil {
+			continue
+		}
+		amount, err := decimal.NewFromString(*field.value)
+		if err != nil {
+			return nil, &service.ValidationError{Message: fmt.Sprintf("Invalid %s format", field.name)}
+		}
+		if amount.IsNegative() {
+			return nil, &service.ValidationError{Message: fmt.Sprintf("%s cannot be negative", field.name)}
+		}
+		if !m.currencies.Fits(account.Currency, amount) {
+			scale, _ := m.currencies.Scale(account.Currency)
+			return nil, &service.ValidationError{Message: fmt.Sprintf("%s has more than %d decimal places", field.name, scale)}
+		}
+		*field.limit = &amount
+	}
+	if limits.DailyCount != nil && *limits.DailyCount < 0 {
+		return nil, &service.ValidationError{Message: "daily_count cannot be negative"}
+	}
+
+	stored, err := m.repo.SetLimits(ctx, limits)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return m.report(ctx, *stored)
+}
+
+// report adds today's usage to limits
+func (m *Manager) report(ctx context.Context, limits models.TransferLimits) (*models.TransferLimitsResponse, error) {
+	day := models.LimitDay(m.now())
+	amount, count, err := m.repo.DailyUsage(ctx, limits.AccountID, day)
+	if err != nil {
+		return nil, err
+	}
+	response := models.NewTransferLimitsResponse(limits, day, amount, count)
+	return &response, nil
+}
+
+// translate converts the repositories' "account not found" into service.ErrAccountNotFound
+func translate(err error) error {
+	if err != nil && err.Error() == service.ErrAccountNotFound.Error() {
+		return service.ErrAccountNotFound
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+func stringPtr(s string) *string { return &s }
+
+func intPtr(i int) *int { return &i }
+
+func TestManager_Set(t *testing.T) {
+	store := memory.NewStore()
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "JPY"})
+	manager := NewManager(store.Limits(), store.Accounts())
+	manager.now = func() time.Time { return time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	response, err := manager.Set(ctx, 1, models.SetTransferLimitsRequest{MaxAmount: stringPtr("500"), DailyCount: intPtr(3)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *response.MaxAmount != "500" || response.DailyAmount != nil || *response.DailyCount != 3 || response.Usage.Day != "2026-10-15" {
+		t.Errorf("Unexpected limits %+v", response)
+	}
+
+	var validation *service.ValidationError
+	for _, req := range []models.SetTransferLimitsRequest{
+		{MaxAmount: stringPtr("abc")},
+		{DailyAmount: stringPtr("-1")},
+		{DailyAmount: stringPtr("10.5")},
+		{DailyCount: intPtr(-1)},
+	} {
+		if _, err := manager.Set(ctx, 1, req); !errors.As(err, &validation) {
+			t.Errorf("Expected a validation error for %+v, got %v", req, err)
+		}
+	}
+	if _, err := manager.Set(ctx, 9, models.SetTransferLimitsRequest{}); !errors.Is(err, service.ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+	if _, err := manager.Get(ctx, 9); !errors.Is(err, service.ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+
+	if response, err := manager.Set(ctx, 1, models.SetTransferLimitsRequest{}); err != nil || response.MaxAmount != nil || response.DailyCount != nil {
+		t.Errorf("Expected an empty request to remove the limits, got %+v (%v)", response, err)
+	}
+}
+
+func TestManager_Enforced(t *testing.T) {
+	store := memory.NewStore()
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(1000)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	manager := NewManager(store.Limits(), store.Accounts())
+	transfers := service.NewTransferService(store.Transactions())
+	ctx := context.Background()
+
+	if _, err := manager.Set(ctx, 1, models.SetTransferLimitsRequest{MaxAmount: stringPtr("100"), DailyAmount: stringPtr("250"), DailyCount: intPtr(3)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	transfer := func(amount string) error {
+		_, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: amount})
+		return err
+	}
+
+	if err := transfer("100.01"); !errors.Is(err, service.ErrAmountLimit) {
+		t.Errorf("Expected ErrAmountLimit, got %v", err)
+	}
+	for _, amount := range []string{"100", "75"} {
+		if err := transfer(amount); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := transfer("75.01"); !errors.Is(err, service.ErrDailyAmountLimit) {
+		t.Errorf("Expected ErrDailyAmountLimit, got %v", err)
+	}
+	if err := transfer("25"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := transfer("0.01"); !errors.Is(err, service.ErrDailyCountLimit) {
+		t.Errorf("Expected ErrDailyCountLimit, got %v", err)
+	}
+
+	response, err := manager.Get(ctx, 1)
+	if err != nil || response.Usage.Amount != "200" || response.Usage.Count != 3 {
+		t.Errorf("Unexpected usage %+v (%v)", response, err)
+	}
+	// The destination account is not limited
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 2, DestinationAccountID: 1, Amount: "200"}); err != nil {
+		t.Errorf("Expected an unlimited account to transfer, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+// Package listeners configures the sockets the server accepts connections on. By default the
+// whole API is served on one TCP port; LISTENERS replaces it with any number of TCP ports and
+// Unix domain sockets, each serving a scope of the routes with its own middleware opt-outs. A
+// sidecar-proxy deployment can then expose the public API on the proxied port and the admin API
+// only on a Unix socket readable by operators, without opening another TCP port
+package listeners
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"internal-transfers/routes"
+)
+
+// Route scopes of a listener
+const (
+	// AllRoutes serves every route
+	AllRoutes = "all"
+
+	// PublicRoutes serves every route except the admin API
+	PublicRoutes = "public"
+
+	// AdminRoutes serves only the admin API (the routes under /admin/)
+	AdminRoutes = "admin"
+
+	// RedirectRoutes serves no route: every request is redirected to HTTPS (HTTP_REDIRECT_ADDR)
+	// It is not accepted in LISTENERS
+	RedirectRoutes = "redirect"
+)
+
+// adminPrefix is the path prefix of the admin API
+const adminPrefix = "/admin/"
+
+// DefaultPort is the TCP port served when neither LISTENERS nor PORT is set
+const DefaultPort = "8080"
+
+// Listener is one socket the server accepts connections on
+type Listener struct {
+	// Network is "tcp" or "unix"
+	Network string
+
+	// Address is host:port for TCP and the socket file path for Unix sockets
+	Address string
+
+	// Routes is the scope of routes served: AllRoutes, PublicRoutes or AdminRoutes
+	Routes string
+
+	// Skip names middleware chain entries (e.g. "access_log" or "usage") this listener's routes
+	// skip, in addition to each route's own opt-outs
+	Skip []string
+
+	// Mode is the permission of a Unix socket file; zero keeps the umask default
+	Mode fs.FileMode
+}
+
+// String identifies the listener in logs, e.g. "tcp :8080" or "unix /run/transfers/admin.sock"
+func (l Listener) String() string {
+	return l.Network + " " + l.Address
+}
+
+// Serves reports whether route is in the listener's scope
+func (l Listener) Serves(route routes.Route) bool {
+	admin := strings.HasPrefix(route.Path, adminPrefix)
+	switch l.Routes {
+	case AdminRoutes:
+		return admin
+	case PublicRoutes:
+		return !admin
+	case RedirectRoutes:
+		return false
+	default:
+		return true
+	}
+}
+
+// Listen opens the listener's socket
+// A socket file left behind by a previous process is removed first; any other file at the path
+// is an error rather than being overwritten. The socket file is removed again when the returned
+// listener is closed
+func (l Listener) Listen() (net.Listener, error) {
+	if l.Network != "unix" {
+		return net.Listen(l.Network, l.Address)
+	}
+	if info, err := os.Lstat(l.Address); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", l.Address)
+		}
+		if err := os.Remove(l.Address); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", l.Address)
+	if err != nil {
+		return nil, err
+	}
+	if l.Mode != 0 {
+		if err := os.Chmod(l.Address, l.Mode); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+		}
+	}
+	return listener, nil
+}
+
+// Config lists the sockets the server listens on
+type Config struct {
+	Listeners []Listener
+}
+
+// LoadConfig reads the listener configuration from the environment
+// Variables:
+//   - LISTENERS (unset): Comma separated listener URLs (see Parse); replaces PORT when set
+//   - PORT (8080): TCP port serving every route when LISTENERS is unset
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	value := os.Getenv("LISTENERS")
+	if strings.TrimSpace(value) == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = DefaultPort
+		}
+		return Config{Listeners: []Listener{{Network: "tcp", Address: ":" + port, Routes: AllRoutes}}}, nil
+	}
+	var config Config
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		listener, err := Parse(item)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid LISTENERS entry %q: %w", item, err)
+		}
+		if seen[listener.String()] {
+			return Config{}, fmt.Errorf("invalid LISTENERS: %s is listed twice", listener)
+		}
+		seen[listener.String()] = true
+		config.Listeners = append(config.Listeners, listener)
+	}
+	if len(config.Listeners) == 0 {
+		return Config{}, fmt.Errorf("invalid LISTENERS %q", value)
+	}
+	return config, nil
+}
+
+// Parse reads one listener URL: tcp://host:port or unix:///path/to/socket, with the optional
+// query parameters
+//   - routes: Scope served, "all" (default), "public" or "admin"
+//   - skip: Middleware chain entry the listener skips; repeat it for several
+//   - mode: Octal permission of a Unix socket file, e.g. 0660
+//
+// Example: unix:///run/internal-transfers/admin.sock?routes=admin&mode=0660&skip=usage
+func Parse(spec string) (Listener, error) {
+	parsed, err := url.Parse(spec)
+	if err != nil {
+		return Listener{}, err
+	}
+	listener := Listener{Network: parsed.Scheme, Routes: AllRoutes}
+	switch parsed.Scheme {
+	case "tcp":
+		if parsed.Path != "" {
+			return Listener{}, fmt.Errorf("unexpected path %q (expected tcp://host:port)", parsed.Path)
+		}
+		if _, _, err := net.SplitHostPort(parsed.Host); err != nil {
+			return Listener{}, fmt.Errorf("invalid address %q (expected tcp://host:port)", parsed.Host)
+		}
+		listener.Address = parsed.Host
+	case "unix":
+		if parsed.Host != "" || parsed.Path == "" {
+			return Listener{}, fmt.Errorf("invalid socket path (expected unix:///path/to/socket)")
+		}
+		listener.Address = parsed.Path
+	default:
+		return Listener{}, fmt.Errorf("unsupported scheme %q (expected tcp or unix)", parsed.Scheme)
+	}
+
+	query := parsed.Query()
+	for name := range query {
+		switch name {
+		case "routes", "skip", "mode":
+		default:
+			return Listener{}, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+	if value := query.Get("routes"); value != "" {
+		switch value {
+		case AllRoutes, PublicRoutes, AdminRoutes:
+			listener.Routes = value
+		default:
+			return Listener{}, fmt.Errorf("invalid routes %q (expected all, public or admin)", value)
+		}
+	}
+	listener.Skip = query["skip"]
+	if value := query.Get("mode"); value != "" {
+		if listener.Network != "unix" {
+			return Listener{}, fmt.Errorf("mode applies to unix sockets only")
+		}
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || mode > 0o777 {
+			return Listener{}, fmt.Errorf("invalid mode %q (expected octal permissions, e.g. 0660)", value)
+		}
+		listener.Mode = fs.FileMode(mode)
+	}
+	return listener, nil
+}
@@ -0,0 +1,133 @@
+package listeners
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"internal-transfers/routes"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want Listener
+	}{
+		{"tcp://:8080", Listener{Network: "tcp", Address: ":8080", Routes: AllRoutes}},
+		{"tcp://127.0.0.1:9090?routes=public&skip=access_log&skip=usage",
+			Listener{Network: "tcp", Address: "127.0.0.1:9090", Routes: PublicRoutes, Skip: []string{"access_log", "usage"}}},
+		{"unix:///run/transfers/admin.sock?routes=admin&mode=0660",
+			Listener{Network: "unix", Address: "/run/transfers/admin.sock", Routes: AdminRoutes, Mode: 0o660}},
+	} {
+		got, err := Parse(tc.spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %+v, got %+v", tc.spec, tc.want, got)
+		}
+	}
+
+	for _, spec := range []string{
+		"http://:8080",
+		"tcp://localhost",
+		"tcp://:8080/path",
+		"unix://relative.sock",
+		"tcp://:8080?routes=private",
+		"tcp://:8080?mode=0600",
+		"unix:///tmp/a.sock?mode=999",
+		"tcp://:8080?port=1",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("LISTENERS", "")
+	t.Setenv("PORT", "9000")
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []Listener{{Network: "tcp", Address: ":9000", Routes: AllRoutes}}; !reflect.DeepEqual(config.Listeners, want) {
+		t.Errorf("Expected the PORT listener, got %+v", config.Listeners)
+	}
+
+	t.Setenv("LISTENERS", "tcp://:8080?routes=public, unix:///run/admin.sock?routes=admin")
+	config, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(config.Listeners) != 2 || config.Listeners[1].String() != "unix /run/admin.sock" {
+		t.Errorf("Unexpected listeners %+v", config.Listeners)
+	}
+
+	t.Setenv("LISTENERS", "tcp://:8080,tcp://:8080?routes=admin")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "twice") {
+		t.Errorf("Expected a duplicate listener error, got %v", err)
+	}
+}
+
+func TestListener_Serves(t *testing.T) {
+	health := routes.Route{Path: "/health"}
+	freeze := routes.Route{Path: "/admin/accounts/{account_id}/freeze"}
+	for _, tc := range []struct {
+		scope         string
+		health, admin bool
+	}{
+		{AllRoutes, true, true},
+		{PublicRoutes, true, false},
+		{AdminRoutes, false, true},
+		{RedirectRoutes, false, false},
+	} {
+		l := Listener{Routes: tc.scope}
+		if l.Serves(health) != tc.health || l.Serves(freeze) != tc.admin {
+			t.Errorf("%s: expected health=%v admin=%v", tc.scope, tc.health, tc.admin)
+		}
+	}
+}
+
+func TestListener_ListenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket file modes are not supported on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	l := Listener{Network: "unix", Address: path, Routes: AdminRoutes, Mode: 0o600}
+
+	first, err := l.Listen()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("Expected a 0600 socket file, got %v %v", info, err)
+	}
+
+	// A stale socket of a crashed process is replaced
+	if unix, ok := first.(interface{ SetUnlinkOnClose(bool) }); ok {
+		unix.SetUnlinkOnClose(false)
+	}
+	first.Close()
+	second, err := l.Listen()
+	if err != nil {
+		t.Fatalf("Expected the stale socket to be replaced, got %v", err)
+	}
+	second.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket file to be removed on close, got %v", err)
+	}
+
+	// Any other file is left alone
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Listen(); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("Expected a not-a-socket error, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package logging configures the service's structured, leveled logger. Log lines are written
+// through log/slog, as human-readable key=value text by default or as one JSON object per line
+// for log pipelines in production. Records logged with a request's context carry its request ID
+// (see middleware.ContextHandler), and the standard library logger is routed through the same
+// handler, so every line of the process shares one format
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"internal-transfers/middleware"
+)
+
+// Output formats
+const (
+	// TextFormat writes key=value lines, for development and terminals
+	TextFormat = "text"
+
+	// JSONFormat writes one JSON object per line, for production log pipelines
+	JSONFormat = "json"
+
+	// CombinedFormat writes access log lines in the Combined Log Format of web servers, for
+	// tooling built around those logs; it applies to the access log only
+	CombinedFormat = "combined"
+)
+
+// Config controls the process logger
+type Config struct {
+	// Level is the minimum level written
+	Level slog.Level
+
+	// Format is TextFormat or JSONFormat
+	Format string
+}
+
+// LoadConfig reads the logging configuration from the environment
+// Variables:
+//   - LOG_LEVEL (info): Minimum level written: debug, info, warn or error
+//   - LOG_FORMAT (text): Output format: text or json
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	return LoadConfigFrom(os.Getenv)
+}
+
+// LoadConfigFrom reads the logging configuration with getenv, e.g. to validate a configuration
+// reload before it is applied (see LoadConfig)
+func LoadConfigFrom(getenv func(string) string) (Config, error) {
+	config := Config{Level: slog.LevelInfo, Format: TextFormat}
+	if value := getenv("LOG_LEVEL"); value != "" {
+		if err := config.Level.UnmarshalText([]byte(value)); err != nil {
+			return Config{}, fmt.Errorf("invalid LOG_LEVEL %q (expected debug, info, warn or error)", value)
+		}
+	}
+	if value := getenv("LOG_FORMAT"); value != "" {
+		switch format := strings.ToLower(value); format {
+		case TextFormat, JSONFormat:
+			config.Format = format
+		default:
+			return Config{}, fmt.Errorf("invalid LOG_FORMAT %q (expected text or json)", value)
+		}
+	}
+	return config, nil
+}
+
+// New returns a logger writing to w in the configured format and level
+func New(config Config, w io.Writer) *slog.Logger {
+	return newLogger(config.Format, config.Level, w)
+}
+
+// newLogger returns a logger writing to w in format, at the minimum level of leveler
+func newLogger(format string, leveler slog.Leveler, w io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{Level: leveler}
+	var handler slog.Handler
+	if format == JSONFormat {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+	return slog.New(middleware.ContextHandler(handler))
+}
+
+// AccessConfig controls the access log, the line logged per request
+type AccessConfig struct {
+	// Format is TextFormat, JSONFormat or CombinedFormat
+	Format string
+
+	// HealthChecks logs the requests of health checks too, which probes send every few seconds
+	HealthChecks bool
+}
+
+// LoadAccessConfig reads the access log configuration from the environment; the format defaults
+// to the one of process, the process logger's configuration
+// Variables:
+//   - ACCESS_LOG_FORMAT (LOG_FORMAT): Access log format: text, json or combined
+//   - ACCESS_LOG_HEALTH_CHECKS (false): Also log health check requests
+//
+// Returns an error for malformed values
+func LoadAccessConfig(process Config) (AccessConfig, error) {
+	config := AccessConfig{Format: process.Format}
+	if value := os.Getenv("ACCESS_LOG_FORMAT"); value != "" {
+		switch format := strings.ToLower(value); format {
+		case TextFormat, JSONFormat, CombinedFormat:
+			config.Format = format
+		default:
+			return AccessConfig{}, fmt.Errorf("invalid ACCESS_LOG_FORMAT %q (expected text, json or combined)", value)
+		}
+	}
+	if value := os.Getenv("ACCESS_LOG_HEALTH_CHECKS"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return AccessConfig{}, fmt.Errorf("invalid ACCESS_LOG_HEALTH_CHECKS %q", value)
+		}
+		config.HealthChecks = enabled
+	}
+	return config, nil
+}
+
+// AccessLog returns the access log middleware writing to w in the configured format, at the
+// process logger's level (see SetLevel) unless combined
+// healthChecks names the routes of the health checks, left out unless configured otherwise
+func AccessLog(config AccessConfig, w io.Writer, healthChecks ...string) middleware.Middleware {
+	var options middleware.AccessLogOptions
+	if config.Format == CombinedFormat {
+		options.Combined = w
+	} else {
+		options.Logger = newLogger(config.Format, &level, w)
+	}
+	if !config.HealthChecks {
+		options.Exclude = healthChecks
+	}
+	return middleware.NewAccessLog(options)
+}
+
+// level is the minimum level of the process logger and the access log, which SetLevel changes
+var level slog.LevelVar
+
+// Setup makes a logger writing to standard error the process default, for both log/slog and
+// the standard library log package
+func Setup(config Config) {
+	level.Set(config.Level)
+	slog.SetDefault(newLogger(config.Format, &level, os.Stderr))
+}
+
+// SetLevel changes the minimum level of the process logger and the access log while they run,
+// e.g. when the configuration is reloaded
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"internal-transfers/middleware"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_FORMAT", "")
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Level != slog.LevelInfo || config.Format != TextFormat {
+		t.Errorf("Unexpected default config %+v", config)
+	}
+
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_FORMAT", "JSON")
+	config, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Level != slog.LevelDebug || config.Format != JSONFormat {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	for name, env := range map[string][2]string{
+		"invalid level":  {"verbose", ""},
+		"invalid format": {"", "xml"},
+	} {
+		t.Setenv("LOG_LEVEL", env[0])
+		t.Setenv("LOG_FORMAT", env[1])
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Level: slog.LevelWarn, Format: JSONFormat}, &buf)
+
+	// Records below the level are dropped; records logged with a request's context carry its ID
+	var ctx context.Context
+	middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	logger.InfoContext(ctx, "dropped")
+	logger.WarnContext(ctx, "kept", "account_id", 7)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "kept" || line["account_id"] != float64(7) || line["request_id"] != middleware.RequestIDFromContext(ctx) {
+		t.Errorf("Unexpected log line %v", line)
+	}
+}
+
+func TestLoadAccessConfig(t *testing.T) {
+	process := Config{Level: slog.LevelInfo, Format: JSONFormat}
+	t.Setenv("ACCESS_LOG_FORMAT", "")
+	t.Setenv("ACCESS_LOG_HEALTH_CHECKS", "")
+	if config, err := LoadAccessConfig(process); err != nil || config.Format != JSONFormat || config.HealthChecks {
+		t.Errorf("Expected the process format without health checks, got %+v (%v)", config, err)
+	}
+	t.Setenv("ACCESS_LOG_FORMAT", "Combined")
+	t.Setenv("ACCESS_LOG_HEALTH_CHECKS", "true")
+	if config, err := LoadAccessConfig(process); err != nil || config.Format != CombinedFormat || !config.HealthChecks {
+		t.Errorf("Unexpected config %+v (%v)", config, err)
+	}
+	for _, env := range [][2]string{{"xml", ""}, {"", "sometimes"}} {
+		t.Setenv("ACCESS_LOG_FORMAT", env[0])
+		t.Setenv("ACCESS_LOG_HEALTH_CHECKS", env[1])
+		if _, err := LoadAccessConfig(process); err == nil {
+			t.Errorf("%v: expected an error", env)
+		}
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	defer SetLevel(slog.LevelInfo)
+	var buf bytes.Buffer
+	h := AccessLog(AccessConfig{Format: TextFormat}, &buf)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// The access log follows the process level as it changes
+	SetLevel(slog.LevelWarn)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/accounts", nil))
+	if buf.Len() != 0 {
+		t.Errorf("Expected no access log line at warn level, got %q", buf.String())
+	}
+	SetLevel(slog.LevelInfo)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/accounts", nil))
+	if !strings.Contains(buf.String(), "path=/accounts") {
+		t.Errorf("Expected an access log line at info level, got %q", buf.String())
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	serve := func(config AccessConfig) string {
+		var buf bytes.Buffer
+		h := AccessLog(config, &buf, "health")(ok)
+		for _, route := range []string{"create_transaction", "health"} {
+			middleware.WithRouteName(route)(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/"+route, nil))
+		}
+		return buf.String()
+	}
+
+	lines := strings.Split(strings.TrimSpace(serve(AccessConfig{Format: JSONFormat})), "\n")
+	var line map[string]interface{}
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &line) != nil || line["route"] != "create_transaction" {
+		t.Errorf("Expected one JSON line for the transfer, got %q", lines)
+	}
+	if logged := serve(AccessConfig{Format: CombinedFormat, HealthChecks: true}); strings.Count(logged, `"GET /health HTTP/1.1" 200 0`) != 1 {
+		t.Errorf("Expected combined lines including the health check, got %q", logged)
+	}
+}
@@ -1,14 +1,60 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"internal-transfers/attachments"
+	"internal-transfers/audit"
+	"internal-transfers/balances"
+	"internal-transfers/config"
+	"internal-transfers/console"
+	"internal-transfers/currencies"
+	"internal-transfers/cutoff"
 	"internal-transfers/database"
+	"internal-transfers/eventsource"
+	"internal-transfers/fixtures"
+	"internal-transfers/fx"
 	"internal-transfers/handlers"
+	"internal-transfers/holds"
+	"internal-transfers/listeners"
+	"internal-transfers/logging"
+	"internal-transfers/memory"
+	"internal-transfers/middleware"
+	"internal-transfers/models"
+	"internal-transfers/outbox"
+	"internal-transfers/pagination"
+	"internal-transfers/preauth"
+	"internal-transfers/pubsub"
+	"internal-transfers/reaper"
+	"internal-transfers/reconcile"
+	"internal-transfers/recurring"
+	"internal-transfers/retention"
+	"internal-transfers/routes"
+	"internal-transfers/rules"
+	"internal-transfers/sandbox"
+	"internal-transfers/settlement"
+	"internal-transfers/shutdown"
+	"internal-transfers/signing"
+	"internal-transfers/sla"
+	"internal-transfers/supervisor"
+	"internal-transfers/transport"
+	"internal-transfers/usage"
 )
 
 // getPort returns the port to listen on, defaulting to 8080
@@ -20,55 +66,1062 @@ func getPort() string {
 	return port
 }
 
+// Title and version of the API in its OpenAPI document and the generated SDKs
+const (
+	apiTitle   = "Internal Transfers API"
+	apiVersion = "1.0.0"
+)
+
+// Default per-route limits for the JSON API; request bodies are capped by MAX_REQUEST_BODY_BYTES
+// (see routes.LoadConfig) unless a route sets its own limit
+const (
+	defaultRouteTimeout = 10 * time.Second
+
+	// Partner acknowledgment files are uploaded whole and applied line by line
+	settlementAckTimeout   = time.Minute
+	settlementAckBodyLimit = 10 << 20
+
+	// Statements read up to 10000 transactions and render them before responding
+	statementTimeout = 30 * time.Second
+
+	// Reconciliation reads every account and transaction before responding
+	reconciliationTimeout = 5 * time.Minute
+)
+
+// apiVersions returns the registry of every served version of the API, oldest first, each under its
+// own path prefix; a new version gets its own route table, listing the handlers it keeps from the
+// previous one, and is mounted beside it
+// Version 1 is also served, deprecated, at the unprefixed paths that predate versioning
+func apiVersions(h *handlers.Handler) []*routes.Registry {
+	return []*routes.Registry{
+		routes.NewRegistry(identified(h, apiRoutes(h))...).WithPrefix("/v1").WithLegacyPaths(),
+	}
+}
+
+// identified wraps the handler of every route in h.Identified, so requests signed with an API key
+// are attributed to it whichever endpoint they call
+func identified(h *handlers.Handler, table []routes.Route) []routes.Route {
+	for i := range table {
+		table[i].Handler = h.Identified(table[i].Handler)
+	}
+	return table
+}
+
+// currentAPI returns the registry of the newest version of the API, the one the SDKs and the
+// release compatibility check describe
+func currentAPI(h *handlers.Handler) *routes.Registry {
+	versions := apiVersions(h)
+	return versions[len(versions)-1]
+}
+
+// apiRoutes declares every endpoint of version 1 of the API with its policy
+// This table is the single source of truth for routing, the OpenAPI document and metrics labels
+func apiRoutes(h *handlers.Handler) []routes.Route {
+	return []routes.Route{
+		// Account endpoints
+		{
+			Name: "create_account", Method: "POST", Path: "/accounts",
+			Summary: "Create an account with an initial balance",
+			Handler: h.CreateAccount, Timeout: defaultRouteTimeout,
+			Request: models.CreateAccountRequest{}, Status: http.StatusCreated,
+			Example:        models.CreateAccountRequest{AccountID: 123, InitialBalance: "100.00"},
+			IdempotencyKey: "account_id",
+		},
+		{
+			Name: "list_accounts", Method: "GET", Path: "/accounts",
+			Summary: "List accounts with pagination and filters",
+			Handler: h.ListAccounts, Timeout: defaultRouteTimeout,
+			Response: models.AccountListResponse{},
+		},
+		{
+			Name: "get_account_by_external_id", Method: "GET", Path: "/accounts/by-external-id/{external_id}",
+			Summary: "Get an account by its ID in an external system",
+			Handler: h.GetAccountByExternalID, Timeout: defaultRouteTimeout,
+			Response: models.AccountResponse{},
+		},
+		{
+			Name: "get_account", Method: "GET", Path: "/accounts/{account_id}",
+			Summary: "Get an account's balance, or its balance at a past time with as_of; 304 if If-None-Match lists its ETag",
+			Handler: h.GetAccount, Timeout: defaultRouteTimeout,
+			Response: models.AccountResponse{},
+		},
+		{
+			Name: "update_account", Method: "PATCH", Path: "/accounts/{account_id}",
+			Summary: "Update an account's metadata, tags and external ID (If-Match with the ETag for optimistic concurrency)",
+			Handler: h.UpdateAccount, Timeout: defaultRouteTimeout,
+			Request: models.UpdateAccountRequest{}, Response: models.AccountResponse{},
+			Example: models.UpdateAccountRequest{Metadata: map[string]interface{}{"cost_center": "CC-42"}, Tags: &[]string{"payroll"}},
+		},
+		{
+			Name: "account_balance_as_of", Method: "GET", Path: "/accounts/{account_id}/balance",
+			Summary: "An account's balance as recorded at one time (recorded_at) and effective at another (effective_at)",
+			Handler: h.GetBalanceAsOf, Timeout: defaultRouteTimeout,
+			Response: models.BalanceAsOfResponse{},
+		},
+		{
+			Name: "get_balance_history", Method: "GET", Path: "/accounts/{account_id}/balance-history",
+			Summary: "Time series of an account's balance, oldest first (filter by from and to; limit, cursor)",
+			Handler: h.GetBalanceHistory, Timeout: defaultRouteTimeout,
+			Response: models.BalanceHistoryResponse{},
+		},
+		{
+			Name: "get_statement", Method: "GET", Path: "/accounts/{account_id}/statement",
+			Summary: "An account statement for a period as CSV or PDF (from, to; format)",
+			Handler: h.GetStatement, Timeout: statementTimeout,
+		},
+		{
+			Name: "account_changes", Method: "GET", Path: "/accounts/{account_id}/changes",
+			Summary: "Ledger movements on an account after a sequence number (since_seq, limit) for incremental sync",
+			Handler: h.GetAccountChanges, Timeout: defaultRouteTimeout,
+			Response: models.AccountChangesResponse{},
+		},
+		{
+			Name: "account_events", Method: "GET", Path: "/accounts/{account_id}/events",
+			Summary: "An account's event stream with the balance after each event (after_seq, limit); STORAGE=eventsourced only",
+			Handler: h.GetAccountEvents, Timeout: defaultRouteTimeout,
+			Response: models.AccountEventListResponse{},
+		},
+		{
+			Name: "search_account_transactions", Method: "GET", Path: "/accounts/{account_id}/transactions",
+			Summary: "An account's transactions, filtered and ordered (reference, memo, created_from, created_before, min_amount, max_amount, direction, counterparty_id, order, limit)",
+			Handler: h.SearchAccountTransactions, Timeout: defaultRouteTimeout,
+			Response: models.TransactionListResponse{},
+		},
+		{
+			// Long-lived Server-Sent Events stream, so no timeout
+			Name: "stream_transactions", Method: "GET", Path: "/accounts/{account_id}/transactions/stream",
+			Summary: "Stream newly committed transfers for an account (text/event-stream)",
+			Handler: h.StreamTransactions,
+		},
+
+		// Transaction endpoint
+		{
+			Name: "create_transaction", Method: "POST", Path: "/transactions",
+			Summary: "Transfer money between two accounts",
+			Handler: h.Signed(h.CreateTransaction), Timeout: defaultRouteTimeout,
+			Request: models.CreateTransactionRequest{}, Response: models.TransactionResponse{}, Status: http.StatusCreated,
+			Example:        models.CreateTransactionRequest{SourceAccountID: 123, DestinationAccountID: 456, Amount: "50.00", Reference: "INV-1001"},
+			IdempotencyKey: "reference",
+		},
+
+		// Authorization holds: reserve funds now, capture or release later
+		{
+			Name: "create_hold", Method: "POST", Path: "/holds",
+			Summary: "Reserve funds on an account for a later transfer (reduces available, not actual, balance)",
+			Handler: h.CreateHold, Timeout: defaultRouteTimeout,
+			Request: models.CreateHoldRequest{}, Response: models.HoldResponse{}, Status: http.StatusCreated,
+			Example: models.CreateHoldRequest{SourceAccountID: 123, DestinationAccountID: 456, Amount: "80.00"},
+		},
+		{
+			Name: "get_hold", Method: "GET", Path: "/holds/{hold_id}",
+			Summary: "Get a hold and its status",
+			Handler: h.GetHold, Timeout: defaultRouteTimeout,
+			Response: models.HoldResponse{},
+		},
+		{
+			Name: "capture_hold", Method: "POST", Path: "/holds/{hold_id}/capture",
+			Summary: "Book the held transfer, optionally for a smaller amount, releasing the rest",
+			Handler: h.Signed(h.CaptureHold), Timeout: defaultRouteTimeout,
+			Request: models.CaptureHoldRequest{}, Response: models.CaptureHoldResponse{}, Status: http.StatusCreated,
+			Example: models.CaptureHoldRequest{Amount: "75.00"},
+		},
+		{
+			Name: "release_hold", Method: "POST", Path: "/holds/{hold_id}/release",
+			Summary: "Free the held funds without a transfer",
+			Handler: h.ReleaseHold, Timeout: defaultRouteTimeout,
+			Response: models.HoldResponse{},
+		},
+		{
+			Name: "list_account_holds", Method: "GET", Path: "/accounts/{account_id}/holds",
+			Summary: "Holds on an account, newest first (limit)",
+			Handler: h.ListAccountHolds, Timeout: defaultRouteTimeout,
+			Response: models.HoldListResponse{},
+		},
+
+		// Multi-currency wallets: balances in other currencies under the same account ID
+		{
+			Name: "open_wallet", Method: "POST", Path: "/accounts/{account_id}/wallets",
+			Summary: "Open an empty wallet in another currency, which transfers naming that currency credit and debit",
+			Handler: h.OpenWallet, Timeout: defaultRouteTimeout,
+			Request: models.OpenWalletRequest{}, Response: models.WalletResponse{}, Status: http.StatusCreated,
+			Example: models.OpenWalletRequest{Currency: "EUR"},
+		},
+		{
+			Name: "list_wallets", Method: "GET", Path: "/accounts/{account_id}/wallets",
+			Summary: "Every balance of an account: its primary balance, then its wallets by currency",
+			Handler: h.ListWallets, Timeout: defaultRouteTimeout,
+			Response: models.WalletListResponse{},
+		},
+
+		// Supported currencies: scale, transfer bounds and whether accounts may go negative
+		{
+			Name: "list_currencies", Method: "GET", Path: "/currencies",
+			Summary: "Every supported currency with its scale, transfer amount bounds and negative balance policy",
+			Handler: h.ListCurrencies, Timeout: defaultRouteTimeout,
+			Response: models.CurrencyListResponse{},
+		},
+
+		// Transfer limits: read by account owners, set by admins
+		{
+			Name: "get_account_limits", Method: "GET", Path: "/accounts/{account_id}/limits",
+			Summary: "An account's transfer limits and what it has debited today",
+			Handler: h.GetAccountLimits, Timeout: defaultRouteTimeout,
+			Response: models.TransferLimitsResponse{},
+		},
+
+		// Recurring transfers booked by the scheduler
+		{
+			Name: "create_recurring_transfer", Method: "POST", Path: "/recurring-transfers",
+			Summary: "Schedule a transfer to recur on a cron or @every schedule",
+			Handler: h.Signed(h.CreateRecurringTransfer), Timeout: defaultRouteTimeout,
+			Request: models.CreateRecurringTransferRequest{}, Response: models.RecurringTransferResponse{}, Status: http.StatusCreated,
+			Example: models.CreateRecurringTransferRequest{SourceAccountID: 123, DestinationAccountID: 456, Amount: "250.00", Schedule: "0 9 1 * *"},
+		},
+		{
+			Name: "list_recurring_transfers", Method: "GET", Path: "/recurring-transfers",
+			Summary: "List recurring transfers",
+			Handler: h.ListRecurringTransfers, Timeout: defaultRouteTimeout,
+			Response: models.RecurringTransferListResponse{},
+		},
+		{
+			Name: "get_recurring_transfer", Method: "GET", Path: "/recurring-transfers/{recurring_id}",
+			Summary: "Get a recurring transfer and its next run time",
+			Handler: h.GetRecurringTransfer, Timeout: defaultRouteTimeout,
+			Response: models.RecurringTransferResponse{},
+		},
+		{
+			Name: "delete_recurring_transfer", Method: "DELETE", Path: "/recurring-transfers/{recurring_id}",
+			Summary: "Delete a recurring transfer and its execution history",
+			Handler: h.DeleteRecurringTransfer, Timeout: defaultRouteTimeout, Status: http.StatusNoContent,
+		},
+		{
+			Name: "pause_recurring_transfer", Method: "POST", Path: "/recurring-transfers/{recurring_id}/pause",
+			Summary: "Stop a recurring transfer from running until it is resumed",
+			Handler: h.PauseRecurringTransfer, Timeout: defaultRouteTimeout,
+			Response: models.RecurringTransferResponse{},
+		},
+		{
+			Name: "resume_recurring_transfer", Method: "POST", Path: "/recurring-transfers/{recurring_id}/resume",
+			Summary: "Resume a paused recurring transfer from its next scheduled time",
+			Handler: h.Signed(h.ResumeRecurringTransfer), Timeout: defaultRouteTimeout,
+			Response: models.RecurringTransferResponse{},
+		},
+		{
+			Name: "list_recurring_executions", Method: "GET", Path: "/recurring-transfers/{recurring_id}/executions",
+			Summary: "Executions of a recurring transfer, newest first (limit), with their transaction or error",
+			Handler: h.ListRecurringExecutions, Timeout: defaultRouteTimeout,
+			Response: models.RecurringExecutionListResponse{},
+		},
+
+		// GraphQL endpoint for nested account + transaction queries and transfers; signed as a whole,
+		// queries included, as any request may carry the transfer mutation
+		{
+			Name: "graphql", Method: "POST", Path: "/graphql",
+			Summary: "GraphQL queries for accounts and transactions, and the transfer mutation",
+			Handler: h.Signed(h.GraphQL().ServeHTTP), Timeout: defaultRouteTimeout,
+		},
+
+		// WebSocket balance feed; long-lived, so no timeout
+		{
+			Name: "balance_feed", Method: "GET", Path: "/ws",
+			Summary: "WebSocket feed of balance updates for subscribed accounts",
+			Handler: h.BalanceFeed,
+		},
+
+		// Health check endpoints (probed constantly, so they skip request ID generation and metering,
+		// and the access log unless configured otherwise (see healthCheckRoutes); outside the version
+		// prefix, so probes need not follow API versions)
+		{
+			Name: "health", Method: "GET", Path: "/health",
+			Summary: "Liveness check",
+			Handler: h.HealthCheck, OptOut: []string{"request_id", "usage"},
+			Unversioned: true,
+		},
+		{
+			Name: "health_db", Method: "GET", Path: "/health/db",
+			Summary: "Database connection pool statistics",
+			Handler: h.DatabaseStats, Timeout: defaultRouteTimeout, OptOut: []string{"request_id", "usage"},
+			Response: database.PoolStats{}, Unversioned: true,
+		},
+
+		// Settlement file generation status
+		{
+			Name: "list_settlement_files", Method: "GET", Path: "/settlements/files",
+			Summary: "Partner settlement file generation status (filter by date and partner_id)",
+			Handler: h.ListSettlementFiles, Timeout: defaultRouteTimeout,
+			Response: models.SettlementFileListResponse{},
+		},
+		{
+			Name: "ingest_settlement_ack", Method: "POST", Path: "/settlements/acks",
+			Summary: "Apply a partner acknowledgment/return file (partner_id query parameter, CSV body)",
+			Handler: h.IngestSettlementAck, Timeout: settlementAckTimeout, BodyLimit: settlementAckBodyLimit,
+			Response: settlement.AckReport{},
+		},
+
+		// API usage per signing key for chargeback
+		{
+			Name: "get_usage", Method: "GET", Path: "/usage",
+			Summary: "The calling API key's usage and quota (filter by from and to dates)",
+			Handler: h.GetUsage, Timeout: defaultRouteTimeout,
+			Response: models.KeyUsageResponse{},
+		},
+		{
+			Name: "get_sla", Method: "GET", Path: "/sla",
+			Summary: "The calling API key's transfer commit latency SLA compliance (filter by from and to dates)",
+			Handler: h.GetSLA, Timeout: defaultRouteTimeout,
+			Response: models.SLAResponse{},
+		},
+
+		// Compliance administration; requires the ADMIN_TOKEN bearer token
+		{
+			Name: "freeze_account", Method: "POST", Path: "/admin/accounts/{account_id}/freeze",
+			Summary: "Freeze an account: transfers debiting or crediting it are refused (423)",
+			Handler: adminOnly(h.FreezeAccount), Timeout: defaultRouteTimeout,
+			Response: models.AccountResponse{},
+		},
+		{
+			Name: "unfreeze_account", Method: "POST", Path: "/admin/accounts/{account_id}/unfreeze",
+			Summary: "Lift a freeze so the account can transact again",
+			Handler: adminOnly(h.UnfreezeAccount), Timeout: defaultRouteTimeout,
+			Response: models.AccountResponse{},
+		},
+		{
+			Name: "set_overdraft_limit", Method: "PUT", Path: "/admin/accounts/{account_id}/overdraft",
+			Summary: "Set how far below zero an account's balance may go",
+			Handler: adminOnly(h.SetOverdraftLimit), Timeout: defaultRouteTimeout,
+			Request: models.SetOverdraftLimitRequest{}, Response: models.AccountResponse{},
+			Example: models.SetOverdraftLimitRequest{OverdraftLimit: "500.00"},
+		},
+		{
+			Name: "set_account_limits", Method: "PUT", Path: "/admin/accounts/{account_id}/limits",
+			Summary: "Replace an account's per-transfer and daily transfer limits (omitted fields are unlimited)",
+			Handler: adminOnly(h.SetAccountLimits), Timeout: defaultRouteTimeout,
+			Request: models.SetTransferLimitsRequest{}, Response: models.TransferLimitsResponse{},
+		},
+		{
+			Name: "create_adjustment", Method: "POST", Path: "/admin/adjustments",
+			Summary: "Credit (positive amount) or debit (negative amount) a single account to correct an operational error, with a mandatory reason and actor",
+			Handler: adminOnly(h.CreateAdjustment), Timeout: defaultRouteTimeout,
+			Request: models.CreateAdjustmentRequest{}, Response: models.AdjustmentResponse{}, Status: http.StatusCreated,
+			Example: models.CreateAdjustmentRequest{AccountID: 123, Amount: "-25.00", Reason: "Duplicate card settlement 2024-03-11", Actor: "jdoe"},
+		},
+		{
+			Name: "set_currency", Method: "PUT", Path: "/admin/currencies/{code}",
+			Summary: "Configure a currency's scale, transfer amount bounds and negative balance policy, adding it if unsupported",
+			Handler: adminOnly(h.SetCurrency), Timeout: defaultRouteTimeout,
+			Request: models.SetCurrencyRequest{}, Response: models.CurrencyResponse{},
+		},
+		{
+			Name: "create_api_key", Method: "POST", Path: "/admin/api-keys",
+			Summary: "Issue an API key for signing requests; its secret is only returned in this response",
+			Handler: adminOnly(h.CreateAPIKey), Timeout: defaultRouteTimeout,
+			Request: models.CreateAPIKeyRequest{}, Response: models.CreateAPIKeyResponse{}, Status: http.StatusCreated,
+			Example: models.CreateAPIKeyRequest{Description: "payroll batch job"},
+		},
+		{
+			Name: "list_api_keys", Method: "GET", Path: "/admin/api-keys",
+			Summary: "Every API key for signing requests, revoked ones included, without their secrets",
+			Handler: adminOnly(h.ListAPIKeys), Timeout: defaultRouteTimeout,
+			Response: models.APIKeyListResponse{},
+		},
+		{
+			Name: "revoke_api_key", Method: "POST", Path: "/admin/api-keys/{key_id}/revoke",
+			Summary: "Revoke an API key: requests signed with it are refused from now on",
+			Handler: adminOnly(h.RevokeAPIKey), Timeout: defaultRouteTimeout,
+			Response: models.APIKeyResponse{},
+		},
+		{
+			Name: "usage_report", Method: "GET", Path: "/admin/usage",
+			Summary: "Usage of every API key for chargeback (filter by from and to dates)",
+			Handler: adminOnly(h.UsageReport), Timeout: defaultRouteTimeout,
+			Response: models.UsageReportResponse{},
+		},
+		{
+			Name: "sla_report", Method: "GET", Path: "/admin/sla",
+			Summary: "Transfer commit latency SLA compliance of every API key (filter by from and to dates)",
+			Handler: adminOnly(h.SLAReport), Timeout: defaultRouteTimeout,
+			Response: models.SLAReportResponse{},
+		},
+		{
+			Name: "list_audit_events", Method: "GET", Path: "/admin/audit",
+			Summary: "Audit log of account changes, transfers, reversals and admin actions, newest first (filter by actor, action, account_id, from and to; limit, cursor)",
+			Handler: adminOnly(h.ListAuditEvents), Timeout: defaultRouteTimeout,
+			Response: models.AuditListResponse{},
+		},
+		{
+			Name: "search_transactions", Method: "GET", Path: "/transactions/search",
+			Summary: "Transactions across accounts for support investigations, newest first (account_id and reference repeatable, text, min_amount, max_amount, created_from, created_before; limit, cursor); admin only",
+			Handler: adminOnly(h.SearchTransactions), Timeout: defaultRouteTimeout,
+			Response: models.TransactionSearchResponse{},
+		},
+		{
+			Name: "transaction_trace", Method: "GET", Path: "/admin/transactions/{transaction_id}/trace",
+			Summary: "Everything recorded about a transfer for incident triage: timeline, lock wait, rules, retries, audit events and outbox events",
+			Handler: adminOnly(h.GetTransactionTrace), Timeout: defaultRouteTimeout,
+			Response: models.TransferTraceResponse{},
+		},
+		{
+			Name: "upload_attachment", Method: "POST", Path: "/admin/transactions/{transaction_id}/attachments",
+			Summary: "Attach a document (base64 content) to a transaction, e.g. an invoice or authorization form",
+			Handler: adminOnly(h.UploadAttachment), Timeout: defaultRouteTimeout, BodyLimit: attachments.MaxRequestSize,
+			Request: models.CreateAttachmentRequest{}, Response: models.AttachmentResponse{}, Status: http.StatusCreated,
+			Example: models.CreateAttachmentRequest{Filename: "invoice-1001.pdf", ContentType: "application/pdf", Content: "JVBERi0xLjQK..."},
+		},
+		{
+			Name: "list_attachments", Method: "GET", Path: "/admin/transactions/{transaction_id}/attachments",
+			Summary: "A transaction's attachments, oldest first",
+			Handler: adminOnly(h.ListAttachments), Timeout: defaultRouteTimeout,
+			Response: models.AttachmentListResponse{},
+		},
+		{
+			Name: "download_attachment", Method: "GET", Path: "/admin/transactions/{transaction_id}/attachments/{attachment_id}",
+			Summary: "Download an attachment's document, verified against its SHA-256 digest",
+			Handler: adminOnly(h.DownloadAttachment), Timeout: defaultRouteTimeout,
+		},
+		{
+			Name: "reconcile_balances", Method: "GET", Path: "/admin/reconciliation",
+			Summary: "Recompute every account's balance from its initial balance, transactions and adjustments and report the discrepancies",
+			Handler: adminOnly(h.Reconcile), Timeout: reconciliationTimeout,
+			Response: models.ReconciliationResponse{},
+		},
+		{
+			Name: "retention_report", Method: "GET", Path: "/admin/retention",
+			Summary: "Effective data retention policies with a dry run of their enforcement",
+			Handler: adminOnly(h.RetentionReport), Timeout: defaultRouteTimeout,
+			Response: models.RetentionReportResponse{},
+		},
+
+		// Runtime and subsystem counters (expvar), e.g. FX cache and rate staleness metrics
+		{
+			Name: "metrics", Method: "GET", Path: "/debug/vars",
+			Summary: "Runtime and subsystem metrics in expvar JSON format",
+			Handler: expvar.Handler().ServeHTTP, OptOut: []string{"request_id", "access_log", "usage"},
+			Unversioned: true,
+		},
+	}
+}
+
 // setupRoutes configures and returns the HTTP router with all endpoints
+// Routes come from the declarative registry; cross-cutting concerns are applied through a single
+// middleware chain, so adding a concern means adding one chain entry
 func setupRoutes(h *handlers.Handler) *mux.Router {
+	config := routes.Config{MaxBodyBytes: routes.DefaultMaxBodyBytes}
+	chain := apiMiddleware(h, middleware.AccessLog, middleware.Allowlist{})
+	return listenerRoutes(h, chain, listeners.Listener{Routes: listeners.AllRoutes}, config)
+}
+
+// listenerRoutes builds the router of one listener: the routes of every API version in its scope,
+// each wrapped in the chain minus the entries the listener and the route skip
+// Each version's OpenAPI document, e.g. /v1/openapi.json, describes its routes the listener serves
+func listenerRoutes(h *handlers.Handler, chain middleware.Chain, l listeners.Listener, config routes.Config) *mux.Router {
 	r := mux.NewRouter()
 
-	// Account endpoints
-	r.HandleFunc("/accounts", h.CreateAccount).Methods("POST")
-	r.HandleFunc("/accounts/{account_id}", h.GetAccount).Methods("GET")
+	for _, version := range apiVersions(h) {
+		registry := version.WithBodyLimit(config.MaxBodyBytes).Filter(l.Serves)
+		extra := []routes.Route{{
+			Name: "openapi", Method: "GET", Path: "/openapi.json",
+			Summary: "OpenAPI description of this API",
+			Handler: registry.OpenAPIHandler(apiTitle, apiVersion),
+		}}
+		if consoleEnabled() {
+			extra = append(extra, routes.Route{
+				Name: "console", Method: "GET", Path: "/console",
+				Summary: "Interactive API console",
+				Handler: console.Handler(),
+			})
+		}
+		for _, route := range extra {
+			if l.Serves(route) {
+				registry.Register(route)
+			}
+		}
+		registry.Mount(r, chain.Without(l.Skip...))
+	}
+
+	return r
+}
+
+// apiMiddleware returns the middleware chain of the API: the defaults with the given access log,
+// then the IP allowlist, the usage recorder, the sandbox header and the fixture recorder when
+// enabled
+// Built once and shared by every listener, so they record into the same usage and fixtures
+func apiMiddleware(h *handlers.Handler, accessLog middleware.Middleware, allowlist middleware.Allowlist) middleware.Chain {
+	chain := defaultMiddleware(accessLog)
+	if allowlist.Enabled() {
+		chain = chain.Append(middleware.Entry{Name: "ip_allowlist", Middleware: middleware.IPAllowlist(allowlist)})
+	}
+	if recorder := h.Usage(); recorder != nil {
+		chain = chain.Append(middleware.Entry{Name: "usage", Middleware: recorder.Middleware})
+	}
+	if sandboxEnabled() {
+		chain = chain.Append(middleware.Entry{Name: "sandbox", Middleware: sandbox.Header})
+	}
+	if dir := os.Getenv("FIXTURE_RECORD_DIR"); dir != "" {
+		slog.Info("Recording sanitized request/response fixtures", "dir", dir)
+		chain = chain.Append(middleware.Entry{Name: "fixtures", Middleware: fixtures.NewRecorder(dir).Middleware})
+	}
+	return chain
+}
+
+// defaultMiddleware returns the middleware chain applied to every route, outermost first
+func defaultMiddleware(accessLog middleware.Middleware) middleware.Chain {
+	return middleware.NewChain(
+		middleware.Entry{Name: "recover", Middleware: middleware.Recover},
+		middleware.Entry{Name: "request_id", Middleware: middleware.RequestID},
+		middleware.Entry{Name: "access_log", Middleware: accessLog},
+	)
+}
 
-	// Transaction endpoint
-	r.HandleFunc("/transactions", h.CreateTransaction).Methods("POST")
+// healthCheckRoutes names the routes of the health checks, left out of the access log unless
+// ACCESS_LOG_HEALTH_CHECKS is set
+var healthCheckRoutes = []string{"health", "health_db"}
 
-	// Health check endpoint
-	r.HandleFunc("/health", h.HealthCheck).Methods("GET")
+// adminOnly restricts an admin handler to callers presenting the ADMIN_TOKEN bearer token
+// The admin API is disabled (403) when ADMIN_TOKEN is unset
+func adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return middleware.AdminAuth(os.Getenv("ADMIN_TOKEN"))(h).ServeHTTP
+}
 
-	return r
+// consoleEnabled reports whether the embedded API console is served at /console
+// Enabled by default; set CONSOLE_ENABLED=false to disable it in locked-down deployments
+func consoleEnabled() bool {
+	return os.Getenv("CONSOLE_ENABLED") != "false"
+}
+
+// getStorage returns the configured storage backend, defaulting to postgres
+// Supported values: "postgres", "memory" (zero-dependency mode for demos and integration tests) and
+// "eventsourced" (the ledger as an event log file)
+func getStorage() string {
+	storage := os.Getenv("STORAGE")
+	if storage == "" {
+		storage = "postgres"
+	}
+	return storage
+}
+
+// sandboxEnabled reports whether SANDBOX_MODE is on
+// Sandbox deployments answer reserved amounts and account IDs with deterministic simulated outcomes
+func sandboxEnabled() bool {
+	return os.Getenv("SANDBOX_MODE") == "true"
+}
+
+// storageBackends maps STORAGE values to the function opening that backend
+// A new backend is added by implementing database.Storage and registering its opener here
+var storageBackends = map[string]func() (database.Storage, error){
+	"postgres":     openPostgres,
+	"memory":       openMemory,
+	"eventsourced": openEventSourced,
 }
 
-// initializeApp initializes the database connection, runs migrations, and returns a handler
-func initializeApp() (*handlers.Handler, error) {
-	// Initialize database connection
-	db, err := database.InitDB()
+// initializeApp initializes the configured storage backend and returns a handler
+// In sandbox mode the repositories are wrapped so reserved inputs never reach storage
+// When settlement partners are configured the settlement file job is started, and when
+// KAFKA_BROKERS is set the outbox relay publishes events recorded by the storage
+// Background jobs run under the coordinator, which also counts the outbox and holds for the
+// shutdown report
+func initializeApp(coordinator *shutdown.Coordinator) (*handlers.Handler, error) {
+	rounding, err := models.ParseRoundingPolicy(os.Getenv("ROUNDING_POLICY"))
 	if err != nil {
 		return nil, err
 	}
 
-	// Run database migrations
-	if err := database.Migrate(db); err != nil {
-		db.Close()
+	schedule, err := cutoff.Load()
+	if err != nil {
+		return nil, err
+	}
+	settlementConfig, err := settlement.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	transferRules, err := rules.Load()
+	if err != nil {
+		return nil, err
+	}
+	if transferRules == nil {
+		// An empty engine still takes the rules of a RULES_FILE set by a configuration reload
+		transferRules, _ = rules.New(nil)
+	}
+	preauthConfig, err := preauth.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	usageConfig, err := usage.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	slaConfig, err := sla.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	rates, err := fx.Load()
+	if err != nil {
+		return nil, err
+	}
+	recurringConfig, err := recurring.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	holdConfig, err := holds.LoadConfig()
+	if err != nil {
 		return nil, err
 	}
+	balanceConfig, err := balances.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	retentionConfig, err := retention.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	reaperConfig, err := reaper.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	currencyConfig, err := currencies.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	attachmentConfig, err := attachments.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	signingConfig, err := signing.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	cursors, cursorKeyConfigured, err := pagination.Load()
+	if err != nil {
+		return nil, err
+	}
+	if !cursorKeyConfigured {
+		slog.Warn("PAGINATION_KEY not set: list cursors are signed with a random key and only valid on this instance until it restarts")
+	}
+	if rates != nil {
+		slog.Info("Cross-currency transfers enabled")
+	}
+	if transferRules.Len() > 0 {
+		slog.Info("Loaded transfer validation rules", "rules", transferRules.Len())
+	}
+	if signingConfig.Required {
+		slog.Info("Signed requests required", "max_age", signingConfig.MaxAge)
+	}
+	var preauthClient *preauth.Client
+	if preauthConfig.Enabled() {
+		slog.Info("Pre-authorizing transfers", "timeout", preauthConfig.Timeout, "failure_policy", preauthConfig.FailurePolicy)
+		preauthClient = preauth.New(preauthConfig, nil).WithSigner(signing.SignRequest)
+	}
 
-	// Initialize handlers
-	h := handlers.NewHandler(db)
+	storage, err := openStorage()
+	if err != nil {
+		return nil, err
+	}
+	accounts, transactions, settlements := storage.Accounts(), storage.Transactions(), storage.Settlements()
+
+	if sandboxEnabled() {
+		slog.Info("Sandbox mode enabled; reserved amounts and account IDs return simulated outcomes")
+		accounts = sandbox.NewAccountRepository(accounts, sandbox.DefaultTimeoutDelay)
+		transactions = sandbox.NewTransactionRepository(transactions, sandbox.DefaultTimeoutDelay)
+	}
+
+	// Publish outbox events; without brokers they accumulate until a relay is configured
+	if kafkaConfig := outbox.LoadKafkaConfig(); kafkaConfig.Enabled() && storage.Outbox() != nil {
+		slog.Info("Publishing outbox events to Kafka", "topic", kafkaConfig.Topic)
+		relay := outbox.NewRelay(storage.Outbox(), outbox.NewKafkaPublisher(kafkaConfig), 0, 0)
+		coordinator.Go("outbox relay", relay.Run)
+	}
+
+	// Partner settlement files are generated in the background once each business day closes
+	if settlementConfig.Enabled() {
+		slog.Info("Generating settlement files", "partners", len(settlementConfig.Partners), "dir", settlementConfig.ExportDir)
+		generator := settlement.NewGenerator(settlementConfig.Partners, settlements,
+			settlement.DirectoryTarget{Dir: settlementConfig.ExportDir}, schedule)
+		coordinator.Go("settlement files", func(ctx context.Context) { generator.Run(ctx, settlementConfig.Interval) })
+	}
+
+	// Requests and transfers are metered per API key and flushed to the usage rollup
+	recorder := usage.NewRecorder(storage.Usage(), usageConfig.Quotas)
+	coordinator.Go("usage recorder", func(ctx context.Context) { recorder.Run(ctx, usageConfig.FlushInterval) })
+
+	// Committed transfers' processing latency is tracked per API key against the commit SLA
+	latency := sla.NewRecorder(storage.Latency(), slaConfig.Target).WithBudgets(slaConfig.Budgets)
+	coordinator.Go("sla recorder", func(ctx context.Context) { latency.Run(ctx, slaConfig.FlushInterval) })
+
+	// Account changes, transfers, reversals and admin actions are appended to the audit log
+	auditLog := audit.NewRecorder(storage.Audit())
+
+	// Committed transfers are fanned out in-process to the live endpoints
+	broker := pubsub.NewBroker()
+	transactions = pubsub.NewTransactionRepository(transactions, broker)
+
+	h := handlers.NewHandlerWithRepositories(accounts, transactions).
+		WithRoundingPolicy(rounding).
+		WithBroker(broker).
+		WithCutoffSchedule(schedule).
+		WithRules(transferRules).
+		WithPreauthorization(preauthClient).
+		WithFX(rates).
+		WithUsage(recorder).
+		WithLatency(latency).
+		WithSettlements(settlements).
+		WithRecurring(storage.Recurring()).
+		WithHolds(storage.Holds(), holdConfig).
+		WithLimits(storage.Limits()).
+		WithCursors(cursors).
+		WithAudit(auditLog).
+		WithBalanceHistory(storage.BalanceHistory()).
+		WithReconciler(reconcile.NewReconciler(storage.Reconciliation())).
+		WithAdjustments(storage.Adjustments()).
+		WithWallets(storage.Wallets()).
+		WithCurrencies(storage.Currencies()).
+		WithSigning(storage.APIKeys(), signingConfig)
+	h.Recurring().WithAudit(auditLog).WithRetries(recurringConfig.RetryDelays)
+	if settlementConfig.Enabled() {
+		h.WithSettlementIngester(settlement.NewIngester(settlementConfig.Partners, settlements, schedule).WithAudit(auditLog))
+	}
+	if stats, ok := storage.(database.PoolStatsProvider); ok {
+		h.WithPoolStats(stats.PoolStats)
+	}
+	// Rotated database credentials reconnect the pool without a restart
+	if watcher, ok := storage.(database.CredentialWatcher); ok {
+		coordinator.Go("database credentials", watcher.WatchCredentials)
+	}
+
+	// Validation follows the stored currency configuration, reloaded for changes made elsewhere
+	if err := h.Currencies().Load(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to load currencies: %w", err)
+	}
+	if currencyConfig.RefreshInterval > 0 {
+		coordinator.Go("currency refresh", func(ctx context.Context) { h.Currencies().Run(ctx, currencyConfig.RefreshInterval) })
+	}
+
+	// Documents attached to transactions are kept in a directory; without one the routes answer 503
+	if attachmentConfig.Dir != "" {
+		store, err := attachments.NewDirStore(attachmentConfig.Dir)
+		if err != nil {
+			return nil, err
+		}
+		h.WithAttachments(attachments.NewManager(storage.Attachments(), store, attachmentConfig))
+	}
+
+	// Recurring transfers are executed through the handler's transfer service once it is configured
+	coordinator.Go("recurring transfers", func(ctx context.Context) { h.Recurring().Run(ctx, recurringConfig.PollInterval) })
+
+	// Holds past their expiry are released in the background
+	coordinator.Go("hold expiry", func(ctx context.Context) { h.Holds().Run(ctx, holdConfig.ExpiryInterval) })
+
+	// Accounts without recent transfers get periodic balance snapshots for their history
+	if balanceConfig.SnapshotInterval > 0 {
+		snapshotter := balances.NewSnapshotter(storage.BalanceHistory())
+		coordinator.Go("balance snapshots", func(ctx context.Context) { snapshotter.Run(ctx, balanceConfig.SnapshotInterval) })
+	}
+
+	if outboxStore := storage.Outbox(); outboxStore != nil {
+		coordinator.PendingOutbox = outboxStore.CountPending
+		h.WithOutbox(outboxStore)
+	}
+
+	// Audit events and published outbox events older than their retention period are purged
+	if retentionStore := storage.Retention(); retentionStore != nil {
+		enforcer := retention.NewEnforcer(retentionStore, retentionConfig)
+		h.WithRetention(enforcer)
+		if retentionConfig.Interval > 0 {
+			coordinator.Go("retention", func(ctx context.Context) { enforcer.Run(ctx, retentionConfig.Interval) })
+		}
+	}
+
+	// Stream subscriptions of connections that died without closing are dropped in the background
+	if reaperConfig.Interval > 0 {
+		collector := reaper.New(reaper.StreamSubscriptions(broker, reaperConfig.StreamIdle))
+		coordinator.Go("reaper", func(ctx context.Context) { collector.Run(ctx, reaperConfig.Interval) })
+	}
+	coordinator.OpenHolds = storage.Holds().CountActiveHolds
 	return h, nil
 }
 
+// openStorage opens the backend selected by STORAGE
+func openStorage() (database.Storage, error) {
+	storage := getStorage()
+	open, ok := storageBackends[storage]
+	if !ok {
+		return nil, fmt.Errorf("unknown STORAGE %q (expected postgres, memory or eventsourced)", storage)
+	}
+	return open()
+}
+
+// openPostgres connects to PostgreSQL, runs migrations and reports schema drift
+// Drift does not stop the server, as the schema still works, only slower or less guarded
+func openPostgres() (database.Storage, error) {
+	storage, err := database.OpenPostgresStorage()
+	if err != nil {
+		return nil, err
+	}
+	drift, err := storage.VerifySchema(context.Background())
+	if err != nil {
+		slog.Error("Schema verification error", "error", err)
+	}
+	for _, d := range drift {
+		slog.Warn("SCHEMA DRIFT (run the doctor command for a full report)", "drift", d.String(), "fix", d.Fix)
+	}
+	return storage, nil
+}
+
+// openMemory creates an empty in-process store
+func openMemory() (database.Storage, error) {
+	slog.Warn("Using in-memory storage; all data will be lost on exit")
+	return memory.NewStore(), nil
+}
+
+// openEventSourced opens the event log at EVENT_LOG_PATH and replays it
+func openEventSourced() (database.Storage, error) {
+	config := eventsource.LoadConfig()
+	slog.Warn("Using event-sourced storage; only the ledger is kept, in the event log", "path", config.Path)
+	store, err := eventsource.Open(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
 func main() {
+	// Apply the config file and flags to the environment before any configuration is read
+	source, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stdout)
+		return
+	}
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	// Configure structured logging first, so every later line uses the chosen level and format
+	logConfig, err := logging.LoadConfig()
+	if err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+	logging.Setup(logConfig)
+	accessConfig, err := logging.LoadAccessConfig(logConfig)
+	if err != nil {
+		fatal("Invalid access log configuration", err)
+	}
+
+	// Run an administrative command if one was given (e.g. "migrate down 1")
+	if len(args) > 0 {
+		if err := runCommand(args); err != nil {
+			fatal("Command failed", err)
+		}
+		return
+	}
+
+	// Serve recorded fixtures instead of the real API when replaying
+	if dir := os.Getenv("FIXTURE_REPLAY_DIR"); dir != "" {
+		replayer, err := fixtures.NewReplayer(dir)
+		if err != nil {
+			fatal("Failed to load fixtures", err)
+		}
+		port := getPort()
+		slog.Info("Replaying fixtures", "fixtures", replayer.Len(), "dir", dir, "port", port)
+		fatal("Server stopped", http.ListenAndServe(":"+port, replayer))
+	}
+
+	shutdownConfig, err := shutdown.LoadConfig()
+	if err != nil {
+		fatal("Invalid shutdown configuration", err)
+	}
+	listenerConfig, err := listeners.LoadConfig()
+	if err != nil {
+		fatal("Invalid listener configuration", err)
+	}
+	transportConfig, err := transport.LoadConfig()
+	if err != nil {
+		fatal("Invalid transport configuration", err)
+	}
+	routeConfig, err := routes.LoadConfig()
+	if err != nil {
+		fatal("Invalid route configuration", err)
+	}
+	reloadInterval, err := config.ReloadInterval()
+	if err != nil {
+		fatal("Invalid configuration reload interval", err)
+	}
+	allowlist, err := middleware.LoadAllowlist()
+	if err != nil {
+		fatal("Invalid IP allowlist", err)
+	}
+	if allowlist.Enabled() {
+		slog.Info("Restricting clients to the IP allowlist", "networks", len(allowlist.Networks), "keys", len(allowlist.Keys))
+	}
+
 	// Initialize the application
-	h, err := initializeApp()
+	coordinator := shutdown.New()
+	h, err := initializeApp(coordinator)
+	if err != nil {
+		fatal("Failed to initialize application", err)
+	}
+	h.WithAllowlist(allowlist)
+	if err := h.CheckAllowlist(context.Background()); err != nil {
+		fatal("Invalid IP allowlist", err)
+	}
+	coordinator.Go("configuration reload", func(ctx context.Context) {
+		watchConfig(ctx, source, reloadInterval, func() error { return reloadConfig(source, h) })
+	})
+
+	// Setup one server per listener, each serving its scope of the routes
+	chain := apiMiddleware(h, logging.AccessLog(accessConfig, os.Stderr, healthCheckRoutes...), allowlist)
+	configured := listenerConfig.Listeners
+	servers := make([]*http.Server, len(configured))
+	httpsPort := ""
+	for i, l := range configured {
+		secure := transportConfig.TLS() && l.Network == "tcp"
+		servers[i] = transportConfig.NewServer(coordinator.Track(listenerRoutes(h, chain, l, routeConfig)), secure)
+		if _, port, err := net.SplitHostPort(l.Address); secure && httpsPort == "" && err == nil {
+			httpsPort = port
+		}
+	}
+
+	// Cleartext HTTP is redirected to the first TCP listener, which serves HTTPS
+	if transportConfig.RedirectAddr != "" {
+		if httpsPort == "" {
+			fatal("Invalid transport configuration", errors.New("HTTP_REDIRECT_ADDR needs a TCP listener"))
+		}
+		configured = append(configured, listeners.Listener{Network: "tcp", Address: transportConfig.RedirectAddr,
+			Routes: listeners.RedirectRoutes})
+		servers = append(servers, transportConfig.NewRedirectServer(httpsPort))
+	}
+	if len(transportConfig.AutocertDomains) > 0 {
+		slog.Info("Obtaining certificates from the ACME CA", "domains", transportConfig.AutocertDomains,
+			"directory", transportConfig.AutocertDirectory)
+		coordinator.Go("certificate renewal", transportConfig.RenewCertificates)
+	}
+	run := func(stop <-chan struct{}) {
+		serve(configured, servers, coordinator, shutdownConfig, stop)
+	}
+
+	// Started by the Windows Service Control Manager, the server runs as a service it controls
+	isService, err := supervisor.RunService(windowsServiceName, run)
 	if err != nil {
-		log.Fatal("Failed to initialize application:", err)
+		fatal("Windows service error", err)
+	}
+	if !isService {
+		run(nil)
+	}
+}
+
+// windowsServiceName is the name the Windows service is registered under (sc.exe create)
+const windowsServiceName = "internal-transfers"
+
+// serve runs the servers, one per listener, until SIGINT or SIGTERM, or until stop is closed by
+// the Windows service manager, then shuts down gracefully and reports the shutdown in the log and,
+// if SHUTDOWN_REPORT_FILE is set, in that file
+// Under systemd (Type=notify) readiness is reported once every listener is open, watchdog
+// heartbeats are sent while the first listener serving /health answers it, and the shutdown is
+// announced
+func serve(configured []listeners.Listener, servers []*http.Server, coordinator *shutdown.Coordinator, config shutdown.Config, stop <-chan struct{}) {
+	opened := make([]net.Listener, len(configured))
+	for i, l := range configured {
+		listener, err := l.Listen()
+		if err != nil {
+			fatal("Failed to listen on "+l.String(), err)
+		}
+		opened[i] = listener
+	}
+	stopped := make(chan error, len(servers))
+	addresses := make([]string, len(configured))
+	for i, server := range servers {
+		go func(server *http.Server, listener net.Listener) {
+			if server.TLSConfig != nil {
+				stopped <- server.ServeTLS(listener, "", "")
+			} else {
+				stopped <- server.Serve(listener)
+			}
+		}(server, opened[i])
+		addresses[i] = configured[i].String()
+		slog.Info("Server starting", "listener", addresses[i], "routes", configured[i].Routes,
+			"tls", server.TLSConfig != nil, "http2", server.Protocols.HTTP2(), "h2c", server.Protocols.UnencryptedHTTP2())
 	}
 
-	// Setup routes
-	r := setupRoutes(h)
+	notifier := supervisor.NewNotifier()
+	if err := notifier.Ready("Serving on " + strings.Join(addresses, ", ")); err != nil {
+		slog.Error("Readiness notification error", "error", err)
+	}
+	if interval := notifier.WatchdogInterval(); interval > 0 {
+		slog.Info("Sending systemd watchdog heartbeats", "watchdog", interval)
+		// Admin-only listeners do not serve /health; without a listener that does, beats are unchecked
+		check := func(ctx context.Context) error { return nil }
+		for i, l := range configured {
+			if l.Serves(routes.Route{Path: "/health"}) {
+				check = checkHealth(opened[i].Addr(), servers[i].TLSConfig != nil)
+				break
+			}
+		}
+		coordinator.Go("systemd watchdog", func(ctx context.Context) { notifier.RunWatchdog(ctx, check) })
+	}
 
-	// Get port
-	port := getPort()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	var reason string
+	select {
+	case err := <-stopped:
+		fatal("Server stopped", err)
+	case sig := <-signals:
+		reason = sig.String()
+	case <-stop:
+		reason = "windows service stop"
+	}
+	signal.Stop(signals)
+
+	if err := notifier.Stopping("Shutting down: " + reason); err != nil {
+		slog.Error("Stopping notification error", "error", err)
+	}
+	slog.Info("Shutting down", "reason", reason, "timeout", config.Timeout)
+	report := coordinator.Shutdown(servers, reason, config.Timeout)
+	report.Log()
+	if config.ReportFile != "" {
+		if err := report.WriteFile(config.ReportFile); err != nil {
+			slog.Error("Shutdown report error", "error", err)
+		}
+	}
+}
+
+// checkHealth returns a check that GET /health answers 200 OK on the listener bound to addr, for
+// the systemd watchdog
+// TCP listeners are checked over loopback, Unix sockets by dialing the socket file; secure
+// listeners over TLS without verifying the certificate, which need not name the loopback address
+func checkHealth(addr net.Addr, secure bool) func(ctx context.Context) error {
+	network, address := addr.Network(), addr.String()
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		address = net.JoinHostPort("127.0.0.1", strconv.Itoa(tcp.Port))
+	}
+	var dialer net.Dialer
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}}
+	url := "http://localhost/health"
+	if secure {
+		url = "https://localhost/health"
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned %s", resp.Status)
+		}
+		return nil
+	}
+}
 
-	log.Printf("Server starting on port %s...", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+// fatal logs err at error level and exits with status 1
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -1,15 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"internal-transfers/config"
 	"internal-transfers/database"
 	"internal-transfers/handlers"
+	"internal-transfers/listeners"
+	"internal-transfers/memory"
+	"internal-transfers/middleware"
+	"internal-transfers/models"
+	"internal-transfers/routes"
+	"internal-transfers/shutdown"
+	"internal-transfers/signing"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
 )
 
 // =============================================================================
@@ -819,7 +834,7 @@ func TestSetupRoutes_MethodRestrictions(t *testing.T) {
 		allowedMethod  string
 		rejectedMethod string
 	}{
-		{"/accounts", "POST", "GET"},
+		{"/accounts", "POST", "DELETE"},
 		{"/accounts/123", "GET", "POST"},
 		{"/transactions", "POST", "GET"},
 		{"/health", "GET", "POST"},
@@ -843,7 +858,7 @@ func TestInitializeApp(t *testing.T) {
 	// Test that initializeApp function exists and is callable
 	t.Run("InitializeApp function exists", func(t *testing.T) {
 		// This may succeed or fail depending on whether a database is available
-		h, err := initializeApp()
+		h, err := initializeApp(shutdown.New())
 
 		if err != nil {
 			t.Logf("initializeApp failed as expected without database: %v", err)
@@ -938,3 +953,393 @@ func TestMainPackage_EnvironmentHandling(t *testing.T) {
 		}
 	})
 }
+
+func TestRunCommand_Validation(t *testing.T) {
+	testCases := [][]string{
+		{"unknown"},
+		{"migrate"},
+		{"migrate", "down", "zero"},
+		{"migrate", "down", "-1"},
+		{"doctor", "extra"},
+		{"compat-check"},
+		{"compat-check", "a.json", "b.json"},
+	}
+
+	for _, args := range testCases {
+		t.Run(fmt.Sprint(args), func(t *testing.T) {
+			if err := runCommand(args); err == nil {
+				t.Errorf("Expected error for command %v", args)
+			}
+		})
+	}
+}
+
+func TestRunCompatCheckCommand(t *testing.T) {
+	artifact := filepath.Join(t.TempDir(), "compat.json")
+	if err := runCommand([]string{"compat-check", "-version", "1.0.0", "-write", artifact}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := runCommand([]string{"compat-check", artifact}); err != nil {
+		t.Errorf("Expected this build to be compatible with its own artifact, got %v", err)
+	}
+}
+
+func TestInitializeApp_Storage(t *testing.T) {
+	original := os.Getenv("STORAGE")
+	defer os.Setenv("STORAGE", original)
+
+	t.Run("Memory", func(t *testing.T) {
+		os.Setenv("STORAGE", "memory")
+		h, err := initializeApp(shutdown.New())
+		if err != nil || h == nil {
+			t.Fatalf("Expected memory storage to initialize, got %v", err)
+		}
+
+		// The in-memory mode serves the full API without a database
+		router := setupRoutes(h)
+		requests := []struct {
+			method, path, body string
+			want               int
+		}{
+			{"POST", "/accounts", `{"account_id": 1, "initial_balance": "100"}`, http.StatusCreated},
+			{"POST", "/accounts", `{"account_id": 2, "initial_balance": "0"}`, http.StatusCreated},
+			{"POST", "/accounts", `{"account_id": 1, "initial_balance": "5"}`, http.StatusConflict},
+			{"POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "30"}`, http.StatusCreated},
+			{"POST", "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "300"}`, http.StatusBadRequest},
+			{"GET", "/accounts/2", "", http.StatusOK},
+		}
+		for _, req := range requests {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+			if rr.Code != req.want {
+				t.Errorf("%s %s: expected %d, got %d (%s)", req.method, req.path, req.want, rr.Code, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		os.Setenv("STORAGE", "spanner")
+		if _, err := initializeApp(shutdown.New()); err == nil {
+			t.Error("Expected error for unknown storage backend")
+		}
+	})
+}
+
+func TestInitializeApp_RoundingPolicy(t *testing.T) {
+	originalStorage, originalPolicy := os.Getenv("STORAGE"), os.Getenv("ROUNDING_POLICY")
+	defer os.Setenv("STORAGE", originalStorage)
+	defer os.Setenv("ROUNDING_POLICY", originalPolicy)
+	os.Setenv("STORAGE", "memory")
+
+	os.Setenv("ROUNDING_POLICY", "half_even")
+	if _, err := initializeApp(shutdown.New()); err != nil {
+		t.Errorf("Expected half_even to be accepted, got %v", err)
+	}
+
+	os.Setenv("ROUNDING_POLICY", "ceiling")
+	if _, err := initializeApp(shutdown.New()); err == nil {
+		t.Error("Expected error for unknown rounding policy")
+	}
+}
+
+func TestInitializeApp_CutoffSchedule(t *testing.T) {
+	originalStorage, originalCutoffs := os.Getenv("STORAGE"), os.Getenv("TRANSFER_CUTOFFS")
+	defer os.Setenv("STORAGE", originalStorage)
+	defer os.Setenv("TRANSFER_CUTOFFS", originalCutoffs)
+	os.Setenv("STORAGE", "memory")
+
+	os.Setenv("TRANSFER_CUTOFFS", "internal=17:30,wire=15:00")
+	if _, err := initializeApp(shutdown.New()); err != nil {
+		t.Errorf("Expected valid cut-offs to be accepted, got %v", err)
+	}
+
+	os.Setenv("TRANSFER_CUTOFFS", "wire=3pm")
+	if _, err := initializeApp(shutdown.New()); err == nil {
+		t.Error("Expected error for malformed cut-off time")
+	}
+}
+
+func TestSetupRoutes_SignedRoutes(t *testing.T) {
+	store := memory.NewStore()
+	h := handlers.NewHandlerWithStorage(store).WithSigning(store.APIKeys(), signing.Config{Required: true, MaxAge: time.Minute})
+	router := setupRoutes(h)
+
+	// Every route moving money, or scheduling it to move, refuses unsigned requests
+	for _, route := range []struct {
+		path, body string
+	}{
+		{"/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "5"}`},
+		{"/holds/1/capture", `{"amount": "5"}`},
+		{"/recurring-transfers", `{"source_account_id": 1, "destination_account_id": 2, "amount": "5", "schedule": "@every 1h"}`},
+		{"/recurring-transfers/1/resume", ``},
+		{"/graphql", `{"query": "mutation { transfer(sourceAccountId: 1, destinationAccountId: 2, amount: \"5\") { id } }"}`},
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", route.path, strings.NewReader(route.body)))
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Unsigned POST %s: expected 401, got %d (%s)", route.path, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Every route identifies signed requests, so the signed routes must not verify them again,
+	// which would refuse the signature as a replay
+	key, err := signing.NewManager(store.APIKeys(), signing.Config{}).Create(context.Background(), models.CreateAPIKeyRequest{Description: "batch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	body := `{"source_account_id": 1, "destination_account_id": 2, "amount": "5"}`
+	req := httptest.NewRequest("POST", "/v1/transactions", strings.NewReader(body))
+	signing.SignRequest(req, key.ID, key.Secret, []byte(body), time.Now())
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected a signed transfer to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	// A signature that does not verify is refused on any route
+	req = httptest.NewRequest("GET", "/v1/accounts/1", nil)
+	signing.SignRequest(req, key.ID, "wrong secret", nil, time.Now())
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a bad signature on a read, got %d", rr.Code)
+	}
+}
+
+func TestSetupRoutes_Console(t *testing.T) {
+	original := os.Getenv("CONSOLE_ENABLED")
+	defer os.Setenv("CONSOLE_ENABLED", original)
+
+	for _, tc := range []struct {
+		value string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"false", http.StatusNotFound},
+	} {
+		os.Setenv("CONSOLE_ENABLED", tc.value)
+		router := setupRoutes(handlers.NewHandler(nil))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/console", nil))
+		if rr.Code != tc.want {
+			t.Errorf("CONSOLE_ENABLED=%q: expected %d, got %d", tc.value, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestSetupRoutes_Versions(t *testing.T) {
+	router := setupRoutes(handlers.NewHandler(nil))
+
+	for _, tc := range []struct {
+		path       string
+		deprecated bool
+	}{
+		{"/v1/openapi.json", false},
+		{"/openapi.json", true},
+		{"/health", false},
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tc.path, rr.Code)
+		}
+		if warning := rr.Header().Get("Warning"); (warning != "") != tc.deprecated {
+			t.Errorf("%s: unexpected Warning %q", tc.path, warning)
+		}
+		if tc.path == "/v1/openapi.json" && !strings.Contains(rr.Body.String(), `"/v1/accounts/{account_id}"`) {
+			t.Error("Expected the document to list the versioned paths")
+		}
+	}
+}
+
+func TestListenerRoutes_Scope(t *testing.T) {
+	h := handlers.NewHandler(nil)
+	chain := apiMiddleware(h, middleware.AccessLog, middleware.Allowlist{})
+	config := routes.Config{MaxBodyBytes: routes.DefaultMaxBodyBytes}
+	public := listenerRoutes(h, chain, listeners.Listener{Routes: listeners.PublicRoutes}, config)
+	admin := listenerRoutes(h, chain, listeners.Listener{Routes: listeners.AdminRoutes}, config)
+
+	for _, tc := range []struct {
+		router *mux.Router
+		path   string
+		served bool
+	}{
+		{public, "/health", true},
+		{public, "/admin/usage", false},
+		{public, "/v1/admin/usage", false},
+		{admin, "/admin/usage", true},
+		{admin, "/v1/admin/usage", true},
+		{admin, "/health", false},
+		{admin, "/console", false},
+	} {
+		match := tc.router.Match(httptest.NewRequest("GET", tc.path, nil), &mux.RouteMatch{})
+		if match != tc.served {
+			t.Errorf("%s: expected served=%v, got %v", tc.path, tc.served, match)
+		}
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	for _, name := range []string{"STORAGE", "ROUNDING_POLICY", "SETTLEMENT_EXPORT_DIR", "KAFKA_BROKERS"} {
+		original, set := os.LookupEnv(name)
+		defer func(name string) {
+			if set {
+				os.Setenv(name, original)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name)
+	}
+
+	dir := t.TempDir()
+	os.Setenv("STORAGE", "memory")
+	os.Setenv("ROUNDING_POLICY", "sideways")
+	os.Setenv("SETTLEMENT_EXPORT_DIR", filepath.Join(dir, "exports", "daily"))
+	os.Unsetenv("KAFKA_BROKERS")
+
+	statuses := make(map[string]checkStatus)
+	results := runDoctor(context.Background())
+	for _, result := range results {
+		statuses[result.Name] = result.Status
+	}
+	if statuses["configuration"] != checkFail || statuses["database"] != checkSkip || statuses["settlement export dir"] != checkOK {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	var out bytes.Buffer
+	if failures := printDoctorReport(&out, results); failures != 1 || !strings.Contains(out.String(), "fix: correct the environment variables") {
+		t.Errorf("Expected 1 failure with its fix, got %d:\n%s", failures, out.String())
+	}
+
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, nil, 0o644)
+	if result := checkDirectory("export", file); result.Status != checkFail {
+		t.Errorf("Expected a file to fail the directory check, got %+v", result)
+	}
+	if result := checkDirectory("export", filepath.Join(file, "sub")); result.Status != checkFail {
+		t.Errorf("Expected a directory below a file to fail, got %+v", result)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reachable := listener.Addr().String()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	unreachable := closed.Addr().String()
+	closed.Close()
+	defer listener.Close()
+	if result := checkBrokers(context.Background(), []string{reachable}); result.Status != checkOK {
+		t.Errorf("Expected reachable broker to pass, got %+v", result)
+	}
+	if result := checkBrokers(context.Background(), []string{reachable, unreachable}); result.Status != checkFail || !strings.Contains(result.Detail, unreachable) {
+		t.Errorf("Expected unreachable broker to fail, got %+v", result)
+	}
+
+	if result := checkClock(database.ServerInfo{ClockSkew: -2 * time.Second}); result.Status != checkFail {
+		t.Errorf("Expected clock skew to fail, got %+v", result)
+	}
+	if result := checkClock(database.ServerInfo{ClockSkew: 20 * time.Millisecond}); result.Status != checkOK {
+		t.Errorf("Expected small clock skew to pass, got %+v", result)
+	}
+	if formatBytes(1536<<20) != "1.5 GiB" || formatBytes(512) != "512 B" {
+		t.Errorf("Unexpected byte formatting %s, %s", formatBytes(1536<<20), formatBytes(512))
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(rulesPath, []byte(`{"rules": [{"name": "small_only", "when": "amount > 50"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	write := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"CONFIG_FILE", "LOG_LEVEL", "USAGE_QUOTAS", "RULES_FILE", "PORT"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("STORAGE", "memory")
+	write("log_level = \"info\"\n")
+	source, _, err := config.Load([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	h, err := initializeApp(shutdown.New())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	router := setupRoutes(h)
+	transfer := func() int {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/transactions",
+			strings.NewReader(`{"source_account_id": 1, "destination_account_id": 2, "amount": "80"}`)))
+		return rr.Code
+	}
+	for _, body := range []string{`{"account_id": 1, "initial_balance": "500"}`, `{"account_id": 2, "initial_balance": "0"}`} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/accounts", strings.NewReader(body)))
+	}
+	if code := transfer(); code != http.StatusCreated {
+		t.Fatalf("Expected the transfer to succeed before the reload, got %d", code)
+	}
+
+	// Rules and quotas apply to the running server; PORT waits for a restart
+	write("rules_file = '" + rulesPath + "'\nusage_quotas = \"key_a=5\"\nport = 9999\n")
+	if err := reloadConfig(source, h); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if code := transfer(); code == http.StatusCreated || h.Rules().Len() != 1 {
+		t.Errorf("Expected the reloaded rule to reject the transfer, got %d", code)
+	}
+	if quota, ok := h.Usage().Quota("key_a"); !ok || quota != 5 {
+		t.Errorf("Expected the reloaded quota, got %d", quota)
+	}
+
+	// An invalid change is refused and the previous settings stay in effect
+	write("rules_file = '" + rulesPath + "'\nusage_quotas = \"key_a\"\n")
+	if err := reloadConfig(source, h); err == nil {
+		t.Error("Expected an error for invalid quotas")
+	}
+	if quota, ok := h.Usage().Quota("key_a"); !ok || quota != 5 || h.Rules().Len() != 1 {
+		t.Errorf("Expected the previous settings to remain, got quota %d and %d rules", quota, h.Rules().Len())
+	}
+	if got := os.Getenv("USAGE_QUOTAS"); got != "key_a=5" {
+		t.Errorf("Expected the environment to keep USAGE_QUOTAS=key_a=5, got %q", got)
+	}
+
+	// A file with one invalid setting applies none of them, not even the valid ones
+	write("usage_quotas = \"key_a=7\"\nlog_level = \"loud\"\n")
+	if err := reloadConfig(source, h); err == nil {
+		t.Error("Expected an error for an invalid log level")
+	}
+	for name, want := range map[string]string{"USAGE_QUOTAS": "key_a=5", "LOG_LEVEL": "", "RULES_FILE": rulesPath} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("Expected a refused reload to leave %s=%q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestProtoDefinitionsUpToDate(t *testing.T) {
+	checkedIn, err := os.ReadFile(protoFile)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", protoFile, err)
+	}
+	file, err := protoDefinitions()
+	if err != nil {
+		t.Fatalf("Failed to build the Protocol Buffers definitions: %v", err)
+	}
+	generated, err := file.Generate(checkedIn)
+	if err != nil {
+		t.Fatalf("Failed to generate the Protocol Buffers definitions: %v", err)
+	}
+	if !bytes.Equal(generated, checkedIn) {
+		t.Errorf("%s is out of date with the API and event types; run go generate", protoFile)
+	}
+}
@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"fmt"
+
+	"internal-transfers/models"
+)
+
+// Apply applies a recorded ledger event (see models.LedgerEvent) to the store without validating
+// it against the ledger's rules, which held when the event was recorded
+// It rebuilds the state of an event-sourced ledger: events must be applied in sequence order to a
+// store whose accounts and transactions only ever changed through Apply
+// Returns an error for an event that does not fit the store's state, e.g. a transfer between
+// unknown accounts or out of ID order
+func (s *Store) Apply(event models.LedgerEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch event.Type {
+	case models.LedgerAccountOpened:
+		if _, exists := s.accounts[event.AccountID]; exists || event.Balance == nil {
+			return fmt.Errorf("event %d: invalid opening of account %d", event.Sequence, event.AccountID)
+		}
+		s.accounts[event.AccountID] = &models.Account{
+			AccountID:      event.AccountID,
+			Balance:        *event.Balance,
+			InitialBalance: *event.Balance,
+			Currency:       event.Currency,
+			ExternalID:     event.ExternalID,
+			CreationToken:  event.CreationToken,
+			Status:         models.AccountActive,
+			Version:        1,
+			CreatedAt:      event.OccurredAt,
+			UpdatedAt:      event.OccurredAt,
+		}
+		s.initialBalances[event.AccountID] = *event.Balance
+		s.addSnapshot(models.BalanceSnapshot{AccountID: event.AccountID, Balance: *event.Balance, Source: models.BalanceSnapshotOpened})
+
+	case models.LedgerAccountStatusChanged:
+		account, err := s.eventAccount(event)
+		if err != nil {
+			return err
+		}
+		if account.Status != event.Status {
+			account.Status = event.Status
+			account.Version++
+			account.UpdatedAt = event.OccurredAt
+		}
+
+	case models.LedgerOverdraftChanged:
+		account, err := s.eventAccount(event)
+		if err != nil {
+			return err
+		}
+		if event.OverdraftLimit == nil {
+			return fmt.Errorf("event %d: missing overdraft limit", event.Sequence)
+		}
+		if !account.OverdraftLimit.Equal(*event.OverdraftLimit) {
+			account.OverdraftLimit = *event.OverdraftLimit
+			account.Version++
+			account.UpdatedAt = event.OccurredAt
+		}
+
+	case models.LedgerAccountUpdated:
+		account, err := s.eventAccount(event)
+		if err != nil {
+			return err
+		}
+		account.Metadata = event.Metadata
+		account.Tags = append([]string{}, event.Tags...)
+		account.ExternalID = event.ExternalID
+		account.Version++
+		account.UpdatedAt = event.OccurredAt
+
+	case models.LedgerAccountAdjusted:
+		account, err := s.eventAccount(event)
+		if err != nil {
+			return err
+		}
+		if a := event.Adjustment; a == nil || a.ID != int64(len(s.adjustments))+1 || a.AccountID != account.AccountID {
+			return fmt.Errorf("event %d: adjustment out of order", event.Sequence)
+		}
+		s.applyAdjustment(account, *event.Adjustment)
+
+	case models.LedgerWalletOpened:
+		account, err := s.eventAccount(event)
+		if err != nil {
+			return err
+		}
+		w := event.Wallet
+		if w == nil || w.AccountID != account.AccountID || s.wallets[walletKey{w.AccountID, w.Currency}] != nil {
+			return fmt.Errorf("event %d: invalid wallet opening of account %d", event.Sequence, event.AccountID)
+		}
+		s.openWallet(*w)
+
+	case models.LedgerTransferCommitted:
+		t := event.Transaction
+		if t == nil || t.ID != int64(len(s.transactions))+1 {
+			return fmt.Errorf("event %d: transaction out of order", event.Sequence)
+		}
+		source, sourceExists := s.accounts[t.SourceAccountID]
+		destination, destinationExists := s.accounts[t.DestinationAccountID]
+		if !sourceExists || !destinationExists {
+			return fmt.Errorf("event %d: transaction %d between unknown accounts", event.Sequence, t.ID)
+		}
+		for _, key := range []walletKey{{t.SourceAccountID, t.SourceWallet}, {t.DestinationAccountID, t.DestinationWallet}} {
+			if _, exists := s.wallets[key]; key.currency != "" && !exists {
+				return fmt.Errorf("event %d: transaction %d moves unknown wallet %s of account %d", event.Sequence, t.ID, key.currency, key.accountID)
+			}
+		}
+		s.commitTransaction(source, destination, *t)
+
+	case models.LedgerTransactionSettled, models.LedgerTransactionReturned:
+		t, err := s.transaction(event.TransactionID)
+		if err != nil {
+			return fmt.Errorf("event %d: transaction %d not found", event.Sequence, event.TransactionID)
+		}
+		if event.Type == models.LedgerTransactionSettled {
+			t.SettlementStatus = models.SettlementSettled
+		} else {
+			t.SettlementStatus = models.SettlementReturned
+			t.Status = models.TransactionReversed
+		}
+
+	default:
+		return fmt.Errorf("event %d: unknown type %q", event.Sequence, event.Type)
+	}
+	return nil
+}
+
+// eventAccount returns the stored account an account event applies to; the caller must hold the
+// write lock
+func (s *Store) eventAccount(event models.LedgerEvent) (*models.Account, error) {
+	account, exists := s.accounts[event.AccountID]
+	if !exists {
+		return nil, fmt.Errorf("event %d: account %d not found", event.Sequence, event.AccountID)
+	}
+	return account, nil
+}
@@ -0,0 +1,1800 @@
+// Package memory provides in-memory implementations of the repository interfaces
+// It backs the STORAGE=memory runtime mode, letting demos and integration tests run the
+// service with zero external dependencies; state is lost when the process exits
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// Store holds all in-memory ledger state behind a single mutex and implements database.Storage
+// One lock for accounts and transactions keeps transfers atomic exactly like the
+// row-locked database transaction: readers never observe a half-applied transfer
+type Store struct {
+	mu       sync.RWMutex
+	accounts map[int64]*models.Account
+	// initialBalances are the balances accounts were opened with, for reconciliation
+	initialBalances map[int64]decimal.Decimal
+	transactions    []models.Transaction
+	settlements     []models.SettlementFile
+	usage           map[usageKey]models.Usage
+	latencies       map[int64]models.TransferLatency
+	recurring       map[int64]*models.RecurringTransfer
+	executions      []models.RecurringExecution
+	// holds are stored in ID order; a hold's ID is its index + 1
+	holds  []models.Hold
+	limits map[int64]models.TransferLimits
+	// audit is append-only; an event's ID is its index + 1
+	audit []models.AuditEvent
+	// snapshots are stored in ID order; a snapshot's ID is its index + 1
+	snapshots []models.BalanceSnapshot
+	// attachments are stored in ID order; an attachment's ID is its index + 1
+	attachments []models.Attachment
+	// adjustments are stored in ID order; an adjustment's ID is its index + 1
+	adjustments []models.Adjustment
+	// wallets are the balances of multi-currency accounts in currencies other than their own
+	wallets map[walletKey]*models.Wallet
+	// currencies are configured by code, starting with the built-in ones
+	currencies map[string]models.Currency
+	// apiKeys are stored in creation order
+	apiKeys []models.APIKey
+	// lastRuleID and lastExecutionID are the most recently assigned IDs
+	lastRuleID      int64
+	lastExecutionID int64
+	now             func() time.Time
+}
+
+// usageKey identifies one row of the usage rollup
+type usageKey struct {
+	keyID string
+	day   string
+}
+
+// walletKey identifies an account's wallet in a currency
+type walletKey struct {
+	accountID int64
+	currency  string
+}
+
+// NewStore creates an empty in-memory store
+func NewStore() *Store {
+	s := &Store{
+		accounts:        make(map[int64]*models.Account),
+		initialBalances: make(map[int64]decimal.Decimal),
+		usage:           make(map[usageKey]models.Usage),
+		latencies:       make(map[int64]models.TransferLatency),
+		recurring:       make(map[int64]*models.RecurringTransfer),
+		limits:          make(map[int64]models.TransferLimits),
+		wallets:         make(map[walletKey]*models.Wallet),
+		currencies:      make(map[string]models.Currency),
+		now:             time.Now,
+	}
+	for _, currency := range models.DefaultCurrencies() {
+		s.currencies[currency.Code] = currency
+	}
+	return s
+}
+
+// Accounts returns an account repository backed by the store
+func (s *Store) Accounts() database.AccountRepositoryInterface {
+	return NewAccountRepository(s)
+}
+
+// Transactions returns a transaction repository backed by the store
+func (s *Store) Transactions() database.TransactionRepositoryInterface {
+	return NewTransactionRepository(s)
+}
+
+// Settlements returns a settlement repository backed by the store
+func (s *Store) Settlements() database.SettlementRepositoryInterface {
+	return NewSettlementRepository(s)
+}
+
+// Usage returns a usage rollup repository backed by the store
+func (s *Store) Usage() database.UsageRepositoryInterface {
+	return NewUsageRepository(s)
+}
+
+// Latency returns a transfer latency repository backed by the store
+func (s *Store) Latency() database.LatencyRepositoryInterface {
+	return NewLatencyRepository(s)
+}
+
+// Recurring returns a recurring transfer repository backed by the store
+func (s *Store) Recurring() database.RecurringRepositoryInterface {
+	return NewRecurringRepository(s)
+}
+
+// Holds returns a hold repository backed by the store
+func (s *Store) Holds() database.HoldRepositoryInterface {
+	return NewHoldRepository(s)
+}
+
+// Limits returns a transfer limit repository backed by the store
+func (s *Store) Limits() database.LimitRepositoryInterface {
+	return NewLimitRepository(s)
+}
+
+// BalanceHistory returns a balance snapshot repository backed by the store
+func (s *Store) BalanceHistory() database.BalanceHistoryRepositoryInterface {
+	return NewBalanceHistoryRepository(s)
+}
+
+// Reconciliation returns a balance reconciliation repository backed by the store
+func (s *Store) Reconciliation() database.ReconciliationRepositoryInterface {
+	return NewReconciliationRepository(s)
+}
+
+// Retention returns nil: the store is discarded on exit, so nothing needs purging
+func (s *Store) Retention() database.RetentionRepositoryInterface {
+	return nil
+}
+
+// Attachments returns an attachment repository backed by the store
+func (s *Store) Attachments() database.AttachmentRepositoryInterface {
+	return NewAttachmentRepository(s)
+}
+
+// Adjustments returns a manual adjustment repository backed by the store
+func (s *Store) Adjustments() database.AdjustmentRepositoryInterface {
+	return NewAdjustmentRepository(s)
+}
+
+// Wallets returns a wallet repository backed by the store
+func (s *Store) Wallets() database.WalletRepositoryInterface {
+	return NewWalletRepository(s)
+}
+
+// Currencies returns a currency repository backed by the store
+func (s *Store) Currencies() database.CurrencyRepositoryInterface {
+	return NewCurrencyRepository(s)
+}
+
+// APIKeys returns an API key repository backed by the store
+func (s *Store) APIKeys() database.APIKeyRepositoryInterface {
+	return NewAPIKeyRepository(s)
+}
+
+// Audit returns an audit log repository backed by the store
+func (s *Store) Audit() database.AuditRepositoryInterface {
+	return NewAuditRepository(s)
+}
+
+// Outbox returns nil: the in-memory backend does not record events
+func (s *Store) Outbox() database.OutboxRepositoryInterface {
+	return nil
+}
+
+// Close is a no-op; the store's state lives until the process exits
+func (s *Store) Close() error {
+	return nil
+}
+
+// AccountRepository implements database.AccountRepositoryInterface on a Store
+type AccountRepository struct {
+	store *Store
+}
+
+// NewAccountRepository creates an account repository backed by the store
+func NewAccountRepository(store *Store) *AccountRepository {
+	return &AccountRepository{store: store}
+}
+
+// CreateAccount adds a new account, failing with "account already exists" on duplicate IDs,
+// "external ID already exists" if another account has the external ID and "creation token
+// already used" if another account was created with the token
+func (r *AccountRepository) CreateAccount(account models.NewAccount) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	accountID, initialBalance := account.AccountID, account.InitialBalance
+	if _, exists := r.store.accounts[accountID]; exists {
+		return fmt.Errorf("account already exists")
+	}
+	if account.ExternalID != "" && r.store.externalIDTaken(account.ExternalID, accountID) {
+		return fmt.Errorf("external ID already exists")
+	}
+	if account.CreationToken != "" {
+		for _, existing := range r.store.accounts {
+			if existing.CreationToken == account.CreationToken {
+				return fmt.Errorf("creation token already used")
+			}
+		}
+	}
+	created := r.store.now().UTC()
+	r.store.accounts[accountID] = &models.Account{
+		AccountID:      accountID,
+		Balance:        initialBalance,
+		InitialBalance: initialBalance,
+		Currency:       account.Currency,
+		ExternalID:     account.ExternalID,
+		CreationToken:  account.CreationToken,
+		Status:         models.AccountActive,
+		Version:        1,
+		CreatedAt:      created,
+		UpdatedAt:      created,
+	}
+	r.store.initialBalances[accountID] = initialBalance
+	r.store.addSnapshot(models.BalanceSnapshot{AccountID: accountID, Balance: initialBalance, Source: models.BalanceSnapshotOpened})
+	return nil
+}
+
+// GetAccount returns a copy of the account so callers cannot mutate stored state
+func (r *AccountRepository) GetAccount(accountID int64) (*models.Account, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	account, exists := r.store.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	return cloneAccount(account), nil
+}
+
+// GetAccountByExternalID returns a copy of the account with the external ID
+func (r *AccountRepository) GetAccountByExternalID(externalID string) (*models.Account, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, account := range r.store.accounts {
+		if account.ExternalID == externalID {
+			return cloneAccount(account), nil
+		}
+	}
+	return nil, fmt.Errorf("account not found")
+}
+
+// externalIDTaken reports whether an account other than accountID has the external ID; the
+// caller must hold the lock
+func (s *Store) externalIDTaken(externalID string, accountID int64) bool {
+	for _, account := range s.accounts {
+		if account.ExternalID == externalID && account.AccountID != accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountExists reports whether an account with the given ID exists
+func (r *AccountRepository) AccountExists(accountID int64) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	_, exists := r.store.accounts[accountID]
+	return exists, nil
+}
+
+// SetAccountStatus freezes or unfreezes an account
+func (r *AccountRepository) SetAccountStatus(accountID int64, status string) (*models.Account, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	account, exists := r.store.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	if account.Status != status {
+		account.Status = status
+		account.Version++
+		account.UpdatedAt = r.store.now().UTC()
+	}
+	return cloneAccount(account), nil
+}
+
+// SetOverdraftLimit changes how far below zero the account's balance may go
+func (r *AccountRepository) SetOverdraftLimit(accountID int64, limit decimal.Decimal) (*models.Account, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	account, exists := r.store.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	if account.Balance.LessThan(limit.Neg()) {
+		return nil, fmt.Errorf("balance below overdraft limit")
+	}
+	if !account.OverdraftLimit.Equal(limit) {
+		account.OverdraftLimit = limit
+		account.Version++
+		account.UpdatedAt = r.store.now().UTC()
+	}
+	return cloneAccount(account), nil
+}
+
+// UpdateAccount merges metadata changes and replaces tags and the external ID, checking the
+// expected version if set
+func (r *AccountRepository) UpdateAccount(accountID int64, update models.AccountUpdate) (*models.Account, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	account, exists := r.store.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	if update.ExpectedVersion != 0 && update.ExpectedVersion != account.Version {
+		return nil, fmt.Errorf("account version mismatch")
+	}
+	if update.ExternalID != nil && *update.ExternalID != "" && r.store.externalIDTaken(*update.ExternalID, accountID) {
+		return nil, fmt.Errorf("external ID already exists")
+	}
+	metadata := models.Metadata{}
+	for key, value := range account.Metadata {
+		metadata[key] = value
+	}
+	for _, key := range update.RemoveMetadata {
+		delete(metadata, key)
+	}
+	for key, value := range update.SetMetadata {
+		metadata[key] = value
+	}
+	account.Metadata = metadata
+	if update.Tags != nil {
+		account.Tags = append([]string{}, update.Tags...)
+	}
+	if update.ExternalID != nil {
+		account.ExternalID = *update.ExternalID
+	}
+	account.Version++
+	account.UpdatedAt = r.store.now().UTC()
+	return cloneAccount(account), nil
+}
+
+// ListAccounts returns a page of the accounts matching the filter, ordered by account ID, and the
+// total number of matches
+func (r *AccountRepository) ListAccounts(filter models.AccountFilter, limit, offset int) ([]models.Account, int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	ids := make([]int64, 0, len(r.store.accounts))
+	for id, account := range r.store.accounts {
+		if filter.Matches(*account) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	total := len(ids)
+	ids = ids[sort.Search(total, func(i int) bool { return ids[i] > filter.AfterID }):]
+	ids = ids[min(offset, len(ids)):]
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	accounts := make([]models.Account, len(ids))
+	for i, id := range ids {
+		accounts[i] = *cloneAccount(r.store.accounts[id])
+	}
+	return accounts, total, nil
+}
+
+// cloneAccount copies an account, including its metadata and tags, so callers cannot mutate
+// stored state
+func cloneAccount(account *models.Account) *models.Account {
+	copied := *account
+	copied.Metadata = models.Metadata{}
+	for key, value := range account.Metadata {
+		copied.Metadata[key] = value
+	}
+	copied.Tags = append([]string{}, account.Tags...)
+	return &copied
+}
+
+// TransactionRepository implements database.TransactionRepositoryInterface on a Store
+type TransactionRepository struct {
+	store *Store
+}
+
+// NewTransactionRepository creates a transaction repository backed by the store
+func NewTransactionRepository(store *Store) *TransactionRepository {
+	return &TransactionRepository{store: store}
+}
+
+// CreateTransaction atomically moves amount between two accounts
+// Enforces the same rules and returns the same error messages as the PostgreSQL repository
+// The store's write lock stands in for the account row locks, so waiting for it is the lock wait
+func (r *TransactionRepository) CreateTransaction(transfer models.Transfer) (*models.Transaction, error) {
+	start := time.Now()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	lockWait := time.Since(start)
+
+	transaction, err := r.store.transfer(transfer)
+	if err != nil {
+		return nil, err
+	}
+	transaction.LockWait = lockWait
+	return transaction, nil
+}
+
+// transfer applies a transfer to the store; the caller must hold the write lock
+func (s *Store) transfer(transfer models.Transfer) (*models.Transaction, error) {
+	transfer = transfer.WithDefaults(s.now())
+	sourceAccountID, destinationAccountID, amount := transfer.SourceAccountID, transfer.DestinationAccountID, transfer.Amount
+
+	if transfer.DependsOn != 0 {
+		dependency, err := s.transaction(transfer.DependsOn)
+		if err != nil {
+			return nil, fmt.Errorf("dependency not found")
+		}
+		if err := dependency.CheckDependency(); err != nil {
+			return nil, err
+		}
+	}
+
+	source, exists := s.accounts[sourceAccountID]
+	if !exists {
+		return nil, fmt.Errorf("source account not found")
+	}
+	if source.Status == models.AccountFrozen {
+		return nil, fmt.Errorf("source account frozen")
+	}
+	// A captured hold stops reserving its amount, so it is available to the capture
+	available := source.Available()
+	var hold *models.Hold
+	if transfer.HoldID != 0 {
+		var err error
+		if hold, err = s.capturableHold(transfer); err != nil {
+			return nil, err
+		}
+		available = available.Add(hold.Amount)
+	}
+	// A wallet leg debits the account's wallet, which cannot be overdrawn, and is not limited
+	sourceWallet := transfer.Wallet(source.Currency)
+	if sourceWallet != "" {
+		wallet, exists := s.wallets[walletKey{sourceAccountID, sourceWallet}]
+		if !exists {
+			return nil, fmt.Errorf("source wallet not found")
+		}
+		available = wallet.Balance
+	}
+	if available.LessThan(amount) {
+		return nil, fmt.Errorf("insufficient balance")
+	}
+	// Reversing a returned transfer is not limited
+	if limits, exists := s.limits[sourceAccountID]; exists && transfer.ReturnOf == 0 && sourceWallet == "" {
+		usedAmount, usedCount := s.dailyUsage(sourceAccountID, models.LimitDay(s.now()))
+		if err := limits.Check(amount, usedAmount, usedCount); err != nil {
+			return nil, err
+		}
+	}
+	destination, exists := s.accounts[destinationAccountID]
+	if !exists {
+		return nil, fmt.Errorf("destination account not found")
+	}
+	if destination.Status == models.AccountFrozen {
+		return nil, fmt.Errorf("destination account frozen")
+	}
+	if err := transfer.CheckCurrencies(source.Currency, destination.Currency); err != nil {
+		return nil, err
+	}
+	destinationWallet := transfer.Wallet(destination.Currency)
+	if _, exists := s.wallets[walletKey{destinationAccountID, destinationWallet}]; destinationWallet != "" && !exists {
+		return nil, fmt.Errorf("destination wallet not found")
+	}
+	// Checked last, as PostgreSQL finds a duplicate reference when inserting the transaction
+	if transfer.Reference != "" {
+		for _, t := range s.transactions {
+			if t.SourceAccountID == sourceAccountID && t.Reference == transfer.Reference {
+				return nil, fmt.Errorf("duplicate reference")
+			}
+		}
+	}
+
+	transaction := models.Transaction{
+		ID:                   int64(len(s.transactions)) + 1,
+		SourceAccountID:      sourceAccountID,
+		DestinationAccountID: destinationAccountID,
+		Amount:               amount,
+		SourceSequence:       source.Sequence + 1,
+		DestinationSequence:  destination.Sequence + 1,
+		RoundingPolicy:       transfer.RoundingPolicy,
+		TransferType:         transfer.TransferType,
+		ValueDate:            transfer.ValueDate,
+		Status:               models.TransactionCompleted,
+		SettlementStatus:     models.SettlementUnsettled,
+		ReturnOf:             transfer.ReturnOf,
+		DestinationAmount:    transfer.Credit(),
+		FXRate:               transfer.FXRate,
+		FXRateTimestamp:      transfer.FXRateTimestamp,
+		CreatedAt:            s.now(),
+		EffectiveAt:          transfer.EffectiveAt,
+		Reference:            transfer.Reference,
+		Memo:                 transfer.Memo,
+		DependsOn:            transfer.DependsOn,
+		SourceWallet:         sourceWallet,
+		DestinationWallet:    destinationWallet,
+	}
+	if transaction.EffectiveAt.IsZero() {
+		transaction.EffectiveAt = transaction.CreatedAt
+	}
+	s.commitTransaction(source, destination, transaction)
+	if hold != nil {
+		s.endHold(hold, models.HoldCaptured)
+		hold.TransactionID = transaction.ID
+	}
+
+	return &transaction, nil
+}
+
+// commitTransaction moves the balances of a transaction between its accounts, the primary
+// balances or wallets it names, and records it with the primary balances it left; the caller must
+// hold the write lock and have checked that its wallets exist
+func (s *Store) commitTransaction(source, destination *models.Account, t models.Transaction) {
+	s.move(source, t.SourceWallet, t.Amount.Neg())
+	source.Sequence = t.SourceSequence
+	s.move(destination, t.DestinationWallet, t.DestinationAmount)
+	destination.Sequence = t.DestinationSequence
+	source.UpdatedAt, destination.UpdatedAt = t.CreatedAt.UTC(), t.CreatedAt.UTC()
+	s.transactions = append(s.transactions, t)
+	for _, account := range []*models.Account{source, destination} {
+		if t.Wallet(account.AccountID) == "" {
+			s.addSnapshot(models.BalanceSnapshot{AccountID: account.AccountID, Balance: account.Balance, Sequence: account.Sequence, Source: models.BalanceSnapshotTransfer, TransactionID: t.ID})
+		}
+	}
+}
+
+// move changes the account's primary balance, or its wallet in currency, by delta; the caller
+// must hold the write lock
+func (s *Store) move(account *models.Account, currency string, delta decimal.Decimal) {
+	if currency == "" {
+		account.Balance = account.Balance.Add(delta)
+		return
+	}
+	wallet := s.wallets[walletKey{account.AccountID, currency}]
+	wallet.Balance = wallet.Balance.Add(delta)
+}
+
+// ListTransactions returns the account's transactions newest first, capped at limit
+func (r *TransactionRepository) ListTransactions(accountID int64, limit int) ([]models.Transaction, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	transactions := []models.Transaction{}
+	for i := len(r.store.transactions) - 1; i >= 0 && len(transactions) < limit; i-- {
+		t := r.store.transactions[i]
+		if t.SourceAccountID == accountID || t.DestinationAccountID == accountID {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+// SearchTransactions returns the account's transactions matching the filter in its order by
+// (CreatedAt, ID), capped at limit
+func (r *TransactionRepository) SearchTransactions(filter models.TransactionFilter, limit int) ([]models.Transaction, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	transactions := []models.Transaction{}
+	for _, t := range r.store.transactions {
+		if filter.Matches(t) && filter.ListedAfter(t.Position(), filter.After) {
+			transactions = append(transactions, t)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return filter.ListedAfter(transactions[j].Position(), transactions[i].Position())
+	})
+	return transactions[:min(limit, len(transactions))], nil
+}
+
+// SearchAllTransactions returns the transactions of any account matching the search newest first
+// by (CreatedAt, ID), capped at limit
+func (r *TransactionRepository) SearchAllTransactions(search models.TransactionSearch, limit int) ([]models.Transaction, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	transactions := []models.Transaction{}
+	for _, t := range r.store.transactions {
+		if search.Matches(t) && t.Position().Follows(search.After) {
+			transactions = append(transactions, t)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[j].Position().Follows(transactions[i].Position())
+	})
+	return transactions[:min(limit, len(transactions))], nil
+}
+
+// ListChanges returns the account's transactions after sinceSeq in sequence order, capped at limit
+func (r *TransactionRepository) ListChanges(accountID, sinceSeq int64, limit int) ([]models.Transaction, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// Sequence numbers are assigned in commit order, which is also the order of the slice
+	transactions := []models.Transaction{}
+	for _, t := range r.store.transactions {
+		if len(transactions) == limit {
+			break
+		}
+		if (t.SourceAccountID == accountID && t.SourceSequence > sinceSeq) ||
+			(t.DestinationAccountID == accountID && t.DestinationSequence > sinceSeq) {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+// BalanceAsOf returns the account's primary balance counting only the movements recorded by
+// recordedAt and effective by effectiveAt
+func (r *TransactionRepository) BalanceAsOf(accountID int64, recordedAt, effectiveAt time.Time) (decimal.Decimal, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	account, exists := r.store.accounts[accountID]
+	if !exists || account.CreatedAt.After(recordedAt) {
+		return decimal.Zero, fmt.Errorf("account not found")
+	}
+	balance := account.Balance
+	for _, t := range r.store.transactions {
+		if (t.SourceAccountID == accountID || t.DestinationAccountID == accountID) && t.Wallet(accountID) == "" && !t.Counts(recordedAt, effectiveAt) {
+			balance = balance.Sub(t.Movement(accountID))
+		}
+	}
+	for _, a := range r.store.adjustments {
+		if a.AccountID == accountID && !a.Counts(recordedAt, effectiveAt) {
+			balance = balance.Sub(a.Amount)
+		}
+	}
+	return balance, nil
+}
+
+// SettlementRepository implements database.SettlementRepositoryInterface on a Store
+type SettlementRepository struct {
+	store *Store
+}
+
+// NewSettlementRepository creates a settlement repository backed by the store
+func NewSettlementRepository(store *Store) *SettlementRepository {
+	return &SettlementRepository{store: store}
+}
+
+// TransactionsByValueDate returns the transactions value-dated on date involving any of the accounts
+func (r *SettlementRepository) TransactionsByValueDate(ctx context.Context, date time.Time, accountIDs []int64) ([]models.Transaction, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	return transactionsByValueDate(r.store.transactions, date, accountIDs), nil
+}
+
+// Snapshot calls fn with a view of a copy of the ledger taken under the read lock
+// Transfers made while fn runs are not visible to it, and fn does not block them
+func (r *SettlementRepository) Snapshot(ctx context.Context, fn func(database.SettlementSnapshot) error) error {
+	r.store.mu.RLock()
+	transactions := append([]models.Transaction(nil), r.store.transactions...)
+	r.store.mu.RUnlock()
+	return fn(settlementSnapshot{transactions: transactions})
+}
+
+// settlementSnapshot implements database.SettlementSnapshot on a copy of the ledger
+type settlementSnapshot struct {
+	transactions []models.Transaction
+}
+
+// TransactionsByValueDate returns the transactions value-dated on date as of the snapshot
+func (s settlementSnapshot) TransactionsByValueDate(ctx context.Context, date time.Time, accountIDs []int64) ([]models.Transaction, error) {
+	return transactionsByValueDate(s.transactions, date, accountIDs), nil
+}
+
+// transactionsByValueDate filters ledger to the transactions value-dated on date involving any of the accounts
+func transactionsByValueDate(ledger []models.Transaction, date time.Time, accountIDs []int64) []models.Transaction {
+	accounts := make(map[int64]bool, len(accountIDs))
+	for _, id := range accountIDs {
+		accounts[id] = true
+	}
+	day := date.Format("2006-01-02")
+
+	transactions := []models.Transaction{}
+	for _, t := range ledger {
+		if t.ValueDate.Format("2006-01-02") == day && (accounts[t.SourceAccountID] || accounts[t.DestinationAccountID]) {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions
+}
+
+// GetTransaction returns a transaction by ID or a "transaction not found" error
+func (r *SettlementRepository) GetTransaction(ctx context.Context, transactionID int64) (*models.Transaction, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	t, err := r.store.transaction(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	copied := *t
+	return &copied, nil
+}
+
+// SettleTransaction marks an unsettled transaction as settled
+func (r *SettlementRepository) SettleTransaction(ctx context.Context, transactionID int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	t, err := r.store.transaction(transactionID)
+	if err != nil {
+		return err
+	}
+	if t.SettlementStatus == models.SettlementReturned {
+		return fmt.Errorf("transaction already returned")
+	}
+	t.SettlementStatus = models.SettlementSettled
+	return nil
+}
+
+// ReturnTransaction atomically books the reverse of a returned transfer and marks it returned
+func (r *SettlementRepository) ReturnTransaction(ctx context.Context, transactionID int64, valueDate time.Time) (*models.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	original, err := r.store.transaction(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if original.SettlementStatus == models.SettlementReturned {
+		return nil, fmt.Errorf("transaction already returned")
+	}
+
+	returned, err := r.store.transfer(original.Return(valueDate))
+	if err != nil {
+		return nil, err
+	}
+	// transfer appended to the slice, so look the original up again
+	original, _ = r.store.transaction(transactionID)
+	original.SettlementStatus = models.SettlementReturned
+	original.Status = models.TransactionReversed
+	return returned, nil
+}
+
+// transaction returns a pointer to the stored transaction; the caller must hold the lock
+// IDs are assigned sequentially from 1, so the ID is the slice position plus one
+func (s *Store) transaction(transactionID int64) (*models.Transaction, error) {
+	if transactionID < 1 || transactionID > int64(len(s.transactions)) {
+		return nil, fmt.Errorf("transaction not found")
+	}
+	return &s.transactions[transactionID-1], nil
+}
+
+// SaveSettlementFile upserts the generation record for the file's partner and business date
+func (r *SettlementRepository) SaveSettlementFile(ctx context.Context, file models.SettlementFile) (*models.SettlementFile, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	file.GeneratedAt = r.store.now()
+	for i, existing := range r.store.settlements {
+		if existing.PartnerID == file.PartnerID && existing.BusinessDate.Equal(file.BusinessDate) {
+			file.ID = existing.ID
+			r.store.settlements[i] = file
+			return &file, nil
+		}
+	}
+	file.ID = int64(len(r.store.settlements)) + 1
+	r.store.settlements = append(r.store.settlements, file)
+	return &file, nil
+}
+
+// ListSettlementFiles returns generation records newest business date first, then by partner,
+// after the record at after if set, capped at limit
+func (r *SettlementRepository) ListSettlementFiles(ctx context.Context, date time.Time, partnerID string, after models.SettlementFileKey, limit int) ([]models.SettlementFile, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	files := []models.SettlementFile{}
+	for _, f := range r.store.settlements {
+		if (date.IsZero() || f.BusinessDate.Equal(date)) && (partnerID == "" || f.PartnerID == partnerID) &&
+			(after == models.SettlementFileKey{} || f.Key().After(after)) {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[j].Key().After(files[i].Key()) })
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	return files, nil
+}
+
+// UsageRepository implements database.UsageRepositoryInterface on a Store
+type UsageRepository struct {
+	store *Store
+}
+
+// NewUsageRepository creates a usage repository backed by the store
+func NewUsageRepository(store *Store) *UsageRepository {
+	return &UsageRepository{store: store}
+}
+
+// AddUsage adds the records' counters to the rollup rows for the same key and day
+func (r *UsageRepository) AddUsage(ctx context.Context, records []models.Usage) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, u := range records {
+		key := usageKey{keyID: u.KeyID, day: u.Day.Format("2006-01-02")}
+		row, exists := r.store.usage[key]
+		if !exists {
+			row = models.Usage{KeyID: u.KeyID, Day: time.Date(u.Day.Year(), u.Day.Month(), u.Day.Day(), 0, 0, 0, 0, time.UTC)}
+		}
+		row.Add(u)
+		r.store.usage[key] = row
+	}
+	return nil
+}
+
+// ListUsage returns the rollup rows within [from, to], ordered by key and day
+func (r *UsageRepository) ListUsage(ctx context.Context, keyID string, from, to time.Time) ([]models.Usage, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	first, last := from.Format("2006-01-02"), to.Format("2006-01-02")
+	records := []models.Usage{}
+	for key, row := range r.store.usage {
+		if (keyID == "" || key.keyID == keyID) && key.day >= first && key.day <= last {
+			records = append(records, row)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].KeyID != records[j].KeyID {
+			return records[i].KeyID < records[j].KeyID
+		}
+		return records[i].Day.Before(records[j].Day)
+	})
+	return records, nil
+}
+
+// LatencyRepository implements database.LatencyRepositoryInterface on a Store
+type LatencyRepository struct {
+	store *Store
+}
+
+// NewLatencyRepository creates a latency repository backed by the store
+func NewLatencyRepository(store *Store) *LatencyRepository {
+	return &LatencyRepository{store: store}
+}
+
+// AddLatencies records the samples, ignoring transactions already recorded
+// Durations are truncated to microseconds, the PostgreSQL backend's resolution
+func (r *LatencyRepository) AddLatencies(ctx context.Context, samples []models.TransferLatency) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, s := range samples {
+		if _, exists := r.store.latencies[s.TransactionID]; !exists {
+			s.Validate = s.Validate.Truncate(time.Microsecond)
+			s.LockWait = s.LockWait.Truncate(time.Microsecond)
+			s.Commit = s.Commit.Truncate(time.Microsecond)
+			s.Emit = s.Emit.Truncate(time.Microsecond)
+			if s.Rules == nil {
+				s.Rules = []string{}
+			}
+			r.store.latencies[s.TransactionID] = s
+		}
+	}
+	return nil
+}
+
+// AddRetries adds to the retry counts of the recorded transactions
+func (r *LatencyRepository) AddRetries(ctx context.Context, retries map[int64]int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for transactionID, count := range retries {
+		if s, exists := r.store.latencies[transactionID]; exists {
+			s.Retries += count
+			r.store.latencies[transactionID] = s
+		}
+	}
+	return nil
+}
+
+// GetLatency returns the sample of a transaction, or nil if none was recorded
+func (r *LatencyRepository) GetLatency(ctx context.Context, transactionID int64) (*models.TransferLatency, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	s, exists := r.store.latencies[transactionID]
+	if !exists {
+		return nil, nil
+	}
+	s.Rules = append([]string{}, s.Rules...)
+	return &s, nil
+}
+
+// LatencyStats computes each client's latency percentiles over the samples received in [from, to)
+func (r *LatencyRepository) LatencyStats(ctx context.Context, clientID string, from, to time.Time, target time.Duration) ([]models.LatencyStats, error) {
+	r.store.mu.RLock()
+	commits := make(map[string][]time.Duration)
+	emits := make(map[string][]time.Duration)
+	for _, s := range r.store.latencies {
+		if (clientID == "" || s.ClientID == clientID) && !s.ReceivedAt.Before(from) && s.ReceivedAt.Before(to) {
+			commits[s.ClientID] = append(commits[s.ClientID], s.Commit)
+			emits[s.ClientID] = append(emits[s.ClientID], s.Emit)
+		}
+	}
+	r.store.mu.RUnlock()
+
+	stats := []models.LatencyStats{}
+	for client, commit := range commits {
+		emit := emits[client]
+		sort.Slice(commit, func(i, j int) bool { return commit[i] < commit[j] })
+		sort.Slice(emit, func(i, j int) bool { return emit[i] < emit[j] })
+		s := models.LatencyStats{
+			ClientID:  client,
+			Transfers: int64(len(commit)),
+			CommitP50: percentile(commit, 0.5),
+			CommitP95: percentile(commit, 0.95),
+			CommitP99: percentile(commit, 0.99),
+			CommitMax: commit[len(commit)-1],
+			EmitP95:   percentile(emit, 0.95),
+		}
+		for _, d := range commit {
+			if d <= target {
+				s.WithinTarget++
+			}
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ClientID < stats[j].ClientID })
+	return stats, nil
+}
+
+// percentile interpolates linearly between the closest ranks of sorted, like percentile_cont
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	position := p * float64(len(sorted)-1)
+	lower := int(position)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	fraction := position - float64(lower)
+	return sorted[lower] + time.Duration(math.Round(fraction*float64(sorted[lower+1]-sorted[lower])))
+}
+
+// RecurringRepository implements database.RecurringRepositoryInterface on a Store
+type RecurringRepository struct {
+	store *Store
+}
+
+// NewRecurringRepository creates a recurring transfer repository backed by the store
+func NewRecurringRepository(store *Store) *RecurringRepository {
+	return &RecurringRepository{store: store}
+}
+
+// CreateRecurringTransfer stores a rule under the next ID
+func (r *RecurringRepository) CreateRecurringTransfer(ctx context.Context, rule models.RecurringTransfer) (*models.RecurringTransfer, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.lastRuleID++
+	rule.ID = r.store.lastRuleID
+	rule.CreatedAt = r.store.now().UTC()
+	r.store.recurring[rule.ID] = &rule
+	stored := rule
+	return &stored, nil
+}
+
+// GetRecurringTransfer returns a copy of the rule or "recurring transfer not found"
+func (r *RecurringRepository) GetRecurringTransfer(ctx context.Context, id int64) (*models.RecurringTransfer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	rule, exists := r.store.recurring[id]
+	if !exists {
+		return nil, fmt.Errorf("recurring transfer not found")
+	}
+	stored := *rule
+	return &stored, nil
+}
+
+// ListRecurringTransfers returns the rules with an ID above afterID ordered by ID, capped at limit
+func (r *RecurringRepository) ListRecurringTransfers(ctx context.Context, afterID int64, limit int) ([]models.RecurringTransfer, error) {
+	return r.list(limit, func(rule models.RecurringTransfer) bool { return rule.ID > afterID }, func(a, b models.RecurringTransfer) bool { return a.ID < b.ID }), nil
+}
+
+// SetRecurringTransferStatus changes a rule's status and next run time
+func (r *RecurringRepository) SetRecurringTransferStatus(ctx context.Context, id int64, status string, nextRunAt time.Time) (*models.RecurringTransfer, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rule, exists := r.store.recurring[id]
+	if !exists {
+		return nil, fmt.Errorf("recurring transfer not found")
+	}
+	rule.Status, rule.NextRunAt = status, nextRunAt
+	stored := *rule
+	return &stored, nil
+}
+
+// DeleteRecurringTransfer removes a rule and its executions
+func (r *RecurringRepository) DeleteRecurringTransfer(ctx context.Context, id int64) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, exists := r.store.recurring[id]; !exists {
+		return fmt.Errorf("recurring transfer not found")
+	}
+	delete(r.store.recurring, id)
+	kept := r.store.executions[:0]
+	for _, e := range r.store.executions {
+		if e.RecurringTransferID != id {
+			kept = append(kept, e)
+		}
+	}
+	r.store.executions = kept
+	return nil
+}
+
+// DueRecurringTransfers returns the active rules due at now, earliest first
+func (r *RecurringRepository) DueRecurringTransfers(ctx context.Context, now time.Time, limit int) ([]models.RecurringTransfer, error) {
+	due := func(rule models.RecurringTransfer) bool {
+		return rule.Status == models.RecurringActive && !rule.NextRunAt.After(now)
+	}
+	earliest := func(a, b models.RecurringTransfer) bool {
+		if !a.NextRunAt.Equal(b.NextRunAt) {
+			return a.NextRunAt.Before(b.NextRunAt)
+		}
+		return a.ID < b.ID
+	}
+	return r.list(limit, due, earliest), nil
+}
+
+// AdvanceRecurringTransfer moves an active rule's next run from from to to
+func (r *RecurringRepository) AdvanceRecurringTransfer(ctx context.Context, id int64, from, to time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	rule, exists := r.store.recurring[id]
+	if !exists || rule.Status != models.RecurringActive || !rule.NextRunAt.Equal(from) {
+		return false, nil
+	}
+	rule.NextRunAt = to
+	return true, nil
+}
+
+// AddRecurringExecution records the outcome of an attempt of a run under the next ID
+// The store publishes no events, so failed executions are not escalated
+func (r *RecurringRepository) AddRecurringExecution(ctx context.Context, execution models.RecurringExecution) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if execution.Attempt == 0 {
+		execution.Attempt = 1
+	}
+	r.store.lastExecutionID++
+	execution.ID = r.store.lastExecutionID
+	r.store.executions = append(r.store.executions, execution)
+	return nil
+}
+
+// DueRecurringRetries returns the queued retries of active rules due at now, earliest first
+func (r *RecurringRepository) DueRecurringRetries(ctx context.Context, now time.Time, limit int) ([]models.RecurringExecution, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	retries := []models.RecurringExecution{}
+	for _, execution := range r.store.executions {
+		rule, exists := r.store.recurring[execution.RecurringTransferID]
+		if execution.RetryAt != nil && !execution.RetryAt.After(now) && exists && rule.Status == models.RecurringActive {
+			retries = append(retries, execution)
+		}
+	}
+	sort.SliceStable(retries, func(i, j int) bool { return retries[i].RetryAt.Before(*retries[j].RetryAt) })
+	if len(retries) > limit {
+		retries = retries[:limit]
+	}
+	return retries, nil
+}
+
+// ClaimRecurringRetry clears a queued retry's retry time, reporting false if it has none
+func (r *RecurringRepository) ClaimRecurringRetry(ctx context.Context, executionID int64) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i := range r.store.executions {
+		if execution := &r.store.executions[i]; execution.ID == executionID {
+			if execution.RetryAt == nil {
+				return false, nil
+			}
+			execution.RetryAt = nil
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListRecurringExecutions returns a rule's executions newest first, below beforeID if set, capped at limit
+func (r *RecurringRepository) ListRecurringExecutions(ctx context.Context, id, beforeID int64, limit int) ([]models.RecurringExecution, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	executions := []models.RecurringExecution{}
+	for i := len(r.store.executions) - 1; i >= 0 && len(executions) < limit; i-- {
+		if execution := r.store.executions[i]; execution.RecurringTransferID == id && (beforeID == 0 || execution.ID < beforeID) {
+			executions = append(executions, r.store.executions[i])
+		}
+	}
+	return executions, nil
+}
+
+// list returns copies of the rules matching keep, sorted by less and capped at limit
+func (r *RecurringRepository) list(limit int, keep func(models.RecurringTransfer) bool, less func(a, b models.RecurringTransfer) bool) []models.RecurringTransfer {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	rules := []models.RecurringTransfer{}
+	for _, rule := range r.store.recurring {
+		if keep(*rule) {
+			rules = append(rules, *rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return less(rules[i], rules[j]) })
+	if len(rules) > limit {
+		rules = rules[:limit]
+	}
+	return rules
+}
+
+// capturableHold returns the hold a transfer captures, with the PostgreSQL repository's errors if
+// it cannot be captured; the caller must hold the write lock
+func (s *Store) capturableHold(transfer models.Transfer) (*models.Hold, error) {
+	if transfer.HoldID < 1 || transfer.HoldID > int64(len(s.holds)) {
+		return nil, fmt.Errorf("hold not found")
+	}
+	hold := &s.holds[transfer.HoldID-1]
+	switch {
+	case hold.Status != models.HoldActive:
+		return nil, fmt.Errorf("hold not active")
+	case !hold.ExpiresAt.After(s.now()):
+		return nil, fmt.Errorf("hold expired")
+	case hold.SourceAccountID != transfer.SourceAccountID || hold.DestinationAccountID != transfer.DestinationAccountID:
+		return nil, fmt.Errorf("hold %d is not for this transfer's accounts", transfer.HoldID)
+	case transfer.Amount.GreaterThan(hold.Amount):
+		return nil, fmt.Errorf("capture exceeds hold amount")
+	}
+	return hold, nil
+}
+
+// endHold sets an active hold's final status and frees its amount; the caller must hold the write lock
+func (s *Store) endHold(hold *models.Hold, status string) {
+	hold.Status = status
+	hold.UpdatedAt = s.now().UTC()
+	if source, exists := s.accounts[hold.SourceAccountID]; exists {
+		source.Held = source.Held.Sub(hold.Amount)
+		source.UpdatedAt = hold.UpdatedAt
+	}
+}
+
+// HoldRepository implements database.HoldRepositoryInterface on a Store
+type HoldRepository struct {
+	store *Store
+}
+
+// NewHoldRepository creates a hold repository backed by the store
+func NewHoldRepository(store *Store) *HoldRepository {
+	return &HoldRepository{store: store}
+}
+
+// CreateHold reserves the hold's amount on its source account and stores it under the next ID
+// Enforces the same rules and returns the same error messages as the PostgreSQL repository
+func (r *HoldRepository) CreateHold(ctx context.Context, hold models.Hold) (*models.Hold, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	source, exists := r.store.accounts[hold.SourceAccountID]
+	if !exists {
+		return nil, fmt.Errorf("source account not found")
+	}
+	if source.Status == models.AccountFrozen {
+		return nil, fmt.Errorf("source account frozen")
+	}
+	if source.Available().LessThan(hold.Amount) {
+		return nil, fmt.Errorf("insufficient balance")
+	}
+	if _, exists := r.store.accounts[hold.DestinationAccountID]; !exists {
+		return nil, fmt.Errorf("destination account not found")
+	}
+
+	source.Held = source.Held.Add(hold.Amount)
+	hold.ID = int64(len(r.store.holds)) + 1
+	hold.Status = models.HoldActive
+	hold.TransactionID = 0
+	hold.CreatedAt = r.store.now().UTC()
+	hold.UpdatedAt = hold.CreatedAt
+	source.UpdatedAt = hold.CreatedAt
+	r.store.holds = append(r.store.holds, hold)
+	return &hold, nil
+}
+
+// GetHold returns a copy of the hold or "hold not found"
+func (r *HoldRepository) GetHold(ctx context.Context, id int64) (*models.Hold, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if id < 1 || id > int64(len(r.store.holds)) {
+		return nil, fmt.Errorf("hold not found")
+	}
+	hold := r.store.holds[id-1]
+	return &hold, nil
+}
+
+// ListHolds returns the holds on a source account newest first, below beforeID if set, capped at limit
+func (r *HoldRepository) ListHolds(ctx context.Context, accountID, beforeID int64, limit int) ([]models.Hold, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// A hold's ID is its index + 1, so the holds below beforeID end at index beforeID - 2
+	start := len(r.store.holds) - 1
+	if beforeID != 0 {
+		start = min(start, int(beforeID)-2)
+	}
+	holds := []models.Hold{}
+	for i := start; i >= 0 && len(holds) < limit; i-- {
+		if r.store.holds[i].SourceAccountID == accountID {
+			holds = append(holds, r.store.holds[i])
+		}
+	}
+	return holds, nil
+}
+
+// ReleaseHold ends an active hold without a transfer and frees its amount
+func (r *HoldRepository) ReleaseHold(ctx context.Context, id int64) (*models.Hold, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if id < 1 || id > int64(len(r.store.holds)) {
+		return nil, fmt.Errorf("hold not found")
+	}
+	hold := &r.store.holds[id-1]
+	if hold.Status != models.HoldActive {
+		return nil, fmt.Errorf("hold not active")
+	}
+	r.store.endHold(hold, models.HoldReleased)
+	released := *hold
+	return &released, nil
+}
+
+// ExpireHolds expires up to limit active holds whose expiry is at or before now, earliest first
+func (r *HoldRepository) ExpireHolds(ctx context.Context, now time.Time, limit int) ([]models.Hold, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var due []*models.Hold
+	for i := range r.store.holds {
+		if hold := &r.store.holds[i]; hold.Status == models.HoldActive && !hold.ExpiresAt.After(now) {
+			due = append(due, hold)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].ExpiresAt.Before(due[j].ExpiresAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	expired := []models.Hold{}
+	for _, hold := range due {
+		r.store.endHold(hold, models.HoldExpired)
+		expired = append(expired, *hold)
+	}
+	return expired, nil
+}
+
+// CountActiveHolds returns the number of holds still reserving funds
+func (r *HoldRepository) CountActiveHolds(ctx context.Context) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var count int64
+	for _, hold := range r.store.holds {
+		if hold.Status == models.HoldActive {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// LimitRepository implements database.LimitRepositoryInterface on a Store
+type LimitRepository struct {
+	store *Store
+}
+
+// NewLimitRepository creates a transfer limit repository backed by the store
+func NewLimitRepository(store *Store) *LimitRepository {
+	return &LimitRepository{store: store}
+}
+
+// GetLimits returns an account's transfer limits or "account not found"
+func (r *LimitRepository) GetLimits(ctx context.Context, accountID int64) (*models.TransferLimits, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if _, exists := r.store.accounts[accountID]; !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	limits, exists := r.store.limits[accountID]
+	if !exists {
+		limits = models.TransferLimits{AccountID: accountID}
+	}
+	return &limits, nil
+}
+
+// SetLimits replaces an account's transfer limits or returns "account not found"
+func (r *LimitRepository) SetLimits(ctx context.Context, limits models.TransferLimits) (*models.TransferLimits, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, exists := r.store.accounts[limits.AccountID]; !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	r.store.limits[limits.AccountID] = limits
+	return &limits, nil
+}
+
+// DailyUsage returns the total amount and number of transfers debiting the account's primary
+// balance since since
+func (r *LimitRepository) DailyUsage(ctx context.Context, accountID int64, since time.Time) (decimal.Decimal, int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	amount, count := r.store.dailyUsage(accountID, since)
+	return amount, count, nil
+}
+
+// dailyUsage sums the debits of the account's primary balance created at or after since; the
+// caller must hold the lock
+func (s *Store) dailyUsage(accountID int64, since time.Time) (decimal.Decimal, int) {
+	amount, count := decimal.Zero, 0
+	for i := len(s.transactions) - 1; i >= 0 && !s.transactions[i].CreatedAt.Before(since); i-- {
+		if s.transactions[i].SourceAccountID == accountID && s.transactions[i].SourceWallet == "" {
+			amount = amount.Add(s.transactions[i].Amount)
+			count++
+		}
+	}
+	return amount, count
+}
+
+// AuditRepository implements database.AuditRepositoryInterface on a Store
+type AuditRepository struct {
+	store *Store
+}
+
+// NewAuditRepository creates an audit log repository backed by the store
+func NewAuditRepository(store *Store) *AuditRepository {
+	return &AuditRepository{store: store}
+}
+
+// AppendAuditEvent appends the event, stamping its ID and occurrence time
+func (r *AuditRepository) AppendAuditEvent(ctx context.Context, event models.AuditEvent) (*models.AuditEvent, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	event.ID = int64(len(r.store.audit)) + 1
+	event.OccurredAt = r.store.now()
+	event.AccountIDs = append([]int64{}, event.AccountIDs...)
+	if len(event.Before) == 0 {
+		event.Before = json.RawMessage("null")
+	}
+	if len(event.After) == 0 {
+		event.After = json.RawMessage("null")
+	}
+	r.store.audit = append(r.store.audit, event)
+	return &event, nil
+}
+
+// ListAuditEvents returns the events matching the filter newest first, capped at limit
+func (r *AuditRepository) ListAuditEvents(ctx context.Context, filter models.AuditFilter, limit int) ([]models.AuditEvent, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	events := []models.AuditEvent{}
+	end := int64(len(r.store.audit))
+	if filter.BeforeID != 0 {
+		end = min(end, filter.BeforeID-1)
+	}
+	for i := end - 1; i >= 0 && len(events) < limit; i-- {
+		if filter.Matches(r.store.audit[i]) {
+			events = append(events, r.store.audit[i])
+		}
+	}
+	return events, nil
+}
+
+// BalanceHistoryRepository implements database.BalanceHistoryRepositoryInterface on a Store
+type BalanceHistoryRepository struct {
+	store *Store
+}
+
+// NewBalanceHistoryRepository creates a balance snapshot repository backed by the store
+func NewBalanceHistoryRepository(store *Store) *BalanceHistoryRepository {
+	return &BalanceHistoryRepository{store: store}
+}
+
+// SnapshotBalances records a periodic snapshot of every account without a snapshot since since
+func (r *BalanceHistoryRepository) SnapshotBalances(ctx context.Context, since time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	recent := make(map[int64]bool)
+	for i := len(r.store.snapshots) - 1; i >= 0 && !r.store.snapshots[i].TakenAt.Before(since); i-- {
+		recent[r.store.snapshots[i].AccountID] = true
+	}
+	ids := make([]int64, 0, len(r.store.accounts))
+	for id := range r.store.accounts {
+		if !recent[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		account := r.store.accounts[id]
+		r.store.addSnapshot(models.BalanceSnapshot{AccountID: id, Balance: account.Balance, Sequence: account.Sequence, Source: models.BalanceSnapshotPeriodic})
+	}
+	return int64(len(ids)), nil
+}
+
+// ListBalanceSnapshots returns the account's snapshots in the filter's period oldest first
+func (r *BalanceHistoryRepository) ListBalanceSnapshots(ctx context.Context, filter models.BalanceHistoryFilter, limit int) ([]models.BalanceSnapshot, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	snapshots := []models.BalanceSnapshot{}
+	for _, snapshot := range r.store.snapshots[min(filter.AfterID, int64(len(r.store.snapshots))):] {
+		if len(snapshots) == limit {
+			break
+		}
+		if filter.Matches(snapshot) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+// ReconciliationRepository implements database.ReconciliationRepositoryInterface on a Store
+type ReconciliationRepository struct {
+	store *Store
+}
+
+// NewReconciliationRepository creates a balance reconciliation repository backed by the store
+func NewReconciliationRepository(store *Store) *ReconciliationRepository {
+	return &ReconciliationRepository{store: store}
+}
+
+// ReconcileBalances recomputes every account's primary balance from its initial balance,
+// transactions and adjustments under the read lock, so no transfer is half counted
+func (r *ReconciliationRepository) ReconcileBalances(ctx context.Context) (int64, []models.BalanceDiscrepancy, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	computed := make(map[int64]decimal.Decimal, len(r.store.accounts))
+	movements := make(map[int64]int64, len(r.store.accounts))
+	for _, t := range r.store.transactions {
+		for _, id := range []int64{t.SourceAccountID, t.DestinationAccountID} {
+			if t.Wallet(id) == "" {
+				computed[id] = computed[id].Add(t.Movement(id))
+				movements[id]++
+			}
+		}
+	}
+	for _, a := range r.store.adjustments {
+		computed[a.AccountID] = computed[a.AccountID].Add(a.Amount)
+		movements[a.AccountID]++
+	}
+
+	discrepancies := []models.BalanceDiscrepancy{}
+	for id, account := range r.store.accounts {
+		initial := r.store.initialBalances[id]
+		if balance := initial.Add(computed[id]); !balance.Equal(account.Balance) {
+			discrepancies = append(discrepancies, models.BalanceDiscrepancy{
+				AccountID:       id,
+				InitialBalance:  initial,
+				StoredBalance:   account.Balance,
+				ComputedBalance: balance,
+				Transactions:    movements[id],
+			})
+		}
+	}
+	sort.Slice(discrepancies, func(i, j int) bool { return discrepancies[i].AccountID < discrepancies[j].AccountID })
+	return int64(len(r.store.accounts)), discrepancies, nil
+}
+
+// AttachmentRepository implements database.AttachmentRepositoryInterface on a Store
+type AttachmentRepository struct {
+	store *Store
+}
+
+// NewAttachmentRepository creates an attachment repository backed by the store
+func NewAttachmentRepository(store *Store) *AttachmentRepository {
+	return &AttachmentRepository{store: store}
+}
+
+// AddAttachment records an attachment of an existing transaction
+func (r *AttachmentRepository) AddAttachment(ctx context.Context, attachment models.Attachment) (*models.Attachment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, err := r.store.transaction(attachment.TransactionID); err != nil {
+		return nil, err
+	}
+	attachment.ID = int64(len(r.store.attachments)) + 1
+	attachment.CreatedAt = r.store.now()
+	r.store.attachments = append(r.store.attachments, attachment)
+	return &attachment, nil
+}
+
+// ListAttachments returns a transaction's attachments oldest first
+func (r *AttachmentRepository) ListAttachments(ctx context.Context, transactionID int64) ([]models.Attachment, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if _, err := r.store.transaction(transactionID); err != nil {
+		return nil, err
+	}
+	attachments := []models.Attachment{}
+	for _, attachment := range r.store.attachments {
+		if attachment.TransactionID == transactionID {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
+
+// GetAttachment returns one of a transaction's attachments
+func (r *AttachmentRepository) GetAttachment(ctx context.Context, transactionID, id int64) (*models.Attachment, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if id < 1 || id > int64(len(r.store.attachments)) || r.store.attachments[id-1].TransactionID != transactionID {
+		return nil, fmt.Errorf("attachment not found")
+	}
+	attachment := r.store.attachments[id-1]
+	return &attachment, nil
+}
+
+// AdjustmentRepository implements database.AdjustmentRepositoryInterface on a Store
+type AdjustmentRepository struct {
+	store *Store
+}
+
+// NewAdjustmentRepository creates a manual adjustment repository backed by the store
+func NewAdjustmentRepository(store *Store) *AdjustmentRepository {
+	return &AdjustmentRepository{store: store}
+}
+
+// CreateAdjustment applies the adjustment to its account and records it, refusing a debit beyond
+// the available balance
+func (r *AdjustmentRepository) CreateAdjustment(ctx context.Context, adjustment models.Adjustment) (*models.Adjustment, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	account, exists := r.store.accounts[adjustment.AccountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	if adjustment.Amount.IsNegative() && account.Available().LessThan(adjustment.Amount.Neg()) {
+		return nil, fmt.Errorf("insufficient balance")
+	}
+	adjustment.ID = int64(len(r.store.adjustments)) + 1
+	adjustment.CreatedAt = r.store.now()
+	adjustment = r.store.applyAdjustment(account, adjustment)
+	return &adjustment, nil
+}
+
+// ListAdjustments returns the account's adjustments created in [from, to) oldest first
+func (r *AdjustmentRepository) ListAdjustments(ctx context.Context, accountID int64, from, to time.Time, limit int) ([]models.Adjustment, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if _, exists := r.store.accounts[accountID]; !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	adjustments := []models.Adjustment{}
+	for _, a := range r.store.adjustments {
+		if len(adjustments) == limit {
+			break
+		}
+		if a.AccountID == accountID && (from.IsZero() || !a.CreatedAt.Before(from)) && (to.IsZero() || a.CreatedAt.Before(to)) {
+			adjustments = append(adjustments, a)
+		}
+	}
+	return adjustments, nil
+}
+
+// applyAdjustment books an adjustment with its ID and creation time on account, setting its
+// balance after; the caller must hold the write lock
+func (s *Store) applyAdjustment(account *models.Account, adjustment models.Adjustment) models.Adjustment {
+	account.Balance = account.Balance.Add(adjustment.Amount)
+	account.Version++
+	account.UpdatedAt = adjustment.CreatedAt.UTC()
+	adjustment.BalanceAfter = account.Balance
+	s.adjustments = append(s.adjustments, adjustment)
+	s.addSnapshot(models.BalanceSnapshot{AccountID: account.AccountID, Balance: account.Balance, Sequence: account.Sequence, Source: models.BalanceSnapshotAdjustment})
+	return adjustment
+}
+
+// WalletRepository implements database.WalletRepositoryInterface on a Store
+type WalletRepository struct {
+	store *Store
+}
+
+// NewWalletRepository creates a wallet repository backed by the store
+func NewWalletRepository(store *Store) *WalletRepository {
+	return &WalletRepository{store: store}
+}
+
+// OpenWallet opens the account's empty wallet in a currency other than its own
+func (r *WalletRepository) OpenWallet(ctx context.Context, accountID int64, currency string) (*models.Wallet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	account, exists := r.store.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("account not found")
+	}
+	if account.Currency == "" {
+		return nil, fmt.Errorf("account has no currency")
+	}
+	if _, exists := r.store.wallets[walletKey{accountID, currency}]; exists || account.Currency == currency {
+		return nil, fmt.Errorf("wallet already exists")
+	}
+	wallet := models.Wallet{AccountID: accountID, Currency: currency, Balance: decimal.Zero, CreatedAt: r.store.now().UTC()}
+	r.store.openWallet(wallet)
+	return &wallet, nil
+}
+
+// ListWallets returns the account's wallets ordered by currency
+func (r *WalletRepository) ListWallets(ctx context.Context, accountID int64) ([]models.Wallet, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	wallets := []models.Wallet{}
+	for key, wallet := range r.store.wallets {
+		if key.accountID == accountID {
+			wallets = append(wallets, *wallet)
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].Currency < wallets[j].Currency })
+	return wallets, nil
+}
+
+// openWallet stores a new wallet, updating its account; the caller must hold the write lock
+func (s *Store) openWallet(wallet models.Wallet) {
+	s.wallets[walletKey{wallet.AccountID, wallet.Currency}] = &wallet
+	if account, exists := s.accounts[wallet.AccountID]; exists {
+		account.UpdatedAt = wallet.CreatedAt
+	}
+}
+
+// CurrencyRepository implements database.CurrencyRepositoryInterface on a Store
+type CurrencyRepository struct {
+	store *Store
+}
+
+// NewCurrencyRepository creates a currency repository backed by the store
+func NewCurrencyRepository(store *Store) *CurrencyRepository {
+	return &CurrencyRepository{store: store}
+}
+
+// ListCurrencies returns every configured currency ordered by code
+func (r *CurrencyRepository) ListCurrencies(ctx context.Context) ([]models.Currency, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	currencies := make([]models.Currency, 0, len(r.store.currencies))
+	for _, currency := range r.store.currencies {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Code < currencies[j].Code })
+	return currencies, nil
+}
+
+// SetCurrency adds or replaces a currency's configuration
+// Returns "currency has overdraft limits" if negative balances are disallowed while accounts in
+// the currency have an overdraft limit
+func (r *CurrencyRepository) SetCurrency(ctx context.Context, currency models.Currency) (*models.Currency, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if !currency.AllowNegative {
+		for _, account := range r.store.accounts {
+			if account.Currency == currency.Code && account.OverdraftLimit.IsPositive() {
+				return nil, fmt.Errorf("currency has overdraft limits")
+			}
+		}
+	}
+	currency.UpdatedAt = r.store.now().UTC()
+	r.store.currencies[currency.Code] = currency
+	return &currency, nil
+}
+
+// APIKeyRepository implements database.APIKeyRepositoryInterface on a Store
+type APIKeyRepository struct {
+	store *Store
+}
+
+// NewAPIKeyRepository creates an API key repository backed by the store
+func NewAPIKeyRepository(store *Store) *APIKeyRepository {
+	return &APIKeyRepository{store: store}
+}
+
+// CreateAPIKey stores a new key and returns it with its creation time
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key.CreatedAt = r.store.now().UTC()
+	key.RevokedAt = nil
+	r.store.apiKeys = append(r.store.apiKeys, key)
+	return &key, nil
+}
+
+// GetAPIKey returns a key, revoked or not, or "API key not found"
+func (r *APIKeyRepository) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, key := range r.store.apiKeys {
+		if key.ID == id {
+			return &key, nil
+		}
+	}
+	return nil, fmt.Errorf("API key not found")
+}
+
+// ListAPIKeys returns every key, revoked ones included, oldest first
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return append([]models.APIKey{}, r.store.apiKeys...), nil
+}
+
+// RevokeAPIKey marks a key revoked and returns it
+// A revoked key keeps its original revocation time; unknown keys return "API key not found"
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i := range r.store.apiKeys {
+		key := &r.store.apiKeys[i]
+		if key.ID != id {
+			continue
+		}
+		if key.RevokedAt == nil {
+			revokedAt := r.store.now().UTC()
+			key.RevokedAt = &revokedAt
+		}
+		revoked := *key
+		return &revoked, nil
+	}
+	return nil, fmt.Errorf("API key not found")
+}
+
+// addSnapshot appends a balance snapshot taken now; the caller must hold the write lock
+func (s *Store) addSnapshot(snapshot models.BalanceSnapshot) {
+	snapshot.ID = int64(len(s.snapshots)) + 1
+	snapshot.TakenAt = s.now()
+	s.snapshots = append(s.snapshots, snapshot)
+}
+
+// Compile-time interface implementation checks
+var _ database.Storage = (*Store)(nil)
+var _ database.AccountRepositoryInterface = (*AccountRepository)(nil)
+var _ database.TransactionRepositoryInterface = (*TransactionRepository)(nil)
+var _ database.SettlementRepositoryInterface = (*SettlementRepository)(nil)
+var _ database.UsageRepositoryInterface = (*UsageRepository)(nil)
+var _ database.LatencyRepositoryInterface = (*LatencyRepository)(nil)
+var _ database.RecurringRepositoryInterface = (*RecurringRepository)(nil)
+var _ database.HoldRepositoryInterface = (*HoldRepository)(nil)
+var _ database.LimitRepositoryInterface = (*LimitRepository)(nil)
+var _ database.AuditRepositoryInterface = (*AuditRepository)(nil)
+var _ database.BalanceHistoryRepositoryInterface = (*BalanceHistoryRepository)(nil)
+var _ database.ReconciliationRepositoryInterface = (*ReconciliationRepository)(nil)
+var _ database.AttachmentRepositoryInterface = (*AttachmentRepository)(nil)
+var _ database.AdjustmentRepositoryInterface = (*AdjustmentRepository)(nil)
+var _ database.WalletRepositoryInterface = (*WalletRepository)(nil)
+var _ database.CurrencyRepositoryInterface = (*CurrencyRepository)(nil)
+var _ database.APIKeyRepositoryInterface = (*APIKeyRepository)(nil)
@@ -0,0 +1,920 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+func newRepositories() (*AccountRepository, *TransactionRepository) {
+	store := NewStore()
+	return NewAccountRepository(store), NewTransactionRepository(store)
+}
+
+func TestAccountRepository(t *testing.T) {
+	accounts, _ := newRepositories()
+
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(5)}); err == nil || err.Error() != "account already exists" {
+		t.Errorf("Expected duplicate error, got %v", err)
+	}
+
+	exists, _ := accounts.AccountExists(1)
+	if !exists {
+		t.Error("Expected account 1 to exist")
+	}
+
+	account, err := accounts.GetAccount(1)
+	if err != nil || !account.Balance.Equal(decimal.NewFromInt(100)) {
+		t.Fatalf("Expected balance 100, got %v, %v", account, err)
+	}
+
+	// Mutating the returned copy must not change stored state
+	account.Balance = decimal.Zero
+	account, _ = accounts.GetAccount(1)
+	if !account.Balance.Equal(decimal.NewFromInt(100)) {
+		t.Error("GetAccount must return a copy")
+	}
+
+	if _, err := accounts.GetAccount(2); err == nil || err.Error() != "account not found" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestAccountRepository_MetadataAndTags(t *testing.T) {
+	accounts, _ := newRepositories()
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.Zero})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	accounts.CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.Zero})
+
+	accounts.UpdateAccount(1, models.AccountUpdate{SetMetadata: models.Metadata{"name": "Payroll", "cost_center": "CC-1"}, Tags: []string{"payroll", "eu"}})
+	account, err := accounts.UpdateAccount(1, models.AccountUpdate{SetMetadata: models.Metadata{"cost_center": "CC-2"}, RemoveMetadata: []string{"name"}})
+	if err != nil || len(account.Metadata) != 1 || account.Metadata["cost_center"] != "CC-2" || len(account.Tags) != 2 {
+		t.Errorf("Unexpected account after merge %+v (%v)", account, err)
+	}
+	accounts.UpdateAccount(3, models.AccountUpdate{Tags: []string{"eu"}})
+
+	// Mutating the returned copy must not change stored state
+	account.Tags[0] = "changed"
+	account.Metadata["cost_center"] = "changed"
+	if stored, _ := accounts.GetAccount(1); stored.Tags[0] != "payroll" || stored.Metadata["cost_center"] != "CC-2" {
+		t.Errorf("UpdateAccount must return a copy, stored %+v", stored)
+	}
+
+	if list, total, _ := accounts.ListAccounts(models.AccountFilter{Tag: "eu"}, 10, 0); len(list) != 2 || total != 2 || list[0].AccountID != 1 || list[1].AccountID != 3 {
+		t.Errorf("Expected accounts 1 and 3 tagged eu, got %+v", list)
+	}
+	if list, total, _ := accounts.ListAccounts(models.AccountFilter{}, 2, 0); len(list) != 2 || total != 3 || list[1].AccountID != 2 {
+		t.Errorf("Expected the first two of three accounts, got %+v (%d)", list, total)
+	}
+	if list, total, _ := accounts.ListAccounts(models.AccountFilter{}, 2, 2); len(list) != 1 || total != 3 || list[0].AccountID != 3 {
+		t.Errorf("Expected the third account on the second page, got %+v (%d)", list, total)
+	}
+	if list, total, _ := accounts.ListAccounts(models.AccountFilter{}, 2, 10); len(list) != 0 || total != 3 {
+		t.Errorf("Expected an empty page past the end, got %+v (%d)", list, total)
+	}
+	// Versions count updates; conditional updates need the current version
+	if account.Version != 3 {
+		t.Errorf("Expected version 3 after two updates, got %d", account.Version)
+	}
+	if _, err := accounts.UpdateAccount(1, models.AccountUpdate{Tags: []string{}, ExpectedVersion: 2}); err == nil || err.Error() != "account version mismatch" {
+		t.Errorf("Expected version mismatch, got %v", err)
+	}
+	accounts.SetAccountStatus(1, models.AccountFrozen)
+	if frozen, _ := accounts.SetAccountStatus(1, models.AccountFrozen); frozen.Version != 4 {
+		t.Errorf("Expected only the status change to bump the version, got %d", frozen.Version)
+	}
+
+	if _, err := accounts.UpdateAccount(9, models.AccountUpdate{}); err == nil || err.Error() != "account not found" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestAccountRepository_ExternalID(t *testing.T) {
+	accounts, _ := newRepositories()
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.Zero, ExternalID: "ERP-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero, ExternalID: "ERP-1"}); err == nil || err.Error() != "external ID already exists" {
+		t.Errorf("Expected a duplicate external ID error, got %v", err)
+	}
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	if account, err := accounts.GetAccountByExternalID("ERP-1"); err != nil || account.AccountID != 1 {
+		t.Errorf("Expected account 1, got %+v (%v)", account, err)
+	}
+
+	taken, moved := "ERP-1", "ERP-9"
+	if _, err := accounts.UpdateAccount(2, models.AccountUpdate{ExternalID: &taken}); err == nil || err.Error() != "external ID already exists" {
+		t.Errorf("Expected a duplicate external ID error, got %v", err)
+	}
+	// Updating an account to its own external ID is not a conflict
+	if account, err := accounts.UpdateAccount(1, models.AccountUpdate{ExternalID: &taken}); err != nil || account.ExternalID != "ERP-1" {
+		t.Errorf("Expected the external ID to be kept, got %+v (%v)", account, err)
+	}
+	accounts.UpdateAccount(1, models.AccountUpdate{ExternalID: &moved})
+	if _, err := accounts.GetAccountByExternalID("ERP-1"); err == nil || err.Error() != "account not found" {
+		t.Errorf("Expected the old external ID to be released, got %v", err)
+	}
+	if _, err := accounts.UpdateAccount(2, models.AccountUpdate{ExternalID: &taken}); err != nil {
+		t.Errorf("Expected the released external ID to be reusable, got %v", err)
+	}
+}
+
+func TestAccountRepository_CreationToken(t *testing.T) {
+	accounts, _ := newRepositories()
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(5), Currency: "USD", CreationToken: "provision-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero, CreationToken: "provision-1"}); err == nil || err.Error() != "creation token already used" {
+		t.Errorf("Expected a reused creation token error, got %v", err)
+	}
+	account, err := accounts.GetAccount(1)
+	if err != nil || account.CreationToken != "provision-1" || !account.InitialBalance.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("Expected the token and initial balance to be kept, got %+v (%v)", account, err)
+	}
+}
+
+func TestAccountRepository_UpdatedAt(t *testing.T) {
+	store := NewStore()
+	accounts, transactions, holds := NewAccountRepository(store), NewTransactionRepository(store), NewHoldRepository(store)
+	minute := 0
+	store.now = func() time.Time { return time.Date(2024, 3, 1, 12, minute, 0, 0, time.UTC) }
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "USD"})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero, Currency: "USD"})
+
+	for _, tc := range []struct {
+		name   string
+		change func() error
+	}{
+		{"freeze", func() error { _, err := accounts.SetAccountStatus(1, models.AccountFrozen); return err }},
+		{"unfreeze", func() error { _, err := accounts.SetAccountStatus(1, models.AccountActive); return err }},
+		{"transfer", func() error {
+			_, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+			return err
+		}},
+		{"hold", func() error {
+			_, err := holds.CreateHold(context.Background(), models.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1), ExpiresAt: store.now().Add(time.Hour)})
+			return err
+		}},
+		{"wallet", func() error {
+			_, err := NewWalletRepository(store).OpenWallet(context.Background(), 1, "EUR")
+			return err
+		}},
+	} {
+		minute++
+		if err := tc.change(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if account, _ := accounts.GetAccount(1); !account.UpdatedAt.Equal(store.now()) {
+			t.Errorf("%s: expected the account to be updated at %v, got %v", tc.name, store.now(), account.UpdatedAt)
+		}
+	}
+}
+
+func TestTransactionRepository(t *testing.T) {
+	accounts, transactions := newRepositories()
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+
+	testCases := []struct {
+		name        string
+		source      int64
+		destination int64
+		amount      int64
+		wantErr     string
+	}{
+		{"Unknown source", 9, 2, 10, "source account not found"},
+		{"Unknown destination", 1, 9, 10, "destination account not found"},
+		{"Insufficient balance", 1, 2, 101, "insufficient balance"},
+		{"Success", 1, 2, 40, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tx, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: tc.source, DestinationAccountID: tc.destination, Amount: decimal.NewFromInt(tc.amount)})
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("Expected %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tx.ID != 1 || tx.SourceSequence != 1 || tx.DestinationSequence != 1 {
+				t.Errorf("Unexpected transaction: %+v", tx)
+			}
+		})
+	}
+
+	source, _ := accounts.GetAccount(1)
+	destination, _ := accounts.GetAccount(2)
+	if !source.Balance.Equal(decimal.NewFromInt(60)) || !destination.Balance.Equal(decimal.NewFromInt(40)) {
+		t.Errorf("Unexpected balances after transfer: %s / %s", source.Balance, destination.Balance)
+	}
+
+	// Frozen accounts can neither send nor receive
+	if _, err := accounts.SetAccountStatus(9, models.AccountFrozen); err == nil || err.Error() != "account not found" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	accounts.SetAccountStatus(2, models.AccountFrozen)
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)}); err == nil || err.Error() != "destination account frozen" {
+		t.Errorf("Expected destination frozen error, got %v", err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(1)}); err == nil || err.Error() != "source account frozen" {
+		t.Errorf("Expected source frozen error, got %v", err)
+	}
+	if account, _ := accounts.SetAccountStatus(2, models.AccountActive); account.Status != models.AccountActive {
+		t.Errorf("Expected active status, got %+v", account)
+	}
+
+	// A duplicate reference is found after the balance check, as in PostgreSQL
+	reference := models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), Reference: "INV-1"}
+	if _, err := transactions.CreateTransaction(reference); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reference.Amount = decimal.NewFromInt(1000)
+	if _, err := transactions.CreateTransaction(reference); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("Expected insufficient balance for an overdrawing duplicate, got %v", err)
+	}
+	reference.Amount = decimal.NewFromInt(10)
+	if _, err := transactions.CreateTransaction(reference); err == nil || err.Error() != "duplicate reference" {
+		t.Errorf("Expected duplicate reference, got %v", err)
+	}
+}
+
+func TestTransactionRepository_Currencies(t *testing.T) {
+	accounts, transactions := newRepositories()
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "USD"})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero, Currency: "EUR"})
+	accounts.CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.Zero, Currency: "USD"})
+
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)}); err == nil || err.Error() != "currency mismatch" {
+		t.Errorf("Expected currency mismatch error, got %v", err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.RequireFromString("0.001")}); err == nil || err.Error() != "amount exceeds currency precision" {
+		t.Errorf("Expected precision error, got %v", err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.RequireFromString("0.01")}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if account, _ := accounts.GetAccount(3); account.Currency != "USD" || !account.Balance.Equal(decimal.RequireFromString("0.01")) {
+		t.Errorf("Unexpected account %+v", account)
+	}
+
+	// Converted transfers debit the amount and credit the converted amount
+	converted, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10),
+		DestinationAmount: decimal.RequireFromString("9.2"), FXRate: decimal.RequireFromString("0.92"), FXRateTimestamp: time.Now()})
+	if err != nil || !converted.DestinationAmount.Equal(decimal.RequireFromString("9.2")) || !converted.Converted() {
+		t.Fatalf("Unexpected converted transaction %+v (%v)", converted, err)
+	}
+	if account, _ := accounts.GetAccount(2); !account.Balance.Equal(decimal.RequireFromString("9.2")) {
+		t.Errorf("Expected the converted amount credited, got %s", account.Balance)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1),
+		DestinationAmount: decimal.RequireFromString("0.921"), FXRate: decimal.RequireFromString("0.921")}); err == nil || err.Error() != "amount exceeds currency precision" {
+		t.Errorf("Expected precision error for the converted amount, got %v", err)
+	}
+
+	// A return restores the source to the amount it was debited
+	returned, err := NewSettlementRepository(accounts.store).ReturnTransaction(context.Background(), converted.ID, time.Time{})
+	if err != nil || !returned.Amount.Equal(decimal.RequireFromString("9.2")) || !returned.DestinationAmount.Equal(decimal.NewFromInt(10)) {
+		t.Fatalf("Unexpected return %+v (%v)", returned, err)
+	}
+	if account, _ := accounts.GetAccount(1); !account.Balance.Equal(decimal.RequireFromString("99.99")) {
+		t.Errorf("Expected the source restored to 99.99, got %s", account.Balance)
+	}
+}
+
+func TestTransactionRepository_ConcurrentTransfers(t *testing.T) {
+	accounts, transactions := newRepositories()
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(50)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+		}()
+	}
+	wg.Wait()
+
+	// Only 50 of the 100 transfers can succeed and no balance may go negative
+	source, _ := accounts.GetAccount(1)
+	destination, _ := accounts.GetAccount(2)
+	if !source.Balance.IsZero() || !destination.Balance.Equal(decimal.NewFromInt(50)) {
+		t.Errorf("Unexpected balances: %s / %s", source.Balance, destination.Balance)
+	}
+	if source.Sequence != 50 {
+		t.Errorf("Expected 50 sequence numbers assigned, got %d", source.Sequence)
+	}
+}
+
+func TestTransactionRepository_ListTransactions(t *testing.T) {
+	accounts, transactions := newRepositories()
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.NewFromInt(100)})
+
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 3, Amount: decimal.NewFromInt(2)})
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 3, DestinationAccountID: 1, Amount: decimal.NewFromInt(3)})
+
+	list, err := transactions.ListTransactions(1, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 1 {
+		t.Errorf("Expected transactions 3 and 1 newest first, got %+v", list)
+	}
+
+	list, _ = transactions.ListTransactions(1, 1)
+	if len(list) != 1 || list[0].ID != 3 {
+		t.Errorf("Expected limit to keep only the newest transaction, got %+v", list)
+	}
+}
+
+func TestAccountRepository_Overdraft(t *testing.T) {
+	accounts, transactions := newRepositories()
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(10)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(11)}); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("Expected insufficient balance without an overdraft limit, got %v", err)
+	}
+
+	account, err := accounts.SetOverdraftLimit(1, decimal.NewFromInt(50))
+	if err != nil || !account.OverdraftLimit.Equal(decimal.NewFromInt(50)) || account.Version != 2 {
+		t.Fatalf("Unexpected account %+v (%v)", account, err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(60)}); err != nil {
+		t.Fatalf("Expected the transfer to use the overdraft, got %v", err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("0.00001")}); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("Expected insufficient balance beyond the overdraft limit, got %v", err)
+	}
+	if account, _ := accounts.GetAccount(1); !account.Balance.Equal(decimal.NewFromInt(-50)) || !account.Available().IsZero() {
+		t.Errorf("Expected balance -50 with nothing available, got %+v", account)
+	}
+
+	if _, err := accounts.SetOverdraftLimit(1, decimal.NewFromInt(49)); err == nil || err.Error() != "balance below overdraft limit" {
+		t.Errorf("Expected a limit below the overdrawn balance to be refused, got %v", err)
+	}
+	if account, _ := accounts.SetOverdraftLimit(1, decimal.NewFromInt(50)); account.Version != 2 {
+		t.Errorf("Expected an unchanged limit to keep the version, got %d", account.Version)
+	}
+	if _, err := accounts.SetOverdraftLimit(9, decimal.Zero); err == nil || err.Error() != "account not found" {
+		t.Errorf("Expected account not found, got %v", err)
+	}
+}
+
+func TestTransactionRepository_ListChanges(t *testing.T) {
+	accounts, transactions := newRepositories()
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.NewFromInt(100)})
+
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 3, Amount: decimal.NewFromInt(2)})
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 3, DestinationAccountID: 1, Amount: decimal.NewFromInt(3)})
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 3, Amount: decimal.NewFromInt(4)})
+
+	// Account 1 has sequence numbers 1 (transaction 1), 2 (transaction 3) and 3 (transaction 4)
+	list, err := transactions.ListChanges(1, 1, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 4 {
+		t.Errorf("Expected transactions 3 and 4 in sequence order, got %+v", list)
+	}
+
+	list, _ = transactions.ListChanges(1, 0, 1)
+	if len(list) != 1 || list[0].ID != 1 {
+		t.Errorf("Expected limit to keep only the first change, got %+v", list)
+	}
+	if list, _ = transactions.ListChanges(1, 3, 10); len(list) != 0 {
+		t.Errorf("Expected no changes after the latest sequence, got %+v", list)
+	}
+}
+
+func TestTransactionRepository_SearchTransactionsOrder(t *testing.T) {
+	store := NewStore()
+	accounts, transactions := NewAccountRepository(store), NewTransactionRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	at := func(minute int) time.Time { return time.Date(2024, 3, 1, 12, minute, 0, 0, time.UTC) }
+	// IDs do not follow creation times, and transactions 1 and 3 were created at the same time
+	for _, minute := range []int{10, 5, 10} {
+		store.now = func() time.Time { return at(minute) }
+		transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+	}
+	ids := func(filter models.TransactionFilter, limit int) []int64 {
+		list, _ := transactions.SearchTransactions(filter, limit)
+		ids := []int64{}
+		for _, t := range list {
+			ids = append(ids, t.ID)
+		}
+		return ids
+	}
+
+	page := ids(models.TransactionFilter{AccountID: 2}, 2)
+	if fmt.Sprint(page) != "[3 1]" {
+		t.Errorf("Expected the newest first by creation time and ID, got %v", page)
+	}
+	before := models.TransactionPosition{CreatedAt: at(10), ID: 1}
+	if next := ids(models.TransactionFilter{AccountID: 2, After: before}, 2); fmt.Sprint(next) != "[2]" {
+		t.Errorf("Expected the page after transaction 1 to hold transaction 2, got %v", next)
+	}
+	oldest := models.TransactionFilter{AccountID: 2, Order: models.TransactionsOldestFirst}
+	if page := ids(oldest, 2); fmt.Sprint(page) != "[2 1]" {
+		t.Errorf("Expected the oldest first, got %v", page)
+	}
+	oldest.After = before
+	if next := ids(oldest, 2); fmt.Sprint(next) != "[3]" {
+		t.Errorf("Expected the oldest-first page after transaction 1 to hold transaction 3, got %v", next)
+	}
+	// A position without a creation time continues below its ID
+	if legacy := ids(models.TransactionFilter{AccountID: 2, After: models.TransactionPosition{ID: 3}}, 10); fmt.Sprint(legacy) != "[1 2]" {
+		t.Errorf("Expected the transactions below ID 3, got %v", legacy)
+	}
+}
+
+func TestTransactionRepository_BalanceAsOf(t *testing.T) {
+	store := NewStore()
+	accounts, transactions := NewAccountRepository(store), NewTransactionRepository(store)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+
+	store.now = func() time.Time { return day(1) }
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	store.now = func() time.Time { return day(5) }
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)})
+	// Recorded on day 10 but effective on day 3
+	store.now = func() time.Time { return day(10) }
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(20), EffectiveAt: day(3)})
+
+	for _, tc := range []struct {
+		name                    string
+		recordedAt, effectiveAt time.Time
+		expected                int64
+	}{
+		{"now", day(11), day(11), 50},
+		{"before the backdated transfer was recorded", day(6), day(11), 70},
+		{"effective on day 4 as known now", day(11), day(4), 80},
+		{"effective on day 4 as known on day 6", day(6), day(4), 100},
+		{"initial balance", day(2), day(2), 100},
+	} {
+		balance, err := transactions.BalanceAsOf(1, tc.recordedAt, tc.effectiveAt)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !balance.Equal(decimal.NewFromInt(tc.expected)) {
+			t.Errorf("%s: expected %d, got %s", tc.name, tc.expected, balance)
+		}
+	}
+
+	if _, err := transactions.BalanceAsOf(1, day(1).Add(-time.Hour), day(11)); err == nil {
+		t.Error("Expected an error before the account was created")
+	}
+}
+
+func TestRecurringRepository_Advance(t *testing.T) {
+	repo := NewRecurringRepository(NewStore())
+	ctx := context.Background()
+	due := time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)
+	rule, _ := repo.CreateRecurringTransfer(ctx, models.RecurringTransfer{
+		SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5), Schedule: "@daily",
+		Status: models.RecurringActive, NextRunAt: due,
+	})
+
+	if list, _ := repo.DueRecurringTransfers(ctx, due.Add(-time.Second), 10); len(list) != 0 {
+		t.Errorf("Expected no rule due before its next run, got %+v", list)
+	}
+	if list, _ := repo.DueRecurringTransfers(ctx, due, 10); len(list) != 1 || list[0].ID != rule.ID {
+		t.Errorf("Expected the rule to be due, got %+v", list)
+	}
+
+	// Only the first of two pollers claims the run
+	next := due.AddDate(0, 0, 1)
+	if claimed, err := repo.AdvanceRecurringTransfer(ctx, rule.ID, due, next); err != nil || !claimed {
+		t.Fatalf("Expected the first claim to succeed, got %v (%v)", claimed, err)
+	}
+	if claimed, _ := repo.AdvanceRecurringTransfer(ctx, rule.ID, due, next); claimed {
+		t.Error("Expected the second claim to fail")
+	}
+
+	repo.SetRecurringTransferStatus(ctx, rule.ID, models.RecurringPaused, next)
+	if claimed, _ := repo.AdvanceRecurringTransfer(ctx, rule.ID, next, next.AddDate(0, 0, 1)); claimed {
+		t.Error("Expected a paused rule not to be claimed")
+	}
+}
+
+func TestSettlementRepository_SettleAndReturn(t *testing.T) {
+	store := NewStore()
+	accounts, transactions, settlements := NewAccountRepository(store), NewTransactionRepository(store), NewSettlementRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	first, _ := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)})
+	second, _ := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(20)})
+	ctx := context.Background()
+
+	if err := settlements.SettleTransaction(ctx, first.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, _ := settlements.GetTransaction(ctx, first.ID); got.SettlementStatus != models.SettlementSettled || got.Status != models.TransactionCompleted {
+		t.Errorf("Expected settled, got %s", got.SettlementStatus)
+	}
+
+	returned, err := settlements.ReturnTransaction(ctx, second.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if returned.ReturnOf != second.ID || returned.SourceAccountID != 2 || returned.TransferType != models.ReturnTransferType {
+		t.Errorf("Unexpected return transaction %+v", returned)
+	}
+	if got, _ := settlements.GetTransaction(ctx, second.ID); got.Status != models.TransactionReversed || returned.Status != models.TransactionCompleted {
+		t.Errorf("Expected the original reversed by a completed return, got %s and %s", got.Status, returned.Status)
+	}
+	if source, _ := accounts.GetAccount(1); !source.Balance.Equal(decimal.NewFromInt(70)) {
+		t.Errorf("Expected returned funds back on the source, balance %s", source.Balance)
+	}
+
+	// Returns are terminal
+	if _, err := settlements.ReturnTransaction(ctx, second.ID, time.Time{}); err == nil || err.Error() != "transaction already returned" {
+		t.Errorf("Expected already returned error, got %v", err)
+	}
+	if err := settlements.SettleTransaction(ctx, second.ID); err == nil || err.Error() != "transaction already returned" {
+		t.Errorf("Expected already returned error, got %v", err)
+	}
+	if _, err := settlements.GetTransaction(ctx, 99); err == nil || err.Error() != "transaction not found" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestSettlementRepository_Snapshot(t *testing.T) {
+	store := NewStore()
+	accounts, transactions, settlements := NewAccountRepository(store), NewTransactionRepository(store), NewSettlementRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	date := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), ValueDate: date})
+	ctx := context.Background()
+
+	err := settlements.Snapshot(ctx, func(snapshot database.SettlementSnapshot) error {
+		// A transfer booked while the snapshot is open is not part of it
+		if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5), ValueDate: date}); err != nil {
+			t.Fatalf("Expected the snapshot not to block transfers, got %v", err)
+		}
+		for _, accountID := range []int64{1, 2} {
+			if found, _ := snapshot.TransactionsByValueDate(ctx, date, []int64{accountID}); len(found) != 1 {
+				t.Errorf("Account %d: expected 1 transaction in the snapshot, got %d", accountID, len(found))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if found, _ := settlements.TransactionsByValueDate(ctx, date, []int64{1}); len(found) != 2 {
+		t.Errorf("Expected both transactions after the snapshot, got %d", len(found))
+	}
+}
+
+func TestSettlementRepository_ListAfter(t *testing.T) {
+	settlements := NewSettlementRepository(NewStore())
+	ctx := context.Background()
+	first, second := time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	for _, f := range []models.SettlementFile{
+		{PartnerID: "beta", BusinessDate: second}, {PartnerID: "acme", BusinessDate: first}, {PartnerID: "acme", BusinessDate: second},
+	} {
+		settlements.SaveSettlementFile(ctx, f)
+	}
+
+	page, _ := settlements.ListSettlementFiles(ctx, time.Time{}, "", models.SettlementFileKey{}, 2)
+	if len(page) != 2 || page[0].PartnerID != "acme" || page[1].PartnerID != "beta" || !page[1].BusinessDate.Equal(second) {
+		t.Fatalf("Unexpected first page %+v", page)
+	}
+	page, _ = settlements.ListSettlementFiles(ctx, time.Time{}, "", page[1].Key(), 2)
+	if len(page) != 1 || page[0].PartnerID != "acme" || !page[0].BusinessDate.Equal(first) {
+		t.Errorf("Unexpected second page %+v", page)
+	}
+}
+
+func TestHoldRepository(t *testing.T) {
+	store := NewStore()
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	accounts, transactions, holds := NewAccountRepository(store), NewTransactionRepository(store), NewHoldRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	ctx := context.Background()
+	hold := func(amount int64, expiresAt time.Time) (*models.Hold, error) {
+		return holds.CreateHold(ctx, models.Hold{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(amount), ExpiresAt: expiresAt})
+	}
+
+	first, err := hold(60, now.Add(time.Hour))
+	if err != nil || first.Status != models.HoldActive {
+		t.Fatalf("Expected an active hold, got %+v (%v)", first, err)
+	}
+	if account, _ := accounts.GetAccount(1); !account.Balance.Equal(decimal.NewFromInt(100)) || !account.Available().Equal(decimal.NewFromInt(40)) {
+		t.Errorf("Expected the hold to reduce only the available balance, got balance %s available %s", account.Balance, account.Available())
+	}
+	if _, err := hold(50, now.Add(time.Hour)); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("Expected a second hold beyond the available balance to fail, got %v", err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(50)}); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("Expected a transfer of held funds to fail, got %v", err)
+	}
+
+	// A partial capture books the transfer and frees the rest of the hold
+	captured, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(45), HoldID: first.ID})
+	if err != nil {
+		t.Fatalf("Unexpected capture error: %v", err)
+	}
+	if got, _ := holds.GetHold(ctx, first.ID); got.Status != models.HoldCaptured || got.TransactionID != captured.ID {
+		t.Errorf("Expected a captured hold, got %+v", got)
+	}
+	if account, _ := accounts.GetAccount(1); !account.Balance.Equal(decimal.NewFromInt(55)) || !account.Held.IsZero() {
+		t.Errorf("Expected balance 55 with nothing held, got %s held %s", account.Balance, account.Held)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1), HoldID: first.ID}); err == nil || err.Error() != "hold not active" {
+		t.Errorf("Expected a second capture to fail, got %v", err)
+	}
+
+	second, _ := hold(20, now.Add(time.Hour))
+	for _, tc := range []struct {
+		transfer models.Transfer
+		want     string
+	}{
+		{models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(21), HoldID: second.ID}, "capture exceeds hold amount"},
+		{models.Transfer{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(1), HoldID: second.ID}, "hold 2 is not for this transfer's accounts"},
+		{models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1), HoldID: 99}, "hold not found"},
+	} {
+		if _, err := transactions.CreateTransaction(tc.transfer); err == nil || err.Error() != tc.want {
+			t.Errorf("%+v: expected %q, got %v", tc.transfer, tc.want, err)
+		}
+	}
+	if released, err := holds.ReleaseHold(ctx, second.ID); err != nil || released.Status != models.HoldReleased {
+		t.Errorf("Expected a released hold, got %+v (%v)", released, err)
+	}
+	if _, err := holds.ReleaseHold(ctx, second.ID); err == nil || err.Error() != "hold not active" {
+		t.Errorf("Expected releasing twice to fail, got %v", err)
+	}
+
+	// Past its expiry a hold cannot be captured, and expiring it frees the funds
+	third, _ := hold(30, now.Add(time.Minute))
+	now = now.Add(time.Hour)
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30), HoldID: third.ID}); err == nil || err.Error() != "hold expired" {
+		t.Errorf("Expected an expired hold not to be captured, got %v", err)
+	}
+	expired, err := holds.ExpireHolds(ctx, now, 10)
+	if err != nil || len(expired) != 1 || expired[0].ID != third.ID || expired[0].Status != models.HoldExpired {
+		t.Errorf("Expected the third hold to expire, got %+v (%v)", expired, err)
+	}
+	if account, _ := accounts.GetAccount(1); !account.Held.IsZero() {
+		t.Errorf("Expected nothing held after expiry, got %s", account.Held)
+	}
+	if list, _ := holds.ListHolds(ctx, 1, 0, 2); len(list) != 2 || list[0].ID != third.ID {
+		t.Errorf("Expected the two newest holds, got %+v", list)
+	}
+}
+
+func TestAuditRepository(t *testing.T) {
+	store := NewStore()
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	audit := NewAuditRepository(store)
+	ctx := context.Background()
+
+	for _, event := range []models.AuditEvent{
+		{Actor: models.AuditActorAdmin, Action: models.AuditAccountFrozen, AccountIDs: []int64{1}},
+		{Actor: "api_key:anonymous", Action: models.AuditTransactionCreated, AccountIDs: []int64{1, 2}, TransactionID: 7},
+		{Actor: "api_key:anonymous", Action: models.AuditTransactionCreated, AccountIDs: []int64{2, 3}, TransactionID: 8},
+	} {
+		stored, err := audit.AppendAuditEvent(ctx, event)
+		if err != nil || !stored.OccurredAt.Equal(now) || string(stored.Before) != "null" {
+			t.Fatalf("Unexpected append %+v (%v)", stored, err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	for _, tc := range []struct {
+		filter models.AuditFilter
+		limit  int
+		want   []int64
+	}{
+		{models.AuditFilter{}, 10, []int64{3, 2, 1}},
+		{models.AuditFilter{}, 2, []int64{3, 2}},
+		{models.AuditFilter{BeforeID: 3}, 10, []int64{2, 1}},
+		{models.AuditFilter{AccountID: 1}, 10, []int64{2, 1}},
+		{models.AuditFilter{Actor: models.AuditActorAdmin}, 10, []int64{1}},
+		{models.AuditFilter{Action: models.AuditTransactionCreated, AccountID: 3}, 10, []int64{3}},
+		{models.AuditFilter{From: now.Add(-2 * time.Hour), To: now.Add(-time.Hour)}, 10, []int64{2}},
+	} {
+		events, err := audit.ListAuditEvents(ctx, tc.filter, tc.limit)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		ids := []int64{}
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tc.want) {
+			t.Errorf("%+v: expected events %v, got %v", tc.filter, tc.want, ids)
+		}
+	}
+}
+
+func TestReconciliationRepository(t *testing.T) {
+	store := NewStore()
+	accounts, transactions := NewAccountRepository(store), NewTransactionRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(5)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.Zero})
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(30)})
+	transactions.CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 3, Amount: decimal.NewFromInt(10)})
+
+	repo := store.Reconciliation()
+	checked, discrepancies, err := repo.ReconcileBalances(context.Background())
+	if err != nil || checked != 3 || len(discrepancies) != 0 {
+		t.Fatalf("Expected a balanced ledger, got %d %+v (%v)", checked, discrepancies, err)
+	}
+
+	// A balance changed outside a transfer
+	store.accounts[2].Balance = decimal.NewFromInt(26)
+	_, discrepancies, _ = repo.ReconcileBalances(context.Background())
+	if len(discrepancies) != 1 {
+		t.Fatalf("Expected one discrepancy, got %+v", discrepancies)
+	}
+	d := discrepancies[0]
+	if d.AccountID != 2 || !d.ComputedBalance.Equal(decimal.NewFromInt(25)) || !d.Difference().Equal(decimal.NewFromInt(1)) || d.Transactions != 2 {
+		t.Errorf("Unexpected discrepancy %+v", d)
+	}
+}
+
+func TestAdjustmentRepository(t *testing.T) {
+	store := NewStore()
+	accounts := NewAccountRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	before := time.Now()
+	repo := store.Adjustments()
+	ctx := context.Background()
+
+	credit, err := repo.CreateAdjustment(ctx, models.Adjustment{AccountID: 1, Amount: decimal.NewFromInt(20), Reason: "Missed refund", Actor: "jdoe"})
+	if err != nil || credit.ID != 1 || credit.BalanceAfter.String() != "120" {
+		t.Fatalf("Expected adjustment 1 leaving 120, got %+v, %v", credit, err)
+	}
+	if _, err := repo.CreateAdjustment(ctx, models.Adjustment{AccountID: 1, Amount: decimal.NewFromInt(-121), Reason: "Too much", Actor: "jdoe"}); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("Expected insufficient balance, got %v", err)
+	}
+	if _, err := repo.CreateAdjustment(ctx, models.Adjustment{AccountID: 9, Amount: decimal.NewFromInt(1), Reason: "x", Actor: "jdoe"}); err == nil || err.Error() != "account not found" {
+		t.Errorf("Expected account not found, got %v", err)
+	}
+	if _, err := repo.CreateAdjustment(ctx, models.Adjustment{AccountID: 1, Amount: decimal.NewFromInt(-5), Reason: "Duplicate fee", Actor: "jdoe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	account, _ := accounts.GetAccount(1)
+	if account.Balance.String() != "115" || account.Version != 3 || account.Sequence != 0 {
+		t.Errorf("Expected balance 115 at version 3 without a sequence number, got %+v", account)
+	}
+	list, err := repo.ListAdjustments(ctx, 1, time.Time{}, time.Time{}, 10)
+	if err != nil || len(list) != 2 || list[1].BalanceAfter.String() != "115" {
+		t.Errorf("Expected both adjustments, got %+v, %v", list, err)
+	}
+	snapshots, _ := store.BalanceHistory().ListBalanceSnapshots(ctx, models.BalanceHistoryFilter{AccountID: 1}, 10)
+	if len(snapshots) != 3 || snapshots[2].Source != models.BalanceSnapshotAdjustment || snapshots[2].Balance.String() != "115" {
+		t.Errorf("Expected an adjustment snapshot, got %+v", snapshots)
+	}
+
+	// Reconciliation counts the adjustments; balances before them exclude them
+	if _, discrepancies, _ := store.Reconciliation().ReconcileBalances(ctx); len(discrepancies) != 0 {
+		t.Errorf("Expected a balanced ledger, got %+v", discrepancies)
+	}
+	balance, err := NewTransactionRepository(store).BalanceAsOf(1, before, before)
+	if err != nil || balance.String() != "100" {
+		t.Errorf("Expected 100 before the adjustments, got %s, %v", balance, err)
+	}
+}
+
+func TestWalletRepository(t *testing.T) {
+	store := NewStore()
+	accounts := NewAccountRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100), Currency: "USD"})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.NewFromInt(100), Currency: "EUR"})
+	accounts.CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.NewFromInt(100)})
+	before := time.Now()
+	repo := store.Wallets()
+	transactions := NewTransactionRepository(store)
+	ctx := context.Background()
+
+	if _, err := repo.OpenWallet(ctx, 1, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		accountID int64
+		currency  string
+		want      string
+	}{
+		{1, "EUR", "wallet already exists"},
+		{1, "USD", "wallet already exists"},
+		{3, "EUR", "account has no currency"},
+		{9, "EUR", "account not found"},
+	} {
+		if _, err := repo.OpenWallet(ctx, tc.accountID, tc.currency); err == nil || err.Error() != tc.want {
+			t.Errorf("OpenWallet(%d, %s): expected %s, got %v", tc.accountID, tc.currency, tc.want, err)
+		}
+	}
+
+	// Account 2's EUR is its primary balance; account 1's EUR is its wallet
+	tx, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(30), Currency: "EUR"})
+	if err != nil || tx.SourceWallet != "" || tx.DestinationWallet != "EUR" {
+		t.Fatalf("Expected a transfer into account 1's EUR wallet, got %+v, %v", tx, err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(31), Currency: "EUR"}); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("Expected the wallet not to be overdrawn, got %v", err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1), Currency: "GBP"}); err == nil || err.Error() != "source wallet not found" {
+		t.Errorf("Expected source wallet not found, got %v", err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.NewFromInt(1), Currency: "GBP"}); err == nil || err.Error() != "source wallet not found" {
+		t.Errorf("Expected source wallet not found, got %v", err)
+	}
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), Currency: "EUR"}); err != nil {
+		t.Fatal(err)
+	}
+
+	wallets, err := repo.ListWallets(ctx, 1)
+	if err != nil || len(wallets) != 1 || wallets[0].Balance.String() != "20" {
+		t.Errorf("Expected a EUR wallet of 20, got %+v, %v", wallets, err)
+	}
+	account, _ := accounts.GetAccount(1)
+	if account.Balance.String() != "100" || account.Sequence != 2 {
+		t.Errorf("Expected the primary balance untouched at sequence 2, got %+v", account)
+	}
+	snapshots, _ := store.BalanceHistory().ListBalanceSnapshots(ctx, models.BalanceHistoryFilter{AccountID: 1}, 10)
+	if len(snapshots) != 1 {
+		t.Errorf("Expected no snapshots of wallet legs, got %+v", snapshots)
+	}
+
+	// Wallet legs are left out of the primary balance's reconciliation and history
+	if _, discrepancies, _ := store.Reconciliation().ReconcileBalances(ctx); len(discrepancies) != 0 {
+		t.Errorf("Expected a balanced ledger, got %+v", discrepancies)
+	}
+	balance, err := transactions.BalanceAsOf(1, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if err != nil || balance.String() != "100" {
+		t.Errorf("Expected 100 excluding the wallet, got %s, %v", balance, err)
+	}
+	balance, err = transactions.BalanceAsOf(2, before, before)
+	if err != nil || balance.String() != "100" {
+		t.Errorf("Expected 100 before the transfers, got %s, %v", balance, err)
+	}
+}
+
+func TestAPIKeyRepository(t *testing.T) {
+	store := NewStore()
+	repo := store.APIKeys()
+	ctx := context.Background()
+
+	key, err := repo.CreateAPIKey(ctx, models.APIKey{ID: "ak_1", Secret: "s3cret", Description: "batch"})
+	if err != nil || key.CreatedAt.IsZero() || key.RevokedAt != nil {
+		t.Fatalf("Expected an active key, got %+v, %v", key, err)
+	}
+	repo.CreateAPIKey(ctx, models.APIKey{ID: "ak_2", Secret: "other"})
+
+	revoked, err := repo.RevokeAPIKey(ctx, "ak_1")
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("Expected a revoked key, got %+v, %v", revoked, err)
+	}
+	// Revoking again keeps the original revocation time
+	again, _ := repo.RevokeAPIKey(ctx, "ak_1")
+	if !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Errorf("Expected revocation at %v, got %v", revoked.RevokedAt, again.RevokedAt)
+	}
+	if got, err := repo.GetAPIKey(ctx, "ak_1"); err != nil || got.Secret != "s3cret" || got.RevokedAt == nil {
+		t.Errorf("Expected the revoked key with its secret, got %+v, %v", got, err)
+	}
+	list, err := repo.ListAPIKeys(ctx)
+	if err != nil || len(list) != 2 || list[0].ID != "ak_1" || list[1].RevokedAt != nil {
+		t.Errorf("Expected both keys oldest first, got %+v, %v", list, err)
+	}
+	for name, call := range map[string]func() error{
+		"get":    func() error { _, err := repo.GetAPIKey(ctx, "ak_9"); return err },
+		"revoke": func() error { _, err := repo.RevokeAPIKey(ctx, "ak_9"); return err },
+	} {
+		if err := call(); err == nil || err.Error() != "API key not found" {
+			t.Errorf("%s: expected API key not found, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// Allowlist is the networks clients may connect from
+type Allowlist struct {
+	// Networks restrict every request; empty allows any address
+	Networks []netip.Prefix
+
+	// Keys restrict the requests signed with an API key, by key ID, to these networks as well; they
+	// apply where the signature is verified, on every route (see handlers.Handler.Identified), as a
+	// key a request merely names proves nothing about its sender. Unsigned requests are not
+	// restricted by them, so they only cover keys that sign (see handlers.Handler.CheckAllowlist)
+	Keys map[string][]netip.Prefix
+}
+
+// LoadAllowlist reads the IP allowlist from the environment
+// Variables:
+//   - IP_ALLOWLIST (unset): Comma separated CIDR ranges or addresses every request must come from
+//   - IP_ALLOWLIST_KEYS (unset): Comma separated key_id=ranges pairs restricting the requests
+//     signed with an API key, with the key's ranges separated by |, e.g. ak_0a1b=10.1.0.0/16|10.2.0.0/16
+//
+// Returns an error for malformed values
+func LoadAllowlist() (Allowlist, error) {
+	var allowlist Allowlist
+	networks, err := parseNetworks(os.Getenv("IP_ALLOWLIST"), ",")
+	if err != nil {
+		return Allowlist{}, fmt.Errorf("invalid IP_ALLOWLIST: %w", err)
+	}
+	allowlist.Networks = networks
+
+	for _, pair := range strings.Split(os.Getenv("IP_ALLOWLIST_KEYS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		keyID, value, ok := strings.Cut(pair, "=")
+		keyID = strings.TrimSpace(keyID)
+		networks, err := parseNetworks(value, "|")
+		if !ok || keyID == "" || len(networks) == 0 || err != nil {
+			return Allowlist{}, fmt.Errorf("invalid IP_ALLOWLIST_KEYS entry %q (expected key_id=cidr|cidr)", pair)
+		}
+		if allowlist.Keys == nil {
+			allowlist.Keys = make(map[string][]netip.Prefix)
+		}
+		allowlist.Keys[keyID] = append(allowlist.Keys[keyID], networks...)
+	}
+	return allowlist, nil
+}
+
+// parseNetworks reads a list of CIDR ranges or single addresses separated by sep
+func parseNetworks(value, sep string) ([]netip.Prefix, error) {
+	var networks []netip.Prefix
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(item); err == nil {
+			networks = append(networks, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		network, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a CIDR range or address", item)
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, nil
+}
+
+// Enabled reports whether the allowlist restricts any request
+func (a Allowlist) Enabled() bool {
+	return len(a.Networks) > 0 || len(a.Keys) > 0
+}
+
+// Allows reports whether a client at addr may make a request with the API key keyID: addr must be
+// in the global networks, if any, and in the key's networks, if it has some
+func (a Allowlist) Allows(addr netip.Addr, keyID string) bool {
+	addr = addr.Unmap().WithZone("")
+	contains := func(networks []netip.Prefix) bool {
+		for _, network := range networks {
+			if network.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(a.Networks) > 0 && !contains(a.Networks) {
+		return false
+	}
+	if networks, ok := a.Keys[keyID]; ok && !contains(networks) {
+		return false
+	}
+	return true
+}
+
+// AllowsRequest reports whether r may be made, signed with the API key keyID ("" for none): its
+// client must be allowed (see Allows), unless r came through a Unix socket or the allowlist is
+// not enabled
+// The client is the connection's remote address: forwarded headers are not trusted, so the
+// allowlist applies to the proxy's address behind a proxy. Clients of Unix sockets, which have no
+// address and are protected by the socket's file permissions, are not restricted; any other
+// request without a client address is refused
+func (a Allowlist) AllowsRequest(r *http.Request, keyID string) bool {
+	if !a.Enabled() {
+		return true
+	}
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && local.Network() == "unix" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && a.Allows(addr, keyID)
+}
+
+// IPAllowlist refuses requests from clients outside the allowlist's networks with 403 (see
+// AllowsRequest); the networks of API keys are left to where signatures are verified
+func IPAllowlist(allowlist Allowlist) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowlist.AllowsRequest(r, "") {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
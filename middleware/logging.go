@@ -0,0 +1,253 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type accountsKey struct{}
+
+// loggedAccounts collects the account IDs a request touched, for its access log line
+type loggedAccounts struct {
+	mu  sync.Mutex
+	ids []int64
+}
+
+// AccessLogOptions controls where the access log is written and which requests it leaves out
+type AccessLogOptions struct {
+	// Logger writes the structured lines; nil writes them through the process default logger
+	Logger *slog.Logger
+
+	// Combined, when set, receives the lines in the Combined Log Format of web servers instead of
+	// structured, for tooling built around those logs
+	Combined io.Writer
+
+	// Exclude lists the names of routes whose requests are not logged, e.g. health checks that
+	// probes send every few seconds
+	Exclude []string
+}
+
+// AccessLog logs one structured line per request through the default logger (see NewAccessLog)
+func AccessLog(next http.Handler) http.Handler {
+	return NewAccessLog(AccessLogOptions{})(next)
+}
+
+// NewAccessLog returns middleware logging one line per request once it completes: method, path,
+// route name, status, response bytes, latency, client IP (the connection's peer, not a forwarded
+// header a client could forge), and the account IDs it touched (the {account_id} path variable
+// plus any reported by the handler through LogAccounts)
+// The request ID is added by the logger's handler (see ContextHandler), or ends a combined line
+// Server errors are logged at error level, the rest at info
+func NewAccessLog(options AccessLogOptions) Middleware {
+	var combinedMu sync.Mutex
+	excluded := make(map[string]bool, len(options.Exclude))
+	for _, name := range options.Exclude {
+		excluded[name] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if excluded[RouteNameFromContext(r.Context())] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			accounts := &loggedAccounts{}
+			if id, err := strconv.ParseInt(mux.Vars(r)["account_id"], 10, 64); err == nil {
+				accounts.ids = append(accounts.ids, id)
+			}
+			sw := &statusWriter{ResponseWriter: w}
+			ctx := context.WithValue(r.Context(), accountsKey{}, accounts)
+			next.ServeHTTP(sw, r.WithContext(ctx))
+			latency := time.Since(start)
+
+			if options.Combined != nil {
+				combinedMu.Lock()
+				writeCombined(options.Combined, r, sw, start, latency)
+				combinedMu.Unlock()
+				return
+			}
+			level := slog.LevelInfo
+			if sw.Status() >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", RouteNameFromContext(r.Context())),
+				slog.Int("status", sw.Status()),
+				slog.Int64("bytes", sw.bytes),
+				slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+				slog.String("client_ip", clientIP(r)),
+			}
+			accounts.mu.Lock()
+			if len(accounts.ids) > 0 {
+				attrs = append(attrs, slog.Any("account_ids", accounts.ids))
+			}
+			accounts.mu.Unlock()
+			logger := options.Logger
+			if logger == nil {
+				logger = slog.Default()
+			}
+			logger.LogAttrs(ctx, level, "request", attrs...)
+		})
+	}
+}
+
+// writeCombined writes a request's line in the Combined Log Format, followed by its request ID
+// ("-" without one) and latency in milliseconds, e.g.
+// 10.0.0.7 - - [15/Oct/2026:09:30:00 +0000] "POST /transactions HTTP/1.1" 201 214 "-" "curl/8.5.0" 6f1c0e0a 4.213
+func writeCombined(w io.Writer, r *http.Request, sw *statusWriter, start time.Time, latency time.Duration) {
+	requestID := RequestIDFromContext(r.Context())
+	if requestID == "" {
+		requestID = "-"
+	}
+	fmt.Fprintf(w, "%s - - [%s] %q %d %d %q %q %s %.3f\n",
+		orDash(clientIP(r)), start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+r.URL.RequestURI()+" "+r.Proto, sw.Status(), sw.bytes,
+		orDash(r.Referer()), orDash(r.UserAgent()), requestID, float64(latency.Microseconds())/1000)
+}
+
+// orDash returns value, or "-" for an empty one as the Combined Log Format writes it
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// clientIP returns the address of the request's peer without its port
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// LogAccounts adds account IDs to the access log line of the request ctx belongs to
+// Handlers call it for accounts named in the request body, e.g. a transfer's source and
+// destination; IDs already recorded are not repeated. A no-op outside AccessLog
+func LogAccounts(ctx context.Context, ids ...int64) {
+	accounts, ok := ctx.Value(accountsKey{}).(*loggedAccounts)
+	if !ok {
+		return
+	}
+	accounts.mu.Lock()
+	defer accounts.mu.Unlock()
+	for _, id := range ids {
+		if id <= 0 || containsID(accounts.ids, id) {
+			continue
+		}
+		accounts.ids = append(accounts.ids, id)
+	}
+}
+
+// containsID reports whether ids contains id
+func containsID(ids []int64, id int64) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// statusWriter records the status code and the number of body bytes written through it
+// Flushing and connection upgrades are passed through for the streaming endpoints
+type statusWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+// WriteHeader records the status before writing it
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write records an implicit 200 before writing the body
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Flush passes streaming flushes through
+func (s *statusWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController (e.g. per-write deadlines on
+// the streaming endpoints)
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Hijack passes connection upgrades (e.g. WebSocket) through
+func (s *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	s.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Status returns the response status: 101 for upgraded connections, 200 if nothing was written
+func (s *statusWriter) Status() int {
+	switch {
+	case s.status != 0:
+		return s.status
+	case s.hijacked:
+		return http.StatusSwitchingProtocols
+	default:
+		return http.StatusOK
+	}
+}
+
+// ContextHandler wraps a slog handler so records logged with a request's context carry its
+// request ID, tying handler and background log lines to the request's access log line
+func ContextHandler(h slog.Handler) slog.Handler {
+	return contextHandler{h}
+}
+
+// contextHandler adds the request ID of the record's context; see ContextHandler
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds request_id when the context has one
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs keeps the request ID handling on derived loggers
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the request ID handling on derived loggers
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
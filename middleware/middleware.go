@@ -0,0 +1,76 @@
+// Package middleware provides the HTTP cross-cutting concerns of the service and a
+// composable chain that applies them in an explicit order with per-route opt-outs
+package middleware
+
+import (
+	"net/http"
+)
+
+// Middleware wraps an http.Handler with additional behavior
+type Middleware func(http.Handler) http.Handler
+
+// Entry is a named middleware; names are used to opt individual routes out of a concern
+type Entry struct {
+	Name       string
+	Middleware Middleware
+}
+
+// Chain is an ordered list of middleware; the first entry is the outermost wrapper
+// Chains are immutable: Append and Without return new chains and never modify the receiver
+type Chain struct {
+	entries []Entry
+}
+
+// NewChain creates a chain applying the given entries in order (first = outermost)
+func NewChain(entries ...Entry) Chain {
+	return Chain{entries: append([]Entry(nil), entries...)}
+}
+
+// Append returns a new chain with the given entries added innermost
+func (c Chain) Append(entries ...Entry) Chain {
+	combined := make([]Entry, 0, len(c.entries)+len(entries))
+	combined = append(combined, c.entries...)
+	combined = append(combined, entries...)
+	return Chain{entries: combined}
+}
+
+// Without returns a new chain with the named entries removed
+// Unknown names are ignored so routes can opt out of concerns that are conditionally enabled
+func (c Chain) Without(names ...string) Chain {
+	if len(names) == 0 {
+		return c
+	}
+	skip := make(map[string]bool, len(names))
+	for _, name := range names {
+		skip[name] = true
+	}
+	kept := make([]Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		if !skip[e.Name] {
+			kept = append(kept, e)
+		}
+	}
+	return Chain{entries: kept}
+}
+
+// Names returns the entry names in application order
+func (c Chain) Names() []string {
+	names := make([]string, len(c.entries))
+	for i, e := range c.entries {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// Then wraps h with every middleware in the chain
+func (c Chain) Then(h http.Handler) http.Handler {
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		h = c.entries[i].Middleware(h)
+	}
+	return h
+}
+
+// ThenFunc is Then for an http.HandlerFunc
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return c.Then(fn)
+}
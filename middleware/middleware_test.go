@@ -0,0 +1,281 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// tag returns a middleware that appends name to the X-Order header before calling next
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Order", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestChain_Order(t *testing.T) {
+	chain := NewChain(Entry{"a", tag("a")}, Entry{"b", tag("b")}).Append(Entry{"c", tag("c")})
+
+	rr := httptest.NewRecorder()
+	chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {}).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if got := rr.Header().Values("X-Order"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Expected middleware order [a b c], got %v", got)
+	}
+}
+
+func TestChain_Without(t *testing.T) {
+	chain := NewChain(Entry{"a", tag("a")}, Entry{"b", tag("b")}, Entry{"c", tag("c")})
+	reduced := chain.Without("b", "unknown")
+
+	if got := reduced.Names(); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("Expected [a c], got %v", got)
+	}
+	// The original chain is unchanged
+	if got := chain.Names(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Without must not modify the receiver, got %v", got)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rr.Code)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	t.Run("Generated", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+		if len(seen) != 32 {
+			t.Errorf("Expected 32 character generated ID, got %q", seen)
+		}
+		if rr.Header().Get(RequestIDHeader) != seen {
+			t.Errorf("Expected response header to echo %q", seen)
+		}
+	})
+
+	t.Run("Propagated", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, "client-123")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if seen != "client-123" {
+			t.Errorf("Expected client request ID to be reused, got %q", seen)
+		}
+	})
+
+	t.Run("Oversized replaced", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, strings.Repeat("x", 500))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if len(seen) != 32 {
+			t.Errorf("Expected oversized ID to be replaced, got length %d", len(seen))
+		}
+	})
+}
+
+func TestAdminAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{"Disabled", "", "Bearer ", http.StatusForbidden},
+		{"Missing", "secret", "", http.StatusUnauthorized},
+		{"Wrong", "secret", "Bearer nope", http.StatusUnauthorized},
+		{"Not bearer", "secret", "Basic secret", http.StatusUnauthorized},
+		{"Valid", "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rr := httptest.NewRecorder()
+			AdminAuth(tc.token)(ok).ServeHTTP(rr, req)
+
+			if rr.Code != tc.want {
+				t.Errorf("Expected %d, got %d", tc.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestLoadAllowlist(t *testing.T) {
+	t.Setenv("IP_ALLOWLIST", "")
+	t.Setenv("IP_ALLOWLIST_KEYS", "")
+	if allowlist, err := LoadAllowlist(); err != nil || allowlist.Enabled() {
+		t.Errorf("Expected no allowlist by default, got %+v %v", allowlist, err)
+	}
+
+	t.Setenv("IP_ALLOWLIST", "10.0.0.0/8, 192.168.1.7, 2001:db8::/32")
+	t.Setenv("IP_ALLOWLIST_KEYS", "key_a=10.1.0.0/16|10.2.0.0/16,key_b=10.9.9.9")
+	allowlist, err := LoadAllowlist()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(allowlist.Networks) != 3 || allowlist.Networks[1].String() != "192.168.1.7/32" ||
+		len(allowlist.Keys["key_a"]) != 2 || len(allowlist.Keys["key_b"]) != 1 {
+		t.Errorf("Unexpected allowlist %+v", allowlist)
+	}
+
+	for name, env := range map[string][2]string{
+		"invalid range":   {"10.0.0.0/33", ""},
+		"host name":       {"internal.example.com", ""},
+		"key without IDs": {"", "=10.0.0.0/8"},
+		"key without IPs": {"", "key_a="},
+		"invalid key IP":  {"", "key_a=10.0.0.0/8|ten"},
+	} {
+		t.Setenv("IP_ALLOWLIST", env[0])
+		t.Setenv("IP_ALLOWLIST_KEYS", env[1])
+		if _, err := LoadAllowlist(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestIPAllowlist(t *testing.T) {
+	allowlist := Allowlist{
+		Networks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")},
+		Keys:     map[string][]netip.Prefix{"key_a": {netip.MustParsePrefix("10.1.0.0/16")}},
+	}
+	h := IPAllowlist(allowlist)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unixSocket := &net.UnixAddr{Name: "/run/transfers.sock", Net: "unix"}
+
+	testCases := []struct {
+		name, remoteAddr string
+		local            net.Addr
+		want             int
+	}{
+		{"Allowed network", "10.5.0.1:4000", nil, http.StatusOK},
+		{"IPv4-mapped IPv6", "[::ffff:10.5.0.1]:4000", nil, http.StatusOK},
+		{"Allowed IPv6", "[2001:db8::1]:4000", nil, http.StatusOK},
+		{"Outside the networks", "203.0.113.7:4000", nil, http.StatusForbidden},
+		{"Key's network is not enough", "10.1.2.3:4000", nil, http.StatusOK},
+		{"Unix socket", "@", unixSocket, http.StatusOK},
+		{"No address", "", nil, http.StatusForbidden},
+		{"Unparseable address", "@", &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 8080}, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/accounts/1", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.local != nil {
+				req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, tc.local))
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if rr.Code != tc.want {
+				t.Errorf("Expected %d, got %d", tc.want, rr.Code)
+			}
+		})
+	}
+
+	// The networks of a key apply to requests signed with it, wherever the signature is verified
+	req := httptest.NewRequest("POST", "/transactions", nil)
+	for addr, want := range map[string]bool{"10.1.2.3:4000": true, "10.5.0.1:4000": false, "203.0.113.7:4000": false} {
+		req.RemoteAddr = addr
+		if got := allowlist.AllowsRequest(req, "key_a"); got != want {
+			t.Errorf("%s with key_a: expected %v, got %v", addr, want, got)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(ContextHandler(slog.NewJSONHandler(&buf, nil))))
+	defer slog.SetDefault(previous)
+
+	h := RequestID(AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LogAccounts(r.Context(), 7, 42, 7)
+		http.Error(w, "Insufficient balance", http.StatusBadRequest)
+	})))
+	router := mux.NewRouter()
+	router.Handle("/accounts/{account_id}/transfers", WithRouteName("transfer")(h))
+
+	req := httptest.NewRequest("POST", "/accounts/7/transfers", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line struct {
+		Level      string  `json:"level"`
+		Msg        string  `json:"msg"`
+		Method     string  `json:"method"`
+		Path       string  `json:"path"`
+		Route      string  `json:"route"`
+		Status     int     `json:"status"`
+		Bytes      int64   `json:"bytes"`
+		LatencyMS  float64 `json:"latency_ms"`
+		ClientIP   string  `json:"client_ip"`
+		AccountIDs []int64 `json:"account_ids"`
+		RequestID  string  `json:"request_id"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q: %v", buf.String(), err)
+	}
+	if line.Level != "INFO" || line.Msg != "request" || line.Method != "POST" || line.Path != "/accounts/7/transfers" ||
+		line.Route != "transfer" || line.Status != http.StatusBadRequest || line.RequestID != "req-1" ||
+		line.Bytes != int64(len("Insufficient balance\n")) || line.ClientIP != "192.0.2.1" {
+		t.Errorf("Unexpected access log line %+v", line)
+	}
+	if !reflect.DeepEqual(line.AccountIDs, []int64{7, 42}) {
+		t.Errorf("Expected account IDs [7 42] without repeats, got %v", line.AccountIDs)
+	}
+}
+
+func TestNewAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	h := RequestID(NewAccessLog(AccessLogOptions{Combined: &buf, Exclude: []string{"health"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})))
+	router := mux.NewRouter()
+	router.Handle("/transactions", WithRouteName("create_transaction")(h))
+	router.Handle("/health", WithRouteName("health")(h))
+
+	req := httptest.NewRequest("POST", "/transactions?dry_run=1", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "192.0.2.1 - - [") || strings.Count(line, "\n") != 1 ||
+		!strings.Contains(line, `] "POST /transactions?dry_run=1 HTTP/1.1" 201 7 "-" "curl/8.5.0" req-1 `) {
+		t.Errorf("Expected one combined line for the transfer only, got %q", line)
+	}
+}
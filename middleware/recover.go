@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover converts a panic in a downstream handler into a 500 response
+// The panic and stack trace are logged so the failure is not lost, and the server keeps serving
+// The request ID is read from the response header, as Recover runs outside RequestID
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				slog.Error("panic serving request", "method", r.Method, "path", r.URL.Path,
+					"request_id", w.Header().Get(RequestIDHeader), "panic", rec, "stack", string(debug.Stack()))
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
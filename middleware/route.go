@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeNameKey struct{}
+
+// WithRouteName stores the matched route's registry name in the request context
+// Metrics and logs use the name instead of the raw path to keep label cardinality bounded
+func WithRouteName(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeNameKey{}, name)))
+		})
+	}
+}
+
+// RouteNameFromContext returns the route name stored by WithRouteName, or "" if there is none
+func RouteNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(routeNameKey{}).(string)
+	return name
+}
@@ -1,23 +1,343 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
 	"github.com/shopspring/decimal"
+
+	"internal-transfers/validation"
 )
 
 // Account represents a bank account
 type Account struct {
 	AccountID int64           `json:"account_id" db:"account_id"`
 	Balance   decimal.Decimal `json:"balance" db:"balance"`
+	Currency  string          `json:"currency" db:"currency"`
+	Sequence  int64           `json:"sequence" db:"sequence"`
+	Status    string          `json:"status" db:"status"`
+	Metadata  Metadata        `json:"metadata" db:"metadata"`
+	Tags      []string        `json:"tags" db:"tags"`
+	Version   int64           `json:"version" db:"version"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+
+	// UpdatedAt is when the account last changed in any way: its balances, holds, status, limits
+	// or metadata (see Version for the changes that conflict with an update)
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// ExternalID is the account's identifier in an external system (e.g. core banking or the
+	// ERP), unique across accounts; empty if it has none
+	ExternalID string `json:"external_id" db:"external_id"`
+
+	// InitialBalance is the balance the account was opened with
+	InitialBalance decimal.Decimal `json:"initial_balance" db:"initial_balance"`
+
+	// CreationToken is the client token the account was created with, unique across accounts;
+	// a retried creation with the same token and request returns the account (see
+	// CreateAccountRequest)
+	CreationToken string `json:"creation_token,omitempty" db:"creation_token"`
+
+	// OverdraftLimit is how far below zero the balance may go; zero forbids negative balances
+	OverdraftLimit decimal.Decimal `json:"overdraft_limit" db:"overdraft_limit"`
+
+	// Held is the total of the account's active holds: reserved for pending captures, it still
+	// counts in the balance but can no longer be debited by other transfers
+	Held decimal.Decimal `json:"held" db:"held"`
+}
+
+// Available returns how much the account can still be debited: its balance plus its overdraft
+// limit, less the funds reserved by active holds
+func (a Account) Available() decimal.Decimal {
+	return a.Balance.Add(a.OverdraftLimit).Sub(a.Held)
+}
+
+// Metadata is free-form JSON attached to an account by its owner, e.g. a name or cost center
+type Metadata map[string]interface{}
+
+// Account statuses; transfers debiting or crediting a frozen account are refused (compliance hold)
+const (
+	AccountActive = "active"
+	AccountFrozen = "frozen"
+)
+
+// NewAccount is an account to open, as passed to the repositories: its balance starts at
+// InitialBalance; Currency, ExternalID and CreationToken are empty for accounts without one
+type NewAccount struct {
+	AccountID      int64
+	InitialBalance decimal.Decimal
+	Currency       string
+	ExternalID     string
+	CreationToken  string
 }
 
 // CreateAccountRequest represents the request payload for creating an account
+// Currency is an optional ISO 4217 code; the initial balance may not have more decimal places
+// than the currency allows. ExternalID optionally names the account in an external system.
+// CreationToken optionally makes the creation idempotent: a retry with the same token and request
+// returns the account created by the first attempt instead of failing as a duplicate
 type CreateAccountRequest struct {
-	AccountID      int64  `json:"account_id"`
-	InitialBalance string `json:"initial_balance"`
+	AccountID      int64  `json:"account_id" validate:"positive"`
+	InitialBalance string `json:"initial_balance" validate:"required,decimal,nonnegative"`
+	Currency       string `json:"currency,omitempty"`
+	ExternalID     string `json:"external_id,omitempty"`
+	CreationToken  string `json:"creation_token,omitempty"`
+}
+
+// Validate checks the request against the account creation rules, with the supported currencies
+// in currencies (nil for the built-in ones), and returns the parsed initial balance and the
+// normalized currency
+// Every violation is reported, as validation.Errors with client-facing messages
+// Rules:
+//   - Account ID must be positive
+//   - Initial balance must be a valid, non-negative decimal
+//   - Currency, if given, must be supported, and the initial balance may not have more decimal
+//     places than it allows
+//   - External ID and creation token, if given, must be valid (see ValidateExternalID and
+//     ValidateCreationToken)
+func (r CreateAccountRequest) Validate(currencies *Currencies) (initialBalance decimal.Decimal, currency string, err error) {
+	errs := validation.Struct(r)
+	currency = NormalizeCurrency(r.Currency)
+	if scale, ok := currencies.Scale(currency); !ok {
+		errs.Add("currency", fmt.Sprintf("Unsupported currency %q", r.Currency))
+	} else if balance, err := decimal.NewFromString(r.InitialBalance); err == nil && currency != "" && !currencies.Fits(currency, balance) {
+		errs.Add("initial_balance", fmt.Sprintf("Initial balance has more than %d decimal places for %s", scale, currency))
+	}
+	if r.ExternalID != "" {
+		if err := ValidateExternalID(r.ExternalID); err != nil {
+			errs.Add("external_id", err.Error())
+		}
+	}
+	if r.CreationToken != "" {
+		if err := ValidateCreationToken(r.CreationToken); err != nil {
+			errs.Add("creation_token", err.Error())
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return decimal.Zero, "", err
+	}
+	return decimal.RequireFromString(r.InitialBalance), currency, nil
+}
+
+// ValidationErrorResponse is the 400 body of a request rejected field by field: Error joins the
+// messages of Fields, the violation of each invalid field
+type ValidationErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields validation.Errors `json:"fields"`
+}
+
+// ValidateCreationToken checks an account creation token
+// Returns a client-facing error if it is invalid
+func ValidateCreationToken(token string) error {
+	if !externalIDPattern.MatchString(token) {
+		return fmt.Errorf("Invalid creation token (letters, digits and _.:- up to %d characters)", MaxExternalIDLength)
+	}
+	return nil
+}
+
+// ValidateExternalID checks an external account ID
+// Returns a client-facing error if it is invalid
+func ValidateExternalID(id string) error {
+	if !externalIDPattern.MatchString(id) {
+		return fmt.Errorf("Invalid external ID %q (letters, digits and _.:- up to %d characters)", id, MaxExternalIDLength)
+	}
+	return nil
 }
 
 // AccountResponse represents the response for account queries
+// Wallets lists every balance of a multi-currency account, the primary balance included; it is
+// omitted for accounts without wallets
 type AccountResponse struct {
-	AccountID int64  `json:"account_id"`
-	Balance   string `json:"balance"`
+	AccountID      int64     `json:"account_id"`
+	Balance        string    `json:"balance"`
+	OverdraftLimit string    `json:"overdraft_limit"`
+	Held           string    `json:"held"`
+	Available      string    `json:"available"`
+	Currency       string    `json:"currency,omitempty"`
+	ExternalID     string    `json:"external_id,omitempty"`
+	Sequence       int64     `json:"sequence"`
+	Status         string    `json:"status"`
+	Metadata       Metadata  `json:"metadata"`
+	Tags           []string  `json:"tags"`
+	Version        int64     `json:"version"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	Wallets []WalletResponse `json:"wallets,omitempty"`
+}
+
+// NewAccountResponse converts an account into its API representation
+// Metadata and tags are always present, as {} and [] when unset
+func NewAccountResponse(a Account) AccountResponse {
+	response := AccountResponse{
+		AccountID:      a.AccountID,
+		Balance:        a.Balance.String(),
+		OverdraftLimit: a.OverdraftLimit.String(),
+		Held:           a.Held.String(),
+		Available:      a.Available().String(),
+		Currency:       a.Currency,
+		ExternalID:     a.ExternalID,
+		Sequence:       a.Sequence,
+		Status:         a.Status,
+		Metadata:       a.Metadata,
+		Tags:           a.Tags,
+		Version:        a.Version,
+		CreatedAt:      a.CreatedAt,
+	}
+	if response.Metadata == nil {
+		response.Metadata = Metadata{}
+	}
+	if response.Tags == nil {
+		response.Tags = []string{}
+	}
+	return response
+}
+
+// SetOverdraftLimitRequest represents the request payload for PUT /admin/accounts/{account_id}/overdraft
+type SetOverdraftLimitRequest struct {
+	OverdraftLimit string `json:"overdraft_limit"`
+}
+
+// AccountListResponse is the response for account listings
+// NextCursor continues the listing and is omitted on its last page
+type AccountListResponse struct {
+	Accounts   []AccountResponse `json:"accounts"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// Account metadata, tag and external ID limits
+const (
+	MaxMetadataBytes    = 4096
+	MaxTags             = 20
+	MaxExternalIDLength = 128
+)
+
+// tagPattern restricts tags to short identifiers usable unescaped in query strings
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]{0,63}$`)
+
+// externalIDPattern restricts external IDs and creation tokens to identifiers usable unescaped in
+// URL paths
+var externalIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]{0,127}$`)
+
+// UpdateAccountRequest represents the request payload for PATCH /accounts/{account_id}
+// Omitted fields are left unchanged. Metadata is merged into the existing metadata (a key set to
+// null is removed, as in JSON Merge Patch); tags, when present, replace the account's tags;
+// external_id, when present, replaces the account's external ID ("" removes it).
+// Status is a compliance control and only changes through the admin freeze/unfreeze endpoints;
+// it is accepted here solely to reject it with a clear error instead of silently ignoring it
+type UpdateAccountRequest struct {
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Tags       *[]string              `json:"tags,omitempty"`
+	ExternalID *string                `json:"external_id,omitempty"`
+	Status     *string                `json:"status,omitempty"`
+}
+
+// AccountUpdate is a validated account change applied atomically by the repository
+type AccountUpdate struct {
+	// SetMetadata keys are added or overwritten; RemoveMetadata keys are deleted
+	SetMetadata    Metadata
+	RemoveMetadata []string
+
+	// Tags replaces the account's tags when non-nil
+	Tags []string
+
+	// ExternalID replaces the account's external ID when non-nil; empty removes it
+	ExternalID *string
+
+	// ExpectedVersion makes the update conditional on the account's current version; 0 applies it
+	// unconditionally
+	ExpectedVersion int64
+}
+
+// Validate checks the request and converts it into the update to apply
+// Tags are deduplicated in their original order
+// Returns a client-facing error if the request is invalid
+func (r UpdateAccountRequest) Validate() (AccountUpdate, error) {
+	var update AccountUpdate
+	if r.Status != nil {
+		return update, fmt.Errorf("Status can only be changed through the admin freeze/unfreeze endpoints")
+	}
+	if r.Metadata == nil && r.Tags == nil && r.ExternalID == nil {
+		return update, fmt.Errorf("Nothing to update (expected metadata, tags or external_id)")
+	}
+	if r.ExternalID != nil {
+		if *r.ExternalID != "" {
+			if err := ValidateExternalID(*r.ExternalID); err != nil {
+				return update, err
+			}
+		}
+		update.ExternalID = r.ExternalID
+	}
+
+	if r.Metadata != nil {
+		encoded, err := json.Marshal(r.Metadata)
+		if err != nil || len(encoded) > MaxMetadataBytes {
+			return update, fmt.Errorf("Metadata must encode to at most %d bytes of JSON", MaxMetadataBytes)
+		}
+		update.SetMetadata = Metadata{}
+		for key, value := range r.Metadata {
+			if value == nil {
+				update.RemoveMetadata = append(update.RemoveMetadata, key)
+			} else {
+				update.SetMetadata[key] = value
+			}
+		}
+	}
+
+	if r.Tags != nil {
+		update.Tags = []string{}
+		seen := make(map[string]bool)
+		for _, tag := range *r.Tags {
+			if !tagPattern.MatchString(tag) {
+				return update, fmt.Errorf("Invalid tag %q (letters, digits and _.:- up to 64 characters)", tag)
+			}
+			if !seen[tag] {
+				seen[tag] = true
+				update.Tags = append(update.Tags, tag)
+			}
+		}
+		if len(update.Tags) > MaxTags {
+			return update, fmt.Errorf("At most %d tags are allowed", MaxTags)
+		}
+	}
+	return update, nil
+}
+
+// AccountFilter selects accounts in listings; zero fields match every account
+// Balance bounds are inclusive; CreatedFrom is inclusive and CreatedBefore exclusive
+type AccountFilter struct {
+	Tag           string
+	Status        string
+	MinBalance    *decimal.Decimal
+	MaxBalance    *decimal.Decimal
+	CreatedFrom   time.Time
+	CreatedBefore time.Time
+	// AfterID starts a page after this account ID (cursor pagination); unlike the other fields it
+	// positions the page rather than selecting accounts, so Matches ignores it
+	AfterID int64
+}
+
+// Matches reports whether the account passes the filter
+// Storage backends that cannot filter natively (e.g. the in-memory store) use it directly
+func (f AccountFilter) Matches(a Account) bool {
+	switch {
+	case f.Status != "" && a.Status != f.Status:
+		return false
+	case f.MinBalance != nil && a.Balance.LessThan(*f.MinBalance):
+		return false
+	case f.MaxBalance != nil && a.Balance.GreaterThan(*f.MaxBalance):
+		return false
+	case !f.CreatedFrom.IsZero() && a.CreatedAt.Before(f.CreatedFrom):
+		return false
+	case !f.CreatedBefore.IsZero() && !a.CreatedAt.Before(f.CreatedBefore):
+		return false
+	case f.Tag == "":
+		return true
+	}
+	for _, tag := range a.Tags {
+		if tag == f.Tag {
+			return true
+		}
+	}
+	return false
 }
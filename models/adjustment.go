@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+)
+
+// Adjustment is a manual credit or debit of a single account, booked by an operator to correct an
+// operational error
+// Adjustments are ledger entries of their own, not transfers: they have no counterparty and take
+// no sequence number, but count in the account's balance history, statements and reconciliation
+type Adjustment struct {
+	ID        int64 `json:"id" db:"id"`
+	AccountID int64 `json:"account_id" db:"account_id"`
+	// Amount is signed: positive credits the account, negative debits it
+	Amount decimal.Decimal `json:"amount" db:"amount"`
+	Reason string          `json:"reason" db:"reason"`
+	// Actor is the operator who booked the adjustment
+	Actor string `json:"actor" db:"actor"`
+	// BalanceAfter is the account's balance once the adjustment was applied
+	BalanceAfter decimal.Decimal `json:"balance_after" db:"balance_after"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Counts reports whether the adjustment is part of a balance as recorded at recordedAt and
+// effective at effectiveAt; an adjustment takes effect when it is recorded
+func (a Adjustment) Counts(recordedAt, effectiveAt time.Time) bool {
+	return !a.CreatedAt.After(recordedAt) && !a.CreatedAt.After(effectiveAt)
+}
+
+// CreateAdjustmentRequest is the body of POST /admin/adjustments
+// Amount is a signed decimal string: "25.00" credits the account, "-25.00" debits it
+type CreateAdjustmentRequest struct {
+	AccountID int64  `json:"account_id"`
+	Amount    string `json:"amount"`
+	Reason    string `json:"reason"`
+	Actor     string `json:"actor"`
+}
+
+// Lengths of an adjustment's annotations, in characters
+const (
+	MaxAdjustmentReasonLength = 500
+	MaxAdjustmentActorLength  = 64
+)
+
+// Validate checks the request and returns the parsed amount; the error messages are client-facing
+// Rules:
+//   - Account ID must be positive
+//   - Amount must be a valid, non-zero decimal
+//   - Reason is required (not blank) and may not exceed MaxAdjustmentReasonLength characters
+//   - Actor is required and may not exceed MaxAdjustmentActorLength characters, surrounding
+//     whitespace or control characters
+func (r CreateAdjustmentRequest) Validate() (decimal.Decimal, error) {
+	if r.AccountID <= 0 {
+		return decimal.Zero, errors.New("Account ID must be positive")
+	}
+	amount, err := decimal.NewFromString(r.Amount)
+	if err != nil {
+		return decimal.Zero, errors.New("Invalid amount format")
+	}
+	if amount.IsZero() {
+		return decimal.Zero, errors.New("Amount must not be zero")
+	}
+	if strings.TrimSpace(r.Reason) == "" {
+		return decimal.Zero, errors.New("Reason is required")
+	}
+	if utf8.RuneCountInString(r.Reason) > MaxAdjustmentReasonLength {
+		return decimal.Zero, fmt.Errorf("Reason must not exceed %d characters", MaxAdjustmentReasonLength)
+	}
+	if r.Actor == "" {
+		return decimal.Zero, errors.New("Actor is required")
+	}
+	if utf8.RuneCountInString(r.Actor) > MaxAdjustmentActorLength {
+		return decimal.Zero, fmt.Errorf("Actor must not exceed %d characters", MaxAdjustmentActorLength)
+	}
+	if r.Actor != strings.TrimSpace(r.Actor) || strings.IndexFunc(r.Actor, unicode.IsControl) >= 0 {
+		return decimal.Zero, errors.New("Actor must not contain surrounding whitespace or control characters")
+	}
+	return amount, nil
+}
+
+// AdjustmentResponse is the API representation of an adjustment
+type AdjustmentResponse struct {
+	ID           int64     `json:"id"`
+	AccountID    int64     `json:"account_id"`
+	Amount       string    `json:"amount"`
+	Reason       string    `json:"reason"`
+	Actor        string    `json:"actor"`
+	BalanceAfter string    `json:"balance_after"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// NewAdjustmentResponse converts an adjustment into its API representation
+func NewAdjustmentResponse(a Adjustment) AdjustmentResponse {
+	return AdjustmentResponse{
+		ID:           a.ID,
+		AccountID:    a.AccountID,
+		Amount:       a.Amount.String(),
+		Reason:       a.Reason,
+		Actor:        a.Actor,
+		BalanceAfter: a.BalanceAfter.String(),
+		CreatedAt:    a.CreatedAt,
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// APIKey is a key signing requests with HMAC-SHA256 (see the signing package), kept in the
+// api_keys table and managed through the /admin/api-keys endpoints
+type APIKey struct {
+	// ID identifies the key in the X-Signature-Key-ID header of signed requests
+	ID string `json:"id" db:"id"`
+	// Secret is the HMAC key, returned once when the key is created
+	Secret      string    `json:"-" db:"secret"`
+	Description string    `json:"description" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	// RevokedAt is when the key was revoked; signatures made with a revoked key are refused
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+// CreateAPIKeyRequest represents the request body for POST /admin/api-keys
+type CreateAPIKeyRequest struct {
+	// Description says who holds the key, e.g. "payroll batch job"
+	Description string `json:"description"`
+}
+
+// APIKeyResponse is the API representation of an API key, without its secret
+type APIKeyResponse struct {
+	ID          string     `json:"id"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// NewAPIKeyResponse converts an API key into its API representation
+func NewAPIKeyResponse(k APIKey) APIKeyResponse {
+	return APIKeyResponse{ID: k.ID, Description: k.Description, CreatedAt: k.CreatedAt, RevokedAt: k.RevokedAt}
+}
+
+// CreateAPIKeyResponse is the body of POST /admin/api-keys: the new key with its secret, which
+// cannot be read again
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Secret string `json:"secret"`
+}
+
+// APIKeyListResponse is the body of GET /admin/api-keys
+type APIKeyListResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Attachment is a document (an invoice, an authorization form) linked to a transaction
+// The content lives in the object store under ObjectKey; the record holds what is needed to
+// serve and verify it
+type Attachment struct {
+	ID            int64
+	TransactionID int64
+	Filename      string
+	ContentType   string
+	Size          int64
+	// SHA256 is the hex digest of the content, checked when it is served
+	SHA256    string
+	ObjectKey string
+	CreatedAt time.Time
+}
+
+// CreateAttachmentRequest is the body of POST /admin/transactions/{transaction_id}/attachments
+// Content is the document, base64 encoded (standard encoding, with padding)
+type CreateAttachmentRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"`
+}
+
+// AttachmentResponse is the API representation of an attachment; the content is downloaded
+// separately
+type AttachmentResponse struct {
+	ID            int64     `json:"id"`
+	TransactionID int64     `json:"transaction_id"`
+	Filename      string    `json:"filename"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	SHA256        string    `json:"sha256"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NewAttachmentResponse converts an attachment into its API representation
+func NewAttachmentResponse(a Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:            a.ID,
+		TransactionID: a.TransactionID,
+		Filename:      a.Filename,
+		ContentType:   a.ContentType,
+		Size:          a.Size,
+		SHA256:        a.SHA256,
+		CreatedAt:     a.CreatedAt,
+	}
+}
+
+// AttachmentListResponse is the body of GET /admin/transactions/{transaction_id}/attachments
+type AttachmentListResponse struct {
+	TransactionID int64                `json:"transaction_id"`
+	Attachments   []AttachmentResponse `json:"attachments"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditAccountCreated      = "account.created"
+	AuditAccountUpdated      = "account.updated"
+	AuditAccountFrozen       = "account.frozen"
+	AuditAccountUnfrozen     = "account.unfrozen"
+	AuditOverdraftLimitSet   = "account.overdraft_limit_set"
+	AuditTransferLimitsSet   = "account.limits_set"
+	AuditAccountAdjusted     = "account.adjusted"
+	AuditWalletOpened        = "account.wallet_opened"
+	AuditCurrencyConfigured  = "currency.configured"
+	AuditAPIKeyCreated       = "api_key.created"
+	AuditAPIKeyRevoked       = "api_key.revoked"
+	AuditTransactionCreated  = "transaction.created"
+	AuditTransactionReversed = "transaction.reversed"
+)
+
+// Actors of changes made by the service itself rather than a caller
+const (
+	// AuditActorAdmin is the holder of the ADMIN_TOKEN
+	AuditActorAdmin = "admin"
+
+	// AuditActorSettlement books the reversals of transfers a settlement partner returned
+	AuditActorSettlement = "settlement"
+)
+
+// AuditEvent records one change to the ledger: who made it, what it was and the state of the
+// changed account or transaction before and after, as returned by the API
+// Audit events are append-only; storage refuses to change or delete them
+type AuditEvent struct {
+	ID         int64     `json:"id" db:"id"`
+	OccurredAt time.Time `json:"occurred_at" db:"occurred_at"`
+	// Actor is "api_key:<key ID>" (the caller's usage key, "api_key:anonymous" without a key),
+	// "admin", "recurring:<rule ID>" or "settlement"
+	Actor  string `json:"actor" db:"actor"`
+	Action string `json:"action" db:"action"`
+	// AccountIDs are the accounts the change touched: the changed account, or a transaction's
+	// source and destination accounts
+	AccountIDs []int64 `json:"account_ids" db:"account_ids"`
+	// TransactionID identifies the changed transaction; zero for account changes
+	TransactionID int64  `json:"transaction_id,omitempty" db:"transaction_id"`
+	RequestID     string `json:"request_id,omitempty" db:"request_id"`
+	// Before and After are JSON snapshots; Before is null for created accounts and transactions
+	Before json.RawMessage `json:"before" db:"before"`
+	After  json.RawMessage `json:"after" db:"after"`
+}
+
+// AuditFilter selects audit events; zero fields match everything
+// AccountID matches the events touching the account; From is inclusive and To exclusive
+type AuditFilter struct {
+	Actor         string
+	Action        string
+	AccountID     int64
+	TransactionID int64
+	From          time.Time
+	To            time.Time
+	BeforeID      int64
+}
+
+// Matches reports whether the event passes the filter; BeforeID is not considered
+func (f AuditFilter) Matches(e AuditEvent) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case f.AccountID != 0 && !containsAccount(e.AccountIDs, f.AccountID):
+		return false
+	case f.TransactionID != 0 && e.TransactionID != f.TransactionID:
+		return false
+	case !f.From.IsZero() && e.OccurredAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.OccurredAt.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// containsAccount reports whether ids contains id
+func containsAccount(ids []int64, id int64) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditListResponse is the body of GET /admin/audit
+// NextCursor continues the listing and is omitted on its last page
+type AuditListResponse struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
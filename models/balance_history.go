@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// What caused a balance snapshot
+const (
+	// BalanceSnapshotOpened records an account's initial balance
+	BalanceSnapshotOpened = "opened"
+
+	// BalanceSnapshotTransfer records the balance a transfer left, in the transfer's commit
+	BalanceSnapshotTransfer = "transfer"
+
+	// BalanceSnapshotPeriodic records every account's balance at a regular interval, so the
+	// history has points for accounts without recent transfers
+	BalanceSnapshotPeriodic = "periodic"
+
+	// BalanceSnapshotAdjustment records the balance a manual adjustment left, in its commit
+	BalanceSnapshotAdjustment = "adjustment"
+)
+
+// BalanceSnapshot is an account's balance at a point in time
+type BalanceSnapshot struct {
+	ID        int64           `json:"id" db:"id"`
+	AccountID int64           `json:"account_id" db:"account_id"`
+	Balance   decimal.Decimal `json:"balance" db:"balance"`
+	// Sequence is the account's ledger sequence number the balance includes movements up to
+	Sequence int64     `json:"sequence" db:"sequence"`
+	TakenAt  time.Time `json:"taken_at" db:"taken_at"`
+	Source   string    `json:"source" db:"source"`
+	// TransactionID is the transfer that left the balance; zero for other sources
+	TransactionID int64 `json:"transaction_id,omitempty" db:"transaction_id"`
+}
+
+// BalanceHistoryFilter selects an account's snapshots; zero times are unbounded
+// From is inclusive and To exclusive; AfterID continues a listing after that snapshot
+type BalanceHistoryFilter struct {
+	AccountID int64
+	From      time.Time
+	To        time.Time
+	AfterID   int64
+}
+
+// Matches reports whether the snapshot passes the filter; AfterID is not considered
+func (f BalanceHistoryFilter) Matches(s BalanceSnapshot) bool {
+	return s.AccountID == f.AccountID &&
+		(f.From.IsZero() || !s.TakenAt.Before(f.From)) &&
+		(f.To.IsZero() || s.TakenAt.Before(f.To))
+}
+
+// BalanceSnapshotResponse is one point of GET /accounts/{account_id}/balance-history
+type BalanceSnapshotResponse struct {
+	ID            int64     `json:"id"`
+	TakenAt       time.Time `json:"taken_at"`
+	Balance       string    `json:"balance"`
+	Sequence      int64     `json:"sequence"`
+	Source        string    `json:"source"`
+	TransactionID int64     `json:"transaction_id,omitempty"`
+}
+
+// NewBalanceSnapshotResponse converts a snapshot into its API representation
+func NewBalanceSnapshotResponse(s BalanceSnapshot) BalanceSnapshotResponse {
+	return BalanceSnapshotResponse{
+		ID:            s.ID,
+		TakenAt:       s.TakenAt,
+		Balance:       s.Balance.String(),
+		Sequence:      s.Sequence,
+		Source:        s.Source,
+		TransactionID: s.TransactionID,
+	}
+}
+
+// BalanceHistoryResponse is the body of GET /accounts/{account_id}/balance-history
+// NextCursor continues the listing and is omitted on its last page
+type BalanceHistoryResponse struct {
+	AccountID  int64                     `json:"account_id"`
+	Snapshots  []BalanceSnapshotResponse `json:"snapshots"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
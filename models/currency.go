@@ -0,0 +1,178 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Currency is the configuration of a supported ISO 4217 currency, kept in the currencies table
+// and changed through PUT /admin/currencies/{code} (see the currencies package)
+type Currency struct {
+	Code string `json:"code" db:"code"`
+	// Scale is the number of minor units (decimal places) amounts in the currency may have, at
+	// most AmountScale, the scale amounts are stored with
+	Scale int32 `json:"scale" db:"scale"`
+	// MinAmount and MaxAmount bound each transfer debiting the currency; nil is not bounded
+	MinAmount *decimal.Decimal `json:"min_amount" db:"min_amount"`
+	MaxAmount *decimal.Decimal `json:"max_amount" db:"max_amount"`
+	// AllowNegative is whether accounts in the currency may be given an overdraft limit
+	AllowNegative bool      `json:"allow_negative" db:"allow_negative"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// defaultScales maps the built-in ISO 4217 currency codes to their number of minor units
+// (decimal places). Accounts created without a currency predate multi-currency support; they
+// keep the ledger's AmountScale and can only transact with each other
+var defaultScales = map[string]int32{
+	"AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "HUF": 2, "INR": 2, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2,
+	"SEK": 2, "SGD": 2, "USD": 2, "ZAR": 2,
+	"CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0, "VND": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// DefaultCurrencies returns the built-in currencies, ordered by code: their ISO 4217 scale,
+// unbounded transfers and negative balances allowed. The currencies migration seeds the same rows
+func DefaultCurrencies() []Currency {
+	list := make([]Currency, 0, len(defaultScales))
+	for code, scale := range defaultScales {
+		list = append(list, Currency{Code: code, Scale: scale, AllowNegative: true})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// Currencies is a set of supported currencies, consulted by the validation of amounts and
+// currency codes of the services it is given to (see service.AccountService.WithCurrencies)
+// The nil set is the built-in currencies (DefaultCurrencies)
+// Safe for concurrent use
+type Currencies struct {
+	mu     sync.RWMutex
+	byCode map[string]Currency
+}
+
+// builtinCurrencies backs the nil set
+var builtinCurrencies = NewCurrencies(DefaultCurrencies())
+
+// NewCurrencies creates a set of the currencies in list
+func NewCurrencies(list []Currency) *Currencies {
+	c := &Currencies{}
+	c.Set(list)
+	return c
+}
+
+// Set replaces the currencies of the set with list, e.g. with the stored configuration
+func (c *Currencies) Set(list []Currency) {
+	byCode := make(map[string]Currency, len(list))
+	for _, currency := range list {
+		byCode[currency.Code] = currency
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byCode = byCode
+}
+
+// Lookup returns the configuration of a supported currency; ok is false for unsupported codes
+// and for the empty currency
+func (c *Currencies) Lookup(code string) (currency Currency, ok bool) {
+	if c == nil {
+		c = builtinCurrencies
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	currency, ok = c.byCode[code]
+	return currency, ok
+}
+
+// NormalizeCurrency upper-cases and trims a currency code
+func NormalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Scale returns the number of decimal places amounts in the currency may have
+// The empty currency (accounts without one) uses AmountScale; ok is false for unsupported codes
+func (c *Currencies) Scale(currency string) (scale int32, ok bool) {
+	if currency == "" {
+		return AmountScale, true
+	}
+	found, ok := c.Lookup(currency)
+	return found.Scale, ok
+}
+
+// Fits reports whether amount has no more decimal places than the currency allows
+// Unsupported currencies fit nothing
+func (c *Currencies) Fits(currency string, amount decimal.Decimal) bool {
+	scale, ok := c.Scale(currency)
+	return ok && amount.Equal(amount.Truncate(scale))
+}
+
+// AllowsNegative reports whether accounts in the currency may be given an overdraft limit
+// Accounts without a currency always may
+func (c *Currencies) AllowsNegative(currency string) bool {
+	if currency == "" {
+		return true
+	}
+	found, ok := c.Lookup(currency)
+	return ok && found.AllowNegative
+}
+
+// CheckTransferAmount tests a transfer's amount against the bounds of the currency it debits
+// Returns the repositories' "amount below currency minimum" or "amount above currency maximum"
+// error, or nil if the amount is within them or the currency has none
+func (c *Currencies) CheckTransferAmount(currency string, amount decimal.Decimal) error {
+	found, ok := c.Lookup(currency)
+	if !ok {
+		return nil
+	}
+	if found.MinAmount != nil && amount.LessThan(*found.MinAmount) {
+		return errors.New("amount below currency minimum")
+	}
+	if found.MaxAmount != nil && amount.GreaterThan(*found.MaxAmount) {
+		return errors.New("amount above currency maximum")
+	}
+	return nil
+}
+
+// SetCurrencyRequest represents the request body for PUT /admin/currencies/{code}
+// The request replaces the currency's configuration; an omitted or null bound removes it, and
+// scale is required for a currency not configured yet
+type SetCurrencyRequest struct {
+	Scale         *int32  `json:"scale"`
+	MinAmount     *string `json:"min_amount"`
+	MaxAmount     *string `json:"max_amount"`
+	AllowNegative *bool   `json:"allow_negative"`
+}
+
+// CurrencyResponse is the API representation of a currency's configuration
+type CurrencyResponse struct {
+	Code          string    `json:"code"`
+	Scale         int32     `json:"scale"`
+	MinAmount     *string   `json:"min_amount"`
+	MaxAmount     *string   `json:"max_amount"`
+	AllowNegative bool      `json:"allow_negative"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// NewCurrencyResponse converts a currency into its API representation
+func NewCurrencyResponse(c Currency) CurrencyResponse {
+	response := CurrencyResponse{Code: c.Code, Scale: c.Scale, AllowNegative: c.AllowNegative, UpdatedAt: c.UpdatedAt}
+	if c.MinAmount != nil {
+		value := c.MinAmount.String()
+		response.MinAmount = &value
+	}
+	if c.MaxAmount != nil {
+		value := c.MaxAmount.String()
+		response.MaxAmount = &value
+	}
+	return response
+}
+
+// CurrencyListResponse is the body of GET /currencies
+type CurrencyListResponse struct {
+	Currencies []CurrencyResponse `json:"currencies"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// AccountCreatedEvent is the payload of the account.created event
+type AccountCreatedEvent struct {
+	AccountID      int64     `json:"account_id"`
+	InitialBalance string    `json:"initial_balance"`
+	Currency       string    `json:"currency,omitempty"`
+	ExternalID     string    `json:"external_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// The transaction.completed event payload is a TransactionResponse, identical to the body
+// returned by POST /transactions
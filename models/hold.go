@@ -0,0 +1,113 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Hold statuses; only an active hold reserves funds
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+	HoldExpired  = "expired"
+)
+
+// Hold reserves funds on its source account for a later transfer to its destination (see the holds
+// package). While active, Amount counts against the source account's available balance but not its
+// balance. Capturing books the transfer and ends the hold, releasing any amount not captured;
+// releasing or expiring frees the whole amount. TransactionID is set once captured
+type Hold struct {
+	ID                   int64           `json:"id" db:"id"`
+	SourceAccountID      int64           `json:"source_account_id" db:"source_account_id"`
+	DestinationAccountID int64           `json:"destination_account_id" db:"destination_account_id"`
+	Amount               decimal.Decimal `json:"amount" db:"amount"`
+	TransferType         string          `json:"transfer_type" db:"transfer_type"`
+	Tenant               string          `json:"tenant" db:"tenant"`
+	Status               string          `json:"status" db:"status"`
+	ExpiresAt            time.Time       `json:"expires_at" db:"expires_at"`
+	TransactionID        int64           `json:"transaction_id,omitempty" db:"transaction_id"`
+	CreatedAt            time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// CaptureRequest returns the transfer request that captures amount of the hold; an empty amount
+// captures the whole hold
+func (h Hold) CaptureRequest(amount string) CreateTransactionRequest {
+	if amount == "" {
+		amount = h.Amount.String()
+	}
+	return CreateTransactionRequest{
+		SourceAccountID:      h.SourceAccountID,
+		DestinationAccountID: h.DestinationAccountID,
+		Amount:               amount,
+		TransferType:         h.TransferType,
+		Tenant:               h.Tenant,
+		HoldID:               h.ID,
+	}
+}
+
+// CreateHoldRequest represents the request body for POST /holds
+// ExpiresAt is when an uncaptured hold is released automatically; it defaults to the configured
+// hold lifetime and may not exceed the maximum
+type CreateHoldRequest struct {
+	SourceAccountID      int64      `json:"source_account_id"`
+	DestinationAccountID int64      `json:"destination_account_id"`
+	Amount               string     `json:"amount"`
+	TransferType         string     `json:"transfer_type,omitempty"`
+	ExpiresAt            *time.Time `json:"expires_at,omitempty"`
+	// Tenant is taken from the X-Tenant-ID header; the capture evaluates that tenant's rules
+	Tenant string `json:"-"`
+}
+
+// CaptureHoldRequest represents the optional request body for POST /holds/{hold_id}/capture
+// Amount defaults to the whole hold; a smaller amount captures part of it and releases the rest
+type CaptureHoldRequest struct {
+	Amount string `json:"amount,omitempty"`
+}
+
+// HoldResponse represents a hold in API responses
+type HoldResponse struct {
+	ID                   int64     `json:"id"`
+	SourceAccountID      int64     `json:"source_account_id"`
+	DestinationAccountID int64     `json:"destination_account_id"`
+	Amount               string    `json:"amount"`
+	TransferType         string    `json:"transfer_type"`
+	Status               string    `json:"status"`
+	ExpiresAt            time.Time `json:"expires_at"`
+	TransactionID        int64     `json:"transaction_id,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// NewHoldResponse converts a hold into its API representation
+func NewHoldResponse(h Hold) HoldResponse {
+	return HoldResponse{
+		ID:                   h.ID,
+		SourceAccountID:      h.SourceAccountID,
+		DestinationAccountID: h.DestinationAccountID,
+		Amount:               h.Amount.String(),
+		TransferType:         h.TransferType,
+		Status:               h.Status,
+		ExpiresAt:            h.ExpiresAt,
+		TransactionID:        h.TransactionID,
+		CreatedAt:            h.CreatedAt,
+		UpdatedAt:            h.UpdatedAt,
+	}
+}
+
+// CaptureHoldResponse is the body of POST /holds/{hold_id}/capture: the captured hold and the
+// transfer it booked
+type CaptureHoldResponse struct {
+	Hold        HoldResponse        `json:"hold"`
+	Transaction TransactionResponse `json:"transaction"`
+}
+
+// HoldListResponse is the body of GET /accounts/{account_id}/holds
+// NextCursor continues the listing and is omitted on its last page
+type HoldListResponse struct {
+	AccountID  int64          `json:"account_id"`
+	Holds      []HoldResponse `json:"holds"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
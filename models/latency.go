@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// TransferLatency is the processing timeline of one committed transfer, the unit of SLA tracking
+// Validate, Commit and Emit are measured from ReceivedAt: Validate until validation, conversion
+// and the rules had passed, Commit until the ledger commit returned, Emit until the
+// committed-transfer event had been emitted to in-process subscribers and hooks. LockWait is the
+// part of the commit spent waiting for the accounts' locks
+type TransferLatency struct {
+	TransactionID int64         `json:"transaction_id" db:"transaction_id"`
+	ClientID      string        `json:"client_id" db:"client_id"`
+	ReceivedAt    time.Time     `json:"received_at" db:"received_at"`
+	Validate      time.Duration `json:"validate" db:"validate_us"`
+	LockWait      time.Duration `json:"lock_wait" db:"lock_wait_us"`
+	Commit        time.Duration `json:"commit" db:"commit_us"`
+	Emit          time.Duration `json:"emit" db:"emit_us"`
+
+	// Rules names the validation rules the transfer was checked against; all of them passed
+	Rules []string `json:"rules" db:"rules"`
+
+	// Retries counts later requests refused as duplicates of the transfer (same source account
+	// and reference), typically a client retrying after losing the response
+	Retries int `json:"retries" db:"retries"`
+}
+
+// Stages of a transfer's processing, each with its own latency budget (see TransferLatency.Stages)
+const (
+	LatencyStageValidate = "validate"
+	LatencyStageLockWait = "lock_wait"
+	LatencyStageCommit   = "commit"
+	LatencyStageEmit     = "emit"
+)
+
+// LatencyStages lists the stages in processing order
+var LatencyStages = []string{LatencyStageValidate, LatencyStageLockWait, LatencyStageCommit, LatencyStageEmit}
+
+// StageLatency is the time one stage of a transfer took
+type StageLatency struct {
+	Stage    string
+	Duration time.Duration
+}
+
+// Stages splits the timeline into the time each stage took, in processing order: validation,
+// the wait for the accounts' locks, the rest of the commit (including the outbox event write) and
+// the emission to in-process subscribers and hooks
+func (l TransferLatency) Stages() []StageLatency {
+	return []StageLatency{
+		{LatencyStageValidate, l.Validate},
+		{LatencyStageLockWait, l.LockWait},
+		{LatencyStageCommit, max(0, l.Commit-l.Validate-l.LockWait)},
+		{LatencyStageEmit, max(0, l.Emit-l.Commit)},
+	}
+}
+
+// LatencyStats summarizes one client's transfer latencies over a period
+// Percentiles interpolate linearly between the closest samples (PostgreSQL percentile_cont)
+type LatencyStats struct {
+	ClientID     string
+	Transfers    int64
+	CommitP50    time.Duration
+	CommitP95    time.Duration
+	CommitP99    time.Duration
+	CommitMax    time.Duration
+	EmitP95      time.Duration
+	WithinTarget int64
+}
+
+// SLAResponse is a client's commit latency SLA compliance over a period
+// Latencies are in milliseconds; Compliant is true when the p95 commit latency is within the
+// target (and for periods without transfers)
+type SLAResponse struct {
+	ClientID     string  `json:"client_id"`
+	From         string  `json:"from"`
+	To           string  `json:"to"`
+	Transfers    int64   `json:"transfers"`
+	CommitP50Ms  float64 `json:"commit_p50_ms"`
+	CommitP95Ms  float64 `json:"commit_p95_ms"`
+	CommitP99Ms  float64 `json:"commit_p99_ms"`
+	CommitMaxMs  float64 `json:"commit_max_ms"`
+	EmitP95Ms    float64 `json:"emit_p95_ms"`
+	TargetP95Ms  float64 `json:"target_p95_ms"`
+	WithinTarget int64   `json:"within_target"`
+	Compliant    bool    `json:"compliant"`
+}
+
+// SLAReportResponse is the body of GET /admin/sla: every client's SLA compliance over a period
+type SLAReportResponse struct {
+	From        string        `json:"from"`
+	To          string        `json:"to"`
+	TargetP95Ms float64       `json:"target_p95_ms"`
+	Clients     []SLAResponse `json:"clients"`
+}
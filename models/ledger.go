@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Types of ledger events recorded by the event-sourced storage (STORAGE=eventsourced)
+//   - LedgerAccountOpened: AccountID, Balance (the initial balance), Currency, ExternalID and
+//     CreationToken
+//   - LedgerAccountStatusChanged: AccountID and Status
+//   - LedgerOverdraftChanged: AccountID and OverdraftLimit
+//   - LedgerAccountUpdated: AccountID, Metadata, Tags and ExternalID as they are after the update
+//   - LedgerAccountAdjusted: AccountID and Adjustment, as booked
+//   - LedgerWalletOpened: AccountID and Wallet, as opened
+//   - LedgerTransferCommitted: Transaction, as committed
+//   - LedgerTransactionSettled: TransactionID
+//   - LedgerTransactionReturned: TransactionID of the returned transfer; its reversal is the
+//     LedgerTransferCommitted event recorded just before
+const (
+	LedgerAccountOpened        = "account.opened"
+	LedgerAccountStatusChanged = "account.status_changed"
+	LedgerOverdraftChanged     = "account.overdraft_changed"
+	LedgerAccountUpdated       = "account.updated"
+	LedgerAccountAdjusted      = "account.adjusted"
+	LedgerWalletOpened         = "account.wallet_opened"
+	LedgerTransferCommitted    = "transfer.committed"
+	LedgerTransactionSettled   = "transaction.settled"
+	LedgerTransactionReturned  = "transaction.returned"
+)
+
+// LedgerEvent is a fact recorded in the event-sourced ledger
+// Events are numbered by Sequence, gap-free from 1 in the order they happened; replaying them in
+// that order rebuilds every account and transaction. Only the fields of the event's Type are set
+type LedgerEvent struct {
+	Sequence   int64     `json:"sequence"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+
+	AccountID      int64            `json:"account_id,omitempty"`
+	Balance        *decimal.Decimal `json:"balance,omitempty"`
+	Currency       string           `json:"currency,omitempty"`
+	ExternalID     string           `json:"external_id,omitempty"`
+	CreationToken  string           `json:"creation_token,omitempty"`
+	Status         string           `json:"status,omitempty"`
+	OverdraftLimit *decimal.Decimal `json:"overdraft_limit,omitempty"`
+	Metadata       Metadata         `json:"metadata,omitempty"`
+	Tags           []string         `json:"tags,omitempty"`
+	Adjustment     *Adjustment      `json:"adjustment,omitempty"`
+	Wallet         *Wallet          `json:"wallet,omitempty"`
+
+	Transaction   *Transaction `json:"transaction,omitempty"`
+	TransactionID int64        `json:"transaction_id,omitempty"`
+}
+
+// AccountIDs returns the accounts the event concerns: the account of an account event, both
+// sides of a transfer, none for the settlement events (they do not move balances)
+func (e LedgerEvent) AccountIDs() []int64 {
+	switch {
+	case e.Transaction != nil:
+		return []int64{e.Transaction.SourceAccountID, e.Transaction.DestinationAccountID}
+	case e.AccountID != 0:
+		return []int64{e.AccountID}
+	}
+	return nil
+}
+
+// AccountEvent is an event of an account's stream with the account's balance after it, folded
+// from the account's events
+type AccountEvent struct {
+	Event   LedgerEvent
+	Balance decimal.Decimal
+}
+
+// AccountEventResponse is an event in an account's event stream with the account's balance
+// folded from its events up to and including this one
+type AccountEventResponse struct {
+	Sequence       int64                `json:"sequence"`
+	Type           string               `json:"type"`
+	OccurredAt     time.Time            `json:"occurred_at"`
+	Balance        string               `json:"balance"`
+	Status         string               `json:"status,omitempty"`
+	OverdraftLimit string               `json:"overdraft_limit,omitempty"`
+	Metadata       Metadata             `json:"metadata,omitempty"`
+	Tags           []string             `json:"tags,omitempty"`
+	Adjustment     *AdjustmentResponse  `json:"adjustment,omitempty"`
+	Wallet         *WalletResponse      `json:"wallet,omitempty"`
+	Transaction    *TransactionResponse `json:"transaction,omitempty"`
+}
+
+// NewAccountEventResponse converts an account's event into its API representation
+func NewAccountEventResponse(e AccountEvent) AccountEventResponse {
+	response := AccountEventResponse{
+		Sequence:   e.Event.Sequence,
+		Type:       e.Event.Type,
+		OccurredAt: e.Event.OccurredAt,
+		Balance:    e.Balance.String(),
+		Status:     e.Event.Status,
+		Metadata:   e.Event.Metadata,
+		Tags:       e.Event.Tags,
+	}
+	if e.Event.OverdraftLimit != nil {
+		response.OverdraftLimit = e.Event.OverdraftLimit.String()
+	}
+	if e.Event.Adjustment != nil {
+		adjustment := NewAdjustmentResponse(*e.Event.Adjustment)
+		response.Adjustment = &adjustment
+	}
+	if e.Event.Wallet != nil {
+		wallet := NewWalletResponse(*e.Event.Wallet)
+		response.Wallet = &wallet
+	}
+	if e.Event.Transaction != nil {
+		transaction := NewTransactionResponse(*e.Event.Transaction)
+		response.Transaction = &transaction
+	}
+	return response
+}
+
+// AccountEventListResponse is the body of GET /accounts/{account_id}/events
+// HasMore is true while events after LastSequence remain; NextCursor then continues the stream
+type AccountEventListResponse struct {
+	AccountID    int64                  `json:"account_id"`
+	AfterSeq     int64                  `json:"after_seq"`
+	LastSequence int64                  `json:"last_sequence"`
+	HasMore      bool                   `json:"has_more"`
+	NextCursor   string                 `json:"next_cursor,omitempty"`
+	Events       []AccountEventResponse `json:"events"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TransferLimits caps the transfers debiting an account (see the limits package)
+// A nil field is not limited. Daily limits count the transfers debited since the start of the
+// current UTC day (LimitDay), and the transfer being made
+type TransferLimits struct {
+	AccountID int64 `json:"account_id" db:"account_id"`
+	// MaxAmount caps a single transfer
+	MaxAmount *decimal.Decimal `json:"max_amount" db:"max_amount"`
+	// DailyAmount caps the total debited per day
+	DailyAmount *decimal.Decimal `json:"daily_amount" db:"daily_amount"`
+	// DailyCount caps the number of debits per day
+	DailyCount *int `json:"daily_count" db:"daily_count"`
+}
+
+// LimitDay returns the start of the UTC day containing t, from which daily limits are counted
+func LimitDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Limited reports whether any limit is set
+func (l TransferLimits) Limited() bool {
+	return l.MaxAmount != nil || l.DailyAmount != nil || l.DailyCount != nil
+}
+
+// Daily reports whether a daily limit is set, so today's usage is needed to check a transfer
+func (l TransferLimits) Daily() bool {
+	return l.DailyAmount != nil || l.DailyCount != nil
+}
+
+// Check tests a debit of amount against the limits, given the amount and number of debits made
+// earlier the same day
+// Returns the repositories' "transfer amount limit exceeded", "daily amount limit exceeded" or
+// "daily count limit exceeded" error, or nil if the debit is within every limit
+func (l TransferLimits) Check(amount, usedAmount decimal.Decimal, usedCount int) error {
+	if l.MaxAmount != nil && amount.GreaterThan(*l.MaxAmount) {
+		return errors.New("transfer amount limit exceeded")
+	}
+	if l.DailyAmount != nil && usedAmount.Add(amount).GreaterThan(*l.DailyAmount) {
+		return errors.New("daily amount limit exceeded")
+	}
+	if l.DailyCount != nil && usedCount+1 > *l.DailyCount {
+		return errors.New("daily count limit exceeded")
+	}
+	return nil
+}
+
+// SetTransferLimitsRequest represents the request body for PUT /admin/accounts/{account_id}/limits
+// The request replaces all of the account's limits; an omitted or null field removes that limit
+type SetTransferLimitsRequest struct {
+	MaxAmount   *string `json:"max_amount"`
+	DailyAmount *string `json:"daily_amount"`
+	DailyCount  *int    `json:"daily_count"`
+}
+
+// LimitUsage is what an account has debited so far on a day
+type LimitUsage struct {
+	Day    string `json:"day"`
+	Amount string `json:"amount"`
+	Count  int    `json:"count"`
+}
+
+// TransferLimitsResponse is the body of the limit endpoints: the limits (null when unset) and the
+// current day's usage
+type TransferLimitsResponse struct {
+	AccountID   int64      `json:"account_id"`
+	MaxAmount   *string    `json:"max_amount"`
+	DailyAmount *string    `json:"daily_amount"`
+	DailyCount  *int       `json:"daily_count"`
+	Usage       LimitUsage `json:"usage"`
+}
+
+// NewTransferLimitsResponse converts limits and the usage of day into their API representation
+func NewTransferLimitsResponse(l TransferLimits, day time.Time, usedAmount decimal.Decimal, usedCount int) TransferLimitsResponse {
+	response := TransferLimitsResponse{
+		AccountID:  l.AccountID,
+		DailyCount: l.DailyCount,
+		Usage:      LimitUsage{Day: day.Format("2006-01-02"), Amount: usedAmount.String(), Count: usedCount},
+	}
+	if l.MaxAmount != nil {
+		value := l.MaxAmount.String()
+		response.MaxAmount = &value
+	}
+	if l.DailyAmount != nil {
+		value := l.DailyAmount.String()
+		response.DailyAmount = &value
+	}
+	return response
+}
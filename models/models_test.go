@@ -1,9 +1,15 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
+
+	"internal-transfers/validation"
 )
 
 func TestAccountModel(t *testing.T) {
@@ -37,6 +43,26 @@ func TestCreateAccountRequest(t *testing.T) {
 	}
 }
 
+func TestCreateAccountRequest_Validate(t *testing.T) {
+	balance, currency, err := CreateAccountRequest{AccountID: 1, InitialBalance: "10.50", Currency: " usd "}.Validate(nil)
+	if err != nil || !balance.Equal(decimal.RequireFromString("10.5")) || currency != "USD" {
+		t.Errorf("Expected 10.5 USD, got %s %q (%v)", balance, currency, err)
+	}
+
+	_, _, err = CreateAccountRequest{InitialBalance: "1.005", Currency: "USD", ExternalID: "ERP 1", CreationToken: "ok-1"}.Validate(nil)
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+	fields := make([]string, len(errs))
+	for i, violation := range errs {
+		fields[i] = violation.Field
+	}
+	if strings.Join(fields, ",") != "account_id,initial_balance,external_id" {
+		t.Errorf("Expected a violation per invalid field, got %v", errs)
+	}
+}
+
 func TestAccountResponse(t *testing.T) {
 	resp := AccountResponse{
 		AccountID: 789,
@@ -52,6 +78,148 @@ func TestAccountResponse(t *testing.T) {
 	}
 }
 
+func TestUpdateAccountRequest_Validate(t *testing.T) {
+	tags := []string{"payroll", "cost:CC-42", "payroll"}
+	update, err := UpdateAccountRequest{Metadata: map[string]interface{}{"name": "Ops", "old": nil}, Tags: &tags}.Validate()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if update.SetMetadata["name"] != "Ops" || len(update.RemoveMetadata) != 1 || update.RemoveMetadata[0] != "old" {
+		t.Errorf("Unexpected metadata change %+v", update)
+	}
+	if len(update.Tags) != 2 || update.Tags[1] != "cost:CC-42" {
+		t.Errorf("Expected deduplicated tags, got %v", update.Tags)
+	}
+
+	invalidID, longID, noID := "ERP/4711", strings.Repeat("x", MaxExternalIDLength+1), ""
+	tooMany := make([]string, MaxTags+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("t%d", i)
+	}
+	for name, req := range map[string]UpdateAccountRequest{
+		"empty":               {},
+		"invalid tag":         {Tags: &[]string{"has space"}},
+		"too many tags":       {Tags: &tooMany},
+		"large metadata":      {Metadata: map[string]interface{}{"blob": strings.Repeat("x", MaxMetadataBytes)}},
+		"invalid external ID": {ExternalID: &invalidID},
+		"long external ID":    {ExternalID: &longID},
+	} {
+		if _, err := req.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	// Clearing tags and the external ID is allowed
+	if update, err := (UpdateAccountRequest{Tags: &[]string{}}).Validate(); err != nil || update.Tags == nil {
+		t.Errorf("Expected empty tag list, got %v (%v)", update.Tags, err)
+	}
+	if update, err := (UpdateAccountRequest{ExternalID: &noID}).Validate(); err != nil || update.ExternalID == nil || *update.ExternalID != "" {
+		t.Errorf("Expected the external ID to be removed, got %+v (%v)", update, err)
+	}
+}
+
+func TestAccountFilter_Matches(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	account := Account{AccountID: 1, Balance: decimal.NewFromInt(100), Status: AccountActive, Tags: []string{"eu"}, CreatedAt: created}
+	low, high := decimal.NewFromInt(100), decimal.NewFromInt(99)
+
+	testCases := []struct {
+		name   string
+		filter AccountFilter
+		want   bool
+	}{
+		{"Empty", AccountFilter{}, true},
+		{"Tag", AccountFilter{Tag: "eu"}, true},
+		{"Other tag", AccountFilter{Tag: "us"}, false},
+		{"Status", AccountFilter{Status: AccountActive}, true},
+		{"Other status", AccountFilter{Status: AccountFrozen}, false},
+		{"Inclusive minimum", AccountFilter{MinBalance: &low}, true},
+		{"Minimum with tag", AccountFilter{MinBalance: &low, Tag: "eu"}, true},
+		{"Above maximum", AccountFilter{MaxBalance: &high}, false},
+		{"Inclusive created from", AccountFilter{CreatedFrom: created}, true},
+		{"Created before from", AccountFilter{CreatedFrom: created.Add(time.Second)}, false},
+		{"Exclusive created before", AccountFilter{CreatedBefore: created}, false},
+		{"Created before", AccountFilter{CreatedBefore: created.Add(time.Second), Status: AccountActive}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.Matches(account); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCurrencies_Fits(t *testing.T) {
+	testCases := []struct {
+		currency string
+		amount   string
+		want     bool
+	}{
+		{"USD", "10.25", true},
+		{"USD", "10.255", false},
+		{"JPY", "1000", true},
+		{"JPY", "1000.5", false},
+		{"KWD", "1.125", true},
+		{"", "1.12345", true},
+		{"", "1.123456", false},
+		{"XXX", "1", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.currency+" "+tc.amount, func(t *testing.T) {
+			if got := (*Currencies)(nil).Fits(tc.currency, decimal.RequireFromString(tc.amount)); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if NormalizeCurrency(" eur ") != "EUR" {
+		t.Error("Expected currency codes to be normalized to upper case")
+	}
+}
+
+func TestCurrencies(t *testing.T) {
+	minimum, maximum := decimal.NewFromInt(1), decimal.NewFromInt(1000)
+	currencies := NewCurrencies([]Currency{
+		{Code: "USD", Scale: 3, MinAmount: &minimum, MaxAmount: &maximum},
+		{Code: "XTS", Scale: 0, AllowNegative: true},
+	})
+
+	if !currencies.Fits("USD", decimal.RequireFromString("1.125")) || currencies.Fits("EUR", decimal.NewFromInt(1)) {
+		t.Error("Expected the set's currencies to replace the built-in ones")
+	}
+	if (*Currencies)(nil).Fits("USD", decimal.RequireFromString("1.125")) || !(*Currencies)(nil).Fits("EUR", decimal.NewFromInt(1)) {
+		t.Error("Expected the nil set to keep the built-in currencies")
+	}
+	if scale, ok := currencies.Scale("XTS"); !ok || scale != 0 {
+		t.Errorf("Expected XTS with scale 0, got %d, %v", scale, ok)
+	}
+	if currencies.AllowsNegative("USD") || !currencies.AllowsNegative("XTS") || !currencies.AllowsNegative("") {
+		t.Error("Expected only USD to disallow negative balances")
+	}
+	if _, _, err := (CreateAccountRequest{AccountID: 1, InitialBalance: "1", Currency: "EUR"}).Validate(currencies); err == nil {
+		t.Error("Expected a currency outside the set to be refused")
+	}
+
+	for _, tc := range []struct {
+		transfer Transfer
+		want     string
+	}{
+		{Transfer{Amount: decimal.RequireFromString("0.5")}, "amount below currency minimum"},
+		{Transfer{Amount: decimal.NewFromInt(1001)}, "amount above currency maximum"},
+		{Transfer{Amount: decimal.NewFromInt(1001), ReturnOf: 7}, ""},
+		{Transfer{Amount: decimal.NewFromInt(1000)}, ""},
+	} {
+		tc.transfer.Currencies = currencies
+		err := tc.transfer.CheckCurrencies("USD", "USD")
+		if (err == nil && tc.want != "") || (err != nil && err.Error() != tc.want) {
+			t.Errorf("%s (return of %d): expected %q, got %v", tc.transfer.Amount, tc.transfer.ReturnOf, tc.want, err)
+		}
+	}
+}
+
 func TestCreateTransactionRequest(t *testing.T) {
 	req := CreateTransactionRequest{
 		SourceAccountID:      123,
@@ -71,3 +239,153 @@ func TestCreateTransactionRequest(t *testing.T) {
 		t.Errorf("Expected Amount '100.50', got '%s'", req.Amount)
 	}
 }
+
+func TestCreateTransactionRequest_Validate(t *testing.T) {
+	amount, err := CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "0.5"}.Validate()
+	if err != nil || !amount.Equal(decimal.RequireFromString("0.5")) {
+		t.Errorf("Expected amount 0.5, got %s (%v)", amount, err)
+	}
+
+	testCases := []struct {
+		req  CreateTransactionRequest
+		want string
+	}{
+		{CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 1, Amount: "1"}, "Destination account ID must differ from source account ID"},
+		{CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", Reference: " INV-1"}, "Reference must not contain surrounding whitespace or control characters"},
+		{CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", DependsOn: -1}, "Dependency transaction ID must be positive"},
+		{CreateTransactionRequest{Amount: "abc"}, "Source account ID must be positive; Destination account ID must be positive; Invalid amount format"},
+	}
+	for _, tc := range testCases {
+		if _, err := tc.req.Validate(); err == nil || err.Error() != tc.want {
+			t.Errorf("%+v: expected %q, got %v", tc.req, tc.want, err)
+		}
+	}
+}
+
+func TestTransaction_CheckDependency(t *testing.T) {
+	for status, want := range map[string]string{
+		TransactionPending:   "dependency pending",
+		TransactionCompleted: "",
+		TransactionFailed:    "dependency failed",
+		TransactionReversed:  "dependency failed",
+	} {
+		err := Transaction{Status: status}.CheckDependency()
+		if (want == "" && err != nil) || (want != "" && (err == nil || err.Error() != want)) {
+			t.Errorf("%s: expected %q, got %v", status, want, err)
+		}
+	}
+}
+
+func TestTransactionFilter_Matches(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// Converted: account 1 was debited 100, account 2 credited 90
+	transaction := Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(100),
+		DestinationAmount: decimal.NewFromInt(90), CreatedAt: created}
+	ninety, hundred := decimal.NewFromInt(90), decimal.NewFromInt(100)
+
+	testCases := []struct {
+		name   string
+		filter TransactionFilter
+		want   bool
+	}{
+		{"Source", TransactionFilter{AccountID: 1}, true},
+		{"Destination", TransactionFilter{AccountID: 2}, true},
+		{"Other account", TransactionFilter{AccountID: 3}, false},
+		{"Outgoing", TransactionFilter{AccountID: 1, Direction: TransactionOutgoing}, true},
+		{"Not incoming", TransactionFilter{AccountID: 1, Direction: TransactionIncoming}, false},
+		{"Incoming", TransactionFilter{AccountID: 2, Direction: TransactionIncoming}, true},
+		{"Counterparty", TransactionFilter{AccountID: 2, CounterpartyID: 1}, true},
+		{"Other counterparty", TransactionFilter{AccountID: 1, CounterpartyID: 3}, false},
+		{"Inclusive minimum of the debit", TransactionFilter{AccountID: 1, MinAmount: &hundred}, true},
+		{"Below minimum of the credit", TransactionFilter{AccountID: 2, MinAmount: &hundred}, false},
+		{"Inclusive maximum of the credit", TransactionFilter{AccountID: 2, MaxAmount: &ninety}, true},
+		{"Above maximum of the debit", TransactionFilter{AccountID: 1, MaxAmount: &ninety}, false},
+		{"Inclusive created from", TransactionFilter{AccountID: 1, CreatedFrom: created}, true},
+		{"Created before from", TransactionFilter{AccountID: 1, CreatedFrom: created.Add(time.Second)}, false},
+		{"Exclusive created before", TransactionFilter{AccountID: 1, CreatedBefore: created}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.Matches(transaction); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTransactionSearch_Matches(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	transaction := Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(100),
+		Reference: "INV-1001", Memo: "Refund: March rent, flat 4", CreatedAt: created}
+	hundred, ninety := decimal.NewFromInt(100), decimal.NewFromInt(90)
+
+	testCases := []struct {
+		name   string
+		search TransactionSearch
+		want   bool
+	}{
+		{"Empty", TransactionSearch{}, true},
+		{"Destination in account set", TransactionSearch{AccountIDs: []int64{5, 2}}, true},
+		{"Neither account", TransactionSearch{AccountIDs: []int64{3, 4}}, false},
+		{"One of the references", TransactionSearch{References: []string{"INV-1000", "INV-1001"}}, true},
+		{"Other references", TransactionSearch{References: []string{"inv-1001"}}, false},
+		{"Words in any order and case", TransactionSearch{Text: "rent REFUND"}, true},
+		{"Punctuation ignored", TransactionSearch{Text: "march, flat-4"}, true},
+		{"Missing word", TransactionSearch{Text: "march deposit"}, false},
+		{"Whole words only", TransactionSearch{Text: "ren"}, false},
+		{"Inclusive minimum", TransactionSearch{MinAmount: &hundred}, true},
+		{"Above maximum", TransactionSearch{MaxAmount: &ninety}, false},
+		{"Exclusive created before", TransactionSearch{CreatedBefore: created}, false},
+		{"Every condition", TransactionSearch{AccountIDs: []int64{1}, References: []string{"INV-1001"}, Text: "rent", MinAmount: &ninety, CreatedFrom: created}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.search.Matches(transaction); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+	if !(TransactionSearch{Text: " ,. "}).IsEmpty() || (TransactionSearch{MaxAmount: &ninety}).IsEmpty() {
+		t.Error("Expected only a search without conditions to be empty")
+	}
+}
+
+func TestRoundingPolicy_Round(t *testing.T) {
+	testCases := []struct {
+		policy RoundingPolicy
+		amount string
+		want   string
+	}{
+		{RoundHalfEven, "1.000025", "1.00002"},
+		{RoundHalfEven, "1.000035", "1.00004"},
+		{RoundHalfUp, "1.000025", "1.00003"},
+		{RoundHalfUp, "1.000024", "1.00002"},
+		{RoundTruncate, "1.000029", "1.00002"},
+		{RoundTruncate, "1.5", "1.5"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.policy)+"/"+tc.amount, func(t *testing.T) {
+			got := tc.policy.RoundAmount(decimal.RequireFromString(tc.amount))
+			if !got.Equal(decimal.RequireFromString(tc.want)) {
+				t.Errorf("Expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseRoundingPolicy(t *testing.T) {
+	if p, err := ParseRoundingPolicy(""); err != nil || p != DefaultRoundingPolicy {
+		t.Errorf("Expected default policy for empty value, got %q, %v", p, err)
+	}
+	for _, value := range []string{"half_even", "half_up", "truncate"} {
+		if p, err := ParseRoundingPolicy(value); err != nil || string(p) != value {
+			t.Errorf("Expected %q to parse, got %q, %v", value, p, err)
+		}
+	}
+	if _, err := ParseRoundingPolicy("ceiling"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BalanceDiscrepancy is an account whose stored balance differs from the balance recomputed from
+// its initial balance and every transaction that debited or credited it
+type BalanceDiscrepancy struct {
+	AccountID       int64
+	InitialBalance  decimal.Decimal
+	StoredBalance   decimal.Decimal
+	ComputedBalance decimal.Decimal
+	// Transactions counts the account's movements: transfers it sent or received, and manual
+	// adjustments
+	Transactions int64
+}
+
+// Difference is the stored balance minus the computed one; positive when the account holds more
+// than its transactions explain
+func (d BalanceDiscrepancy) Difference() decimal.Decimal {
+	return d.StoredBalance.Sub(d.ComputedBalance)
+}
+
+// ReconciliationReport is the result of one reconciliation run
+type ReconciliationReport struct {
+	CheckedAt     time.Time
+	Accounts      int64
+	Discrepancies []BalanceDiscrepancy
+}
+
+// BalanceDiscrepancyResponse is the API representation of a BalanceDiscrepancy
+type BalanceDiscrepancyResponse struct {
+	AccountID       int64  `json:"account_id"`
+	InitialBalance  string `json:"initial_balance"`
+	StoredBalance   string `json:"stored_balance"`
+	ComputedBalance string `json:"computed_balance"`
+	Difference      string `json:"difference"`
+	Transactions    int64  `json:"transactions"`
+}
+
+// ReconciliationResponse is the body of GET /admin/reconciliation
+// Balanced is true when no account has a discrepancy
+type ReconciliationResponse struct {
+	CheckedAt     time.Time                    `json:"checked_at"`
+	Accounts      int64                        `json:"accounts"`
+	Balanced      bool                         `json:"balanced"`
+	Discrepancies []BalanceDiscrepancyResponse `json:"discrepancies"`
+}
+
+// NewReconciliationResponse converts a reconciliation report into its API representation
+func NewReconciliationResponse(r ReconciliationReport) ReconciliationResponse {
+	response := ReconciliationResponse{
+		CheckedAt:     r.CheckedAt,
+		Accounts:      r.Accounts,
+		Balanced:      len(r.Discrepancies) == 0,
+		Discrepancies: make([]BalanceDiscrepancyResponse, 0, len(r.Discrepancies)),
+	}
+	for _, d := range r.Discrepancies {
+		response.Discrepancies = append(response.Discrepancies, BalanceDiscrepancyResponse{
+			AccountID:       d.AccountID,
+			InitialBalance:  d.InitialBalance.String(),
+			StoredBalance:   d.StoredBalance.String(),
+			ComputedBalance: d.ComputedBalance.String(),
+			Difference:      d.Difference().String(),
+			Transactions:    d.Transactions,
+		})
+	}
+	return response
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Recurring transfer statuses; only active rules are executed
+const (
+	RecurringActive = "active"
+	RecurringPaused = "paused"
+)
+
+// Recurring execution outcomes; a retrying execution failed for a transient reason and is queued
+// to be attempted again at its RetryAt, while a failed one is final
+const (
+	ExecutionSucceeded = "succeeded"
+	ExecutionFailed    = "failed"
+	ExecutionRetrying  = "retrying"
+)
+
+// RecurringTransfer is a rule that books the same transfer on a schedule (see the recurring package)
+// Schedule is a cron expression or an @every interval; NextRunAt is when the rule is next due
+// Executions evaluate the validation rules of the tenant that created the rule
+type RecurringTransfer struct {
+	ID                   int64           `json:"id" db:"id"`
+	SourceAccountID      int64           `json:"source_account_id" db:"source_account_id"`
+	DestinationAccountID int64           `json:"destination_account_id" db:"destination_account_id"`
+	Amount               decimal.Decimal `json:"amount" db:"amount"`
+	TransferType         string          `json:"transfer_type" db:"transfer_type"`
+	Tenant               string          `json:"tenant" db:"tenant"`
+	Schedule             string          `json:"schedule" db:"schedule"`
+	Status               string          `json:"status" db:"status"`
+	NextRunAt            time.Time       `json:"next_run_at" db:"next_run_at"`
+	CreatedAt            time.Time       `json:"created_at" db:"created_at"`
+}
+
+// TransferRequest returns the transfer request an execution of the rule submits
+func (r RecurringTransfer) TransferRequest() CreateTransactionRequest {
+	return CreateTransactionRequest{
+		SourceAccountID:      r.SourceAccountID,
+		DestinationAccountID: r.DestinationAccountID,
+		Amount:               r.Amount.String(),
+		TransferType:         r.TransferType,
+		Tenant:               r.Tenant,
+	}
+}
+
+// RecurringExecution records one attempt of a run of a recurring transfer
+// TransactionID is set when the transfer was booked, Error when it was refused. Attempt counts
+// the attempts of the run scheduled at ScheduledAt, from 1; RetryAt is set while a retrying
+// execution is queued, and cleared once its retry is taken
+type RecurringExecution struct {
+	ID                  int64      `json:"id" db:"id"`
+	RecurringTransferID int64      `json:"recurring_transfer_id" db:"recurring_transfer_id"`
+	ScheduledAt         time.Time  `json:"scheduled_at" db:"scheduled_at"`
+	ExecutedAt          time.Time  `json:"executed_at" db:"executed_at"`
+	Status              string     `json:"status" db:"status"`
+	TransactionID       int64      `json:"transaction_id,omitempty" db:"transaction_id"`
+	Error               string     `json:"error,omitempty" db:"error"`
+	Attempt             int        `json:"attempt" db:"attempt"`
+	RetryAt             *time.Time `json:"retry_at,omitempty" db:"retry_at"`
+}
+
+// RecurringEscalation is the payload of the event escalating a run of a recurring transfer that
+// failed for good: its last attempt and the rule as it was then
+type RecurringEscalation struct {
+	RecurringTransfer RecurringTransferResponse `json:"recurring_transfer"`
+	Execution         RecurringExecution        `json:"execution"`
+}
+
+// CreateRecurringTransferRequest represents the request body for creating a recurring transfer
+// Schedule is a five-field cron expression (minute hour day-of-month month day-of-week, UTC), one of
+// @hourly, @daily, @weekly and @monthly, or "@every <duration>"; StartAt optionally delays the first
+// run, which otherwise is the schedule's next time after creation
+type CreateRecurringTransferRequest struct {
+	SourceAccountID      int64      `json:"source_account_id"`
+	DestinationAccountID int64      `json:"destination_account_id"`
+	Amount               string     `json:"amount"`
+	TransferType         string     `json:"transfer_type,omitempty"`
+	Schedule             string     `json:"schedule"`
+	StartAt              *time.Time `json:"start_at,omitempty"`
+	// Tenant is taken from the X-Tenant-ID header; executions evaluate that tenant's rules
+	Tenant string `json:"-"`
+}
+
+// RecurringTransferResponse represents a recurring transfer in API responses
+type RecurringTransferResponse struct {
+	ID                   int64     `json:"id"`
+	SourceAccountID      int64     `json:"source_account_id"`
+	DestinationAccountID int64     `json:"destination_account_id"`
+	Amount               string    `json:"amount"`
+	TransferType         string    `json:"transfer_type"`
+	Schedule             string    `json:"schedule"`
+	Status               string    `json:"status"`
+	NextRunAt            time.Time `json:"next_run_at"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// NewRecurringTransferResponse converts a recurring transfer into its API representation
+func NewRecurringTransferResponse(r RecurringTransfer) RecurringTransferResponse {
+	return RecurringTransferResponse{
+		ID:                   r.ID,
+		SourceAccountID:      r.SourceAccountID,
+		DestinationAccountID: r.DestinationAccountID,
+		Amount:               r.Amount.String(),
+		TransferType:         r.TransferType,
+		Schedule:             r.Schedule,
+		Status:               r.Status,
+		NextRunAt:            r.NextRunAt,
+		CreatedAt:            r.CreatedAt,
+	}
+}
+
+// RecurringTransferListResponse is the body of GET /recurring-transfers
+// NextCursor continues the listing and is omitted on its last page
+type RecurringTransferListResponse struct {
+	RecurringTransfers []RecurringTransferResponse `json:"recurring_transfers"`
+	NextCursor         string                      `json:"next_cursor,omitempty"`
+}
+
+// RecurringExecutionListResponse is the body of GET /recurring-transfers/{id}/executions
+// NextCursor continues the listing and is omitted on its last page
+type RecurringExecutionListResponse struct {
+	RecurringTransferID int64                `json:"recurring_transfer_id"`
+	Executions          []RecurringExecution `json:"executions"`
+	NextCursor          string               `json:"next_cursor,omitempty"`
+}
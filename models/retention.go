@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Data classes governed by retention policies
+const (
+	// RetentionTransactions is the ledger of record; it is never purged
+	RetentionTransactions = "transactions"
+
+	// RetentionAuditEvents is the audit log (audit_events)
+	RetentionAuditEvents = "audit_events"
+
+	// RetentionOutboxEvents is the published events of the outbox (outbox_events); unpublished
+	// events are never purged
+	RetentionOutboxEvents = "outbox_events"
+)
+
+// RetentionPolicy is how long one data class is kept
+// A zero Retention keeps the class forever
+type RetentionPolicy struct {
+	Class     string
+	Retention time.Duration
+	// Enforceable is false for classes that are kept forever regardless of configuration
+	Enforceable bool
+	Note        string
+}
+
+// RetentionResult is one data class's part of a retention run
+// Cutoff is zero for classes kept forever; Eligible counts the rows older than the cutoff before
+// the run, Deleted those the run removed (always zero for a dry run)
+type RetentionResult struct {
+	Policy   RetentionPolicy
+	Cutoff   time.Time
+	Eligible int64
+	Deleted  int64
+}
+
+// RetentionReport is the result of a retention run over every data class
+type RetentionReport struct {
+	CheckedAt time.Time
+	DryRun    bool
+	Results   []RetentionResult
+}
+
+// RetentionPolicyResponse is the API representation of a RetentionResult
+// RetentionDays is 0 and Cutoff omitted for classes kept forever
+type RetentionPolicyResponse struct {
+	Class         string     `json:"class"`
+	RetentionDays int        `json:"retention_days"`
+	KeepForever   bool       `json:"keep_forever"`
+	Enforceable   bool       `json:"enforceable"`
+	Note          string     `json:"note,omitempty"`
+	Cutoff        *time.Time `json:"cutoff,omitempty"`
+	Eligible      int64      `json:"eligible"`
+	Deleted       int64      `json:"deleted"`
+}
+
+// RetentionReportResponse is the body of GET /admin/retention and of the retention command's
+// JSON output
+type RetentionReportResponse struct {
+	CheckedAt time.Time                 `json:"checked_at"`
+	DryRun    bool                      `json:"dry_run"`
+	Policies  []RetentionPolicyResponse `json:"policies"`
+}
+
+// NewRetentionReportResponse converts a retention report into its API representation
+func NewRetentionReportResponse(r RetentionReport) RetentionReportResponse {
+	response := RetentionReportResponse{CheckedAt: r.CheckedAt, DryRun: r.DryRun, Policies: make([]RetentionPolicyResponse, 0, len(r.Results))}
+	for _, result := range r.Results {
+		policy := RetentionPolicyResponse{
+			Class:         result.Policy.Class,
+			RetentionDays: int(result.Policy.Retention / (24 * time.Hour)),
+			KeepForever:   result.Policy.Retention == 0,
+			Enforceable:   result.Policy.Enforceable,
+			Note:          result.Policy.Note,
+			Eligible:      result.Eligible,
+			Deleted:       result.Deleted,
+		}
+		if !result.Cutoff.IsZero() {
+			cutoff := result.Cutoff
+			policy.Cutoff = &cutoff
+		}
+		response.Policies = append(response.Policies, policy)
+	}
+	return response
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// AmountScale is the number of decimal places stored for balances and amounts (DECIMAL(15,5))
+const AmountScale int32 = 5
+
+// RoundingPolicy determines how amounts with more precision than AmountScale are rounded
+// Every calculation that produces an amount (transfers today; fees, FX conversion and interest
+// accrual as they are added) must round through the configured policy rather than relying on
+// implicit rounding by the storage layer, and the policy is recorded on the resulting transaction
+type RoundingPolicy string
+
+const (
+	// RoundHalfEven rounds to the nearest value, ties to the even digit (banker's rounding)
+	RoundHalfEven RoundingPolicy = "half_even"
+	// RoundHalfUp rounds to the nearest value, ties away from zero
+	// This matches PostgreSQL's numeric rounding and is the default
+	RoundHalfUp RoundingPolicy = "half_up"
+	// RoundTruncate drops digits beyond the scale (rounds toward zero)
+	RoundTruncate RoundingPolicy = "truncate"
+)
+
+// DefaultRoundingPolicy is used when no policy is configured
+const DefaultRoundingPolicy = RoundHalfUp
+
+// ParseRoundingPolicy converts a configuration value into a RoundingPolicy
+// An empty value selects DefaultRoundingPolicy
+func ParseRoundingPolicy(value string) (RoundingPolicy, error) {
+	switch policy := RoundingPolicy(value); policy {
+	case "":
+		return DefaultRoundingPolicy, nil
+	case RoundHalfEven, RoundHalfUp, RoundTruncate:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown rounding policy %q (expected half_even, half_up or truncate)", value)
+	}
+}
+
+// Round rounds d to places decimal places according to the policy
+func (p RoundingPolicy) Round(d decimal.Decimal, places int32) decimal.Decimal {
+	switch p {
+	case RoundHalfEven:
+		return d.RoundBank(places)
+	case RoundTruncate:
+		return d.Truncate(places)
+	default:
+		return d.Round(places)
+	}
+}
+
+// RoundAmount rounds d to the stored AmountScale according to the policy
+func (p RoundingPolicy) RoundAmount(d decimal.Decimal) decimal.Decimal {
+	return p.Round(d, AmountScale)
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Settlement file generation statuses
+const (
+	SettlementFileGenerated = "generated"
+	SettlementFileFailed    = "failed"
+)
+
+// SettlementFile records the outcome of generating one partner's settlement file for a business date
+// There is at most one record per partner and date; regenerating replaces it
+type SettlementFile struct {
+	ID            int64     `json:"id" db:"id"`
+	PartnerID     string    `json:"partner_id" db:"partner_id"`
+	BusinessDate  time.Time `json:"business_date" db:"business_date"`
+	FileName      string    `json:"file_name" db:"file_name"`
+	Format        string    `json:"format" db:"format"`
+	Status        string    `json:"status" db:"status"`
+	TransferCount int       `json:"transfer_count" db:"transfer_count"`
+	Error         string    `json:"error" db:"error"`
+	GeneratedAt   time.Time `json:"generated_at" db:"generated_at"`
+}
+
+// SettlementFileResponse represents a settlement file record in API responses
+// Error is only set for failed generations
+type SettlementFileResponse struct {
+	ID            int64     `json:"id"`
+	PartnerID     string    `json:"partner_id"`
+	BusinessDate  string    `json:"business_date"`
+	FileName      string    `json:"file_name"`
+	Format        string    `json:"format"`
+	Status        string    `json:"status"`
+	TransferCount int       `json:"transfer_count"`
+	Error         string    `json:"error,omitempty"`
+	GeneratedAt   time.Time `json:"generated_at"`
+}
+
+// SettlementFileListResponse is the body of GET /settlements/files
+// NextCursor continues the listing and is omitted on its last page
+type SettlementFileListResponse struct {
+	Files      []SettlementFileResponse `json:"files"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// SettlementFileKey is the position of a record in settlement file listings, which are ordered by
+// business date descending, then partner ID; the zero key is before the first record
+type SettlementFileKey struct {
+	BusinessDate time.Time
+	PartnerID    string
+}
+
+// Key returns the record's position in settlement file listings
+func (f SettlementFile) Key() SettlementFileKey {
+	return SettlementFileKey{BusinessDate: f.BusinessDate, PartnerID: f.PartnerID}
+}
+
+// After reports whether a record at key k is listed after one at other
+func (k SettlementFileKey) After(other SettlementFileKey) bool {
+	if !k.BusinessDate.Equal(other.BusinessDate) {
+		return k.BusinessDate.Before(other.BusinessDate)
+	}
+	return k.PartnerID > other.PartnerID
+}
+
+// NewSettlementFileResponse converts a settlement file record into its API representation
+func NewSettlementFileResponse(f SettlementFile) SettlementFileResponse {
+	return SettlementFileResponse{
+		ID:            f.ID,
+		PartnerID:     f.PartnerID,
+		BusinessDate:  f.BusinessDate.Format("2006-01-02"),
+		FileName:      f.FileName,
+		Format:        f.Format,
+		Status:        f.Status,
+		TransferCount: f.TransferCount,
+		Error:         f.Error,
+		GeneratedAt:   f.GeneratedAt,
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Statement is an account's statement for a period: the balance it opened with, every
+// transaction effective in the period with the running balance, and the balance it closed with
+// The period is [From, To) in effective time, as the ledger stood at GeneratedAt
+type Statement struct {
+	AccountID int64
+	Currency  string
+	// Scale is the number of decimal places of Currency, which amounts are rendered with; amounts
+	// of accounts without a currency keep their own
+	Scale          int32
+	From           time.Time
+	To             time.Time
+	GeneratedAt    time.Time
+	OpeningBalance decimal.Decimal
+	ClosingBalance decimal.Decimal
+	// TotalDebits and TotalCredits sum the lines' debits and credits, both positive
+	TotalDebits  decimal.Decimal
+	TotalCredits decimal.Decimal
+	Lines        []StatementLine
+}
+
+// StatementLine is one transaction or manual adjustment on a statement
+// Exactly one of TransactionID and AdjustmentID is set; adjustments have no counterparty
+type StatementLine struct {
+	TransactionID  int64
+	AdjustmentID   int64
+	EffectiveAt    time.Time
+	ValueDate      time.Time
+	CounterpartyID int64
+	Reference      string
+	Memo           string
+	// Amount is the signed movement on the account, negative for debits
+	Amount decimal.Decimal
+	// Balance is the running balance after the transaction
+	Balance decimal.Decimal
+}
+
+// NewStatementLine describes a transaction on accountID's statement, which must be its source or
+// destination; Balance is left for the caller to fill in
+func NewStatementLine(t Transaction, accountID int64) StatementLine {
+	counterparty := t.DestinationAccountID
+	if t.DestinationAccountID == accountID {
+		counterparty = t.SourceAccountID
+	}
+	return StatementLine{
+		TransactionID:  t.ID,
+		EffectiveAt:    t.EffectiveAt,
+		ValueDate:      t.ValueDate,
+		CounterpartyID: counterparty,
+		Reference:      t.Reference,
+		Memo:           t.Memo,
+		Amount:         t.Movement(accountID),
+	}
+}
+
+// NewAdjustmentStatementLine describes a manual adjustment on its account's statement, with its
+// reason as the memo; Balance is left for the caller to fill in
+func NewAdjustmentStatementLine(a Adjustment) StatementLine {
+	return StatementLine{
+		AdjustmentID: a.ID,
+		EffectiveAt:  a.CreatedAt,
+		ValueDate:    a.CreatedAt.UTC().Truncate(24 * time.Hour),
+		Memo:         a.Reason,
+		Amount:       a.Amount,
+	}
+}
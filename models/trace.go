@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Sources of a transfer trace that a deployment may not record
+const (
+	TraceTimeline = "timeline"
+	TraceAudit    = "audit"
+	TraceEvents   = "events"
+)
+
+// TransferTimelineResponse is the processing timeline of a transfer, as recorded for SLA tracking
+// Durations are in milliseconds from ReceivedAt (see TransferLatency); LockWaitMs is the part of
+// the commit spent waiting for the accounts' locks. Stages is the time each stage took against its
+// current latency budget, and BudgetExceeded names the stages over budget
+type TransferTimelineResponse struct {
+	ClientID       string                `json:"client_id"`
+	ReceivedAt     time.Time             `json:"received_at"`
+	ValidateMs     float64               `json:"validate_ms"`
+	LockWaitMs     float64               `json:"lock_wait_ms"`
+	CommitMs       float64               `json:"commit_ms"`
+	EmitMs         float64               `json:"emit_ms"`
+	Stages         []StageBudgetResponse `json:"stages"`
+	BudgetExceeded []string              `json:"budget_exceeded"`
+	Rules          []string              `json:"rules"`
+	Retries        int                   `json:"retries"`
+}
+
+// StageBudgetResponse is the time one stage of a transfer took, in milliseconds, against its
+// budget; BudgetMs is omitted for a stage without a budget
+type StageBudgetResponse struct {
+	Stage    string  `json:"stage"`
+	Ms       float64 `json:"ms"`
+	BudgetMs float64 `json:"budget_ms,omitempty"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+// TransferEventResponse is an event the transfer's commit recorded in the outbox
+// PublishedAt is omitted while the event waits for the relay
+type TransferEventResponse struct {
+	EventID     string     `json:"event_id"`
+	EventType   string     `json:"event_type"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Published   bool       `json:"published"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// TransferTraceResponse is the body of GET /admin/transactions/{transaction_id}/trace: everything
+// recorded about one transfer, for incident triage
+// Timeline is null when the transfer was not booked through the API (e.g. reversals) or was
+// booked before timelines were recorded; Unavailable names the sources this deployment does not
+// record, whose fields are then empty
+type TransferTraceResponse struct {
+	Transaction TransactionResponse       `json:"transaction"`
+	Timeline    *TransferTimelineResponse `json:"timeline"`
+	Audit       []AuditEvent              `json:"audit"`
+	Events      []TransferEventResponse   `json:"events"`
+	Unavailable []string                  `json:"unavailable"`
+}
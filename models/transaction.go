@@ -1,23 +1,625 @@
 package models
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/shopspring/decimal"
+
+	"internal-transfers/validation"
 )
 
 // Transaction represents a money transfer between accounts
+// The ledger is bi-temporal: CreatedAt is the transaction time, when the movement was recorded,
+// and EffectiveAt the business time from which it counts. They are equal unless the transfer was
+// backdated, e.g. to restate a closed period
+// Status is the transaction's place in its lifecycle (TransactionCompleted for every transfer
+// booked synchronously); SettlementStatus tracks the partner's acknowledgment separately
+// SourceWallet and DestinationWallet are the currency of the wallet (see Wallet) the transaction
+// moved on each side, empty where it moved the account's primary balance
 type Transaction struct {
 	ID                   int64           `json:"id" db:"id"`
 	SourceAccountID      int64           `json:"source_account_id" db:"source_account_id"`
 	DestinationAccountID int64           `json:"destination_account_id" db:"destination_account_id"`
 	Amount               decimal.Decimal `json:"amount" db:"amount"`
+	SourceSequence       int64           `json:"source_sequence" db:"source_sequence"`
+	DestinationSequence  int64           `json:"destination_sequence" db:"destination_sequence"`
+	RoundingPolicy       RoundingPolicy  `json:"rounding_policy" db:"rounding_policy"`
+	TransferType         string          `json:"transfer_type" db:"transfer_type"`
+	ValueDate            time.Time       `json:"value_date" db:"value_date"`
+	Status               string          `json:"status" db:"status"`
+	SettlementStatus     string          `json:"settlement_status" db:"settlement_status"`
+	ReturnOf             int64           `json:"return_of,omitempty" db:"return_of"`
+	DestinationAmount    decimal.Decimal `json:"destination_amount" db:"destination_amount"`
+	FXRate               decimal.Decimal `json:"fx_rate" db:"fx_rate"`
+	FXRateTimestamp      time.Time       `json:"fx_rate_timestamp" db:"fx_rate_timestamp"`
 	CreatedAt            time.Time       `json:"created_at" db:"created_at"`
+	EffectiveAt          time.Time       `json:"effective_at" db:"effective_at"`
+	Reference            string          `json:"reference,omitempty" db:"reference"`
+	Memo                 string          `json:"memo,omitempty" db:"memo"`
+	DependsOn            int64           `json:"depends_on,omitempty" db:"depends_on"`
+	SourceWallet         string          `json:"source_wallet,omitempty" db:"source_wallet"`
+	DestinationWallet    string          `json:"destination_wallet,omitempty" db:"destination_wallet"`
+
+	// LockWait is how long booking the transaction waited for the accounts' locks; measured by
+	// CreateTransaction for the transfer trace and not stored with the transaction
+	LockWait time.Duration `json:"-" db:"-"`
+
+	// Timeline is the processing timeline of the transfer that booked the transaction, set by the
+	// transfer service when it records latencies; not stored with the transaction
+	Timeline *TransferLatency `json:"-" db:"-"`
+}
+
+// Movement returns the signed change the transaction made to accountID's balance, or to its
+// wallet (see Wallet): the debited amount negated if it is the source, otherwise the credited
+// amount
+func (t Transaction) Movement(accountID int64) decimal.Decimal {
+	if t.SourceAccountID == accountID {
+		return t.Amount.Neg()
+	}
+	return t.DestinationAmount
+}
+
+// Wallet returns the wallet the transaction moved on accountID, which must be its source or
+// destination: the wallet's currency, or "" for the account's primary balance
+func (t Transaction) Wallet(accountID int64) string {
+	if t.SourceAccountID == accountID {
+		return t.SourceWallet
+	}
+	return t.DestinationWallet
+}
+
+// WalletCurrency returns the currency of the wallets the transaction moved, "" if it only moved
+// primary balances
+func (t Transaction) WalletCurrency() string {
+	if t.SourceWallet != "" {
+		return t.SourceWallet
+	}
+	return t.DestinationWallet
+}
+
+// Counts reports whether the transaction is part of a balance as recorded at recordedAt and
+// effective at effectiveAt
+func (t Transaction) Counts(recordedAt, effectiveAt time.Time) bool {
+	return !t.CreatedAt.After(recordedAt) && !t.EffectiveAt.After(effectiveAt)
+}
+
+// Converted reports whether the transaction converted between currencies
+// DestinationAmount is the amount credited; it equals Amount unless the transaction converted
+func (t Transaction) Converted() bool {
+	return !t.FXRate.IsZero()
+}
+
+// Return returns the transfer reversing t after a partner returned it
+// The reversal moves exactly what t credited back to t's source, between the same wallets; for a
+// cross-currency transfer that is the converted amount, reconverted at the inverse of t's rate so
+// the source is restored to the amount it was debited
+func (t Transaction) Return(valueDate time.Time) Transfer {
+	transfer := Transfer{
+		SourceAccountID:      t.DestinationAccountID,
+		DestinationAccountID: t.SourceAccountID,
+		Amount:               t.Amount,
+		RoundingPolicy:       t.RoundingPolicy,
+		TransferType:         ReturnTransferType,
+		ValueDate:            valueDate,
+		ReturnOf:             t.ID,
+		Currency:             t.WalletCurrency(),
+	}
+	if t.Converted() {
+		transfer.Amount = t.DestinationAmount
+		transfer.DestinationAmount = t.Amount
+		transfer.FXRate = decimal.NewFromInt(1).DivRound(t.FXRate, FXRateScale)
+		transfer.FXRateTimestamp = t.FXRateTimestamp
+	}
+	return transfer
+}
+
+// FXRateScale is the number of decimal places stored for FX rates (DECIMAL(20,10))
+const FXRateScale int32 = 10
+
+// Lifecycle statuses of a transaction
+//   - TransactionPending: Recorded, waiting to be executed; no balance has moved yet
+//   - TransactionCompleted: Balances moved; the status of every transfer booked synchronously
+//   - TransactionFailed: Execution was refused; no balance moved
+//   - TransactionReversed: Completed, then reversed by a return transaction (see ReturnOf)
+const (
+	TransactionPending   = "pending"
+	TransactionCompleted = "completed"
+	TransactionFailed    = "failed"
+	TransactionReversed  = "reversed"
+)
+
+// CheckDependency checks that a transfer depending on t may be booked
+// Returns the repositories' "dependency pending" while t has not been executed, or "dependency
+// failed" if it failed or was reversed
+func (t Transaction) CheckDependency() error {
+	switch t.Status {
+	case TransactionPending:
+		return errors.New("dependency pending")
+	case TransactionFailed, TransactionReversed:
+		return errors.New("dependency failed")
+	}
+	return nil
+}
+
+// Settlement statuses of a transaction, updated from partner acknowledgment/return files
+const (
+	SettlementUnsettled = "unsettled"
+	SettlementSettled   = "settled"
+	SettlementReturned  = "returned"
+)
+
+// Transfer describes a validated money movement for a repository to commit
+// Amount must already be rounded with RoundingPolicy; ValueDate is a date (midnight UTC)
+// ReturnOf is set only for return transactions and names the transfer being reversed
+// A cross-currency transfer debits Amount in the source currency and credits DestinationAmount
+// (already rounded to the destination currency) in the destination currency, converted at FXRate
+// observed at FXRateTimestamp; same-currency transfers leave the three fields zero
+// EffectiveAt backdates the business effective time; zero means when the transfer is recorded
+// Reference and Memo are the client's optional annotations; a reference is unique per source account
+// DependsOn names a transaction that must have completed, and not been reversed, for the transfer
+// to be booked
+// Currency moves balances in that currency: on each side, the account's primary balance if it is
+// the account's currency, otherwise the account's wallet in it (see Wallet). Empty moves the
+// primary balances; a transfer naming a currency never converts
+// Currencies are the supported currencies CheckCurrencies checks against, like RoundingPolicy
+// set by the service preparing the transfer; nil checks against the built-in ones
+type Transfer struct {
+	SourceAccountID      int64
+	DestinationAccountID int64
+	Amount               decimal.Decimal
+	RoundingPolicy       RoundingPolicy
+	TransferType         string
+	ValueDate            time.Time
+	ReturnOf             int64
+	DestinationAmount    decimal.Decimal
+	FXRate               decimal.Decimal
+	FXRateTimestamp      time.Time
+	EffectiveAt          time.Time
+	// HoldID captures the active hold with that ID: its reservation is released in the same
+	// atomic write that books the transfer, which may not exceed the held amount
+	HoldID    int64
+	Reference string
+	Memo      string
+	DependsOn int64
+	// Currency, if set, moves each account's wallet in that currency instead of its primary
+	// balance, unless the currency is the account's own (see Wallet)
+	Currency   string
+	Currencies *Currencies
+}
+
+// Credit returns the amount credited to the destination account
+func (t Transfer) Credit() decimal.Decimal {
+	if t.FXRate.IsZero() {
+		return t.Amount
+	}
+	return t.DestinationAmount
+}
+
+// Wallet returns the wallet the transfer moves on an account of accountCurrency: "" for the
+// account's primary balance, or the transfer's currency if the account holds another one
+func (t Transfer) Wallet(accountCurrency string) string {
+	if t.Currency == "" || t.Currency == accountCurrency {
+		return ""
+	}
+	return t.Currency
+}
+
+// CheckCurrencies checks the transfer against the currencies of its accounts
+// A transfer naming a currency moves that currency on both sides, whatever the accounts' own
+// Returns the repositories' "currency mismatch" error for a same-currency transfer between
+// different currencies, "amount exceeds currency precision" when an amount has more decimal
+// places than its currency allows, or the error of Currencies.CheckTransferAmount when the amount
+// is out of the source currency's bounds
+// Reversals of returned transfers move amounts the ledger already booked, so only their
+// currencies are checked: their precision and bounds are those of the original transfer
+func (t Transfer) CheckCurrencies(sourceCurrency, destinationCurrency string) error {
+	if t.Currency != "" {
+		sourceCurrency, destinationCurrency = t.Currency, t.Currency
+	}
+	if t.FXRate.IsZero() && sourceCurrency != destinationCurrency {
+		return errors.New("currency mismatch")
+	}
+	if t.ReturnOf != 0 {
+		return nil
+	}
+	if !t.Currencies.Fits(sourceCurrency, t.Amount) || !t.Currencies.Fits(destinationCurrency, t.Credit()) {
+		return errors.New("amount exceeds currency precision")
+	}
+	return t.Currencies.CheckTransferAmount(sourceCurrency, t.Amount)
+}
+
+// DefaultTransferType is recorded for transfers that do not specify a type
+const DefaultTransferType = "internal"
+
+// ReturnTransferType is recorded for transactions reversing a transfer a partner returned
+const ReturnTransferType = "return"
+
+// WithDefaults fills unset optional fields: the default transfer type and today's date (UTC)
+func (t Transfer) WithDefaults(now time.Time) Transfer {
+	if t.TransferType == "" {
+		t.TransferType = DefaultTransferType
+	}
+	if t.ValueDate.IsZero() {
+		now = now.UTC()
+		t.ValueDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t
 }
 
 // CreateTransactionRequest represents the request payload for creating a transaction
 type CreateTransactionRequest struct {
-	SourceAccountID      int64  `json:"source_account_id"`
-	DestinationAccountID int64  `json:"destination_account_id"`
-	Amount               string `json:"amount"`
+	SourceAccountID      int64  `json:"source_account_id" validate:"positive"`
+	DestinationAccountID int64  `json:"destination_account_id" validate:"positive,nefield=SourceAccountID"`
+	Amount               string `json:"amount" validate:"required,decimal,positive"`
+	TransferType         string `json:"transfer_type,omitempty"`
+	// Convert allows a transfer between accounts of different currencies: the source is debited
+	// Amount in its currency and the destination credited the amount converted at the current rate
+	Convert bool `json:"convert,omitempty"`
+	// Tenant selects the tenant-specific validation rules; it is taken from the X-Tenant-ID
+	// header rather than the body
+	Tenant string `json:"-"`
+	// ClientID and ReceivedAt attribute the transfer's processing latency for SLA tracking; they
+	// are set by the API from the API key the request was signed with and the time it arrived
+	ClientID   string    `json:"-"`
+	ReceivedAt time.Time `json:"-"`
+	// EffectiveAt backdates the transfer's business effective time, e.g. to restate a closed
+	// period; it defaults to when the transfer is recorded and may not be in the future
+	EffectiveAt *time.Time `json:"effective_at,omitempty"`
+	// HoldID is set when the transfer captures a hold (POST /holds/{hold_id}/capture)
+	HoldID int64 `json:"-"`
+	// Reference is the client's identifier for the transfer, e.g. an invoice number; no two
+	// transfers from the same source account may share one. Memo is free text. Both are optional
+	Reference string `json:"reference,omitempty" validate:"max=64"`
+	Memo      string `json:"memo,omitempty" validate:"max=500"`
+	// DependsOn chains the transfer after an earlier one, e.g. a payout after the transfer that
+	// funded it: it is refused unless that transaction completed and was not reversed
+	DependsOn int64 `json:"depends_on,omitempty"`
+	// Currency moves balances of multi-currency accounts: on each side the account's wallet in
+	// this currency, or its primary balance if it is the account's own currency. It cannot be
+	// combined with convert
+	Currency string `json:"currency,omitempty"`
+}
+
+// Lengths of the transfer annotations, in characters; the max rules of Reference and Memo
+const (
+	MaxReferenceLength = 64
+	MaxMemoLength      = 500
+)
+
+// Validate checks the request against the transfer business rules and returns the parsed amount
+// Every violation is reported, as validation.Errors; the messages are client-facing and shared by
+// every API surface (REST, GraphQL)
+// Rules:
+//   - Both account IDs must be positive and different from each other
+//   - Amount must be a valid, positive decimal
+//   - Reference and memo may not exceed MaxReferenceLength and MaxMemoLength characters, and a
+//     reference may not have surrounding whitespace or control characters
+//   - DependsOn must not be negative
+func (r CreateTransactionRequest) Validate() (decimal.Decimal, error) {
+	errs := validation.Struct(r)
+	if r.Reference != strings.TrimSpace(r.Reference) || strings.IndexFunc(r.Reference, unicode.IsControl) >= 0 {
+		errs.Add("reference", "Reference must not contain surrounding whitespace or control characters")
+	}
+	if r.DependsOn < 0 {
+		errs.Add("depends_on", "Dependency transaction ID must be positive")
+	}
+	if err := errs.Err(); err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.RequireFromString(r.Amount), nil
+}
+
+// TransactionResponse represents the response for a committed transaction
+// SourceSequence and DestinationSequence are the per-account ledger sequence numbers
+// assigned to this movement; consumers can use them to detect gaps and order updates
+// RoundingPolicy records how the amount was rounded to the stored scale; ValueDate (YYYY-MM-DD)
+// is the accounting date assigned from the transfer type's cut-off time; Status is the lifecycle
+// status (see TransactionCompleted); SettlementStatus and ReturnOf reflect partner acknowledgment/return files. The FX fields are only present on
+// cross-currency transactions: DestinationAmount is the converted amount credited, at FXRate
+// (destination currency per unit of source currency) observed at FXRateTimestamp. CreatedAt is
+// when the transaction was recorded and EffectiveAt when it takes effect for the business.
+// Reference and Memo are the client's annotations and DependsOn the transaction the transfer was
+// chained after, omitted when not given. SourceWallet and DestinationWallet are the currency of
+// the wallet moved on each side, omitted for primary balances
+type TransactionResponse struct {
+	ID                   int64      `json:"id"`
+	SourceAccountID      int64      `json:"source_account_id"`
+	DestinationAccountID int64      `json:"destination_account_id"`
+	Amount               string     `json:"amount"`
+	SourceSequence       int64      `json:"source_sequence"`
+	DestinationSequence  int64      `json:"destination_sequence"`
+	RoundingPolicy       string     `json:"rounding_policy"`
+	TransferType         string     `json:"transfer_type"`
+	ValueDate            string     `json:"value_date"`
+	Status               string     `json:"status"`
+	SettlementStatus     string     `json:"settlement_status"`
+	ReturnOf             int64      `json:"return_of,omitempty"`
+	DestinationAmount    string     `json:"destination_amount,omitempty"`
+	FXRate               string     `json:"fx_rate,omitempty"`
+	FXRateTimestamp      *time.Time `json:"fx_rate_timestamp,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	EffectiveAt          time.Time  `json:"effective_at"`
+	Reference            string     `json:"reference,omitempty"`
+	Memo                 string     `json:"memo,omitempty"`
+	DependsOn            int64      `json:"depends_on,omitempty"`
+	SourceWallet         string     `json:"source_wallet,omitempty"`
+	DestinationWallet    string     `json:"destination_wallet,omitempty"`
+}
+
+// NewTransactionResponse converts a committed transaction into its API representation
+func NewTransactionResponse(t Transaction) TransactionResponse {
+	response := TransactionResponse{
+		ID:                   t.ID,
+		SourceAccountID:      t.SourceAccountID,
+		DestinationAccountID: t.DestinationAccountID,
+		Amount:               t.Amount.String(),
+		SourceSequence:       t.SourceSequence,
+		DestinationSequence:  t.DestinationSequence,
+		RoundingPolicy:       string(t.RoundingPolicy),
+		TransferType:         t.TransferType,
+		ValueDate:            t.ValueDate.Format("2006-01-02"),
+		Status:               t.Status,
+		SettlementStatus:     t.SettlementStatus,
+		ReturnOf:             t.ReturnOf,
+		CreatedAt:            t.CreatedAt,
+		EffectiveAt:          t.EffectiveAt,
+		Reference:            t.Reference,
+		Memo:                 t.Memo,
+		DependsOn:            t.DependsOn,
+		SourceWallet:         t.SourceWallet,
+		DestinationWallet:    t.DestinationWallet,
+	}
+	if t.Converted() {
+		timestamp := t.FXRateTimestamp
+		response.DestinationAmount = t.DestinationAmount.String()
+		response.FXRate = t.FXRate.String()
+		response.FXRateTimestamp = &timestamp
+	}
+	return response
+}
+
+// Directions of a transaction relative to the listed account
+const (
+	TransactionIncoming = "incoming"
+	TransactionOutgoing = "outgoing"
+)
+
+// Orders of transaction listings
+const (
+	TransactionsNewestFirst = "newest"
+	TransactionsOldestFirst = "oldest"
+)
+
+// TransactionFilter selects an account's transactions by their annotations, time, amount and
+// counterparty
+// Empty fields match every transaction; Reference matches exactly, Memo any memo containing it
+// regardless of case. EffectiveFrom and CreatedFrom are inclusive, EffectiveTo and CreatedBefore
+// exclusive. Amount bounds are inclusive and apply to the amount moved on the account: the
+// amount debited if it is the source, otherwise the amount credited. Direction is
+// TransactionIncoming or TransactionOutgoing, CounterpartyID the other account of the transfer
+// Matches are listed newest first, or oldest first with Order TransactionsOldestFirst (see
+// TransactionPosition); a non-zero After starts after that position in the listing's order
+type TransactionFilter struct {
+	AccountID      int64
+	Reference      string
+	Memo           string
+	EffectiveFrom  time.Time
+	EffectiveTo    time.Time
+	CreatedFrom    time.Time
+	CreatedBefore  time.Time
+	MinAmount      *decimal.Decimal
+	MaxAmount      *decimal.Decimal
+	Direction      string
+	CounterpartyID int64
+	Order          string
+	After          TransactionPosition
+}
+
+// TransactionPosition is a transaction's place in the newest-first order of the transaction
+// listings: its creation time, ties broken by ID
+// A position without a creation time, from cursors issued while listings were ordered by ID
+// alone, stands for the transactions below its ID
+type TransactionPosition struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// Position returns the transaction's place in the transaction listings
+func (t Transaction) Position() TransactionPosition {
+	return TransactionPosition{CreatedAt: t.CreatedAt, ID: t.ID}
+}
+
+// IsZero reports whether p is the zero position, which starts a listing at its newest transaction
+func (p TransactionPosition) IsZero() bool {
+	return p.ID == 0
+}
+
+// Follows reports whether a transaction at p is listed after one at q, i.e. it is older; every
+// position follows the zero position
+func (p TransactionPosition) Follows(q TransactionPosition) bool {
+	switch {
+	case q.IsZero():
+		return true
+	case q.CreatedAt.IsZero() || p.CreatedAt.Equal(q.CreatedAt):
+		return p.ID < q.ID
+	}
+	return p.CreatedAt.Before(q.CreatedAt)
+}
+
+// OldestFirst reports whether the filter lists its matches oldest first
+func (f TransactionFilter) OldestFirst() bool {
+	return f.Order == TransactionsOldestFirst
+}
+
+// ListedAfter reports whether a transaction at p is listed after one at q in the filter's order;
+// every position is listed after the zero position
+func (f TransactionFilter) ListedAfter(p, q TransactionPosition) bool {
+	switch {
+	case !f.OldestFirst() || q.IsZero():
+		return p.Follows(q)
+	case q.CreatedAt.IsZero():
+		return p.ID > q.ID
+	}
+	return q.Follows(p)
+}
+
+// Matches reports whether t involves the filter's account and passes the filter's other
+// conditions; After is not considered
+func (f TransactionFilter) Matches(t Transaction) bool {
+	outgoing := t.SourceAccountID == f.AccountID
+	if !outgoing && t.DestinationAccountID != f.AccountID {
+		return false
+	}
+	counterparty := t.SourceAccountID
+	if outgoing {
+		counterparty = t.DestinationAccountID
+	}
+	amount := t.Movement(f.AccountID).Abs()
+	switch {
+	case f.Direction == TransactionIncoming && outgoing, f.Direction == TransactionOutgoing && !outgoing:
+		return false
+	case f.CounterpartyID != 0 && counterparty != f.CounterpartyID:
+		return false
+	case f.Reference != "" && t.Reference != f.Reference:
+		return false
+	case !f.EffectiveFrom.IsZero() && t.EffectiveAt.Before(f.EffectiveFrom),
+		!f.EffectiveTo.IsZero() && !t.EffectiveAt.Before(f.EffectiveTo):
+		return false
+	case !f.CreatedFrom.IsZero() && t.CreatedAt.Before(f.CreatedFrom),
+		!f.CreatedBefore.IsZero() && !t.CreatedAt.Before(f.CreatedBefore):
+		return false
+	case f.MinAmount != nil && amount.LessThan(*f.MinAmount),
+		f.MaxAmount != nil && amount.GreaterThan(*f.MaxAmount):
+		return false
+	}
+	return f.Memo == "" || strings.Contains(strings.ToLower(t.Memo), strings.ToLower(f.Memo))
+}
+
+// TransactionSearch selects transactions across accounts, e.g. to investigate a dispute
+// Empty fields match every transaction. A transaction matches AccountIDs if either side is one of
+// them, References if its reference is one of them, and Text if its memo contains every word of
+// the text, ignoring case and punctuation (see SearchWords). Amount bounds are inclusive and apply
+// to the amount debited; CreatedFrom is inclusive and CreatedBefore exclusive. Matches are listed
+// newest first (see TransactionPosition); a non-zero After starts after that position
+type TransactionSearch struct {
+	AccountIDs    []int64
+	References    []string
+	Text          string
+	MinAmount     *decimal.Decimal
+	MaxAmount     *decimal.Decimal
+	CreatedFrom   time.Time
+	CreatedBefore time.Time
+	After         TransactionPosition
+}
+
+// IsEmpty reports whether the search has no condition, so it would match every transaction
+func (s TransactionSearch) IsEmpty() bool {
+	return len(s.AccountIDs) == 0 && len(s.References) == 0 && len(SearchWords(s.Text)) == 0 &&
+		s.MinAmount == nil && s.MaxAmount == nil && s.CreatedFrom.IsZero() && s.CreatedBefore.IsZero()
+}
+
+// Matches reports whether t passes every condition of the search; After is not considered
+func (s TransactionSearch) Matches(t Transaction) bool {
+	switch {
+	case len(s.AccountIDs) > 0 && !containsAccount(s.AccountIDs, t.SourceAccountID) && !containsAccount(s.AccountIDs, t.DestinationAccountID):
+		return false
+	case len(s.References) > 0 && (t.Reference == "" || !slices.Contains(s.References, t.Reference)):
+		return false
+	case s.MinAmount != nil && t.Amount.LessThan(*s.MinAmount),
+		s.MaxAmount != nil && t.Amount.GreaterThan(*s.MaxAmount):
+		return false
+	case !s.CreatedFrom.IsZero() && t.CreatedAt.Before(s.CreatedFrom),
+		!s.CreatedBefore.IsZero() && !t.CreatedAt.Before(s.CreatedBefore):
+		return false
+	}
+	words := SearchWords(t.Memo)
+	for _, word := range SearchWords(s.Text) {
+		if !slices.Contains(words, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchWords splits text into the lower-case words full-text search compares, dropping
+// punctuation, e.g. "Refund: INV-1001" into "refund", "inv" and "1001"
+func SearchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// TransactionSearchResponse is the body of GET /transactions/search
+// NextCursor continues the search and is omitted on its last page
+type TransactionSearchResponse struct {
+	Transactions []TransactionResponse `json:"transactions"`
+	NextCursor   string                `json:"next_cursor,omitempty"`
+}
+
+// TransactionListResponse is the body of GET /accounts/{account_id}/transactions
+// NextCursor continues the listing and is omitted on its last page
+type TransactionListResponse struct {
+	AccountID    int64                 `json:"account_id"`
+	Transactions []TransactionResponse `json:"transactions"`
+	NextCursor   string                `json:"next_cursor,omitempty"`
+}
+
+// LedgerChange is one ledger movement on an account in the compact form served to mirroring
+// systems: Amount is signed (negative for debits) and in the account's currency, or in Currency
+// for a movement of one of its wallets
+type LedgerChange struct {
+	Seq            int64     `json:"seq"`
+	TransactionID  int64     `json:"transaction_id"`
+	Amount         string    `json:"amount"`
+	Currency       string    `json:"currency,omitempty"`
+	CounterpartyID int64     `json:"counterparty_id"`
+	ValueDate      string    `json:"value_date"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// NewLedgerChange describes a transaction as the movement it made on accountID, which must be
+// its source or destination
+func NewLedgerChange(t Transaction, accountID int64) LedgerChange {
+	change := LedgerChange{
+		TransactionID: t.ID,
+		Amount:        t.Movement(accountID).String(),
+		Currency:      t.Wallet(accountID),
+		ValueDate:     t.ValueDate.Format("2006-01-02"),
+		CreatedAt:     t.CreatedAt,
+	}
+	if t.SourceAccountID == accountID {
+		change.Seq = t.SourceSequence
+		change.CounterpartyID = t.DestinationAccountID
+	} else {
+		change.Seq = t.DestinationSequence
+		change.CounterpartyID = t.SourceAccountID
+	}
+	return change
+}
+
+// AccountChangesResponse is the body of GET /accounts/{account_id}/changes
+// LastSeq is the sequence number to pass as since_seq for the next page; HasMore is true when
+// further changes were already committed
+// NextCursor is an opaque alternative to since_seq=LastSeq, set while HasMore is true
+type AccountChangesResponse struct {
+	AccountID  int64          `json:"account_id"`
+	SinceSeq   int64          `json:"since_seq"`
+	LastSeq    int64          `json:"last_seq"`
+	HasMore    bool           `json:"has_more"`
+	Changes    []LedgerChange `json:"changes"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// BalanceAsOfResponse is the body of GET /accounts/{account_id}/balance: the account's balance
+// counting only the movements recorded by RecordedAt and effective by EffectiveAt
+type BalanceAsOfResponse struct {
+	AccountID   int64     `json:"account_id"`
+	Balance     string    `json:"balance"`
+	Currency    string    `json:"currency,omitempty"`
+	RecordedAt  time.Time `json:"recorded_at"`
+	EffectiveAt time.Time `json:"effective_at"`
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Usage is one day of API usage by one API key, the unit of the usage rollup
+// Keys are identified by their API key ID, that of the key requests were verified to be signed
+// with (see usage.Attribute)
+type Usage struct {
+	KeyID          string          `json:"key_id" db:"key_id"`
+	Day            time.Time       `json:"day" db:"day"`
+	Requests       int64           `json:"requests" db:"requests"`
+	Transfers      int64           `json:"transfers" db:"transfers"`
+	TransferVolume decimal.Decimal `json:"transfer_volume" db:"transfer_volume"`
+}
+
+// Add accumulates another usage record's counters into u
+func (u *Usage) Add(other Usage) {
+	u.Requests += other.Requests
+	u.Transfers += other.Transfers
+	u.TransferVolume = u.TransferVolume.Add(other.TransferVolume)
+}
+
+// UsageDayResponse is one day of a key's usage in API responses
+type UsageDayResponse struct {
+	Day            string `json:"day"`
+	Requests       int64  `json:"requests"`
+	Transfers      int64  `json:"transfers"`
+	TransferVolume string `json:"transfer_volume"`
+}
+
+// QuotaResponse reports a key's monthly request quota and its consumption in the current month
+type QuotaResponse struct {
+	MonthlyRequests int64 `json:"monthly_requests"`
+	Used            int64 `json:"used"`
+	Remaining       int64 `json:"remaining"`
+}
+
+// KeyUsageResponse is a key's usage totals over a period
+// Days is only set on GET /usage; Quota only when a quota is configured for the key
+type KeyUsageResponse struct {
+	KeyID          string             `json:"key_id"`
+	From           string             `json:"from"`
+	To             string             `json:"to"`
+	Requests       int64              `json:"requests"`
+	Transfers      int64              `json:"transfers"`
+	TransferVolume string             `json:"transfer_volume"`
+	Days           []UsageDayResponse `json:"days,omitempty"`
+	Quota          *QuotaResponse     `json:"quota,omitempty"`
+}
+
+// UsageReportResponse is the body of GET /admin/usage: every key's totals over a period
+type UsageReportResponse struct {
+	From string             `json:"from"`
+	To   string             `json:"to"`
+	Keys []KeyUsageResponse `json:"keys"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Wallet is an account's balance in a currency other than its own
+// A multi-currency account holds its balance in its own currency (Account.Balance, the primary
+// wallet) and one Wallet per other currency, all under the same account ID. Transfers naming a
+// currency (Transfer.Currency) move the wallet of that currency on each side whose own currency
+// differs. Wallets open empty and cannot be overdrawn or held; limits, statements and the
+// balance history cover the primary balance only
+type Wallet struct {
+	AccountID int64           `json:"account_id" db:"account_id"`
+	Currency  string          `json:"currency" db:"currency"`
+	Balance   decimal.Decimal `json:"balance" db:"balance"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// OpenWalletRequest is the body of POST /accounts/{account_id}/wallets
+// Currency is a supported ISO 4217 code other than the account's own currency
+type OpenWalletRequest struct {
+	Currency string `json:"currency"`
+}
+
+// WalletResponse is one balance of a multi-currency account
+// Primary marks the account's balance in its own currency, whose available amount also counts
+// its overdraft limit and holds; a wallet's whole balance is available
+type WalletResponse struct {
+	Currency  string    `json:"currency"`
+	Balance   string    `json:"balance"`
+	Available string    `json:"available"`
+	Primary   bool      `json:"primary,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewWalletResponse converts a wallet into its API representation
+func NewWalletResponse(w Wallet) WalletResponse {
+	return WalletResponse{
+		Currency:  w.Currency,
+		Balance:   w.Balance.String(),
+		Available: w.Balance.String(),
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// NewWalletResponses lists every balance of an account: its primary balance first, then its
+// wallets by currency
+func NewWalletResponses(account Account, wallets []Wallet) []WalletResponse {
+	responses := make([]WalletResponse, 0, len(wallets)+1)
+	responses = append(responses, WalletResponse{
+		Currency:  account.Currency,
+		Balance:   account.Balance.String(),
+		Available: account.Available().String(),
+		Primary:   true,
+		CreatedAt: account.CreatedAt,
+	})
+	sorted := append([]Wallet(nil), wallets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Currency < sorted[j].Currency })
+	for _, w := range sorted {
+		responses = append(responses, NewWalletResponse(w))
+	}
+	return responses
+}
+
+// WalletListResponse is the body of GET /accounts/{account_id}/wallets
+type WalletListResponse struct {
+	AccountID int64            `json:"account_id"`
+	Wallets   []WalletResponse `json:"wallets"`
+}
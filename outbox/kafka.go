@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"internal-transfers/database"
+)
+
+// DefaultTopic is the Kafka topic events are published to when KAFKA_TOPIC is unset
+const DefaultTopic = "transfers.events"
+
+// Kafka message headers carrying the event envelope; consumers map them onto consumer.Event
+const (
+	HeaderEventID   = "event_id"
+	HeaderEventType = "event_type"
+)
+
+// KafkaConfig holds the broker connection settings
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+}
+
+// LoadKafkaConfig reads the Kafka configuration from the environment
+// Environment variables used (with defaults):
+//   - KAFKA_BROKERS (unset): Comma separated broker addresses; publishing is disabled when empty
+//   - KAFKA_TOPIC (transfers.events): Topic for all events
+func LoadKafkaConfig() KafkaConfig {
+	config := KafkaConfig{Topic: os.Getenv("KAFKA_TOPIC")}
+	if config.Topic == "" {
+		config.Topic = DefaultTopic
+	}
+	for _, broker := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			config.Brokers = append(config.Brokers, broker)
+		}
+	}
+	return config
+}
+
+// Enabled reports whether brokers are configured
+func (c KafkaConfig) Enabled() bool {
+	return len(c.Brokers) > 0
+}
+
+// KafkaPublisher publishes outbox events to a Kafka topic
+// Messages are keyed by the outbox partition key (the account ID), so all events of an account
+// land on the same partition in commit order; writes wait for all in-sync replicas
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher creates a publisher for the configured brokers and topic
+func NewKafkaPublisher(config KafkaConfig) *KafkaPublisher {
+	return &KafkaPublisher{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(config.Brokers...),
+		Topic:                  config.Topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: false,
+	}}
+}
+
+// Publish writes the batch synchronously and returns once the broker acknowledged every message
+func (p *KafkaPublisher) Publish(ctx context.Context, messages []database.OutboxMessage) error {
+	if err := p.writer.WriteMessages(ctx, kafkaMessages(messages)...); err != nil {
+		return fmt.Errorf("failed to publish %d events to kafka: %w", len(messages), err)
+	}
+	return nil
+}
+
+// Close flushes and closes the underlying writer
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+// kafkaMessages converts outbox events into Kafka messages
+func kafkaMessages(messages []database.OutboxMessage) []kafka.Message {
+	out := make([]kafka.Message, len(messages))
+	for i, m := range messages {
+		out[i] = kafka.Message{
+			Key:   []byte(m.PartitionKey),
+			Value: m.Payload,
+			Time:  m.CreatedAt,
+			Headers: []kafka.Header{
+				{Key: HeaderEventID, Value: []byte(m.EventID)},
+				{Key: HeaderEventType, Value: []byte(m.EventType)},
+			},
+		}
+	}
+	return out
+}
+
+// Compile-time interface implementation check
+var _ Publisher = (*KafkaPublisher)(nil)
@@ -0,0 +1,92 @@
+// Package outbox relays events recorded in the transactional outbox to the message broker
+// Repositories write an outbox row in the same database transaction as the change it describes;
+// the relay publishes committed rows and marks them published only after the broker acknowledges
+// them. Together this guarantees no lost events (every committed change is eventually published)
+// and no phantom events (rolled back changes never reach the outbox). Delivery is at least once;
+// consumers deduplicate by event ID (see the consumer package)
+package outbox
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"time"
+
+	"internal-transfers/database"
+)
+
+// Relay defaults
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+)
+
+// metrics exposes relay counters under "outbox" at /debug/vars
+var metrics = expvar.NewMap("outbox")
+
+// Publisher delivers a batch of events to the broker
+// Publish must return nil only once every message in the batch is durably acknowledged
+type Publisher interface {
+	Publish(ctx context.Context, messages []database.OutboxMessage) error
+}
+
+// Store is the outbox the relay drains
+// database.OutboxRepository is the PostgreSQL implementation
+type Store interface {
+	PublishPending(ctx context.Context, limit int, publish func(ctx context.Context, messages []database.OutboxMessage) error) (int, error)
+}
+
+// Relay polls the outbox and publishes pending events in order
+type Relay struct {
+	store        Store
+	publisher    Publisher
+	batchSize    int
+	pollInterval time.Duration
+}
+
+// NewRelay creates a relay; zero batchSize or pollInterval use the defaults
+func NewRelay(store Store, publisher Publisher, batchSize int, pollInterval time.Duration) *Relay {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	return &Relay{store: store, publisher: publisher, batchSize: batchSize, pollInterval: pollInterval}
+}
+
+// Run publishes pending events until ctx is cancelled
+// Full batches are followed immediately by the next batch; otherwise the relay waits for the
+// poll interval. Errors are logged and retried after the poll interval
+func (r *Relay) Run(ctx context.Context) {
+	for {
+		published, err := r.RunOnce(ctx)
+		if err != nil {
+			slog.Error("Outbox relay error", "error", err)
+		}
+		if err == nil && published == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// RunOnce publishes a single batch of pending events
+// Returns the number of events published
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	published, err := r.store.PublishPending(ctx, r.batchSize, r.publisher.Publish)
+	if err != nil {
+		metrics.Add("publish_errors", 1)
+		return 0, err
+	}
+	metrics.Add("published", int64(published))
+	return published, nil
+}
+
+// Compile-time interface implementation check
+var _ Store = (*database.OutboxRepository)(nil)
@@ -0,0 +1,161 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"internal-transfers/database"
+)
+
+// memoryStore is an in-process outbox that marks events published only if publish succeeds
+type memoryStore struct {
+	pending   []database.OutboxMessage
+	published []database.OutboxMessage
+}
+
+func (s *memoryStore) PublishPending(ctx context.Context, limit int, publish func(ctx context.Context, messages []database.OutboxMessage) error) (int, error) {
+	batch := s.pending
+	if len(batch) > limit {
+		batch = batch[:limit]
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(ctx, batch); err != nil {
+		return 0, err
+	}
+	s.published = append(s.published, batch...)
+	s.pending = s.pending[len(batch):]
+	return len(batch), nil
+}
+
+// flakyPublisher fails the first failures calls and records delivered events
+type flakyPublisher struct {
+	mu        sync.Mutex
+	failures  int
+	delivered []string
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, messages []database.OutboxMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	for _, m := range messages {
+		p.delivered = append(p.delivered, m.EventID)
+	}
+	return nil
+}
+
+func (p *flakyPublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.delivered)
+}
+
+func newMessages(ids ...string) []database.OutboxMessage {
+	messages := make([]database.OutboxMessage, len(ids))
+	for i, id := range ids {
+		messages[i] = database.OutboxMessage{ID: int64(i + 1), EventID: id, EventType: database.EventTransactionCompleted, PartitionKey: "123"}
+	}
+	return messages
+}
+
+func TestRelay_PublishesInBatches(t *testing.T) {
+	store := &memoryStore{pending: newMessages("a", "b", "c")}
+	publisher := &flakyPublisher{}
+	relay := NewRelay(store, publisher, 2, time.Millisecond)
+
+	if n, err := relay.RunOnce(context.Background()); err != nil || n != 2 {
+		t.Fatalf("Expected first batch of 2, got %d, %v", n, err)
+	}
+	if n, err := relay.RunOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected second batch of 1, got %d, %v", n, err)
+	}
+	if n, _ := relay.RunOnce(context.Background()); n != 0 {
+		t.Errorf("Expected empty outbox, published %d", n)
+	}
+
+	want := []string{"a", "b", "c"}
+	for i, id := range want {
+		if publisher.delivered[i] != id {
+			t.Errorf("Expected events in outbox order %v, got %v", want, publisher.delivered)
+			break
+		}
+	}
+}
+
+func TestRelay_KeepsEventsPendingWhenPublishFails(t *testing.T) {
+	store := &memoryStore{pending: newMessages("a")}
+	publisher := &flakyPublisher{failures: 1}
+	relay := NewRelay(store, publisher, 10, time.Millisecond)
+
+	if _, err := relay.RunOnce(context.Background()); err == nil {
+		t.Fatal("Expected publish failure to be reported")
+	}
+	if len(store.pending) != 1 || len(store.published) != 0 {
+		t.Fatalf("Failed batch must stay pending, got %d pending / %d published", len(store.pending), len(store.published))
+	}
+
+	// Run retries until the broker recovers, then stops on cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for publisher.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(publisher.delivered) != 1 || len(store.pending) != 0 {
+		t.Errorf("Expected event to be published after recovery, delivered %v", publisher.delivered)
+	}
+}
+
+func TestKafkaMessages(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	payload, _ := json.Marshal(map[string]int64{"account_id": 123})
+	messages := kafkaMessages([]database.OutboxMessage{{
+		EventID: "evt-1", EventType: database.EventAccountCreated, PartitionKey: "123", Payload: payload, CreatedAt: created,
+	}})
+
+	m := messages[0]
+	if string(m.Key) != "123" || string(m.Value) != string(payload) || !m.Time.Equal(created) {
+		t.Errorf("Unexpected message %+v", m)
+	}
+	headers := map[string]string{}
+	for _, h := range m.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers[HeaderEventID] != "evt-1" || headers[HeaderEventType] != database.EventAccountCreated {
+		t.Errorf("Expected event envelope headers, got %v", headers)
+	}
+}
+
+func TestLoadKafkaConfig(t *testing.T) {
+	defer os.Unsetenv("KAFKA_BROKERS")
+	defer os.Unsetenv("KAFKA_TOPIC")
+
+	os.Unsetenv("KAFKA_BROKERS")
+	if config := LoadKafkaConfig(); config.Enabled() || config.Topic != DefaultTopic {
+		t.Errorf("Expected disabled config with default topic, got %+v", config)
+	}
+
+	os.Setenv("KAFKA_BROKERS", "kafka-1:9092, kafka-2:9092,")
+	os.Setenv("KAFKA_TOPIC", "ledger")
+	config := LoadKafkaConfig()
+	if !config.Enabled() || len(config.Brokers) != 2 || config.Brokers[1] != "kafka-2:9092" || config.Topic != "ledger" {
+		t.Errorf("Unexpected config %+v", config)
+	}
+}
@@ -0,0 +1,157 @@
+// Package pagination issues and verifies the opaque cursors of the list endpoints. A cursor
+// records where the next page starts (the sort key of the last row returned, never an offset or
+// storage-internal position) and the filters of the listing it belongs to, and is signed with
+// HMAC-SHA256, so clients cannot forge positions or change the filters halfway through a listing.
+//
+// Cursors are versioned: the sort keys are API fields (account IDs, sequence numbers, dates), so
+// they stay valid across schema and index changes, and a future change of the format can still
+// recognize the cursors it issued before.
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// version is the cursor format issued by Encode
+const version = 1
+
+// MinKeyLength is the shortest PAGINATION_KEY accepted
+const MinKeyLength = 32
+
+// Errors returned by Decode
+var (
+	// ErrInvalidCursor reports a cursor that is malformed, forged, issued with another key, or
+	// issued by a different endpoint
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrFilterMismatch reports request filters that differ from the filters of the cursor's listing
+	ErrFilterMismatch = errors.New("filters do not match the cursor")
+)
+
+// payload is the signed content of a cursor
+type payload struct {
+	Version  int             `json:"v"`
+	Endpoint string          `json:"e"`
+	Filters  url.Values      `json:"f,omitempty"`
+	Key      json.RawMessage `json:"k"`
+}
+
+// Codec signs and verifies cursors with one key
+// Every instance serving the same API must use the same key, or cursors issued by one instance
+// are rejected by the others. Safe for concurrent use
+type Codec struct {
+	key []byte
+}
+
+// NewCodec creates a codec signing cursors with key
+func NewCodec(key []byte) *Codec {
+	return &Codec{key: key}
+}
+
+// NewRandomCodec creates a codec with a random key: its cursors are only accepted by the same
+// process, and are invalidated when it restarts
+func NewRandomCodec() *Codec {
+	key := make([]byte, MinKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("pagination: failed to generate a key: %v", err))
+	}
+	return NewCodec(key)
+}
+
+// Load creates the codec from the environment
+// Variables:
+//   - PAGINATION_KEY: Secret signing the cursors, at least 32 bytes, shared by every instance
+//
+// Returns a random codec with configured false when PAGINATION_KEY is unset, or an error when it
+// is too short
+func Load() (codec *Codec, configured bool, err error) {
+	key := os.Getenv("PAGINATION_KEY")
+	if key == "" {
+		return NewRandomCodec(), false, nil
+	}
+	if len(key) < MinKeyLength {
+		return nil, false, fmt.Errorf("PAGINATION_KEY must be at least %d bytes", MinKeyLength)
+	}
+	return NewCodec([]byte(key)), true, nil
+}
+
+// Filters returns the non-empty values of the query parameters among names, the filters a
+// listing's cursors are bound to; repeated parameters keep every value, in order
+func Filters(query url.Values, names ...string) url.Values {
+	filters := url.Values{}
+	for _, name := range names {
+		for _, value := range query[name] {
+			if value != "" {
+				filters.Add(name, value)
+			}
+		}
+	}
+	return filters
+}
+
+// Encode returns the cursor continuing endpoint's listing with filters after the row whose sort
+// key is key; key must marshal to JSON
+func (c *Codec) Encode(endpoint string, filters url.Values, key interface{}) string {
+	encodedKey, err := json.Marshal(key)
+	if err != nil {
+		panic(fmt.Sprintf("pagination: cannot encode cursor key %T: %v", key, err))
+	}
+	if len(filters) == 0 {
+		filters = nil
+	}
+	content, _ := json.Marshal(payload{Version: version, Endpoint: endpoint, Filters: filters, Key: encodedKey})
+	return base64.RawURLEncoding.EncodeToString(content) + "." + base64.RawURLEncoding.EncodeToString(c.sign(content))
+}
+
+// Decode verifies a cursor issued by Encode for endpoint and unmarshals its sort key into key
+// Every filter given in the request must have the value it had when the cursor was issued; the
+// filters the request omits are taken from the cursor, so a cursor can be passed on its own
+// Returns the listing's filters, ErrInvalidCursor or ErrFilterMismatch
+func (c *Codec) Decode(cursor, endpoint string, filters url.Values, key interface{}) (url.Values, error) {
+	encodedContent, encodedSignature, found := strings.Cut(cursor, ".")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+	content, err := base64.RawURLEncoding.DecodeString(encodedContent)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, c.sign(content)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var p payload
+	if err := json.Unmarshal(content, &p); err != nil || p.Version != version || p.Endpoint != endpoint {
+		return nil, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(p.Key, key); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	for name, values := range filters {
+		if !reflect.DeepEqual(values, p.Filters[name]) {
+			return nil, ErrFilterMismatch
+		}
+	}
+	if p.Filters == nil {
+		p.Filters = url.Values{}
+	}
+	return p.Filters, nil
+}
+
+// sign returns the HMAC-SHA256 of content
+func (c *Codec) sign(content []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(content)
+	return mac.Sum(nil)
+}
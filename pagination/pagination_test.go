@@ -0,0 +1,80 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type key struct {
+	ID int64 `json:"id"`
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	codec := NewCodec([]byte(strings.Repeat("k", MinKeyLength)))
+	filters := url.Values{"status": {"active"}, "tag": {"payroll"}}
+	cursor := codec.Encode("list_accounts", filters, key{ID: 42})
+
+	var decoded key
+	got, err := codec.Decode(cursor, "list_accounts", url.Values{}, &decoded)
+	if err != nil || decoded.ID != 42 || got.Get("status") != "active" || got.Get("tag") != "payroll" {
+		t.Fatalf("Unexpected decode %+v %v (%v)", decoded, got, err)
+	}
+	if _, err := codec.Decode(cursor, "list_accounts", url.Values{"status": {"active"}}, &decoded); err != nil {
+		t.Errorf("Expected a repeated filter to be accepted, got %v", err)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	query := url.Values{"status": {"active"}, "tag": {""}, "account_id": {"1", "", "2"}, "limit": {"10"}}
+	filters := Filters(query, "status", "tag", "account_id")
+	if want := (url.Values{"status": {"active"}, "account_id": {"1", "2"}}); !reflect.DeepEqual(filters, want) {
+		t.Errorf("Expected %v, got %v", want, filters)
+	}
+}
+
+func TestCodec_Rejects(t *testing.T) {
+	codec := NewCodec([]byte(strings.Repeat("k", MinKeyLength)))
+	cursor := codec.Encode("list_accounts", url.Values{"status": {"active"}}, key{ID: 42})
+	content, signature, _ := strings.Cut(cursor, ".")
+
+	for name, tc := range map[string]struct {
+		codec    *Codec
+		cursor   string
+		endpoint string
+		filters  url.Values
+		want     error
+	}{
+		"tampered":       {codec, content + "x." + signature, "list_accounts", nil, ErrInvalidCursor},
+		"unsigned":       {codec, content, "list_accounts", nil, ErrInvalidCursor},
+		"garbage":        {codec, "%%%.%%%", "list_accounts", nil, ErrInvalidCursor},
+		"other key":      {NewRandomCodec(), cursor, "list_accounts", nil, ErrInvalidCursor},
+		"other endpoint": {codec, cursor, "list_account_holds", nil, ErrInvalidCursor},
+		"changed filter": {codec, cursor, "list_accounts", url.Values{"status": {"frozen"}}, ErrFilterMismatch},
+		"added filter":   {codec, cursor, "list_accounts", url.Values{"tag": {"payroll"}}, ErrFilterMismatch},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var decoded key
+			if _, err := tc.codec.Decode(tc.cursor, tc.endpoint, tc.filters, &decoded); !errors.Is(err, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("PAGINATION_KEY", "")
+	if codec, configured, err := Load(); err != nil || configured || codec == nil {
+		t.Errorf("Expected a random codec, got %v %v (%v)", codec, configured, err)
+	}
+	t.Setenv("PAGINATION_KEY", "short")
+	if _, _, err := Load(); err == nil {
+		t.Error("Expected an error for a short key")
+	}
+	t.Setenv("PAGINATION_KEY", strings.Repeat("s", MinKeyLength))
+	if _, configured, err := Load(); err != nil || !configured {
+		t.Errorf("Expected the configured key, got %v (%v)", configured, err)
+	}
+}
@@ -0,0 +1,281 @@
+// Package preauth asks an external decision endpoint, such as a fraud engine, to approve each
+// transfer before it is committed. The callout is synchronous and bounded by a timeout; its
+// verdict can decline the transfer. When the endpoint cannot give a verdict (timeout, transport
+// error, unexpected answer) the failure policy decides: fail closed refuses the transfer, fail
+// open lets it through unchecked. External engines are integrated this way without being embedded
+// in the service
+package preauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// Failure policies
+const (
+	// FailClosed refuses transfers while the endpoint gives no verdict
+	FailClosed = "closed"
+
+	// FailOpen lets transfers through unchecked while the endpoint gives no verdict
+	FailOpen = "open"
+)
+
+// DefaultTimeout bounds each callout when PREAUTH_TIMEOUT is unset
+const DefaultTimeout = 500 * time.Millisecond
+
+// Verdicts of the decision endpoint
+const (
+	DecisionApprove = "approve"
+	DecisionDecline = "decline"
+)
+
+// maxResponseSize bounds the decision endpoint's response body
+const maxResponseSize = 64 << 10
+
+// metrics exposes callout counters under "preauth" at /debug/vars
+//   - approved, declined: verdicts received
+//   - failures: callouts without a verdict
+//   - failed_open: failures that let the transfer through (FailOpen)
+var metrics = expvar.NewMap("preauth")
+
+// ErrUnavailable means the endpoint gave no verdict and the policy is FailClosed
+var ErrUnavailable = errors.New("pre-authorization unavailable")
+
+// Decline reports that the decision endpoint declined a transfer
+type Decline struct {
+	// Reason is the endpoint's explanation; it may be empty
+	Reason string
+}
+
+func (d *Decline) Error() string {
+	if d.Reason == "" {
+		return "declined by pre-authorization"
+	}
+	return "declined by pre-authorization: " + d.Reason
+}
+
+// Config controls the pre-authorization callout
+type Config struct {
+	// URL is the decision endpoint; empty disables pre-authorization
+	URL string
+
+	// Token is sent as a bearer token, if set
+	Token string
+
+	// SigningSecret, if set, signs each callout like a signed request to the API, with key ID
+	// SigningKeyID (see WithSigner), so the endpoint can check its origin and integrity
+	SigningSecret string
+
+	// Timeout bounds each callout, including reading the verdict
+	Timeout time.Duration
+
+	// FailurePolicy is FailClosed or FailOpen
+	FailurePolicy string
+}
+
+// Enabled reports whether a decision endpoint is configured
+func (c Config) Enabled() bool {
+	return c.URL != ""
+}
+
+// LoadConfig reads the pre-authorization configuration from the environment
+// Variables:
+//   - PREAUTH_URL (unset): Decision endpoint called before each transfer; unset disables it
+//   - PREAUTH_TOKEN (unset): Bearer token sent to the endpoint
+//   - PREAUTH_SIGNING_SECRET (unset): Secret signing each callout with HMAC-SHA256
+//   - PREAUTH_TIMEOUT (500ms): How long a callout may take
+//   - PREAUTH_FAILURE_POLICY (closed): "closed" refuses transfers without a verdict, "open"
+//     lets them through
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{
+		URL:           os.Getenv("PREAUTH_URL"),
+		Token:         os.Getenv("PREAUTH_TOKEN"),
+		SigningSecret: os.Getenv("PREAUTH_SIGNING_SECRET"),
+		Timeout:       DefaultTimeout,
+		FailurePolicy: FailClosed,
+	}
+	if config.URL != "" {
+		parsed, err := url.Parse(config.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return Config{}, fmt.Errorf("invalid PREAUTH_URL %q", config.URL)
+		}
+	}
+	if value := os.Getenv("PREAUTH_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid PREAUTH_TIMEOUT %q", value)
+		}
+		config.Timeout = d
+	}
+	if value := os.Getenv("PREAUTH_FAILURE_POLICY"); value != "" {
+		if value != FailClosed && value != FailOpen {
+			return Config{}, fmt.Errorf("invalid PREAUTH_FAILURE_POLICY %q (expected closed or open)", value)
+		}
+		config.FailurePolicy = value
+	}
+	return config, nil
+}
+
+// Request is the JSON body posted to the decision endpoint
+type Request struct {
+	Tenant               string `json:"tenant"`
+	ClientID             string `json:"client_id,omitempty"`
+	SourceAccountID      int64  `json:"source_account_id"`
+	DestinationAccountID int64  `json:"destination_account_id"`
+	// Amount is debited from the source; DestinationAmount is set for cross-currency transfers
+	Amount            string     `json:"amount"`
+	DestinationAmount string     `json:"destination_amount,omitempty"`
+	TransferType      string     `json:"transfer_type"`
+	EffectiveAt       *time.Time `json:"effective_at,omitempty"`
+	HoldID            int64      `json:"hold_id,omitempty"`
+	Reference         string     `json:"reference,omitempty"`
+	Memo              string     `json:"memo,omitempty"`
+	// Currency is set for transfers moving multi-currency accounts' wallets in that currency
+	Currency string `json:"currency,omitempty"`
+}
+
+// NewRequest describes a prepared transfer of the tenant's client to the decision endpoint
+func NewRequest(tenant, clientID string, transfer models.Transfer) Request {
+	req := Request{
+		Tenant:               tenant,
+		ClientID:             clientID,
+		SourceAccountID:      transfer.SourceAccountID,
+		DestinationAccountID: transfer.DestinationAccountID,
+		Amount:               transfer.Amount.String(),
+		TransferType:         transfer.TransferType,
+		HoldID:               transfer.HoldID,
+		Reference:            transfer.Reference,
+		Memo:                 transfer.Memo,
+		Currency:             transfer.Currency,
+	}
+	if !transfer.FXRate.IsZero() {
+		req.DestinationAmount = transfer.DestinationAmount.String()
+	}
+	if !transfer.EffectiveAt.IsZero() {
+		effectiveAt := transfer.EffectiveAt
+		req.EffectiveAt = &effectiveAt
+	}
+	return req
+}
+
+// response is the decision endpoint's answer, e.g. {"decision": "decline", "reason": "velocity"}
+type response struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+}
+
+// SigningKeyID is the key ID callouts signed with Config.SigningSecret carry
+const SigningKeyID = "preauth"
+
+// Signer sets the signature headers of a request whose body is body, signed with the key at time
+// now; signing.SignRequest is one, which this package cannot import itself
+type Signer func(r *http.Request, keyID, secret string, body []byte, now time.Time)
+
+// Client calls the decision endpoint
+// A nil *Client approves every transfer
+// Safe for concurrent use
+type Client struct {
+	url    string
+	token  string
+	secret string
+	sign   Signer
+	policy string
+	client *http.Client
+}
+
+// New creates a client for an enabled config; client may be nil to use one bounded by the
+// config's timeout
+func New(config Config, client *http.Client) *Client {
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+	return &Client{url: config.URL, token: config.Token, secret: config.SigningSecret, policy: config.FailurePolicy, client: client}
+}
+
+// WithSigner sets how callouts are signed when the config has a signing secret, normally with
+// signing.SignRequest: the endpoint then receives the X-Signature-Key-ID, X-Signature-Timestamp
+// and X-Signature headers of a signed request, covering the method, path, time and body
+// Returns the client to allow chaining after New
+func (c *Client) WithSigner(sign Signer) *Client {
+	c.sign = sign
+	return c
+}
+
+// Authorize asks the endpoint to approve a transfer
+// The endpoint is expected to answer 200 OK with {"decision": "approve"} or
+// {"decision": "decline", "reason": "..."}; anything else within the timeout is a failure
+// Returns nil if the transfer is approved (or the endpoint failed under FailOpen), a *Decline if
+// it is declined, or ErrUnavailable (wrapped) if the endpoint failed under FailClosed
+func (c *Client) Authorize(ctx context.Context, req Request) error {
+	if c == nil {
+		return nil
+	}
+	verdict, err := c.call(ctx, req)
+	switch {
+	case err != nil:
+		metrics.Add("failures", 1)
+		if c.policy == FailOpen {
+			metrics.Add("failed_open", 1)
+			slog.WarnContext(ctx, "Pre-authorization failed; transfer allowed unchecked", "error", err,
+				"source_account_id", req.SourceAccountID, "destination_account_id", req.DestinationAccountID)
+			return nil
+		}
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	case verdict.Decision == DecisionDecline:
+		metrics.Add("declined", 1)
+		return &Decline{Reason: verdict.Reason}
+	default:
+		metrics.Add("approved", 1)
+		return nil
+	}
+}
+
+// call posts the request and reads a valid verdict
+func (c *Client) call(ctx context.Context, req Request) (response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return response{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.secret != "" && c.sign != nil {
+		c.sign(httpReq, SigningKeyID, c.secret, body, time.Now())
+	}
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return response{}, fmt.Errorf("decision endpoint answered status %d", resp.StatusCode)
+	}
+
+	var verdict response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&verdict); err != nil {
+		return response{}, fmt.Errorf("invalid decision: %w", err)
+	}
+	if verdict.Decision != DecisionApprove && verdict.Decision != DecisionDecline {
+		return response{}, fmt.Errorf("invalid decision %q", verdict.Decision)
+	}
+	return verdict, nil
+}
@@ -0,0 +1,148 @@
+package preauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// endpoint serves a fixed answer and keeps the last request it received
+func endpoint(t *testing.T, status int, body string, delay time.Duration) (*httptest.Server, *Request) {
+	t.Helper()
+	var received Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Expected the bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		time.Sleep(delay)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func TestAuthorize(t *testing.T) {
+	transfer := models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("12.5"),
+		TransferType: "standard", Reference: "INV-7"}
+
+	testCases := []struct {
+		name     string
+		status   int
+		body     string
+		delay    time.Duration
+		policy   string
+		declined string
+		failed   bool
+	}{
+		{"Approved", http.StatusOK, `{"decision": "approve"}`, 0, FailClosed, "", false},
+		{"Declined", http.StatusOK, `{"decision": "decline", "reason": "velocity"}`, 0, FailClosed, "velocity", false},
+		{"Server error fails closed", http.StatusInternalServerError, "", 0, FailClosed, "", true},
+		{"Unknown decision fails closed", http.StatusOK, `{"decision": "maybe"}`, 0, FailClosed, "", true},
+		{"Timeout fails closed", http.StatusOK, `{"decision": "approve"}`, 200 * time.Millisecond, FailClosed, "", true},
+		{"Timeout fails open", http.StatusOK, `{"decision": "decline"}`, 200 * time.Millisecond, FailOpen, "", false},
+		{"Decline applies when failing open", http.StatusOK, `{"decision": "decline", "reason": "blocked"}`, 0, FailOpen, "blocked", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, received := endpoint(t, tc.status, tc.body, tc.delay)
+			client := New(Config{URL: server.URL, Token: "secret", Timeout: 50 * time.Millisecond, FailurePolicy: tc.policy}, nil)
+
+			err := client.Authorize(context.Background(), NewRequest("acme", "key-1", transfer))
+			var decline *Decline
+			switch {
+			case tc.failed:
+				if !errors.Is(err, ErrUnavailable) {
+					t.Errorf("Expected ErrUnavailable, got %v", err)
+				}
+			case tc.declined != "":
+				if !errors.As(err, &decline) || decline.Reason != tc.declined {
+					t.Errorf("Expected a decline for %q, got %v", tc.declined, err)
+				}
+			case err != nil:
+				t.Errorf("Expected approval, got %v", err)
+			}
+			if tc.delay == 0 && (received.Tenant != "acme" || received.ClientID != "key-1" || received.Amount != "12.5" ||
+				received.SourceAccountID != 1 || received.DestinationAccountID != 2 || received.Reference != "INV-7") {
+				t.Errorf("Unexpected request %+v", *received)
+			}
+		})
+	}
+}
+
+func TestAuthorize_Signed(t *testing.T) {
+	var header http.Header
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		received, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"decision": "approve"}`))
+	}))
+	defer server.Close()
+	var signed []byte
+	sign := func(r *http.Request, keyID, secret string, body []byte, now time.Time) {
+		signed = body
+		r.Header.Set("X-Signature", keyID+":"+secret)
+	}
+
+	// Without a secret the signer is not used
+	client := New(Config{URL: server.URL, Timeout: time.Second, FailurePolicy: FailClosed}, nil).WithSigner(sign)
+	if err := client.Authorize(context.Background(), Request{Tenant: "acme"}); err != nil || header.Get("X-Signature") != "" {
+		t.Errorf("Expected an unsigned approval, got %v with %q", err, header.Get("X-Signature"))
+	}
+	client = New(Config{URL: server.URL, SigningSecret: "s3cret", Timeout: time.Second, FailurePolicy: FailClosed}, nil).WithSigner(sign)
+	if err := client.Authorize(context.Background(), Request{Tenant: "acme"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if header.Get("X-Signature") != SigningKeyID+":s3cret" || string(signed) != string(received) {
+		t.Errorf("Expected the body sent to be signed with the secret, got %q over %s (sent %s)", header.Get("X-Signature"), signed, received)
+	}
+}
+
+func TestAuthorize_NilClient(t *testing.T) {
+	var client *Client
+	if err := client.Authorize(context.Background(), Request{}); err != nil {
+		t.Errorf("Expected a nil client to approve, got %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("PREAUTH_URL", "")
+	t.Setenv("PREAUTH_TIMEOUT", "")
+	t.Setenv("PREAUTH_FAILURE_POLICY", "")
+	config, err := LoadConfig()
+	if err != nil || config.Enabled() || config.Timeout != DefaultTimeout || config.FailurePolicy != FailClosed {
+		t.Errorf("Unexpected defaults %+v, %v", config, err)
+	}
+
+	t.Setenv("PREAUTH_URL", "https://fraud.internal/decide")
+	t.Setenv("PREAUTH_TIMEOUT", "2s")
+	t.Setenv("PREAUTH_FAILURE_POLICY", "open")
+	config, err = LoadConfig()
+	if err != nil || !config.Enabled() || config.Timeout != 2*time.Second || config.FailurePolicy != FailOpen {
+		t.Errorf("Unexpected config %+v, %v", config, err)
+	}
+
+	for name, value := range map[string]string{
+		"PREAUTH_URL":            "fraud.internal",
+		"PREAUTH_TIMEOUT":        "-1s",
+		"PREAUTH_FAILURE_POLICY": "sometimes",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := LoadConfig(); err == nil {
+				t.Errorf("Expected an error for %s=%q", name, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,487 @@
+// Package protogen derives Protocol Buffers (proto3) definitions from the service's JSON body
+// types, so integrations speaking protobuf, such as gRPC clients and event consumers, share the
+// schema the HTTP API and the outbox events are built from instead of a copy that drifts from it
+//
+// Each Go struct becomes a message named after its type, with one field per JSON field, named
+// like it and following encoding/json rules (embedded structs are flattened, "-" fields skipped),
+// so the proto3 JSON form of a message with the original field names is the body the service
+// reads and writes. Types map as follows:
+//
+//	time.Time                            google.protobuf.Timestamp (RFC 3339 in JSON)
+//	decimal.Decimal                      string, as amounts are sent
+//	interface{}, json.RawMessage         google.protobuf.Value
+//	map[string]interface{} (Metadata)    google.protobuf.Struct
+//	[]T, map[K]V                         repeated T, map<K, V>
+//	*T of a scalar type                  optional T
+//
+// Field numbers are wire identifiers and must never change, so they are kept across
+// regenerations by reading the previously generated file: existing fields keep their number, new
+// ones take the next free number, and a removed field, or one whose type changed, leaves its
+// number, and the name of a removed field, reserved
+package protogen
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Well-known types used by the generated messages, with the files declaring them
+const (
+	timestampType = "google.protobuf.Timestamp"
+	valueType     = "google.protobuf.Value"
+	structType    = "google.protobuf.Struct"
+)
+
+var wellKnownImports = map[string]string{
+	timestampType: "google/protobuf/timestamp.proto",
+	valueType:     "google/protobuf/struct.proto",
+	structType:    "google/protobuf/struct.proto",
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	decimalType    = reflect.TypeOf(decimal.Decimal{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// identifierPattern matches the names proto3 accepts for messages and fields
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// File collects the messages of one .proto file
+type File struct {
+	// Package is the proto package, e.g. transfers.v1
+	Package string
+
+	// GoPackage is the go_package option, the import path and name of the Go bindings
+	GoPackage string
+
+	messages map[string]*message
+}
+
+// message is a struct type to generate and the uses documented on it
+type message struct {
+	typ  reflect.Type
+	uses []string
+}
+
+// field is a message field as generated
+type field struct {
+	name   string
+	label  string // "", "optional" or "repeated"
+	typ    string
+	number int
+}
+
+// declaration returns the field's type as declared, e.g. "repeated string"
+func (f field) declaration() string {
+	if f.label == "" {
+		return f.typ
+	}
+	return f.label + " " + f.typ
+}
+
+// NewFile creates an empty file in package pkg
+func NewFile(pkg, goPackage string) *File {
+	return &File{Package: pkg, GoPackage: goPackage, messages: make(map[string]*message)}
+}
+
+// Add registers the struct type of value, and those of its fields, as messages; use is documented
+// on the message, e.g. "Response of get_account", and may be empty
+// Returns an error if value is not a struct or another type of the same name was added
+func (f *File) Add(value any, use string) error {
+	t := reflect.TypeOf(value)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || isSpecial(t) {
+		return fmt.Errorf("%v is not a struct", t)
+	}
+	name, err := f.register(t, "")
+	if err != nil {
+		return err
+	}
+	if msg := f.messages[name]; use != "" && !slices.Contains(msg.uses, use) {
+		msg.uses = append(msg.uses, use)
+	}
+	return nil
+}
+
+// register adds struct type t as a message, named after the type or, for an anonymous struct,
+// fallback, and returns the message name
+func (f *File) register(t reflect.Type, fallback string) (string, error) {
+	name := t.Name()
+	if name == "" {
+		name = fallback
+	}
+	if !identifierPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid message name %q for %v", name, t)
+	}
+	if existing, ok := f.messages[name]; ok {
+		if existing.typ != t {
+			return "", fmt.Errorf("message %s is declared by both %v and %v", name, existing.typ, t)
+		}
+		return name, nil
+	}
+	f.messages[name] = &message{typ: t}
+	// Registering the field types also checks that every field maps to a proto type
+	if _, err := f.fields(name, t); err != nil {
+		delete(f.messages, name)
+		return "", err
+	}
+	return name, nil
+}
+
+// fields returns the fields of message name, declared by struct type t, without their numbers
+func (f *File) fields(name string, t reflect.Type) ([]field, error) {
+	var fields []field
+	seen := make(map[string]bool)
+	var collect func(t reflect.Type) error
+	collect = func(t reflect.Type) error {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if jsonName == "-" {
+				continue
+			}
+			// encoding/json promotes the fields of untagged embedded structs
+			if sf.Anonymous && jsonName == "" {
+				embedded := sf.Type
+				if embedded.Kind() == reflect.Pointer {
+					embedded = embedded.Elem()
+				}
+				if embedded.Kind() == reflect.Struct && !isSpecial(embedded) {
+					if err := collect(embedded); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if jsonName == "" {
+				jsonName = sf.Name
+			}
+			if !identifierPattern.MatchString(jsonName) {
+				return fmt.Errorf("%s.%s: invalid field name %q", name, sf.Name, jsonName)
+			}
+			if seen[jsonName] {
+				return fmt.Errorf("%s: duplicate field %q", name, jsonName)
+			}
+			seen[jsonName] = true
+			label, typ, err := f.fieldType(sf.Type, name+sf.Name)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", name, sf.Name, err)
+			}
+			fields = append(fields, field{name: jsonName, label: label, typ: typ})
+		}
+		return nil
+	}
+	return fields, collect(t)
+}
+
+// fieldType maps Go type t to the label and type of a proto field; anonymous structs are
+// registered as fallback
+func (f *File) fieldType(t reflect.Type, fallback string) (label, typ string, err error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if scalar, ok := scalarType(t); ok {
+			return "optional", scalar, nil
+		}
+	}
+	switch {
+	case t == rawMessageType:
+		return "", valueType, nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return "", "bytes", nil
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		elem, err := f.elementType(t.Elem(), fallback)
+		if err != nil {
+			return "", "", err
+		}
+		return "repeated", elem, nil
+	case t.Kind() == reflect.Map:
+		if t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Interface {
+			return "", structType, nil
+		}
+		key, ok := scalarType(t.Key())
+		if !ok || key == "double" || key == "float" || key == "bytes" {
+			return "", "", fmt.Errorf("unsupported map key %v", t.Key())
+		}
+		value, err := f.elementType(t.Elem(), fallback)
+		if err != nil {
+			return "", "", err
+		}
+		return "", "map<" + key + ", " + value + ">", nil
+	}
+	typ, err = f.elementType(t, fallback)
+	return "", typ, err
+}
+
+// elementType maps Go type t to a proto type that can be repeated or a map value
+func (f *File) elementType(t reflect.Type, fallback string) (string, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return timestampType, nil
+	case t == decimalType:
+		return "string", nil
+	case t == rawMessageType || t.Kind() == reflect.Interface:
+		return valueType, nil
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Interface:
+		return structType, nil
+	case t.Kind() == reflect.Struct:
+		return f.register(t, fallback)
+	}
+	if scalar, ok := scalarType(t); ok {
+		return scalar, nil
+	}
+	return "", fmt.Errorf("unsupported type %v", t)
+}
+
+// scalarType maps Go type t to a proto scalar type, following the integer widths of the OpenAPI
+// document (int is int32)
+func scalarType(t reflect.Type) (string, bool) {
+	switch t {
+	case decimalType:
+		return "string", true
+	case rawMessageType:
+		return "", false
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return "int32", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "uint32", true
+	case reflect.Int64:
+		return "int64", true
+	case reflect.Uint64:
+		return "uint64", true
+	case reflect.Float32:
+		return "float", true
+	case reflect.Float64:
+		return "double", true
+	case reflect.String:
+		return "string", true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes", true
+		}
+	}
+	return "", false
+}
+
+// isSpecial reports struct types mapped to something other than a message of their own
+func isSpecial(t reflect.Type) bool {
+	return t == timeType || t == decimalType
+}
+
+// previousMessage is a message as declared in the previously generated file
+type previousMessage struct {
+	fields        map[string]field
+	reservedNames map[string]bool
+	reservedNums  map[int]bool
+}
+
+var (
+	messagePattern  = regexp.MustCompile(`^message (\w+) \{$`)
+	fieldPattern    = regexp.MustCompile(`^\s+((?:optional |repeated )?[\w.<>, ]+) (\w+) = (\d+);$`)
+	reservedPattern = regexp.MustCompile(`^\s+reserved (.+);$`)
+)
+
+// parse reads the messages of a file written by Generate
+func parse(data []byte) (map[string]*previousMessage, error) {
+	messages := make(map[string]*previousMessage)
+	var current *previousMessage
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if match := messagePattern.FindStringSubmatch(text); match != nil {
+			current = &previousMessage{
+				fields:        make(map[string]field),
+				reservedNames: make(map[string]bool),
+				reservedNums:  make(map[int]bool),
+			}
+			messages[match[1]] = current
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if text == "}" {
+			current = nil
+		} else if match := fieldPattern.FindStringSubmatch(text); match != nil {
+			number, _ := strconv.Atoi(match[3])
+			label, typ, _ := strings.Cut(match[1], " ")
+			if label != "optional" && label != "repeated" {
+				label, typ = "", match[1]
+			}
+			current.fields[match[2]] = field{name: match[2], label: label, typ: typ, number: number}
+		} else if match := reservedPattern.FindStringSubmatch(text); match != nil {
+			for _, item := range strings.Split(match[1], ", ") {
+				if name, err := strconv.Unquote(item); err == nil {
+					current.reservedNames[name] = true
+				} else if number, err := strconv.Atoi(item); err == nil {
+					current.reservedNums[number] = true
+				} else {
+					return nil, fmt.Errorf("line %d: malformed reserved entry %q", line, item)
+				}
+			}
+		}
+	}
+	return messages, scanner.Err()
+}
+
+// Generate writes the file, numbering the fields of messages already declared in previous, the
+// file as last generated (nil for the first generation), as they were
+// Returns an error if previous cannot be read
+func (f *File) Generate(previous []byte) ([]byte, error) {
+	declared, err := parse(previous)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(f.messages))
+	for name := range f.messages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var body bytes.Buffer
+	imports := make(map[string]bool)
+	for _, name := range names {
+		msg := f.messages[name]
+		fields, err := f.fields(name, msg.typ)
+		if err != nil {
+			return nil, err
+		}
+		prev := declared[name]
+		if prev == nil {
+			prev = &previousMessage{fields: map[string]field{}, reservedNames: map[string]bool{}, reservedNums: map[int]bool{}}
+		}
+		fields, reservedNums, reservedNames := number(fields, prev)
+
+		body.WriteString("\n")
+		for _, use := range msg.uses {
+			body.WriteString("// " + use + "\n")
+		}
+		body.WriteString("message " + name + " {\n")
+		if len(reservedNums) > 0 {
+			items := make([]string, len(reservedNums))
+			for i, n := range reservedNums {
+				items[i] = strconv.Itoa(n)
+			}
+			body.WriteString("  reserved " + strings.Join(items, ", ") + ";\n")
+		}
+		if len(reservedNames) > 0 {
+			items := make([]string, len(reservedNames))
+			for i, n := range reservedNames {
+				items[i] = strconv.Quote(n)
+			}
+			body.WriteString("  reserved " + strings.Join(items, ", ") + ";\n")
+		}
+		for _, fd := range fields {
+			fmt.Fprintf(&body, "  %s %s = %d;\n", fd.declaration(), fd.name, fd.number)
+			for typ, file := range wellKnownImports {
+				if strings.Contains(fd.typ, typ) {
+					imports[file] = true
+				}
+			}
+		}
+		body.WriteString("}\n")
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by protogen from the service's JSON body types. DO NOT EDIT.\n")
+	out.WriteString("// Field numbers are kept from the previous generation; see the protogen package.\n\n")
+	out.WriteString("syntax = \"proto3\";\n\n")
+	out.WriteString("package " + f.Package + ";\n")
+	if len(imports) > 0 {
+		files := make([]string, 0, len(imports))
+		for file := range imports {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		out.WriteString("\n")
+		for _, file := range files {
+			out.WriteString("import \"" + file + "\";\n")
+		}
+	}
+	if f.GoPackage != "" {
+		out.WriteString("\noption go_package = \"" + f.GoPackage + "\";\n")
+	}
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// number assigns the field numbers of a message previously declared as prev, and returns the
+// fields with the numbers and names to reserve, both sorted
+func number(fields []field, prev *previousMessage) ([]field, []int, []string) {
+	reservedNums := make(map[int]bool)
+	reservedNames := make(map[string]bool)
+	for n := range prev.reservedNums {
+		reservedNums[n] = true
+	}
+	for n := range prev.reservedNames {
+		reservedNames[n] = true
+	}
+
+	next := 1
+	for n := range reservedNums {
+		next = max(next, n+1)
+	}
+	current := make(map[string]bool, len(fields))
+	for _, fd := range fields {
+		current[fd.name] = true
+	}
+	for name, old := range prev.fields {
+		next = max(next, old.number+1)
+		if !current[name] {
+			// Removed: neither its number nor its name may be reused
+			reservedNums[old.number] = true
+			reservedNames[name] = true
+		}
+	}
+
+	for i, fd := range fields {
+		old, ok := prev.fields[fd.name]
+		switch {
+		case ok && old.declaration() == fd.declaration():
+			fields[i].number = old.number
+		case ok:
+			// Retyped: old readers would misread the field under its previous number
+			reservedNums[old.number] = true
+			fallthrough
+		default:
+			delete(reservedNames, fd.name)
+			fields[i].number = next
+			next++
+		}
+	}
+	return fields, sortedKeys(reservedNums), sortedKeys(reservedNames)
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys[K int | string](m map[K]bool) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package protogen
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type testAudit struct {
+	Actor string `json:"actor"`
+}
+
+type testTransfer struct {
+	testAudit
+	ID        int64                  `json:"id"`
+	Amount    decimal.Decimal        `json:"amount"`
+	Limit     *decimal.Decimal       `json:"limit,omitempty"`
+	Count     *int                   `json:"count"`
+	Tags      []string               `json:"tags"`
+	Labels    map[string]bool        `json:"labels"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	Snapshot  json.RawMessage        `json:"snapshot"`
+	CreatedAt time.Time              `json:"created_at"`
+	SettledAt *time.Time             `json:"settled_at"`
+	Secret    string                 `json:"-"`
+	internal  string
+}
+
+type testTransferList struct {
+	Transfers []testTransfer `json:"transfers"`
+	Page      struct {
+		Next string `json:"next"`
+	} `json:"page"`
+}
+
+// generate builds a file of value's type and generates it over previous
+func generate(t *testing.T, value any, previous string) string {
+	t.Helper()
+	file := NewFile("test.v1", "example.com/test/v1;testv1")
+	if err := file.Add(value, "Response of list_transfers"); err != nil {
+		t.Fatalf("Failed to add %T: %v", value, err)
+	}
+	content, err := file.Generate([]byte(previous))
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	return string(content)
+}
+
+func TestGenerate(t *testing.T) {
+	src := generate(t, testTransferList{}, "")
+
+	for _, want := range []string{
+		"syntax = \"proto3\";\n\npackage test.v1;\n",
+		"import \"google/protobuf/struct.proto\";\nimport \"google/protobuf/timestamp.proto\";\n",
+		"option go_package = \"example.com/test/v1;testv1\";\n",
+		"// Response of list_transfers\nmessage testTransferList {\n  repeated testTransfer transfers = 1;\n  testTransferListPage page = 2;\n}\n",
+		"message testTransferListPage {\n  string next = 1;\n}\n",
+		"message testTransfer {\n" +
+			"  string actor = 1;\n" +
+			"  int64 id = 2;\n" +
+			"  string amount = 3;\n" +
+			"  optional string limit = 4;\n" +
+			"  optional int32 count = 5;\n" +
+			"  repeated string tags = 6;\n" +
+			"  map<string, bool> labels = 7;\n" +
+			"  google.protobuf.Struct metadata = 8;\n" +
+			"  google.protobuf.Value snapshot = 9;\n" +
+			"  google.protobuf.Timestamp created_at = 10;\n" +
+			"  google.protobuf.Timestamp settled_at = 11;\n" +
+			"}\n",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Expected the file to contain %q, got:\n%s", want, src)
+		}
+	}
+	if generate(t, testTransferList{}, src) != src {
+		t.Error("Expected regenerating an unchanged file to be stable")
+	}
+
+	// Compile the file when protoc is installed
+	protoc, err := exec.LookPath("protoc")
+	if err != nil {
+		t.Skip("protoc not installed")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test.proto"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(protoc, "-I", dir, "--descriptor_set_out", filepath.Join(dir, "test.pb"), "test.proto")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("protoc failed: %v\n%s", err, out)
+	}
+}
+
+func TestGenerate_FieldNumbers(t *testing.T) {
+	previous := `message testAudit {
+  reserved 2;
+  reserved "note";
+  string reason = 1;
+  int64 actor = 3;
+  string removed = 4;
+}
+`
+	src := generate(t, testAudit{}, previous)
+
+	// actor changed type, so it takes a new number; reason and removed were dropped, so their
+	// numbers and names join the reserved ones
+	want := "message testAudit {\n  reserved 1, 2, 3, 4;\n  reserved \"note\", \"reason\", \"removed\";\n  string actor = 5;\n}\n"
+	if !strings.Contains(src, want) {
+		t.Errorf("Expected the file to contain %q, got:\n%s", want, src)
+	}
+
+	if _, err := NewFile("test.v1", "").Generate([]byte("message testAudit {\n  reserved x;\n}\n")); err == nil {
+		t.Error("Expected a malformed reserved entry to be rejected")
+	}
+}
+
+func TestAdd_Errors(t *testing.T) {
+	type testTransfer struct {
+		Action string `json:"action"`
+	}
+	type testMatrix struct {
+		Cells [][]int `json:"cells"`
+	}
+	type testHyphen struct {
+		Name string `json:"full-name"`
+	}
+
+	file := NewFile("test.v1", "")
+	if err := file.Add(testTransferList{}, ""); err != nil {
+		t.Fatalf("Failed to add testTransferList: %v", err)
+	}
+	for name, value := range map[string]any{
+		"not a struct":  []testTransfer{},
+		"name conflict": testTransfer{},
+		"nested slices": testMatrix{},
+		"invalid name":  testHyphen{},
+	} {
+		if err := file.Add(value, ""); err == nil {
+			t.Errorf("%s: expected %T to be rejected", name, value)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package pubsub fans committed transfers out to in-process subscribers
+// It feeds the live endpoints (the SSE transaction stream and the WebSocket balance feed).
+// Only transfers committed by this instance are seen; cross-instance consumers should use the
+// Kafka events published through the outbox instead
+package pubsub
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// DefaultBufferSize is the number of undelivered transfers a subscription may queue
+const DefaultBufferSize = 64
+
+// metrics exposes broker counters under "pubsub" at /debug/vars
+//   - published: transfers handed to the broker
+//   - subscriptions: currently open subscriptions
+//   - overflows: subscriptions closed because their consumer fell too far behind
+//   - reaped: subscriptions closed because their connection went silent (see Reap)
+var metrics = expvar.NewMap("pubsub")
+
+// Broker delivers each published transfer to the subscriptions of both accounts involved
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker with no subscribers
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives transfers touching any of its accounts on C
+// C is closed when the subscription is closed, either by Close, because the subscriber did not
+// keep up (its buffer filled) or because it was reaped as dead; Overflowed tells the first two
+// apart so clients can reconnect and resync
+type Subscription struct {
+	C <-chan models.Transaction
+
+	broker     *Broker
+	ch         chan models.Transaction
+	accounts   map[int64]bool
+	closed     bool
+	overflowed bool
+	lastSeen   time.Time
+}
+
+// Subscribe opens a subscription for the given accounts
+// A bufferSize of zero uses DefaultBufferSize
+func (b *Broker) Subscribe(bufferSize int, accountIDs ...int64) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	ch := make(chan models.Transaction, bufferSize)
+	s := &Subscription{C: ch, broker: b, ch: ch, accounts: make(map[int64]bool), lastSeen: time.Now()}
+	for _, id := range accountIDs {
+		s.accounts[id] = true
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	metrics.Add("subscriptions", 1)
+	return s
+}
+
+// Publish delivers a committed transfer to every interested subscription without blocking
+// A subscription whose buffer is full is closed rather than silently missing updates
+func (b *Broker) Publish(t models.Transaction) {
+	metrics.Add("published", 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if !s.accounts[t.SourceAccountID] && !s.accounts[t.DestinationAccountID] {
+			continue
+		}
+		select {
+		case s.ch <- t:
+		default:
+			s.overflowed = true
+			b.remove(s)
+			metrics.Add("overflows", 1)
+		}
+	}
+}
+
+// Add extends the subscription to more accounts
+func (s *Subscription) Add(accountIDs ...int64) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	for _, id := range accountIDs {
+		s.accounts[id] = true
+	}
+}
+
+// Remove stops delivering transfers for the given accounts
+func (s *Subscription) Remove(accountIDs ...int64) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	for _, id := range accountIDs {
+		delete(s.accounts, id)
+	}
+}
+
+// Accounts returns the number of accounts the subscription covers
+func (s *Subscription) Accounts() int {
+	s.broker.mu.RLock()
+	defer s.broker.mu.RUnlock()
+	return len(s.accounts)
+}
+
+// Interested reports whether the subscription currently covers accountID
+func (s *Subscription) Interested(accountID int64) bool {
+	s.broker.mu.RLock()
+	defer s.broker.mu.RUnlock()
+	return s.accounts[accountID]
+}
+
+// Close ends the subscription; it is safe to call more than once
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// Overflowed reports whether the subscription was closed because its buffer filled
+func (s *Subscription) Overflowed() bool {
+	s.broker.mu.RLock()
+	defer s.broker.mu.RUnlock()
+	return s.overflowed
+}
+
+// Touch records that the subscriber's connection is alive, e.g. after a successful write or a
+// pong; subscriptions not touched for a while are closed by Reap
+func (s *Subscription) Touch() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.lastSeen = time.Now()
+}
+
+// Reap closes the subscriptions last touched before idleSince, whose connections are presumed
+// dead, so they stop receiving transfers and their handlers exit
+// Returns the number of subscriptions closed
+func (b *Broker) Reap(idleSince time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	reaped := 0
+	for s := range b.subs {
+		if s.lastSeen.Before(idleSince) {
+			b.remove(s)
+			reaped++
+		}
+	}
+	metrics.Add("reaped", int64(reaped))
+	return reaped
+}
+
+// remove unregisters s and closes its channel; the broker lock must be held
+func (b *Broker) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(b.subs, s)
+	close(s.ch)
+	metrics.Add("subscriptions", -1)
+}
+
+// TransactionRepository decorates a transaction repository to publish committed transfers
+type TransactionRepository struct {
+	next   database.TransactionRepositoryInterface
+	broker *Broker
+}
+
+// NewTransactionRepository wraps next so every successful transfer is published to broker
+func NewTransactionRepository(next database.TransactionRepositoryInterface, broker *Broker) *TransactionRepository {
+	return &TransactionRepository{next: next, broker: broker}
+}
+
+// CreateTransaction delegates and publishes the transfer once it has committed
+func (r *TransactionRepository) CreateTransaction(transfer models.Transfer) (*models.Transaction, error) {
+	transaction, err := r.next.CreateTransaction(transfer)
+	if err != nil {
+		return nil, err
+	}
+	r.broker.Publish(*transaction)
+	return transaction, nil
+}
+
+// ListTransactions delegates to the wrapped repository
+func (r *TransactionRepository) ListTransactions(accountID int64, limit int) ([]models.Transaction, error) {
+	return r.next.ListTransactions(accountID, limit)
+}
+
+// SearchTransactions delegates to the wrapped repository
+func (r *TransactionRepository) SearchTransactions(filter models.TransactionFilter, limit int) ([]models.Transaction, error) {
+	return r.next.SearchTransactions(filter, limit)
+}
+
+// SearchAllTransactions delegates to the wrapped repository
+func (r *TransactionRepository) SearchAllTransactions(search models.TransactionSearch, limit int) ([]models.Transaction, error) {
+	return r.next.SearchAllTransactions(search, limit)
+}
+
+// ListChanges delegates to the wrapped repository
+func (r *TransactionRepository) ListChanges(accountID, sinceSeq int64, limit int) ([]models.Transaction, error) {
+	return r.next.ListChanges(accountID, sinceSeq, limit)
+}
+
+// BalanceAsOf delegates to the wrapped repository
+func (r *TransactionRepository) BalanceAsOf(accountID int64, recordedAt, effectiveAt time.Time) (decimal.Decimal, error) {
+	return r.next.BalanceAsOf(accountID, recordedAt, effectiveAt)
+}
+
+// Compile-time interface implementation check
+var _ database.TransactionRepositoryInterface = (*TransactionRepository)(nil)
@@ -0,0 +1,136 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+)
+
+func TestBroker_DeliversToInterestedSubscriptions(t *testing.T) {
+	broker := NewBroker()
+	source := broker.Subscribe(0, 1)
+	destination := broker.Subscribe(0, 2)
+	unrelated := broker.Subscribe(0, 3)
+	defer source.Close()
+	defer destination.Close()
+	defer unrelated.Close()
+
+	broker.Publish(models.Transaction{ID: 7, SourceAccountID: 1, DestinationAccountID: 2})
+
+	for name, sub := range map[string]*Subscription{"source": source, "destination": destination} {
+		select {
+		case got := <-sub.C:
+			if got.ID != 7 {
+				t.Errorf("%s: expected transaction 7, got %d", name, got.ID)
+			}
+		default:
+			t.Errorf("%s: expected a delivered transaction", name)
+		}
+	}
+	select {
+	case got := <-unrelated.C:
+		t.Errorf("Unrelated subscription received transaction %d", got.ID)
+	default:
+	}
+}
+
+func TestSubscription_AddRemove(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.Subscribe(0)
+	defer sub.Close()
+
+	sub.Add(1, 2)
+	sub.Remove(1)
+	if sub.Accounts() != 1 || sub.Interested(1) || !sub.Interested(2) {
+		t.Fatalf("Expected subscription to cover only account 2")
+	}
+
+	broker.Publish(models.Transaction{ID: 1, SourceAccountID: 1, DestinationAccountID: 3})
+	broker.Publish(models.Transaction{ID: 2, SourceAccountID: 3, DestinationAccountID: 2})
+	if got := <-sub.C; got.ID != 2 {
+		t.Errorf("Expected only transaction 2, got %d", got.ID)
+	}
+}
+
+func TestBroker_ClosesSlowSubscriptions(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.Subscribe(1, 1)
+
+	broker.Publish(models.Transaction{ID: 1, SourceAccountID: 1, DestinationAccountID: 2})
+	broker.Publish(models.Transaction{ID: 2, SourceAccountID: 1, DestinationAccountID: 2})
+
+	if !sub.Overflowed() {
+		t.Fatal("Expected subscription to overflow")
+	}
+	if got := <-sub.C; got.ID != 1 {
+		t.Errorf("Expected buffered transaction 1, got %d", got.ID)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("Expected channel to be closed after overflow")
+	}
+
+	// Closing after an overflow is harmless
+	sub.Close()
+}
+
+func TestBroker_ReapsIdleSubscriptions(t *testing.T) {
+	broker := NewBroker()
+	idle := broker.Subscribe(0, 1)
+	live := broker.Subscribe(0, 1)
+	defer live.Close()
+
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	live.Touch()
+	if reaped := broker.Reap(cutoff); reaped != 1 {
+		t.Fatalf("Expected 1 subscription reaped, got %d", reaped)
+	}
+	if _, ok := <-idle.C; ok {
+		t.Error("Expected the idle subscription's channel to be closed")
+	}
+	if idle.Overflowed() {
+		t.Error("A reaped subscription did not overflow")
+	}
+
+	broker.Publish(models.Transaction{ID: 1, SourceAccountID: 1, DestinationAccountID: 2})
+	if got := <-live.C; got.ID != 1 {
+		t.Errorf("Expected the live subscription to receive transaction 1, got %d", got.ID)
+	}
+
+	// Closing after being reaped is harmless
+	idle.Close()
+}
+
+func TestTransactionRepository_PublishesCommittedTransfers(t *testing.T) {
+	store := memory.NewStore()
+	accounts := memory.NewAccountRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(10)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+
+	broker := NewBroker()
+	sub := broker.Subscribe(0, 2)
+	defer sub.Close()
+	transactions := NewTransactionRepository(memory.NewTransactionRepository(store), broker)
+
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(100)}); err == nil {
+		t.Fatal("Expected insufficient balance")
+	}
+	committed, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(5)})
+	if err != nil {
+		t.Fatalf("Transfer failed: %v", err)
+	}
+
+	// Only the committed transfer is published
+	if got := <-sub.C; got.ID != committed.ID {
+		t.Errorf("Expected transaction %d, got %d", committed.ID, got.ID)
+	}
+	select {
+	case got := <-sub.C:
+		t.Errorf("Failed transfer must not be published, got %+v", got)
+	default:
+	}
+}
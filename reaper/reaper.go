@@ -0,0 +1,149 @@
+// Package reaper collects the garbage state a long-running instance accumulates: live stream
+// subscriptions whose connections died without closing, and the deduplication records of event
+// consumers (claims whose lease expired with their consumer, and completed events past their
+// retention). Each kind of garbage is a Task; a scheduled run executes every task and counts what
+// it reaped. Expired holds, the ledger's prepared transfers, are released by their own expiry job
+// (see package holds), which frees their funds as well
+package reaper
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"internal-transfers/consumer"
+	"internal-transfers/pubsub"
+)
+
+// Defaults
+const (
+	DefaultInterval = time.Minute
+
+	// DefaultStreamIdle is how long a stream connection may go unheard before it is presumed dead
+	// It exceeds both the SSE heartbeat and the WebSocket pong wait, so live connections are
+	// always heard from in time
+	DefaultStreamIdle = 2 * time.Minute
+)
+
+// metrics exposes reaper counters under "reaper" at /debug/vars
+//   - runs: completed reaper runs
+//   - reaped.<task>: items reaped per task
+//   - failures: task runs that failed
+var metrics = expvar.NewMap("reaper")
+
+// reapedItems counts the items reaped per task
+var reapedItems = new(expvar.Map).Init()
+
+func init() {
+	metrics.Set("reaped", reapedItems)
+}
+
+// Config controls the reaper's schedule and thresholds
+type Config struct {
+	// Interval is how often the reaper runs; zero disables it
+	Interval time.Duration
+
+	// StreamIdle is how long a stream subscription may go untouched before it is closed
+	StreamIdle time.Duration
+}
+
+// LoadConfig reads the reaper configuration from the environment
+// Variables:
+//   - REAPER_INTERVAL (1m): How often garbage state is reaped; 0 disables the reaper
+//   - REAPER_STREAM_IDLE (2m): How long an SSE or WebSocket connection may go unheard before it
+//     is closed
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{Interval: DefaultInterval, StreamIdle: DefaultStreamIdle}
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+	}{{"REAPER_INTERVAL", &config.Interval}, {"REAPER_STREAM_IDLE", &config.StreamIdle}} {
+		if value := os.Getenv(setting.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return Config{}, fmt.Errorf("invalid %s %q", setting.name, value)
+			}
+			*setting.value = d
+		}
+	}
+	if config.StreamIdle == 0 {
+		return Config{}, fmt.Errorf("invalid REAPER_STREAM_IDLE %q", os.Getenv("REAPER_STREAM_IDLE"))
+	}
+	return config, nil
+}
+
+// Task reaps one kind of garbage and returns the number of items it removed
+type Task struct {
+	Name string
+	Reap func(ctx context.Context) (int64, error)
+}
+
+// StreamSubscriptions returns the task closing stream subscriptions untouched for idle
+func StreamSubscriptions(broker *pubsub.Broker, idle time.Duration) Task {
+	return Task{Name: "stream_subscriptions", Reap: func(ctx context.Context) (int64, error) {
+		return int64(broker.Reap(time.Now().Add(-idle))), nil
+	}}
+}
+
+// ConsumerStore returns the tasks keeping a consumer's deduplication store bounded: expired
+// claims are deleted, and completed events once older than keep (zero keeps them forever)
+func ConsumerStore(name string, store consumer.Reaper, keep time.Duration) []Task {
+	tasks := []Task{{Name: name + "_claims", Reap: store.ReapClaims}}
+	if keep > 0 {
+		tasks = append(tasks, Task{Name: name + "_completed", Reap: func(ctx context.Context) (int64, error) {
+			return store.PurgeCompleted(ctx, time.Now().Add(-keep))
+		}})
+	}
+	return tasks
+}
+
+// Reaper runs its tasks on a schedule
+type Reaper struct {
+	tasks []Task
+}
+
+// New creates a reaper running the given tasks
+func New(tasks ...Task) *Reaper {
+	return &Reaper{tasks: tasks}
+}
+
+// ReapOnce runs every task and returns the items each one reaped
+// A failing task is logged and reported in the error after the other tasks have run
+func (r *Reaper) ReapOnce(ctx context.Context) (map[string]int64, error) {
+	reaped := make(map[string]int64, len(r.tasks))
+	var failed error
+	for _, task := range r.tasks {
+		n, err := task.Reap(ctx)
+		if err != nil {
+			metrics.Add("failures", 1)
+			failed = fmt.Errorf("%s: %w", task.Name, err)
+			slog.ErrorContext(ctx, "Reaper error", "task", task.Name, "error", err)
+			continue
+		}
+		reaped[task.Name] = n
+		reapedItems.Add(task.Name, n)
+		if n > 0 {
+			slog.InfoContext(ctx, "Reaped", "task", task.Name, "items", n)
+		}
+	}
+	metrics.Add("runs", 1)
+	return reaped, failed
+}
+
+// Run reaps every interval until ctx is cancelled
+// The first run is one interval after the start
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		r.ReapOnce(ctx)
+	}
+}
@@ -0,0 +1,75 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"internal-transfers/consumer"
+	"internal-transfers/pubsub"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("REAPER_INTERVAL", "")
+	t.Setenv("REAPER_STREAM_IDLE", "")
+	config, err := LoadConfig()
+	if err != nil || config.Interval != DefaultInterval || config.StreamIdle != DefaultStreamIdle {
+		t.Fatalf("Expected defaults, got %+v, %v", config, err)
+	}
+
+	t.Setenv("REAPER_INTERVAL", "0")
+	t.Setenv("REAPER_STREAM_IDLE", "5m")
+	if config, err := LoadConfig(); err != nil || config.Interval != 0 || config.StreamIdle != 5*time.Minute {
+		t.Errorf("Expected a disabled reaper with 5m stream idle, got %+v, %v", config, err)
+	}
+
+	for name, value := range map[string]string{"REAPER_INTERVAL": "often", "REAPER_STREAM_IDLE": "0"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := LoadConfig(); err == nil {
+				t.Errorf("Expected an error for %s=%q", name, value)
+			}
+		})
+	}
+}
+
+func TestReapOnce(t *testing.T) {
+	broker := pubsub.NewBroker()
+	idle := broker.Subscribe(0, 1)
+	defer idle.Close()
+
+	store := consumer.NewMemoryStore()
+	ctx := context.Background()
+	store.Claim(ctx, "orphaned", -time.Second)
+	token, _, _ := store.Claim(ctx, "done", time.Minute)
+	store.Complete(ctx, "done", token)
+
+	tasks := append([]Task{StreamSubscriptions(broker, -time.Second)}, ConsumerStore("events", store, time.Nanosecond)...)
+	tasks = append(tasks, Task{Name: "broken", Reap: func(context.Context) (int64, error) { return 0, errors.New("unavailable") }})
+	time.Sleep(time.Millisecond)
+
+	reaped, err := New(tasks...).ReapOnce(ctx)
+	if err == nil {
+		t.Error("Expected the failing task to be reported")
+	}
+	want := map[string]int64{"stream_subscriptions": 1, "events_claims": 1, "events_completed": 1}
+	for name, n := range want {
+		if reaped[name] != n {
+			t.Errorf("Expected %d reaped by %s, got %d", n, name, reaped[name])
+		}
+	}
+	if _, ok := reaped["broken"]; ok {
+		t.Error("A failing task must not report reaped items")
+	}
+	if _, ok := <-idle.C; ok {
+		t.Error("Expected the idle subscription to be closed")
+	}
+}
+
+func TestConsumerStore_KeepForever(t *testing.T) {
+	tasks := ConsumerStore("events", consumer.NewMemoryStore(), 0)
+	if len(tasks) != 1 || tasks[0].Name != "events_claims" {
+		t.Errorf("Expected only the claims task when completed events are kept forever, got %d tasks", len(tasks))
+	}
+}
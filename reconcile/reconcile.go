@@ -0,0 +1,73 @@
+// Package reconcile checks the ledger against itself: every account's stored balance must equal
+// the balance it was opened with plus the transfers it received minus those it sent. A
+// discrepancy means a balance was changed outside a transfer or a transfer was lost, and is
+// reported for investigation, never corrected automatically
+package reconcile
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// metrics exposes reconciliation counters under "reconciliation" at /debug/vars
+//   - runs: completed reconciliation runs
+//   - discrepancies: accounts with a discrepancy in the last run
+var metrics = expvar.NewMap("reconciliation")
+
+// lastDiscrepancies is the number of discrepancies found by the last run
+var lastDiscrepancies = new(expvar.Int)
+
+func init() {
+	metrics.Set("discrepancies", lastDiscrepancies)
+}
+
+// Reconciler compares stored balances with the balances recomputed from the transactions
+type Reconciler struct {
+	repo database.ReconciliationRepositoryInterface
+	now  func() time.Time
+}
+
+// NewReconciler creates a reconciler reading the ledger through repo
+func NewReconciler(repo database.ReconciliationRepositoryInterface) *Reconciler {
+	return &Reconciler{repo: repo, now: time.Now}
+}
+
+// Reconcile checks every account and returns the discrepancy report
+// Discrepancies are logged as errors, one per account
+func (r *Reconciler) Reconcile(ctx context.Context) (*models.ReconciliationReport, error) {
+	checkedAt := r.now().UTC()
+	accounts, discrepancies, err := r.repo.ReconcileBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.Add("runs", 1)
+	lastDiscrepancies.Set(int64(len(discrepancies)))
+	for _, d := range discrepancies {
+		slog.ErrorContext(ctx, "Balance discrepancy", "account_id", d.AccountID, "stored", d.StoredBalance.String(),
+			"computed", d.ComputedBalance.String(), "difference", d.Difference().String())
+	}
+	return &models.ReconciliationReport{CheckedAt: checkedAt, Accounts: accounts, Discrepancies: discrepancies}, nil
+}
+
+// WriteReport writes the report as text, one line per discrepancy
+func WriteReport(w io.Writer, report *models.ReconciliationReport) {
+	fmt.Fprintf(w, "Reconciled %d accounts at %s\n", report.Accounts, report.CheckedAt.Format(time.RFC3339))
+	if len(report.Discrepancies) == 0 {
+		fmt.Fprintln(w, "Every stored balance matches its transactions")
+		return
+	}
+	fmt.Fprintf(w, "%d accounts with discrepancies:\n", len(report.Discrepancies))
+	fmt.Fprintf(w, "%-20s %20s %20s %20s %12s\n", "account_id", "stored", "computed", "difference", "transactions")
+	for _, d := range report.Discrepancies {
+		fmt.Fprintf(w, "%-20d %20s %20s %20s %12d\n", d.AccountID, d.StoredBalance.String(), d.ComputedBalance.String(),
+			d.Difference().String(), d.Transactions)
+	}
+}
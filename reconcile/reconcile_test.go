@@ -0,0 +1,45 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+)
+
+func TestReconciler(t *testing.T) {
+	store := memory.NewStore()
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	store.Transactions().CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(40)})
+
+	report, err := NewReconciler(store.Reconciliation()).Reconcile(context.Background())
+	if err != nil || report.Accounts != 2 || len(report.Discrepancies) != 0 || report.CheckedAt.IsZero() {
+		t.Fatalf("Expected a balanced report, got %+v (%v)", report, err)
+	}
+	if lastDiscrepancies.Value() != 0 {
+		t.Errorf("Expected no discrepancies in the metrics, got %d", lastDiscrepancies.Value())
+	}
+	var out bytes.Buffer
+	WriteReport(&out, report)
+	if !strings.Contains(out.String(), "Reconciled 2 accounts") || !strings.Contains(out.String(), "Every stored balance matches") {
+		t.Errorf("Unexpected report:\n%s", out.String())
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	report := &models.ReconciliationReport{Accounts: 5, Discrepancies: []models.BalanceDiscrepancy{{
+		AccountID: 7, StoredBalance: decimal.RequireFromString("90.5"), ComputedBalance: decimal.RequireFromString("80.5"), Transactions: 3,
+	}}}
+	var out bytes.Buffer
+	WriteReport(&out, report)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || lines[1] != "1 accounts with discrepancies:" || strings.Join(strings.Fields(lines[3]), " ") != "7 90.5 80.5 10 3" {
+		t.Errorf("Unexpected report:\n%s", out.String())
+	}
+}
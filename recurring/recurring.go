@@ -0,0 +1,369 @@
+// Package recurring books transfers on a schedule, e.g. a monthly sweep between two accounts. A
+// recurring transfer is a stored rule with a transfer template and a cron or interval schedule
+// (see ParseSchedule). The Scheduler polls storage for due rules and submits their transfers
+// through the transfer service, so executions are validated, value-dated and rule-checked exactly
+// like API transfers; each outcome is recorded as an execution.
+//
+// Runs are at most once: a due run is claimed by advancing the rule's next run time before its
+// transfer is submitted, which also lets several instances poll the same storage. A rule that was
+// due while the service was down or the rule was paused runs once when it is picked up, and
+// the missed runs are skipped rather than made up.
+//
+// A run refused for a transient reason, such as an insufficient balance at run time, is queued
+// to be attempted again after each of the configured retry delays (see Config.RetryDelays). Once
+// they are used up, or for any other refusal, the run fails for good and is escalated with a
+// recurring_transfer.failed outbox event. Retries are claimed like runs, and a paused rule's
+// queued retries wait until it is resumed.
+package recurring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"internal-transfers/audit"
+	"internal-transfers/database"
+	"internal-transfers/fx"
+	"internal-transfers/models"
+	"internal-transfers/preauth"
+	"internal-transfers/service"
+)
+
+// ErrNotFound is returned for operations on a recurring transfer that does not exist
+var ErrNotFound = errors.New("recurring transfer not found")
+
+// Defaults
+const (
+	// DefaultPollInterval is how often the scheduler looks for due rules
+	DefaultPollInterval = 30 * time.Second
+
+	// maxDuePerPoll caps the rules executed by one poll; the rest are picked up by the next
+	maxDuePerPoll = 100
+)
+
+// DefaultRetryDelays are the waits before each retry of a run refused for a transient reason
+var DefaultRetryDelays = []time.Duration{5 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+
+// Config controls the recurring transfer scheduler
+type Config struct {
+	// PollInterval is how often due rules and retries are looked for; runs start up to this late
+	PollInterval time.Duration
+
+	// RetryDelays are the waits before each retry of a run refused for a transient reason,
+	// measured from the previous attempt; a run is attempted at most len(RetryDelays)+1 times,
+	// and empty disables retrying
+	RetryDelays []time.Duration
+}
+
+// LoadConfig reads the scheduler configuration from the environment
+// Variables:
+//   - RECURRING_POLL_INTERVAL (30s): How often due recurring transfers are looked for
+//   - RECURRING_RETRY_DELAYS (5m,30m,2h): Comma-separated waits before each retry of a run
+//     refused for a transient reason; 0 disables retrying
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{PollInterval: DefaultPollInterval, RetryDelays: DefaultRetryDelays}
+	if value := os.Getenv("RECURRING_POLL_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid RECURRING_POLL_INTERVAL %q", value)
+		}
+		config.PollInterval = d
+	}
+	if value := os.Getenv("RECURRING_RETRY_DELAYS"); value == "0" {
+		config.RetryDelays = nil
+	} else if value != "" {
+		config.RetryDelays = nil
+		for _, field := range strings.Split(value, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(field))
+			if err != nil || d <= 0 {
+				return Config{}, fmt.Errorf("invalid RECURRING_RETRY_DELAYS %q", value)
+			}
+			config.RetryDelays = append(config.RetryDelays, d)
+		}
+	}
+	return config, nil
+}
+
+// Scheduler manages recurring transfer rules and executes them when due
+// Safe for concurrent use
+type Scheduler struct {
+	repo      database.RecurringRepositoryInterface
+	accounts  database.AccountRepositoryInterface
+	transfers *service.TransferService
+	audit     *audit.Recorder
+	retries   []time.Duration
+	now       func() time.Time
+}
+
+// NewScheduler creates a scheduler storing rules in repo, checking their accounts in accounts and
+// submitting their transfers through transfers
+func NewScheduler(repo database.RecurringRepositoryInterface, accounts database.AccountRepositoryInterface, transfers *service.TransferService) *Scheduler {
+	return &Scheduler{repo: repo, accounts: accounts, transfers: transfers, now: time.Now}
+}
+
+// WithAudit appends the transfers of executed rules to the audit log, as made by "recurring:<rule ID>"
+// Returns the scheduler to allow chaining
+func (s *Scheduler) WithAudit(recorder *audit.Recorder) *Scheduler {
+	s.audit = recorder
+	return s
+}
+
+// WithRetries queues runs refused for a transient reason to be attempted again after each of
+// delays in turn (see Config.RetryDelays); without it, every refused run fails at once
+// Returns the scheduler to allow chaining
+func (s *Scheduler) WithRetries(delays []time.Duration) *Scheduler {
+	s.retries = delays
+	return s
+}
+
+// Create validates and stores a recurring transfer
+// The transfer template is validated like a transfer made now (amount, rounding, transfer type);
+// the first run is req.StartAt if set, otherwise the schedule's next time
+// Returns the stored rule, or one of:
+//   - *service.ValidationError: Invalid template, schedule or start time
+//   - service.ErrSourceNotFound, service.ErrDestinationNotFound: An account does not exist
+//   - any other error: Storage failure
+func (s *Scheduler) Create(ctx context.Context, req models.CreateRecurringTransferRequest) (*models.RecurringTransfer, error) {
+	schedule, err := ParseSchedule(req.Schedule)
+	if err != nil {
+		return nil, &service.ValidationError{Message: err.Error()}
+	}
+	transfer, err := s.transfers.Prepare(models.CreateTransactionRequest{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               req.Amount,
+		TransferType:         req.TransferType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	nextRunAt := schedule.Next(now)
+	if req.StartAt != nil {
+		if !req.StartAt.After(now) {
+			return nil, &service.ValidationError{Message: "start_at must be in the future"}
+		}
+		nextRunAt = *req.StartAt
+	}
+
+	for _, check := range []struct {
+		accountID int64
+		missing   error
+	}{{req.SourceAccountID, service.ErrSourceNotFound}, {req.DestinationAccountID, service.ErrDestinationNotFound}} {
+		exists, err := s.accounts.AccountExists(check.accountID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, check.missing
+		}
+	}
+
+	return s.repo.CreateRecurringTransfer(ctx, models.RecurringTransfer{
+		SourceAccountID:      transfer.SourceAccountID,
+		DestinationAccountID: transfer.DestinationAccountID,
+		Amount:               transfer.Amount,
+		TransferType:         transfer.TransferType,
+		Tenant:               req.Tenant,
+		Schedule:             req.Schedule,
+		Status:               models.RecurringActive,
+		NextRunAt:            nextRunAt.UTC(),
+	})
+}
+
+// Get returns a recurring transfer or ErrNotFound
+func (s *Scheduler) Get(ctx context.Context, id int64) (*models.RecurringTransfer, error) {
+	rule, err := s.repo.GetRecurringTransfer(ctx, id)
+	return rule, translate(err)
+}
+
+// List returns up to limit recurring transfers with an ID above afterID, ordered by ID
+func (s *Scheduler) List(ctx context.Context, afterID int64, limit int) ([]models.RecurringTransfer, error) {
+	return s.repo.ListRecurringTransfers(ctx, afterID, limit)
+}
+
+// Pause stops a recurring transfer from running until it is resumed; pausing a paused rule is a no-op
+// Returns the updated rule or ErrNotFound
+func (s *Scheduler) Pause(ctx context.Context, id int64) (*models.RecurringTransfer, error) {
+	rule, err := s.Get(ctx, id)
+	if err != nil || rule.Status == models.RecurringPaused {
+		return rule, err
+	}
+	rule, err = s.repo.SetRecurringTransferStatus(ctx, id, models.RecurringPaused, rule.NextRunAt)
+	return rule, translate(err)
+}
+
+// Resume reactivates a paused recurring transfer; resuming an active rule is a no-op
+// Runs missed while it was paused are skipped: the next run is the schedule's next time from now,
+// or the original first run if that is still in the future
+// Returns the updated rule or ErrNotFound
+func (s *Scheduler) Resume(ctx context.Context, id int64) (*models.RecurringTransfer, error) {
+	rule, err := s.Get(ctx, id)
+	if err != nil || rule.Status == models.RecurringActive {
+		return rule, err
+	}
+	schedule, err := ParseSchedule(rule.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	nextRunAt := rule.NextRunAt
+	if !nextRunAt.After(now) {
+		nextRunAt = schedule.Next(now).UTC()
+	}
+	rule, err = s.repo.SetRecurringTransferStatus(ctx, id, models.RecurringActive, nextRunAt)
+	return rule, translate(err)
+}
+
+// Delete removes a recurring transfer and its execution history; transfers it booked are kept
+// Returns ErrNotFound if it does not exist
+func (s *Scheduler) Delete(ctx context.Context, id int64) error {
+	return translate(s.repo.DeleteRecurringTransfer(ctx, id))
+}
+
+// Executions returns up to limit executions of a recurring transfer, newest first, below beforeID
+// if set
+// Returns ErrNotFound if it does not exist
+func (s *Scheduler) Executions(ctx context.Context, id, beforeID int64, limit int) ([]models.RecurringExecution, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRecurringExecutions(ctx, id, beforeID, limit)
+}
+
+// RunDue executes the recurring transfers that are due, then the queued retries that are due
+// Each run or retry is claimed before its transfer is submitted, so one claimed by another
+// instance is skipped. A transfer refused for a transient reason is recorded as a retrying
+// execution while retries remain, any other refusal as a failed one; either way the rule stays
+// active
+// Returns the number of attempts executed, or a storage error that stopped the poll
+func (s *Scheduler) RunDue(ctx context.Context) (int, error) {
+	now := s.now()
+	due, err := s.repo.DueRecurringTransfers(ctx, now, maxDuePerPoll)
+	if err != nil {
+		return 0, err
+	}
+
+	executed := 0
+	for _, rule := range due {
+		schedule, err := ParseSchedule(rule.Schedule)
+		if err != nil {
+			slog.Error("Recurring transfer has an invalid schedule", "recurring_transfer_id", rule.ID, "error", err)
+			continue
+		}
+		next := schedule.Next(rule.NextRunAt)
+		if !next.After(now) {
+			next = schedule.Next(now)
+		}
+		claimed, err := s.repo.AdvanceRecurringTransfer(ctx, rule.ID, rule.NextRunAt, next.UTC())
+		if err != nil {
+			return executed, err
+		}
+		if !claimed {
+			continue
+		}
+
+		s.execute(ctx, rule, rule.NextRunAt, 1)
+		executed++
+	}
+
+	retries, err := s.repo.DueRecurringRetries(ctx, now, maxDuePerPoll)
+	if err != nil {
+		return executed, err
+	}
+	for _, retry := range retries {
+		claimed, err := s.repo.ClaimRecurringRetry(ctx, retry.ID)
+		if err != nil {
+			return executed, err
+		}
+		if !claimed {
+			continue
+		}
+		rule, err := s.repo.GetRecurringTransfer(ctx, retry.RecurringTransferID)
+		if err != nil {
+			// The rule was deleted since the poll, taking its executions with it
+			if translate(err) != ErrNotFound {
+				slog.Error("Failed to get recurring transfer for retry", "recurring_transfer_id", retry.RecurringTransferID, "error", err)
+			}
+			continue
+		}
+		s.execute(ctx, *rule, retry.ScheduledAt, retry.Attempt+1)
+		executed++
+	}
+	return executed, nil
+}
+
+// execute submits attempt number attempt of the rule's run scheduled at scheduledAt and records
+// its execution, queuing a retry if the transfer was refused for a transient reason and retries
+// remain
+func (s *Scheduler) execute(ctx context.Context, rule models.RecurringTransfer, scheduledAt time.Time, attempt int) {
+	execution := models.RecurringExecution{
+		RecurringTransferID: rule.ID,
+		ScheduledAt:         scheduledAt,
+		Status:              models.ExecutionSucceeded,
+		Attempt:             attempt,
+	}
+	transaction, err := s.transfers.Transfer(rule.TransferRequest())
+	execution.ExecutedAt = s.now().UTC()
+	switch {
+	case err == nil:
+		execution.TransactionID = transaction.ID
+		s.audit.Record(ctx, audit.TransferEvent(audit.RecurringActor(rule.ID), transaction))
+	case transient(err) && attempt <= len(s.retries):
+		retryAt := execution.ExecutedAt.Add(s.retries[attempt-1])
+		execution.Status, execution.Error, execution.RetryAt = models.ExecutionRetrying, err.Error(), &retryAt
+		slog.Warn("Recurring transfer refused, retry queued", "recurring_transfer_id", rule.ID,
+			"attempt", attempt, "retry_at", retryAt, "error", err)
+	default:
+		execution.Status, execution.Error = models.ExecutionFailed, err.Error()
+		slog.Error("Recurring transfer failed", "recurring_transfer_id", rule.ID, "attempt", attempt, "error", err)
+	}
+	if err := s.repo.AddRecurringExecution(ctx, execution); err != nil {
+		slog.Error("Failed to record recurring transfer execution", "recurring_transfer_id", rule.ID, "error", err)
+	}
+}
+
+// Run executes due recurring transfers every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	for {
+		if _, err := s.RunDue(ctx); err != nil {
+			slog.Error("Recurring transfer error", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// transient reports whether a transfer was refused for a reason that may clear by itself, e.g.
+// an insufficient balance before the next incoming payment or an unavailable exchange rate
+// Storage errors are not retried, as the transfer may have been booked before the error
+func transient(err error) bool {
+	for _, target := range []error{
+		service.ErrInsufficientBalance, service.ErrOverdrawn, service.ErrDailyAmountLimit, service.ErrDailyCountLimit,
+		service.ErrSourceFrozen, service.ErrDestinationFrozen, service.ErrTransactionConflict,
+		fx.ErrRateUnavailable, fx.ErrStaleRate, fx.ErrRateDeviation, preauth.ErrUnavailable,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// translate converts the repository's not-found error into ErrNotFound
+func translate(err error) error {
+	if err != nil && err.Error() == ErrNotFound.Error() {
+		return ErrNotFound
+	}
+	return err
+}
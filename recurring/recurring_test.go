@@ -0,0 +1,264 @@
+package recurring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/fx"
+	"internal-transfers/memory"
+	"internal-transfers/models"
+	"internal-transfers/preauth"
+	"internal-transfers/service"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2024, 3, 11, 9, 30, 15, 0, time.UTC) // a Monday
+	for _, tc := range []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 3, 11, 9, 45, 0, 0, time.UTC)},
+		{"0 9 1 * *", time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2024, 3, 12, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 12 14 * 3", time.Date(2024, 3, 13, 12, 0, 0, 0, time.UTC)}, // the 14th or any Wednesday
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", from.Add(90 * time.Minute)},
+	} {
+		schedule, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.spec, err)
+			continue
+		}
+		if next := schedule.Next(from); !next.Equal(tc.expected) {
+			t.Errorf("%s: expected %s, got %s", tc.spec, tc.expected, next)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "0 0 31 2 *", "*/0 * * * *", "5-1 * * * *", "@every 10s", "@every soon", "@fortnightly"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+// newScheduler returns a scheduler on an in-memory store with accounts 1 (balance 100) and 2
+func newScheduler(t *testing.T) (*Scheduler, *memory.Store, *time.Time) {
+	t.Helper()
+	store := memory.NewStore()
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	scheduler := NewScheduler(store.Recurring(), store.Accounts(), service.NewTransferService(store.Transactions()))
+	now := time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return now }
+	return scheduler, store, &now
+}
+
+func TestScheduler_Create(t *testing.T) {
+	scheduler, _, now := newScheduler(t)
+	ctx := context.Background()
+
+	rule, err := scheduler.Create(ctx, models.CreateRecurringTransferRequest{
+		SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.123456", Schedule: "0 9 * * *", Tenant: "acme",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rule.ID == 0 || rule.Status != models.RecurringActive || !rule.Amount.Equal(decimal.RequireFromString("10.12346")) ||
+		rule.TransferType != models.DefaultTransferType || rule.Tenant != "acme" || !rule.NextRunAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+
+	start := now.Add(48 * time.Hour)
+	if rule, err := scheduler.Create(ctx, models.CreateRecurringTransferRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", Schedule: "@daily", StartAt: &start}); err != nil || !rule.NextRunAt.Equal(start) {
+		t.Errorf("Expected the first run at start_at, got %+v (%v)", rule, err)
+	}
+
+	var validation *service.ValidationError
+	past := now.Add(-time.Minute)
+	for _, req := range []models.CreateRecurringTransferRequest{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", Schedule: "every day"},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "-1", Schedule: "@daily"},
+		{SourceAccountID: 1, DestinationAccountID: 1, Amount: "1", Schedule: "@daily"},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", Schedule: "@daily", TransferType: "wire"},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", Schedule: "@daily", StartAt: &past},
+	} {
+		if _, err := scheduler.Create(ctx, req); !errors.As(err, &validation) {
+			t.Errorf("%+v: expected a validation error, got %v", req, err)
+		}
+	}
+	if _, err := scheduler.Create(ctx, models.CreateRecurringTransferRequest{SourceAccountID: 1, DestinationAccountID: 9, Amount: "1", Schedule: "@daily"}); !errors.Is(err, service.ErrDestinationNotFound) {
+		t.Errorf("Expected ErrDestinationNotFound, got %v", err)
+	}
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	scheduler, store, now := newScheduler(t)
+	ctx := context.Background()
+	rule, _ := scheduler.Create(ctx, models.CreateRecurringTransferRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "40", Schedule: "0 9 * * *"})
+
+	if executed, err := scheduler.RunDue(ctx); err != nil || executed != 0 {
+		t.Fatalf("Expected nothing due before 09:00, got %d (%v)", executed, err)
+	}
+
+	// Due on three consecutive days; the third run lacks funds
+	for day := 0; day < 3; day++ {
+		*now = time.Date(2024, 3, 11+day, 9, 0, 30, 0, time.UTC)
+		if executed, err := scheduler.RunDue(ctx); err != nil || executed != 1 {
+			t.Fatalf("Day %d: expected one execution, got %d (%v)", day, executed, err)
+		}
+		if executed, _ := scheduler.RunDue(ctx); executed != 0 {
+			t.Fatalf("Day %d: expected a run to execute once, got %d more", day, executed)
+		}
+	}
+
+	executions, err := scheduler.Executions(ctx, rule.ID, 0, 10)
+	if err != nil || len(executions) != 3 {
+		t.Fatalf("Expected 3 executions, got %+v (%v)", executions, err)
+	}
+	if latest := executions[0]; latest.Status != models.ExecutionFailed || latest.Error != service.ErrInsufficientBalance.Error() || latest.TransactionID != 0 {
+		t.Errorf("Expected the latest execution to fail for insufficient balance, got %+v", latest)
+	}
+	if first := executions[2]; first.Status != models.ExecutionSucceeded || first.TransactionID == 0 ||
+		!first.ScheduledAt.Equal(time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected first execution %+v", first)
+	}
+	if account, _ := store.Accounts().GetAccount(2); !account.Balance.Equal(decimal.NewFromInt(80)) {
+		t.Errorf("Expected two transfers of 40 to be booked, balance %s", account.Balance)
+	}
+	if rule, _ = scheduler.Get(ctx, rule.ID); rule.Status != models.RecurringActive || !rule.NextRunAt.Equal(time.Date(2024, 3, 14, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the rule to stay active for the next day, got %+v", rule)
+	}
+}
+
+func TestScheduler_PauseResume(t *testing.T) {
+	scheduler, _, now := newScheduler(t)
+	ctx := context.Background()
+	rule, _ := scheduler.Create(ctx, models.CreateRecurringTransferRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1", Schedule: "@every 1h"})
+
+	if paused, err := scheduler.Pause(ctx, rule.ID); err != nil || paused.Status != models.RecurringPaused {
+		t.Fatalf("Expected a paused rule, got %+v (%v)", paused, err)
+	}
+	*now = now.Add(5 * time.Hour)
+	if executed, _ := scheduler.RunDue(ctx); executed != 0 {
+		t.Errorf("Expected a paused rule not to run, got %d executions", executed)
+	}
+
+	resumed, err := scheduler.Resume(ctx, rule.ID)
+	if err != nil || resumed.Status != models.RecurringActive || !resumed.NextRunAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the missed runs to be skipped, got %+v (%v)", resumed, err)
+	}
+
+	if err := scheduler.Delete(ctx, rule.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := scheduler.Get(ctx, rule.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if _, err := scheduler.Executions(ctx, rule.ID, 0, 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for the executions of a deleted rule, got %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("RECURRING_POLL_INTERVAL", "")
+	t.Setenv("RECURRING_RETRY_DELAYS", "")
+	if config, err := LoadConfig(); err != nil || len(config.RetryDelays) != len(DefaultRetryDelays) {
+		t.Errorf("Expected the default retry delays, got %+v (%v)", config, err)
+	}
+	t.Setenv("RECURRING_RETRY_DELAYS", "1m, 1h")
+	if config, err := LoadConfig(); err != nil || len(config.RetryDelays) != 2 || config.RetryDelays[1] != time.Hour {
+		t.Errorf("Expected two retry delays, got %+v (%v)", config, err)
+	}
+	t.Setenv("RECURRING_RETRY_DELAYS", "0")
+	if config, err := LoadConfig(); err != nil || len(config.RetryDelays) != 0 {
+		t.Errorf("Expected retrying to be disabled, got %+v (%v)", config, err)
+	}
+	for _, value := range []string{"soon", "1m,", "1m,-1h"} {
+		t.Setenv("RECURRING_RETRY_DELAYS", value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("RECURRING_RETRY_DELAYS %q: expected an error", value)
+		}
+	}
+}
+
+func TestScheduler_Retries(t *testing.T) {
+	scheduler, store, now := newScheduler(t)
+	scheduler.WithRetries([]time.Duration{10 * time.Minute, time.Hour})
+	ctx := context.Background()
+	rule, _ := scheduler.Create(ctx, models.CreateRecurringTransferRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "150", Schedule: "0 9 * * *"})
+	scheduledAt := time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)
+
+	// The first attempt lacks funds and is queued for a retry 10 minutes later
+	*now = scheduledAt.Add(30 * time.Second)
+	if executed, err := scheduler.RunDue(ctx); err != nil || executed != 1 {
+		t.Fatalf("Expected one execution, got %d (%v)", executed, err)
+	}
+	executions, _ := scheduler.Executions(ctx, rule.ID, 0, 10)
+	if len(executions) != 1 || executions[0].Status != models.ExecutionRetrying || executions[0].Attempt != 1 ||
+		executions[0].RetryAt == nil || !executions[0].RetryAt.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("Expected a queued retry, got %+v", executions)
+	}
+	if executed, _ := scheduler.RunDue(ctx); executed != 0 {
+		t.Fatalf("Expected the retry to wait, got %d executions", executed)
+	}
+
+	// Funded in time for the retry, which books the run as scheduled
+	store.Accounts().CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.NewFromInt(100)})
+	service.NewTransferService(store.Transactions()).Transfer(models.CreateTransactionRequest{SourceAccountID: 3, DestinationAccountID: 1, Amount: "100"})
+	*now = now.Add(10 * time.Minute)
+	if executed, err := scheduler.RunDue(ctx); err != nil || executed != 1 {
+		t.Fatalf("Expected the retry to execute, got %d (%v)", executed, err)
+	}
+	executions, _ = scheduler.Executions(ctx, rule.ID, 0, 10)
+	if latest := executions[0]; latest.Status != models.ExecutionSucceeded || latest.Attempt != 2 || latest.TransactionID == 0 ||
+		!latest.ScheduledAt.Equal(scheduledAt) {
+		t.Errorf("Expected the second attempt to succeed, got %+v", latest)
+	}
+	if executions[1].RetryAt != nil {
+		t.Errorf("Expected the retry to be taken off the queue, got %+v", executions[1])
+	}
+
+	// The next day's run fails all three attempts; the last one is final
+	*now = scheduledAt.Add(24 * time.Hour)
+	for attempt := 1; attempt <= 3; attempt++ {
+		if executed, err := scheduler.RunDue(ctx); err != nil || executed != 1 {
+			t.Fatalf("Attempt %d: expected one execution, got %d (%v)", attempt, executed, err)
+		}
+		*now = now.Add(time.Hour)
+	}
+	executions, _ = scheduler.Executions(ctx, rule.ID, 0, 10)
+	if latest := executions[0]; latest.Status != models.ExecutionFailed || latest.Attempt != 3 || latest.RetryAt != nil ||
+		latest.Error != service.ErrInsufficientBalance.Error() {
+		t.Errorf("Expected the third attempt to fail for good, got %+v", latest)
+	}
+	if executed, _ := scheduler.RunDue(ctx); executed != 0 {
+		t.Errorf("Expected nothing left to retry, got %d executions", executed)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected bool
+	}{
+		{service.ErrInsufficientBalance, true},
+		{service.ErrSourceFrozen, true},
+		{fmt.Errorf("converting: %w", fx.ErrStaleRate), true},
+		{fmt.Errorf("pre-authorizing: %w", preauth.ErrUnavailable), true},
+		{service.ErrDestinationNotFound, false},
+		{&service.ValidationError{Message: "Invalid amount format"}, false},
+		{errors.New("failed to commit transaction: connection reset"), false},
+	} {
+		if got := transient(tc.err); got != tc.expected {
+			t.Errorf("%v: expected transient %v, got %v", tc.err, tc.expected, got)
+		}
+	}
+}
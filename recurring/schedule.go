@@ -0,0 +1,180 @@
+package recurring
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinInterval is the shortest @every interval; the scheduler polls far less often than that anyway
+const MinInterval = time.Minute
+
+// Schedule computes the run times of a recurring transfer
+type Schedule interface {
+	// Next returns the first run time strictly after t, or the zero time if there is none
+	Next(t time.Time) time.Time
+}
+
+// macros are the named schedules accepted in place of a cron expression
+var macros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+}
+
+// ParseSchedule parses a schedule specification:
+//   - "@every <duration>", e.g. "@every 6h": runs at that interval from the previous run (at least
+//     MinInterval)
+//   - @hourly, @daily (@midnight), @weekly, @monthly, @yearly
+//   - A five-field cron expression "minute hour day-of-month month day-of-week", evaluated in UTC.
+//     Fields take numbers, *, ranges (1-5), lists (1,15) and steps (*/15, 0-30/10); day-of-week
+//     counts from 0 (Sunday) to 6, 7 also meaning Sunday. As in cron, when both day fields are
+//     restricted a day matching either one runs
+//
+// Returns an error describing the first problem found
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if value, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < MinInterval {
+			return nil, fmt.Errorf("invalid schedule %q (expected @every with a duration of at least %s)", spec, MinInterval)
+		}
+		return interval(d), nil
+	}
+	if expanded, ok := macros[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q (expected 5 cron fields, a macro such as @daily, or @every <duration>)", spec)
+	}
+	var c cronSchedule
+	for i, field := range []struct {
+		name     string
+		min, max int
+		bits     *uint64
+	}{
+		{"minute", 0, 59, &c.minute},
+		{"hour", 0, 23, &c.hour},
+		{"day of month", 1, 31, &c.dayOfMonth},
+		{"month", 1, 12, &c.month},
+		{"day of week", 0, 7, &c.dayOfWeek},
+	} {
+		bits, err := parseField(fields[i], field.min, field.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s %v", spec, field.name, err)
+		}
+		*field.bits = bits
+	}
+	if c.dayOfWeek&(1<<7) != 0 {
+		c.dayOfWeek |= 1
+	}
+	c.anyDayOfMonth = fields[2] == "*"
+	c.anyDayOfWeek = fields[4] == "*"
+
+	if c.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never runs", spec)
+	}
+	return &c, nil
+}
+
+// parseField parses one cron field into a bit set of the values it matches
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("has invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		low, high := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("has invalid value %q", from)
+			}
+			if high, err = strconv.Atoi(to); err != nil {
+				return 0, fmt.Errorf("has invalid value %q", to)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("has invalid value %q", rangePart)
+			}
+			low, high = n, n
+			if hasStep {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is outside %d-%d", item, min, max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// interval runs at a fixed duration after the previous run
+type interval time.Duration
+
+// Next returns t plus the interval
+func (d interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+// cronSchedule matches times whose fields are all in the corresponding bit sets (UTC)
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+	anyDayOfMonth, anyDayOfWeek                bool
+}
+
+// cronHorizon bounds the search for the next run; every valid expression matches within it
+// (February 29 on the required weekday included)
+const cronHorizon = 30
+
+// Next returns the first whole minute after t matching the expression
+func (c *cronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronHorizon, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's day rule: both day fields must match unless both are restricted, in
+// which case either may
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.anyDayOfMonth || c.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
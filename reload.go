@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"internal-transfers/config"
+	"internal-transfers/handlers"
+	"internal-transfers/logging"
+	"internal-transfers/rules"
+	"internal-transfers/usage"
+)
+
+// reloadableSettings names the settings a configuration reload applies while the server runs;
+// the others, such as the database address, keep their startup values until the next restart
+// The database credentials are applied by the storage's credential watcher, which reconnects the
+// pool at its next check (see database.PostgresStorage.WatchCredentials)
+var reloadableSettings = map[string]bool{
+	"LOG_LEVEL":    true,
+	"USAGE_QUOTAS": true,
+	"RULES_FILE":   true,
+	"DB_USER":      true,
+	"DB_PASSWORD":  true,
+}
+
+// reloadConfig re-reads the config file and applies the reloadable settings: the log level, the
+// usage quotas, and the transfer validation rules, whose file is re-read even if RULES_FILE is
+// unchanged
+// The settings are validated as the file would set them before the process environment changes,
+// so an invalid change leaves both the environment and the settings in effect as they were; a
+// changed setting that is not reloadable is logged as waiting for a restart
+func reloadConfig(source *config.Source, h *handlers.Handler) error {
+	pending, err := source.Read()
+	if err != nil {
+		return err
+	}
+	logConfig, err := logging.LoadConfigFrom(pending.Getenv)
+	if err != nil {
+		return err
+	}
+	usageConfig, err := usage.LoadConfigFrom(pending.Getenv)
+	if err != nil {
+		return err
+	}
+	transferRules, err := rules.LoadFrom(pending.Getenv)
+	if err != nil {
+		return err
+	}
+	changed := pending.Apply()
+
+	logging.SetLevel(logConfig.Level)
+	if recorder := h.Usage(); recorder != nil {
+		recorder.SetQuotas(usageConfig.Quotas)
+	}
+	if engine := h.Rules(); engine != nil {
+		engine.Replace(transferRules)
+	}
+
+	var restart []string
+	for _, name := range changed {
+		if !reloadableSettings[name] {
+			restart = append(restart, name)
+		}
+	}
+	if len(restart) > 0 {
+		slog.Warn("Changed settings take effect after a restart", "settings", restart)
+	}
+	slog.Info("Configuration reloaded", "changed", changed, "log_level", logConfig.Level,
+		"quotas", len(usageConfig.Quotas), "rules", transferRules.Len())
+	return nil
+}
+
+// watchConfig calls reload on SIGHUP, and when the config file of source changes, checked every
+// interval (never if 0), until ctx is done
+// A failed reload is logged and the previous settings stay in effect
+func watchConfig(ctx context.Context, source *config.Source, interval time.Duration, reload func() error) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var tick <-chan time.Time
+	if source.File != "" && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		reason := "SIGHUP"
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+		case <-tick:
+			if !source.Modified() {
+				continue
+			}
+			reason = "config file changed"
+		}
+		if err := reload(); err != nil {
+			slog.Error("Configuration reload failed; keeping the previous settings", "reason", reason, "error", err)
+		}
+	}
+}
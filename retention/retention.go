@@ -0,0 +1,204 @@
+// Package retention enforces how long each data class is kept. Policies come from the
+// environment; a scheduled job purges the rows older than their class's retention period in
+// batches, and dry runs report what a run would purge without deleting anything. The ledger of
+// record (transactions) is kept forever: balances, statements and reconciliation are computed
+// from it
+package retention
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// Enforcement defaults
+const (
+	DefaultInterval = 24 * time.Hour
+
+	// DefaultBatchSize bounds the rows one DELETE removes, so a purge never holds locks for long
+	DefaultBatchSize = 5000
+)
+
+// metrics exposes retention counters under "retention" at /debug/vars
+//   - runs: enforcement runs, including dry runs
+//   - deleted.<class>: rows purged per data class
+//   - failures: runs that failed for a data class
+var metrics = expvar.NewMap("retention")
+
+// deletedRows counts the rows purged per data class
+var deletedRows = new(expvar.Map).Init()
+
+func init() {
+	metrics.Set("deleted", deletedRows)
+}
+
+// Config holds the retention policies and their enforcement schedule
+type Config struct {
+	// AuditEvents and OutboxEvents are the retention periods of their classes; zero keeps forever
+	AuditEvents  time.Duration
+	OutboxEvents time.Duration
+
+	// Interval is how often policies are enforced; zero disables scheduled enforcement
+	Interval time.Duration
+
+	// DryRun makes scheduled runs only log what they would purge
+	DryRun bool
+}
+
+// LoadConfig reads the retention policies from the environment
+// Variables:
+//   - RETENTION_AUDIT_EVENTS_DAYS (0): Days audit events are kept; 0 keeps them forever
+//   - RETENTION_OUTBOX_EVENTS_DAYS (0): Days published outbox events are kept; 0 keeps them forever
+//   - RETENTION_INTERVAL (24h): How often policies are enforced; 0 disables scheduled enforcement
+//   - RETENTION_DRY_RUN (false): Scheduled runs only report what they would purge
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{Interval: DefaultInterval}
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+	}{{"RETENTION_AUDIT_EVENTS_DAYS", &config.AuditEvents}, {"RETENTION_OUTBOX_EVENTS_DAYS", &config.OutboxEvents}} {
+		if value := os.Getenv(setting.name); value != "" {
+			days, err := strconv.Atoi(value)
+			if err != nil || days < 0 {
+				return Config{}, fmt.Errorf("invalid %s %q", setting.name, value)
+			}
+			*setting.value = time.Duration(days) * 24 * time.Hour
+		}
+	}
+	if value := os.Getenv("RETENTION_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid RETENTION_INTERVAL %q", value)
+		}
+		config.Interval = d
+	}
+	if value := os.Getenv("RETENTION_DRY_RUN"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid RETENTION_DRY_RUN %q", value)
+		}
+		config.DryRun = dryRun
+	}
+	return config, nil
+}
+
+// Policies returns the effective policy of every data class, in a fixed order
+func (c Config) Policies() []models.RetentionPolicy {
+	return []models.RetentionPolicy{
+		{Class: models.RetentionTransactions, Note: "ledger of record; balances, statements and reconciliation are computed from it"},
+		{Class: models.RetentionAuditEvents, Retention: c.AuditEvents, Enforceable: true},
+		{Class: models.RetentionOutboxEvents, Retention: c.OutboxEvents, Enforceable: true, Note: "only published events are purged"},
+	}
+}
+
+// Enforcer applies the retention policies to the storage
+type Enforcer struct {
+	repo      database.RetentionRepositoryInterface
+	config    Config
+	batchSize int
+	now       func() time.Time
+}
+
+// NewEnforcer creates an enforcer purging through repo according to config
+func NewEnforcer(repo database.RetentionRepositoryInterface, config Config) *Enforcer {
+	return &Enforcer{repo: repo, config: config, batchSize: DefaultBatchSize, now: time.Now}
+}
+
+// Enforce counts the expired rows of every enforceable class with a retention period and, unless
+// dryRun, purges them in batches until none are left
+// A failing class is reported in the error after the other classes have been enforced
+func (e *Enforcer) Enforce(ctx context.Context, dryRun bool) (*models.RetentionReport, error) {
+	now := e.now().UTC()
+	report := &models.RetentionReport{CheckedAt: now, DryRun: dryRun}
+	var failed error
+	for _, policy := range e.config.Policies() {
+		result := models.RetentionResult{Policy: policy}
+		if policy.Enforceable && policy.Retention > 0 {
+			result.Cutoff = now.Add(-policy.Retention)
+			if err := e.enforce(ctx, &result, dryRun); err != nil {
+				metrics.Add("failures", 1)
+				failed = fmt.Errorf("%s: %w", policy.Class, err)
+				slog.ErrorContext(ctx, "Retention error", "class", policy.Class, "error", err)
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+	metrics.Add("runs", 1)
+	return report, failed
+}
+
+// enforce fills in the class's eligible rows and purges them unless dryRun
+func (e *Enforcer) enforce(ctx context.Context, result *models.RetentionResult, dryRun bool) error {
+	eligible, err := e.repo.CountExpired(ctx, result.Policy.Class, result.Cutoff)
+	if err != nil {
+		return err
+	}
+	result.Eligible = eligible
+	if dryRun {
+		return nil
+	}
+	for result.Deleted < eligible {
+		deleted, err := e.repo.DeleteExpired(ctx, result.Policy.Class, result.Cutoff, e.batchSize)
+		if err != nil {
+			return err
+		}
+		result.Deleted += deleted
+		deletedRows.Add(result.Policy.Class, deleted)
+		if deleted < int64(e.batchSize) {
+			break
+		}
+	}
+	if result.Deleted > 0 {
+		slog.InfoContext(ctx, "Expired data purged", "class", result.Policy.Class, "deleted", result.Deleted, "cutoff", result.Cutoff)
+	}
+	return nil
+}
+
+// Run enforces the policies every interval until ctx is cancelled, as dry runs if configured
+// The first run is one interval after the start
+func (e *Enforcer) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		report, err := e.Enforce(ctx, e.config.DryRun)
+		if err != nil || !report.DryRun {
+			continue
+		}
+		for _, result := range report.Results {
+			if result.Eligible > 0 {
+				slog.InfoContext(ctx, "Retention dry run", "class", result.Policy.Class, "eligible", result.Eligible, "cutoff", result.Cutoff)
+			}
+		}
+	}
+}
+
+// WriteReport writes the report as text, one line per data class
+func WriteReport(w io.Writer, report *models.RetentionReport) {
+	mode := "Purged"
+	if report.DryRun {
+		mode = "Dry run (nothing deleted)"
+	}
+	fmt.Fprintf(w, "%s at %s\n", mode, report.CheckedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "%-16s %-14s %-22s %12s %12s\n", "class", "retention", "cutoff", "eligible", "deleted")
+	for _, result := range report.Results {
+		retention, cutoff := "forever", "-"
+		if result.Policy.Retention > 0 {
+			retention = fmt.Sprintf("%d days", int(result.Policy.Retention/(24*time.Hour)))
+			cutoff = result.Cutoff.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%-16s %-14s %-22s %12d %12d\n", result.Policy.Class, retention, cutoff, result.Eligible, result.Deleted)
+	}
+}
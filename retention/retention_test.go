@@ -0,0 +1,140 @@
+package retention
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// fakeRepository holds the creation times of each data class's rows
+type fakeRepository struct {
+	rows    map[string][]time.Time
+	deletes int
+	failing string
+}
+
+func (r *fakeRepository) expired(class string, before time.Time) []int {
+	var indexes []int
+	for i, at := range r.rows[class] {
+		if at.Before(before) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func (r *fakeRepository) CountExpired(ctx context.Context, class string, before time.Time) (int64, error) {
+	if class == r.failing {
+		return 0, errors.New("database unavailable")
+	}
+	return int64(len(r.expired(class, before))), nil
+}
+
+func (r *fakeRepository) DeleteExpired(ctx context.Context, class string, before time.Time, limit int) (int64, error) {
+	r.deletes++
+	expired := r.expired(class, before)
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	removed := make(map[int]bool)
+	for _, i := range expired {
+		removed[i] = true
+	}
+	var kept []time.Time
+	for i, at := range r.rows[class] {
+		if !removed[i] {
+			kept = append(kept, at)
+		}
+	}
+	r.rows[class] = kept
+	return int64(len(expired)), nil
+}
+
+func TestLoadConfig(t *testing.T) {
+	for _, name := range []string{"RETENTION_AUDIT_EVENTS_DAYS", "RETENTION_OUTBOX_EVENTS_DAYS", "RETENTION_INTERVAL", "RETENTION_DRY_RUN"} {
+		t.Setenv(name, "")
+	}
+	config, err := LoadConfig()
+	if err != nil || config.AuditEvents != 0 || config.OutboxEvents != 0 || config.Interval != DefaultInterval || config.DryRun {
+		t.Errorf("Expected everything kept forever by default, got %+v (%v)", config, err)
+	}
+
+	t.Setenv("RETENTION_AUDIT_EVENTS_DAYS", "2555")
+	t.Setenv("RETENTION_OUTBOX_EVENTS_DAYS", "30")
+	t.Setenv("RETENTION_INTERVAL", "0")
+	t.Setenv("RETENTION_DRY_RUN", "true")
+	config, err = LoadConfig()
+	if err != nil || config.AuditEvents != 2555*24*time.Hour || config.OutboxEvents != 30*24*time.Hour || config.Interval != 0 || !config.DryRun {
+		t.Errorf("Unexpected config %+v (%v)", config, err)
+	}
+
+	for name, value := range map[string]string{"RETENTION_AUDIT_EVENTS_DAYS": "-1", "RETENTION_OUTBOX_EVENTS_DAYS": "30d", "RETENTION_INTERVAL": "daily", "RETENTION_DRY_RUN": "maybe"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := LoadConfig(); err == nil {
+				t.Errorf("%s %q: expected an error", name, value)
+			}
+		})
+	}
+}
+
+func TestEnforcer(t *testing.T) {
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	repo := &fakeRepository{rows: map[string][]time.Time{
+		models.RetentionAuditEvents:  {now.AddDate(0, 0, -40), now.AddDate(0, 0, -35), now.AddDate(0, 0, -31), now.AddDate(0, 0, -1)},
+		models.RetentionOutboxEvents: {now.AddDate(0, 0, -40)},
+	}}
+	enforcer := NewEnforcer(repo, Config{AuditEvents: 30 * 24 * time.Hour})
+	enforcer.now = func() time.Time { return now }
+	enforcer.batchSize = 2
+
+	report, err := enforcer.Enforce(context.Background(), true)
+	if err != nil || !report.DryRun || len(report.Results) != 3 {
+		t.Fatalf("Unexpected dry run %+v (%v)", report, err)
+	}
+	audit := report.Results[1]
+	if audit.Policy.Class != models.RetentionAuditEvents || audit.Eligible != 3 || audit.Deleted != 0 || !audit.Cutoff.Equal(now.AddDate(0, 0, -30)) {
+		t.Errorf("Unexpected audit result %+v", audit)
+	}
+	if transactions := report.Results[0]; transactions.Policy.Enforceable || !transactions.Cutoff.IsZero() {
+		t.Errorf("Expected transactions to be kept forever, got %+v", transactions)
+	}
+	// Outbox events without a retention period are kept
+	if outbox := report.Results[2]; outbox.Eligible != 0 || !outbox.Cutoff.IsZero() {
+		t.Errorf("Unexpected outbox result %+v", outbox)
+	}
+	if repo.deletes != 0 || len(repo.rows[models.RetentionAuditEvents]) != 4 {
+		t.Fatalf("Expected the dry run to delete nothing, got %d deletes", repo.deletes)
+	}
+
+	report, err = enforcer.Enforce(context.Background(), false)
+	if err != nil || report.DryRun || report.Results[1].Deleted != 3 {
+		t.Fatalf("Unexpected run %+v (%v)", report, err)
+	}
+	if repo.deletes != 2 || len(repo.rows[models.RetentionAuditEvents]) != 1 || len(repo.rows[models.RetentionOutboxEvents]) != 1 {
+		t.Errorf("Expected two batches purging the expired audit events, got %d deletes and %v", repo.deletes, repo.rows)
+	}
+
+	var out bytes.Buffer
+	WriteReport(&out, report)
+	if !strings.Contains(out.String(), "audit_events") || !strings.Contains(out.String(), "30 days") || !strings.Contains(out.String(), "forever") {
+		t.Errorf("Unexpected report:\n%s", out.String())
+	}
+}
+
+func TestEnforcer_Failure(t *testing.T) {
+	repo := &fakeRepository{rows: map[string][]time.Time{models.RetentionOutboxEvents: {time.Now().AddDate(-1, 0, 0)}}, failing: models.RetentionAuditEvents}
+	report, err := NewEnforcer(repo, Config{AuditEvents: time.Hour, OutboxEvents: time.Hour}).Enforce(context.Background(), false)
+	if err == nil || !strings.Contains(err.Error(), models.RetentionAuditEvents) {
+		t.Errorf("Expected the audit failure to be reported, got %v", err)
+	}
+	// The other classes are still enforced
+	if report == nil || report.Results[2].Deleted != 1 {
+		t.Errorf("Expected the outbox events to be purged, got %+v", report)
+	}
+}
@@ -0,0 +1,177 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// pathParamPattern matches mux path variables such as {account_id}
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// OpenAPI builds an OpenAPI 3.0 document describing the registered routes at the paths they are
+// served at; legacy aliases (see WithLegacyPaths) are listed as deprecated operations
+// Request and response schemas are derived by reflection from each route's body types
+func (r *Registry) OpenAPI(title, version string) map[string]any {
+	paths := make(map[string]any)
+	add := func(path, method string, op map[string]any) {
+		item, _ := paths[path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(method)] = op
+	}
+	for _, route := range r.routes {
+		add(r.path(route), route.Method, route.operation())
+		if r.aliased(route) {
+			op := route.operation()
+			op["operationId"] = legacyName(route.Name)
+			op["deprecated"] = true
+			add(route.Path, route.Method, op)
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+	}
+}
+
+// OpenAPIHandler serves the registry's OpenAPI document as JSON
+// The document is built once; routes registered afterwards are not reflected
+func (r *Registry) OpenAPIHandler(title, version string) http.HandlerFunc {
+	doc, err := json.Marshal(r.OpenAPI(title, version))
+	return func(w http.ResponseWriter, req *http.Request) {
+		if err != nil {
+			http.Error(w, "Failed to build API description", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}
+}
+
+// operation describes a single route as an OpenAPI operation object
+func (route Route) operation() map[string]any {
+	op := map[string]any{
+		"operationId": route.Name,
+	}
+	if route.Summary != "" {
+		op["summary"] = route.Summary
+	}
+	if route.IdempotencyKey != "" {
+		op["x-idempotency-key"] = route.IdempotencyKey
+	}
+
+	var params []any
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		params = append(params, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   pathParamSchema(match[1]),
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.Request != nil {
+		content := map[string]any{"schema": schemaFor(reflect.TypeOf(route.Request))}
+		if route.Example != nil {
+			content["example"] = route.Example
+		}
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": content},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := map[string]any{"description": http.StatusText(status)}
+	if route.Response != nil {
+		response["content"] = map[string]any{
+			"application/json": map[string]any{"schema": schemaFor(reflect.TypeOf(route.Response))},
+		}
+	}
+	op["responses"] = map[string]any{strconv.Itoa(status): response}
+
+	return op
+}
+
+// pathParamSchema returns the schema of a path parameter; *_id parameters are 64-bit integers
+func pathParamSchema(name string) map[string]any {
+	if strings.HasSuffix(name, "_id") {
+		return map[string]any{"type": "integer", "format": "int64"}
+	}
+	return map[string]any{"type": "string"}
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+)
+
+// schemaFor derives a JSON schema for t following encoding/json field naming rules
+func schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case decimalType:
+		return map[string]any{"type": "string", "format": "decimal"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = schemaFor(field.Type)
+		}
+		return map[string]any{"type": "object", "properties": properties}
+	default:
+		return map[string]any{}
+	}
+}
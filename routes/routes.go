@@ -0,0 +1,224 @@
+// Package routes holds the declarative route registry
+// Every endpoint is described once (method, path, handler and its per-endpoint policy); the
+// registry mounts it on the router and derives the OpenAPI document and metrics labels from the
+// same table, so per-endpoint policy cannot drift between those places
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/middleware"
+)
+
+// Route describes a single endpoint and its policy
+type Route struct {
+	// Name is a stable identifier used as the metrics label and OpenAPI operationId
+	Name    string
+	Method  string
+	Path    string
+	Summary string
+	Handler http.HandlerFunc
+
+	// Timeout bounds handler execution (503 on expiry); zero disables it, e.g. for streaming
+	Timeout time.Duration
+
+	// BodyLimit caps the request body size in bytes; zero applies the registry's limit (see
+	// WithBodyLimit), so only routes accepting larger bodies, such as file uploads, set it
+	BodyLimit int64
+
+	// OptOut lists middleware chain entries this route skips
+	OptOut []string
+
+	// Request and Response are zero values of the JSON body types, used for OpenAPI schemas
+	Request  any
+	Response any
+
+	// Example is a sample request body shown in the generated documentation
+	Example any
+
+	// Status is the success status code documented for the route (defaults to 200)
+	Status int
+
+	// IdempotencyKey names the request body field that makes retrying the request safe: the
+	// server refuses a second request with the same value (409), so a retry cannot apply it twice
+	// Documented as x-idempotency-key; the generated SDKs retry such requests when the field is set
+	IdempotencyKey string
+
+	// Unversioned serves the route at its path alone, outside the registry's version prefix, for
+	// endpoints probed by infrastructure such as health checks; list it in one version only
+	Unversioned bool
+}
+
+// DefaultMaxBodyBytes is the default request body limit of routes without their own; 64 KiB is
+// far above any valid JSON request payload
+const DefaultMaxBodyBytes = 64 << 10
+
+// Config controls the policy applied to every mounted route
+type Config struct {
+	// MaxBodyBytes caps the request body of routes without their own BodyLimit; larger bodies are
+	// refused with 413 before a handler can buffer them
+	MaxBodyBytes int64
+}
+
+// LoadConfig reads the route policy from the environment
+// Variables:
+//   - MAX_REQUEST_BODY_BYTES (65536): Largest request body accepted by routes without their own
+//     limit
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{MaxBodyBytes: DefaultMaxBodyBytes}
+	if value := os.Getenv("MAX_REQUEST_BODY_BYTES"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("invalid MAX_REQUEST_BODY_BYTES %q", value)
+		}
+		config.MaxBodyBytes = n
+	}
+	return config, nil
+}
+
+// Registry is an ordered collection of routes
+// A registry holds one version of the API; versions coexist as registries with their own routes and
+// prefix mounted on the same router
+type Registry struct {
+	routes    []Route
+	bodyLimit int64
+	prefix    string
+	legacy    bool
+}
+
+// NewRegistry creates a registry containing the given routes
+func NewRegistry(routes ...Route) *Registry {
+	r := &Registry{}
+	r.Register(routes...)
+	return r
+}
+
+// Register adds routes to the registry
+func (r *Registry) Register(routes ...Route) {
+	r.routes = append(r.routes, routes...)
+}
+
+// Routes returns a copy of the registered routes in registration order
+func (r *Registry) Routes() []Route {
+	return append([]Route(nil), r.routes...)
+}
+
+// WithBodyLimit caps the request body of the routes without their own BodyLimit at limit bytes;
+// zero, the default, leaves them unlimited
+// Returns the registry to allow chaining after NewRegistry
+func (r *Registry) WithBodyLimit(limit int64) *Registry {
+	r.bodyLimit = limit
+	return r
+}
+
+// WithPrefix serves the routes under prefix, the path of the API version they make up, e.g. "/v1"
+// Route paths stay relative to it, so listener scopes and route tables are version-independent
+// Returns the registry to allow chaining after NewRegistry
+func (r *Registry) WithPrefix(prefix string) *Registry {
+	r.prefix = prefix
+	return r
+}
+
+// WithLegacyPaths also serves the prefixed routes at their unprefixed paths, as deprecated aliases
+// whose responses carry a Warning header naming the versioned path
+// Meant for the version that predates versioning, so clients calling the old paths keep working
+// Returns the registry to allow chaining after NewRegistry
+func (r *Registry) WithLegacyPaths() *Registry {
+	r.legacy = true
+	return r
+}
+
+// Filter returns a registry of the routes keep accepts, in registration order, with the same
+// body limit and paths
+func (r *Registry) Filter(keep func(Route) bool) *Registry {
+	filtered := &Registry{bodyLimit: r.bodyLimit, prefix: r.prefix, legacy: r.legacy}
+	for _, route := range r.routes {
+		if keep(route) {
+			filtered.routes = append(filtered.routes, route)
+		}
+	}
+	return filtered
+}
+
+// Mount registers every route on the router, wrapping each handler with the chain (minus the
+// route's opt-outs) and the route's own policy (timeout and body limit)
+// The route name is attached outermost so every middleware in the chain can label by it; a legacy
+// alias shares the name, and so the metrics and policy, of its versioned route
+func (r *Registry) Mount(router *mux.Router, chain middleware.Chain) {
+	for _, route := range r.routes {
+		if route.BodyLimit == 0 {
+			route.BodyLimit = r.bodyLimit
+		}
+		h := middleware.WithRouteName(route.Name)(chain.Without(route.OptOut...).Then(route.policy()))
+		router.Handle(r.path(route), h).
+			Methods(route.Method).
+			Name(route.Name)
+		if r.aliased(route) {
+			router.Handle(route.Path, deprecated(r.prefix, h)).
+				Methods(route.Method).
+				Name(legacyName(route.Name))
+		}
+	}
+}
+
+// path returns the path the route is served at
+func (r *Registry) path(route Route) string {
+	if route.Unversioned {
+		return route.Path
+	}
+	return r.prefix + route.Path
+}
+
+// aliased reports whether the route is also served at its unprefixed path
+func (r *Registry) aliased(route Route) bool {
+	return r.legacy && r.prefix != "" && !route.Unversioned
+}
+
+// legacyName names the alias of a route at its unprefixed path, in the router and the OpenAPI
+// document, where names must be unique
+func legacyName(name string) string {
+	return "legacy_" + name
+}
+
+// deprecated serves a legacy alias, adding a Warning header naming the versioned path to every
+// response, including those the middleware chain refuses
+func deprecated(prefix string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Warning", fmt.Sprintf(`299 - "Deprecated API path; use %s%s"`, prefix, r.URL.EscapedPath()))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// policy wraps the route handler with its per-endpoint limits
+func (route Route) policy() http.Handler {
+	var h http.Handler = route.Handler
+	if route.BodyLimit > 0 {
+		h = bodyLimit(route.BodyLimit, h)
+	}
+	if route.Timeout > 0 {
+		h = http.TimeoutHandler(h, route.Timeout, "Request timed out")
+	}
+	return h
+}
+
+// bodyLimit caps the number of bytes a handler can read from the request body
+// A body declaring a larger Content-Length is refused with 413 without being read; otherwise reads
+// past the limit fail with *http.MaxBytesError, which handlers report as 413
+func bodyLimit(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,236 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"internal-transfers/middleware"
+)
+
+type testRequest struct {
+	AccountID int64     `json:"account_id"`
+	Amount    string    `json:"amount"`
+	At        time.Time `json:"at"`
+	Internal  string    `json:"-"`
+}
+
+func TestRegistry_MountAppliesPolicy(t *testing.T) {
+	var routeName string
+	registry := NewRegistry(
+		Route{
+			Name: "echo", Method: "POST", Path: "/echo", BodyLimit: 8,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				routeName = middleware.RouteNameFromContext(r.Context())
+				if _, err := io.ReadAll(r.Body); err != nil {
+					http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+				}
+			},
+		},
+		Route{
+			Name: "slow", Method: "GET", Path: "/slow", Timeout: 10 * time.Millisecond,
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+		},
+	)
+
+	router := mux.NewRouter()
+	registry.Mount(router, middleware.NewChain())
+
+	t.Run("Body limit", func(t *testing.T) {
+		// A streamed body of unknown length fails the handler's read
+		req := httptest.NewRequest("POST", "/echo", strings.NewReader("0123456789"))
+		req.ContentLength = -1
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected 413 for oversized body, got %d", rr.Code)
+		}
+		if routeName != "echo" {
+			t.Errorf("Expected route name echo in context, got %q", routeName)
+		}
+
+		// A declared oversized body is refused before the handler runs
+		routeName = ""
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/echo", strings.NewReader("0123456789")))
+		if rr.Code != http.StatusRequestEntityTooLarge || routeName != "" {
+			t.Errorf("Expected 413 without running the handler, got %d (%q)", rr.Code, routeName)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/slow", nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 on timeout, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Method restriction", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/echo", nil))
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", rr.Code)
+		}
+	})
+}
+
+func TestRegistry_WithBodyLimit(t *testing.T) {
+	read := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+		}
+	}
+	registry := NewRegistry(
+		Route{Name: "small", Method: "POST", Path: "/small", Handler: read},
+		Route{Name: "upload", Method: "POST", Path: "/upload", BodyLimit: 64, Handler: read},
+	).WithBodyLimit(8).Filter(func(Route) bool { return true })
+
+	router := mux.NewRouter()
+	registry.Mount(router, middleware.NewChain())
+	for _, tc := range []struct {
+		path string
+		want int
+	}{{"/small", http.StatusRequestEntityTooLarge}, {"/upload", http.StatusOK}} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", tc.path, strings.NewReader("0123456789")))
+		if rr.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.path, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "")
+	if config, err := LoadConfig(); err != nil || config.MaxBodyBytes != DefaultMaxBodyBytes {
+		t.Errorf("Expected the default limit, got %+v (%v)", config, err)
+	}
+	t.Setenv("MAX_REQUEST_BODY_BYTES", "1048576")
+	if config, err := LoadConfig(); err != nil || config.MaxBodyBytes != 1<<20 {
+		t.Errorf("Expected a 1 MiB limit, got %+v (%v)", config, err)
+	}
+	for _, value := range []string{"64KiB", "0", "-1"} {
+		t.Setenv("MAX_REQUEST_BODY_BYTES", value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("MAX_REQUEST_BODY_BYTES %q: expected an error", value)
+		}
+	}
+}
+
+func TestRegistry_OpenAPI(t *testing.T) {
+	registry := NewRegistry(Route{
+		Name: "create_thing", Method: "POST", Path: "/things/{account_id}",
+		Summary: "Create a thing", Request: testRequest{}, Status: http.StatusCreated,
+		Example: testRequest{AccountID: 1, Amount: "5"}, IdempotencyKey: "amount",
+	})
+
+	rr := httptest.NewRecorder()
+	registry.OpenAPIHandler("Test API", "1.0").ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID    string `json:"operationId"`
+			IdempotencyKey string `json:"x-idempotency-key"`
+			Parameters     []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]string `json:"properties"`
+					} `json:"schema"`
+					Example map[string]any `json:"example"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]any `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document: %v", err)
+	}
+
+	op, ok := doc.Paths["/things/{account_id}"]["post"]
+	if !ok {
+		t.Fatalf("Expected POST /things/{account_id} in document, got %+v", doc.Paths)
+	}
+	if op.OperationID != "create_thing" {
+		t.Errorf("Expected operationId create_thing, got %q", op.OperationID)
+	}
+	if op.IdempotencyKey != "amount" {
+		t.Errorf("Expected x-idempotency-key amount, got %q", op.IdempotencyKey)
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "account_id" || op.Parameters[0].In != "path" {
+		t.Errorf("Unexpected parameters: %+v", op.Parameters)
+	}
+	if _, ok := op.Responses["201"]; !ok {
+		t.Errorf("Expected 201 response, got %+v", op.Responses)
+	}
+
+	content := op.RequestBody.Content["application/json"]
+	props := content.Schema.Properties
+	if props["account_id"]["format"] != "int64" || props["amount"]["type"] != "string" || props["at"]["format"] != "date-time" {
+		t.Errorf("Unexpected request schema: %+v", props)
+	}
+	if _, ok := props["Internal"]; ok {
+		t.Error("Fields tagged json:\"-\" must be omitted")
+	}
+	if content.Example["amount"] != "5" {
+		t.Errorf("Expected example to be included, got %+v", content.Example)
+	}
+}
+
+func TestRegistry_WithPrefix(t *testing.T) {
+	var routeName string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		routeName = middleware.RouteNameFromContext(r.Context())
+	}
+	registry := NewRegistry(
+		Route{Name: "get_thing", Method: "GET", Path: "/things/{thing_id}", Handler: handler},
+		Route{Name: "health", Method: "GET", Path: "/health", Handler: handler, Unversioned: true},
+	).WithPrefix("/v1").WithLegacyPaths().Filter(func(Route) bool { return true })
+
+	router := mux.NewRouter()
+	registry.Mount(router, middleware.NewChain())
+	for _, tc := range []struct {
+		path    string
+		status  int
+		warning string
+	}{
+		{"/v1/things/7", http.StatusOK, ""},
+		{"/things/7", http.StatusOK, `299 - "Deprecated API path; use /v1/things/7"`},
+		{"/health", http.StatusOK, ""},
+		{"/v1/health", http.StatusNotFound, ""},
+	} {
+		routeName = ""
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+		if rr.Code != tc.status || rr.Header().Get("Warning") != tc.warning {
+			t.Errorf("%s: expected %d with Warning %q, got %d with %q", tc.path, tc.status, tc.warning, rr.Code, rr.Header().Get("Warning"))
+		}
+		if tc.status == http.StatusOK && routeName == "" {
+			t.Errorf("%s: expected the route name in context", tc.path)
+		}
+	}
+
+	paths := registry.OpenAPI("Test API", "1.0")["paths"].(map[string]any)
+	if len(paths) != 3 {
+		t.Errorf("Expected the versioned, legacy and unversioned paths, got %v", paths)
+	}
+	legacy := paths["/things/{thing_id}"].(map[string]any)["get"].(map[string]any)
+	if legacy["operationId"] != "legacy_get_thing" || legacy["deprecated"] != true {
+		t.Errorf("Expected a deprecated legacy operation, got %v", legacy)
+	}
+	if current := paths["/v1/things/{thing_id}"].(map[string]any)["get"].(map[string]any); current["operationId"] != "get_thing" || current["deprecated"] != nil {
+		t.Errorf("Unexpected versioned operation %v", current)
+	}
+}
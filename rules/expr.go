@@ -0,0 +1,338 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// Expressions are boolean conditions over the transfer and the state of both accounts, e.g.
+//
+//	transfer_type == "wire" and amount > 10000
+//	destination.status != "active" or source.tags contains "restricted"
+//
+// Grammar (keywords are case-insensitive):
+//
+//	expr       = and { "or" and }
+//	and        = not { "and" not }
+//	not        = "not" not | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "contains" ) operand ]
+//	operand    = number | string | "true" | "false" | field | "(" expr ")"
+//
+// Expressions are type-checked when rules are loaded: numbers compare with numbers, strings with
+// strings (== and != only), and contains tests a tag list for a string. A rule's condition must be
+// boolean; a transfer is rejected when it evaluates to true
+
+// valueType is the static type of an expression
+type valueType int
+
+const (
+	typeNumber valueType = iota
+	typeString
+	typeBool
+	typeList
+)
+
+func (t valueType) String() string {
+	return [...]string{"number", "string", "boolean", "list"}[t]
+}
+
+// evaluator computes an expression's value for an input
+// The dynamic type of the result always matches the expression's static type:
+// decimal.Decimal, string, bool or []string
+type evaluator func(in Input) interface{}
+
+// fields are the identifiers available to expressions
+var fields = map[string]struct {
+	typ  valueType
+	eval evaluator
+}{
+	"amount":                 {typeNumber, func(in Input) interface{} { return in.Transfer.Amount }},
+	"transfer_type":          {typeString, func(in Input) interface{} { return in.Transfer.TransferType }},
+	"tenant":                 {typeString, func(in Input) interface{} { return in.Tenant }},
+	"source.id":              {typeNumber, func(in Input) interface{} { return decimal.NewFromInt(in.Source.AccountID) }},
+	"source.balance":         {typeNumber, func(in Input) interface{} { return in.Source.Balance }},
+	"source.status":          {typeString, func(in Input) interface{} { return in.Source.Status }},
+	"source.tags":            {typeList, func(in Input) interface{} { return in.Source.Tags }},
+	"source.currency":        {typeString, func(in Input) interface{} { return in.Source.Currency }},
+	"destination.id":         {typeNumber, func(in Input) interface{} { return decimal.NewFromInt(in.Destination.AccountID) }},
+	"destination.balance":    {typeNumber, func(in Input) interface{} { return in.Destination.Balance }},
+	"destination.status":     {typeString, func(in Input) interface{} { return in.Destination.Status }},
+	"destination.tags":       {typeList, func(in Input) interface{} { return in.Destination.Tags }},
+	"destination.currency":   {typeString, func(in Input) interface{} { return in.Destination.Currency }},
+	"source_account_id":      {typeNumber, func(in Input) interface{} { return decimal.NewFromInt(in.Transfer.SourceAccountID) }},
+	"destination_account_id": {typeNumber, func(in Input) interface{} { return decimal.NewFromInt(in.Transfer.DestinationAccountID) }},
+}
+
+// Compile parses and type-checks a boolean expression
+func Compile(expression string) (func(in Input) bool, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	eval, typ, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if typ != typeBool {
+		return nil, fmt.Errorf("expression is a %s, expected a condition", typ)
+	}
+	return func(in Input) bool { return eval(in).(bool) }, nil
+}
+
+// tokenKind classifies tokens
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenString
+	tokenWord
+	tokenOperator
+	tokenParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits an expression into tokens
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{tokenParen, string(c)})
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, token{tokenString, s[i+1 : i+1+end]})
+			i += end + 2
+		case strings.ContainsRune("=!<>", c):
+			op := string(c)
+			if i+1 < len(s) && s[i+1] == '=' {
+				op += "="
+			}
+			if op == "=" || op == "!" {
+				return nil, fmt.Errorf("unknown operator %q at offset %d", op, i)
+			}
+			tokens = append(tokens, token{tokenOperator, op})
+			i += len(op)
+		case unicode.IsDigit(c) || c == '.' || c == '-':
+			j := i + 1
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, s[i:j]})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_' || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenWord, s[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser producing typed evaluators
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// keyword consumes the next token if it is the given keyword
+func (p *parser) keyword(word string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenWord && strings.EqualFold(p.tokens[p.pos].text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (evaluator, valueType, error) {
+	left, typ, err := p.and()
+	for err == nil && p.keyword("or") {
+		if typ != typeBool {
+			return nil, 0, fmt.Errorf("or needs conditions, got a %s", typ)
+		}
+		var right evaluator
+		if right, err = p.operand(p.and, "or"); err == nil {
+			l := left
+			left = func(in Input) interface{} { return l(in).(bool) || right(in).(bool) }
+		}
+	}
+	return left, typ, err
+}
+
+func (p *parser) and() (evaluator, valueType, error) {
+	left, typ, err := p.not()
+	for err == nil && p.keyword("and") {
+		if typ != typeBool {
+			return nil, 0, fmt.Errorf("and needs conditions, got a %s", typ)
+		}
+		var right evaluator
+		if right, err = p.operand(p.not, "and"); err == nil {
+			l := left
+			left = func(in Input) interface{} { return l(in).(bool) && right(in).(bool) }
+		}
+	}
+	return left, typ, err
+}
+
+// operand parses the right-hand side of a boolean operator and checks it is a condition
+func (p *parser) operand(next func() (evaluator, valueType, error), op string) (evaluator, error) {
+	eval, typ, err := next()
+	if err != nil {
+		return nil, err
+	}
+	if typ != typeBool {
+		return nil, fmt.Errorf("%s needs conditions, got a %s", op, typ)
+	}
+	return eval, nil
+}
+
+func (p *parser) not() (evaluator, valueType, error) {
+	if p.keyword("not") {
+		inner, err := p.operand(p.not, "not")
+		if err != nil {
+			return nil, 0, err
+		}
+		return func(in Input) interface{} { return !inner(in).(bool) }, typeBool, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (evaluator, valueType, error) {
+	left, leftType, err := p.primary()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var op string
+	switch {
+	case p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator:
+		op = p.tokens[p.pos].text
+		p.pos++
+	case p.keyword("contains"):
+		op = "contains"
+	default:
+		return left, leftType, nil
+	}
+
+	right, rightType, err := p.primary()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch {
+	case op == "contains":
+		if leftType != typeList || rightType != typeString {
+			return nil, 0, fmt.Errorf("contains needs a tag list and a string, got %s and %s", leftType, rightType)
+		}
+		return func(in Input) interface{} {
+			needle := right(in).(string)
+			for _, item := range left(in).([]string) {
+				if item == needle {
+					return true
+				}
+			}
+			return false
+		}, typeBool, nil
+	case leftType != rightType:
+		return nil, 0, fmt.Errorf("cannot compare %s with %s", leftType, rightType)
+	case leftType == typeNumber:
+		return func(in Input) interface{} {
+			cmp := left(in).(decimal.Decimal).Cmp(right(in).(decimal.Decimal))
+			return compareResult(op, cmp)
+		}, typeBool, nil
+	case leftType == typeList:
+		return nil, 0, fmt.Errorf("tag lists only support contains")
+	case op != "==" && op != "!=":
+		return nil, 0, fmt.Errorf("%s values only support == and !=", leftType)
+	default:
+		return func(in Input) interface{} {
+			return (left(in) == right(in)) == (op == "==")
+		}, typeBool, nil
+	}
+}
+
+// compareResult applies a comparison operator to a Cmp result
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func (p *parser) primary() (evaluator, valueType, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, 0, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+
+	switch t.kind {
+	case tokenNumber:
+		n, err := decimal.NewFromString(t.text)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid number %q", t.text)
+		}
+		return func(Input) interface{} { return n }, typeNumber, nil
+	case tokenString:
+		return func(Input) interface{} { return t.text }, typeString, nil
+	case tokenParen:
+		if t.text != "(" {
+			return nil, 0, fmt.Errorf("unexpected )")
+		}
+		inner, typ, err := p.or()
+		if err != nil {
+			return nil, 0, err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].text != ")" {
+			return nil, 0, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, typ, nil
+	case tokenWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return func(Input) interface{} { return true }, typeBool, nil
+		case "false":
+			return func(Input) interface{} { return false }, typeBool, nil
+		}
+		field, ok := fields[strings.ToLower(t.text)]
+		if !ok {
+			return nil, 0, fmt.Errorf("unknown field %q", t.text)
+		}
+		return field.eval, field.typ, nil
+	default:
+		return nil, 0, fmt.Errorf("unexpected %q", t.text)
+	}
+}
@@ -0,0 +1,263 @@
+// Package rules evaluates additional, configurable validation rules for transfers
+// Rules come from a JSON file (RULES_FILE), so business teams can add or change them without a
+// code deploy. A rule is either an expression evaluated against the transfer and the state of both
+// accounts (see Compile), or a named Go rule registered in-process with Register. Each rule
+// applies to every tenant or only to the tenants it lists
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"internal-transfers/models"
+)
+
+// DefaultTenant is used for requests that do not name a tenant
+const DefaultTenant = "default"
+
+// Input is what a rule sees: the prepared transfer and a snapshot of both accounts
+// Balances are read before the transfer commits, so rules must not replace the atomic balance
+// check made when the transfer is booked
+type Input struct {
+	Tenant      string
+	Transfer    models.Transfer
+	Source      models.Account
+	Destination models.Account
+}
+
+// Rule is an in-process validation rule
+// Check returns a non-empty message to reject the transfer; an error means the rule could not be
+// evaluated, and the transfer is rejected as failed rather than allowed
+type Rule interface {
+	Check(in Input) (violation string, err error)
+}
+
+// RuleFunc adapts a function to the Rule interface
+type RuleFunc func(in Input) (string, error)
+
+// Check calls f
+func (f RuleFunc) Check(in Input) (string, error) { return f(in) }
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Rule)
+)
+
+// Register makes an in-process rule available to the rules file under name
+// It is intended to be called from init functions of plugin packages linked into the binary
+// Registering the same name twice panics
+func Register(name string, rule Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("rules: rule %q registered twice", name))
+	}
+	registry[name] = rule
+}
+
+// Registered returns the names of the registered in-process rules, sorted
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Definition is one entry of the rules file
+// Exactly one of When (an expression) or Plugin (a registered rule name) is set
+type Definition struct {
+	Name    string   `json:"name"`
+	When    string   `json:"when,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Plugin  string   `json:"plugin,omitempty"`
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+// Violation reports the rule that rejected a transfer
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("rejected by rule %s: %s", v.Rule, v.Message)
+}
+
+// compiled is a loaded rule ready to evaluate
+type compiled struct {
+	name    string
+	tenants map[string]bool
+	rule    Rule
+}
+
+// Engine evaluates the configured rules in file order
+// A nil Engine has no rules
+// Safe for concurrent use
+type Engine struct {
+	mu    sync.RWMutex
+	rules []compiled
+}
+
+// Replace makes the engine evaluate the rules of next instead of its own, e.g. when the rules file
+// is reloaded; transfers already being evaluated finish with the previous rules
+// A nil next removes every rule
+func (e *Engine) Replace(next *Engine) {
+	list := next.loaded()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = list
+}
+
+// loaded returns the rules to evaluate
+func (e *Engine) loaded() []compiled {
+	if e == nil {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules
+}
+
+// New compiles rule definitions
+// Returns an error naming the first invalid definition: a missing or duplicate name, both or
+// neither of when and plugin, an expression that does not compile, or an unregistered plugin
+func New(definitions []Definition) (*Engine, error) {
+	engine := &Engine{}
+	seen := make(map[string]bool)
+	for _, d := range definitions {
+		if d.Name == "" {
+			return nil, fmt.Errorf("rule without a name")
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("duplicate rule %q", d.Name)
+		}
+		seen[d.Name] = true
+
+		rule, err := d.compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", d.Name, err)
+		}
+		c := compiled{name: d.Name, rule: rule}
+		if len(d.Tenants) > 0 {
+			c.tenants = make(map[string]bool, len(d.Tenants))
+			for _, tenant := range d.Tenants {
+				c.tenants[tenant] = true
+			}
+		}
+		engine.rules = append(engine.rules, c)
+	}
+	return engine, nil
+}
+
+// compile turns a definition into a Rule
+func (d Definition) compile() (Rule, error) {
+	switch {
+	case d.When != "" && d.Plugin != "":
+		return nil, fmt.Errorf("set either when or plugin, not both")
+	case d.Plugin != "":
+		registryMu.RLock()
+		rule, ok := registry[d.Plugin]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown plugin %q (registered: %v)", d.Plugin, Registered())
+		}
+		return rule, nil
+	case d.When != "":
+		condition, err := Compile(d.When)
+		if err != nil {
+			return nil, err
+		}
+		message := d.Message
+		if message == "" {
+			message = "transfer not allowed"
+		}
+		return RuleFunc(func(in Input) (string, error) {
+			if condition(in) {
+				return message, nil
+			}
+			return "", nil
+		}), nil
+	default:
+		return nil, fmt.Errorf("set when or plugin")
+	}
+}
+
+// Load reads the rules file named by RULES_FILE
+// File format: {"rules": [{"name": ..., "when": ..., "message": ..., "tenants": [...]}, ...]}
+// Returns a nil Engine (no rules) if RULES_FILE is unset
+func Load() (*Engine, error) {
+	return LoadFrom(os.Getenv)
+}
+
+// LoadFrom reads the rules file named by RULES_FILE as returned by getenv, e.g. to validate a
+// configuration reload before it is applied (see Load)
+func LoadFrom(getenv func(string) string) (*Engine, error) {
+	path := getenv("RULES_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RULES_FILE: %w", err)
+	}
+	var file struct {
+		Rules []Definition `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid RULES_FILE: %w", err)
+	}
+	engine, err := New(file.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RULES_FILE: %w", err)
+	}
+	return engine, nil
+}
+
+// Len returns the number of loaded rules
+func (e *Engine) Len() int {
+	return len(e.loaded())
+}
+
+// Applicable returns the names of the rules that apply to the tenant's transfers, in evaluation
+// order; the empty tenant is DefaultTenant
+func (e *Engine) Applicable(tenant string) []string {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	var names []string
+	for _, c := range e.loaded() {
+		if c.tenants == nil || c.tenants[tenant] {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+// Evaluate runs the rules that apply to the input's tenant in order and stops at the first
+// violation
+// Returns a *Violation if a rule rejects the transfer, another error if a rule failed, or nil
+func (e *Engine) Evaluate(in Input) error {
+	if in.Tenant == "" {
+		in.Tenant = DefaultTenant
+	}
+	for _, c := range e.loaded() {
+		if c.tenants != nil && !c.tenants[in.Tenant] {
+			continue
+		}
+		message, err := c.rule.Check(in)
+		if err != nil {
+			return fmt.Errorf("rule %s failed: %w", c.name, err)
+		}
+		if message != "" {
+			return &Violation{Rule: c.name, Message: message}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package rules
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+func testInput() Input {
+	return Input{
+		Tenant: "acme",
+		Transfer: models.Transfer{
+			SourceAccountID:      1,
+			DestinationAccountID: 2,
+			Amount:               decimal.RequireFromString("2500.50"),
+			TransferType:         "wire",
+		},
+		Source:      models.Account{AccountID: 1, Balance: decimal.RequireFromString("10000"), Status: models.AccountActive, Tags: []string{"vip"}},
+		Destination: models.Account{AccountID: 2, Balance: decimal.Zero, Status: models.AccountActive},
+	}
+}
+
+func TestCompile(t *testing.T) {
+	testCases := []struct {
+		expression string
+		want       bool
+	}{
+		{`amount > 1000`, true},
+		{`amount >= 2500.50 and amount <= 2500.5`, true},
+		{`amount < -1`, false},
+		{`transfer_type == "wire"`, true},
+		{`TRANSFER_TYPE != "wire"`, false},
+		{`tenant == "acme" and source.tags contains "vip"`, true},
+		{`destination.tags contains "vip"`, false},
+		{`not (amount > 1000 or destination.balance == 0)`, false},
+		{`source.balance > amount and destination.status == "active"`, true},
+		{`source.id == 1 and destination_account_id == 2 and source_account_id != destination.id`, true},
+		{`false or true and false`, false},
+		{`true`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			condition, err := Compile(tc.expression)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := condition(testInput()); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	testCases := []struct {
+		expression string
+		wantErr    string
+	}{
+		{``, "unexpected end"},
+		{`amount`, "expected a condition"},
+		{`amount > "10"`, "cannot compare number with string"},
+		{`transfer_type < "wire"`, "only support == and !="},
+		{`source.tags == "vip"`, "cannot compare list with string"},
+		{`amount contains "vip"`, "contains needs a tag list"},
+		{`amount > 10 and amount`, "and needs conditions"},
+		{`not amount`, "not needs conditions"},
+		{`balance > 10`, `unknown field "balance"`},
+		{`amount = 10`, "unknown operator"},
+		{`transfer_type == "wire`, "unterminated string"},
+		{`(amount > 10`, "missing )"},
+		{`amount > 10)`, `unexpected ")"`},
+		{`amount > 10 & true`, "unexpected character"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			_, err := Compile(tc.expression)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	Register("test_amount_limit", RuleFunc(func(in Input) (string, error) {
+		if in.Destination.Status == models.AccountFrozen {
+			return "", errors.New("lookup failed")
+		}
+		if in.Transfer.Amount.GreaterThan(decimal.NewFromInt(5000)) {
+			return "amount above plugin limit", nil
+		}
+		return "", nil
+	}))
+
+	engine, err := New([]Definition{
+		{Name: "wire_limit", When: `transfer_type == "wire" and amount > 2000`, Message: "wires above 2000 need approval", Tenants: []string{"acme"}},
+		{Name: "plugin_limit", Plugin: "test_amount_limit"},
+		{Name: "empty_destination", When: `destination.balance == 0`},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if engine.Len() != 3 {
+		t.Errorf("Expected 3 rules, got %d", engine.Len())
+	}
+
+	in := testInput()
+	var violation *Violation
+	if err := engine.Evaluate(in); !errors.As(err, &violation) || violation.Rule != "wire_limit" || violation.Message != "wires above 2000 need approval" {
+		t.Errorf("Expected wire_limit violation, got %v", err)
+	}
+
+	// Tenant-scoped rules are skipped for other tenants; the default message applies without one
+	in.Tenant = ""
+	if err := engine.Evaluate(in); !errors.As(err, &violation) || violation.Rule != "empty_destination" || violation.Message != "transfer not allowed" {
+		t.Errorf("Expected empty_destination violation for the default tenant, got %v", err)
+	}
+
+	in.Transfer.Amount = decimal.NewFromInt(6000)
+	if err := engine.Evaluate(in); !errors.As(err, &violation) || violation.Rule != "plugin_limit" {
+		t.Errorf("Expected plugin_limit violation, got %v", err)
+	}
+
+	in.Destination.Status = models.AccountFrozen
+	if err := engine.Evaluate(in); err == nil || errors.As(err, &violation) || !strings.Contains(err.Error(), "plugin_limit failed") {
+		t.Errorf("Expected plugin failure, got %v", err)
+	}
+
+	in = testInput()
+	in.Tenant = "other"
+	in.Destination.Balance = decimal.NewFromInt(1)
+	if err := engine.Evaluate(in); err != nil {
+		t.Errorf("Expected no violation, got %v", err)
+	}
+
+	if got := strings.Join(engine.Applicable("acme"), ","); got != "wire_limit,plugin_limit,empty_destination" {
+		t.Errorf("Expected every rule to apply to acme, got %s", got)
+	}
+	if got := strings.Join(engine.Applicable(""), ","); got != "plugin_limit,empty_destination" {
+		t.Errorf("Expected the unscoped rules to apply to the default tenant, got %s", got)
+	}
+
+	var none *Engine
+	if err := none.Evaluate(in); err != nil || none.Len() != 0 || none.Applicable("acme") != nil {
+		t.Errorf("Expected a nil engine to allow everything, got %v", err)
+	}
+
+	// Replacing the rules takes effect for the next evaluation
+	replacement, err := New([]Definition{{Name: "no_transfers", When: "amount > 0"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	engine.Replace(replacement)
+	if err := engine.Evaluate(in); !errors.As(err, &violation) || violation.Rule != "no_transfers" || engine.Len() != 1 {
+		t.Errorf("Expected the replacement rule to apply, got %v", err)
+	}
+	engine.Replace(none)
+	if err := engine.Evaluate(in); err != nil || engine.Len() != 0 {
+		t.Errorf("Expected no rules after replacing them with none, got %v", err)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		definitions []Definition
+		wantErr     string
+	}{
+		{"Missing name", []Definition{{When: "true"}}, "without a name"},
+		{"Duplicate name", []Definition{{Name: "a", When: "true"}, {Name: "a", When: "false"}}, `duplicate rule "a"`},
+		{"Neither", []Definition{{Name: "a"}}, "set when or plugin"},
+		{"Both", []Definition{{Name: "a", When: "true", Plugin: "x"}}, "not both"},
+		{"Unknown plugin", []Definition{{Name: "a", Plugin: "missing"}}, `unknown plugin "missing"`},
+		{"Bad expression", []Definition{{Name: "a", When: "amount >"}}, `rule "a": unexpected end`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.definitions)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	Register("test_duplicate", RuleFunc(func(Input) (string, error) { return "", nil }))
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic registering the same name twice")
+		}
+	}()
+	Register("test_duplicate", RuleFunc(func(Input) (string, error) { return "", nil }))
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("RULES_FILE", "")
+	if engine, err := Load(); err != nil || engine != nil {
+		t.Errorf("Expected no engine without RULES_FILE, got %v (%v)", engine, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	t.Setenv("RULES_FILE", path)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "failed to read RULES_FILE") {
+		t.Errorf("Expected read error, got %v", err)
+	}
+
+	os.WriteFile(path, []byte(`{"rules": [{"name": "large", "when": "amount > 100"}]}`), 0o600)
+	engine, err := Load()
+	if err != nil || engine.Len() != 1 {
+		t.Fatalf("Expected 1 rule, got %v (%v)", engine, err)
+	}
+
+	os.WriteFile(path, []byte(`{"rules": [{"name": "large", "when": "amount >"}]}`), 0o600)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "invalid RULES_FILE") {
+		t.Errorf("Expected invalid rules error, got %v", err)
+	}
+}
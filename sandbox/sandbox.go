@@ -0,0 +1,235 @@
+// Package sandbox wraps the repositories with deterministic simulated failures
+// In sandbox mode, reserved amounts and account IDs short-circuit to a fixed outcome before the
+// underlying repository is touched, so integrators can exercise their error handling against the
+// real API responses without moving any balances
+package sandbox
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// Outcome is a simulated result triggered by a reserved input
+type Outcome string
+
+// Simulated outcomes
+const (
+	OutcomeInsufficientBalance Outcome = "insufficient_balance"
+	OutcomeSourceNotFound      Outcome = "source_not_found"
+	OutcomeDestinationNotFound Outcome = "destination_not_found"
+	OutcomeAccountFrozen       Outcome = "account_frozen"
+	OutcomeDuplicate           Outcome = "duplicate"
+	OutcomeNotFound            Outcome = "not_found"
+	OutcomeTimeout             Outcome = "timeout"
+	OutcomeInternalError       Outcome = "internal_error"
+)
+
+// TransferAmounts maps reserved transfer amounts to their simulated outcome
+var TransferAmounts = map[string]Outcome{
+	"99999.01": OutcomeInsufficientBalance,
+	"99999.02": OutcomeSourceNotFound,
+	"99999.03": OutcomeDestinationNotFound,
+	"99999.04": OutcomeTimeout,
+	"99999.05": OutcomeInternalError,
+	"99999.06": OutcomeAccountFrozen,
+}
+
+// AccountIDs maps reserved account IDs to the simulated outcome of creating or reading them
+var AccountIDs = map[int64]Outcome{
+	9000000001: OutcomeDuplicate,
+	9000000002: OutcomeNotFound,
+	9000000003: OutcomeTimeout,
+	9000000004: OutcomeInternalError,
+}
+
+// DefaultTimeoutDelay is how long a simulated timeout blocks; it exceeds the route timeout so
+// clients observe the same 503 a genuinely slow request produces
+const DefaultTimeoutDelay = 15 * time.Second
+
+// transferOutcome returns the simulated outcome for a transfer amount, if any
+func transferOutcome(amount decimal.Decimal) (Outcome, bool) {
+	for reserved, outcome := range TransferAmounts {
+		if amount.Equal(decimal.RequireFromString(reserved)) {
+			return outcome, true
+		}
+	}
+	return "", false
+}
+
+// AccountRepository decorates an account repository with simulated outcomes
+type AccountRepository struct {
+	next         database.AccountRepositoryInterface
+	timeoutDelay time.Duration
+}
+
+// NewAccountRepository wraps next; reserved account IDs never reach it
+func NewAccountRepository(next database.AccountRepositoryInterface, timeoutDelay time.Duration) *AccountRepository {
+	return &AccountRepository{next: next, timeoutDelay: timeoutDelay}
+}
+
+// CreateAccount simulates duplicates, timeouts and errors for reserved IDs
+func (r *AccountRepository) CreateAccount(account models.NewAccount) error {
+	if outcome, ok := AccountIDs[account.AccountID]; ok {
+		return r.simulate(outcome)
+	}
+	return r.next.CreateAccount(account)
+}
+
+// GetAccount simulates not found, timeouts and errors for reserved IDs
+func (r *AccountRepository) GetAccount(accountID int64) (*models.Account, error) {
+	if outcome, ok := AccountIDs[accountID]; ok {
+		if outcome == OutcomeDuplicate {
+			// The "duplicate" account exists as far as reads are concerned
+			return &models.Account{AccountID: accountID, Balance: decimal.Zero, Status: models.AccountActive, Version: 1}, nil
+		}
+		return nil, r.simulate(outcome)
+	}
+	return r.next.GetAccount(accountID)
+}
+
+// AccountExists reports reserved duplicate IDs as existing so the handler answers 409
+func (r *AccountRepository) AccountExists(accountID int64) (bool, error) {
+	if outcome, ok := AccountIDs[accountID]; ok {
+		return outcome == OutcomeDuplicate, nil
+	}
+	return r.next.AccountExists(accountID)
+}
+
+// SetAccountStatus simulates not found, timeouts and errors for reserved IDs
+// The reserved duplicate ID is read-only, so changing its status is reported as not found
+func (r *AccountRepository) SetAccountStatus(accountID int64, status string) (*models.Account, error) {
+	if outcome, ok := AccountIDs[accountID]; ok {
+		if outcome == OutcomeDuplicate {
+			return nil, r.simulate(OutcomeNotFound)
+		}
+		return nil, r.simulate(outcome)
+	}
+	return r.next.SetAccountStatus(accountID, status)
+}
+
+// SetOverdraftLimit simulates not found, timeouts and errors for reserved IDs
+// The reserved duplicate ID is read-only, so changing its limit is reported as not found
+func (r *AccountRepository) SetOverdraftLimit(accountID int64, limit decimal.Decimal) (*models.Account, error) {
+	if outcome, ok := AccountIDs[accountID]; ok {
+		if outcome == OutcomeDuplicate {
+			return nil, r.simulate(OutcomeNotFound)
+		}
+		return nil, r.simulate(outcome)
+	}
+	return r.next.SetOverdraftLimit(accountID, limit)
+}
+
+// UpdateAccount simulates not found, timeouts and errors for reserved IDs
+// The reserved duplicate ID is read-only, so updating it is reported as not found
+func (r *AccountRepository) UpdateAccount(accountID int64, update models.AccountUpdate) (*models.Account, error) {
+	if outcome, ok := AccountIDs[accountID]; ok {
+		if outcome == OutcomeDuplicate {
+			return nil, r.simulate(OutcomeNotFound)
+		}
+		return nil, r.simulate(outcome)
+	}
+	return r.next.UpdateAccount(accountID, update)
+}
+
+// ListAccounts passes through; reserved IDs only exist for single-account requests
+func (r *AccountRepository) ListAccounts(filter models.AccountFilter, limit, offset int) ([]models.Account, int, error) {
+	return r.next.ListAccounts(filter, limit, offset)
+}
+
+// GetAccountByExternalID passes through; reserved accounts have no external ID
+func (r *AccountRepository) GetAccountByExternalID(externalID string) (*models.Account, error) {
+	return r.next.GetAccountByExternalID(externalID)
+}
+
+// simulate produces the error for an account outcome
+func (r *AccountRepository) simulate(outcome Outcome) error {
+	switch outcome {
+	case OutcomeDuplicate:
+		return fmt.Errorf("account already exists")
+	case OutcomeNotFound:
+		return fmt.Errorf("account not found")
+	case OutcomeTimeout:
+		time.Sleep(r.timeoutDelay)
+		return fmt.Errorf("sandbox: simulated timeout")
+	default:
+		return fmt.Errorf("sandbox: simulated internal error")
+	}
+}
+
+// TransactionRepository decorates a transaction repository with simulated outcomes
+type TransactionRepository struct {
+	next         database.TransactionRepositoryInterface
+	timeoutDelay time.Duration
+}
+
+// NewTransactionRepository wraps next; transfers of reserved amounts never reach it
+func NewTransactionRepository(next database.TransactionRepositoryInterface, timeoutDelay time.Duration) *TransactionRepository {
+	return &TransactionRepository{next: next, timeoutDelay: timeoutDelay}
+}
+
+// CreateTransaction returns the simulated outcome for reserved amounts and otherwise delegates
+func (r *TransactionRepository) CreateTransaction(transfer models.Transfer) (*models.Transaction, error) {
+	outcome, ok := transferOutcome(transfer.Amount)
+	if !ok {
+		return r.next.CreateTransaction(transfer)
+	}
+
+	switch outcome {
+	case OutcomeInsufficientBalance:
+		return nil, fmt.Errorf("insufficient balance")
+	case OutcomeSourceNotFound:
+		return nil, fmt.Errorf("source account not found")
+	case OutcomeDestinationNotFound:
+		return nil, fmt.Errorf("destination account not found")
+	case OutcomeAccountFrozen:
+		return nil, fmt.Errorf("source account frozen")
+	case OutcomeTimeout:
+		time.Sleep(r.timeoutDelay)
+		return nil, fmt.Errorf("sandbox: simulated timeout")
+	default:
+		return nil, fmt.Errorf("sandbox: simulated internal error")
+	}
+}
+
+// ListTransactions delegates to the wrapped repository
+func (r *TransactionRepository) ListTransactions(accountID int64, limit int) ([]models.Transaction, error) {
+	return r.next.ListTransactions(accountID, limit)
+}
+
+// SearchTransactions delegates to the wrapped repository
+func (r *TransactionRepository) SearchTransactions(filter models.TransactionFilter, limit int) ([]models.Transaction, error) {
+	return r.next.SearchTransactions(filter, limit)
+}
+
+// SearchAllTransactions delegates to the wrapped repository
+func (r *TransactionRepository) SearchAllTransactions(search models.TransactionSearch, limit int) ([]models.Transaction, error) {
+	return r.next.SearchAllTransactions(search, limit)
+}
+
+// ListChanges delegates to the wrapped repository
+func (r *TransactionRepository) ListChanges(accountID, sinceSeq int64, limit int) ([]models.Transaction, error) {
+	return r.next.ListChanges(accountID, sinceSeq, limit)
+}
+
+// BalanceAsOf delegates to the wrapped repository
+func (r *TransactionRepository) BalanceAsOf(accountID int64, recordedAt, effectiveAt time.Time) (decimal.Decimal, error) {
+	return r.next.BalanceAsOf(accountID, recordedAt, effectiveAt)
+}
+
+// Compile-time interface implementation checks
+var _ database.AccountRepositoryInterface = (*AccountRepository)(nil)
+var _ database.TransactionRepositoryInterface = (*TransactionRepository)(nil)
+
+// Header marks every response as coming from the sandbox so clients cannot mistake it for production
+func Header(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Sandbox", "true")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,90 @@
+package sandbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+
+	"internal-transfers/memory"
+)
+
+func newRepositories() (*AccountRepository, *TransactionRepository) {
+	store := memory.NewStore()
+	accounts := NewAccountRepository(memory.NewAccountRepository(store), time.Millisecond)
+	transactions := NewTransactionRepository(memory.NewTransactionRepository(store), time.Millisecond)
+	return accounts, transactions
+}
+
+func TestTransactionRepository_ReservedAmounts(t *testing.T) {
+	accounts, transactions := newRepositories()
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(1000000)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+
+	testCases := []struct {
+		amount  string
+		wantErr string
+	}{
+		{"99999.01", "insufficient balance"},
+		{"99999.02", "source account not found"},
+		{"99999.03", "destination account not found"},
+		{"99999.040", "sandbox: simulated timeout"},
+		{"99999.05", "sandbox: simulated internal error"},
+		{"99999.06", "source account frozen"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.amount, func(t *testing.T) {
+			_, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString(tc.amount)})
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("Expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	// Simulated outcomes never touch balances
+	account, _ := accounts.GetAccount(1)
+	if !account.Balance.Equal(decimal.NewFromInt(1000000)) {
+		t.Errorf("Simulated transfers must not move money, balance is %s", account.Balance)
+	}
+
+	// Ordinary amounts pass through to storage
+	if _, err := transactions.CreateTransaction(models.Transfer{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10)}); err != nil {
+		t.Errorf("Expected ordinary transfer to succeed, got %v", err)
+	}
+}
+
+func TestAccountRepository_ReservedIDs(t *testing.T) {
+	accounts, _ := newRepositories()
+
+	if exists, _ := accounts.AccountExists(9000000001); !exists {
+		t.Error("Reserved duplicate ID should report as existing")
+	}
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 9000000001, InitialBalance: decimal.Zero}); err == nil || err.Error() != "account already exists" {
+		t.Errorf("Expected duplicate error, got %v", err)
+	}
+	if _, err := accounts.GetAccount(9000000002); err == nil || err.Error() != "account not found" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 9000000004, InitialBalance: decimal.Zero}); err == nil {
+		t.Error("Expected simulated internal error")
+	}
+
+	if err := accounts.CreateAccount(models.NewAccount{AccountID: 7, InitialBalance: decimal.Zero}); err != nil {
+		t.Errorf("Expected ordinary account creation to succeed, got %v", err)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Header(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Header().Get("X-Sandbox") != "true" {
+		t.Error("Expected X-Sandbox header")
+	}
+}
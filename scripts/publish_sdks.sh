@@ -0,0 +1,52 @@
+#!/bin/bash
+
+# Generate and publish the TypeScript and Python client SDKs
+# Usage: scripts/publish_sdks.sh <version> [openapi.json]
+#
+# Publishes to the registries npm and twine are configured for; set NPM_REGISTRY and
+# TWINE_REPOSITORY_URL to use a private registry. DRY_RUN=1 builds without publishing
+
+set -e
+
+VERSION="$1"
+SPEC="$2"
+if [ -z "$VERSION" ]; then
+    echo "usage: $0 <version> [openapi.json]" >&2
+    exit 1
+fi
+
+OUT="$(mktemp -d)"
+trap 'rm -rf "$OUT"' EXIT
+
+echo "📦 Generating SDKs $VERSION"
+if [ -n "$SPEC" ]; then
+    go run . sdk -spec "$SPEC" -version "$VERSION" "$OUT"
+else
+    go run . sdk -version "$VERSION" "$OUT"
+fi
+
+echo ""
+echo "🟦 TypeScript"
+(
+    cd "$OUT/typescript"
+    npm install --no-audit --no-fund
+    npm run build
+    if [ "$DRY_RUN" = "1" ]; then
+        npm pack --dry-run
+    else
+        npm publish ${NPM_REGISTRY:+--registry "$NPM_REGISTRY"}
+    fi
+)
+
+echo ""
+echo "🐍 Python"
+(
+    cd "$OUT/python"
+    python3 -m build
+    if [ "$DRY_RUN" != "1" ]; then
+        python3 -m twine upload ${TWINE_REPOSITORY_URL:+--repository-url "$TWINE_REPOSITORY_URL"} dist/*
+    fi
+)
+
+echo ""
+echo "✅ Done"
@@ -0,0 +1,48 @@
+#!/bin/bash
+
+# Cut a release: check it against the previous release for breaking changes, record its
+# compatibility artifact and publish the client SDKs
+# Usage: scripts/release.sh <version> [previous-artifact.json]
+#
+# The previous artifact defaults to the newest releases/compat-*.json. Breaking changes stop the
+# release; ALLOW_BREAKING=1 releases anyway, for a deliberate major version. DRY_RUN=1 checks and
+# builds without recording the artifact or publishing
+
+set -e
+
+VERSION="$1"
+PREVIOUS="$2"
+if [ -z "$VERSION" ]; then
+    echo "usage: $0 <version> [previous-artifact.json]" >&2
+    exit 1
+fi
+
+ARTIFACT="releases/compat-$VERSION.json"
+if [ -e "$ARTIFACT" ]; then
+    echo "$ARTIFACT already exists; $VERSION was released" >&2
+    exit 1
+fi
+if [ -z "$PREVIOUS" ]; then
+    PREVIOUS="$(ls releases/compat-*.json 2>/dev/null | sort -V | tail -n 1)"
+fi
+
+echo "🔍 Compatibility"
+if [ -z "$PREVIOUS" ]; then
+    echo "No previous release artifact; nothing to compare"
+elif ! go run . compat-check -version "$VERSION" "$PREVIOUS"; then
+    if [ "$ALLOW_BREAKING" != "1" ]; then
+        echo "❌ Breaking changes since $PREVIOUS; set ALLOW_BREAKING=1 to release them deliberately" >&2
+        exit 1
+    fi
+    echo "⚠️  Releasing breaking changes (ALLOW_BREAKING=1)"
+fi
+
+echo ""
+scripts/publish_sdks.sh "$VERSION"
+
+# Recorded last, so a failed publish can be retried
+if [ "$DRY_RUN" != "1" ]; then
+    mkdir -p releases
+    go run . compat-check -version "$VERSION" -write "$ARTIFACT"
+    echo "Commit $ARTIFACT; the next release is checked against it"
+fi
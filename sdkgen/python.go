@@ -0,0 +1,199 @@
+package sdkgen
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//go:embed runtime/runtime.py
+var pythonRuntime string
+
+// pyIdentifier matches names usable as Python identifiers (keywords are checked separately)
+var pyIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// pyKeywords are the Python keywords, which cannot name arguments or class-syntax fields
+var pyKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+	"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// Python returns the files of the Python package by path: pyproject.toml and the package, whose
+// client module holds the runtime, a TypedDict per request and response body and the Client
+func Python(doc *Document, opts Options) map[string][]byte {
+	var src strings.Builder
+	src.WriteString("# Code generated by internal-transfers sdk; DO NOT EDIT.\n\n")
+	src.WriteString(pythonRuntime)
+
+	exports := []string{"ApiError", "IdempotencyConflictError", "Client"}
+	var methods strings.Builder
+	for _, op := range doc.Operations() {
+		name := pascalCase(op.OperationID)
+		args := []string{"self"}
+		for _, param := range op.PathParameters() {
+			args = append(args, fmt.Sprintf("%s: %s", pyArgument(param.Name), pyType(param.Schema)))
+		}
+
+		body := "None"
+		if schema := op.RequestSchema(); schema != nil {
+			src.WriteString(pyTypedDict(name+"Request", schema))
+			exports = append(exports, name+"Request")
+			args = append(args, fmt.Sprintf("body: %sRequest", name))
+			body = "body"
+		}
+		result := "Any"
+		if schema, noContent := op.ResponseSchema(); noContent {
+			result = "None"
+		} else if schema != nil {
+			src.WriteString(pyTypedDict(name+"Response", schema))
+			exports = append(exports, name+"Response")
+			result = name + "Response"
+		}
+		args = append(args, "*", "query: Optional[Query] = None", "headers: Optional[Mapping[str, str]] = None")
+		idempotency, idempotencyKey := "None", "None"
+		if op.IdempotencyKey != "" {
+			args = append(args, "idempotency_key: Optional[str] = None")
+			idempotency, idempotencyKey = quote(op.IdempotencyKey), "idempotency_key"
+		}
+		args = append(args, "timeout: Optional[float] = None")
+		fmt.Fprintf(&methods, "\n    def %s(\n", snakeCase(op.OperationID))
+		for _, arg := range args {
+			fmt.Fprintf(&methods, "        %s,\n", arg)
+		}
+		fmt.Fprintf(&methods, "    ) -> %s:\n", result)
+		lines := docLines(op, "idempotency_key")
+		fmt.Fprintf(&methods, "        \"\"\"%s\n", strings.ReplaceAll(lines[0], `"""`, `'''`))
+		if len(lines) > 1 {
+			methods.WriteString("\n")
+			for _, line := range lines[1:] {
+				fmt.Fprintf(&methods, "        %s\n", line)
+			}
+		}
+		methods.WriteString("        \"\"\"\n")
+		fmt.Fprintf(&methods, "        return self._request(%s, %s, %s, %s, query, headers, %s, timeout)\n",
+			quote(op.Method), pyPath(op), body, idempotency, idempotencyKey)
+	}
+
+	fmt.Fprintf(&src, "\n\nclass Client(_BaseClient):\n    \"\"\"Client of the %s; every method raises ApiError for a non-2xx response.\"\"\"\n", doc.Info.Title)
+	src.WriteString(methods.String())
+	quoted := make([]string, len(exports))
+	for i, export := range exports {
+		quoted[i] = quote(export)
+	}
+	fmt.Fprintf(&src, "\n\n__all__ = [%s]\n", strings.Join(quoted, ", "))
+
+	pyproject := fmt.Sprintf(`[build-system]
+requires = ["setuptools>=61"]
+build-backend = "setuptools.build_meta"
+
+[project]
+name = %s
+version = %s
+description = %s
+requires-python = ">=3.8"
+dependencies = []
+
+[tool.setuptools]
+packages = [%s]
+
+[tool.setuptools.package-data]
+%s = ["py.typed"]
+`, quote(PythonPackage), quote(opts.Version), quote("Generated client for the "+doc.Info.Title), quote(pythonModule), pythonModule)
+
+	return map[string][]byte{
+		"pyproject.toml":              []byte(pyproject),
+		pythonModule + "/__init__.py": []byte("# Code generated by internal-transfers sdk; DO NOT EDIT.\n\nfrom .client import *  # noqa: F401,F403\nfrom .client import __all__  # noqa: F401\n"),
+		pythonModule + "/client.py":   []byte(src.String()),
+		pythonModule + "/py.typed":    nil,
+	}
+}
+
+// pyTypedDict renders an object schema as a TypedDict whose fields are all optional, as the
+// service omits empty fields; other schemas become a type alias
+func pyTypedDict(name string, schema *Schema) string {
+	if schema.Type != "object" || len(schema.Properties) == 0 {
+		return fmt.Sprintf("\n\n%s = %s\n", name, pyType(schema))
+	}
+	keys := sortedKeys(schema.Properties)
+	classSyntax := true
+	for _, key := range keys {
+		if !pyIdentifier.MatchString(key) || pyKeywords[key] {
+			classSyntax = false
+		}
+	}
+
+	var b strings.Builder
+	if classSyntax {
+		fmt.Fprintf(&b, "\n\nclass %s(TypedDict, total=False):\n", name)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "    %s: %s\n", key, pyType(schema.Properties[key]))
+		}
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\n\n%s = TypedDict(\n    %s,\n    {\n", name, quote(name))
+	for _, key := range keys {
+		fmt.Fprintf(&b, "        %s: %s,\n", quote(key), pyType(schema.Properties[key]))
+	}
+	b.WriteString("    },\n    total=False,\n)\n")
+	return b.String()
+}
+
+// pyType renders a schema as a Python type annotation; nested objects are plain dictionaries
+func pyType(schema *Schema) string {
+	if schema == nil {
+		return "Any"
+	}
+	switch schema.Type {
+	case "string":
+		return "str"
+	case "integer":
+		return "int"
+	case "number":
+		return "float"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "List[" + pyType(schema.Items) + "]"
+	case "object":
+		if schema.AdditionalProperties != nil {
+			return "Dict[str, " + pyType(schema.AdditionalProperties) + "]"
+		}
+		return "Dict[str, Any]"
+	default:
+		return "Any"
+	}
+}
+
+// pyArgument converts a path parameter to a Python argument name
+func pyArgument(name string) string {
+	arg := snakeCase(name)
+	if pyKeywords[arg] || !pyIdentifier.MatchString(arg) {
+		arg += "_"
+	}
+	return arg
+}
+
+// pyPath renders the operation's path as a string literal, or an f-string substituting the encoded
+// path arguments
+func pyPath(op *Operation) string {
+	matches := pathParamPattern.FindAllStringSubmatchIndex(op.Path, -1)
+	if len(matches) == 0 {
+		return quote(op.Path)
+	}
+	literal := strings.NewReplacer("{", "{{", "}", "}}", `\`, `\\`, `"`, `\"`)
+	var b strings.Builder
+	b.WriteString(`f"`)
+	last := 0
+	for _, loc := range matches {
+		b.WriteString(literal.Replace(op.Path[last:loc[0]]))
+		b.WriteString("{_path(" + pyArgument(op.Path[loc[2]:loc[3]]) + ")}")
+		last = loc[1]
+	}
+	b.WriteString(literal.Replace(op.Path[last:]))
+	b.WriteString(`"`)
+	return b.String()
+}
@@ -0,0 +1,153 @@
+# Transport shared by every operation: authentication headers, per-attempt timeouts, retries
+# with exponential backoff, and errors carrying the response status and request ID
+# Only the standard library is used, so the package has no dependencies
+
+import email.utils
+import json
+import random
+import time
+import urllib.error
+import urllib.parse
+import urllib.request
+import uuid
+from typing import Any, Dict, List, Mapping, Optional, TypedDict, Union
+
+_RETRYABLE_STATUS = frozenset({429, 502, 503, 504})
+_IDEMPOTENT_METHODS = frozenset({"GET", "HEAD", "PUT", "DELETE"})
+_MAX_BACKOFF = 30.0
+
+Query = Mapping[str, Union[str, int, float, bool, None]]
+
+
+class ApiError(Exception):
+    """Raised for every non-2xx response."""
+
+    def __init__(self, status: int, body: str, request_id: str) -> None:
+        super().__init__(f"HTTP {status}: {body.strip()}")
+        self.status = status
+        self.body = body
+        self.request_id = request_id
+
+
+class IdempotencyConflictError(ApiError):
+    """Raised when a retried request is refused as a duplicate.
+
+    An earlier attempt whose response was lost was most likely applied, so look it up by its
+    idempotency key instead of sending it again.
+    """
+
+
+class _BaseClient:
+    def __init__(
+        self,
+        base_url: str,
+        *,
+        api_key: Optional[str] = None,
+        tenant_id: Optional[str] = None,
+        max_retries: int = 3,
+        retry_delay: float = 0.2,
+        timeout: float = 30.0,
+    ) -> None:
+        """Creates a client.
+
+        api_key and tenant_id are sent as X-API-Key and X-Tenant-ID. A retryable request is
+        retried up to max_retries times after the first attempt, with exponential backoff from
+        retry_delay seconds; timeout bounds each attempt in seconds.
+        """
+        self._base_url = base_url.rstrip("/")
+        self._api_key = api_key
+        self._tenant_id = tenant_id
+        self._max_retries = max_retries
+        self._retry_delay = retry_delay
+        self._timeout = timeout
+
+    def _request(
+        self,
+        method: str,
+        path: str,
+        body: Optional[Mapping[str, Any]],
+        idempotency_field: Optional[str],
+        query: Optional[Query] = None,
+        headers: Optional[Mapping[str, str]] = None,
+        idempotency_key: Optional[str] = None,
+        timeout: Optional[float] = None,
+    ) -> Any:
+        if body is not None and idempotency_field and idempotency_key is not None and body.get(idempotency_field) is None:
+            body = {**body, idempotency_field: idempotency_key}
+        # Without an idempotency key a lost response leaves a write in an unknown state, so only
+        # methods that are idempotent by definition are retried
+        retryable = method in _IDEMPOTENT_METHODS or (
+            idempotency_field is not None and body is not None and body.get(idempotency_field) is not None
+        )
+
+        url = self._base_url + path
+        params = {name: _query_value(value) for name, value in (query or {}).items() if value is not None}
+        if params:
+            url += "?" + urllib.parse.urlencode(params)
+        request_headers = {"Accept": "application/json", "X-Request-ID": uuid.uuid4().hex, **(headers or {})}
+        if self._api_key:
+            request_headers["X-API-Key"] = self._api_key
+        if self._tenant_id:
+            request_headers["X-Tenant-ID"] = self._tenant_id
+        data = None
+        if body is not None:
+            request_headers["Content-Type"] = "application/json"
+            data = json.dumps(body).encode("utf-8")
+
+        attempts = self._max_retries + 1 if retryable else 1
+        attempt = 0
+        while True:
+            request = urllib.request.Request(url, data=data, headers=request_headers, method=method)
+            try:
+                with urllib.request.urlopen(request, timeout=timeout or self._timeout) as response:
+                    return _decode(response.status, response.headers.get("Content-Type", ""), response.read())
+            except urllib.error.HTTPError as err:
+                text = err.read().decode("utf-8", "replace")
+                request_id = err.headers.get("X-Request-ID") or request_headers["X-Request-ID"]
+                if err.code == 409 and attempt > 0 and idempotency_field is not None:
+                    raise IdempotencyConflictError(err.code, text, request_id) from None
+                if err.code not in _RETRYABLE_STATUS or attempt + 1 >= attempts:
+                    raise ApiError(err.code, text, request_id) from None
+                time.sleep(self._backoff(attempt, err.headers.get("Retry-After")))
+            except (urllib.error.URLError, OSError):
+                if attempt + 1 >= attempts:
+                    raise
+                time.sleep(self._backoff(attempt, None))
+            attempt += 1
+
+    def _backoff(self, attempt: int, retry_after: Optional[str]) -> float:
+        """Returns the delay before the next attempt: the server's Retry-After if given,
+        otherwise exponential with full jitter."""
+        if retry_after:
+            try:
+                return min(max(float(retry_after), 0.0), _MAX_BACKOFF)
+            except ValueError:
+                pass
+            try:
+                when = email.utils.parsedate_to_datetime(retry_after)
+                return min(max(when.timestamp() - time.time(), 0.0), _MAX_BACKOFF)
+            except (TypeError, ValueError):
+                pass
+        return random.uniform(0, min(self._retry_delay * 2**attempt, _MAX_BACKOFF))
+
+
+def _decode(status: int, content_type: str, payload: bytes) -> Any:
+    """Returns a JSON body parsed, text as str, anything else (e.g. a PDF) as bytes, and None for
+    an empty body."""
+    if status == 204 or not payload:
+        return None
+    if "json" in content_type:
+        return json.loads(payload)
+    if content_type.startswith("text/"):
+        return payload.decode("utf-8", "replace")
+    return payload
+
+
+def _query_value(value: Union[str, int, float, bool]) -> str:
+    if isinstance(value, bool):
+        return "true" if value else "false"
+    return str(value)
+
+
+def _path(value: Any) -> str:
+    return urllib.parse.quote(str(value), safe="")
@@ -0,0 +1,196 @@
+// Transport shared by every operation: authentication headers, per-attempt timeouts, retries
+// with exponential backoff, and errors carrying the response status and request ID
+
+export interface ClientOptions {
+  // Base URL of the service, e.g. https://transfers.example.com
+  baseUrl: string;
+  // API key sent as X-API-Key
+  apiKey?: string;
+  // Tenant sent as X-Tenant-ID
+  tenantId?: string;
+  // Retries after the first attempt of a retryable request (default 3)
+  maxRetries?: number;
+  // Base delay of the exponential backoff between attempts (default 200ms)
+  retryDelayMs?: number;
+  // Timeout of a single attempt (default 30s)
+  timeoutMs?: number;
+  // fetch implementation, for runtimes without a global fetch or for tests
+  fetch?: typeof fetch;
+}
+
+export interface RequestOptions {
+  // Query string parameters; undefined values are omitted
+  query?: Record<string, string | number | boolean | undefined>;
+  // Extra request headers
+  headers?: Record<string, string>;
+  // Cancels the request, including pending retries
+  signal?: AbortSignal;
+  // Fills the operation's idempotency field (see the operation's documentation) when the body
+  // does not set it, making the request safe to retry
+  idempotencyKey?: string;
+}
+
+// ApiError is thrown for every non-2xx response
+export class ApiError extends Error {
+  constructor(
+    readonly status: number,
+    readonly body: string,
+    readonly requestId: string,
+  ) {
+    super(`HTTP ${status}: ${body.trim()}`);
+    this.name = "ApiError";
+  }
+}
+
+// IdempotencyConflictError is thrown when a retried request is refused as a duplicate: an
+// earlier attempt whose response was lost was most likely applied, so look it up by its
+// idempotency key instead of sending it again
+export class IdempotencyConflictError extends ApiError {
+  constructor(status: number, body: string, requestId: string) {
+    super(status, body, requestId);
+    this.name = "IdempotencyConflictError";
+  }
+}
+
+const retryableStatus = new Set([429, 502, 503, 504]);
+const idempotentMethods = new Set(["GET", "HEAD", "PUT", "DELETE"]);
+const maxBackoffMs = 30000;
+
+export class BaseClient {
+  private readonly options: Required<Omit<ClientOptions, "apiKey" | "tenantId">> & ClientOptions;
+
+  constructor(options: ClientOptions) {
+    this.options = {
+      maxRetries: 3,
+      retryDelayMs: 200,
+      timeoutMs: 30000,
+      ...options,
+      fetch: options.fetch ?? ((input, init) => globalThis.fetch(input, init)),
+      baseUrl: options.baseUrl.replace(/\/+$/, ""),
+    };
+  }
+
+  protected async request<T>(
+    method: string,
+    path: string,
+    body: Record<string, unknown> | undefined,
+    idempotencyField: string | undefined,
+    options: RequestOptions = {},
+  ): Promise<T> {
+    if (body !== undefined && idempotencyField && options.idempotencyKey !== undefined && body[idempotencyField] === undefined) {
+      body = { ...body, [idempotencyField]: options.idempotencyKey };
+    }
+    // Without an idempotency key a lost response leaves a write in an unknown state, so only
+    // methods that are idempotent by definition are retried
+    const retryable =
+      idempotentMethods.has(method) || (idempotencyField !== undefined && body !== undefined && body[idempotencyField] !== undefined);
+
+    const url = new URL(this.options.baseUrl + path);
+    for (const [name, value] of Object.entries(options.query ?? {})) {
+      if (value !== undefined) {
+        url.searchParams.set(name, String(value));
+      }
+    }
+    const headers: Record<string, string> = { Accept: "application/json", "X-Request-ID": requestId(), ...options.headers };
+    if (this.options.apiKey) {
+      headers["X-API-Key"] = this.options.apiKey;
+    }
+    if (this.options.tenantId) {
+      headers["X-Tenant-ID"] = this.options.tenantId;
+    }
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+
+    const attempts = retryable ? this.options.maxRetries + 1 : 1;
+    for (let attempt = 0; ; attempt++) {
+      let response: Response;
+      const controller = new AbortController();
+      const abort = () => controller.abort();
+      options.signal?.addEventListener("abort", abort);
+      const timer = setTimeout(abort, this.options.timeoutMs);
+      try {
+        response = await this.options.fetch(url.toString(), {
+          method,
+          headers,
+          body: body === undefined ? undefined : JSON.stringify(body),
+          signal: controller.signal,
+        });
+      } catch (err) {
+        if (options.signal?.aborted || attempt + 1 >= attempts) {
+          throw err;
+        }
+        await sleep(this.backoff(attempt, null), options.signal);
+        continue;
+      } finally {
+        clearTimeout(timer);
+        options.signal?.removeEventListener("abort", abort);
+      }
+
+      if (response.ok) {
+        return (await decode(response)) as T;
+      }
+      const text = await response.text();
+      const id = response.headers.get("X-Request-ID") ?? headers["X-Request-ID"];
+      if (response.status === 409 && attempt > 0 && idempotencyField !== undefined) {
+        throw new IdempotencyConflictError(response.status, text, id);
+      }
+      if (!retryableStatus.has(response.status) || attempt + 1 >= attempts) {
+        throw new ApiError(response.status, text, id);
+      }
+      await sleep(this.backoff(attempt, response.headers.get("Retry-After")), options.signal);
+    }
+  }
+
+  // backoff returns the delay before the next attempt: the server's Retry-After if given,
+  // otherwise exponential with full jitter
+  private backoff(attempt: number, retryAfter: string | null): number {
+    if (retryAfter) {
+      const seconds = Number(retryAfter);
+      const ms = Number.isNaN(seconds) ? Date.parse(retryAfter) - Date.now() : seconds * 1000;
+      if (!Number.isNaN(ms)) {
+        return Math.min(Math.max(ms, 0), maxBackoffMs);
+      }
+    }
+    return Math.random() * Math.min(this.options.retryDelayMs * 2 ** attempt, maxBackoffMs);
+  }
+}
+
+// decode returns a JSON body parsed, text as a string, anything else (e.g. a PDF) as bytes, and
+// nothing for an empty body
+async function decode(response: Response): Promise<unknown> {
+  if (response.status === 204) {
+    return undefined;
+  }
+  const type = response.headers.get("Content-Type") ?? "";
+  if (type.includes("json")) {
+    const text = await response.text();
+    return text === "" ? undefined : JSON.parse(text);
+  }
+  if (type.startsWith("text/")) {
+    return response.text();
+  }
+  return response.arrayBuffer();
+}
+
+// requestId identifies one logical call; every attempt sends the same ID, so server logs group them
+function requestId(): string {
+  if (globalThis.crypto?.randomUUID) {
+    return globalThis.crypto.randomUUID().replace(/-/g, "");
+  }
+  return Array.from({ length: 32 }, () => Math.floor(Math.random() * 16).toString(16)).join("");
+}
+
+function sleep(ms: number, signal?: AbortSignal): Promise<void> {
+  return new Promise((resolve, reject) => {
+    if (signal?.aborted) {
+      reject(signal.reason);
+      return;
+    }
+    const timer = setTimeout(resolve, ms);
+    signal?.addEventListener("abort", () => {
+      clearTimeout(timer);
+      reject(signal.reason);
+    });
+  });
+}
@@ -0,0 +1,253 @@
+// Package sdkgen generates the TypeScript and Python client SDKs from the service's OpenAPI
+// document, so consumers outside Go share one client per language instead of hand-rolling their own
+// Every operation becomes a typed method; the clients authenticate with an API key, time out and
+// retry each attempt with backoff, and retry writes only when the operation documents an
+// idempotency field (x-idempotency-key) and the request sets it
+package sdkgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Package names under which the SDKs are published
+const (
+	NPMPackage    = "@internal-transfers/client"
+	PythonPackage = "internal-transfers-client"
+	pythonModule  = "internal_transfers_client"
+)
+
+// pathParamPattern matches path template variables such as {account_id}, as routes.Registry
+// writes them
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// Document is the part of an OpenAPI 3 document the generators use
+type Document struct {
+	Info struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths map[string]map[string]*Operation `json:"paths"`
+}
+
+// Operation is an OpenAPI operation; Method and Path are filled in from its position in the document
+type Operation struct {
+	Method         string               `json:"-"`
+	Path           string               `json:"-"`
+	OperationID    string               `json:"operationId"`
+	Summary        string               `json:"summary"`
+	IdempotencyKey string               `json:"x-idempotency-key"`
+	Deprecated     bool                 `json:"deprecated"`
+	Parameters     []Parameter          `json:"parameters"`
+	RequestBody    *Body                `json:"requestBody"`
+	Responses      map[string]*Response `json:"responses"`
+}
+
+// Parameter is an operation parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Body is a request body
+type Body struct {
+	Content map[string]struct {
+		Schema *Schema `json:"schema"`
+	} `json:"content"`
+}
+
+// Response is an operation response
+type Response struct {
+	Description string `json:"description"`
+	Content     map[string]struct {
+		Schema *Schema `json:"schema"`
+	} `json:"content"`
+}
+
+// Schema is a JSON schema as emitted by routes.Registry.OpenAPI
+type Schema struct {
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Properties           map[string]*Schema `json:"properties"`
+	Items                *Schema            `json:"items"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+}
+
+// Parse decodes an OpenAPI document
+// Deprecated operations, such as the unversioned aliases of the API's paths, are left out
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			if op.OperationID == "" {
+				return nil, fmt.Errorf("%s %s has no operationId", strings.ToUpper(method), path)
+			}
+			if op.Deprecated {
+				delete(item, method)
+				continue
+			}
+			op.Method, op.Path = strings.ToUpper(method), path
+		}
+		if len(item) == 0 {
+			delete(doc.Paths, path)
+		}
+	}
+	return &doc, nil
+}
+
+// Operations returns the document's operations ordered by path, then method
+func (d *Document) Operations() []*Operation {
+	var ops []*Operation
+	for _, item := range d.Paths {
+		for _, op := range item {
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops
+}
+
+// PathParameters returns the operation's path parameters in the order they appear in the path
+func (op *Operation) PathParameters() []Parameter {
+	var params []Parameter
+	for _, match := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+		param := Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		for _, declared := range op.Parameters {
+			if declared.In == "path" && declared.Name == param.Name && declared.Schema != nil {
+				param.Schema = declared.Schema
+			}
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// RequestSchema returns the schema of the JSON request body, or nil if the operation takes none
+func (op *Operation) RequestSchema() *Schema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	if content, ok := op.RequestBody.Content["application/json"]; ok && content.Schema != nil {
+		return content.Schema
+	}
+	return &Schema{}
+}
+
+// ResponseSchema returns the schema of the JSON success response, or nil if none is documented
+// NoContent reports a 204 response, which has no body at all
+func (op *Operation) ResponseSchema() (schema *Schema, noContent bool) {
+	for status, response := range op.Responses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		if status == "204" {
+			return nil, true
+		}
+		if content, ok := response.Content["application/json"]; ok {
+			return content.Schema, false
+		}
+	}
+	return nil, false
+}
+
+// Options configures the generated packages
+type Options struct {
+	// Version is the package version; defaults to the document's info.version
+	Version string
+}
+
+// Generate writes the TypeScript package to dir/typescript and the Python package to dir/python,
+// replacing generated files left by an earlier run
+func Generate(dir string, doc *Document, opts Options) error {
+	if opts.Version == "" {
+		opts.Version = doc.Info.Version
+	}
+	if opts.Version == "" {
+		return fmt.Errorf("no SDK version: the document has no info.version")
+	}
+	for lang, files := range map[string]map[string][]byte{
+		"typescript": TypeScript(doc, opts),
+		"python":     Python(doc, opts),
+	} {
+		for name, content := range files {
+			path := filepath.Join(dir, lang, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, content, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// words splits an identifier such as create_transaction or account-id into lowercase words
+func words(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// camelCase converts an identifier to camelCase, e.g. account_id to accountId
+func camelCase(name string) string {
+	parts := words(name)
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// pascalCase converts an identifier to PascalCase, e.g. create_transaction to CreateTransaction
+func pascalCase(name string) string {
+	camel := camelCase(name)
+	if camel == "" {
+		return camel
+	}
+	return strings.ToUpper(camel[:1]) + camel[1:]
+}
+
+// snakeCase converts an identifier to snake_case
+func snakeCase(name string) string {
+	return strings.Join(words(name), "_")
+}
+
+// sortedKeys returns the property names of a schema in alphabetical order
+func sortedKeys(properties map[string]*Schema) []string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// docLines splits an operation's documentation into lines: its summary, the method and path, and
+// how to retry it safely
+func docLines(op *Operation, idempotencyOption string) []string {
+	lines := []string{}
+	if op.Summary != "" {
+		lines = append(lines, op.Summary)
+	}
+	lines = append(lines, op.Method+" "+op.Path)
+	if op.IdempotencyKey != "" {
+		lines = append(lines, fmt.Sprintf("Retried on failure when %s is set (directly or through %s)", op.IdempotencyKey, idempotencyOption))
+	}
+	return lines
+}
@@ -0,0 +1,234 @@
+package sdkgen
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"internal-transfers/handlers"
+	"internal-transfers/middleware"
+	"internal-transfers/routes"
+)
+
+type testTransfer struct {
+	AccountID int64  `json:"account_id"`
+	Amount    string `json:"amount"`
+	Reference string `json:"reference,omitempty"`
+}
+
+type testTransferList struct {
+	Transfers []testTransfer  `json:"transfers"`
+	Labels    map[string]bool `json:"labels"`
+}
+
+// testDocument describes a small API the way the service's registry does
+func testDocument(t *testing.T) *Document {
+	t.Helper()
+	registry := routes.NewRegistry(
+		routes.Route{
+			Name: "create_transfer", Method: "POST", Path: "/transfers", Summary: "Create a transfer",
+			Request: testTransfer{}, Response: testTransfer{}, Status: http.StatusCreated, IdempotencyKey: "reference",
+		},
+		routes.Route{
+			Name: "list_transfers", Method: "GET", Path: "/accounts/{account_id}/transfers/{kind}",
+			Response: testTransferList{},
+		},
+		routes.Route{Name: "delete_transfer", Method: "DELETE", Path: "/transfers/{transfer_id}", Status: http.StatusNoContent},
+	)
+	data, err := json.Marshal(registry.OpenAPI("Test API", "2.1.0"))
+	if err != nil {
+		t.Fatalf("Failed to encode document: %v", err)
+	}
+	doc, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+	return doc
+}
+
+func TestParse(t *testing.T) {
+	doc := testDocument(t)
+
+	var names []string
+	for _, op := range doc.Operations() {
+		names = append(names, op.Method+" "+op.OperationID)
+	}
+	if got := strings.Join(names, ","); got != "GET list_transfers,POST create_transfer,DELETE delete_transfer" {
+		t.Errorf("Unexpected operation order %s", got)
+	}
+
+	list := doc.Paths["/accounts/{account_id}/transfers/{kind}"]["get"]
+	params := list.PathParameters()
+	if len(params) != 2 || params[0].Name != "account_id" || params[0].Schema.Type != "integer" || params[1].Name != "kind" {
+		t.Errorf("Unexpected path parameters %+v", params)
+	}
+	if schema, _ := list.ResponseSchema(); schema == nil || schema.Properties["transfers"].Items.Properties["amount"].Type != "string" {
+		t.Errorf("Unexpected response schema %+v", schema)
+	}
+	if create := doc.Paths["/transfers"]["post"]; create.IdempotencyKey != "reference" || create.RequestSchema() == nil {
+		t.Errorf("Unexpected create operation %+v", create)
+	}
+	if _, noContent := doc.Paths["/transfers/{transfer_id}"]["delete"].ResponseSchema(); !noContent {
+		t.Error("Expected the 204 response to have no content")
+	}
+
+	if _, err := Parse([]byte(`{"paths": {"/x": {"get": {}}}}`)); err == nil {
+		t.Error("Expected an operation without operationId to be rejected")
+	}
+	legacy, err := Parse([]byte(`{"paths": {"/x": {"get": {"operationId": "legacy_x", "deprecated": true}}, "/v1/x": {"get": {"operationId": "x"}}}}`))
+	if err != nil || len(legacy.Operations()) != 1 || legacy.Operations()[0].Path != "/v1/x" {
+		t.Errorf("Expected the deprecated operation to be left out, got %v", err)
+	}
+}
+
+func TestTypeScript(t *testing.T) {
+	files := TypeScript(testDocument(t), Options{Version: "2.1.0"})
+	src := string(files["src/index.ts"])
+
+	for _, want := range []string{
+		"export type CreateTransferRequest = {\n  account_id?: number;\n  amount?: string;\n  reference?: string;\n};",
+		"  labels?: Record<string, boolean>;",
+		"  createTransfer(body: CreateTransferRequest, options?: RequestOptions): Promise<CreateTransferResponse> {",
+		`    return this.request<CreateTransferResponse>("POST", ` + "`/transfers`" + `, body, "reference", options);`,
+		"  listTransfers(accountId: number, kind: string, options?: RequestOptions): Promise<ListTransfersResponse> {",
+		"`/accounts/${encodeURIComponent(String(accountId))}/transfers/${encodeURIComponent(String(kind))}`",
+		"  deleteTransfer(transferId: number, options?: RequestOptions): Promise<void> {",
+		"   * Retried on failure when reference is set (directly or through options.idempotencyKey)",
+		`"X-API-Key"`, `"` + handlers.TenantHeader + `"`, `"` + middleware.RequestIDHeader + `"`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Expected TypeScript source to contain %q", want)
+		}
+	}
+
+	var pkg map[string]any
+	if err := json.Unmarshal(files["package.json"], &pkg); err != nil || pkg["name"] != NPMPackage || pkg["version"] != "2.1.0" {
+		t.Errorf("Unexpected package.json %s (%v)", files["package.json"], err)
+	}
+
+	// Type-check the package when a TypeScript compiler is installed
+	tsc, err := exec.LookPath("tsc")
+	if err != nil {
+		t.Skip("tsc not installed")
+	}
+	dir := t.TempDir()
+	if err := Generate(dir, testDocument(t), Options{}); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	cmd := exec.Command(tsc, "--noEmit", "-p", filepath.Join(dir, "typescript"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("Generated TypeScript does not compile: %v\n%s", err, out)
+	}
+}
+
+func TestPython(t *testing.T) {
+	files := Python(testDocument(t), Options{Version: "2.1.0"})
+	src := string(files[pythonModule+"/client.py"])
+
+	for _, want := range []string{
+		"class CreateTransferRequest(TypedDict, total=False):\n    account_id: int\n    amount: str\n    reference: str\n",
+		"    labels: Dict[str, bool]\n    transfers: List[Dict[str, Any]]\n",
+		"    def create_transfer(\n        self,\n        body: CreateTransferRequest,\n",
+		`        return self._request("POST", "/transfers", body, "reference", query, headers, idempotency_key, timeout)`,
+		`f"/accounts/{_path(account_id)}/transfers/{_path(kind)}"`,
+		"    ) -> None:",
+		`"X-API-Key"`, `"` + handlers.TenantHeader + `"`, `"` + middleware.RequestIDHeader + `"`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Expected Python source to contain %q", want)
+		}
+	}
+	if !strings.Contains(string(files["pyproject.toml"]), `version = "2.1.0"`) {
+		t.Errorf("Unexpected pyproject.toml:\n%s", files["pyproject.toml"])
+	}
+}
+
+// TestPython_Retries runs the generated Python client against a server that fails the first
+// attempt of every request, when a Python interpreter is installed
+func TestPython_Retries(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not installed")
+	}
+	dir := t.TempDir()
+	if err := Generate(dir, testDocument(t), Options{}); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	requestIDs := make(map[string]map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		key := r.Method + " " + r.URL.Path
+		attempts[key]++
+		attempt := attempts[key]
+		if requestIDs[key] == nil {
+			requestIDs[key] = make(map[string]bool)
+		}
+		requestIDs[key][r.Header.Get(middleware.RequestIDHeader)] = true
+		mu.Unlock()
+
+		if r.Header.Get("X-API-Key") != "secret" {
+			http.Error(w, "missing key", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case attempt == 1:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		case r.Method == "POST":
+			// The first attempt was applied; the retry is a duplicate
+			http.Error(w, "duplicate reference", http.StatusConflict)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"transfers": [{"amount": "5"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	script := `
+import sys
+from internal_transfers_client import Client, ApiError, IdempotencyConflictError
+
+client = Client(sys.argv[1], api_key="secret", retry_delay=0.01)
+print(client.list_transfers(7, "sent", query={"limit": 1})["transfers"][0]["amount"])
+try:
+    client.create_transfer({"account_id": 7, "amount": "5"}, idempotency_key="INV-1")
+except IdempotencyConflictError as err:
+    print("conflict", err.status)
+try:
+    client.create_transfer({"account_id": 7, "amount": "5"})
+except IdempotencyConflictError:
+    print("unexpected conflict")
+except ApiError as err:
+    print("error", err.status)
+`
+	cmd := exec.Command(python, "-c", script, server.URL)
+	cmd.Dir = filepath.Join(dir, "python")
+	cmd.Env = append(os.Environ(), "PYTHONDONTWRITEBYTECODE=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Python client failed: %v\n%s", err, out)
+	}
+	if got := string(out); got != "5\nconflict 409\nerror 409\n" {
+		t.Errorf("Unexpected output:\n%s", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The GET and the keyed POST were retried once; the unkeyed POST was not retried, so its
+	// second call reached the server as the POST's third attempt
+	if attempts["GET /accounts/7/transfers/sent"] != 2 || attempts["POST /transfers"] != 3 {
+		t.Errorf("Unexpected attempts %v", attempts)
+	}
+	if len(requestIDs["GET /accounts/7/transfers/sent"]) != 1 {
+		t.Errorf("Expected retries to reuse the request ID, got %v", requestIDs)
+	}
+}
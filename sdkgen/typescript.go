@@ -0,0 +1,154 @@
+package sdkgen
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//go:embed runtime/runtime.ts
+var typescriptRuntime string
+
+// tsIdentifier matches property names usable unquoted in a TypeScript type
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// TypeScript returns the files of the TypeScript package by path: package.json, tsconfig.json and
+// src/index.ts, which holds the runtime, a type per request and response body and the Client
+func TypeScript(doc *Document, opts Options) map[string][]byte {
+	var src strings.Builder
+	src.WriteString("// Code generated by internal-transfers sdk; DO NOT EDIT.\n\n")
+	src.WriteString(typescriptRuntime)
+
+	var methods strings.Builder
+	for _, op := range doc.Operations() {
+		name := pascalCase(op.OperationID)
+		args := []string{}
+		for _, param := range op.PathParameters() {
+			args = append(args, fmt.Sprintf("%s: %s", camelCase(param.Name), tsType(param.Schema, "")))
+		}
+
+		body := "undefined"
+		if schema := op.RequestSchema(); schema != nil {
+			fmt.Fprintf(&src, "\nexport type %sRequest = %s;\n", name, tsType(schema, ""))
+			args = append(args, fmt.Sprintf("body: %sRequest", name))
+			body = "body"
+		}
+		result := "unknown"
+		if schema, noContent := op.ResponseSchema(); noContent {
+			result = "void"
+		} else if schema != nil {
+			fmt.Fprintf(&src, "\nexport type %sResponse = %s;\n", name, tsType(schema, ""))
+			result = name + "Response"
+		}
+		args = append(args, "options?: RequestOptions")
+
+		idempotency := "undefined"
+		if op.IdempotencyKey != "" {
+			idempotency = quote(op.IdempotencyKey)
+		}
+		methods.WriteString("\n  /**\n")
+		for _, line := range docLines(op, "options.idempotencyKey") {
+			fmt.Fprintf(&methods, "   * %s\n", line)
+		}
+		methods.WriteString("   */\n")
+		fmt.Fprintf(&methods, "  %s(%s): Promise<%s> {\n", camelCase(op.OperationID), strings.Join(args, ", "), result)
+		fmt.Fprintf(&methods, "    return this.request<%s>(%s, %s, %s, %s, options);\n", result, quote(op.Method), tsPath(op), body, idempotency)
+		methods.WriteString("  }\n")
+	}
+
+	fmt.Fprintf(&src, "\n// Client of the %s; every method throws ApiError for a non-2xx response\n", doc.Info.Title)
+	src.WriteString("export class Client extends BaseClient {")
+	src.WriteString(methods.String())
+	src.WriteString("}\n")
+
+	packageJSON, _ := json.MarshalIndent(map[string]any{
+		"name":        NPMPackage,
+		"version":     opts.Version,
+		"description": "Generated client for the " + doc.Info.Title,
+		"main":        "dist/index.js",
+		"types":       "dist/index.d.ts",
+		"files":       []string{"dist"},
+		"engines":     map[string]string{"node": ">=18"},
+		"scripts": map[string]string{
+			"build":          "tsc",
+			"prepublishOnly": "npm run build",
+		},
+		"devDependencies": map[string]string{"typescript": "^5.4.0"},
+	}, "", "  ")
+	tsconfig, _ := json.MarshalIndent(map[string]any{
+		"compilerOptions": map[string]any{
+			"target":      "ES2020",
+			"module":      "commonjs",
+			"lib":         []string{"ES2020", "DOM"},
+			"declaration": true,
+			"strict":      true,
+			"outDir":      "dist",
+		},
+		"include": []string{"src"},
+	}, "", "  ")
+
+	return map[string][]byte{
+		"package.json":  append(packageJSON, '\n'),
+		"tsconfig.json": append(tsconfig, '\n'),
+		"src/index.ts":  []byte(src.String()),
+	}
+}
+
+// tsType renders a schema as a TypeScript type; indent is the indentation of the enclosing line
+// Every property is optional, as the service omits empty fields
+func tsType(schema *Schema, indent string) string {
+	if schema == nil {
+		return "unknown"
+	}
+	switch schema.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		item := tsType(schema.Items, indent)
+		if strings.ContainsAny(item, " |") {
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	case "object":
+		if schema.AdditionalProperties != nil {
+			return "Record<string, " + tsType(schema.AdditionalProperties, indent) + ">"
+		}
+		if len(schema.Properties) == 0 {
+			return "Record<string, unknown>"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, key := range sortedKeys(schema.Properties) {
+			name := key
+			if !tsIdentifier.MatchString(key) {
+				name = quote(key)
+			}
+			fmt.Fprintf(&b, "%s  %s?: %s;\n", indent, name, tsType(schema.Properties[key], indent+"  "))
+		}
+		b.WriteString(indent + "}")
+		return b.String()
+	default:
+		return "unknown"
+	}
+}
+
+// tsPath renders the operation's path as a template literal substituting the encoded path arguments
+func tsPath(op *Operation) string {
+	path := pathParamPattern.ReplaceAllStringFunc(op.Path, func(param string) string {
+		name := pathParamPattern.FindStringSubmatch(param)[1]
+		return "${encodeURIComponent(String(" + camelCase(name) + "))}"
+	})
+	return "`" + strings.ReplaceAll(path, "`", "\\`") + "`"
+}
+
+// quote renders a string literal valid in both TypeScript and Python
+func quote(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// AccountService creates, reads and administers accounts
+type AccountService struct {
+	accounts    database.AccountRepositoryInterface
+	adjustments database.AdjustmentRepositoryInterface
+	wallets     database.WalletRepositoryInterface
+	rounding    models.RoundingPolicy
+	currencies  *models.Currencies
+	hooks       accountHooks
+}
+
+// NewAccountService creates an account service using the default rounding policy and the built-in
+// currencies
+func NewAccountService(accounts database.AccountRepositoryInterface) *AccountService {
+	return &AccountService{accounts: accounts, rounding: models.DefaultRoundingPolicy}
+}
+
+// WithRoundingPolicy sets the policy used to round opening balances to the stored scale
+// Returns the service to allow chaining after NewAccountService
+func (s *AccountService) WithRoundingPolicy(policy models.RoundingPolicy) *AccountService {
+	s.rounding = policy
+	return s
+}
+
+// WithCurrencies sets the supported currencies accounts, overdraft limits and adjustments are
+// validated against; nil keeps the built-in ones
+// Returns the service to allow chaining after NewAccountService
+func (s *AccountService) WithCurrencies(currencies *models.Currencies) *AccountService {
+	s.currencies = currencies
+	return s
+}
+
+// CreateAccount opens an account with an initial balance
+// Validation rules:
+//   - Account ID must be positive
+//   - Initial balance must be a valid, non-negative decimal; it is rounded to the stored scale
+//   - Currency is optional; if set it must be supported, and the initial balance may not have
+//     more decimal places than the currency allows
+//   - External ID is optional; if set it must be a valid external ID not used by another account
+//   - Creation token is optional; if set it must be a valid token not used by another account
+//
+// With a creation token the creation is idempotent: if the account already exists and was created
+// with the same token and an identical request, nothing is created and created is false, so a
+// client can retry a creation whose response it lost
+// OnAccountCreated hooks run once the account exists
+// Returns a *ValidationError, ErrAccountExists, ErrExternalIDExists, ErrCreationTokenUsed if the
+// token was used for a different request, or a storage error
+func (s *AccountService) CreateAccount(req models.CreateAccountRequest) (created bool, err error) {
+	initialBalance, currency, err := req.Validate(s.currencies)
+	if err != nil {
+		return false, invalid(err)
+	}
+	initialBalance = s.rounding.RoundAmount(initialBalance)
+
+	exists, err := s.accounts.AccountExists(req.AccountID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check account: %w", err)
+	}
+	if exists {
+		return false, s.replayCreation(req, initialBalance, currency)
+	}
+
+	// A concurrent request may still create the account after the existence check; the repository
+	// reports that as ErrAccountExists too
+	if err := s.accounts.CreateAccount(models.NewAccount{
+		AccountID: req.AccountID, InitialBalance: initialBalance, Currency: currency,
+		ExternalID: req.ExternalID, CreationToken: req.CreationToken,
+	}); err != nil {
+		if err = translate(err); err == ErrAccountExists {
+			return false, s.replayCreation(req, initialBalance, currency)
+		}
+		return false, err
+	}
+
+	s.accountCreated(models.Account{
+		AccountID: req.AccountID, Balance: initialBalance, InitialBalance: initialBalance, Currency: currency,
+		ExternalID: req.ExternalID, CreationToken: req.CreationToken, Status: models.AccountActive,
+	})
+	return true, nil
+}
+
+// replayCreation checks a creation request for an account that already exists
+// Returns nil if the account was created by an earlier attempt of the request (same creation
+// token, initial balance, currency and external ID), ErrCreationTokenUsed if it was created with
+// the token but a different request, and ErrAccountExists otherwise
+func (s *AccountService) replayCreation(req models.CreateAccountRequest, initialBalance decimal.Decimal, currency string) error {
+	if req.CreationToken == "" {
+		return ErrAccountExists
+	}
+	account, err := s.accounts.GetAccount(req.AccountID)
+	if err != nil {
+		return translate(err)
+	}
+	switch {
+	case account.CreationToken != req.CreationToken:
+		return ErrAccountExists
+	case !account.InitialBalance.Equal(initialBalance) || account.Currency != currency || account.ExternalID != req.ExternalID:
+		return ErrCreationTokenUsed
+	}
+	return nil
+}
+
+// GetAccount returns an account's balance, latest sequence number and status
+// Returns ErrAccountNotFound or a storage error
+func (s *AccountService) GetAccount(accountID int64) (*models.Account, error) {
+	account, err := s.accounts.GetAccount(accountID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return account, nil
+}
+
+// GetAccountByExternalID returns the account with the given external ID
+// Returns ErrAccountNotFound or a storage error
+func (s *AccountService) GetAccountByExternalID(externalID string) (*models.Account, error) {
+	account, err := s.accounts.GetAccountByExternalID(externalID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return account, nil
+}
+
+// UpdateAccount changes an account's metadata, tags and external ID (see models.UpdateAccountRequest)
+// With a non-zero expectedVersion the update only applies if the account is still at that version,
+// so a client that read the account cannot overwrite changes made since (optimistic concurrency)
+// Returns the updated account, a *ValidationError, ErrAccountNotFound, ErrVersionMismatch,
+// ErrExternalIDExists or a storage error
+func (s *AccountService) UpdateAccount(accountID int64, req models.UpdateAccountRequest, expectedVersion int64) (*models.Account, error) {
+	update, err := req.Validate()
+	if err != nil {
+		return nil, invalid(err)
+	}
+	update.ExpectedVersion = expectedVersion
+	account, err := s.accounts.UpdateAccount(accountID, update)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return account, nil
+}
+
+// ListAccounts returns up to limit accounts matching the filter, ordered by account ID, after
+// skipping the first offset matches, together with the total number of matching accounts
+func (s *AccountService) ListAccounts(filter models.AccountFilter, limit, offset int) ([]models.Account, int, error) {
+	return s.accounts.ListAccounts(filter, limit, offset)
+}
+
+// Freeze places a compliance hold on an account; transfers debiting or crediting it are refused
+// with ErrSourceFrozen or ErrDestinationFrozen until it is unfrozen. Freezing is idempotent
+// Returns the updated account, ErrAccountNotFound or a storage error
+func (s *AccountService) Freeze(accountID int64) (*models.Account, error) {
+	return s.setStatus(accountID, models.AccountFrozen)
+}
+
+// Unfreeze lifts a compliance hold. Unfreezing an active account is a no-op
+// Returns the updated account, ErrAccountNotFound or a storage error
+func (s *AccountService) Unfreeze(accountID int64) (*models.Account, error) {
+	return s.setStatus(accountID, models.AccountActive)
+}
+
+// SetOverdraftLimit changes how far below zero an account's balance may go, so credit-style
+// internal accounts can be debited beyond their balance; a limit of zero forbids negative balances
+// Validation rules:
+//   - The limit must be a valid, non-negative decimal with no more decimal places than the
+//     account's currency allows (the stored scale for accounts without a currency)
+//   - The limit must be zero if the account's currency disallows negative balances
+//
+// Returns the updated account, a *ValidationError, ErrAccountNotFound, ErrOverdrawn if the account
+// is already overdrawn by more than the new limit, or a storage error
+func (s *AccountService) SetOverdraftLimit(accountID int64, req models.SetOverdraftLimitRequest) (*models.Account, error) {
+	limit, err := decimal.NewFromString(req.OverdraftLimit)
+	if err != nil {
+		return nil, invalid(errors.New("Invalid overdraft limit format"))
+	}
+	if limit.IsNegative() {
+		return nil, invalid(errors.New("Overdraft limit cannot be negative"))
+	}
+
+	account, err := s.accounts.GetAccount(accountID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	scale, _ := s.currencies.Scale(account.Currency)
+	if !s.currencies.Fits(account.Currency, limit) {
+		return nil, invalid(fmt.Errorf("Overdraft limit has more than %d decimal places", scale))
+	}
+	if limit.IsPositive() && !s.currencies.AllowsNegative(account.Currency) {
+		return nil, invalid(fmt.Errorf("%s accounts cannot have negative balances", account.Currency))
+	}
+
+	account, err = s.accounts.SetOverdraftLimit(accountID, limit)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return account, nil
+}
+
+// WithAdjustments sets the repository that books manual adjustments
+// Returns the service to allow chaining after NewAccountService
+func (s *AccountService) WithAdjustments(adjustments database.AdjustmentRepositoryInterface) *AccountService {
+	s.adjustments = adjustments
+	return s
+}
+
+// Adjust books a manual credit (positive amount) or debit (negative amount) of a single account to
+// correct an operational error, recorded with its reason and the operator who made it
+// Validation rules:
+//   - See models.CreateAdjustmentRequest.Validate
+//   - The amount may not have more decimal places than the account's currency allows (the stored
+//     scale for accounts without a currency)
+//
+// Adjustments are not transfers: no limits, rules or hooks apply, and frozen accounts can be
+// adjusted. A debit may not exceed the account's available balance
+// Returns the booked adjustment, a *ValidationError, ErrAccountNotFound, ErrInsufficientBalance or
+// a storage error
+func (s *AccountService) Adjust(ctx context.Context, req models.CreateAdjustmentRequest) (*models.Adjustment, error) {
+	amount, err := req.Validate()
+	if err != nil {
+		return nil, invalid(err)
+	}
+
+	account, err := s.accounts.GetAccount(req.AccountID)
+	if err != nil {
+		return nil, translate(err)
+	}
+	scale, _ := s.currencies.Scale(account.Currency)
+	if !s.currencies.Fits(account.Currency, amount) {
+		return nil, invalid(fmt.Errorf("Amount has more than %d decimal places", scale))
+	}
+
+	adjustment, err := s.adjustments.CreateAdjustment(ctx, models.Adjustment{
+		AccountID: req.AccountID,
+		Amount:    amount,
+		Reason:    req.Reason,
+		Actor:     req.Actor,
+	})
+	if err != nil {
+		return nil, translate(err)
+	}
+	return adjustment, nil
+}
+
+// WithWallets sets the repository of multi-currency accounts' wallets
+// Returns the service to allow chaining after NewAccountService
+func (s *AccountService) WithWallets(wallets database.WalletRepositoryInterface) *AccountService {
+	s.wallets = wallets
+	return s
+}
+
+// OpenWallet opens an empty wallet of the account in another currency, so transfers naming that
+// currency can credit and debit it (see models.Wallet)
+// Validation rules:
+//   - Currency is required and must be supported
+//
+// Returns the opened wallet, a *ValidationError, ErrAccountNotFound, ErrAccountNoCurrency for an
+// account without a currency, ErrWalletExists if the account already holds the currency, or a
+// storage error
+func (s *AccountService) OpenWallet(ctx context.Context, accountID int64, req models.OpenWalletRequest) (*models.Wallet, error) {
+	currency := models.NormalizeCurrency(req.Currency)
+	if currency == "" {
+		return nil, invalid(errors.New("Currency is required"))
+	}
+	if _, ok := s.currencies.Scale(currency); !ok {
+		return nil, invalid(fmt.Errorf("Unsupported currency %q", req.Currency))
+	}
+	wallet, err := s.wallets.OpenWallet(ctx, accountID, currency)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return wallet, nil
+}
+
+// Wallets returns the wallets of the account, ordered by currency, without its primary balance
+// Returns an empty list if the service has no wallet repository
+func (s *AccountService) Wallets(ctx context.Context, accountID int64) ([]models.Wallet, error) {
+	if s.wallets == nil {
+		return []models.Wallet{}, nil
+	}
+	return s.wallets.ListWallets(ctx, accountID)
+}
+
+func (s *AccountService) setStatus(accountID int64, status string) (*models.Account, error) {
+	account, err := s.accounts.SetAccountStatus(accountID, status)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return account, nil
+}
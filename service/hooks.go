@@ -0,0 +1,78 @@
+package service
+
+import (
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	"internal-transfers/models"
+)
+
+// Hooks let embedders and plugins react to ledger events in-process, without the outbox and a
+// message broker. Hooks run synchronously after the change has committed, in registration order,
+// on the goroutine that made the change, so they should be quick and hand slow work off.
+// A hook cannot undo or fail the change; a panicking hook is logged and the remaining hooks still
+// run. Only changes made through the services are reported: settlement returns, for example, are
+// booked by the settlement package directly against storage. For durable, cross-process delivery
+// use the outbox (see the outbox package)
+
+// accountHooks holds the callbacks registered on an AccountService
+type accountHooks struct {
+	mu      sync.RWMutex
+	created []func(models.Account)
+}
+
+// OnAccountCreated registers a callback invoked with every account created through the service
+// Safe to call concurrently with account creation
+func (s *AccountService) OnAccountCreated(hook func(account models.Account)) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	s.hooks.created = append(s.hooks.created, hook)
+}
+
+// accountCreated runs the OnAccountCreated hooks
+func (s *AccountService) accountCreated(account models.Account) {
+	s.hooks.mu.RLock()
+	hooks := s.hooks.created
+	s.hooks.mu.RUnlock()
+
+	for _, hook := range hooks {
+		runHook("OnAccountCreated", func() { hook(account) })
+	}
+}
+
+// transferHooks holds the callbacks registered on a TransferService
+type transferHooks struct {
+	mu        sync.RWMutex
+	committed []func(models.Transaction)
+}
+
+// OnTransferCommitted registers a callback invoked with every transfer committed through the
+// service, including its ID and per-account sequence numbers
+// Safe to call concurrently with transfers
+func (s *TransferService) OnTransferCommitted(hook func(transaction models.Transaction)) {
+	s.hooks.mu.Lock()
+	defer s.hooks.mu.Unlock()
+	s.hooks.committed = append(s.hooks.committed, hook)
+}
+
+// transferCommitted runs the OnTransferCommitted hooks
+func (s *TransferService) transferCommitted(transaction models.Transaction) {
+	s.hooks.mu.RLock()
+	hooks := s.hooks.committed
+	s.hooks.mu.RUnlock()
+
+	for _, hook := range hooks {
+		runHook("OnTransferCommitted", func() { hook(transaction) })
+	}
+}
+
+// runHook calls a hook, logging instead of propagating a panic
+func runHook(name string, call func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			slog.Error("Hook panicked", "hook", name, "panic", err, "stack", string(debug.Stack()))
+		}
+	}()
+	call()
+}
@@ -0,0 +1,129 @@
+// Package service holds the ledger's business logic behind a stable Go API
+// AccountService and TransferService apply the same validation, rounding and value-dating rules as
+// the HTTP and GraphQL APIs, which are thin adapters over them. Other Go programs (CLI tools, batch
+// jobs) embed the ledger by constructing the services on a database.Storage:
+//
+//	storage, _ := database.OpenPostgresStorage()
+//	accounts, transfers := service.New(storage)
+//	transaction, err := transfers.Transfer(models.CreateTransactionRequest{...})
+//
+// Failures are reported as the sentinel errors below (compare with errors.Is) or a
+// *ValidationError; any other error is an unexpected storage failure
+package service
+
+import (
+	"errors"
+
+	"internal-transfers/database"
+	"internal-transfers/validation"
+)
+
+// Business rule violations reported by the services
+var (
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrAccountExists       = errors.New("account already exists")
+	ErrVersionMismatch     = errors.New("account version mismatch")
+	ErrSourceNotFound      = errors.New("source account not found")
+	ErrDestinationNotFound = errors.New("destination account not found")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrSourceFrozen        = errors.New("source account frozen")
+	ErrDestinationFrozen   = errors.New("destination account frozen")
+	ErrCurrencyMismatch    = errors.New("currency mismatch")
+	ErrCurrencyPrecision   = errors.New("amount exceeds currency precision")
+	ErrOverdrawn           = errors.New("balance below overdraft limit")
+	ErrHoldNotFound        = errors.New("hold not found")
+	ErrHoldNotActive       = errors.New("hold not active")
+	ErrHoldExpired         = errors.New("hold expired")
+	ErrHoldExceeded        = errors.New("capture exceeds hold amount")
+	ErrAmountLimit         = errors.New("transfer amount limit exceeded")
+	ErrDailyAmountLimit    = errors.New("daily amount limit exceeded")
+	ErrDailyCountLimit     = errors.New("daily count limit exceeded")
+	ErrDuplicateReference  = errors.New("duplicate reference")
+	ErrDependencyNotFound  = errors.New("dependency not found")
+	ErrDependencyPending   = errors.New("dependency pending")
+	ErrDependencyFailed    = errors.New("dependency failed")
+	ErrWalletExists        = errors.New("wallet already exists")
+	ErrAccountNoCurrency   = errors.New("account has no currency")
+	ErrSourceWallet        = errors.New("source wallet not found")
+	ErrDestinationWallet   = errors.New("destination wallet not found")
+	ErrAmountBelowMinimum  = errors.New("amount below currency minimum")
+	ErrAmountAboveMaximum  = errors.New("amount above currency maximum")
+	ErrCurrencyOverdrafts  = errors.New("currency has overdraft limits")
+	ErrExternalIDExists    = errors.New("external ID already exists")
+	ErrCreationTokenUsed   = errors.New("creation token already used")
+	ErrAPIKeyNotFound      = errors.New("API key not found")
+
+	// ErrTransactionConflict reports a transfer that kept conflicting with concurrent transfers
+	// after the storage's retries; it can be retried later as is
+	ErrTransactionConflict = errors.New("transaction conflict")
+)
+
+// repositoryErrors maps the repositories' error messages to the exported sentinels
+// Repositories report business rule violations as plain errors with these exact messages
+var repositoryErrors = map[string]error{
+	ErrAccountNotFound.Error():     ErrAccountNotFound,
+	ErrAccountExists.Error():       ErrAccountExists,
+	ErrVersionMismatch.Error():     ErrVersionMismatch,
+	ErrSourceNotFound.Error():      ErrSourceNotFound,
+	ErrDestinationNotFound.Error(): ErrDestinationNotFound,
+	ErrInsufficientBalance.Error(): ErrInsufficientBalance,
+	ErrSourceFrozen.Error():        ErrSourceFrozen,
+	ErrDestinationFrozen.Error():   ErrDestinationFrozen,
+	ErrCurrencyMismatch.Error():    ErrCurrencyMismatch,
+	ErrCurrencyPrecision.Error():   ErrCurrencyPrecision,
+	ErrOverdrawn.Error():           ErrOverdrawn,
+	ErrHoldNotFound.Error():        ErrHoldNotFound,
+	ErrHoldNotActive.Error():       ErrHoldNotActive,
+	ErrHoldExpired.Error():         ErrHoldExpired,
+	ErrHoldExceeded.Error():        ErrHoldExceeded,
+	ErrAmountLimit.Error():         ErrAmountLimit,
+	ErrDailyAmountLimit.Error():    ErrDailyAmountLimit,
+	ErrDailyCountLimit.Error():     ErrDailyCountLimit,
+	ErrDuplicateReference.Error():  ErrDuplicateReference,
+	ErrDependencyNotFound.Error():  ErrDependencyNotFound,
+	ErrDependencyPending.Error():   ErrDependencyPending,
+	ErrDependencyFailed.Error():    ErrDependencyFailed,
+	ErrWalletExists.Error():        ErrWalletExists,
+	ErrAccountNoCurrency.Error():   ErrAccountNoCurrency,
+	ErrSourceWallet.Error():        ErrSourceWallet,
+	ErrDestinationWallet.Error():   ErrDestinationWallet,
+	ErrAmountBelowMinimum.Error():  ErrAmountBelowMinimum,
+	ErrAmountAboveMaximum.Error():  ErrAmountAboveMaximum,
+	ErrCurrencyOverdrafts.Error():  ErrCurrencyOverdrafts,
+	ErrExternalIDExists.Error():    ErrExternalIDExists,
+	ErrCreationTokenUsed.Error():   ErrCreationTokenUsed,
+	ErrAPIKeyNotFound.Error():      ErrAPIKeyNotFound,
+	ErrTransactionConflict.Error(): ErrTransactionConflict,
+}
+
+// ValidationError reports an invalid request; its message is safe to show to the caller
+// Fields lists the violation of each invalid field for requests checked field by field (see the
+// validation package); it is empty for failures not tied to a field
+type ValidationError struct {
+	Message string
+	Fields  validation.Errors
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+// invalid wraps a client-facing validation failure, keeping its field violations if it has any
+func invalid(err error) error {
+	var fields validation.Errors
+	errors.As(err, &fields)
+	return &ValidationError{Message: err.Error(), Fields: fields}
+}
+
+// translate converts a repository error into the matching sentinel, leaving other errors unchanged
+func translate(err error) error {
+	if sentinel, ok := repositoryErrors[err.Error()]; ok {
+		return sentinel
+	}
+	return err
+}
+
+// New creates the account and transfer services on a storage backend with default settings
+// Use the services' With* setters to change the rounding policy, cut-off schedule or currencies
+func New(storage database.Storage) (*AccountService, *TransferService) {
+	return NewAccountService(storage.Accounts()).WithAdjustments(storage.Adjustments()).WithWallets(storage.Wallets()),
+		NewTransferService(storage.Transactions()).WithAdjustments(storage.Adjustments())
+}
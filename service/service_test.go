@@ -0,0 +1,428 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/cutoff"
+	"internal-transfers/fx"
+	"internal-transfers/memory"
+	"internal-transfers/models"
+	"internal-transfers/rules"
+	"internal-transfers/sla"
+)
+
+func TestAccountService_CreateAccount(t *testing.T) {
+	accounts, _ := New(memory.NewStore())
+	accounts.WithRoundingPolicy(models.RoundTruncate)
+
+	testCases := []struct {
+		name    string
+		req     models.CreateAccountRequest
+		wantErr error
+		invalid bool
+	}{
+		{"Success", models.CreateAccountRequest{AccountID: 1, InitialBalance: "10.123456"}, nil, false},
+		{"Duplicate", models.CreateAccountRequest{AccountID: 1, InitialBalance: "1"}, ErrAccountExists, false},
+		{"Non-positive ID", models.CreateAccountRequest{AccountID: 0, InitialBalance: "1"}, nil, true},
+		{"Malformed balance", models.CreateAccountRequest{AccountID: 2, InitialBalance: "ten"}, nil, true},
+		{"Negative balance", models.CreateAccountRequest{AccountID: 2, InitialBalance: "-1"}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := accounts.CreateAccount(tc.req)
+			var validation *ValidationError
+			if tc.invalid != errors.As(err, &validation) {
+				t.Fatalf("Expected validation error %v, got %v", tc.invalid, err)
+			}
+			if !tc.invalid && !errors.Is(err, tc.wantErr) {
+				t.Errorf("Expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	account, err := accounts.GetAccount(1)
+	if err != nil || !account.Balance.Equal(decimal.RequireFromString("10.12345")) || account.Status != models.AccountActive {
+		t.Errorf("Unexpected account %+v (%v)", account, err)
+	}
+	if _, err := accounts.GetAccount(99); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+
+	var validation *ValidationError
+	if _, err := accounts.UpdateAccount(1, models.UpdateAccountRequest{}, 0); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for empty update, got %v", err)
+	}
+	if _, err := accounts.UpdateAccount(99, models.UpdateAccountRequest{Tags: &[]string{"eu"}}, 0); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+
+	// Conditional updates apply only at the expected version
+	if updated, err := accounts.UpdateAccount(1, models.UpdateAccountRequest{Tags: &[]string{"eu"}}, 1); err != nil || updated.Version != 2 {
+		t.Errorf("Expected update at version 1 to succeed, got %+v (%v)", updated, err)
+	}
+	if _, err := accounts.UpdateAccount(1, models.UpdateAccountRequest{Tags: &[]string{"us"}}, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+}
+
+func TestAccountService_SetOverdraftLimit(t *testing.T) {
+	accounts, transfers := New(memory.NewStore())
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "0", Currency: "JPY"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0", Currency: "JPY"})
+
+	var validation *ValidationError
+	for _, limit := range []string{"abc", "-1", "10.5"} {
+		if _, err := accounts.SetOverdraftLimit(1, models.SetOverdraftLimitRequest{OverdraftLimit: limit}); !errors.As(err, &validation) {
+			t.Errorf("Expected validation error for limit %q, got %v", limit, err)
+		}
+	}
+	if _, err := accounts.SetOverdraftLimit(9, models.SetOverdraftLimitRequest{OverdraftLimit: "1"}); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+
+	if _, err := accounts.SetOverdraftLimit(1, models.SetOverdraftLimitRequest{OverdraftLimit: "1000"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "800"}); err != nil {
+		t.Fatalf("Expected the transfer to use the overdraft, got %v", err)
+	}
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "201"}); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance beyond the limit, got %v", err)
+	}
+	if _, err := accounts.SetOverdraftLimit(1, models.SetOverdraftLimitRequest{OverdraftLimit: "0"}); !errors.Is(err, ErrOverdrawn) {
+		t.Errorf("Expected ErrOverdrawn, got %v", err)
+	}
+}
+
+func TestTransferService_Transfer(t *testing.T) {
+	accounts, transfers := New(memory.NewStore())
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "100"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0"})
+
+	transfer := func(source, destination int64, amount string) error {
+		_, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: source, DestinationAccountID: destination, Amount: amount})
+		return err
+	}
+
+	var validation *ValidationError
+	if err := transfer(1, 1, "1"); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for same account, got %v", err)
+	}
+	if err := transfer(1, 2, "0.000001"); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for amount rounding to zero, got %v", err)
+	}
+	if err := transfer(9, 2, "1"); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("Expected ErrSourceNotFound, got %v", err)
+	}
+	if err := transfer(1, 9, "1"); !errors.Is(err, ErrDestinationNotFound) {
+		t.Errorf("Expected ErrDestinationNotFound, got %v", err)
+	}
+	if err := transfer(1, 2, "101"); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got %v", err)
+	}
+
+	accounts.Freeze(2)
+	if err := transfer(1, 2, "1"); !errors.Is(err, ErrDestinationFrozen) {
+		t.Errorf("Expected ErrDestinationFrozen, got %v", err)
+	}
+	if err := transfer(2, 1, "1"); !errors.Is(err, ErrSourceFrozen) {
+		t.Errorf("Expected ErrSourceFrozen, got %v", err)
+	}
+	if account, err := accounts.Unfreeze(2); err != nil || account.Status != models.AccountActive {
+		t.Errorf("Unexpected account after unfreeze %+v (%v)", account, err)
+	}
+	if _, err := accounts.Freeze(99); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("Expected ErrAccountNotFound, got %v", err)
+	}
+
+	if err := transfer(1, 2, "40"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	history, err := transfers.Transactions(2, 10)
+	if err != nil || len(history) != 1 || !history[0].Amount.Equal(decimal.NewFromInt(40)) {
+		t.Errorf("Unexpected history %+v (%v)", history, err)
+	}
+}
+
+func TestTransferService_Changes(t *testing.T) {
+	accounts, transfers := New(memory.NewStore())
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "100"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0"})
+	for _, req := range []models.CreateTransactionRequest{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10"},
+		{SourceAccountID: 2, DestinationAccountID: 1, Amount: "2.5"},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1"},
+	} {
+		if _, err := transfers.Transfer(req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	changes, hasMore, err := transfers.Changes(1, 0, 2)
+	if err != nil || !hasMore || len(changes) != 2 {
+		t.Fatalf("Expected a full page with more to follow, got %+v, %v (%v)", changes, hasMore, err)
+	}
+	if changes[0].Seq != 1 || changes[0].Amount != "-10" || changes[0].CounterpartyID != 2 ||
+		changes[1].Seq != 2 || changes[1].Amount != "2.5" || changes[1].TransactionID != 2 {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+
+	changes, hasMore, _ = transfers.Changes(1, 2, 2)
+	if hasMore || len(changes) != 1 || changes[0].Seq != 3 || changes[0].Amount != "-1" {
+		t.Errorf("Expected the last change without more, got %+v, %v", changes, hasMore)
+	}
+}
+
+func TestAccountService_CreationToken(t *testing.T) {
+	accounts, _ := New(memory.NewStore())
+	req := models.CreateAccountRequest{AccountID: 1, InitialBalance: "100.50", Currency: "usd", CreationToken: "provision-1"}
+	if created, err := accounts.CreateAccount(req); err != nil || !created {
+		t.Fatalf("Expected the account to be created, got %v (%v)", created, err)
+	}
+	// A retry with the same token and payload is not a conflict
+	if created, err := accounts.CreateAccount(req); err != nil || created {
+		t.Errorf("Expected the creation to be replayed, got %v (%v)", created, err)
+	}
+
+	changed := req
+	changed.InitialBalance = "200"
+	if _, err := accounts.CreateAccount(changed); !errors.Is(err, ErrCreationTokenUsed) {
+		t.Errorf("Expected ErrCreationTokenUsed for a different payload, got %v", err)
+	}
+	other := req
+	other.AccountID = 2
+	if _, err := accounts.CreateAccount(other); !errors.Is(err, ErrCreationTokenUsed) {
+		t.Errorf("Expected ErrCreationTokenUsed for another account, got %v", err)
+	}
+	untokened := req
+	untokened.CreationToken = ""
+	if _, err := accounts.CreateAccount(untokened); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("Expected ErrAccountExists without the token, got %v", err)
+	}
+	retokened := req
+	retokened.CreationToken = "provision-2"
+	if _, err := accounts.CreateAccount(retokened); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("Expected ErrAccountExists with another token, got %v", err)
+	}
+	var validation *ValidationError
+	invalid := models.CreateAccountRequest{AccountID: 3, InitialBalance: "1", CreationToken: "not a token"}
+	if _, err := accounts.CreateAccount(invalid); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for a malformed token, got %v", err)
+	}
+}
+
+func TestTransferService_Currencies(t *testing.T) {
+	accounts, transfers := New(memory.NewStore())
+
+	var validation *ValidationError
+	if _, err := accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "1", Currency: "XYZ"}); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for unsupported currency, got %v", err)
+	}
+	if _, err := accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "100.5", Currency: "JPY"}); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for fractional yen, got %v", err)
+	}
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "100.50", Currency: "usd"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0", Currency: "USD"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 3, InitialBalance: "0", Currency: "JPY"})
+
+	if account, err := accounts.GetAccount(1); err != nil || account.Currency != "USD" {
+		t.Errorf("Expected normalized currency USD, got %+v (%v)", account, err)
+	}
+
+	transfer := func(source, destination int64, amount string) error {
+		_, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: source, DestinationAccountID: destination, Amount: amount})
+		return err
+	}
+	if err := transfer(1, 3, "1"); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch, got %v", err)
+	}
+	if err := transfer(1, 2, "1.005"); !errors.Is(err, ErrCurrencyPrecision) {
+		t.Errorf("Expected ErrCurrencyPrecision, got %v", err)
+	}
+	if err := transfer(1, 2, "1.25"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTransferService_Convert(t *testing.T) {
+	store := memory.NewStore()
+	accounts, transfers := New(store)
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "100", Currency: "EUR"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0", Currency: "JPY"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 3, InitialBalance: "0", Currency: "GBP"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 4, InitialBalance: "0"})
+
+	convert := func(destination int64, amount string) (*models.Transaction, error) {
+		return transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: destination, Amount: amount, Convert: true})
+	}
+
+	var validation *ValidationError
+	if _, err := convert(2, "10"); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error without a rate provider, got %v", err)
+	}
+
+	transfers.WithFX(fx.NewStaticProvider(map[string]decimal.Decimal{"EUR/JPY": decimal.RequireFromString("162.345")}), store.Accounts())
+	transaction, err := convert(2, "10.01")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// 10.01 EUR at 162.345 is 1625.07345 JPY, rounded half up to whole yen
+	if !transaction.Amount.Equal(decimal.RequireFromString("10.01")) || !transaction.DestinationAmount.Equal(decimal.NewFromInt(1625)) ||
+		!transaction.FXRate.Equal(decimal.RequireFromString("162.345")) || transaction.FXRateTimestamp.IsZero() {
+		t.Errorf("Unexpected converted transaction %+v", transaction)
+	}
+	if account, _ := accounts.GetAccount(2); !account.Balance.Equal(decimal.NewFromInt(1625)) {
+		t.Errorf("Expected 1625 JPY credited, got %s", account.Balance)
+	}
+
+	if _, err := convert(3, "10"); !errors.Is(err, fx.ErrRateUnavailable) {
+		t.Errorf("Expected fx.ErrRateUnavailable, got %v", err)
+	}
+	if _, err := convert(4, "10"); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for an account without currency, got %v", err)
+	}
+	if _, err := convert(9, "10"); !errors.Is(err, ErrDestinationNotFound) {
+		t.Errorf("Expected ErrDestinationNotFound, got %v", err)
+	}
+	if _, err := convert(2, "0.001"); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for an amount converting to zero, got %v", err)
+	}
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "1"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch without convert, got %v", err)
+	}
+}
+
+func TestTransferService_Latency(t *testing.T) {
+	store := memory.NewStore()
+	accounts, transfers := New(store)
+	recorder := sla.NewRecorder(store.Latency(), 500*time.Millisecond)
+	transfers.WithLatency(recorder)
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "100"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0"})
+
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+	transfers.now = func() time.Time { return now }
+	transfers.OnTransferCommitted(func(models.Transaction) { now = now.Add(30 * time.Millisecond) })
+
+	received := now.Add(-200 * time.Millisecond)
+	req := models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "5", ClientID: "key_a", ReceivedAt: received}
+	if _, err := transfers.Transfer(req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Failed transfers are not measured
+	req.Amount = "500"
+	transfers.Transfer(req)
+
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	stats, err := recorder.Stats(context.Background(), "", day, day)
+	if err != nil || len(stats) != 1 || stats[0].ClientID != "key_a" || stats[0].Transfers != 1 ||
+		stats[0].CommitMax != 200*time.Millisecond || stats[0].EmitP95 != 230*time.Millisecond {
+		t.Errorf("Unexpected stats %+v (%v)", stats, err)
+	}
+}
+
+func TestTransferService_Prepare(t *testing.T) {
+	schedule, err := cutoff.Parse("wire=15:00", "UTC", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfers := NewTransferService(memory.NewTransactionRepository(memory.NewStore())).WithCutoffSchedule(schedule)
+	transfers.now = func() time.Time { return time.Date(2024, 3, 11, 16, 0, 0, 0, time.UTC) }
+
+	transfer, err := transfers.Prepare(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "5", TransferType: "wire"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.ValueDate.Format("2006-01-02") != "2024-03-12" || transfer.RoundingPolicy != models.DefaultRoundingPolicy {
+		t.Errorf("Unexpected transfer %+v", transfer)
+	}
+
+	var validation *ValidationError
+	if _, err := transfers.Prepare(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "5", TransferType: "ach"}); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for unknown transfer type, got %v", err)
+	}
+
+	backdated := time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC)
+	transfer, err = transfers.Prepare(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "5", EffectiveAt: &backdated})
+	if err != nil || !transfer.EffectiveAt.Equal(backdated) {
+		t.Errorf("Expected effective time %s, got %+v (%v)", backdated, transfer, err)
+	}
+	future := time.Date(2024, 3, 12, 0, 0, 0, 0, time.UTC)
+	if _, err := transfers.Prepare(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "5", EffectiveAt: &future}); !errors.As(err, &validation) {
+		t.Errorf("Expected validation error for a future effective time, got %v", err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	accounts, transfers := New(memory.NewStore())
+
+	var created []int64
+	var committed []models.Transaction
+	accounts.OnAccountCreated(func(account models.Account) { created = append(created, account.AccountID) })
+	transfers.OnTransferCommitted(func(transaction models.Transaction) { panic("faulty plugin") })
+	transfers.OnTransferCommitted(func(transaction models.Transaction) { committed = append(committed, transaction) })
+
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "100"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0"})
+	if len(created) != 2 || created[0] != 1 || created[1] != 2 {
+		t.Errorf("Expected hooks for accounts 1 and 2 only, got %v", created)
+	}
+
+	// Failed transfers are not reported; a panicking hook neither fails the transfer nor stops
+	// later hooks
+	transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "500"})
+	transaction, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "25"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(committed) != 1 || committed[0].ID != transaction.ID || committed[0].SourceSequence != 1 {
+		t.Errorf("Expected one committed transfer hook, got %+v", committed)
+	}
+}
+
+func TestTransferService_Rules(t *testing.T) {
+	store := memory.NewStore()
+	accounts, transfers := New(store)
+	engine, err := rules.New([]rules.Definition{
+		{Name: "restricted", When: `destination.tags contains "restricted"`, Message: "destination is restricted"},
+		{Name: "acme_cap", When: `amount > 50`, Tenants: []string{"acme"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	transfers.WithRules(engine, store.Accounts())
+
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 1, InitialBalance: "100"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 2, InitialBalance: "0"})
+	accounts.CreateAccount(models.CreateAccountRequest{AccountID: 3, InitialBalance: "0"})
+	accounts.UpdateAccount(3, models.UpdateAccountRequest{Tags: &[]string{"restricted"}}, 0)
+
+	var violation *rules.Violation
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 3, Amount: "1"}); !errors.As(err, &violation) || violation.Rule != "restricted" {
+		t.Errorf("Expected restricted violation, got %v", err)
+	}
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "60", Tenant: "acme"}); !errors.As(err, &violation) || violation.Rule != "acme_cap" {
+		t.Errorf("Expected acme_cap violation, got %v", err)
+	}
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "60"}); err != nil {
+		t.Errorf("Expected the acme rule not to apply to other tenants, got %v", err)
+	}
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 99, DestinationAccountID: 2, Amount: "1"}); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("Expected ErrSourceNotFound, got %v", err)
+	}
+	if _, err := transfers.Transfer(models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 99, Amount: "1"}); !errors.Is(err, ErrDestinationNotFound) {
+		t.Errorf("Expected ErrDestinationNotFound, got %v", err)
+	}
+
+	source, _ := accounts.GetAccount(1)
+	if !source.Balance.Equal(decimal.NewFromInt(40)) {
+		t.Errorf("Expected only the allowed transfer to move money, got balance %s", source.Balance)
+	}
+}
@@ -0,0 +1,511 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/cutoff"
+	"internal-transfers/database"
+	"internal-transfers/fx"
+	"internal-transfers/models"
+	"internal-transfers/preauth"
+	"internal-transfers/rules"
+	"internal-transfers/sla"
+)
+
+// TransferService moves money between accounts and reads transaction history
+type TransferService struct {
+	transactions database.TransactionRepositoryInterface
+	rounding     models.RoundingPolicy
+	cutoffs      *cutoff.Schedule
+	now          func() time.Time
+	hooks        transferHooks
+	rules        *rules.Engine
+	accounts     database.AccountRepositoryInterface
+	latency      *sla.Recorder
+	rates        fx.RateProvider
+	adjustments  database.AdjustmentRepositoryInterface
+	preauth      *preauth.Client
+	currencies   *models.Currencies
+}
+
+// NewTransferService creates a transfer service using the default rounding policy, the built-in
+// currencies and no cut-off schedule (only the default transfer type is accepted and value-dated
+// on the day it is made)
+func NewTransferService(transactions database.TransactionRepositoryInterface) *TransferService {
+	return &TransferService{transactions: transactions, rounding: models.DefaultRoundingPolicy, now: time.Now}
+}
+
+// WithRoundingPolicy sets the policy used to round amounts to the stored scale
+// Returns the service to allow chaining after NewTransferService
+func (s *TransferService) WithRoundingPolicy(policy models.RoundingPolicy) *TransferService {
+	s.rounding = policy
+	return s
+}
+
+// WithCurrencies sets the supported currencies transfers are validated against, by Prepare and by
+// the repository committing them (see models.Transfer.CheckCurrencies); nil keeps the built-in ones
+// Returns the service to allow chaining after NewTransferService
+func (s *TransferService) WithCurrencies(currencies *models.Currencies) *TransferService {
+	s.currencies = currencies
+	return s
+}
+
+// WithCutoffSchedule sets the cut-off schedule used to accept transfer types and assign value dates
+// Returns the service to allow chaining after NewTransferService
+func (s *TransferService) WithCutoffSchedule(schedule *cutoff.Schedule) *TransferService {
+	s.cutoffs = schedule
+	return s
+}
+
+// WithAdjustments sets the repository of manual adjustments, which statements list alongside the
+// transactions; without it statements show transactions only
+// Returns the service to allow chaining after NewTransferService
+func (s *TransferService) WithAdjustments(adjustments database.AdjustmentRepositoryInterface) *TransferService {
+	s.adjustments = adjustments
+	return s
+}
+
+// WithRules sets the validation rules evaluated before each transfer is committed
+// The rules see both accounts as read from accounts just before the transfer; a nil engine
+// disables rule evaluation
+// Returns the service to allow chaining after NewTransferService
+func (s *TransferService) WithRules(engine *rules.Engine, accounts database.AccountRepositoryInterface) *TransferService {
+	s.rules = engine
+	s.accounts = accounts
+	return s
+}
+
+// Rules returns the validation rules, or nil if none are set
+func (s *TransferService) Rules() *rules.Engine {
+	return s.rules
+}
+
+// WithPreauthorization sets the external decision endpoint asked to approve each transfer after
+// the validation rules pass; a nil client approves every transfer
+// Returns the service to allow chaining after NewTransferService
+func (s *TransferService) WithPreauthorization(client *preauth.Client) *TransferService {
+	s.preauth = client
+	return s
+}
+
+// WithFX enables cross-currency transfers (CreateTransactionRequest.Convert) priced by rates
+// Account currencies are read from accounts; a nil provider disables conversion
+// Returns the service to allow chaining after NewTransferService
+func (s *TransferService) WithFX(rates fx.RateProvider, accounts database.AccountRepositoryInterface) *TransferService {
+	s.rates = rates
+	s.accounts = accounts
+	return s
+}
+
+// WithLatency sets the recorder that receives the processing latency of each committed transfer
+// Returns the service to allow chaining after NewTransferService
+func (s *TransferService) WithLatency(recorder *sla.Recorder) *TransferService {
+	s.latency = recorder
+	return s
+}
+
+// Prepare validates a transfer request and converts it into the transfer the repository commits
+// Steps:
+//   - Validates account IDs and amount (see CreateTransactionRequest.Validate)
+//   - Rounds the amount to the stored scale with the configured rounding policy
+//   - Checks the transfer type and assigns its value date from the cut-off schedule
+//   - Checks that a backdated effective time is not in the future
+//   - Checks that a currency is supported and not combined with conversion or a hold capture
+//
+// Returns a *ValidationError if the request is invalid
+func (s *TransferService) Prepare(req models.CreateTransactionRequest) (models.Transfer, error) {
+	amount, err := req.Validate()
+	if err != nil {
+		return models.Transfer{}, invalid(err)
+	}
+
+	rounded := s.rounding.RoundAmount(amount)
+	if !rounded.IsPositive() {
+		return models.Transfer{}, invalid(fmt.Errorf("Amount rounds to zero at %d decimal places", models.AmountScale))
+	}
+
+	transferType := req.TransferType
+	if transferType == "" {
+		transferType = models.DefaultTransferType
+	}
+	if !s.cutoffs.Known(transferType) {
+		return models.Transfer{}, invalid(fmt.Errorf("Unknown transfer type %q (expected one of %s)", transferType, strings.Join(s.cutoffs.Types(), ", ")))
+	}
+
+	var effectiveAt time.Time
+	if req.EffectiveAt != nil {
+		if req.EffectiveAt.After(s.now()) {
+			return models.Transfer{}, invalid(errors.New("Effective time cannot be in the future"))
+		}
+		effectiveAt = *req.EffectiveAt
+	}
+
+	currency := models.NormalizeCurrency(req.Currency)
+	if currency != "" {
+		if _, ok := s.currencies.Scale(currency); !ok {
+			return models.Transfer{}, invalid(fmt.Errorf("Unsupported currency %q", req.Currency))
+		}
+		if req.Convert {
+			return models.Transfer{}, invalid(errors.New("Currency cannot be combined with convert"))
+		}
+		if req.HoldID != 0 {
+			return models.Transfer{}, invalid(errors.New("Hold captures move the primary balance; currency cannot be set"))
+		}
+	}
+
+	return models.Transfer{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               rounded,
+		RoundingPolicy:       s.rounding,
+		Currencies:           s.currencies,
+		TransferType:         transferType,
+		ValueDate:            s.cutoffs.ValueDate(transferType, s.now()),
+		EffectiveAt:          effectiveAt,
+		HoldID:               req.HoldID,
+		Reference:            req.Reference,
+		Memo:                 req.Memo,
+		DependsOn:            req.DependsOn,
+		Currency:             currency,
+	}, nil
+}
+
+// Transfer validates and atomically commits a transfer: either both balances move or neither does
+// OnTransferCommitted hooks run after the commit, before Transfer returns
+// With a latency recorder (see WithLatency), the transfer's timeline from req.ReceivedAt (or the
+// call, if unset) through validation (the pre-authorization callout included), the lock wait and
+// the commit to the end of the hooks is recorded for req.ClientID, with the rules it passed, and
+// returned as the transaction's Timeline; a duplicate reference is recorded as a retry of the
+// transfer that used it
+// Returns the committed transaction with its per-account sequence numbers, or one of:
+//   - *ValidationError: The request is invalid (see Prepare)
+//   - ErrSourceNotFound, ErrDestinationNotFound: An account does not exist
+//   - ErrInsufficientBalance: The source's available balance does not cover the amount
+//   - ErrSourceFrozen, ErrDestinationFrozen: An account is under a compliance hold
+//   - ErrCurrencyMismatch: The accounts hold different currencies and req.Convert is not set
+//   - ErrCurrencyPrecision: The amount has more decimal places than the source currency allows
+//   - ErrAmountBelowMinimum, ErrAmountAboveMaximum: The amount is out of the source currency's
+//     configured bounds
+//   - ErrSourceWallet, ErrDestinationWallet: req.Currency is not an account's own currency and
+//     the account has no wallet in it
+//   - fx.ErrRateUnavailable, fx.ErrStaleRate, fx.ErrRateDeviation (wrapped): No usable exchange
+//     rate for a cross-currency transfer (see convert)
+//   - *rules.Violation: A configured validation rule rejected the transfer (see WithRules)
+//   - *preauth.Decline: The pre-authorization endpoint declined the transfer, or
+//     preauth.ErrUnavailable (wrapped) if it gave no verdict and fails closed (see
+//     WithPreauthorization)
+//   - ErrHoldNotFound, ErrHoldNotActive, ErrHoldExpired, ErrHoldExceeded: req.HoldID names a hold
+//     that cannot be captured for the amount
+//   - ErrAmountLimit, ErrDailyAmountLimit, ErrDailyCountLimit: The transfer exceeds one of the source
+//     account's transfer limits
+//   - ErrDuplicateReference: The source account already sent a transfer with req.Reference
+//   - ErrDependencyNotFound, ErrDependencyPending, ErrDependencyFailed: req.DependsOn names a
+//     transaction that does not exist, has not been executed yet, or failed or was reversed
+//   - ErrTransactionConflict: The transfer kept conflicting with concurrent transfers after the
+//     storage's retries
+//   - any other error: Storage failure or a rule that failed to evaluate
+func (s *TransferService) Transfer(req models.CreateTransactionRequest) (*models.Transaction, error) {
+	received := req.ReceivedAt
+	if received.IsZero() {
+		received = s.now()
+	}
+	transfer, err := s.Prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Convert {
+		if transfer, err = s.convert(transfer); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkRules(req.Tenant, transfer); err != nil {
+		return nil, err
+	}
+	if err := s.preauth.Authorize(context.Background(), preauth.NewRequest(req.Tenant, req.ClientID, transfer)); err != nil {
+		return nil, err
+	}
+	validated := s.now()
+	transaction, err := s.transactions.CreateTransaction(transfer)
+	if err != nil {
+		err = translate(err)
+		if err == ErrDuplicateReference {
+			s.recordRetry(transfer)
+		}
+		return nil, err
+	}
+	committed := s.now()
+
+	s.transferCommitted(*transaction)
+	if s.latency != nil {
+		timeline := models.TransferLatency{
+			TransactionID: transaction.ID,
+			ClientID:      req.ClientID,
+			ReceivedAt:    received,
+			Validate:      validated.Sub(received),
+			LockWait:      transaction.LockWait,
+			Commit:        committed.Sub(received),
+			Emit:          s.now().Sub(received),
+			Rules:         s.rules.Applicable(req.Tenant),
+		}
+		s.latency.Record(timeline)
+		transaction.Timeline = &timeline
+	}
+	return transaction, nil
+}
+
+// recordRetry records a transfer refused for its duplicate reference as a retry of the transfer
+// that used the reference
+func (s *TransferService) recordRetry(transfer models.Transfer) {
+	if s.latency == nil {
+		return
+	}
+	// The search also matches transfers the account received with the same reference
+	matches, err := s.transactions.SearchTransactions(models.TransactionFilter{
+		AccountID: transfer.SourceAccountID,
+		Reference: transfer.Reference,
+	}, 10)
+	if err != nil {
+		return
+	}
+	for _, t := range matches {
+		if t.SourceAccountID == transfer.SourceAccountID {
+			s.latency.RecordRetry(t.ID)
+			return
+		}
+	}
+}
+
+// convert prices a transfer between accounts of different currencies
+// The amount stays the debit in the source currency; the credit is the amount converted at the
+// provider's current rate, rounded to the destination currency with the rounding policy.
+// Transfers between accounts of the same currency are returned unchanged
+func (s *TransferService) convert(transfer models.Transfer) (models.Transfer, error) {
+	if s.rates == nil {
+		return transfer, invalid(errors.New("Currency conversion is not enabled"))
+	}
+	source, destination, err := s.loadAccounts(transfer)
+	if err != nil {
+		return transfer, err
+	}
+	if source.Currency == destination.Currency {
+		return transfer, nil
+	}
+	if source.Currency == "" || destination.Currency == "" {
+		return transfer, invalid(errors.New("Currency conversion requires both accounts to have a currency"))
+	}
+
+	rate, err := s.rates.Rate(context.Background(), source.Currency, destination.Currency)
+	if err != nil {
+		return transfer, err
+	}
+	// The rate is applied at its stored precision so the recorded rate reproduces the credit
+	value := rate.Value.Round(models.FXRateScale)
+	scale, _ := s.currencies.Scale(destination.Currency)
+	credit := s.rounding.Round(transfer.Amount.Mul(value), scale)
+	if !credit.IsPositive() {
+		return transfer, invalid(fmt.Errorf("Amount converts to zero in %s", destination.Currency))
+	}
+
+	transfer.DestinationAmount = credit
+	transfer.FXRate = value
+	transfer.FXRateTimestamp = rate.Timestamp
+	return transfer, nil
+}
+
+// checkRules evaluates the configured rules against the transfer and the current account state
+func (s *TransferService) checkRules(tenant string, transfer models.Transfer) error {
+	if s.rules.Len() == 0 {
+		return nil
+	}
+	source, destination, err := s.loadAccounts(transfer)
+	if err != nil {
+		return err
+	}
+	return s.rules.Evaluate(rules.Input{Tenant: tenant, Transfer: transfer, Source: *source, Destination: *destination})
+}
+
+// loadAccounts reads both accounts of a transfer before it is committed
+// Missing accounts are reported as ErrSourceNotFound or ErrDestinationNotFound, as they would be
+// when the transfer is committed
+func (s *TransferService) loadAccounts(transfer models.Transfer) (source, destination *models.Account, err error) {
+	source, err = s.accounts.GetAccount(transfer.SourceAccountID)
+	if err != nil {
+		if translate(err) == ErrAccountNotFound {
+			return nil, nil, ErrSourceNotFound
+		}
+		return nil, nil, err
+	}
+	destination, err = s.accounts.GetAccount(transfer.DestinationAccountID)
+	if err != nil {
+		if translate(err) == ErrAccountNotFound {
+			return nil, nil, ErrDestinationNotFound
+		}
+		return nil, nil, err
+	}
+	return source, destination, nil
+}
+
+// Transactions returns up to limit of the account's transactions, newest first
+func (s *TransferService) Transactions(accountID int64, limit int) ([]models.Transaction, error) {
+	return s.transactions.ListTransactions(accountID, limit)
+}
+
+// Search returns up to limit of the account's transactions matching the filter, in its order
+func (s *TransferService) Search(filter models.TransactionFilter, limit int) ([]models.Transaction, error) {
+	return s.transactions.SearchTransactions(filter, limit)
+}
+
+// SearchAll returns up to limit of the transactions of any account matching the search, newest
+// first
+func (s *TransferService) SearchAll(search models.TransactionSearch, limit int) ([]models.Transaction, error) {
+	return s.transactions.SearchAllTransactions(search, limit)
+}
+
+// BalanceAsOf reconstructs an account's balance from the ledger's two time axes: recordedAt asks
+// what the balance was believed to be at that time (transaction time), effectiveAt what it was
+// effective at that time (business time). Pass the current time for an axis to ignore it
+// Returns ErrAccountNotFound if the account did not exist by recordedAt, or a storage error
+func (s *TransferService) BalanceAsOf(accountID int64, recordedAt, effectiveAt time.Time) (decimal.Decimal, error) {
+	balance, err := s.transactions.BalanceAsOf(accountID, recordedAt, effectiveAt)
+	if err != nil {
+		return decimal.Zero, translate(err)
+	}
+	return balance, nil
+}
+
+// Changes returns up to limit of the account's ledger movements after sequence number sinceSeq,
+// in sequence order, and whether more movements follow them
+func (s *TransferService) Changes(accountID, sinceSeq int64, limit int) ([]models.LedgerChange, bool, error) {
+	transactions, err := s.transactions.ListChanges(accountID, sinceSeq, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(transactions) > limit
+	if hasMore {
+		transactions = transactions[:limit]
+	}
+	changes := make([]models.LedgerChange, 0, len(transactions))
+	for _, t := range transactions {
+		changes = append(changes, models.NewLedgerChange(t, accountID))
+	}
+	return changes, hasMore, nil
+}
+
+// maxStatementLines caps the transactions on one statement; longer periods must be split
+const maxStatementLines = 10000
+
+// statementPageSize is how many transactions a statement reads per query
+const statementPageSize = 1000
+
+// Statement builds the account's statement for the effective period [from, to), as the ledger
+// stands now: the opening balance, the period's transactions and manual adjustments in effective
+// order with running balances, and the closing balance, all of the primary balance
+// Returns a *ValidationError if the period is empty or has more than 10000 transactions, or a
+// storage error
+func (s *TransferService) Statement(account models.Account, from, to time.Time) (*models.Statement, error) {
+	if !from.Before(to) {
+		return nil, invalid(fmt.Errorf("from must be before to"))
+	}
+	scale, _ := s.currencies.Scale(account.Currency)
+	statement := &models.Statement{
+		AccountID:    account.AccountID,
+		Currency:     account.Currency,
+		Scale:        scale,
+		From:         from,
+		To:           to,
+		GeneratedAt:  s.now().UTC(),
+		TotalDebits:  decimal.Zero,
+		TotalCredits: decimal.Zero,
+		Lines:        []models.StatementLine{},
+	}
+
+	filter := models.TransactionFilter{AccountID: account.AccountID, EffectiveFrom: from, EffectiveTo: to}
+	var transactions []models.Transaction
+	for {
+		page, err := s.transactions.SearchTransactions(filter, statementPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range page {
+			// Transfers committed while the statement is read belong to the next one
+			if !t.CreatedAt.After(statement.GeneratedAt) {
+				transactions = append(transactions, t)
+			}
+		}
+		if len(transactions) > maxStatementLines {
+			return nil, invalid(fmt.Errorf("the period has more than %d transactions; request a shorter period", maxStatementLines))
+		}
+		if len(page) < statementPageSize {
+			break
+		}
+		filter.After = page[len(page)-1].Position()
+	}
+	lines := make([]models.StatementLine, 0, len(transactions))
+	for _, t := range transactions {
+		// Movements of the account's wallets are in other currencies
+		if t.Wallet(account.AccountID) == "" {
+			lines = append(lines, models.NewStatementLine(t, account.AccountID))
+		}
+	}
+
+	// Manual adjustments are lines of their own, effective when they were recorded
+	if s.adjustments != nil {
+		adjustments, err := s.adjustments.ListAdjustments(context.Background(), account.AccountID, from, to, maxStatementLines+1)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range adjustments {
+			if !a.CreatedAt.After(statement.GeneratedAt) {
+				lines = append(lines, models.NewAdjustmentStatementLine(a))
+			}
+		}
+		if len(lines) > maxStatementLines {
+			return nil, invalid(fmt.Errorf("the period has more than %d transactions; request a shorter period", maxStatementLines))
+		}
+	}
+
+	// Lines are in effective order; at the same time transactions come first, each kind by ID
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		switch {
+		case !a.EffectiveAt.Equal(b.EffectiveAt):
+			return a.EffectiveAt.Before(b.EffectiveAt)
+		case (a.AdjustmentID == 0) != (b.AdjustmentID == 0):
+			return a.AdjustmentID == 0
+		}
+		return a.TransactionID+a.AdjustmentID < b.TransactionID+b.AdjustmentID
+	})
+
+	// BalanceAsOf counts the lines effective at from itself, which open the period instead
+	opening, err := s.BalanceAsOf(account.AccountID, statement.GeneratedAt, from)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if line.EffectiveAt.Equal(from) {
+			opening = opening.Sub(line.Amount)
+		}
+	}
+
+	statement.OpeningBalance = opening
+	balance := opening
+	for _, line := range lines {
+		balance = balance.Add(line.Amount)
+		line.Balance = balance
+		if line.Amount.IsNegative() {
+			statement.TotalDebits = statement.TotalDebits.Sub(line.Amount)
+		} else {
+			statement.TotalCredits = statement.TotalCredits.Add(line.Amount)
+		}
+		statement.Lines = append(statement.Lines, line)
+	}
+	statement.ClosingBalance = balance
+	return statement, nil
+}
@@ -0,0 +1,227 @@
+package settlement
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"internal-transfers/audit"
+	"internal-transfers/cutoff"
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// ErrUnknownPartner is returned when an acknowledgment file names a partner that is not configured
+var ErrUnknownPartner = errors.New("unknown settlement partner")
+
+// Acknowledgment statuses accepted in partner files, mapped to the outcome they report
+var ackStatuses = map[string]string{
+	"settled":  models.SettlementSettled,
+	"accepted": models.SettlementSettled,
+	"ack":      models.SettlementSettled,
+	"returned": models.SettlementReturned,
+	"rejected": models.SettlementReturned,
+	"ret":      models.SettlementReturned,
+}
+
+// AckReport summarises one ingested acknowledgment/return file
+type AckReport struct {
+	PartnerID string         `json:"partner_id"`
+	Lines     int            `json:"lines"`
+	Settled   []int64        `json:"settled"`
+	Returned  []ReturnedItem `json:"returned"`
+	Unmatched []Unmatched    `json:"unmatched"`
+}
+
+// ReturnedItem is a transfer the partner returned and the return transaction that reversed it
+type ReturnedItem struct {
+	TransactionID       int64  `json:"transaction_id"`
+	ReturnTransactionID int64  `json:"return_transaction_id"`
+	Reason              string `json:"reason,omitempty"`
+}
+
+// Unmatched is a file line that could not be applied, with the reason
+type Unmatched struct {
+	Line    int    `json:"line"`
+	Content string `json:"content"`
+	Reason  string `json:"reason"`
+}
+
+// Ingester applies partner acknowledgment/return files to exported transfers
+type Ingester struct {
+	partners map[string]Partner
+	repo     database.SettlementRepositoryInterface
+	schedule *cutoff.Schedule
+	audit    *audit.Recorder
+	now      func() time.Time
+}
+
+// NewIngester creates an ingester for the configured partners
+// schedule assigns the value date of return transactions
+func NewIngester(partners []Partner, repo database.SettlementRepositoryInterface, schedule *cutoff.Schedule) *Ingester {
+	byID := make(map[string]Partner, len(partners))
+	for _, p := range partners {
+		byID[p.ID] = p
+	}
+	return &Ingester{partners: byID, repo: repo, schedule: schedule, now: time.Now}
+}
+
+// WithAudit appends the reversals of returned transfers, and the return transactions booking
+// them, to the audit log as made by "settlement"
+// Returns the ingester to allow chaining
+func (i *Ingester) WithAudit(recorder *audit.Recorder) *Ingester {
+	i.audit = recorder
+	return i
+}
+
+// Ingest reads a partner's acknowledgment/return file and applies every line
+// File format: CSV lines of transaction_id,status[,reason]; an optional header line starting with
+// "transaction_id" and blank lines are skipped. Status is settled/accepted/ack or
+// returned/rejected/ret (case-insensitive)
+// A line matches when the transaction exists, debits or credits one of the partner's accounts, and
+// the partner's settlement file for its value date was generated. Settled lines mark the transfer
+// settled; returned lines book a return transaction reversing it. Lines are applied independently,
+// so re-ingesting a file is safe: already settled transfers stay settled and already returned
+// transfers are reported as unmatched instead of being returned twice
+// Returns ErrUnknownPartner, a read error, or the report
+func (i *Ingester) Ingest(ctx context.Context, partnerID string, file io.Reader) (*AckReport, error) {
+	partner, ok := i.partners[partnerID]
+	if !ok {
+		return nil, ErrUnknownPartner
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	report := &AckReport{PartnerID: partnerID, Settled: []int64{}, Returned: []ReturnedItem{}, Unmatched: []Unmatched{}}
+	exported := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read acknowledgment file: %w", err)
+			}
+			report.Lines++
+			report.Unmatched = append(report.Unmatched, Unmatched{Line: parseErr.StartLine, Reason: "malformed line"})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "") {
+			continue
+		}
+		if report.Lines == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "transaction_id") {
+			continue
+		}
+		report.Lines++
+
+		if reason := i.apply(ctx, partner, record, exported, report); reason != "" {
+			report.Unmatched = append(report.Unmatched, Unmatched{Line: line, Content: strings.Join(record, ","), Reason: reason})
+		}
+	}
+	return report, nil
+}
+
+// apply matches one line and records the outcome in the report
+// Returns the reason the line is unmatched, or "" once it was applied
+func (i *Ingester) apply(ctx context.Context, partner Partner, record []string, exported map[string]bool, report *AckReport) string {
+	if len(record) < 2 {
+		return "expected transaction_id,status[,reason]"
+	}
+	transactionID, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+	if err != nil {
+		return "invalid transaction ID"
+	}
+	status, ok := ackStatuses[strings.ToLower(strings.TrimSpace(record[1]))]
+	if !ok {
+		return fmt.Sprintf("unknown status %q", strings.TrimSpace(record[1]))
+	}
+	var reason string
+	if len(record) > 2 {
+		reason = strings.TrimSpace(record[2])
+	}
+
+	transaction, err := i.repo.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return err.Error()
+	}
+	if !partner.involves(*transaction) {
+		return "transaction does not involve the partner's accounts"
+	}
+	wasExported, err := i.exported(ctx, partner.ID, transaction.ValueDate, exported)
+	if err != nil {
+		return err.Error()
+	}
+	if !wasExported {
+		return "transaction was not exported to the partner"
+	}
+
+	if status == models.SettlementSettled {
+		if err := i.repo.SettleTransaction(ctx, transactionID); err != nil {
+			return err.Error()
+		}
+		report.Settled = append(report.Settled, transactionID)
+		return ""
+	}
+
+	returned, err := i.repo.ReturnTransaction(ctx, transactionID, i.schedule.ValueDate(models.ReturnTransferType, i.now()))
+	if err != nil {
+		return err.Error()
+	}
+	report.Returned = append(report.Returned, ReturnedItem{TransactionID: transactionID, ReturnTransactionID: returned.ID, Reason: reason})
+	i.recordReturn(ctx, transaction, returned)
+	return ""
+}
+
+// recordReturn appends a returned transfer's reversal and its return transaction to the audit log
+func (i *Ingester) recordReturn(ctx context.Context, original, returned *models.Transaction) {
+	if i.audit == nil {
+		return
+	}
+	after := original
+	if reversed, err := i.repo.GetTransaction(ctx, original.ID); err == nil {
+		after = reversed
+	}
+	i.audit.Record(ctx, audit.TransferEvent(models.AuditActorSettlement, returned))
+	i.audit.Record(ctx, models.AuditEvent{
+		Actor:         models.AuditActorSettlement,
+		Action:        models.AuditTransactionReversed,
+		AccountIDs:    []int64{original.SourceAccountID, original.DestinationAccountID},
+		TransactionID: original.ID,
+		Before:        audit.Snapshot(models.NewTransactionResponse(*original)),
+		After:         audit.Snapshot(models.NewTransactionResponse(*after)),
+	})
+}
+
+// exported reports whether the partner's file for the value date was generated, caching per file
+func (i *Ingester) exported(ctx context.Context, partnerID string, valueDate time.Time, cache map[string]bool) (bool, error) {
+	key := valueDate.Format("2006-01-02")
+	if generated, ok := cache[key]; ok {
+		return generated, nil
+	}
+	files, err := i.repo.ListSettlementFiles(ctx, valueDate, partnerID, models.SettlementFileKey{}, 1)
+	if err != nil {
+		return false, err
+	}
+	cache[key] = len(files) == 1 && files[0].Status == models.SettlementFileGenerated
+	return cache[key], nil
+}
+
+// involves reports whether the transaction debits or credits one of the partner's accounts
+func (p Partner) involves(t models.Transaction) bool {
+	for _, id := range p.Accounts {
+		if id == t.SourceAccountID || id == t.DestinationAccountID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package settlement
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"internal-transfers/cutoff"
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// metrics exposes generation counters under "settlement" at /debug/vars
+var metrics = expvar.NewMap("settlement")
+
+// Target receives generated settlement files
+type Target interface {
+	Upload(ctx context.Context, name string, data []byte) error
+}
+
+// DirectoryTarget uploads files into a local (or mounted) directory
+// Files are written to a temporary name and renamed, so readers never see partial files
+type DirectoryTarget struct {
+	Dir string
+}
+
+// Upload writes the file atomically, replacing any earlier file with the same name
+func (d DirectoryTarget) Upload(ctx context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(d.Dir, "."+name+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(d.Dir, name)); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", name, err)
+	}
+	return nil
+}
+
+// Generator produces, uploads and records partner settlement files
+type Generator struct {
+	partners []Partner
+	repo     database.SettlementRepositoryInterface
+	target   Target
+	schedule *cutoff.Schedule
+	now      func() time.Time
+}
+
+// NewGenerator creates a generator
+// Parameters:
+//   - partners: Validated partner configuration (see Config.Validate)
+//   - repo: Source of value-dated transactions and store of generation records
+//   - target: Where files are uploaded
+//   - schedule: Cut-off schedule whose time zone decides when a business day is closed
+func NewGenerator(partners []Partner, repo database.SettlementRepositoryInterface, target Target, schedule *cutoff.Schedule) *Generator {
+	return &Generator{partners: partners, repo: repo, target: target, schedule: schedule, now: time.Now}
+}
+
+// Generate produces every partner's file for the business date and records the outcomes
+// Partners that already have a generated file for the date are skipped unless force is set
+// A failure for one partner is recorded and does not stop the others
+// Returns the records written; the error is only set if a record could not be stored
+func (g *Generator) Generate(ctx context.Context, date time.Time, force bool) ([]models.SettlementFile, error) {
+	existing := make(map[string]bool)
+	if !force {
+		files, err := g.repo.ListSettlementFiles(ctx, date, "", models.SettlementFileKey{}, len(g.partners))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if f.Status == models.SettlementFileGenerated {
+				existing[f.PartnerID] = true
+			}
+		}
+	}
+
+	var pending []Partner
+	for _, partner := range g.partners {
+		if !existing[partner.ID] {
+			pending = append(pending, partner)
+		}
+	}
+	reads := g.read(ctx, pending, date)
+
+	var results []models.SettlementFile
+	for _, partner := range pending {
+		record := g.generate(ctx, partner, date, reads[partner.ID])
+		if record.Status == models.SettlementFileGenerated {
+			metrics.Add("files_generated", 1)
+		} else {
+			metrics.Add("files_failed", 1)
+			slog.Error("Settlement file failed", "file", record.FileName, "error", record.Error)
+		}
+
+		saved, err := g.repo.SaveSettlementFile(ctx, record)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, *saved)
+	}
+	return results, nil
+}
+
+// partnerRead is a partner's transactions for a file, or the error that prevented reading them
+type partnerRead struct {
+	transactions []models.Transaction
+	err          error
+}
+
+// read reads every partner's transactions within one snapshot of the ledger, so the files of a
+// business date agree with each other even if transfers are booked while they are generated
+// Rendering and uploading happen after the snapshot is released
+func (g *Generator) read(ctx context.Context, partners []Partner, date time.Time) map[string]partnerRead {
+	reads := make(map[string]partnerRead, len(partners))
+	if len(partners) == 0 {
+		return reads
+	}
+	err := g.repo.Snapshot(ctx, func(snapshot database.SettlementSnapshot) error {
+		for _, partner := range partners {
+			transactions, err := snapshot.TransactionsByValueDate(ctx, date, partner.Accounts)
+			reads[partner.ID] = partnerRead{transactions: transactions, err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, partner := range partners {
+			reads[partner.ID] = partnerRead{err: err}
+		}
+	}
+	return reads
+}
+
+// generate renders and uploads one partner's file, returning the record describing the outcome
+func (g *Generator) generate(ctx context.Context, partner Partner, date time.Time, read partnerRead) models.SettlementFile {
+	record := models.SettlementFile{
+		PartnerID:    partner.ID,
+		BusinessDate: date,
+		FileName:     partner.FileName(date),
+		Format:       partner.Layout.Format,
+		Status:       models.SettlementFileFailed,
+	}
+
+	if read.err != nil {
+		record.Error = read.err.Error()
+		return record
+	}
+	data, count, err := partner.Render(read.transactions)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	if err := g.target.Upload(ctx, record.FileName, data); err != nil {
+		record.Error = err.Error()
+		return record
+	}
+
+	record.Status = models.SettlementFileGenerated
+	record.TransferCount = count
+	return record
+}
+
+// Run generates files for the most recently closed business day until ctx is cancelled
+// A day is closed once it has ended in the cut-off time zone, since no further transfers can be
+// value-dated on it. Checks happen at start-up and then every interval; failed files are retried
+func (g *Generator) Run(ctx context.Context, interval time.Duration) {
+	for {
+		closed := g.schedule.Today(g.now()).AddDate(0, 0, -1)
+		if _, err := g.Generate(ctx, closed, false); err != nil {
+			slog.Error("Settlement job error", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
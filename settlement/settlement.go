@@ -0,0 +1,345 @@
+// Package settlement generates per-partner settlement files of each business day's transfers
+// A partner owns a set of accounts and receives one file per business date listing every transfer
+// value-dated that day which debits or credits one of its accounts, one line per leg. The file
+// layout (CSV or fixed-width, column selection and widths) is configured per partner; files are
+// uploaded to the export target and each generation outcome is recorded for the status API
+package settlement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"internal-transfers/models"
+)
+
+// Layout formats
+const (
+	FormatCSV        = "csv"
+	FormatFixedWidth = "fixed_width"
+)
+
+// Fields available as layout columns; direction and amounts are from the partner account's side
+const (
+	FieldTransactionID      = "transaction_id"
+	FieldValueDate          = "value_date"
+	FieldCreatedAt          = "created_at"
+	FieldTransferType       = "transfer_type"
+	FieldAccountID          = "account_id"
+	FieldCounterpartyID     = "counterparty_account_id"
+	FieldDirection          = "direction"
+	FieldAmount             = "amount"
+	FieldSignedAmount       = "signed_amount"
+	FieldSourceAccountID    = "source_account_id"
+	FieldDestinationAccount = "destination_account_id"
+)
+
+// defaultColumns is the layout used when a partner does not list columns
+var defaultColumns = []Column{
+	{Field: FieldTransactionID}, {Field: FieldValueDate}, {Field: FieldAccountID},
+	{Field: FieldCounterpartyID}, {Field: FieldDirection}, {Field: FieldAmount}, {Field: FieldTransferType},
+}
+
+// Column is one field of a settlement file line
+// Width, Align ("left" or "right") and Pad (a single character, default space) apply to
+// fixed-width layouts only; values longer than Width are an error rather than being truncated
+type Column struct {
+	Field string `json:"field"`
+	Width int    `json:"width,omitempty"`
+	Align string `json:"align,omitempty"`
+	Pad   string `json:"pad,omitempty"`
+}
+
+// Layout describes how a partner's file is written
+type Layout struct {
+	Format    string   `json:"format"`
+	Delimiter string   `json:"delimiter,omitempty"`
+	Header    bool     `json:"header,omitempty"`
+	Columns   []Column `json:"columns,omitempty"`
+}
+
+// Partner is a settlement counterparty and the accounts it settles
+type Partner struct {
+	ID       string  `json:"id"`
+	Accounts []int64 `json:"accounts"`
+	Layout   Layout  `json:"layout"`
+}
+
+// Config holds the settlement job configuration
+type Config struct {
+	Partners  []Partner     `json:"partners"`
+	ExportDir string        `json:"-"`
+	Interval  time.Duration `json:"-"`
+}
+
+// DefaultInterval is how often the job checks for closed business days without files
+const DefaultInterval = time.Hour
+
+// LoadConfig reads the settlement configuration
+// Environment variables used (with defaults):
+//   - SETTLEMENT_PARTNERS_FILE (unset): JSON file listing partners; the job is disabled when unset
+//   - SETTLEMENT_EXPORT_DIR (unset): Directory files are written to; required when partners are set
+//   - SETTLEMENT_INTERVAL (1h): How often the job looks for days still missing a file
+//
+// Returns an error if the partners file cannot be read or is invalid
+func LoadConfig() (Config, error) {
+	config := Config{ExportDir: os.Getenv("SETTLEMENT_EXPORT_DIR"), Interval: DefaultInterval}
+
+	if value := os.Getenv("SETTLEMENT_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return Config{}, fmt.Errorf("invalid SETTLEMENT_INTERVAL %q", value)
+		}
+		config.Interval = interval
+	}
+
+	path := os.Getenv("SETTLEMENT_PARTNERS_FILE")
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read settlement partners: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("invalid settlement partners file %s: %w", path, err)
+	}
+	if config.ExportDir == "" {
+		return Config{}, fmt.Errorf("SETTLEMENT_EXPORT_DIR is required when SETTLEMENT_PARTNERS_FILE is set")
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Enabled reports whether any partner is configured
+func (c Config) Enabled() bool {
+	return len(c.Partners) > 0
+}
+
+// Validate checks partner IDs are unique and usable in file names and that layouts are well formed
+func (c Config) Validate() error {
+	seen := make(map[string]bool)
+	for _, p := range c.Partners {
+		if p.ID == "" || strings.ContainsAny(p.ID, `/\. `) {
+			return fmt.Errorf("invalid settlement partner ID %q", p.ID)
+		}
+		if seen[p.ID] {
+			return fmt.Errorf("duplicate settlement partner %q", p.ID)
+		}
+		seen[p.ID] = true
+		if len(p.Accounts) == 0 {
+			return fmt.Errorf("settlement partner %s has no accounts", p.ID)
+		}
+		if err := p.Layout.validate(); err != nil {
+			return fmt.Errorf("settlement partner %s: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the format, delimiter and columns of a layout
+func (l Layout) validate() error {
+	switch l.Format {
+	case FormatCSV:
+		if len([]rune(l.Delimiter)) > 1 {
+			return fmt.Errorf("CSV delimiter must be a single character")
+		}
+	case FormatFixedWidth:
+		if len(l.Columns) == 0 {
+			return fmt.Errorf("fixed-width layout needs columns with widths")
+		}
+	default:
+		return fmt.Errorf("unknown layout format %q (expected %s or %s)", l.Format, FormatCSV, FormatFixedWidth)
+	}
+
+	for _, c := range l.Columns {
+		if _, err := fieldValue(c.Field, line{}); err != nil {
+			return err
+		}
+		if l.Format != FormatFixedWidth {
+			continue
+		}
+		if c.Width <= 0 {
+			return fmt.Errorf("column %s needs a positive width", c.Field)
+		}
+		if c.Align != "" && c.Align != "left" && c.Align != "right" {
+			return fmt.Errorf("column %s has unknown alignment %q", c.Field, c.Align)
+		}
+		if len([]rune(c.Pad)) > 1 {
+			return fmt.Errorf("column %s pad must be a single character", c.Field)
+		}
+	}
+	return nil
+}
+
+// FileName returns the name of the partner's file for a business date, e.g. acme_20240131.csv
+func (p Partner) FileName(date time.Time) string {
+	extension := "csv"
+	if p.Layout.Format == FormatFixedWidth {
+		extension = "txt"
+	}
+	return fmt.Sprintf("%s_%s.%s", p.ID, date.Format("20060102"), extension)
+}
+
+// line is one leg of a transfer as seen from a partner account
+type line struct {
+	transaction  models.Transaction
+	accountID    int64
+	counterparty int64
+	debit        bool
+}
+
+// lines splits transactions into the legs that touch the partner's accounts
+// A transfer between two of the partner's own accounts yields both legs
+func (p Partner) lines(transactions []models.Transaction) []line {
+	accounts := make(map[int64]bool, len(p.Accounts))
+	for _, id := range p.Accounts {
+		accounts[id] = true
+	}
+
+	var lines []line
+	for _, t := range transactions {
+		if accounts[t.SourceAccountID] {
+			lines = append(lines, line{transaction: t, accountID: t.SourceAccountID, counterparty: t.DestinationAccountID, debit: true})
+		}
+		if accounts[t.DestinationAccountID] {
+			lines = append(lines, line{transaction: t, accountID: t.DestinationAccountID, counterparty: t.SourceAccountID})
+		}
+	}
+	return lines
+}
+
+// fieldValue renders one field of a line; unknown fields are an error
+func fieldValue(field string, l line) (string, error) {
+	t := l.transaction
+	switch field {
+	case FieldTransactionID:
+		return strconv.FormatInt(t.ID, 10), nil
+	case FieldValueDate:
+		return t.ValueDate.Format("2006-01-02"), nil
+	case FieldCreatedAt:
+		return t.CreatedAt.UTC().Format(time.RFC3339), nil
+	case FieldTransferType:
+		return t.TransferType, nil
+	case FieldAccountID:
+		return strconv.FormatInt(l.accountID, 10), nil
+	case FieldCounterpartyID:
+		return strconv.FormatInt(l.counterparty, 10), nil
+	case FieldDirection:
+		if l.debit {
+			return "D", nil
+		}
+		return "C", nil
+	case FieldAmount:
+		return t.Amount.StringFixed(models.AmountScale), nil
+	case FieldSignedAmount:
+		if l.debit {
+			return t.Amount.Neg().StringFixed(models.AmountScale), nil
+		}
+		return t.Amount.StringFixed(models.AmountScale), nil
+	case FieldSourceAccountID:
+		return strconv.FormatInt(t.SourceAccountID, 10), nil
+	case FieldDestinationAccount:
+		return strconv.FormatInt(t.DestinationAccountID, 10), nil
+	default:
+		return "", fmt.Errorf("unknown settlement field %q", field)
+	}
+}
+
+// Render writes the partner's file for the given transactions
+// Returns the file contents and the number of lines (transfer legs) written
+func (p Partner) Render(transactions []models.Transaction) ([]byte, int, error) {
+	columns := p.Layout.Columns
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+	lines := p.lines(transactions)
+
+	var buf bytes.Buffer
+	var err error
+	if p.Layout.Format == FormatFixedWidth {
+		err = renderFixedWidth(&buf, p.Layout.Header, columns, lines)
+	} else {
+		err = renderCSV(&buf, p.Layout, columns, lines)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), len(lines), nil
+}
+
+// renderCSV writes lines as delimited records
+func renderCSV(buf *bytes.Buffer, layout Layout, columns []Column, lines []line) error {
+	w := csv.NewWriter(buf)
+	if layout.Delimiter != "" {
+		w.Comma = []rune(layout.Delimiter)[0]
+	}
+
+	record := make([]string, len(columns))
+	if layout.Header {
+		for i, c := range columns {
+			record[i] = c.Field
+		}
+		w.Write(record)
+	}
+	for _, l := range lines {
+		for i, c := range columns {
+			value, err := fieldValue(c.Field, l)
+			if err != nil {
+				return err
+			}
+			record[i] = value
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// renderFixedWidth writes lines as padded, newline terminated records
+func renderFixedWidth(buf *bytes.Buffer, header bool, columns []Column, lines []line) error {
+	if header {
+		for _, c := range columns {
+			name := c.Field
+			if len(name) > c.Width {
+				name = name[:c.Width]
+			}
+			buf.WriteString(pad(name, Column{Width: c.Width}))
+		}
+		buf.WriteByte('\n')
+	}
+	for _, l := range lines {
+		for _, c := range columns {
+			value, err := fieldValue(c.Field, l)
+			if err != nil {
+				return err
+			}
+			if len(value) > c.Width {
+				return fmt.Errorf("transaction %d: %s value %q exceeds width %d", l.transaction.ID, c.Field, value, c.Width)
+			}
+			buf.WriteString(pad(value, c))
+		}
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+// pad fills value to the column width with its pad character and alignment
+func pad(value string, c Column) string {
+	fill := c.Pad
+	if fill == "" {
+		fill = " "
+	}
+	padding := strings.Repeat(fill, c.Width-len(value))
+	if c.Align == "right" {
+		return padding + value
+	}
+	return value + padding
+}
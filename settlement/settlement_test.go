@@ -0,0 +1,249 @@
+package settlement
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/cutoff"
+	"internal-transfers/database"
+	"internal-transfers/memory"
+	"internal-transfers/models"
+)
+
+var businessDate = time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+func transaction(id, source, destination int64, amount string) models.Transaction {
+	return models.Transaction{
+		ID: id, SourceAccountID: source, DestinationAccountID: destination,
+		Amount: decimal.RequireFromString(amount), TransferType: "internal", ValueDate: businessDate,
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	csvLayout := Layout{Format: FormatCSV}
+	testCases := []struct {
+		name     string
+		partners []Partner
+		wantErr  bool
+	}{
+		{"valid", []Partner{{ID: "acme", Accounts: []int64{1}, Layout: csvLayout}}, false},
+		{"path in ID", []Partner{{ID: "../acme", Accounts: []int64{1}, Layout: csvLayout}}, true},
+		{"duplicate", []Partner{{ID: "acme", Accounts: []int64{1}, Layout: csvLayout}, {ID: "acme", Accounts: []int64{2}, Layout: csvLayout}}, true},
+		{"no accounts", []Partner{{ID: "acme", Layout: csvLayout}}, true},
+		{"unknown format", []Partner{{ID: "acme", Accounts: []int64{1}, Layout: Layout{Format: "xlsx"}}}, true},
+		{"unknown field", []Partner{{ID: "acme", Accounts: []int64{1}, Layout: Layout{Format: FormatCSV, Columns: []Column{{Field: "memo"}}}}}, true},
+		{"fixed width without widths", []Partner{{ID: "acme", Accounts: []int64{1}, Layout: Layout{Format: FormatFixedWidth, Columns: []Column{{Field: FieldAmount}}}}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Config{Partners: tc.partners}.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Expected error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPartner_RenderCSV(t *testing.T) {
+	partner := Partner{ID: "acme", Accounts: []int64{1, 2}, Layout: Layout{
+		Format: FormatCSV, Delimiter: ";", Header: true,
+		Columns: []Column{{Field: FieldTransactionID}, {Field: FieldAccountID}, {Field: FieldDirection}, {Field: FieldSignedAmount}},
+	}}
+
+	data, count, err := partner.Render([]models.Transaction{
+		transaction(10, 1, 9, "5.5"),
+		transaction(11, 1, 2, "1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "transaction_id;account_id;direction;signed_amount\n" +
+		"10;1;D;-5.50000\n" +
+		"11;1;D;-1.00000\n" +
+		"11;2;C;1.00000\n"
+	if string(data) != want || count != 3 {
+		t.Errorf("Unexpected file (%d lines):\n%s", count, data)
+	}
+}
+
+func TestPartner_RenderFixedWidth(t *testing.T) {
+	partner := Partner{ID: "acme", Accounts: []int64{9}, Layout: Layout{
+		Format:  FormatFixedWidth,
+		Columns: []Column{{Field: FieldTransactionID, Width: 6, Align: "right", Pad: "0"}, {Field: FieldDirection, Width: 2}, {Field: FieldAmount, Width: 10, Align: "right"}},
+	}}
+
+	data, _, err := partner.Render([]models.Transaction{transaction(10, 1, 9, "5.5")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "000010C    5.50000\n"; string(data) != want {
+		t.Errorf("Expected %q, got %q", want, data)
+	}
+
+	// Values are never truncated
+	if _, _, err := partner.Render([]models.Transaction{transaction(10, 1, 9, "123456")}); err == nil {
+		t.Error("Expected error for value exceeding its width")
+	}
+}
+
+type failingTarget struct{}
+
+func (failingTarget) Upload(ctx context.Context, name string, data []byte) error {
+	return errors.New("export target unreachable")
+}
+
+// failingSnapshots is a settlement repository whose ledger snapshots cannot be taken
+type failingSnapshots struct {
+	*memory.SettlementRepository
+}
+
+func (failingSnapshots) Snapshot(ctx context.Context, fn func(database.SettlementSnapshot) error) error {
+	return errors.New("snapshot unavailable")
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	store := memory.NewStore()
+	accounts := memory.NewAccountRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	memory.NewTransactionRepository(store).CreateTransaction(models.Transfer{
+		SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(25), ValueDate: businessDate,
+	})
+	repo := memory.NewSettlementRepository(store)
+
+	dir := t.TempDir()
+	partners := []Partner{
+		{ID: "acme", Accounts: []int64{1}, Layout: Layout{Format: FormatCSV}},
+		{ID: "globex", Accounts: []int64{3}, Layout: Layout{Format: FormatCSV}},
+	}
+	generator := NewGenerator(partners, repo, DirectoryTarget{Dir: dir}, nil)
+
+	files, err := generator.Generate(context.Background(), businessDate, false)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected two records, got %v (%v)", files, err)
+	}
+	if files[0].Status != models.SettlementFileGenerated || files[0].TransferCount != 1 {
+		t.Errorf("Unexpected acme record %+v", files[0])
+	}
+	if files[1].TransferCount != 0 {
+		t.Errorf("Expected empty globex file, got %+v", files[1])
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "acme_20240131.csv"))
+	if err != nil || !strings.HasPrefix(string(data), "1,2024-01-31,1,2,D,25.00000,internal") {
+		t.Errorf("Unexpected file contents %q (%v)", data, err)
+	}
+
+	// Generated partners are skipped on the next run
+	if files, _ := generator.Generate(context.Background(), businessDate, false); len(files) != 0 {
+		t.Errorf("Expected no regeneration, got %v", files)
+	}
+
+	// Failures are recorded and replace the earlier record when forced
+	generator.target = failingTarget{}
+	files, _ = generator.Generate(context.Background(), businessDate, true)
+	if len(files) != 2 || files[0].Status != models.SettlementFileFailed || files[0].Error == "" {
+		t.Errorf("Expected failed records, got %+v", files)
+	}
+	if listed, _ := repo.ListSettlementFiles(context.Background(), businessDate, "acme", models.SettlementFileKey{}, 10); len(listed) != 1 || listed[0].Status != models.SettlementFileFailed {
+		t.Errorf("Expected a single failed acme record, got %+v", listed)
+	}
+
+	// A snapshot that cannot be taken fails every partner's file
+	generator = NewGenerator(partners, failingSnapshots{repo}, DirectoryTarget{Dir: dir}, nil)
+	files, err = generator.Generate(context.Background(), businessDate, true)
+	if err != nil || len(files) != 2 || files[0].Error != "snapshot unavailable" || files[1].Error != "snapshot unavailable" {
+		t.Errorf("Expected both files to fail on the snapshot, got %+v (%v)", files, err)
+	}
+}
+
+func TestGenerator_RunClosedDay(t *testing.T) {
+	store := memory.NewStore()
+	repo := memory.NewSettlementRepository(store)
+	schedule, _ := cutoff.Parse("", "", "")
+	generator := NewGenerator([]Partner{{ID: "acme", Accounts: []int64{1}, Layout: Layout{Format: FormatCSV}}},
+		repo, DirectoryTarget{Dir: t.TempDir()}, schedule)
+	generator.now = func() time.Time { return businessDate.Add(24*time.Hour + time.Minute) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	generator.Run(ctx, time.Hour)
+
+	files, _ := repo.ListSettlementFiles(context.Background(), time.Time{}, "", models.SettlementFileKey{}, 10)
+	if len(files) != 1 || !files[0].BusinessDate.Equal(businessDate) {
+		t.Errorf("Expected the closed day's file, got %+v", files)
+	}
+}
+
+func TestIngester_Ingest(t *testing.T) {
+	store := memory.NewStore()
+	accounts := memory.NewAccountRepository(store)
+	transactions := memory.NewTransactionRepository(store)
+	repo := memory.NewSettlementRepository(store)
+	accounts.CreateAccount(models.NewAccount{AccountID: 1, InitialBalance: decimal.NewFromInt(100)})
+	accounts.CreateAccount(models.NewAccount{AccountID: 2, InitialBalance: decimal.Zero})
+	accounts.CreateAccount(models.NewAccount{AccountID: 3, InitialBalance: decimal.NewFromInt(100)})
+	for _, transfer := range []models.Transfer{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(10), ValueDate: businessDate},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(20), ValueDate: businessDate},
+		{SourceAccountID: 3, DestinationAccountID: 2, Amount: decimal.NewFromInt(5), ValueDate: businessDate},
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1), ValueDate: businessDate.AddDate(0, 0, 1)},
+	} {
+		if _, err := transactions.CreateTransaction(transfer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	partners := []Partner{{ID: "acme", Accounts: []int64{1}, Layout: Layout{Format: FormatCSV}}}
+	if _, err := NewGenerator(partners, repo, DirectoryTarget{Dir: t.TempDir()}, nil).Generate(context.Background(), businessDate, false); err != nil {
+		t.Fatal(err)
+	}
+	ingester := NewIngester(partners, repo, nil)
+
+	if _, err := ingester.Ingest(context.Background(), "globex", strings.NewReader("")); err != ErrUnknownPartner {
+		t.Errorf("Expected ErrUnknownPartner, got %v", err)
+	}
+
+	file := "transaction_id,status,reason\n" +
+		"1,ACK\n" +
+		"2,returned,R01 insufficient funds\n" +
+		"\n" +
+		"3,settled\n" + // not the partner's accounts
+		"4,settled\n" + // value date not exported yet
+		"99,settled\n" +
+		"abc,settled\n" +
+		"1,pending\n"
+	report, err := ingester.Ingest(context.Background(), "acme", strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Lines != 7 || len(report.Settled) != 1 || report.Settled[0] != 1 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(report.Returned) != 1 || report.Returned[0].TransactionID != 2 || report.Returned[0].Reason != "R01 insufficient funds" {
+		t.Fatalf("Unexpected returns %+v", report.Returned)
+	}
+	if len(report.Unmatched) != 5 || report.Unmatched[0].Line != 5 {
+		t.Errorf("Unexpected unmatched lines %+v", report.Unmatched)
+	}
+
+	returned, _ := repo.GetTransaction(context.Background(), report.Returned[0].ReturnTransactionID)
+	if returned.ReturnOf != 2 || returned.SourceAccountID != 2 || !returned.Amount.Equal(decimal.NewFromInt(20)) {
+		t.Errorf("Unexpected return transaction %+v", returned)
+	}
+
+	// Re-ingesting never returns a transfer twice
+	report, _ = ingester.Ingest(context.Background(), "acme", strings.NewReader(file))
+	if len(report.Settled) != 1 || len(report.Returned) != 0 || len(report.Unmatched) != 6 {
+		t.Errorf("Unexpected report on re-ingestion %+v", report)
+	}
+}
@@ -0,0 +1,273 @@
+// Package shutdown stops the server gracefully and reports what the stop left behind. On
+// shutdown the HTTP servers stop accepting connections and drain in-flight requests, then the
+// background jobs are cancelled and finish their current work (the usage and SLA recorders flush
+// their buffers on the way out, including the usage of the drained requests). Each phase gets
+// the shutdown timeout. The Report records how many
+// requests were drained or cut off, which jobs did not stop in time, and what durable work is
+// still outstanding (unpublished outbox events, active holds), so operators can verify that a
+// deploy or incident lost nothing
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds each phase of the shutdown: draining requests, then stopping jobs
+const DefaultTimeout = 30 * time.Second
+
+// probeTimeout bounds the outstanding work counts, which run after the shutdown timeout may
+// already have been used up
+const probeTimeout = 5 * time.Second
+
+// Config controls graceful shutdown
+type Config struct {
+	// Timeout bounds draining requests, and then stopping jobs; what is still running is cut off
+	Timeout time.Duration
+
+	// ReportFile is where the report is written as JSON, in addition to the log; empty for none
+	ReportFile string
+}
+
+// LoadConfig reads the shutdown configuration from the environment
+// Variables:
+//   - SHUTDOWN_TIMEOUT (30s): How long in-flight requests, and then background jobs, get to finish
+//   - SHUTDOWN_REPORT_FILE (unset): File the shutdown report is written to as JSON
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{Timeout: DefaultTimeout, ReportFile: os.Getenv("SHUTDOWN_REPORT_FILE")}
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid SHUTDOWN_TIMEOUT %q", value)
+		}
+		config.Timeout = d
+	}
+	return config, nil
+}
+
+// Counter counts outstanding durable work for the report
+type Counter func(ctx context.Context) (int64, error)
+
+// Report describes a completed shutdown
+type Report struct {
+	// Reason is what triggered the shutdown, e.g. the signal received
+	Reason     string    `json:"reason"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	// InFlightRequests were being served when the shutdown started; DrainedRequests completed
+	// during it and AbortedRequests were still running at the timeout (including open WebSocket
+	// and event stream connections)
+	InFlightRequests int64 `json:"in_flight_requests"`
+	DrainedRequests  int64 `json:"drained_requests"`
+	AbortedRequests  int64 `json:"aborted_requests"`
+
+	// StoppedJobs finished after cancellation; AbortedJobs were still running at the timeout
+	StoppedJobs []string `json:"stopped_jobs"`
+	AbortedJobs []string `json:"aborted_jobs"`
+
+	// UnpublishedOutboxEvents are left for the next relay; absent if the storage has no outbox
+	// or they could not be counted
+	UnpublishedOutboxEvents *int64 `json:"unpublished_outbox_events,omitempty"`
+
+	// OpenHolds are still reserving funds; absent if they could not be counted
+	OpenHolds *int64 `json:"open_holds,omitempty"`
+
+	// Errors lists what went wrong during the shutdown, e.g. a count that failed
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Clean reports whether nothing was cut off: every request drained and every job stopped
+// Outstanding outbox events and holds are durable, so they do not make a shutdown unclean
+func (r Report) Clean() bool {
+	return r.AbortedRequests == 0 && len(r.AbortedJobs) == 0 && len(r.Errors) == 0
+}
+
+// Log writes the report as one structured log line; unclean shutdowns are logged as warnings
+func (r Report) Log() {
+	level := slog.LevelInfo
+	if !r.Clean() {
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("reason", r.Reason),
+		slog.Duration("duration", r.FinishedAt.Sub(r.StartedAt)),
+		slog.Int64("in_flight_requests", r.InFlightRequests),
+		slog.Int64("drained_requests", r.DrainedRequests),
+		slog.Int64("aborted_requests", r.AbortedRequests),
+		slog.Any("stopped_jobs", r.StoppedJobs),
+		slog.Any("aborted_jobs", r.AbortedJobs),
+	}
+	if r.UnpublishedOutboxEvents != nil {
+		attrs = append(attrs, slog.Int64("unpublished_outbox_events", *r.UnpublishedOutboxEvents))
+	}
+	if r.OpenHolds != nil {
+		attrs = append(attrs, slog.Int64("open_holds", *r.OpenHolds))
+	}
+	if len(r.Errors) > 0 {
+		attrs = append(attrs, slog.Any("errors", r.Errors))
+	}
+	slog.LogAttrs(context.Background(), level, "Shutdown report", attrs...)
+}
+
+// WriteFile writes the report to path as indented JSON
+func (r Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode shutdown report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write shutdown report: %w", err)
+	}
+	return nil
+}
+
+// job is a background job started through Coordinator.Go
+type job struct {
+	name string
+	done chan struct{}
+}
+
+// wait reports whether the job finished before ctx ended
+func (j job) wait(ctx context.Context) bool {
+	select {
+	case <-j.done:
+		return true
+	case <-ctx.Done():
+	}
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Coordinator tracks what a shutdown has to wait for: in-flight requests and background jobs
+// Safe for concurrent use
+type Coordinator struct {
+	// PendingOutbox counts unpublished outbox events; nil when the storage has no outbox
+	PendingOutbox Counter
+
+	// OpenHolds counts holds still reserving funds
+	OpenHolds Counter
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inFlight atomic.Int64
+
+	mu   sync.Mutex
+	jobs []job
+}
+
+// New creates a coordinator with no jobs or counters
+func New() *Coordinator {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Coordinator{ctx: ctx, cancel: cancel}
+}
+
+// Go runs a background job until the shutdown cancels its context
+// name identifies the job in the report
+func (c *Coordinator) Go(name string, run func(ctx context.Context)) {
+	j := job{name: name, done: make(chan struct{})}
+	c.mu.Lock()
+	c.jobs = append(c.jobs, j)
+	c.mu.Unlock()
+	go func() {
+		defer close(j.done)
+		run(c.ctx)
+	}()
+}
+
+// Track counts the requests being served by next, for the report's request totals
+func (c *Coordinator) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.inFlight.Add(1)
+		defer c.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Shutdown drains the servers' requests, then stops the background jobs, each within timeout,
+// and returns the report
+// The servers (one per listener) drain together; those whose requests do not drain in time are
+// closed forcibly. Jobs still running at the timeout are left to be cut off when the process exits
+func (c *Coordinator) Shutdown(servers []*http.Server, reason string, timeout time.Duration) Report {
+	report := Report{Reason: reason, StartedAt: time.Now(), StoppedJobs: []string{}, AbortedJobs: []string{}}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	report.InFlightRequests = c.inFlight.Load()
+	errs := make([]error, len(servers))
+	var drained sync.WaitGroup
+	for i, server := range servers {
+		drained.Add(1)
+		go func(i int, server *http.Server) {
+			defer drained.Done()
+			errs[i] = server.Shutdown(ctx)
+		}(i, server)
+	}
+	drained.Wait()
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			report.Errors = append(report.Errors, fmt.Sprintf("server shutdown: %v", err))
+		}
+	}
+	// Hijacked connections (WebSocket) are not drained by Shutdown and are counted as aborted
+	report.AbortedRequests = c.inFlight.Load()
+	report.DrainedRequests = max(report.InFlightRequests-report.AbortedRequests, 0)
+	if ctx.Err() != nil {
+		for _, server := range servers {
+			server.Close()
+		}
+	}
+
+	c.cancel()
+	jobsCtx, cancelJobs := context.WithTimeout(context.Background(), timeout)
+	defer cancelJobs()
+
+	c.mu.Lock()
+	jobs := append([]job(nil), c.jobs...)
+	c.mu.Unlock()
+	for _, j := range jobs {
+		if j.wait(jobsCtx) {
+			report.StoppedJobs = append(report.StoppedJobs, j.name)
+		} else {
+			report.AbortedJobs = append(report.AbortedJobs, j.name)
+		}
+	}
+	sort.Strings(report.StoppedJobs)
+	sort.Strings(report.AbortedJobs)
+
+	probeCtx, cancelProbes := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancelProbes()
+	report.UnpublishedOutboxEvents = count(probeCtx, c.PendingOutbox, "unpublished outbox events", &report)
+	report.OpenHolds = count(probeCtx, c.OpenHolds, "open holds", &report)
+
+	report.FinishedAt = time.Now()
+	return report
+}
+
+// count runs counter, recording a failure in the report; returns nil without a counter or on failure
+func count(ctx context.Context, counter Counter, what string, report *Report) *int64 {
+	if counter == nil {
+		return nil
+	}
+	n, err := counter(ctx)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("counting %s: %v", what, err))
+		return nil
+	}
+	return &n
+}
@@ -0,0 +1,108 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "")
+	t.Setenv("SHUTDOWN_REPORT_FILE", "/var/log/shutdown.json")
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Timeout != DefaultTimeout || config.ReportFile != "/var/log/shutdown.json" {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	for _, value := range []string{"soon", "0s", "-1s"} {
+		t.Setenv("SHUTDOWN_TIMEOUT", value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("SHUTDOWN_TIMEOUT %q: expected an error", value)
+		}
+	}
+}
+
+func TestCoordinator_Shutdown(t *testing.T) {
+	c := New()
+	c.PendingOutbox = func(ctx context.Context) (int64, error) { return 3, nil }
+	c.OpenHolds = func(ctx context.Context) (int64, error) { return 0, errors.New("database unavailable") }
+
+	// One job stops when cancelled; the other ignores cancellation and is cut off
+	stuck := make(chan struct{})
+	defer close(stuck)
+	c.Go("relay", func(ctx context.Context) { <-ctx.Done() })
+	c.Go("stuck", func(ctx context.Context) { <-stuck })
+
+	// One request finishes during the drain; the other outlasts the timeout
+	started := make(chan struct{}, 2)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(100 * time.Millisecond)
+	})
+	mux.HandleFunc("/hung", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-stuck:
+		case <-r.Context().Done():
+		}
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: c.Track(mux)}
+	go server.Serve(listener)
+	for _, path := range []string{"/slow", "/hung"} {
+		go http.Get("http://" + listener.Addr().String() + path)
+	}
+	<-started
+	<-started
+
+	report := c.Shutdown([]*http.Server{server}, "terminated", 500*time.Millisecond)
+
+	if report.Reason != "terminated" || report.InFlightRequests != 2 || report.DrainedRequests != 1 || report.AbortedRequests != 1 {
+		t.Errorf("Unexpected request totals %+v", report)
+	}
+	if !reflect.DeepEqual(report.StoppedJobs, []string{"relay"}) || !reflect.DeepEqual(report.AbortedJobs, []string{"stuck"}) {
+		t.Errorf("Expected relay stopped and stuck aborted, got %v and %v", report.StoppedJobs, report.AbortedJobs)
+	}
+	if report.UnpublishedOutboxEvents == nil || *report.UnpublishedOutboxEvents != 3 {
+		t.Errorf("Expected 3 unpublished outbox events, got %v", report.UnpublishedOutboxEvents)
+	}
+	if report.OpenHolds != nil || len(report.Errors) != 1 {
+		t.Errorf("Expected the failed hold count to be reported as an error, got %v %v", report.OpenHolds, report.Errors)
+	}
+	if report.Clean() {
+		t.Error("Expected an unclean shutdown")
+	}
+
+	path := filepath.Join(t.TempDir(), "shutdown.json")
+	if err := report.WriteFile(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var written map[string]interface{}
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("Expected a JSON report, got %q: %v", data, err)
+	}
+	if written["aborted_requests"] != float64(1) || written["unpublished_outbox_events"] != float64(3) {
+		t.Errorf("Unexpected report file %v", written)
+	}
+	if _, ok := written["open_holds"]; ok {
+		t.Error("Expected open_holds to be omitted when it could not be counted")
+	}
+}
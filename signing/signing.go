@@ -0,0 +1,264 @@
+// Package signing verifies requests signed with HMAC-SHA256 by the holder of an API key, a
+// higher-assurance alternative to bearer tokens: the key's secret never travels with a request,
+// and a signature covers one request's method, path, body and time, so a captured request cannot
+// be altered, or replayed: the Manager accepts each signature once, and refuses it for good once
+// its timestamp is outside the allowed window. Keys are kept in the api_keys table and issued and
+// revoked through the Manager
+//
+// A client signs a request by sending these headers:
+//
+//	X-Signature-Key-ID: <key ID>
+//	X-Signature-Timestamp: <Unix time in seconds>
+//	X-Signature: <hex HMAC-SHA256 of the canonical request, keyed with the secret>
+//
+// The canonical request is the uppercase method, the path with its query string as sent, the
+// timestamp and the hex SHA-256 of the body, each followed by a newline (see Canonical)
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+// Headers of a signed request
+const (
+	KeyIDHeader     = "X-Signature-Key-ID"
+	TimestampHeader = "X-Signature-Timestamp"
+	SignatureHeader = "X-Signature"
+)
+
+// DefaultMaxAge is how far a signature's timestamp may be from the server's clock
+const DefaultMaxAge = 5 * time.Minute
+
+// maxDescriptionLength bounds the description of a key
+const maxDescriptionLength = 200
+
+var (
+	// ErrUnsigned reports a request without a signature to an endpoint requiring one
+	ErrUnsigned = errors.New("request is not signed")
+
+	// ErrInvalidSignature reports a signature that does not verify; the wrapping error says why
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// Config controls the verification of signed requests
+type Config struct {
+	// Required refuses unsigned requests to the signed endpoints; otherwise they are accepted and
+	// only the signatures requests carry are verified
+	Required bool
+
+	// MaxAge is how far a signature's timestamp may be from the server's clock, either way
+	MaxAge time.Duration
+}
+
+// LoadConfig reads the request signing settings from the environment
+// Variables:
+//   - REQUIRE_SIGNED_REQUESTS (false): Refuse unsigned requests to the signed endpoints
+//   - SIGNATURE_MAX_AGE (5m): How far a signature's timestamp may be from the server's clock
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{MaxAge: DefaultMaxAge}
+	if value := os.Getenv("REQUIRE_SIGNED_REQUESTS"); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid REQUIRE_SIGNED_REQUESTS %q", value)
+		}
+		config.Required = required
+	}
+	if value := os.Getenv("SIGNATURE_MAX_AGE"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid SIGNATURE_MAX_AGE %q", value)
+		}
+		config.MaxAge = d
+	}
+	return config, nil
+}
+
+// Canonical returns the canonical form of a request, the string its signature covers
+func Canonical(method, path, timestamp string, body []byte) string {
+	digest := sha256.Sum256(body)
+	return strings.ToUpper(method) + "\n" + path + "\n" + timestamp + "\n" + hex.EncodeToString(digest[:]) + "\n"
+}
+
+// Sign returns the hex HMAC-SHA256 of a canonical request keyed with secret
+func Sign(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest signs r, whose body is body, with the key at time now by setting its signature
+// headers; used by clients and tests
+func SignRequest(r *http.Request, keyID, secret string, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	r.Header.Set(KeyIDHeader, keyID)
+	r.Header.Set(TimestampHeader, timestamp)
+	r.Header.Set(SignatureHeader, Sign(secret, Canonical(r.Method, r.URL.RequestURI(), timestamp, body)))
+}
+
+// Manager issues and revokes API keys and verifies the requests signed with them
+// Safe for concurrent use
+type Manager struct {
+	repo   database.APIKeyRepositoryInterface
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // verified signatures, by key ID and signature, until they expire
+	nextSweep time.Time            // when expired signatures are next dropped from seen
+}
+
+// NewManager creates a manager storing the keys in repo
+func NewManager(repo database.APIKeyRepositoryInterface, config Config) *Manager {
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultMaxAge
+	}
+	return &Manager{repo: repo, config: config, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+// Required reports whether unsigned requests to the signed endpoints are refused
+func (m *Manager) Required() bool {
+	return m.config.Required
+}
+
+// Create issues a key with a random ID and secret
+// Returns the stored key with its secret, a *service.ValidationError for a description longer
+// than 200 characters, or a storage error
+func (m *Manager) Create(ctx context.Context, req models.CreateAPIKeyRequest) (*models.APIKey, error) {
+	description := strings.TrimSpace(req.Description)
+	if len([]rune(description)) > maxDescriptionLength {
+		return nil, &service.ValidationError{Message: fmt.Sprintf("description exceeds %d characters", maxDescriptionLength)}
+	}
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return m.repo.CreateAPIKey(ctx, models.APIKey{
+		ID:          "ak_" + hex.EncodeToString(id),
+		Secret:      base64.RawURLEncoding.EncodeToString(secret),
+		Description: description,
+	})
+}
+
+// List returns every key, revoked ones included, oldest first
+func (m *Manager) List(ctx context.Context) ([]models.APIKey, error) {
+	return m.repo.ListAPIKeys(ctx)
+}
+
+// Revoke revokes a key: requests signed with it are refused from now on
+// Returns the key, service.ErrAPIKeyNotFound, or a storage error
+func (m *Manager) Revoke(ctx context.Context, id string) (*models.APIKey, error) {
+	key, err := m.repo.RevokeAPIKey(ctx, id)
+	if err != nil {
+		if err.Error() == service.ErrAPIKeyNotFound.Error() {
+			return nil, service.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// IsSigned reports whether r carries any of the signature headers, which Verify then checks
+func IsSigned(r *http.Request) bool {
+	return r.Header.Get(KeyIDHeader) != "" || r.Header.Get(TimestampHeader) != "" || r.Header.Get(SignatureHeader) != ""
+}
+
+// Verify checks the signature of r, whose body is body
+// Returns the signing key, nil for an unsigned request when signatures are not required,
+// ErrUnsigned when they are, an error wrapping ErrInvalidSignature for a signature that does not
+// verify (malformed, by an unknown or revoked key, outside the allowed time window, not matching
+// the request or already verified), or a storage error
+// A signature is accepted once: a client retrying a request signs it again with a new timestamp.
+// Signatures are remembered by this process only, so replicas behind a load balancer each accept
+// a replay once; signed transfers carrying a reference are booked once all the same
+func (m *Manager) Verify(ctx context.Context, r *http.Request, body []byte) (*models.APIKey, error) {
+	keyID := r.Header.Get(KeyIDHeader)
+	timestamp := r.Header.Get(TimestampHeader)
+	signature := r.Header.Get(SignatureHeader)
+	if !IsSigned(r) {
+		if m.config.Required {
+			return nil, ErrUnsigned
+		}
+		return nil, nil
+	}
+	if keyID == "" || timestamp == "" || signature == "" {
+		return nil, fmt.Errorf("%w: %s, %s and %s are all required", ErrInvalidSignature,
+			KeyIDHeader, TimestampHeader, SignatureHeader)
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+	}
+	if skew := m.now().Sub(time.Unix(seconds, 0)); skew > m.config.MaxAge || skew < -m.config.MaxAge {
+		return nil, fmt.Errorf("%w: timestamp outside the allowed window", ErrInvalidSignature)
+	}
+	presented, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	key, err := m.repo.GetAPIKey(ctx, keyID)
+	if err != nil {
+		if err.Error() == service.ErrAPIKeyNotFound.Error() {
+			return nil, fmt.Errorf("%w: unknown key", ErrInvalidSignature)
+		}
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: revoked key", ErrInvalidSignature)
+	}
+	expected, _ := hex.DecodeString(Sign(key.Secret, Canonical(r.Method, r.URL.RequestURI(), timestamp, body)))
+	if !hmac.Equal(presented, expected) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+	if !m.firstUse(keyID+":"+hex.EncodeToString(presented), time.Unix(seconds, 0).Add(m.config.MaxAge)) {
+		return nil, fmt.Errorf("%w: replayed signature", ErrInvalidSignature)
+	}
+	return key, nil
+}
+
+// firstUse records a verified signature until expires, when its timestamp leaves the allowed
+// window; it reports false if the signature was already recorded
+// Expired signatures are dropped at most once per MaxAge, bounding seen to the signatures of
+// about two windows
+func (m *Manager) firstUse(signature string, expires time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.After(m.nextSweep) {
+		for seen, until := range m.seen {
+			if now.After(until) {
+				delete(m.seen, seen)
+			}
+		}
+		m.nextSweep = now.Add(m.config.MaxAge)
+	}
+	if _, ok := m.seen[signature]; ok {
+		return false
+	}
+	m.seen[signature] = expires
+	return true
+}
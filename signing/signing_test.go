@@ -0,0 +1,144 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+	"internal-transfers/service"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("REQUIRE_SIGNED_REQUESTS", "")
+	t.Setenv("SIGNATURE_MAX_AGE", "")
+	if config, err := LoadConfig(); err != nil || config.Required || config.MaxAge != DefaultMaxAge {
+		t.Errorf("Expected the defaults, got %+v (%v)", config, err)
+	}
+	t.Setenv("REQUIRE_SIGNED_REQUESTS", "true")
+	t.Setenv("SIGNATURE_MAX_AGE", "30s")
+	if config, err := LoadConfig(); err != nil || !config.Required || config.MaxAge != 30*time.Second {
+		t.Errorf("Expected required signatures within 30s, got %+v (%v)", config, err)
+	}
+	for name, value := range map[string]string{"REQUIRE_SIGNED_REQUESTS": "always", "SIGNATURE_MAX_AGE": "0"} {
+		t.Setenv(name, value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("%s=%q: expected an error", name, value)
+		}
+		t.Setenv(name, "")
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	got := Canonical("post", "/v1/transactions?dry_run=true", "1760520600", []byte("{}"))
+	want := "POST\n/v1/transactions?dry_run=true\n1760520600\n" +
+		"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a\n"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestManager(t *testing.T) {
+	manager := NewManager(memory.NewStore().APIKeys(), Config{})
+	now := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	manager.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	key, err := manager.Create(ctx, models.CreateAPIKeyRequest{Description: " payroll batch job "})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(key.ID, "ak_") || len(key.Secret) != 43 || key.Description != "payroll batch job" {
+		t.Errorf("Unexpected key %+v", key)
+	}
+	var invalid *service.ValidationError
+	if _, err := manager.Create(ctx, models.CreateAPIKeyRequest{Description: strings.Repeat("x", 201)}); !errors.As(err, &invalid) {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+
+	body := []byte(`{"source_account_id":1,"destination_account_id":2,"amount":"10.00"}`)
+	signed := func(mutate func(r *http.Request)) *http.Request {
+		r := httptest.NewRequest("POST", "/v1/transactions", bytes.NewReader(body))
+		SignRequest(r, key.ID, key.Secret, body, now.Add(-time.Minute))
+		if mutate != nil {
+			mutate(r)
+		}
+		return r
+	}
+
+	if verified, err := manager.Verify(ctx, signed(nil), body); err != nil || verified.ID != key.ID {
+		t.Errorf("Expected the key to verify, got %+v, %v", verified, err)
+	}
+	for name, mutate := range map[string]func(r *http.Request){
+		"tampered path":   func(r *http.Request) { r.URL.Path = "/v1/holds" },
+		"tampered method": func(r *http.Request) { r.Method = "PUT" },
+		"unknown key":     func(r *http.Request) { r.Header.Set(KeyIDHeader, "ak_0") },
+		"missing header":  func(r *http.Request) { r.Header.Del(TimestampHeader) },
+		"bad timestamp":   func(r *http.Request) { r.Header.Set(TimestampHeader, "yesterday") },
+		"bad signature":   func(r *http.Request) { r.Header.Set(SignatureHeader, "zz") },
+		"expired": func(r *http.Request) {
+			SignRequest(r, key.ID, key.Secret, body, now.Add(-DefaultMaxAge-time.Second))
+		},
+		"future": func(r *http.Request) {
+			SignRequest(r, key.ID, key.Secret, body, now.Add(DefaultMaxAge+time.Second))
+		},
+	} {
+		if _, err := manager.Verify(ctx, signed(mutate), body); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected an invalid signature, got %v", name, err)
+		}
+	}
+	if _, err := manager.Verify(ctx, signed(nil), []byte(`{"amount":"1000.00"}`)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a tampered body to fail, got %v", err)
+	}
+
+	// A signature verifies once: replays within the window are refused, and after it the
+	// timestamp is refused and the signature forgotten
+	signedAt := func(at time.Time) *http.Request {
+		r := httptest.NewRequest("POST", "/v1/transactions", bytes.NewReader(body))
+		SignRequest(r, key.ID, key.Secret, body, at)
+		return r
+	}
+	captured := signed(nil).Header.Clone() // the signature verified first
+	replay := httptest.NewRequest("POST", "/v1/transactions", bytes.NewReader(body))
+	replay.Header = captured
+	if _, err := manager.Verify(ctx, replay, body); !errors.Is(err, ErrInvalidSignature) || !strings.Contains(err.Error(), "replayed") {
+		t.Errorf("Expected a replayed signature to fail, got %v", err)
+	}
+	if _, err := manager.Verify(ctx, signedAt(now), body); err != nil {
+		t.Errorf("Expected a request signed again to verify, got %v", err)
+	}
+	now = now.Add(2 * DefaultMaxAge)
+	if _, err := manager.Verify(ctx, replay, body); !errors.Is(err, ErrInvalidSignature) || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("Expected an expired replay to fail, got %v", err)
+	}
+	if _, err := manager.Verify(ctx, signedAt(now), body); err != nil || len(manager.seen) != 1 {
+		t.Errorf("Expected expired signatures to be forgotten, got %d remembered (%v)", len(manager.seen), err)
+	}
+
+	// Unsigned requests pass unless signatures are required
+	unsigned := httptest.NewRequest("POST", "/v1/transactions", bytes.NewReader(body))
+	if verified, err := manager.Verify(ctx, unsigned, body); verified != nil || err != nil {
+		t.Errorf("Expected an unsigned request to pass, got %+v, %v", verified, err)
+	}
+	manager.config.Required = true
+	if _, err := manager.Verify(ctx, unsigned, body); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Expected an unsigned request to be refused, got %v", err)
+	}
+
+	// Revoked keys no longer verify
+	if _, err := manager.Revoke(ctx, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Verify(ctx, signed(nil), body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a revoked key to fail, got %v", err)
+	}
+	if _, err := manager.Revoke(ctx, "ak_0"); !errors.Is(err, service.ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,308 @@
+// Package sla records the processing latency of every committed transfer per client, so the commit
+// latency SLA in partner contracts (a p95 of 500ms by default) can be proven from data. A transfer's
+// timeline runs from when its request was received, to the ledger commit, to the emission of the
+// committed-transfer event to in-process subscribers. Clients are identified like API usage, by
+// the API key their requests are signed with (see usage.Attribute). Samples are buffered in
+// memory and periodically written to storage, so recording adds no database write to the transfer
+// path.
+// Each stage of a transfer (validation, lock wait, commit, emit) also has a latency budget; the
+// stages over budget are flagged on the transfer's response and trace and counted in metrics
+package sla
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+	"internal-transfers/usage"
+)
+
+// Defaults
+const (
+	// DefaultTarget is the p95 commit latency partners are promised
+	DefaultTarget = 500 * time.Millisecond
+
+	// DefaultFlushInterval is how often buffered samples are written to storage
+	DefaultFlushInterval = 10 * time.Second
+
+	// MaxPending bounds the samples buffered while storage is unavailable; newer samples are
+	// dropped (and counted in the flush error log) rather than growing without limit
+	MaxPending = 100000
+)
+
+// metrics exposes SLA counters under "sla" at /debug/vars
+//   - samples: transfer timelines recorded
+//   - budget_exceeded.<stage>: transfers whose stage went over its latency budget
+var metrics = expvar.NewMap("sla")
+
+// budgetExceeded counts the transfers over budget per stage
+var budgetExceeded = new(expvar.Map).Init()
+
+func init() {
+	metrics.Set("budget_exceeded", budgetExceeded)
+}
+
+// Budgets is the latency budget of each transfer stage (see models.LatencyStages); a stage
+// without a budget is never over it
+type Budgets map[string]time.Duration
+
+// DefaultBudgets splits DefaultTarget over the stages, most of it to the commit
+func DefaultBudgets() Budgets {
+	return Budgets{
+		models.LatencyStageValidate: 50 * time.Millisecond,
+		models.LatencyStageLockWait: 100 * time.Millisecond,
+		models.LatencyStageCommit:   250 * time.Millisecond,
+		models.LatencyStageEmit:     100 * time.Millisecond,
+	}
+}
+
+// Exceeded returns the stages of the timeline that went over their budget, in processing order
+func (b Budgets) Exceeded(latency models.TransferLatency) []string {
+	exceeded := []string{}
+	for _, stage := range latency.Stages() {
+		if budget := b[stage.Stage]; budget > 0 && stage.Duration > budget {
+			exceeded = append(exceeded, stage.Stage)
+		}
+	}
+	return exceeded
+}
+
+// ParseBudgets parses stage budgets as comma-separated stage=duration pairs, e.g.
+// "lock_wait=20ms,commit=150ms"; stages not listed keep their default and 0 removes a budget
+func ParseBudgets(value string) (Budgets, error) {
+	budgets := DefaultBudgets()
+	for _, pair := range strings.Split(value, ",") {
+		stage, duration, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !slices.Contains(models.LatencyStages, stage) {
+			return nil, fmt.Errorf("invalid stage budget %q (expected stage=duration with a stage of %s)", pair, strings.Join(models.LatencyStages, ", "))
+		}
+		d, err := time.ParseDuration(duration)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid budget %q for stage %s", duration, stage)
+		}
+		budgets[stage] = d
+	}
+	return budgets, nil
+}
+
+// Config controls SLA tracking
+type Config struct {
+	// Target is the p95 commit latency a client's transfers are measured against
+	Target time.Duration
+
+	// FlushInterval is how often buffered samples are written to storage
+	FlushInterval time.Duration
+
+	// Budgets is the latency budget of each transfer stage
+	Budgets Budgets
+}
+
+// LoadConfig reads the SLA configuration from the environment
+// Variables:
+//   - SLA_COMMIT_TARGET (500ms): p95 commit latency target
+//   - SLA_FLUSH_INTERVAL (10s): How often buffered samples are written to storage
+//   - SLA_STAGE_BUDGETS (validate=50ms,lock_wait=100ms,commit=250ms,emit=100ms): Per-stage
+//     latency budgets to override (see ParseBudgets)
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	config := Config{Target: DefaultTarget, FlushInterval: DefaultFlushInterval, Budgets: DefaultBudgets()}
+	for _, setting := range []struct {
+		name   string
+		target *time.Duration
+	}{{"SLA_COMMIT_TARGET", &config.Target}, {"SLA_FLUSH_INTERVAL", &config.FlushInterval}} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid %s %q", setting.name, value)
+		}
+		*setting.target = d
+	}
+	if value := os.Getenv("SLA_STAGE_BUDGETS"); value != "" {
+		budgets, err := ParseBudgets(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SLA_STAGE_BUDGETS: %w", err)
+		}
+		config.Budgets = budgets
+	}
+	return config, nil
+}
+
+// Recorder buffers transfer latency samples and flushes them to storage
+// Safe for concurrent use
+type Recorder struct {
+	repo    database.LatencyRepositoryInterface
+	target  time.Duration
+	budgets Budgets
+	now     func() time.Time
+
+	mu      sync.Mutex
+	pending []models.TransferLatency
+	retries map[int64]int
+	dropped int64
+}
+
+// NewRecorder creates a recorder writing to repo and reporting against target, with the default
+// stage budgets
+func NewRecorder(repo database.LatencyRepositoryInterface, target time.Duration) *Recorder {
+	return &Recorder{repo: repo, target: target, budgets: DefaultBudgets(), now: time.Now}
+}
+
+// WithBudgets sets the stage latency budgets samples are checked against
+// Returns the recorder to allow chaining after NewRecorder
+func (r *Recorder) WithBudgets(budgets Budgets) *Recorder {
+	r.budgets = budgets
+	return r
+}
+
+// Record buffers the latency sample of a committed transfer and counts its stages over budget
+// Samples without a client are attributed to usage.Anonymous
+func (r *Recorder) Record(sample models.TransferLatency) {
+	if sample.ClientID == "" {
+		sample.ClientID = usage.Anonymous
+	}
+	metrics.Add("samples", 1)
+	for _, stage := range r.budgets.Exceeded(sample) {
+		budgetExceeded.Add(stage, 1)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) >= MaxPending {
+		r.dropped++
+		return
+	}
+	r.pending = append(r.pending, sample)
+}
+
+// RecordRetry buffers a retry of a committed transfer: a later request refused as its duplicate
+// Retries are counted per transaction, so the buffer grows with the transfers retried, not the
+// retries; it is bounded by MaxPending like the samples
+func (r *Recorder) RecordRetry(transactionID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.retries == nil {
+		r.retries = make(map[int64]int)
+	}
+	if _, counted := r.retries[transactionID]; !counted && len(r.retries) >= MaxPending {
+		r.dropped++
+		return
+	}
+	r.retries[transactionID]++
+}
+
+// Flush writes the buffered samples, then the buffered retries to storage, so a retry of a
+// transfer buffered in the same flush finds its sample
+// On failure the samples or retries are kept and retried by the next flush
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending, retries, dropped := r.pending, r.retries, r.dropped
+	r.pending, r.retries, r.dropped = nil, nil, 0
+	r.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("SLA recorder dropped latency samples while storage was unavailable", "dropped", dropped)
+	}
+	if len(pending) > 0 {
+		if err := r.repo.AddLatencies(ctx, pending); err != nil {
+			r.mu.Lock()
+			r.pending = append(pending, r.pending...)
+			if len(r.pending) > MaxPending {
+				r.dropped += int64(len(r.pending) - MaxPending)
+				r.pending = r.pending[:MaxPending]
+			}
+			r.mu.Unlock()
+			r.requeueRetries(retries)
+			return err
+		}
+	}
+	if len(retries) > 0 {
+		if err := r.repo.AddRetries(ctx, retries); err != nil {
+			r.requeueRetries(retries)
+			return err
+		}
+	}
+	return nil
+}
+
+// requeueRetries returns retries that could not be written to the buffer
+func (r *Recorder) requeueRetries(retries map[int64]int) {
+	if len(retries) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.retries == nil {
+		r.retries = retries
+		return
+	}
+	for transactionID, count := range retries {
+		r.retries[transactionID] += count
+	}
+}
+
+// Run flushes every interval until ctx is cancelled, then flushes once more
+func (r *Recorder) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := r.Flush(context.Background()); err != nil {
+				slog.Error("SLA flush error", "error", err)
+			}
+			return
+		case <-time.After(interval):
+		}
+		if err := r.Flush(ctx); err != nil {
+			slog.Error("SLA flush error", "error", err)
+		}
+	}
+}
+
+// Stats returns the latency statistics of a client (or all clients if clientID is empty) for the
+// transfers received between the from and to dates inclusive (UTC), ordered by client
+// Buffered samples are flushed first so reports include the most recent transfers
+func (r *Recorder) Stats(ctx context.Context, clientID string, from, to time.Time) ([]models.LatencyStats, error) {
+	if err := r.Flush(ctx); err != nil {
+		slog.ErrorContext(ctx, "SLA flush error", "error", err)
+	}
+	return r.repo.LatencyStats(ctx, clientID, from, to.AddDate(0, 0, 1), r.target)
+}
+
+// Latency returns the recorded timeline of a transfer, or nil if none was recorded
+// Buffered samples are flushed first so the most recent transfers are included
+func (r *Recorder) Latency(ctx context.Context, transactionID int64) (*models.TransferLatency, error) {
+	if err := r.Flush(ctx); err != nil {
+		slog.ErrorContext(ctx, "SLA flush error", "error", err)
+	}
+	return r.repo.GetLatency(ctx, transactionID)
+}
+
+// Target returns the p95 commit latency target
+func (r *Recorder) Target() time.Duration {
+	return r.target
+}
+
+// Budgets returns the stage latency budgets
+func (r *Recorder) Budgets() Budgets {
+	return r.budgets
+}
+
+// Now returns the recorder's current time
+func (r *Recorder) Now() time.Time {
+	return r.now()
+}
+
+// Compliant reports whether stats meet the target: the p95 commit latency is within it
+func (r *Recorder) Compliant(stats models.LatencyStats) bool {
+	return stats.CommitP95 <= r.target
+}
@@ -0,0 +1,164 @@
+package sla
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+	"internal-transfers/usage"
+)
+
+// failingRepository fails writes while failing is set
+type failingRepository struct {
+	*memory.LatencyRepository
+	failing bool
+}
+
+func (r *failingRepository) AddLatencies(ctx context.Context, samples []models.TransferLatency) error {
+	if r.failing {
+		return errors.New("database unavailable")
+	}
+	return r.LatencyRepository.AddLatencies(ctx, samples)
+}
+
+func (r *failingRepository) AddRetries(ctx context.Context, retries map[int64]int) error {
+	if r.failing {
+		return errors.New("database unavailable")
+	}
+	return r.LatencyRepository.AddRetries(ctx, retries)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("SLA_COMMIT_TARGET", "250ms")
+	t.Setenv("SLA_FLUSH_INTERVAL", "")
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Target != 250*time.Millisecond || config.FlushInterval != DefaultFlushInterval || config.Budgets[models.LatencyStageCommit] != 250*time.Millisecond {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	t.Setenv("SLA_STAGE_BUDGETS", "lock_wait=20ms, emit=0")
+	if config, err := LoadConfig(); err != nil || config.Budgets[models.LatencyStageLockWait] != 20*time.Millisecond ||
+		config.Budgets[models.LatencyStageEmit] != 0 || config.Budgets[models.LatencyStageValidate] != 50*time.Millisecond {
+		t.Errorf("Expected the listed budgets to override the defaults, got %+v, %v", config.Budgets, err)
+	}
+
+	for name, env := range map[string][3]string{
+		"invalid target":   {"fast", "", ""},
+		"zero target":      {"0s", "", ""},
+		"invalid interval": {"", "soon", ""},
+		"unknown stage":    {"", "", "render=5ms"},
+		"invalid budget":   {"", "", "commit=-1s"},
+		"missing budget":   {"", "", "commit"},
+	} {
+		t.Setenv("SLA_COMMIT_TARGET", env[0])
+		t.Setenv("SLA_FLUSH_INTERVAL", env[1])
+		t.Setenv("SLA_STAGE_BUDGETS", env[2])
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBudgets_Exceeded(t *testing.T) {
+	latency := models.TransferLatency{
+		Validate: 10 * time.Millisecond,
+		LockWait: 150 * time.Millisecond,
+		Commit:   200 * time.Millisecond,
+		Emit:     450 * time.Millisecond,
+	}
+	stages := latency.Stages()
+	if stages[2].Stage != models.LatencyStageCommit || stages[2].Duration != 40*time.Millisecond || stages[3].Duration != 250*time.Millisecond {
+		t.Errorf("Expected the commit's own 40ms and the emit's 250ms, got %+v", stages)
+	}
+	if exceeded := DefaultBudgets().Exceeded(latency); len(exceeded) != 2 || exceeded[0] != models.LatencyStageLockWait || exceeded[1] != models.LatencyStageEmit {
+		t.Errorf("Expected lock_wait and emit over budget, got %v", exceeded)
+	}
+	if exceeded := (Budgets{}).Exceeded(latency); len(exceeded) != 0 {
+		t.Errorf("Expected no stage over budget without budgets, got %v", exceeded)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	repo := &failingRepository{LatencyRepository: memory.NewStore().Latency().(*memory.LatencyRepository)}
+	recorder := NewRecorder(repo, 500*time.Millisecond)
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	// key_a: commits of 100ms..1000ms, so the p95 (955ms) misses the target
+	for i := int64(1); i <= 10; i++ {
+		recorder.Record(models.TransferLatency{TransactionID: i, ClientID: "key_a", ReceivedAt: day.Add(time.Hour),
+			Commit: time.Duration(i) * 100 * time.Millisecond, Emit: time.Duration(i)*100*time.Millisecond + time.Millisecond})
+	}
+	recorder.Record(models.TransferLatency{TransactionID: 11, ReceivedAt: day, Commit: 20 * time.Millisecond, Emit: 21 * time.Millisecond})
+	// Received the next day, outside the period
+	recorder.Record(models.TransferLatency{TransactionID: 12, ClientID: "key_a", ReceivedAt: day.AddDate(0, 0, 1), Commit: time.Millisecond})
+
+	// Samples survive a failed flush and are included once storage recovers
+	repo.failing = true
+	if err := recorder.Flush(ctx); err == nil {
+		t.Fatal("Expected flush error")
+	}
+	repo.failing = false
+
+	stats, err := recorder.Stats(ctx, "", day, day)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(stats) != 2 || stats[0].ClientID != usage.Anonymous || stats[1].ClientID != "key_a" {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	a := stats[1]
+	if a.Transfers != 10 || a.CommitP50 != 550*time.Millisecond || a.CommitP95 != 955*time.Millisecond ||
+		a.CommitMax != time.Second || a.EmitP95 != 956*time.Millisecond || a.WithinTarget != 5 {
+		t.Errorf("Unexpected stats for key_a %+v", a)
+	}
+	if recorder.Compliant(a) || !recorder.Compliant(stats[0]) {
+		t.Errorf("Expected only the anonymous client to meet the target")
+	}
+
+	// Recording the same transaction again is ignored
+	recorder.Record(models.TransferLatency{TransactionID: 11, ReceivedAt: day, Commit: time.Minute})
+	if stats, _ := recorder.Stats(ctx, usage.Anonymous, day, day); len(stats) != 1 || stats[0].Transfers != 1 || stats[0].CommitMax != 20*time.Millisecond {
+		t.Errorf("Unexpected stats after duplicate %+v", stats)
+	}
+}
+
+func TestRecorder_Latency(t *testing.T) {
+	repo := &failingRepository{LatencyRepository: memory.NewStore().Latency().(*memory.LatencyRepository)}
+	recorder := NewRecorder(repo, 500*time.Millisecond)
+	ctx := context.Background()
+
+	// A retry buffered with its sample is counted once the sample is written
+	recorder.Record(models.TransferLatency{TransactionID: 1, ClientID: "key_a", ReceivedAt: time.Now(),
+		Validate: time.Millisecond, LockWait: 2 * time.Millisecond, Commit: 5 * time.Millisecond, Rules: []string{"wire_limit"}})
+	recorder.RecordRetry(1)
+
+	// Retries survive a failed flush
+	repo.failing = true
+	recorder.RecordRetry(1)
+	if err := recorder.Flush(ctx); err == nil {
+		t.Fatal("Expected flush error")
+	}
+	repo.failing = false
+
+	latency, err := recorder.Latency(ctx, 1)
+	if err != nil || latency == nil {
+		t.Fatalf("Expected the recorded timeline, got %+v (%v)", latency, err)
+	}
+	if latency.Retries != 2 || latency.LockWait != 2*time.Millisecond || latency.Validate != time.Millisecond ||
+		len(latency.Rules) != 1 || latency.Rules[0] != "wire_limit" {
+		t.Errorf("Unexpected timeline %+v", latency)
+	}
+
+	// Retries of transfers without a sample are dropped
+	recorder.RecordRetry(2)
+	if latency, err := recorder.Latency(ctx, 2); err != nil || latency != nil {
+		t.Errorf("Expected no timeline for an unrecorded transfer, got %+v (%v)", latency, err)
+	}
+}
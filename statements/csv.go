@@ -0,0 +1,51 @@
+package statements
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"internal-transfers/models"
+)
+
+// csvHeader names the CSV columns
+var csvHeader = []string{"date", "transaction_id", "description", "counterparty_id", "reference", "memo", "debit", "credit", "balance"}
+
+// WriteCSV renders the statement as one table: an opening balance row dated at the start of the
+// period, a row per transaction with its running balance, and a closing balance row dated at the
+// end of the period
+// Debits and credits are positive amounts in separate columns, in the account's currency; the
+// closing row carries the period's totals
+func WriteCSV(w io.Writer, statement *models.Statement) error {
+	writer := csv.NewWriter(w)
+	writer.Write(csvHeader)
+	writer.Write([]string{formatTime(statement.From), "", "Opening balance", "", "", "", "", "", formatAmount(statement, statement.OpeningBalance)})
+	for _, line := range statement.Lines {
+		debit, credit := debitCredit(statement, line.Amount)
+		writer.Write([]string{
+			formatTime(line.EffectiveAt),
+			optionalID(line.TransactionID),
+			safeCell(description(line)),
+			optionalID(line.CounterpartyID),
+			safeCell(line.Reference),
+			safeCell(line.Memo),
+			debit,
+			credit,
+			formatAmount(statement, line.Balance),
+		})
+	}
+	writer.Write([]string{formatTime(statement.To), "", "Closing balance", "", "", "",
+		formatAmount(statement, statement.TotalDebits), formatAmount(statement, statement.TotalCredits),
+		formatAmount(statement, statement.ClosingBalance)})
+	writer.Flush()
+	return writer.Error()
+}
+
+// safeCell defuses caller-supplied text that spreadsheets would evaluate as a formula by
+// prefixing it with an apostrophe
+func safeCell(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
@@ -0,0 +1,193 @@
+package statements
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"internal-transfers/models"
+)
+
+// A4 page layout in points
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	marginLeft   = 40
+	marginTop    = 800
+	marginBottom = 50
+)
+
+// Fonts of the PDF; both are standard fonts every reader provides
+const (
+	fontHeading = "F1" // Helvetica-Bold
+	fontBody    = "F2" // Courier, monospaced so table columns align
+)
+
+// pdfLine is one line of text on a page
+type pdfLine struct {
+	font    string
+	size    float64
+	leading float64
+	text    string
+}
+
+// tableRow formats a table row; Courier at 8pt fits 107 characters between the margins
+func tableRow(date, transaction, counterparty, text, debit, credit, balance string) string {
+	return fmt.Sprintf("%-10s %10s %12s  %-27s %13s %13s %14s", date, transaction, counterparty, truncate(text, 27), debit, credit, balance)
+}
+
+// WritePDF renders the statement as an A4 PDF: a heading with the account and period, a summary
+// of the balances and totals, and the transaction table, whose column headings repeat on every
+// page
+func WritePDF(w io.Writer, statement *models.Statement) error {
+	heading := func(size float64, text string) pdfLine { return pdfLine{fontHeading, size, size * 1.6, text} }
+	body := func(text string) pdfLine { return pdfLine{fontBody, 8, 11, text} }
+
+	currencyLabel := statement.Currency
+	if currencyLabel == "" {
+		currencyLabel = "-"
+	}
+	intro := []pdfLine{
+		heading(16, "Account statement"),
+		body(fmt.Sprintf("Account:       %d", statement.AccountID)),
+		body(fmt.Sprintf("Currency:      %s", currencyLabel)),
+		body(fmt.Sprintf("Period:        %s to %s", formatTime(statement.From), formatTime(statement.To))),
+		body(fmt.Sprintf("Generated:     %s", formatTime(statement.GeneratedAt))),
+		body(""),
+		heading(11, "Summary"),
+		body(fmt.Sprintf("Opening balance: %20s", formatAmount(statement, statement.OpeningBalance))),
+		body(fmt.Sprintf("Total debits:    %20s", formatAmount(statement, statement.TotalDebits))),
+		body(fmt.Sprintf("Total credits:   %20s", formatAmount(statement, statement.TotalCredits))),
+		body(fmt.Sprintf("Closing balance: %20s", formatAmount(statement, statement.ClosingBalance))),
+		body(fmt.Sprintf("Transactions:    %20d", len(statement.Lines))),
+		body(""),
+		heading(11, "Transactions"),
+	}
+	columns := []pdfLine{
+		body(tableRow("Date", "Txn", "Counterparty", "Description", "Debit", "Credit", "Balance")),
+		body(strings.Repeat("-", 107)),
+	}
+
+	rows := []pdfLine{body(tableRow(statement.From.UTC().Format("2006-01-02"), "", "", "Opening balance", "", "",
+		formatAmount(statement, statement.OpeningBalance)))}
+	for _, line := range statement.Lines {
+		debit, credit := debitCredit(statement, line.Amount)
+		rows = append(rows, body(tableRow(line.EffectiveAt.UTC().Format("2006-01-02"), optionalID(line.TransactionID),
+			optionalID(line.CounterpartyID), description(line), debit, credit, formatAmount(statement, line.Balance))))
+	}
+	rows = append(rows, body(tableRow(statement.To.UTC().Format("2006-01-02"), "", "", "Closing balance",
+		formatAmount(statement, statement.TotalDebits), formatAmount(statement, statement.TotalCredits),
+		formatAmount(statement, statement.ClosingBalance))))
+
+	// Fill pages top to bottom, starting each page's table with the column headings
+	var pages [][]pdfLine
+	page := append([]pdfLine(nil), intro...)
+	page = append(page, columns...)
+	used := height(page)
+	for _, row := range rows {
+		if marginTop-used-row.leading < marginBottom {
+			pages = append(pages, page)
+			page = append([]pdfLine(nil), columns...)
+			used = height(page)
+		}
+		page = append(page, row)
+		used += row.leading
+	}
+	pages = append(pages, page)
+
+	var doc pdfDocument
+	return doc.write(w, pages)
+}
+
+// height returns the vertical space the lines take
+func height(lines []pdfLine) float64 {
+	var total float64
+	for _, line := range lines {
+		total += line.leading
+	}
+	return total
+}
+
+// truncate shortens text to width characters, marking the cut with "..."
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// pdfDocument assembles the objects of a PDF file and its cross-reference table
+type pdfDocument struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// object writes the next numbered object with the given body
+func (d *pdfDocument) object(body string) {
+	d.offsets = append(d.offsets, d.buf.Len())
+	fmt.Fprintf(&d.buf, "%d 0 obj\n%s\nendobj\n", len(d.offsets), body)
+}
+
+// write renders the pages, each a list of lines from the top margin down, with a page number
+// Object layout: 1 catalog, 2 page tree, 3-4 fonts, then a page and its content per page
+func (d *pdfDocument) write(w io.Writer, pages [][]pdfLine) error {
+	d.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	d.object("<< /Type /Catalog /Pages 2 0 R >>")
+	d.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	d.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	d.object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	for i, lines := range pages {
+		var content strings.Builder
+		y := float64(marginTop)
+		for _, line := range lines {
+			if line.text != "" {
+				fmt.Fprintf(&content, "BT /%s %g Tf %d %g Td (%s) Tj ET\n", line.font, line.size, marginLeft, y, pdfString(line.text))
+			}
+			y -= line.leading
+		}
+		fmt.Fprintf(&content, "BT /%s 8 Tf %d %d Td (%s) Tj ET\n", fontBody, pageWidth-marginLeft-72, marginBottom-20,
+			pdfString(fmt.Sprintf("Page %d of %d", i+1, len(pages))))
+
+		d.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontHeading, fontBody, 6+2*i))
+		d.object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := d.buf.Len()
+	fmt.Fprintf(&d.buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.offsets)+1)
+	for _, offset := range d.offsets {
+		fmt.Fprintf(&d.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&d.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.offsets)+1, xref)
+	_, err := w.Write(d.buf.Bytes())
+	return err
+}
+
+// pdfString escapes text for a PDF literal string in WinAnsiEncoding
+// Characters outside Latin-1 are replaced with "?", as the standard fonts cannot show them
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+// Package statements renders account statements (see service.TransferService.Statement) as the
+// files served by GET /accounts/{account_id}/statement: CSV for spreadsheets and accounting
+// imports, and PDF for customers and auditors. Both are generated in process without external
+// tools; the PDF uses the standard Courier and Helvetica fonts, so it embeds none
+package statements
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+// Statement formats
+const (
+	FormatCSV = "csv"
+	FormatPDF = "pdf"
+)
+
+// ContentType returns the media type of a statement format
+func ContentType(format string) string {
+	if format == FormatPDF {
+		return "application/pdf"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FileName returns the download name of a statement, e.g. statement_123_20240101_20240201.pdf
+func FileName(statement *models.Statement, format string) string {
+	return fmt.Sprintf("statement_%d_%s_%s.%s", statement.AccountID,
+		statement.From.UTC().Format("20060102"), statement.To.UTC().Format("20060102"), format)
+}
+
+// Write renders the statement in the format (FormatCSV or FormatPDF) to w
+func Write(w io.Writer, statement *models.Statement, format string) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, statement)
+	case FormatPDF:
+		return WritePDF(w, statement)
+	default:
+		return fmt.Errorf("unknown statement format %q (expected %s or %s)", format, FormatCSV, FormatPDF)
+	}
+}
+
+// formatAmount renders an amount of the statement with its currency's decimal places; amounts of
+// accounts without a currency keep their own
+func formatAmount(statement *models.Statement, amount decimal.Decimal) string {
+	if statement.Currency != "" {
+		return amount.StringFixed(statement.Scale)
+	}
+	return amount.String()
+}
+
+// formatTime renders a statement time in UTC
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// description joins a line's reference and memo, after "Manual adjustment" for adjustments
+func description(line models.StatementLine) string {
+	parts := make([]string, 0, 3)
+	if line.AdjustmentID != 0 {
+		parts = append(parts, "Manual adjustment")
+	}
+	for _, part := range []string{line.Reference, line.Memo} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " - ")
+}
+
+// optionalID renders a line's transaction or counterparty ID, empty for the zero ID of an
+// adjustment
+func optionalID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// debitCredit splits a signed amount into its debit and credit columns; the other is empty
+func debitCredit(statement *models.Statement, amount decimal.Decimal) (debit, credit string) {
+	if amount.IsNegative() {
+		return formatAmount(statement, amount.Neg()), ""
+	}
+	return "", formatAmount(statement, amount)
+}
@@ -0,0 +1,112 @@
+package statements
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/models"
+)
+
+func testStatement(lines int) *models.Statement {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	statement := &models.Statement{
+		AccountID:      7,
+		Currency:       "USD",
+		Scale:          2,
+		From:           from,
+		To:             from.AddDate(0, 1, 0),
+		GeneratedAt:    from.AddDate(0, 1, 2),
+		OpeningBalance: decimal.NewFromInt(100),
+		TotalDebits:    decimal.Zero,
+		TotalCredits:   decimal.Zero,
+	}
+	balance := statement.OpeningBalance
+	for i := 0; i < lines; i++ {
+		amount := decimal.NewFromFloat(12.5)
+		if i%2 == 1 {
+			amount = amount.Neg()
+			statement.TotalDebits = statement.TotalDebits.Sub(amount)
+		} else {
+			statement.TotalCredits = statement.TotalCredits.Add(amount)
+		}
+		balance = balance.Add(amount)
+		statement.Lines = append(statement.Lines, models.StatementLine{
+			TransactionID: int64(i + 1), EffectiveAt: from.Add(time.Duration(i) * time.Hour), CounterpartyID: 9,
+			Reference: "INV-" + strconv.Itoa(i), Memo: "=HYPERLINK(\"x\") (rent) €", Amount: amount, Balance: balance,
+		})
+	}
+	statement.ClosingBalance = balance
+	return statement
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testStatement(2), FormatCSV); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := `date,transaction_id,description,counterparty_id,reference,memo,debit,credit,balance
+2024-01-01T00:00:00Z,,Opening balance,,,,,,100.00
+2024-01-01T00:00:00Z,1,"INV-0 - =HYPERLINK(""x"") (rent) ` + "€" + `",9,INV-0,"'=HYPERLINK(""x"") (rent) ` + "€" + `",,12.50,112.50
+2024-01-01T01:00:00Z,2,"INV-1 - =HYPERLINK(""x"") (rent) ` + "€" + `",9,INV-1,"'=HYPERLINK(""x"") (rent) ` + "€" + `",12.50,,100.00
+2024-02-01T00:00:00Z,,Closing balance,,,,12.50,12.50,100.00
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected CSV:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testStatement(150), FormatPDF); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("Expected a PDF file, got %q...", pdf[:20])
+	}
+
+	// Every cross-reference entry points at its object
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	if match == nil {
+		t.Fatal("Missing startxref")
+	}
+	xref, _ := strconv.Atoi(match[1])
+	entries := strings.Split(strings.TrimSpace(pdf[xref:strings.Index(pdf, "trailer")]), "\n")[3:]
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[:10])
+		if prefix := strconv.Itoa(i+1) + " 0 obj"; !strings.HasPrefix(pdf[offset:], prefix) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[offset:offset+10])
+		}
+	}
+
+	// 150 rows do not fit one page; each page is numbered and repeats the column headings
+	if !strings.Contains(pdf, "/Count 3 ") {
+		t.Errorf("Expected 3 pages, got %s", regexp.MustCompile(`/Count \d+`).FindString(pdf))
+	}
+	if strings.Count(pdf, "Counterparty") != 3 || !strings.Contains(pdf, "(Page 3 of 3)") {
+		t.Error("Expected the column headings and page number on every page")
+	}
+	for _, text := range []string{"(Account statement)", "Closing balance:", `\("x"\)`, "HYPERLINK"} {
+		if !strings.Contains(pdf, text) {
+			t.Errorf("Expected %q in the PDF", text)
+		}
+	}
+	if strings.Contains(pdf, "€") {
+		t.Error("Expected characters outside Latin-1 to be replaced")
+	}
+}
+
+func TestFileName(t *testing.T) {
+	if got := FileName(testStatement(0), FormatPDF); got != "statement_7_20240101_20240201.pdf" {
+		t.Errorf("Unexpected file name %q", got)
+	}
+	if err := Write(&bytes.Buffer{}, testStatement(0), "xlsx"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
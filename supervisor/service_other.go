@@ -0,0 +1,9 @@
+//go:build !windows
+
+package supervisor
+
+// RunService runs the process as a Windows service when the Service Control Manager started it
+// Always returns false without calling run: there are no Windows services on this platform
+func RunService(name string, run func(stop <-chan struct{})) (bool, error) {
+	return false, nil
+}
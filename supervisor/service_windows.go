@@ -0,0 +1,145 @@
+//go:build windows
+
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                          = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+)
+
+// Service Control Manager constants (winsvc.h, winerror.h)
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped     = 1
+	serviceStopPending = 3
+	serviceRunning     = 4
+
+	serviceAcceptStop     = 0x1
+	serviceAcceptShutdown = 0x4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+
+	// stopWaitHint is how long the SCM is told to wait for the stop before assuming a hang
+	stopWaitHint = 90_000 // milliseconds
+
+	// errorFailedServiceControllerConnect means the process was not started by the SCM
+	errorFailedServiceControllerConnect = syscall.Errno(1063)
+	errorCallNotImplemented             = syscall.Errno(120)
+)
+
+// serviceTableEntry is a SERVICE_TABLE_ENTRYW
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// serviceStatus is a SERVICE_STATUS
+type serviceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+// service is the state of the service run by RunService; the SCM callbacks cannot carry Go values
+var service struct {
+	name     *uint16
+	run      func(stop <-chan struct{})
+	stop     chan struct{}
+	stopOnce sync.Once
+	err      error
+
+	mu     sync.Mutex
+	handle uintptr
+	status serviceStatus
+}
+
+// RunService runs the process as a Windows service when the Service Control Manager started it
+// run serves until stop is closed, which happens when the service is stopped or the system shuts
+// down; the service is reported running while run serves and stopped once it returns
+// Returns false without calling run when the process was started interactively, e.g. from a console
+func RunService(name string, run func(stop <-chan struct{})) (bool, error) {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return false, fmt.Errorf("invalid service name %q: %w", name, err)
+	}
+	service.name, service.run, service.stop = namePtr, run, make(chan struct{})
+
+	table := []serviceTableEntry{{name: namePtr, proc: syscall.NewCallback(serviceMain)}, {}}
+	// Blocks until the service has stopped
+	if ok, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); ok == 0 {
+		if errors.Is(err, errorFailedServiceControllerConnect) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to connect to the service control manager: %w", err)
+	}
+	return true, service.err
+}
+
+// serviceMain is the ServiceMain the SCM calls on its own thread
+func serviceMain(argc uint32, argv **uint16) uintptr {
+	handle, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(service.name)), syscall.NewCallback(serviceHandler), 0)
+	if handle == 0 {
+		service.err = fmt.Errorf("failed to register the service control handler: %w", err)
+		return 0
+	}
+	service.mu.Lock()
+	service.handle = handle
+	service.mu.Unlock()
+
+	setServiceStatus(serviceRunning, serviceAcceptStop|serviceAcceptShutdown, 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.run(service.stop)
+	}()
+	<-done
+	setServiceStatus(serviceStopped, 0, 0)
+	return 0
+}
+
+// serviceHandler is the HandlerEx the SCM calls with control requests
+func serviceHandler(control, eventType uint32, eventData, context uintptr) uintptr {
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		setServiceStatus(serviceStopPending, 0, stopWaitHint)
+		service.stopOnce.Do(func() { close(service.stop) })
+	case serviceControlInterrogate:
+		service.mu.Lock()
+		status := service.status
+		service.mu.Unlock()
+		setServiceStatus(status.currentState, status.controlsAccepted, status.waitHint)
+	default:
+		return uintptr(errorCallNotImplemented)
+	}
+	return 0
+}
+
+// setServiceStatus reports the service state to the SCM
+func setServiceStatus(state, accepts, waitHint uint32) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	service.status = serviceStatus{
+		serviceType:      serviceWin32OwnProcess,
+		currentState:     state,
+		controlsAccepted: accepts,
+		waitHint:         waitHint,
+	}
+	procSetServiceStatus.Call(service.handle, uintptr(unsafe.Pointer(&service.status)))
+}
@@ -0,0 +1,109 @@
+// Package supervisor integrates the service with process managers outside Kubernetes. Under
+// systemd (Type=notify) it reports readiness once the server is listening, sends watchdog
+// heartbeats while the server answers its health check, and announces the shutdown; on Windows it
+// runs the server as a native service controlled by the Service Control Manager
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifier sends sd_notify messages to the systemd service manager
+// A nil Notifier, or one created outside systemd, sends nothing
+type Notifier struct {
+	socket   string
+	watchdog time.Duration
+}
+
+// NewNotifier returns a notifier for the manager named by the environment systemd sets for the
+// process, or nil when the process was not started with Type=notify
+// Variables (set by systemd):
+//   - NOTIFY_SOCKET: Datagram socket the messages are sent to; a leading "@" is an abstract socket
+//   - WATCHDOG_USEC: Watchdog timeout in microseconds (WatchdogSec=); unset disables heartbeats
+//   - WATCHDOG_PID: Process the watchdog applies to; heartbeats are disabled for any other process
+func NewNotifier() *Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	n := &Notifier{socket: socket}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n
+	}
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		n.watchdog = time.Duration(usec) * time.Microsecond
+	}
+	return n
+}
+
+// Ready reports that the service has started and is accepting requests, with a status line for
+// systemctl status
+func (n *Notifier) Ready(status string) error {
+	return n.notify("READY=1", "STATUS="+status)
+}
+
+// Stopping reports that the service is shutting down, with a status line for systemctl status
+func (n *Notifier) Stopping(status string) error {
+	return n.notify("STOPPING=1", "STATUS="+status)
+}
+
+// WatchdogInterval returns the watchdog timeout systemd enforces, or zero if there is none
+func (n *Notifier) WatchdogInterval() time.Duration {
+	if n == nil {
+		return 0
+	}
+	return n.watchdog
+}
+
+// RunWatchdog sends a heartbeat every half watchdog interval while healthy succeeds, until ctx is
+// cancelled. A failed check skips the heartbeat, so a wedged server is restarted by systemd
+// Returns immediately if systemd enforces no watchdog
+func (n *Notifier) RunWatchdog(ctx context.Context, healthy func(ctx context.Context) error) {
+	interval := n.WatchdogInterval() / 2
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		err := healthy(checkCtx)
+		cancel()
+		if err != nil {
+			slog.Warn("Health check failed; skipping the watchdog heartbeat", "error", err)
+		} else if err := n.notify("WATCHDOG=1"); err != nil {
+			slog.Error("Watchdog heartbeat error", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// notify sends one message made of the given KEY=VALUE lines
+func (n *Notifier) notify(lines ...string) error {
+	if n == nil {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: n.socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to the systemd notify socket: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(lines, "\n"))); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+//go:build linux || darwin
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// listenNotify opens a datagram socket standing in for systemd's and points NOTIFY_SOCKET at it
+func listenNotify(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+// receive returns the next message sent to the socket
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Expected a notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotifier(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if n := NewNotifier(); n != nil || n.Ready("ready") != nil || n.WatchdogInterval() != 0 {
+		t.Errorf("Expected a no-op notifier outside systemd, got %+v", n)
+	}
+
+	conn := listenNotify(t)
+	t.Setenv("WATCHDOG_USEC", "")
+	n := NewNotifier()
+	if err := n.Ready("Serving on port 8080"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := receive(t, conn); got != "READY=1\nSTATUS=Serving on port 8080" {
+		t.Errorf("Unexpected readiness message %q", got)
+	}
+	n.Stopping("Shutting down: terminated")
+	if got := receive(t, conn); got != "STOPPING=1\nSTATUS=Shutting down: terminated" {
+		t.Errorf("Unexpected stopping message %q", got)
+	}
+	if n.WatchdogInterval() != 0 {
+		t.Errorf("Expected no watchdog without WATCHDOG_USEC, got %s", n.WatchdogInterval())
+	}
+
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if got := NewNotifier().WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("Expected a 30s watchdog, got %s", got)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if got := NewNotifier().WatchdogInterval(); got != 0 {
+		t.Errorf("Expected no watchdog for another process, got %s", got)
+	}
+}
+
+func TestNotifier_RunWatchdog(t *testing.T) {
+	conn := listenNotify(t)
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "40000")
+	n := NewNotifier()
+
+	// The second check fails, so only the first and third send a heartbeat
+	var checks atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.RunWatchdog(ctx, func(ctx context.Context) error {
+			if checks.Add(1) == 2 {
+				return errors.New("unhealthy")
+			}
+			return nil
+		})
+	}()
+	for i := 0; i < 2; i++ {
+		if got := receive(t, conn); got != "WATCHDOG=1" {
+			t.Errorf("Unexpected heartbeat %q", got)
+		}
+	}
+	cancel()
+	<-done
+	if checks.Load() < 3 {
+		t.Errorf("Expected a heartbeat to be skipped for the failed check, got %d checks", checks.Load())
+	}
+}
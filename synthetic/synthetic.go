@@ -0,0 +1,270 @@
+// Package synthetic generates a synthetic ledger for load and schema validation: many accounts and
+// transfer traffic whose accounts follow a Zipf distribution, so a few hot accounts take part in
+// most transfers as they do in production. The ledger stays consistent (no overdrafts, balances
+// equal to the opening balances plus the transfers, gapless sequence numbers), so reconciliation,
+// statements and balance history work on it. It is written with database.BulkLoad by the generate
+// command, to validate indexes, partitioning and hot-account sharding at production volumes
+package synthetic
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+)
+
+// Generation defaults
+const (
+	DefaultAccounts       = 100000
+	DefaultTransfers      = 1000000
+	DefaultSkew           = 1.2
+	DefaultDays           = 30
+	DefaultFirstAccountID = 1
+	DefaultSeed           = 1
+
+	// medianAmount and amountSigma shape the log-normal transfer amounts, in cents: most are small,
+	// a long tail is large
+	medianAmount = 2500
+	amountSigma  = 1.2
+
+	// zipfDraws bounds how often a hot source without funds is drawn again before any account
+	// with funds is picked
+	zipfDraws = 16
+)
+
+// DefaultInitialBalance is every account's opening balance
+var DefaultInitialBalance = decimal.NewFromInt(1000)
+
+// Config shapes the generated ledger
+type Config struct {
+	// Accounts is the number of accounts, with consecutive IDs from FirstAccountID
+	Accounts       int
+	FirstAccountID int64
+	InitialBalance decimal.Decimal
+	Transfers      int
+	// Skew is the Zipf exponent (> 1) of the accounts' share of transfers; higher is hotter
+	Skew float64
+	// Days is the period before now the transfers are spread over, evenly
+	Days int
+	// Seed makes a run reproducible
+	Seed uint64
+}
+
+// DefaultConfig returns the default generation settings
+func DefaultConfig() Config {
+	return Config{
+		Accounts:       DefaultAccounts,
+		FirstAccountID: DefaultFirstAccountID,
+		InitialBalance: DefaultInitialBalance,
+		Transfers:      DefaultTransfers,
+		Skew:           DefaultSkew,
+		Days:           DefaultDays,
+		Seed:           DefaultSeed,
+	}
+}
+
+// Validate reports the first invalid setting
+func (c Config) Validate() error {
+	switch {
+	case c.Accounts < 2:
+		return fmt.Errorf("invalid account count %d (at least 2)", c.Accounts)
+	case c.FirstAccountID < 1 || c.FirstAccountID > math.MaxInt64-int64(c.Accounts):
+		return fmt.Errorf("invalid first account ID %d", c.FirstAccountID)
+	case !c.InitialBalance.IsPositive() || !c.InitialBalance.Equal(c.InitialBalance.Truncate(2)):
+		return fmt.Errorf("invalid initial balance %s (positive, at most 2 decimal places)", c.InitialBalance)
+	case c.Transfers < 0:
+		return fmt.Errorf("invalid transfer count %d", c.Transfers)
+	case !(c.Skew > 1):
+		return fmt.Errorf("invalid skew %v (greater than 1)", c.Skew)
+	case c.Days < 1:
+		return fmt.Errorf("invalid days %d (at least 1)", c.Days)
+	}
+	return nil
+}
+
+// Generator produces the ledger for database.BulkLoad (it implements database.BulkSource): the
+// accounts, then the transfers, then the final balances
+// Balances are kept in cents in memory, a few bytes per account, so millions of accounts fit
+type Generator struct {
+	config Config
+	rng    *rand.Rand
+	zipf   *rand.Zipf
+	// stride and offset scatter Zipf ranks over the accounts, so the hot accounts are not the
+	// lowest IDs
+	stride, offset uint64
+
+	start time.Time
+	step  time.Duration
+
+	balances  []int64
+	sequences []int64
+	touches   []uint32
+
+	accountsDone, transfersDone, balancesDone int
+}
+
+// NewGenerator creates a generator for a valid config, whose transfers end at now
+func NewGenerator(config Config, now time.Time) *Generator {
+	rng := rand.New(rand.NewPCG(config.Seed, config.Seed))
+	n := uint64(config.Accounts)
+	stride := uint64(float64(n)*0.6180339887) | 1
+	for gcd(stride, n) != 1 {
+		stride++
+	}
+	g := &Generator{
+		config:    config,
+		rng:       rng,
+		zipf:      rand.NewZipf(rng, config.Skew, 1, n-1),
+		stride:    stride,
+		offset:    rng.Uint64N(n),
+		start:     now.Add(-time.Duration(config.Days) * 24 * time.Hour),
+		balances:  make([]int64, config.Accounts),
+		sequences: make([]int64, config.Accounts),
+		touches:   make([]uint32, config.Accounts),
+	}
+	if config.Transfers > 0 {
+		g.step = time.Duration(config.Days) * 24 * time.Hour / time.Duration(config.Transfers)
+	}
+	opening := config.InitialBalance.Shift(2).IntPart()
+	for i := range g.balances {
+		g.balances[i] = opening
+	}
+	return g
+}
+
+// NextAccount returns the next account, opened when the transfers start
+func (g *Generator) NextAccount() (database.BulkAccount, bool) {
+	if g.accountsDone == g.config.Accounts {
+		return database.BulkAccount{}, false
+	}
+	account := database.BulkAccount{
+		AccountID:      g.accountID(g.accountsDone),
+		InitialBalance: g.config.InitialBalance,
+		CreatedAt:      g.start,
+	}
+	g.accountsDone++
+	return account, true
+}
+
+// NextTransfer returns the next transfer, in time order
+// Both accounts are drawn from the Zipf distribution; the amount is log-normal, capped by the
+// source's balance
+func (g *Generator) NextTransfer() (database.BulkTransfer, bool) {
+	if g.transfersDone == g.config.Transfers {
+		return database.BulkTransfer{}, false
+	}
+	source := g.fundedSource()
+	destination := g.hot()
+	for destination == source {
+		destination = g.hot()
+	}
+	amount := int64(math.Round(medianAmount * math.Exp(amountSigma*g.rng.NormFloat64())))
+	amount = max(1, min(amount, g.balances[source]))
+
+	g.balances[source] -= amount
+	g.balances[destination] += amount
+	g.sequences[source]++
+	g.sequences[destination]++
+	g.touches[source]++
+	g.touches[destination]++
+	transfer := database.BulkTransfer{
+		SourceAccountID:      g.accountID(source),
+		DestinationAccountID: g.accountID(destination),
+		Amount:               decimal.New(amount, -2),
+		SourceSequence:       g.sequences[source],
+		DestinationSequence:  g.sequences[destination],
+		CreatedAt:            g.start.Add(time.Duration(g.transfersDone) * g.step),
+	}
+	g.transfersDone++
+	return transfer, true
+}
+
+// NextBalance returns the next account's balance after all transfers; only valid once
+// NextTransfer is exhausted
+func (g *Generator) NextBalance() (database.BulkBalance, bool) {
+	if g.balancesDone == g.config.Accounts {
+		return database.BulkBalance{}, false
+	}
+	i := g.balancesDone
+	g.balancesDone++
+	return database.BulkBalance{AccountID: g.accountID(i), Balance: decimal.New(g.balances[i], -2), Sequence: g.sequences[i]}, true
+}
+
+// hot draws an account index from the Zipf distribution
+func (g *Generator) hot() int {
+	return int((g.zipf.Uint64()*g.stride + g.offset) % uint64(g.config.Accounts))
+}
+
+// fundedSource draws a source with at least a cent; once hot draws keep finding drained accounts
+// any funded account is taken, so generation never stalls
+func (g *Generator) fundedSource() int {
+	for range zipfDraws {
+		if i := g.hot(); g.balances[i] > 0 {
+			return i
+		}
+	}
+	for {
+		if i := g.rng.IntN(g.config.Accounts); g.balances[i] > 0 {
+			return i
+		}
+	}
+}
+
+// accountID returns the ID of the account at index i
+func (g *Generator) accountID(i int) int64 {
+	return g.config.FirstAccountID + int64(i)
+}
+
+// Summary describes how concentrated the generated traffic is
+type Summary struct {
+	Accounts  int
+	Transfers int
+	// HotAccounts is the busiest 1% of the accounts (at least one)
+	HotAccounts int
+	// HotShare is the fraction of transfer legs (a source or destination) on the hot accounts
+	HotShare float64
+	// Busiest is the account in the most transfers, and BusiestTransfers their number
+	Busiest          int64
+	BusiestTransfers int
+}
+
+// Summary reports the traffic generated so far
+func (g *Generator) Summary() Summary {
+	summary := Summary{Accounts: g.config.Accounts, Transfers: g.transfersDone, HotAccounts: max(1, g.config.Accounts/100)}
+	order := make([]int, g.config.Accounts)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return cmp.Compare(g.touches[b], g.touches[a]) })
+	var hotLegs int
+	for _, i := range order[:summary.HotAccounts] {
+		hotLegs += int(g.touches[i])
+	}
+	if g.transfersDone > 0 {
+		summary.HotShare = float64(hotLegs) / float64(2*g.transfersDone)
+	}
+	summary.Busiest, summary.BusiestTransfers = g.accountID(order[0]), int(g.touches[order[0]])
+	return summary
+}
+
+// WriteSummary prints the summary as a short text report
+func WriteSummary(w io.Writer, summary Summary) {
+	fmt.Fprintf(w, "%d accounts, %d transfers\n", summary.Accounts, summary.Transfers)
+	fmt.Fprintf(w, "hottest %d accounts take part in %.1f%% of transfer legs\n", summary.HotAccounts, 100*summary.HotShare)
+	fmt.Fprintf(w, "busiest account %d is in %d transfers\n", summary.Busiest, summary.BusiestTransfers)
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,112 @@
+package synthetic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/database"
+)
+
+// generate drains a generator
+func generate(config Config) (*Generator, []database.BulkAccount, []database.BulkTransfer, []database.BulkBalance) {
+	g := NewGenerator(config, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	var accounts []database.BulkAccount
+	var transfers []database.BulkTransfer
+	var balances []database.BulkBalance
+	for account, ok := g.NextAccount(); ok; account, ok = g.NextAccount() {
+		accounts = append(accounts, account)
+	}
+	for transfer, ok := g.NextTransfer(); ok; transfer, ok = g.NextTransfer() {
+		transfers = append(transfers, transfer)
+	}
+	for balance, ok := g.NextBalance(); ok; balance, ok = g.NextBalance() {
+		balances = append(balances, balance)
+	}
+	return g, accounts, transfers, balances
+}
+
+func TestGenerator_ConsistentLedger(t *testing.T) {
+	config := Config{Accounts: 500, FirstAccountID: 1000, InitialBalance: decimal.NewFromInt(50), Transfers: 20000, Skew: 1.3, Days: 7, Seed: 42}
+	_, accounts, transfers, balances := generate(config)
+	if len(accounts) != 500 || len(transfers) != 20000 || len(balances) != 500 {
+		t.Fatalf("Expected 500 accounts, 20000 transfers and 500 balances, got %d, %d, %d", len(accounts), len(transfers), len(balances))
+	}
+
+	// Replaying the transfers over the opening balances must give the final balances, never
+	// overdrawing an account or skipping a sequence number
+	balance := make(map[int64]decimal.Decimal)
+	sequence := make(map[int64]int64)
+	for _, account := range accounts {
+		balance[account.AccountID] = account.InitialBalance
+	}
+	previous := time.Time{}
+	for i, transfer := range transfers {
+		source, destination := transfer.SourceAccountID, transfer.DestinationAccountID
+		if source == destination || !transfer.Amount.IsPositive() {
+			t.Fatalf("Transfer %d: invalid %+v", i, transfer)
+		}
+		if transfer.CreatedAt.Before(previous) {
+			t.Fatalf("Transfer %d is out of time order", i)
+		}
+		previous = transfer.CreatedAt
+		balance[source] = balance[source].Sub(transfer.Amount)
+		balance[destination] = balance[destination].Add(transfer.Amount)
+		if balance[source].IsNegative() {
+			t.Fatalf("Transfer %d overdraws account %d", i, source)
+		}
+		sequence[source]++
+		sequence[destination]++
+		if transfer.SourceSequence != sequence[source] || transfer.DestinationSequence != sequence[destination] {
+			t.Fatalf("Transfer %d: sequence numbers %d/%d, want %d/%d", i, transfer.SourceSequence, transfer.DestinationSequence, sequence[source], sequence[destination])
+		}
+	}
+	for _, final := range balances {
+		if !final.Balance.Equal(balance[final.AccountID]) || final.Sequence != sequence[final.AccountID] {
+			t.Errorf("Account %d: final %s/%d, replayed %s/%d", final.AccountID, final.Balance, final.Sequence, balance[final.AccountID], sequence[final.AccountID])
+		}
+	}
+}
+
+func TestGenerator_HotAccounts(t *testing.T) {
+	config := Config{Accounts: 10000, FirstAccountID: 1, InitialBalance: decimal.NewFromInt(1000), Transfers: 50000, Skew: 1.2, Days: 1, Seed: 7}
+	g, _, transfers, _ := generate(config)
+	summary := g.Summary()
+	// Uniform traffic would put about 1% of the legs on the busiest 1% of accounts
+	if summary.HotAccounts != 100 || summary.HotShare < 0.3 {
+		t.Errorf("Expected the hottest 100 accounts in at least 30%% of legs, got %d in %.1f%%", summary.HotAccounts, 100*summary.HotShare)
+	}
+	if summary.Busiest == config.FirstAccountID {
+		t.Error("Expected the hottest account to be scattered away from the first ID")
+	}
+
+	_, _, again, _ := generate(config)
+	for i := range transfers {
+		a, b := transfers[i], again[i]
+		if a.SourceAccountID != b.SourceAccountID || a.DestinationAccountID != b.DestinationAccountID || !a.Amount.Equal(b.Amount) {
+			t.Fatalf("Expected the same seed to generate the same transfers, transfer %d differs", i)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+	for name, change := range map[string]func(*Config){
+		"one account":    func(c *Config) { c.Accounts = 1 },
+		"first ID":       func(c *Config) { c.FirstAccountID = 0 },
+		"zero balance":   func(c *Config) { c.InitialBalance = decimal.Zero },
+		"sub-cent":       func(c *Config) { c.InitialBalance = decimal.RequireFromString("10.001") },
+		"negative count": func(c *Config) { c.Transfers = -1 },
+		"skew":           func(c *Config) { c.Skew = 1 },
+		"days":           func(c *Config) { c.Days = 0 },
+	} {
+		config := DefaultConfig()
+		change(&config)
+		if config.Validate() == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
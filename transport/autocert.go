@@ -0,0 +1,329 @@
+package transport
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// Certificate management defaults
+const (
+	// RenewBefore renews an ACME certificate this long before it expires
+	RenewBefore = 30 * 24 * time.Hour
+
+	// renewCheckInterval is how often the certificate's expiry is checked
+	renewCheckInterval = time.Hour
+
+	// issueTimeout bounds obtaining a certificate during a TLS handshake
+	issueTimeout = 2 * time.Minute
+)
+
+// Files of the certificate cache directory
+const (
+	accountKeyFile  = "account.key"
+	certificateFile = "cert.pem"
+	privateKeyFile  = "key.pem"
+)
+
+// certManager obtains a certificate for the configured domains from an ACME certificate authority
+// such as Let's Encrypt, and renews it before it expires. Control of the domains is proven with
+// the TLS-ALPN-01 challenge, answered by the TLS listeners themselves, so the server must be
+// reachable on port 443 of every domain. The account key and the certificate are kept in a cache
+// directory, so restarts reuse them rather than asking the CA again
+// Safe for concurrent use
+type certManager struct {
+	domains   []string
+	dir       string
+	email     string
+	directory string
+
+	// issuing serializes obtaining certificates
+	issuing sync.Mutex
+	client  *acme.Client
+
+	mu         sync.Mutex
+	current    *tls.Certificate
+	challenges map[string]*tls.Certificate
+}
+
+// newCertManager creates a manager for the domains caching its state in dir, and loads the cached
+// certificate if it still covers every domain; an unreadable one is replaced at the first renewal
+func newCertManager(domains []string, dir, email, directory string) *certManager {
+	m := &certManager{domains: domains, dir: dir, email: email, directory: directory,
+		challenges: make(map[string]*tls.Certificate)}
+	certificate, err := tls.LoadX509KeyPair(filepath.Join(dir, certificateFile),
+		filepath.Join(dir, privateKeyFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		slog.Warn("Ignoring the invalid cached certificate", "dir", dir, "error", err)
+	case m.covers(certificate.Leaf):
+		m.current = &certificate
+	}
+	return m
+}
+
+// covers reports whether leaf names every domain of the manager
+func (m *certManager) covers(leaf *x509.Certificate) bool {
+	for _, domain := range m.domains {
+		if leaf.VerifyHostname(domain) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// certificate returns the current certificate, or nil if there is none
+func (m *certManager) certificate() *tls.Certificate {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// expiring reports whether certificate is missing or expires within RenewBefore
+func expiring(certificate *tls.Certificate, now time.Time) bool {
+	return certificate == nil || now.Add(RenewBefore).After(certificate.Leaf.NotAfter)
+}
+
+// getCertificate picks the certificate of a TLS handshake (tls.Config.GetCertificate): the
+// challenge certificate for the CA's TLS-ALPN-01 validation, otherwise the domains' certificate,
+// obtained first if there is none yet
+// Handshakes for hosts outside the domains fail; clients without SNI get the domains' certificate
+func (m *certManager) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if challenge := m.challenges[name]; challenge != nil {
+			return challenge, nil
+		}
+		return nil, fmt.Errorf("no pending challenge for %q", name)
+	}
+	if name != "" && !slices.Contains(m.domains, name) {
+		return nil, fmt.Errorf("host %q is not a configured domain", name)
+	}
+	certificate := m.certificate()
+	if certificate != nil && time.Now().Before(certificate.Leaf.NotAfter) {
+		return certificate, nil
+	}
+	ctx, cancel := context.WithTimeout(hello.Context(), issueTimeout)
+	defer cancel()
+	return m.obtain(ctx)
+}
+
+// Run renews the certificate before it expires, checking hourly until ctx is done; failures are
+// logged and retried at the next check
+func (m *certManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(renewCheckInterval)
+	defer ticker.Stop()
+	for {
+		if expiring(m.certificate(), time.Now()) {
+			if _, err := m.obtain(ctx); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Certificate renewal failed", "domains", m.domains, "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// obtain orders a certificate for the domains, answers their challenges, and caches and installs
+// the issued certificate
+// Returns the current certificate without a new order if another caller renewed it meanwhile
+func (m *certManager) obtain(ctx context.Context) (*tls.Certificate, error) {
+	m.issuing.Lock()
+	defer m.issuing.Unlock()
+	if current := m.certificate(); !expiring(current, time.Now()) {
+		return current, nil
+	}
+
+	client, err := m.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(m.domains...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to order a certificate: %w", err)
+	}
+	for _, url := range order.AuthzURLs {
+		if err := m.authorize(ctx, client, url); err != nil {
+			return nil, err
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, fmt.Errorf("certificate order failed: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: m.domains[0]}, DNSNames: m.domains}, key)
+	if err != nil {
+		return nil, err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize the certificate order: %w", err)
+	}
+	certificate, err := m.store(chain, key)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Certificate obtained", "domains", m.domains, "expires", certificate.Leaf.NotAfter)
+
+	m.mu.Lock()
+	m.current = certificate
+	m.mu.Unlock()
+	return certificate, nil
+}
+
+// authorize proves control of the domain of the authorization at url with its TLS-ALPN-01
+// challenge, unless the CA already considers it valid
+func (m *certManager) authorize(ctx context.Context, client *acme.Client, url string) error {
+	authz, err := client.GetAuthorization(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to read an authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	domain := authz.Identifier.Value
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "tls-alpn-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("%s: the CA offers no tls-alpn-01 challenge", domain)
+	}
+	certificate, err := client.TLSALPN01ChallengeCert(challenge.Token, domain)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.challenges[domain] = &certificate
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.challenges, domain)
+		m.mu.Unlock()
+	}()
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("%s: failed to accept the challenge: %w", domain, err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("%s: authorization failed: %w", domain, err)
+	}
+	return nil
+}
+
+// account returns the ACME client, registering the cached account key with the CA the first time
+// A new key is generated and cached if the directory has none
+func (m *certManager) account(ctx context.Context) (*acme.Client, error) {
+	if m.client != nil {
+		return m.client, nil
+	}
+	key, err := m.accountKey()
+	if err != nil {
+		return nil, err
+	}
+	client := &acme.Client{Key: key, DirectoryURL: m.directory, UserAgent: "internal-transfers"}
+	account := &acme.Account{}
+	if m.email != "" {
+		account.Contact = []string{"mailto:" + m.email}
+	}
+	_, err = client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register the ACME account: %w", err)
+	}
+	m.client = client
+	return client, nil
+}
+
+// accountKey reads the cached account key, generating and caching one if there is none
+func (m *certManager) accountKey() (crypto.Signer, error) {
+	path := filepath.Join(m.dir, accountKeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("invalid ACME account key %s", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, keyPEM, 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// store caches an issued certificate chain with its key and returns it ready to serve
+func (m *certManager) store(chain [][]byte, key *ecdsa.PrivateKey) (*tls.Certificate, error) {
+	var certPEM []byte
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issued certificate: %w", err)
+	}
+
+	// The key is written first, so a crash in between leaves a pair that fails to load, and is
+	// replaced, rather than a new certificate with the old key
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(m.dir, privateKeyFile), keyPEM, 0o600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(m.dir, certificateFile), certPEM, 0o600); err != nil {
+		return nil, err
+	}
+	return &certificate, nil
+}
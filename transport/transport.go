@@ -0,0 +1,320 @@
+// Package transport configures the HTTP protocols and connection management of the servers.
+// HTTP/2 multiplexes many concurrent requests over one connection, so high-concurrency callers
+// such as the SDK stop exhausting their connection pools. It is negotiated over TLS (TLS_CERT_FILE
+// and TLS_KEY_FILE) and, behind a trusted proxy that terminates TLS, can be spoken in cleartext
+// (h2c). Idle keepalive connections are closed after HTTP_IDLE_TIMEOUT, and HTTP/2 connections
+// that stop answering pings are dropped rather than holding streams forever
+//
+// Deployments without a load balancer terminating TLS can serve HTTPS directly, with a
+// certificate from files or obtained from an ACME certificate authority such as Let's Encrypt
+// (TLS_AUTOCERT_DOMAINS), and redirect cleartext HTTP to HTTPS (HTTP_REDIRECT_ADDR)
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// Defaults
+const (
+	// DefaultMaxConcurrentStreams is how many requests one HTTP/2 connection may have in flight
+	DefaultMaxConcurrentStreams = 250
+
+	// DefaultIdleTimeout closes keepalive connections without requests for this long
+	DefaultIdleTimeout = 2 * time.Minute
+
+	// DefaultPingInterval is how long an HTTP/2 connection may be silent before it is pinged
+	DefaultPingInterval = 30 * time.Second
+
+	// DefaultPingTimeout closes HTTP/2 connections whose ping is unanswered for this long
+	DefaultPingTimeout = 15 * time.Second
+
+	// DefaultAutocertDir caches ACME certificates, relative to the working directory
+	DefaultAutocertDir = "autocert-cache"
+)
+
+// Config controls the protocols and keepalives of the HTTP servers
+type Config struct {
+	// HTTP2 negotiates HTTP/2 on TLS connections (ALPN)
+	HTTP2 bool
+
+	// H2C accepts cleartext HTTP/2 with prior knowledge, for trusted proxies that terminate TLS
+	H2C bool
+
+	// MaxConcurrentStreams caps the requests in flight on one HTTP/2 connection
+	MaxConcurrentStreams int
+
+	// IdleTimeout closes HTTP/1.1 keepalive and HTTP/2 connections without requests for this long
+	IdleTimeout time.Duration
+
+	// PingInterval pings HTTP/2 connections silent for this long; zero disables pings
+	PingInterval time.Duration
+
+	// PingTimeout closes HTTP/2 connections whose ping is unanswered for this long
+	PingTimeout time.Duration
+
+	// TLSCertFile and TLSKeyFile enable TLS on TCP listeners; both empty serves cleartext
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// TLSMinVersion is the oldest TLS version accepted, tls.VersionTLS12 or tls.VersionTLS13
+	TLSMinVersion uint16
+
+	// AutocertDomains enable TLS on TCP listeners with a certificate for these domains, obtained
+	// and renewed from the ACME directory at AutocertDirectory; exclusive with TLSCertFile
+	AutocertDomains []string
+
+	// AutocertDir caches the ACME account key and the certificate across restarts
+	AutocertDir string
+
+	// AutocertEmail is the contact address of the ACME account, for expiry notices; optional
+	AutocertEmail string
+
+	// AutocertDirectory is the ACME directory URL of the certificate authority
+	AutocertDirectory string
+
+	// RedirectAddr is the address of a cleartext HTTP listener redirecting every request to
+	// HTTPS; empty disables it
+	RedirectAddr string
+
+	certificate tls.Certificate
+	autocert    *certManager
+}
+
+// LoadConfig reads the transport configuration from the environment
+// Variables:
+//   - HTTP2_ENABLED (true): Negotiate HTTP/2 on TLS connections
+//   - H2C_ENABLED (false): Accept cleartext HTTP/2 (prior knowledge); only behind trusted proxies
+//   - HTTP2_MAX_CONCURRENT_STREAMS (250): Requests in flight per HTTP/2 connection
+//   - HTTP_IDLE_TIMEOUT (2m): How long idle keepalive connections are kept open
+//   - HTTP2_PING_INTERVAL (30s): Silence after which an HTTP/2 connection is pinged; 0 disables it
+//   - HTTP2_PING_TIMEOUT (15s): How long a ping may go unanswered before the connection is closed
+//   - TLS_CERT_FILE, TLS_KEY_FILE (unset): PEM certificate chain and key serving TCP listeners
+//     over TLS; set both or neither
+//   - TLS_MIN_VERSION (1.2): Oldest TLS version accepted: 1.2 or 1.3
+//   - TLS_AUTOCERT_DOMAINS (unset): Comma separated domains to obtain a certificate for from an
+//     ACME CA, serving TCP listeners over TLS; exclusive with TLS_CERT_FILE
+//   - TLS_AUTOCERT_CACHE_DIR (autocert-cache): Directory caching the ACME account and certificate
+//   - TLS_AUTOCERT_EMAIL (unset): Contact address of the ACME account
+//   - TLS_AUTOCERT_DIRECTORY_URL (Let's Encrypt): ACME directory URL of the CA
+//   - HTTP_REDIRECT_ADDR (unset): Address of a cleartext listener redirecting to HTTPS, e.g. :80
+//
+// Returns an error for malformed values or an unreadable certificate
+func LoadConfig() (Config, error) {
+	config := Config{
+		HTTP2:                true,
+		MaxConcurrentStreams: DefaultMaxConcurrentStreams,
+		IdleTimeout:          DefaultIdleTimeout,
+		PingInterval:         DefaultPingInterval,
+		PingTimeout:          DefaultPingTimeout,
+		TLSCertFile:          os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:           os.Getenv("TLS_KEY_FILE"),
+		TLSMinVersion:        tls.VersionTLS12,
+		AutocertDir:          DefaultAutocertDir,
+		AutocertEmail:        os.Getenv("TLS_AUTOCERT_EMAIL"),
+		AutocertDirectory:    acme.LetsEncryptURL,
+		RedirectAddr:         os.Getenv("HTTP_REDIRECT_ADDR"),
+	}
+	for _, setting := range []struct {
+		name   string
+		target *bool
+	}{{"HTTP2_ENABLED", &config.HTTP2}, {"H2C_ENABLED", &config.H2C}} {
+		if value := os.Getenv(setting.name); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s %q (expected true or false)", setting.name, value)
+			}
+			*setting.target = enabled
+		}
+	}
+	if value := os.Getenv("HTTP2_MAX_CONCURRENT_STREAMS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("invalid HTTP2_MAX_CONCURRENT_STREAMS %q", value)
+		}
+		config.MaxConcurrentStreams = n
+	}
+	for _, setting := range []struct {
+		name      string
+		target    *time.Duration
+		allowZero bool
+	}{
+		{"HTTP_IDLE_TIMEOUT", &config.IdleTimeout, false},
+		{"HTTP2_PING_INTERVAL", &config.PingInterval, true},
+		{"HTTP2_PING_TIMEOUT", &config.PingTimeout, false},
+	} {
+		if value := os.Getenv(setting.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 || (d == 0 && !setting.allowZero) {
+				return Config{}, fmt.Errorf("invalid %s %q", setting.name, value)
+			}
+			*setting.target = d
+		}
+	}
+
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return Config{}, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	switch value := os.Getenv("TLS_MIN_VERSION"); value {
+	case "", "1.2":
+	case "1.3":
+		config.TLSMinVersion = tls.VersionTLS13
+	default:
+		return Config{}, fmt.Errorf("invalid TLS_MIN_VERSION %q (expected 1.2 or 1.3)", value)
+	}
+
+	for _, domain := range strings.Split(os.Getenv("TLS_AUTOCERT_DOMAINS"), ",") {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" {
+			continue
+		}
+		if strings.ContainsAny(domain, "*/: ") || net.ParseIP(domain) != nil {
+			return Config{}, fmt.Errorf("invalid TLS_AUTOCERT_DOMAINS entry %q (expected a host name)", domain)
+		}
+		config.AutocertDomains = append(config.AutocertDomains, domain)
+	}
+	for name, target := range map[string]*string{
+		"TLS_AUTOCERT_CACHE_DIR":     &config.AutocertDir,
+		"TLS_AUTOCERT_DIRECTORY_URL": &config.AutocertDirectory,
+	} {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+	if len(config.AutocertDomains) > 0 {
+		if config.TLSCertFile != "" {
+			return Config{}, fmt.Errorf("TLS_AUTOCERT_DOMAINS and TLS_CERT_FILE cannot both be set")
+		}
+		if parsed, err := url.Parse(config.AutocertDirectory); err != nil || parsed.Scheme != "https" {
+			return Config{}, fmt.Errorf("invalid TLS_AUTOCERT_DIRECTORY_URL %q (expected an https URL)", config.AutocertDirectory)
+		}
+		config.autocert = newCertManager(config.AutocertDomains, config.AutocertDir, config.AutocertEmail,
+			config.AutocertDirectory)
+	}
+
+	if config.RedirectAddr != "" {
+		if !config.TLS() {
+			return Config{}, fmt.Errorf("HTTP_REDIRECT_ADDR needs TLS (TLS_CERT_FILE or TLS_AUTOCERT_DOMAINS)")
+		}
+		if _, _, err := net.SplitHostPort(config.RedirectAddr); err != nil {
+			return Config{}, fmt.Errorf("invalid HTTP_REDIRECT_ADDR %q (expected host:port)", config.RedirectAddr)
+		}
+	}
+	if config.TLSCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid TLS certificate: %w", err)
+		}
+		config.certificate = certificate
+	}
+	return config, nil
+}
+
+// TLS reports whether TCP listeners are served over TLS
+func (c Config) TLS() bool {
+	return c.TLSCertFile != "" || len(c.AutocertDomains) > 0
+}
+
+// RenewCertificates keeps the ACME certificate renewed until ctx is done, obtaining it first if
+// the cache has none; it returns at once without TLS_AUTOCERT_DOMAINS
+func (c Config) RenewCertificates(ctx context.Context) {
+	if c.autocert != nil {
+		c.autocert.Run(ctx)
+	}
+}
+
+// NewServer returns a server for handler with the configured protocols and keepalives
+// secure serves TLS with the configured certificate; the caller passes ServeTLS(listener, "", "")
+// for it. Unix sockets are served in cleartext, protected by their file permissions
+func (c Config) NewServer(handler http.Handler, secure bool) *http.Server {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(c.HTTP2)
+	protocols.SetUnencryptedHTTP2(c.H2C)
+
+	server := &http.Server{
+		Handler:     handler,
+		Protocols:   &protocols,
+		IdleTimeout: c.IdleTimeout,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: c.MaxConcurrentStreams,
+			SendPingTimeout:      c.PingInterval,
+			PingTimeout:          c.PingTimeout,
+		},
+	}
+	if secure {
+		server.TLSConfig = c.tlsConfig()
+	}
+	return server
+}
+
+// tlsConfig returns the TLS settings of secure servers: TLS 1.2 or newer, with forward secret
+// AEAD cipher suites and modern key exchanges only (TLS 1.3 suites are not configurable)
+func (c Config) tlsConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion: c.TLSMinVersion,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519MLKEM768, tls.X25519, tls.CurveP256},
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if c.autocert != nil {
+		config.GetCertificate = c.autocert.getCertificate
+		config.NextProtos = []string{acme.ALPNProto}
+	} else {
+		config.Certificates = []tls.Certificate{c.certificate}
+	}
+	return config
+}
+
+// NewRedirectServer returns a cleartext server redirecting every request to the same URL over
+// HTTPS on httpsPort, the port of the TLS listener
+func (c Config) NewRedirectServer(httpsPort string) *http.Server {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	return &http.Server{
+		Handler:           RedirectHandler(httpsPort),
+		Protocols:         &protocols,
+		IdleTimeout:       c.IdleTimeout,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// RedirectHandler redirects every request to the same URL over HTTPS on httpsPort, with 308
+// Permanent Redirect so clients repeat the method and body
+// The port is left out of the URL when it is 443
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host += ":" + httpsPort
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawPath: r.URL.RawPath,
+			RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,241 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !config.HTTP2 || config.H2C || config.TLS() || config.MaxConcurrentStreams != DefaultMaxConcurrentStreams ||
+		config.IdleTimeout != DefaultIdleTimeout || config.PingInterval != DefaultPingInterval {
+		t.Errorf("Unexpected defaults %+v", config)
+	}
+
+	t.Setenv("H2C_ENABLED", "true")
+	t.Setenv("HTTP2_MAX_CONCURRENT_STREAMS", "1000")
+	t.Setenv("HTTP2_PING_INTERVAL", "0")
+	if config, err = LoadConfig(); err != nil || !config.H2C || config.MaxConcurrentStreams != 1000 || config.PingInterval != 0 {
+		t.Errorf("Unexpected config %+v %v", config, err)
+	}
+
+	for name, value := range map[string]string{
+		"HTTP2_ENABLED":                "sometimes",
+		"HTTP2_MAX_CONCURRENT_STREAMS": "0",
+		"HTTP_IDLE_TIMEOUT":            "0",
+		"HTTP2_PING_TIMEOUT":           "soon",
+		"TLS_CERT_FILE":                "cert.pem",
+		"TLS_MIN_VERSION":              "1.0",
+		"TLS_AUTOCERT_DOMAINS":         "*.example.com",
+		"HTTP_REDIRECT_ADDR":           ":80",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := LoadConfig(); err == nil {
+				t.Errorf("Expected an error for %s=%s", name, value)
+			}
+		})
+	}
+}
+
+// serveOnce serves a handler reporting the request's protocol and returns its address
+func serveOnce(t *testing.T, server *http.Server) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	go func() {
+		if server.TLSConfig != nil {
+			server.ServeTLS(listener, "", "")
+		} else {
+			server.Serve(listener)
+		}
+	}()
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String()
+}
+
+// protocol returns the protocol the server saw for a GET with the client
+func protocol(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 16)
+	n, _ := resp.Body.Read(buf)
+	return string(buf[:n])
+}
+
+func TestNewServer_H2C(t *testing.T) {
+	var prior http.Protocols
+	prior.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &prior}}
+
+	addr := serveOnce(t, Config{H2C: true, IdleTimeout: time.Minute}.NewServer(nil, false))
+	if got := protocol(t, client, "http://"+addr); got != "HTTP/2.0" {
+		t.Errorf("Expected h2c, got %s", got)
+	}
+
+	addr = serveOnce(t, Config{IdleTimeout: time.Minute}.NewServer(nil, false))
+	if _, err := client.Get("http://" + addr); err == nil {
+		t.Error("Expected cleartext HTTP/2 to be refused without H2C_ENABLED")
+	}
+	if got := protocol(t, http.DefaultClient, "http://"+addr); got != "HTTP/1.1" {
+		t.Errorf("Expected HTTP/1.1, got %s", got)
+	}
+}
+
+func TestNewServer_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile)
+	t.Setenv("TLS_CERT_FILE", certFile)
+	t.Setenv("TLS_KEY_FILE", keyFile)
+	config, err := LoadConfig()
+	if err != nil || !config.TLS() {
+		t.Fatalf("Expected a TLS config, got %+v %v", config, err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	addr := serveOnce(t, config.NewServer(nil, true))
+	if got := protocol(t, client, "https://"+addr); got != "HTTP/2.0" {
+		t.Errorf("Expected HTTP/2 over TLS, got %s", got)
+	}
+
+	config.HTTP2 = false
+	addr = serveOnce(t, config.NewServer(nil, true))
+	if got := protocol(t, client, "https://"+addr); got != "HTTP/1.1" {
+		t.Errorf("Expected HTTP/1.1 with HTTP/2 disabled, got %s", got)
+	}
+
+	// Clients limited to older versions or without a modern cipher suite are refused
+	for name, clientConfig := range map[string]*tls.Config{
+		"TLS 1.1": {InsecureSkipVerify: true, MaxVersion: tls.VersionTLS11},
+		"CBC suite": {InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}},
+	} {
+		conn, err := tls.Dial("tcp", addr, clientConfig)
+		if err == nil {
+			conn.Close()
+			t.Errorf("%s: expected the handshake to fail", name)
+		}
+	}
+	t.Setenv("TLS_MIN_VERSION", "1.3")
+	if config, err = LoadConfig(); err != nil || config.NewServer(nil, true).TLSConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 as the minimum version, got %+v %v", config, err)
+	}
+}
+
+func TestNewServer_Autocert(t *testing.T) {
+	dir := t.TempDir()
+	writeCertificate(t, filepath.Join(dir, certificateFile), filepath.Join(dir, privateKeyFile), "api.example.com")
+	t.Setenv("TLS_AUTOCERT_DOMAINS", "API.example.com.")
+	t.Setenv("TLS_AUTOCERT_CACHE_DIR", dir)
+	t.Setenv("HTTP_REDIRECT_ADDR", ":8081")
+	config, err := LoadConfig()
+	if err != nil || !config.TLS() || config.AutocertDomains[0] != "api.example.com" || config.AutocertDirectory != acme.LetsEncryptURL {
+		t.Fatalf("Expected an autocert config, got %+v %v", config, err)
+	}
+	t.Setenv("TLS_CERT_FILE", "cert.pem")
+	t.Setenv("TLS_KEY_FILE", "key.pem")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected an error with both a certificate file and autocert domains")
+	}
+
+	// The cached certificate is served for the domain without asking the CA; other hosts are refused
+	addr := serveOnce(t, config.NewServer(nil, true))
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: "api.example.com", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	if names := conn.ConnectionState().PeerCertificates[0].DNSNames; len(names) != 1 || names[0] != "api.example.com" {
+		t.Errorf("Expected the cached certificate, got %v", names)
+	}
+	conn.Close()
+	for name, clientConfig := range map[string]*tls.Config{
+		"other host":   {ServerName: "other.example.com", InsecureSkipVerify: true},
+		"no challenge": {ServerName: "api.example.com", InsecureSkipVerify: true, NextProtos: []string{acme.ALPNProto}},
+	} {
+		if conn, err := tls.Dial("tcp", addr, clientConfig); err == nil {
+			conn.Close()
+			t.Errorf("%s: expected the handshake to fail", name)
+		}
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	for _, tc := range []struct {
+		port, host, target, want string
+	}{
+		{"443", "api.example.com", "/v1/accounts/1?x=1", "https://api.example.com/v1/accounts/1?x=1"},
+		{"8443", "api.example.com:8080", "/health", "https://api.example.com:8443/health"},
+		{"443", "[::1]:80", "/", "https://[::1]/"},
+	} {
+		req := httptest.NewRequest("POST", tc.target, nil)
+		req.Host = tc.host
+		rr := httptest.NewRecorder()
+		RedirectHandler(tc.port).ServeHTTP(rr, req)
+		if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != tc.want {
+			t.Errorf("%s%s: expected 308 to %s, got %d %s", tc.host, tc.target, tc.want, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+}
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and the DNS names, valid for
+// another hour, and its key as PEM
+func writeCertificate(t *testing.T, certFile, keyFile string, names ...string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,412 @@
+// Package usage meters API usage per API key for chargeback between the business units sharing
+// the service. Requests are attributed to the API key that signed them once the signature has been
+// verified against the api_keys table (see Attribute), so a caller cannot charge its usage to
+// another unit; every other request, including one merely naming a key, is counted as anonymous.
+// Counters are aggregated in memory and periodically added to a daily rollup in storage, so
+// metering adds no database write to the request path; up to one flush interval of usage is lost
+// if the process dies
+package usage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/singleflight"
+
+	"internal-transfers/database"
+	"internal-transfers/models"
+)
+
+// Anonymous is the key ID usage without a verified API key is attributed to
+const Anonymous = "anonymous"
+
+// DefaultFlushInterval is how often pending usage is written to storage
+const DefaultFlushInterval = 10 * time.Second
+
+// Config controls usage metering
+type Config struct {
+	// Quotas maps API key IDs to their monthly request quota; keys without one are unlimited
+	Quotas map[string]int64
+
+	// FlushInterval is how often pending usage is written to storage
+	FlushInterval time.Duration
+}
+
+// LoadConfig reads the usage configuration from the environment
+// Variables:
+//   - USAGE_QUOTAS (unset): Monthly request quotas as comma separated key_id=requests pairs
+//   - USAGE_FLUSH_INTERVAL (10s): How often pending usage is written to storage
+//
+// Returns an error for malformed values
+func LoadConfig() (Config, error) {
+	return LoadConfigFrom(os.Getenv)
+}
+
+// LoadConfigFrom reads the usage configuration with getenv, e.g. to validate a configuration
+// reload before it is applied (see LoadConfig)
+func LoadConfigFrom(getenv func(string) string) (Config, error) {
+	config := Config{Quotas: map[string]int64{}, FlushInterval: DefaultFlushInterval}
+
+	if value := getenv("USAGE_FLUSH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return Config{}, fmt.Errorf("invalid USAGE_FLUSH_INTERVAL %q", value)
+		}
+		config.FlushInterval = interval
+	}
+
+	for _, pair := range strings.Split(getenv("USAGE_QUOTAS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		keyID, value, ok := strings.Cut(pair, "=")
+		quota, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if !ok || strings.TrimSpace(keyID) == "" || err != nil || quota < 0 {
+			return Config{}, fmt.Errorf("invalid USAGE_QUOTAS entry %q (expected key_id=requests)", pair)
+		}
+		config.Quotas[strings.TrimSpace(keyID)] = quota
+	}
+	return config, nil
+}
+
+// pendingKey identifies an unflushed counter
+type pendingKey struct {
+	keyID string
+	day   string
+}
+
+// Recorder counts requests and transfers per key and day and flushes them to storage
+// Safe for concurrent use
+type Recorder struct {
+	repo   database.UsageRepositoryInterface
+	quotas map[string]int64
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending map[pendingKey]*models.Usage
+	monthly map[string]*monthlyRequests
+	// flushes counts the completed flushes, so monthly counts loaded before one are reloaded
+	flushes int64
+	// loads shares one storage query among the concurrent loads of a key's monthly count
+	loads singleflight.Group
+}
+
+// monthlyRequests caches a quota key's requests in the current month for the quota headers
+type monthlyRequests struct {
+	month    string
+	requests int64
+	// flushes is the flush count when the count was loaded; a count loaded before the last flush
+	// is served while it is reloaded, picking up the usage other instances flushed
+	flushes int64
+}
+
+// NewRecorder creates a recorder writing to repo; quotas may be nil
+func NewRecorder(repo database.UsageRepositoryInterface, quotas map[string]int64) *Recorder {
+	return &Recorder{repo: repo, quotas: quotas, now: time.Now, pending: make(map[pendingKey]*models.Usage), monthly: make(map[string]*monthlyRequests)}
+}
+
+// counter returns the pending counter for the key and today; the caller holds r.mu
+func (r *Recorder) counter(keyID string) *models.Usage {
+	today := Today(r.now())
+	key := pendingKey{keyID: keyID, day: today.Format("2006-01-02")}
+	u, ok := r.pending[key]
+	if !ok {
+		u = &models.Usage{KeyID: keyID, Day: today}
+		r.pending[key] = u
+	}
+	return u
+}
+
+// Request counts one API request for the key
+func (r *Recorder) Request(keyID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counter(keyID).Requests++
+	if m, ok := r.monthly[keyID]; ok && m.month == r.now().UTC().Format("2006-01") {
+		m.requests++
+	}
+}
+
+// Transfer counts one committed transfer of amount for the key
+func (r *Recorder) Transfer(keyID string, amount decimal.Decimal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u := r.counter(keyID)
+	u.Transfers++
+	u.TransferVolume = u.TransferVolume.Add(amount)
+}
+
+// Flush writes the pending counters to storage
+// On failure the counters are kept and retried by the next flush
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[pendingKey]*models.Usage)
+	r.mu.Unlock()
+	// Once the counters are in storage, or back in pending, the monthly counts are reloaded, picking
+	// up the usage other instances flushed; loads reading storage meanwhile are repeated, as they
+	// missed the counters in flight
+	defer func() {
+		r.mu.Lock()
+		r.flushes++
+		r.mu.Unlock()
+	}()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	records := make([]models.Usage, 0, len(pending))
+	for _, u := range pending {
+		records = append(records, *u)
+	}
+	if err := r.repo.AddUsage(ctx, records); err != nil {
+		r.mu.Lock()
+		for key, u := range pending {
+			if current, ok := r.pending[key]; ok {
+				current.Add(*u)
+			} else {
+				r.pending[key] = u
+			}
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is cancelled, then flushes once more
+func (r *Recorder) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := r.Flush(context.Background()); err != nil {
+				slog.Error("Usage flush error", "error", err)
+			}
+			return
+		case <-time.After(interval):
+		}
+		if err := r.Flush(ctx); err != nil {
+			slog.Error("Usage flush error", "error", err)
+		}
+	}
+}
+
+// Usage returns the daily usage of a key (or all keys if keyID is empty) between from and to
+// inclusive, including counters not yet flushed, ordered by key and day
+func (r *Recorder) Usage(ctx context.Context, keyID string, from, to time.Time) ([]models.Usage, error) {
+	stored, err := r.repo.ListUsage(ctx, keyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := from.Format("2006-01-02"), to.Format("2006-01-02")
+	rows := make(map[pendingKey]*models.Usage, len(stored))
+	for i := range stored {
+		u := stored[i]
+		rows[pendingKey{keyID: u.KeyID, day: u.Day.Format("2006-01-02")}] = &u
+	}
+	r.mu.Lock()
+	for key, u := range r.pending {
+		if (keyID != "" && key.keyID != keyID) || key.day < first || key.day > last {
+			continue
+		}
+		if row, ok := rows[key]; ok {
+			row.Add(*u)
+		} else {
+			copied := *u
+			rows[key] = &copied
+		}
+	}
+	r.mu.Unlock()
+
+	records := make([]models.Usage, 0, len(rows))
+	for _, u := range rows {
+		records = append(records, *u)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].KeyID != records[j].KeyID {
+			return records[i].KeyID < records[j].KeyID
+		}
+		return records[i].Day.Before(records[j].Day)
+	})
+	return records, nil
+}
+
+// Quota returns the key's monthly request quota, if one is configured
+func (r *Recorder) Quota(keyID string) (int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	quota, ok := r.quotas[keyID]
+	return quota, ok
+}
+
+// SetQuotas replaces the monthly request quotas, e.g. when the configuration is reloaded; quotas
+// may be nil
+func (r *Recorder) SetQuotas(quotas map[string]int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quotas = quotas
+}
+
+// QuotaStatus returns the key's monthly quota, the requests left in the current month, and the
+// start of the next month when it resets; ok is false for keys without a quota
+// The month's count is loaded from storage once and counted in memory from then on; after each
+// flush it is reloaded in the background while the count in memory is served, so requests served
+// by other instances since the last flush are not included
+func (r *Recorder) QuotaStatus(ctx context.Context, keyID string) (quota, remaining int64, reset time.Time, ok bool, err error) {
+	quota, ok = r.Quota(keyID)
+	if !ok {
+		return 0, 0, time.Time{}, false, nil
+	}
+	today := Today(r.now())
+	first := today.AddDate(0, 0, 1-today.Day())
+	reset = first.AddDate(0, 1, 0)
+
+	r.mu.Lock()
+	m, cached := r.monthly[keyID]
+	cached = cached && m.month == first.Format("2006-01")
+	var used int64
+	var stale bool
+	if cached {
+		used, stale = m.requests, m.flushes != r.flushes
+	}
+	r.mu.Unlock()
+
+	switch {
+	case !cached:
+		if used, err = r.loadMonth(ctx, keyID, first, today); err != nil {
+			return 0, 0, time.Time{}, false, err
+		}
+	case stale:
+		go func() {
+			if _, err := r.loadMonth(context.WithoutCancel(ctx), keyID, first, today); err != nil {
+				slog.ErrorContext(ctx, "Usage quota reload error", "error", err)
+			}
+		}()
+	}
+	return quota, max(quota-used, 0), reset, true, nil
+}
+
+// loadMonth counts the key's requests from first to today, those in storage and those pending, and
+// caches the count, which Request then keeps up to date; concurrent loads of a key share one query
+// Returns the count
+func (r *Recorder) loadMonth(ctx context.Context, keyID string, first, today time.Time) (int64, error) {
+	month := first.Format("2006-01")
+	requests, err, _ := r.loads.Do(keyID+"/"+month, func() (any, error) {
+		for {
+			r.mu.Lock()
+			flushes := r.flushes
+			r.mu.Unlock()
+			stored, err := r.repo.ListUsage(ctx, keyID, first, today)
+			if err != nil {
+				return int64(0), err
+			}
+
+			// Pending counters are added under the lock the count is cached under, so no request is
+			// missed between the two; a flush meanwhile may have moved some from pending to storage
+			// after they were read, so the load is repeated
+			r.mu.Lock()
+			if r.flushes != flushes {
+				r.mu.Unlock()
+				continue
+			}
+			m := &monthlyRequests{month: month, flushes: flushes}
+			for _, u := range stored {
+				m.requests += u.Requests
+			}
+			from, to := first.Format("2006-01-02"), today.Format("2006-01-02")
+			for key, u := range r.pending {
+				if key.keyID == keyID && key.day >= from && key.day <= to {
+					m.requests += u.Requests
+				}
+			}
+			r.monthly[keyID] = m
+			r.mu.Unlock()
+			return m.requests, nil
+		}
+	})
+	return requests.(int64), err
+}
+
+// Now returns the recorder's current time
+func (r *Recorder) Now() time.Time {
+	return r.now()
+}
+
+// Today returns the UTC calendar date of t; usage days are UTC
+func Today(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+type keyIDKey struct{}
+
+// attributionKey carries a request's attribution from Middleware to Attribute
+type attributionKey struct{}
+
+// attribution is who a request metered by Middleware is counted for: counted is set by whichever
+// of Attribute or Middleware counts it first, as a handler behind http.TimeoutHandler may still
+// attribute its request after Middleware returned
+type attribution struct {
+	recorder *Recorder
+	counted  atomic.Bool
+}
+
+// Headers reporting a key's monthly quota on every response, so clients can pace themselves
+const (
+	QuotaLimitHeader     = "X-Quota-Limit"
+	QuotaRemainingHeader = "X-Quota-Remaining"
+	QuotaResetHeader     = "X-Quota-Reset"
+)
+
+// Middleware counts every request: for the API key its handler attributes it to (see Attribute),
+// or as anonymous once the handler returns without attributing it
+func (r *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		a := &attribution{recorder: r}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), attributionKey{}, a)))
+		if a.counted.CompareAndSwap(false, true) {
+			r.Request(Anonymous)
+		}
+	})
+}
+
+// Attribute attributes req to the API key keyID, which the caller verified req was signed with
+// (see signing.Manager.Verify), and returns req with the key ID in its context for handlers that
+// record transfers (see KeyIDFromContext)
+// Behind Middleware the request is counted for the key, and a response to a key with a quota
+// carries the quota headers; the quota itself is not enforced
+func Attribute(w http.ResponseWriter, req *http.Request, keyID string) *http.Request {
+	if a, ok := req.Context().Value(attributionKey{}).(*attribution); ok && a.counted.CompareAndSwap(false, true) {
+		a.recorder.Request(keyID)
+		quota, remaining, reset, ok, err := a.recorder.QuotaStatus(req.Context(), keyID)
+		if err != nil {
+			slog.ErrorContext(req.Context(), "Usage quota query error", "error", err)
+		}
+		if ok {
+			w.Header().Set(QuotaLimitHeader, strconv.FormatInt(quota, 10))
+			w.Header().Set(QuotaRemainingHeader, strconv.FormatInt(remaining, 10))
+			w.Header().Set(QuotaResetHeader, strconv.FormatInt(reset.Unix(), 10))
+		}
+	}
+	return req.WithContext(context.WithValue(req.Context(), keyIDKey{}, keyID))
+}
+
+// KeyIDFromContext returns the key ID stored by Attribute, or Anonymous if there is none
+func KeyIDFromContext(ctx context.Context) string {
+	if keyID, ok := ctx.Value(keyIDKey{}).(string); ok {
+		return keyID
+	}
+	return Anonymous
+}
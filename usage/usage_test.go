@@ -0,0 +1,316 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"internal-transfers/memory"
+	"internal-transfers/models"
+)
+
+// failingRepository fails writes while failing is set
+type failingRepository struct {
+	*memory.UsageRepository
+	failing bool
+}
+
+func (r *failingRepository) AddUsage(ctx context.Context, records []models.Usage) error {
+	if r.failing {
+		return errors.New("database unavailable")
+	}
+	return r.UsageRepository.AddUsage(ctx, records)
+}
+
+// gatedRepository counts the usage queries and holds each one until release is closed
+type gatedRepository struct {
+	*memory.UsageRepository
+	lists   atomic.Int32
+	release chan struct{}
+}
+
+func (r *gatedRepository) ListUsage(ctx context.Context, keyID string, from, to time.Time) ([]models.Usage, error) {
+	r.lists.Add(1)
+	<-r.release
+	return r.UsageRepository.ListUsage(ctx, keyID, from, to)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("USAGE_QUOTAS", "key_a=1000, key_b=0")
+	t.Setenv("USAGE_FLUSH_INTERVAL", "")
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(config.Quotas) != 2 || config.Quotas["key_a"] != 1000 || config.FlushInterval != DefaultFlushInterval {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	for name, env := range map[string][2]string{
+		"missing quota":    {"key_a", ""},
+		"negative quota":   {"key_a=-1", ""},
+		"invalid interval": {"", "soon"},
+		"zero interval":    {"", "0s"},
+	} {
+		t.Setenv("USAGE_QUOTAS", env[0])
+		t.Setenv("USAGE_FLUSH_INTERVAL", env[1])
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	repo := &failingRepository{UsageRepository: memory.NewStore().Usage().(*memory.UsageRepository)}
+	recorder := NewRecorder(repo, map[string]int64{"key_a": 10})
+	now := time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	recorder.Request("key_a")
+	recorder.Request("key_a")
+	recorder.Transfer("key_a", decimal.RequireFromString("10.5"))
+	recorder.Request("key_b")
+	if err := recorder.Flush(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Counters survive a failed flush and are merged with later usage
+	repo.failing = true
+	recorder.Transfer("key_a", decimal.RequireFromString("4.5"))
+	if err := recorder.Flush(ctx); err == nil {
+		t.Fatal("Expected flush error")
+	}
+	now = now.Add(2 * time.Hour)
+	recorder.Request("key_a")
+
+	// Unflushed counters are included in queries
+	day := Today(now)
+	records, err := recorder.Usage(ctx, "key_a", day.AddDate(0, 0, -1), day)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 2 || records[0].Requests != 2 || records[0].Transfers != 2 ||
+		!records[0].TransferVolume.Equal(decimal.NewFromInt(15)) || records[1].Requests != 1 || !records[1].Day.Equal(day) {
+		t.Errorf("Unexpected usage %+v", records)
+	}
+
+	repo.failing = false
+	if err := recorder.Flush(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stored, _ := repo.ListUsage(ctx, "", day.AddDate(0, 0, -1), day)
+	if len(stored) != 3 || stored[0].Transfers != 2 || stored[2].KeyID != "key_b" {
+		t.Errorf("Expected all usage stored after recovery, got %+v", stored)
+	}
+
+	if quota, ok := recorder.Quota("key_a"); !ok || quota != 10 {
+		t.Errorf("Expected quota 10, got %d (%v)", quota, ok)
+	}
+	if _, ok := recorder.Quota("key_b"); ok {
+		t.Error("Expected no quota for key_b")
+	}
+}
+
+// verifiedKeyHeader stands in for a verified signature in the tests: the handler attributes the
+// request to the key it names
+const verifiedKeyHeader = "X-Test-Verified-Key"
+
+// attributing is a handler attributing its request like a handler verifying signatures, then
+// recording the key ID it sees
+func attributing(seen *[]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if keyID := r.Header.Get(verifiedKeyHeader); keyID != "" {
+			r = Attribute(w, r, keyID)
+		}
+		if seen != nil {
+			*seen = append(*seen, KeyIDFromContext(r.Context()))
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := NewRecorder(memory.NewStore().Usage(), nil)
+	var seen []string
+	handler := recorder.Middleware(attributing(&seen))
+
+	req := httptest.NewRequest("GET", "/usage", nil)
+	req.Header.Set(verifiedKeyHeader, "ak_payroll")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	// A key merely named in a header is not attributed
+	req = httptest.NewRequest("GET", "/usage", nil)
+	req.Header.Set("X-API-Key", "ak_payroll")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(seen) != 2 || seen[0] != "ak_payroll" || seen[1] != Anonymous {
+		t.Errorf("Unexpected key IDs in context %v", seen)
+	}
+	if KeyIDFromContext(context.Background()) != Anonymous {
+		t.Error("Expected anonymous without the middleware")
+	}
+	day := Today(time.Now())
+	records, _ := recorder.Usage(context.Background(), "", day, day)
+	if len(records) != 2 || records[0].KeyID != "ak_payroll" || records[0].Requests != 1 || records[1].Requests != 1 {
+		t.Errorf("Expected one request per key, got %+v", records)
+	}
+
+	// Without the middleware the request is attributed but not counted
+	seen = nil
+	req = httptest.NewRequest("GET", "/usage", nil)
+	req.Header.Set(verifiedKeyHeader, "ak_payroll")
+	attributing(&seen).ServeHTTP(httptest.NewRecorder(), req)
+	if len(seen) != 1 || seen[0] != "ak_payroll" {
+		t.Errorf("Expected the key in context without the middleware, got %v", seen)
+	}
+	if records, _ := recorder.Usage(context.Background(), "", day, day); len(records) != 2 || records[0].Requests != 1 {
+		t.Errorf("Expected no request counted without the middleware, got %+v", records)
+	}
+}
+
+func TestMiddleware_QuotaHeaders(t *testing.T) {
+	repo := memory.NewStore().Usage()
+	keyID := "ak_payroll"
+	now := time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	if err := repo.AddUsage(ctx, []models.Usage{{KeyID: keyID, Day: Today(now).AddDate(0, 0, -1), Requests: 7}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	recorder := NewRecorder(repo, map[string]int64{keyID: 10})
+	recorder.now = func() time.Time { return now }
+	handler := recorder.Middleware(attributing(nil))
+
+	serve := func(key string) http.Header {
+		req := httptest.NewRequest("GET", "/usage", nil)
+		if key != "" {
+			req.Header.Set(verifiedKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Header()
+	}
+
+	reset := strconv.FormatInt(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC).Unix(), 10)
+	for i, expected := range []string{"2", "1", "0", "0"} {
+		header := serve(keyID)
+		if header.Get(QuotaLimitHeader) != "10" || header.Get(QuotaRemainingHeader) != expected || header.Get(QuotaResetHeader) != reset {
+			t.Errorf("Request %d: unexpected quota headers %v", i+1, header)
+		}
+	}
+
+	// The count is reloaded from storage after a flush
+	if err := recorder.Flush(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if header := serve(keyID); header.Get(QuotaRemainingHeader) != "0" {
+		t.Errorf("Expected no requests left after the flush, got %v", header)
+	}
+
+	// The quota resets with the month
+	now = now.Add(2 * time.Hour)
+	if header := serve(keyID); header.Get(QuotaRemainingHeader) != "9" {
+		t.Errorf("Expected a fresh quota in April, got %v", header)
+	}
+
+	if header := serve(""); header.Get(QuotaLimitHeader) != "" {
+		t.Errorf("Expected no quota headers without a quota, got %v", header)
+	}
+
+	// Replaced quotas apply to the next request
+	recorder.SetQuotas(map[string]int64{keyID: 20})
+	if header := serve(keyID); header.Get(QuotaLimitHeader) != "20" || header.Get(QuotaRemainingHeader) != "18" {
+		t.Errorf("Expected the replaced quota, got %v", header)
+	}
+	recorder.SetQuotas(nil)
+	if header := serve(keyID); header.Get(QuotaLimitHeader) != "" {
+		t.Errorf("Expected no quota headers once the quota is removed, got %v", header)
+	}
+}
+
+func TestQuotaStatus_ConcurrentLoads(t *testing.T) {
+	repo := &gatedRepository{UsageRepository: memory.NewStore().Usage().(*memory.UsageRepository), release: make(chan struct{})}
+	keyID := "ak_payroll"
+	ctx := context.Background()
+	recorder := NewRecorder(repo, map[string]int64{keyID: 100})
+
+	// Requests counted while the month is loading are not lost, and the loads share one query
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorder.Request(keyID)
+			if _, _, _, _, err := recorder.QuotaStatus(ctx, keyID); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	for repo.lists.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(repo.release)
+	wg.Wait()
+	if _, remaining, _, _, _ := recorder.QuotaStatus(ctx, keyID); remaining != 90 {
+		t.Errorf("Expected 90 requests left, got %d", remaining)
+	}
+	if lists := repo.lists.Load(); lists > 10 {
+		t.Errorf("Expected the month loaded at most once per request, got %d queries", lists)
+	}
+}
+
+func TestQuotaStatus_AfterFlush(t *testing.T) {
+	repo := &gatedRepository{UsageRepository: memory.NewStore().Usage().(*memory.UsageRepository), release: make(chan struct{})}
+	close(repo.release)
+	keyID := "ak_payroll"
+	ctx := context.Background()
+	recorder := NewRecorder(repo, map[string]int64{keyID: 10})
+	recorder.Request(keyID)
+	if _, remaining, _, _, _ := recorder.QuotaStatus(ctx, keyID); remaining != 9 {
+		t.Fatalf("Expected 9 requests left, got %d", remaining)
+	}
+	gate := make(chan struct{})
+	repo.release = gate
+
+	// After a flush the count in memory is served while it is reloaded in the background
+	if err := recorder.Flush(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Usage flushed by another instance
+	if err := repo.AddUsage(ctx, []models.Usage{{KeyID: keyID, Day: Today(time.Now()), Requests: 3}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	done := make(chan int64)
+	go func() {
+		_, remaining, _, _, _ := recorder.QuotaStatus(ctx, keyID)
+		done <- remaining
+	}()
+	select {
+	case remaining := <-done:
+		if remaining != 9 {
+			t.Errorf("Expected the count in memory, 9 requests left, got %d", remaining)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected QuotaStatus not to wait for storage after a flush")
+	}
+
+	recorder.Request(keyID)
+	close(gate)
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, remaining, _, _, _ := recorder.QuotaStatus(ctx, keyID)
+		if remaining == 5 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 5 requests left once reloaded, got %d", remaining)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
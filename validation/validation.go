@@ -0,0 +1,199 @@
+// Package validation checks request structs against rules declared in their validate struct
+// tags, with go-playground/validator, so each rule sits next to the field it constrains and every violation is reported with
+// the JSON field it concerns. Rules that need more than the field's own value, such as a lookup
+// of the supported currencies, stay in the request's Validate method, which adds its violations
+// to the same list
+//
+// Rules, comma separated and checked in order until one fails; each has a client-facing message:
+//   - required: the field is not its zero value
+//   - decimal: a string holding a decimal number; an empty string is left to required
+//   - positive: an integer, or a decimal string, above zero
+//   - nonnegative: an integer, or a decimal string, of zero or more
+//   - max=N: a string of at most N characters
+//   - nefield=Name: a value different from the struct's field Name
+//
+// The numeric rules skip strings that are not decimals, leaving them to the decimal rule
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+// FieldError is one violation of a request's rules
+type FieldError struct {
+	// Field is the JSON name of the invalid field
+	Field string `json:"field"`
+	// Message is client-facing, e.g. "Amount must be positive"
+	Message string `json:"message"`
+}
+
+// Errors lists the violations of a request's rules, at most one per field, in field order
+type Errors []FieldError
+
+// Error joins the violation messages
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, violation := range e {
+		messages[i] = violation.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add records a violation of field, unless the field already has one
+func (e *Errors) Add(field, message string) {
+	for _, violation := range *e {
+		if violation.Field == field {
+			return
+		}
+	}
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Err returns the violations as an error, or nil if there are none
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// validate is the shared validator: validators cache struct metadata and are safe for concurrent use
+var validate = newValidator()
+
+// newValidator returns a validator knowing this package's rules on top of the library's required,
+// max and nefield, and naming fields by their JSON names
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(fieldName)
+	rules := map[string]validator.Func{
+		"decimal": func(fl validator.FieldLevel) bool {
+			s := stringValue("decimal", fl.Field())
+			_, err := decimal.NewFromString(s)
+			return s == "" || err == nil
+		},
+		"positive": func(fl validator.FieldLevel) bool {
+			sign, ok := sign("positive", fl.Field())
+			return !ok || sign > 0
+		},
+		"nonnegative": func(fl validator.FieldLevel) bool {
+			sign, ok := sign("nonnegative", fl.Field())
+			return !ok || sign >= 0
+		},
+	}
+	for tag, rule := range rules {
+		if err := v.RegisterValidation(tag, rule, true); err != nil {
+			panic(fmt.Sprintf("validation: registering %s: %v", tag, err))
+		}
+	}
+	return v
+}
+
+// Struct checks the fields of the struct v points to, or of v itself, against their validate tags
+// Returns the violations, empty if v is valid
+// Panics on an unknown rule or a rule applied to a field of the wrong kind, as both are
+// programming errors
+func Struct(v any) Errors {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var violations validator.ValidationErrors
+	if !errors.As(err, &violations) {
+		panic(fmt.Sprintf("validation: %v", err))
+	}
+	t := reflect.Indirect(reflect.ValueOf(v)).Type()
+	var errs Errors
+	for _, violation := range violations {
+		errs.Add(violation.Field(), capitalize(fmt.Sprintf(message(t, violation), label(violation.Field()))))
+	}
+	return errs
+}
+
+// message returns the message of a violation of a field of the struct type t, with a %s verb for
+// the field's label
+func message(t reflect.Type, violation validator.FieldError) string {
+	switch violation.Tag() {
+	case "required":
+		return "%s is required"
+	case "decimal":
+		return "Invalid %s format"
+	case "positive":
+		return "%s must be positive"
+	case "nonnegative":
+		return "%s cannot be negative"
+	case "max":
+		return "%s must not exceed " + violation.Param() + " characters"
+	case "nefield":
+		other, ok := t.FieldByName(violation.Param())
+		if !ok {
+			panic(fmt.Sprintf("validation: unknown field %q", violation.Param()))
+		}
+		return "%s must differ from " + label(fieldName(other))
+	}
+	panic(fmt.Sprintf("validation: no message for rule %q", violation.Tag()))
+}
+
+// stringValue returns the value of a string field, for rules that only apply to strings
+func stringValue(rule string, field reflect.Value) string {
+	if field.Kind() != reflect.String {
+		panic(fmt.Sprintf("validation: %s applied to a %s field", rule, field.Kind()))
+	}
+	return field.String()
+}
+
+// sign returns the sign of an integer field or of a decimal string field
+// ok is false for strings that are not decimals
+func sign(rule string, field reflect.Value) (sign int, ok bool) {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := field.Int()
+		switch {
+		case n > 0:
+			return 1, true
+		case n < 0:
+			return -1, true
+		}
+		return 0, true
+	case reflect.String:
+		d, err := decimal.NewFromString(field.String())
+		if err != nil {
+			return 0, false
+		}
+		return d.Sign(), true
+	}
+	panic(fmt.Sprintf("validation: %s applied to a %s field", rule, field.Kind()))
+}
+
+// fieldName returns the JSON name of a struct field
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// label turns a JSON field name into the words messages refer to it by, e.g. "source_account_id"
+// into "source account ID"
+func label(field string) string {
+	words := strings.Split(field, "_")
+	for i, word := range words {
+		if word == "id" {
+			words[i] = "ID"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// capitalize upper-cases the first letter of a message
+func capitalize(message string) string {
+	r, size := utf8.DecodeRuneInString(message)
+	return string(unicode.ToUpper(r)) + message[size:]
+}
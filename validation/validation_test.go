@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testRequest struct {
+	SourceID      int64  `json:"source_id" validate:"positive"`
+	DestinationID int64  `json:"destination_id" validate:"positive,nefield=SourceID"`
+	Amount        string `json:"amount" validate:"required,decimal,positive"`
+	Balance       string `json:"balance" validate:"decimal,nonnegative"`
+	Memo          string `json:"memo,omitempty" validate:"max=5"`
+	Unchecked     string `json:"unchecked"`
+}
+
+func TestStruct(t *testing.T) {
+	valid := testRequest{SourceID: 1, DestinationID: 2, Amount: "10.5", Balance: "0", Memo: "rent"}
+	if errs := Struct(valid); len(errs) != 0 {
+		t.Errorf("Expected no violations, got %v", errs)
+	}
+	if err := Struct(&valid).Err(); err != nil {
+		t.Errorf("Expected a nil error, got %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		req  testRequest
+		want Errors
+	}{
+		{"Zero ID", testRequest{DestinationID: 2, Amount: "1"}, Errors{{"source_id", "Source ID must be positive"}}},
+		{"Same IDs", testRequest{SourceID: 2, DestinationID: 2, Amount: "1"}, Errors{{"destination_id", "Destination ID must differ from source ID"}}},
+		{"Missing amount", testRequest{SourceID: 1, DestinationID: 2}, Errors{{"amount", "Amount is required"}}},
+		{"Malformed amount", testRequest{SourceID: 1, DestinationID: 2, Amount: "ten"}, Errors{{"amount", "Invalid amount format"}}},
+		{"Negative balance", testRequest{SourceID: 1, DestinationID: 2, Amount: "1", Balance: "-1"}, Errors{{"balance", "Balance cannot be negative"}}},
+		{"Long memo", testRequest{SourceID: 1, DestinationID: 2, Amount: "1", Memo: "groceries"}, Errors{{"memo", "Memo must not exceed 5 characters"}}},
+		{
+			"Every field", testRequest{SourceID: -1, DestinationID: 0, Amount: "0", Balance: "x"},
+			Errors{
+				{"source_id", "Source ID must be positive"},
+				{"destination_id", "Destination ID must be positive"},
+				{"amount", "Amount must be positive"},
+				{"balance", "Invalid balance format"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if errs := Struct(tc.req); !reflect.DeepEqual(errs, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, errs)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	errs.Add("amount", "Amount must be positive")
+	errs.Add("amount", "Invalid amount format")
+	errs.Add("memo", "Memo must not exceed 5 characters")
+	if len(errs) != 2 || errs.Error() != "Amount must be positive; Memo must not exceed 5 characters" {
+		t.Errorf("Expected one violation per field, got %v", errs)
+	}
+}
+
+func TestStruct_PanicsOnUnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an unknown rule")
+		}
+	}()
+	Struct(struct {
+		ID int64 `validate:"even"`
+	}{})
+}